@@ -111,6 +111,23 @@ func TestEventId(t *testing.T) {
 	}
 }
 
+func TestEventSig(t *testing.T) {
+	definition := `[{ "type" : "event", "name" : "Transfer", "inputs": [{ "name" : "from", "type": "address", "indexed": true }, { "name" : "to", "type": "address", "indexed": true }, { "name" : "value", "type": "uint256" }] }]`
+	abi, err := JSON(strings.NewReader(definition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := abi.Events["Transfer"]
+	if sig := event.Sig(); sig != "Transfer(address,address,uint256)" {
+		t.Errorf("expected signature to be Transfer(address,address,uint256), got %s", sig)
+	}
+	// the well known topic of ERC20 transfers
+	topic := common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	if crypto.Keccak256Hash([]byte(event.Sig())) != topic {
+		t.Errorf("expected signature hash to be %x, got %x", topic, crypto.Keccak256Hash([]byte(event.Sig())))
+	}
+}
+
 // TestEventMultiValueWithArrayUnpack verifies that array fields will be counted after parsing array.
 func TestEventMultiValueWithArrayUnpack(t *testing.T) {
 	definition := `[{"name": "test", "type": "event", "inputs": [{"indexed": false, "name":"value1", "type":"uint8[2]"},{"indexed": false, "name":"value2", "type":"uint8"}]}]`
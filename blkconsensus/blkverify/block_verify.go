@@ -33,7 +33,6 @@ type Matrix interface {
 	TxPool() *core.TxPool
 	SignHelper() *signhelper.SignHelper
 	ReElection() *reelection.ReElection
-	EventMux() *event.TypeMux
 	TopNode() *topnode.TopNodeService
 }
 
@@ -22,7 +22,6 @@ import (
 	"github.com/matrix/go-matrix/blkconsensus/votepool"
 	"github.com/matrix/go-matrix/common"
 	"github.com/matrix/go-matrix/core"
-	"github.com/matrix/go-matrix/event"
 	"github.com/matrix/go-matrix/hd"
 	"github.com/matrix/go-matrix/log"
 	"github.com/matrix/go-matrix/reelection"
@@ -41,7 +40,6 @@ type ProcessManage struct {
 	txPool     *core.TxPool
 	reElection *reelection.ReElection
 	topNode    *topnode.TopNodeService
-	event      *event.TypeMux
 }
 
 func NewProcessManage(matrix Matrix) *ProcessManage {
@@ -55,7 +53,6 @@ func NewProcessManage(matrix Matrix) *ProcessManage {
 		txPool:     matrix.TxPool(),
 		reElection: matrix.ReElection(),
 		topNode:    matrix.TopNode(),
-		event:      matrix.EventMux(),
 	}
 }
 
@@ -25,7 +25,6 @@ import (
 	"github.com/matrix/go-matrix/common"
 	"github.com/matrix/go-matrix/core"
 	"github.com/matrix/go-matrix/core/types"
-	"github.com/matrix/go-matrix/event"
 	"github.com/matrix/go-matrix/log"
 	"github.com/matrix/go-matrix/matrixwork"
 	"github.com/matrix/go-matrix/mc"
@@ -370,7 +369,7 @@ func (p *Process) VerifyTxs(result *core.RetChan) {
 			return
 		}
 	}*/
-	err = work.ConsensusTransactions(p.pm.event, p.curProcessReq.txs, p.pm.bc)
+	err = work.ConsensusTransactions(p.curProcessReq.txs, p.pm.bc)
 	if err != nil {
 		log.ERROR(p.logExtraInfo(), "交易验证，共识执行交易出错!", err, "高度", p.number)
 		p.startDPOSVerify(localVerifyResultStateFailed)
@@ -535,6 +534,4 @@ func (p *Process) reElection() *reelection.ReElection { return p.pm.reElection }
 
 func (p *Process) logExtraInfo() string { return p.pm.logExtraInfo() }
 
-func (p *Process) eventMux() *event.TypeMux { return p.pm.event }
-
 func (p *Process) topNode() *topnode.TopNodeService { return p.pm.topNode }
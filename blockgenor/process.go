@@ -25,7 +25,6 @@ import (
 	"github.com/matrix/go-matrix/consensus"
 	"github.com/matrix/go-matrix/core"
 	"github.com/matrix/go-matrix/crypto"
-	"github.com/matrix/go-matrix/event"
 	"github.com/matrix/go-matrix/log"
 	"github.com/matrix/go-matrix/mc"
 	"github.com/matrix/go-matrix/reelection"
@@ -332,8 +331,6 @@ func (p *Process) txPool() *core.TxPool { return p.pm.txPool }
 
 func (p *Process) signHelper() *signhelper.SignHelper { return p.pm.signHelper }
 
-func (p *Process) eventMux() *event.TypeMux { return p.pm.matrix.EventMux() }
-
 func (p *Process) reElection() *reelection.ReElection { return p.pm.reElection }
 
 func (p *Process) backend() Backend { return p.pm.matrix }
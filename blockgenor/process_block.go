@@ -220,7 +220,7 @@ func (p *Process) insertAndBcBlock(isSelf bool, header *types.Header) (common.Ha
 
 	// Broadcast the block and announce chain insertion event
 	hash := block.Hash()
-	p.eventMux().Post(core.NewMinedBlockEvent{Block: block})
+	p.blockChain().PostMinedBlock(block)
 	p.pm.hd.SendNodeMsg(mc.HD_NewBlockInsert, &mc.HD_BlockInsertNotify{Header: insertHeader}, common.RoleValidator|common.RoleBroadcast, nil)
 	var (
 		events []interface{}
@@ -228,6 +228,7 @@ func (p *Process) insertAndBcBlock(isSelf bool, header *types.Header) (common.Ha
 	)
 	events = append(events, core.ChainEvent{Block: block, Hash: hash, Logs: logs})
 	if stat == core.CanonStatTy {
+		events = append(events, core.BalanceChangeEvent{Block: block, Changes: state.StopBalanceTracking()})
 		events = append(events, core.ChainHeadEvent{Block: block})
 	}
 	p.blockChain().PostChainEvents(events, logs)
@@ -93,8 +93,9 @@ func (p *Process) dealMinerResultVerifyBroadcast() {
 			log.INFO("==========", "Finalize:GasPrice", tx.GasPrice(), "amount", tx.Value()) //hezi
 		}
 
-		work.ProcessBroadcastTransactions(p.pm.matrix.EventMux(), result.Txs, p.pm.bc)
+		work.ProcessBroadcastTransactions(result.Txs, p.pm.bc)
 		//todo: 执行交易
+		work.State.Prepare(common.Hash{}, common.Hash{}, len(result.Txs))
 		_, err = p.blockChain().Engine().Finalize(p.blockChain(), result.Header, work.State, result.Txs, nil, work.Receipts)
 
 		if err != nil {
@@ -101,7 +101,7 @@ func (p *Process) processHeaderGen() error {
 			}
 			Txs = append(Txs, txs...)
 		}
-		work.ProcessBroadcastTransactions(p.pm.matrix.EventMux(), Txs, p.pm.bc)
+		work.ProcessBroadcastTransactions(Txs, p.pm.bc)
 
 		for _, tx := range Txs {
 			log.INFO("==========", "Finalize:GasPrice", tx.GasPrice(), "amount", tx.Value())
@@ -119,6 +119,7 @@ func (p *Process) processHeaderGen() error {
 		//	}
 		//}
 		//send to local block mining module
+		work.State.Prepare(common.Hash{}, common.Hash{}, len(Txs))
 		block, err := p.engine().Finalize(p.blockChain(), header, work.State, Txs, nil, work.Receipts)
 		if err != nil {
 			log.ERROR(p.logExtraInfo(), "Failed to finalize block for sealing", err)
@@ -147,7 +148,7 @@ func (p *Process) processHeaderGen() error {
 			return err
 		}
 
-		//work.commitTransactions(self.mux, Txs, self.chain)
+		//work.commitTransactions(Txs, self.chain)
 		// todo： update uptime
 		/*if common.IsBroadcastNumber(p.number-1) && p.number > common.GetBroadcastInterval() {
 			upTimeAccounts, err := work.GetUpTimeAccounts(p.number)
@@ -168,9 +169,10 @@ func (p *Process) processHeaderGen() error {
 		}*/
 		log.INFO(p.logExtraInfo(), "区块验证请求生成，交易部分", "完成创建work, 开始执行交易")
 
-		txsCode, Txs := work.ProcessTransactions(p.pm.matrix.EventMux(), p.pm.txPool, p.pm.bc)
+		txsCode, Txs := work.ProcessTransactions(p.pm.txPool, p.pm.bc)
 		log.INFO(p.logExtraInfo(), "区块验证请求生成，交易部分", "完成执行交易, 开始finalize")
 		log.INFO("processHeaderGen", "问题定位", "step7")
+		work.State.Prepare(common.Hash{}, common.Hash{}, len(Txs))
 		block, err := p.engine().Finalize(p.blockChain(), header, work.State, Txs, nil, work.Receipts)
 		log.INFO("问题定位-交易,", "step", 5)
 		if err != nil {
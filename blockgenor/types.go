@@ -22,7 +22,6 @@ import (
 	"github.com/matrix/go-matrix/common"
 	"github.com/matrix/go-matrix/core"
 	"github.com/matrix/go-matrix/mandb"
-	"github.com/matrix/go-matrix/event"
 	"github.com/matrix/go-matrix/hd"
 	"github.com/matrix/go-matrix/reelection"
 	"math/big"
@@ -64,7 +63,6 @@ type Backend interface {
 	BlockChain() *core.BlockChain
 	TxPool() *core.TxPool
 	ChainDb() mandb.Database
-	EventMux() *event.TypeMux
 	SignHelper() *signhelper.SignHelper
 	HD() *hd.HD
 	ReElection() *reelection.ReElection
@@ -23,7 +23,7 @@ Usage: go run build/ci.go <command> <command flags/arguments>
 
 Available commands are:
 
-   install    [ -arch architecture ] [ -cc compiler ] [ packages... ]                          -- builds packages and executables
+   install    [ -arch architecture ] [ -cc compiler ] [ -tags tags ] [ packages... ]           -- builds packages and executables
    test       [ -coverage ] [ packages... ]                                                    -- runs the tests
    lint                                                                                        -- runs certain pre-selected linters
    archive    [ -arch architecture ] [ -type zip|tar ] [ -signer key-envvar ] [ -upload dest ] -- archives build artefacts
@@ -175,6 +175,7 @@ func doInstall(cmdline []string) {
 	var (
 		arch = flag.String("arch", "", "Architecture to cross build for")
 		cc   = flag.String("cc", "", "C compiler to cross build with")
+		tags = flag.String("tags", "", "Comma separated build tags, recorded in the executables")
 	)
 	flag.CommandLine.Parse(cmdline)
 	env := build.Env()
@@ -182,11 +183,7 @@ func doInstall(cmdline []string) {
 	// Check Go version. People regularly open issues about compilation
 	// failure with outdated Go. This should save them the trouble.
 	if !strings.Contains(runtime.Version(), "devel") {
-		// Figure out the minor version number since we can't textually compare (1.10 < 1.9)
-		var minor int
-		fmt.Sscanf(strings.TrimPrefix(runtime.Version(), "go1."), "%d", &minor)
-
-		if minor < 9 {
+		if goMinorVersion() < 9 {
 			log.Println("You have Go version", runtime.Version())
 			log.Println("go-matrix requires at least Go version 1.9 and cannot")
 			log.Println("be compiled with an earlier version. Please upgrade your Go installation.")
@@ -201,7 +198,7 @@ func doInstall(cmdline []string) {
 	packages = build.ExpandPackagesNoVendor(packages)
 
 	if *arch == "" || *arch == runtime.GOARCH {
-		goinstall := goTool("install", buildFlags(env)...)
+		goinstall := goTool("install", buildFlags(env, *tags)...)
 		goinstall.Args = append(goinstall.Args, "-v")
 		goinstall.Args = append(goinstall.Args, packages...)
 		build.MustRun(goinstall)
@@ -215,7 +212,7 @@ func doInstall(cmdline []string) {
 		}
 	}
 	// Seems we are cross compiling, work around forbidden GOBIN
-	goinstall := goToolArch(*arch, *cc, "install", buildFlags(env)...)
+	goinstall := goToolArch(*arch, *cc, "install", buildFlags(env, *tags)...)
 	goinstall.Args = append(goinstall.Args, "-v")
 	goinstall.Args = append(goinstall.Args, []string{"-buildmode", "archive"}...)
 	goinstall.Args = append(goinstall.Args, packages...)
@@ -229,7 +226,7 @@ func doInstall(cmdline []string) {
 			}
 			for name := range pkgs {
 				if name == "main" {
-					gobuild := goToolArch(*arch, *cc, "build", buildFlags(env)...)
+					gobuild := goToolArch(*arch, *cc, "build", buildFlags(env, *tags)...)
 					gobuild.Args = append(gobuild.Args, "-v")
 					gobuild.Args = append(gobuild.Args, []string{"-o", executablePath(cmd.Name())}...)
 					gobuild.Args = append(gobuild.Args, "."+string(filepath.Separator)+filepath.Join("cmd", cmd.Name()))
@@ -241,11 +238,18 @@ func doInstall(cmdline []string) {
 	}
 }
 
-func buildFlags(env build.Environment) (flags []string) {
+func buildFlags(env build.Environment, tags string) (flags []string) {
 	var ld []string
 	if env.Commit != "" {
 		ld = append(ld, "-X", "main.gitCommit="+env.Commit)
 	}
+	if env.Date != "" {
+		ld = append(ld, "-X", "main.gitDate="+env.Date)
+	}
+	if tags != "" {
+		ld = append(ld, "-X", "main.buildTags="+tags)
+		flags = append(flags, "-tags", strings.Replace(tags, ",", " ", -1))
+	}
 	if runtime.GOOS == "darwin" {
 		ld = append(ld, "-s")
 	}
@@ -253,9 +257,22 @@ func buildFlags(env build.Environment) (flags []string) {
 	if len(ld) > 0 {
 		flags = append(flags, "-ldflags", strings.Join(ld, " "))
 	}
+	// Keep the local paths out of the executables, so that the same commit
+	// yields the same binaries on every machine.
+	if strings.Contains(runtime.Version(), "devel") || goMinorVersion() >= 13 {
+		flags = append(flags, "-trimpath")
+	}
 	return flags
 }
 
+// goMinorVersion returns the minor version number of the Go toolchain, since
+// versions can't be compared textually (1.10 < 1.9).
+func goMinorVersion() int {
+	var minor int
+	fmt.Sscanf(strings.TrimPrefix(runtime.Version(), "go1."), "%d", &minor)
+	return minor
+}
+
 func goTool(subcmd string, args ...string) *exec.Cmd {
 	return goToolArch(runtime.GOARCH, os.Getenv("CC"), subcmd, args...)
 }
@@ -302,7 +319,7 @@ func doTest(cmdline []string) {
 	build.MustRun(goTool("vet", packages...))
 
 	// Run the actual tests.
-	gotest := goTool("test", buildFlags(env)...)
+	gotest := goTool("test", buildFlags(env, "")...)
 	// Test a single package at a time. CI builders are slow
 	// and some tests run into timeouts under load.
 	gotest.Args = append(gotest.Args, "-p", "1")
@@ -947,7 +964,7 @@ func doXgo(cmdline []string) {
 	build.MustRun(gogetxgo)
 
 	// If all tools building is requested, build everything the builder wants
-	args := append(buildFlags(env), flag.Args()...)
+	args := append(buildFlags(env, ""), flag.Args()...)
 
 	if *alltools {
 		args = append(args, []string{"--dest", GOBIN}...)
@@ -93,6 +93,10 @@ type Identity struct {
 
 var ide = newIde()
 
+// errNotStarted is returned by the topology lookups before the identity's
+// database was opened by Start.
+var errNotStarted = errors.New("ca not started")
+
 func newIde() *Identity {
 	return &Identity{
 		quit:        make(chan struct{}),
@@ -354,6 +358,14 @@ func GetRolesByGroupOnlyBackup(roleType common.RoleType) (result []discover.Node
 	return
 }
 
+// SetSelf updates the node id of the local node, after its node key was rotated.
+func SetSelf(id discover.NodeID) {
+	ide.lock.Lock()
+	defer ide.lock.Unlock()
+
+	ide.self = id
+}
+
 // Get self identity.
 func GetRole() (role common.RoleType) {
 	ide.lock.Lock()
@@ -571,6 +583,9 @@ func GetSelfLevel() int {
 
 // GetTopologyByNumber
 func GetTopologyByNumber(reqTypes common.RoleType, number uint64) (*mc.TopologyGraph, error) {
+	if ide.ldb == nil {
+		return nil, errNotStarted
+	}
 	tgBytes := append(big.NewInt(int64(number)).Bytes(), []byte(LevelDBTopologyGraph)...)
 	val, err := ide.ldb.Get(tgBytes, nil)
 	if err != nil {
@@ -606,6 +621,9 @@ func GetTopologyByNumber(reqTypes common.RoleType, number uint64) (*mc.TopologyG
 
 // GetAccountTopologyInfo
 func GetAccountTopologyInfo(account common.Address, number uint64) (*mc.TopologyNodeInfo, error) {
+	if ide.ldb == nil {
+		return nil, errNotStarted
+	}
 	tgBytes := append(big.NewInt(int64(number)).Bytes(), []byte(LevelDBTopologyGraph)...)
 	val, err := ide.ldb.Get(tgBytes, nil)
 	if err != nil {
@@ -633,6 +651,9 @@ func GetAccountOriginalRole(account common.Address, number uint64) (common.RoleT
 			return common.RoleBroadcast, nil
 		}
 	}
+	if ide.ldb == nil {
+		return common.RoleNil, errNotStarted
+	}
 	orBytes := append(big.NewInt(int64(number)).Bytes(), []byte(LevelDBOriginalRole)...)
 	val, err := ide.ldb.Get(orBytes, nil)
 	if err != nil {
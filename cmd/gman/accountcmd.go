@@ -19,6 +19,7 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
 
 	"github.com/matrix/go-matrix/accounts"
 	"github.com/matrix/go-matrix/accounts/keystore"
@@ -26,6 +27,7 @@ import (
 	"github.com/matrix/go-matrix/console"
 	"github.com/matrix/go-matrix/crypto"
 	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/node"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -186,6 +188,24 @@ Note:
 As you can directly copy your encrypted accounts to another matrix instance,
 this import mechanism is not needed when you transfer an account between
 nodes.
+`,
+			},
+			{
+				Name:   "migrate",
+				Usage:  "Move the keystore out of the data directory",
+				Action: utils.MigrateFlags(accountMigrate),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+				},
+				Description: `
+    gman account migrate --keystore <dir>
+
+Moves the key files from the default keystore inside the data directory into
+the directory given by --keystore, which must be empty. The keys are copied
+over if the two directories are on different filesystems.
+
+Afterwards the node must always be started with the same --keystore flag.
 `,
 			},
 		},
@@ -335,6 +355,34 @@ func accountUpdate(ctx *cli.Context) error {
 	return nil
 }
 
+// accountMigrate moves the default keystore into the configured keystore directory.
+func accountMigrate(ctx *cli.Context) error {
+	cfg := gmanConfig{Node: defaultNodeConfig()}
+	// Load config file.
+	if file := ctx.GlobalString(configFileFlag.Name); file != "" {
+		if err := loadConfig(file, &cfg); err != nil {
+			utils.Fatalf("%v", err)
+		}
+	}
+	utils.SetNodeConfig(ctx, &cfg.Node)
+	if cfg.Node.KeyStoreDir == "" {
+		utils.Fatalf("No keystore directory specified, use --%s", utils.KeyStoreDirFlag.Name)
+	}
+	if cfg.Node.DataDir == "" {
+		utils.Fatalf("No data directory to migrate the keystore from")
+	}
+	_, _, keydir, err := cfg.Node.AccountConfig()
+	if err != nil {
+		utils.Fatalf("Failed to read configuration: %v", err)
+	}
+	olddir := filepath.Join(cfg.Node.DataDir, "keystore")
+	if err := node.MoveDir(olddir, keydir); err != nil {
+		utils.Fatalf("Failed to migrate keystore: %v", err)
+	}
+	fmt.Printf("Moved keystore from %s to %s\n", olddir, keydir)
+	return nil
+}
+
 func importWallet(ctx *cli.Context) error {
 	keyfile := ctx.Args().First()
 	if len(keyfile) == 0 {
@@ -0,0 +1,133 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+
+	"github.com/matrix/go-matrix/cmd/utils"
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/les"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/man"
+	"github.com/matrix/go-matrix/node"
+	"github.com/matrix/go-matrix/p2p"
+	"github.com/matrix/go-matrix/params"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+// BuildInfo identifies the code a node runs.
+type BuildInfo struct {
+	Version         string      `json:"version"`
+	GitCommit       string      `json:"gitCommit"`
+	BuildDate       string      `json:"buildDate"` // Commit date, keeping builds reproducible
+	GoVersion       string      `json:"goVersion"`
+	Platform        string      `json:"platform"`
+	BuildTags       []string    `json:"buildTags"`
+	ChainConfigHash common.Hash `json:"chainConfigHash"` // Keccak256 of the JSON chain config
+}
+
+// buildInfoService reports the build information of the node in the admin
+// namespace.
+type buildInfoService struct {
+	info BuildInfo
+}
+
+// registerBuildInfo adds the build information service to the stack. It relies
+// on the full or light node service for the chain config.
+func registerBuildInfo(stack *node.Node) {
+	err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var config *params.ChainConfig
+
+		var matrix *man.Matrix
+		if err := ctx.Service(&matrix); err == nil {
+			config = matrix.BlockChain().Config()
+		}
+		var lightMatrix *les.LightMatrix
+		if err := ctx.Service(&lightMatrix); err == nil {
+			config = lightMatrix.BlockChain().Config()
+		}
+		return &buildInfoService{info: makeBuildInfo(config)}, nil
+	})
+	if err != nil {
+		utils.Fatalf("Failed to register the build information service: %v", err)
+	}
+}
+
+// makeBuildInfo gathers the build information of the running executable.
+func makeBuildInfo(config *params.ChainConfig) BuildInfo {
+	info := BuildInfo{
+		Version:   params.VersionWithCommit(gitCommit, gitDate),
+		GitCommit: gitCommit,
+		BuildDate: gitDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		BuildTags: []string{},
+	}
+	for _, tag := range strings.Split(buildTags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			info.BuildTags = append(info.BuildTags, tag)
+		}
+	}
+	if config != nil {
+		blob, err := json.Marshal(config)
+		if err != nil {
+			log.Warn("Failed to encode chain config", "err", err)
+		} else {
+			info.ChainConfigHash = crypto.Keccak256Hash(blob)
+		}
+	}
+	return info
+}
+
+// Protocols implements node.Service.
+func (s *buildInfoService) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, adding admin_buildInfo.
+func (s *buildInfoService) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   &PrivateBuildInfoAPI{s},
+		},
+	}
+}
+
+// Start implements node.Service, logging the build information.
+func (s *buildInfoService) Start(*p2p.Server) error {
+	log.Info("Build information", "version", s.info.Version, "commit", s.info.GitCommit, "date", s.info.BuildDate,
+		"go", s.info.GoVersion, "tags", strings.Join(s.info.BuildTags, ","), "chainconfig", s.info.ChainConfigHash)
+	return nil
+}
+
+// Stop implements node.Service.
+func (s *buildInfoService) Stop() error { return nil }
+
+// PrivateBuildInfoAPI exposes the build information in the admin namespace.
+type PrivateBuildInfoAPI struct {
+	s *buildInfoService
+}
+
+// BuildInfo returns the commit, build date, Go version, build tags and chain
+// config hash of the running node.
+func (api *PrivateBuildInfoAPI) BuildInfo() BuildInfo {
+	return api.s.info
+}
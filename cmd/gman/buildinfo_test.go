@@ -0,0 +1,60 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/params"
+)
+
+func TestBuildInfo(t *testing.T) {
+	defer func(commit, date, tags string) {
+		gitCommit, gitDate, buildTags = commit, date, tags
+	}(gitCommit, gitDate, buildTags)
+
+	gitCommit, gitDate, buildTags = "0123456789abcdef0123456789abcdef01234567", "20181010", "generic, netgo"
+
+	info := makeBuildInfo(params.TestChainConfig)
+	if want := params.Version + "-01234567-20181010"; info.Version != want {
+		t.Errorf("version mismatch: have %s, want %s", info.Version, want)
+	}
+	if info.GitCommit != gitCommit || info.BuildDate != gitDate {
+		t.Errorf("commit mismatch: have %s/%s, want %s/%s", info.GitCommit, info.BuildDate, gitCommit, gitDate)
+	}
+	if want := []string{"generic", "netgo"}; !reflect.DeepEqual(info.BuildTags, want) {
+		t.Errorf("build tags mismatch: have %v, want %v", info.BuildTags, want)
+	}
+	// The chain config hash must be stable, yet tell configs apart
+	if info.ChainConfigHash == (common.Hash{}) {
+		t.Fatalf("missing chain config hash")
+	}
+	if again := makeBuildInfo(params.TestChainConfig); again.ChainConfigHash != info.ChainConfigHash {
+		t.Errorf("chain config hash unstable: %x != %x", again.ChainConfigHash, info.ChainConfigHash)
+	}
+	config := *params.TestChainConfig
+	config.ChainId = big.NewInt(12345)
+	if other := makeBuildInfo(&config); other.ChainConfigHash == info.ChainConfigHash {
+		t.Errorf("chain config hash ignores the chain id")
+	}
+	if none := makeBuildInfo(nil); none.ChainConfigHash != (common.Hash{}) {
+		t.Errorf("chain config hash without config: %x", none.ChainConfigHash)
+	}
+}
@@ -20,20 +20,26 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/matrix/go-matrix/cmd/utils"
 	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
 	"github.com/matrix/go-matrix/console"
 	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/core/rawdb"
 	"github.com/matrix/go-matrix/core/state"
+	"github.com/matrix/go-matrix/core/state/export"
 	"github.com/matrix/go-matrix/core/types"
 	"github.com/matrix/go-matrix/man/downloader"
 	"github.com/matrix/go-matrix/mandb"
-	"github.com/matrix/go-matrix/event"
+	"github.com/matrix/go-matrix/mandb/snapshot"
 	"github.com/matrix/go-matrix/log"
 	"github.com/matrix/go-matrix/trie"
 	"github.com/syndtr/goleveldb/leveldb/util"
@@ -48,7 +54,13 @@ var (
 		ArgsUsage: "<genesisPath>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.ChainDataDirFlag,
 			utils.LightModeFlag,
+			utils.NetworkIdFlag,
+			utils.FromSnapshotFlag,
+			utils.SnapshotSignersFlag,
+			utils.SnapshotThresholdFlag,
+			utils.SnapshotHashFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -56,7 +68,19 @@ The init command initializes a new genesis block and definition for the network.
 This is a destructive action and changes the network in which you will be
 participating.
 
-It expects the genesis file as argument.`,
+It expects the genesis file as argument.
+
+With --from-snapshot=<manifest URL> the chain database is instead downloaded
+from a signed snapshot. The manifest must carry at least --snapshot.threshold
+signatures from the addresses given in --snapshot.signers and belong to the
+configured network, and every file is checked against the hash listed in the
+manifest before the database is moved into place. With --snapshot.hash the
+manifest is also refused unless its head block hash is the given one.
+
+The downloaded database is only kept if its head block is the one named by the
+manifest and, if a genesis file is given or a predefined network is selected,
+its genesis block is the configured one. Trusting a snapshot means trusting its
+signers for all state up to the snapshot head.`,
 	}
 	importCommand = cli.Command{
 		Action:    utils.MigrateFlags(importChain),
@@ -65,6 +89,7 @@ It expects the genesis file as argument.`,
 		ArgsUsage: "<filename> (<filename 2> ... <filename N>) ",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.ChainDataDirFlag,
 			utils.CacheFlag,
 			utils.LightModeFlag,
 			utils.GCModeFlag,
@@ -86,6 +111,7 @@ processing will proceed even if an individual RLP-file import failure occurs.`,
 		ArgsUsage: "<filename> [<blockNumFirst> <blockNumLast>]",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.ChainDataDirFlag,
 			utils.CacheFlag,
 			utils.LightModeFlag,
 		},
@@ -103,6 +129,7 @@ if already existing.`,
 		ArgsUsage: "<datafile>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.ChainDataDirFlag,
 			utils.CacheFlag,
 			utils.LightModeFlag,
 		},
@@ -117,6 +144,7 @@ if already existing.`,
 		ArgsUsage: "<dumpfile>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.ChainDataDirFlag,
 			utils.CacheFlag,
 			utils.LightModeFlag,
 		},
@@ -131,6 +159,7 @@ The export-preimages command export hash preimages to an RLP encoded stream`,
 		ArgsUsage: "<sourceChaindataDir>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.ChainDataDirFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 			utils.FakePoWFlag,
@@ -148,6 +177,7 @@ The first argument must be the directory containing the blockchain to download f
 		ArgsUsage: " ",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.ChainDataDirFlag,
 			utils.LightModeFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
@@ -161,6 +191,7 @@ Remove blockchain and state databases`,
 		ArgsUsage: "[<blockHash> | <blockNum>]...",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.ChainDataDirFlag,
 			utils.CacheFlag,
 			utils.LightModeFlag,
 		},
@@ -169,26 +200,57 @@ Remove blockchain and state databases`,
 The arguments are interpreted as block numbers or hashes.
 Use "matrix dump 0" to dump the genesis block.`,
 	}
+	traceBackfillCommand = cli.Command{
+		Action:    utils.MigrateFlags(traceBackfill),
+		Name:      "trace-backfill",
+		Usage:     "Add historical blocks to the transaction tracing index",
+		ArgsUsage: "[<blockNumFirst>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.ChainDataDirFlag,
+			utils.CacheFlag,
+			utils.LightModeFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The trace-backfill command re-executes the canonical blocks from the given one
+(the genesis by default) up to the first block indexed by --trace.index, adding
+their call frames to the tracing index. The node must not be running.`,
+	}
+	exportStateCommand = cli.Command{
+		Action:    utils.MigrateFlags(exportState),
+		Name:      "export-state",
+		Usage:     "Export the state of a block into a chunked, resumable dump",
+		ArgsUsage: "<dir> [<blockHash> | <blockNum>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.ChainDataDirFlag,
+			utils.CacheFlag,
+			utils.LightModeFlag,
+			utils.ExportChunkSizeFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The export-state command writes all accounts and storage slots of the state of
+the given block (the head block by default) into gzipped JSON lines chunks in
+dir, along with a manifest of their checksums. An interrupted export resumes
+where it stopped when run again with the same dir.`,
+	}
 )
 
 // initGenesis will initialise the given JSON format genesis file and writes it as
 // the zero'd block (i.e. genesis) or will fail hard if it can't succeed.
 func initGenesis(ctx *cli.Context) error {
+	if ctx.IsSet(utils.FromSnapshotFlag.Name) {
+		return initSnapshot(ctx)
+	}
 	// Make sure we have a valid genesis JSON
 	genesisPath := ctx.Args().First()
 	if len(genesisPath) == 0 {
 		utils.Fatalf("Must supply path to genesis JSON file")
 	}
-	file, err := os.Open(genesisPath)
-	if err != nil {
-		utils.Fatalf("Failed to read genesis file: %v", err)
-	}
-	defer file.Close()
+	genesis := readGenesis(genesisPath)
 
-	genesis := new(core.Genesis)
-	if err := json.NewDecoder(file).Decode(genesis); err != nil {
-		utils.Fatalf("invalid genesis file: %v", err)
-	}
 	// Open an initialise both full and light databases
 	stack := makeFullNode(ctx)
 	for _, name := range []string{"chaindata", "lightchaindata"} {
@@ -205,6 +267,112 @@ func initGenesis(ctx *cli.Context) error {
 	return nil
 }
 
+// readGenesis reads the JSON format genesis file at path, failing hard if it is
+// missing or invalid.
+func readGenesis(path string) *core.Genesis {
+	file, err := os.Open(path)
+	if err != nil {
+		utils.Fatalf("Failed to read genesis file: %v", err)
+	}
+	defer file.Close()
+
+	genesis := new(core.Genesis)
+	if err := json.NewDecoder(file).Decode(genesis); err != nil {
+		utils.Fatalf("invalid genesis file: %v", err)
+	}
+	return genesis
+}
+
+// initSnapshot initializes the chain database from a signed snapshot.
+func initSnapshot(ctx *cli.Context) error {
+	var signers []common.Address
+	for _, signer := range strings.Split(ctx.String(utils.SnapshotSignersFlag.Name), ",") {
+		if signer = strings.TrimSpace(signer); signer == "" {
+			continue
+		}
+		if !common.IsHexAddress(signer) {
+			utils.Fatalf("Invalid snapshot signer address %q", signer)
+		}
+		signers = append(signers, common.HexToAddress(signer))
+	}
+	if len(signers) == 0 {
+		utils.Fatalf("Snapshot initialization requires at least one trusted signer (--%s)", utils.SnapshotSignersFlag.Name)
+	}
+	fetcher := &snapshot.Fetcher{
+		Signers:   signers,
+		Threshold: ctx.Int(utils.SnapshotThresholdFlag.Name),
+	}
+	if ctx.IsSet(utils.SnapshotHashFlag.Name) {
+		hash := ctx.String(utils.SnapshotHashFlag.Name)
+		if raw, err := hexutil.Decode(hash); err != nil || len(raw) != common.HashLength {
+			utils.Fatalf("Invalid snapshot hash %q", hash)
+		}
+		fetcher.Hash = common.HexToHash(hash)
+	}
+	stack, cfg := makeConfigNode(ctx)
+	genesis := cfg.Eth.Genesis
+	if path := ctx.Args().First(); path != "" {
+		genesis = readGenesis(path)
+	}
+	location := ctx.String(utils.FromSnapshotFlag.Name)
+	manifest, err := fetcher.Manifest(location)
+	if err != nil {
+		utils.Fatalf("Failed to verify snapshot manifest: %v", err)
+	}
+	if manifest.Network != cfg.Eth.NetworkId {
+		utils.Fatalf("Snapshot network mismatch: have %d, want %d", manifest.Network, cfg.Eth.NetworkId)
+	}
+	log.Info("Verified snapshot manifest", "network", manifest.Network, "number", manifest.Number, "hash", manifest.Hash, "files", len(manifest.Files))
+
+	light := ctx.Bool(utils.LightModeFlag.Name)
+	name := "chaindata"
+	if light {
+		name = "lightchaindata"
+	}
+	path := stack.ResolvePath(name)
+	if err := fetcher.Download(location, manifest, path); err != nil {
+		utils.Fatalf("Failed to download snapshot: %v", err)
+	}
+	chaindb, err := stack.OpenDatabase(name, 0, 0)
+	if err != nil {
+		utils.Fatalf("Failed to open snapshot database: %v", err)
+	}
+	err = checkSnapshotDatabase(chaindb, manifest, light, genesis)
+	chaindb.Close()
+	if err != nil {
+		os.RemoveAll(path)
+		utils.Fatalf("Snapshot database rejected: %v", err)
+	}
+	log.Info("Successfully initialized database from snapshot", "database", name, "number", manifest.Number, "hash", manifest.Hash)
+	return nil
+}
+
+// checkSnapshotDatabase verifies that the head of a downloaded snapshot database
+// is the block named by its manifest and, if genesis is not nil, that the
+// database is built on top of the given genesis block.
+func checkSnapshotDatabase(db mandb.Database, manifest *snapshot.Manifest, light bool, genesis *core.Genesis) error {
+	head := rawdb.ReadHeadBlockHash(db)
+	if light {
+		head = rawdb.ReadHeadHeaderHash(db)
+	}
+	if head != manifest.Hash {
+		return fmt.Errorf("head block mismatch: have %x, want %x", head, manifest.Hash)
+	}
+	if number := rawdb.ReadHeaderNumber(db, head); number == nil || *number != manifest.Number {
+		return fmt.Errorf("head block %x is not block #%d", head, manifest.Number)
+	}
+	if hash := rawdb.ReadCanonicalHash(db, manifest.Number); hash != manifest.Hash {
+		return fmt.Errorf("canonical block #%d mismatch: have %x, want %x", manifest.Number, hash, manifest.Hash)
+	}
+	if genesis != nil {
+		want := genesis.ToBlock(nil).Hash()
+		if hash := rawdb.ReadCanonicalHash(db, 0); hash != want {
+			return fmt.Errorf("genesis block mismatch: have %x, want %x", hash, want)
+		}
+	}
+	return nil
+}
+
 func importChain(ctx *cli.Context) error {
 	if len(ctx.Args()) < 1 {
 		utils.Fatalf("This command requires an argument.")
@@ -331,6 +499,72 @@ func exportChain(ctx *cli.Context) error {
 	return nil
 }
 
+// traceBackfill re-executes historical blocks to add them to the tracing index.
+func traceBackfill(ctx *cli.Context) error {
+	var from uint64
+	if len(ctx.Args()) > 0 {
+		number, err := strconv.ParseUint(ctx.Args().First(), 10, 64)
+		if err != nil {
+			utils.Fatalf("Backfill error in parsing parameters: block number not an integer\n")
+		}
+		from = number
+	}
+	stack := makeFullNode(ctx)
+	chain, _ := utils.MakeChain(ctx, stack)
+	chain.EnableTraceIndex()
+	start := time.Now()
+
+	if err := chain.BackfillTraceIndex(from); err != nil {
+		utils.Fatalf("Backfill error: %v\n", err)
+	}
+	chain.Stop()
+	fmt.Printf("Backfill done in %v\n", time.Since(start))
+	return nil
+}
+
+// exportState exports the state of a block into a directory of chunks.
+func exportState(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	block := chain.CurrentBlock()
+	if arg := ctx.Args().Get(1); arg != "" {
+		if hashish(arg) {
+			block = chain.GetBlockByHash(common.HexToHash(arg))
+		} else {
+			num, _ := strconv.Atoi(arg)
+			block = chain.GetBlockByNumber(uint64(num))
+		}
+	}
+	if block == nil {
+		utils.Fatalf("block not found")
+	}
+	exporter, err := export.New(state.NewDatabase(chainDb), block.Root(), ctx.Args().First(), ctx.Int(utils.ExportChunkSizeFlag.Name))
+	if err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	// stop in a resumable way on interrupt
+	quit := make(chan struct{})
+	go func() {
+		sigc := make(chan os.Signal, 1)
+		signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+		<-sigc
+		close(quit)
+	}()
+	start := time.Now()
+	if err := exporter.Run(quit); err != nil {
+		progress := exporter.Progress()
+		utils.Fatalf("Export error after %d accounts and %d slots: %v\n", progress.Accounts, progress.Slots, err)
+	}
+	progress := exporter.Progress()
+	fmt.Printf("Exported %d accounts and %d slots of block %d in %d chunks in %v\n", progress.Accounts, progress.Slots, block.NumberU64(), progress.Chunks, time.Since(start))
+	return nil
+}
+
 // importPreimages imports preimage data from the specified file.
 func importPreimages(ctx *cli.Context) error {
 	if len(ctx.Args()) < 1 {
@@ -373,7 +607,7 @@ func copyDb(ctx *cli.Context) error {
 	chain, chainDb := utils.MakeChain(ctx, stack)
 
 	syncmode := *utils.GlobalTextMarshaler(ctx, utils.SyncModeFlag.Name).(*downloader.SyncMode)
-	dl := downloader.New(syncmode, chainDb, new(event.TypeMux), chain, nil, nil)
+	dl := downloader.New(syncmode, chainDb, chain, nil, nil)
 
 	// Create a source peer to satisfy downloader requests from
 	db, err := mandb.NewLDBDatabase(ctx.Args().First(), ctx.GlobalInt(utils.CacheFlag.Name), 256)
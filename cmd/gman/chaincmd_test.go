@@ -0,0 +1,50 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/mandb"
+	"github.com/matrix/go-matrix/mandb/snapshot"
+	"github.com/matrix/go-matrix/params"
+)
+
+func TestCheckSnapshotDatabase(t *testing.T) {
+	db := mandb.NewMemDatabase()
+	genesis := &core.Genesis{Config: params.TestChainConfig, ExtraData: []byte("snapshot")}
+	block := genesis.MustCommit(db)
+
+	manifest := &snapshot.Manifest{Number: 0, Hash: block.Hash()}
+	if err := checkSnapshotDatabase(db, manifest, false, genesis); err != nil {
+		t.Errorf("matching snapshot rejected: %v", err)
+	}
+	if err := checkSnapshotDatabase(db, manifest, true, nil); err != nil {
+		t.Errorf("matching light snapshot rejected: %v", err)
+	}
+	other := &core.Genesis{Config: params.TestChainConfig, ExtraData: []byte("other")}
+	if err := checkSnapshotDatabase(db, manifest, false, other); err == nil {
+		t.Error("snapshot of another genesis accepted")
+	}
+	if err := checkSnapshotDatabase(db, &snapshot.Manifest{Number: 0, Hash: other.ToBlock(nil).Hash()}, false, nil); err == nil {
+		t.Error("snapshot with another head accepted")
+	}
+	if err := checkSnapshotDatabase(db, &snapshot.Manifest{Number: 1, Hash: block.Hash()}, false, nil); err == nil {
+		t.Error("snapshot with another head number accepted")
+	}
+}
@@ -28,10 +28,14 @@ import (
 	cli "gopkg.in/urfave/cli.v1"
 
 	"github.com/matrix/go-matrix/cmd/utils"
+	"github.com/matrix/go-matrix/common"
 	"github.com/matrix/go-matrix/dashboard"
+	"github.com/matrix/go-matrix/faucet"
 	"github.com/matrix/go-matrix/man"
 	"github.com/matrix/go-matrix/node"
 	"github.com/matrix/go-matrix/params"
+	"github.com/matrix/go-matrix/userop"
+	"github.com/matrix/go-matrix/watchdog"
 	whisper "github.com/matrix/go-matrix/whisper/whisperv6"
 	"github.com/naoina/toml"
 )
@@ -74,12 +78,23 @@ type manstatsConfig struct {
 	URL string `toml:",omitempty"`
 }
 
+// logConfig holds the logging settings of the configuration file. They take
+// effect on configuration reloads and yield to the command line flags.
+type logConfig struct {
+	Verbosity int    `toml:",omitempty"` // Log level (1=error, ..., 5=trace), zero keeps the --verbosity level
+	Vmodule   string `toml:",omitempty"` // Per-module verbosity pattern
+}
+
 type gmanConfig struct {
+	Log       logConfig
 	Eth       man.Config
 	Shh       whisper.Config
 	Node      node.Config
 	Ethstats  manstatsConfig
 	Dashboard dashboard.Config
+	Faucet    faucet.Config
+	UserOp    userop.Config
+	Watchdog  watchdog.Config
 }
 
 func loadConfig(file string, cfg *gmanConfig) error {
@@ -100,21 +115,28 @@ func loadConfig(file string, cfg *gmanConfig) error {
 func defaultNodeConfig() node.Config {
 	cfg := node.DefaultConfig
 	cfg.Name = clientIdentifier
-	cfg.Version = params.VersionWithCommit(gitCommit)
+	cfg.Version = params.VersionWithCommit(gitCommit, gitDate)
 	cfg.HTTPModules = append(cfg.HTTPModules, "man", "shh")
 	cfg.WSModules = append(cfg.WSModules, "man", "shh")
 	cfg.IPCPath = "gman.ipc"
 	return cfg
 }
 
-func makeConfigNode(ctx *cli.Context) (*node.Node, gmanConfig) {
-	// Load defaults.
-	cfg := gmanConfig{
+func defaultGmanConfig() gmanConfig {
+	return gmanConfig{
 		Eth:       man.DefaultConfig,
 		Shh:       whisper.DefaultConfig,
 		Node:      defaultNodeConfig(),
 		Dashboard: dashboard.DefaultConfig,
+		Faucet:    faucet.DefaultConfig,
+		UserOp:    userop.DefaultConfig,
+		Watchdog:  watchdog.DefaultConfig,
 	}
+}
+
+func makeConfigNode(ctx *cli.Context) (*node.Node, gmanConfig) {
+	// Load defaults.
+	cfg := defaultGmanConfig()
 
 	// Load config file.
 	if file := ctx.GlobalString(configFileFlag.Name); file != "" {
@@ -136,6 +158,9 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gmanConfig) {
 
 	utils.SetShhConfig(ctx, stack, &cfg.Shh)
 	utils.SetDashboardConfig(ctx, &cfg.Dashboard)
+	utils.SetFaucetConfig(ctx, &cfg.Faucet)
+	utils.SetUserOpConfig(ctx, &cfg.UserOp)
+	utils.SetWatchdogConfig(ctx, &cfg.Watchdog)
 
 	return stack, cfg
 }
@@ -171,10 +196,31 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 		utils.RegisterShhService(stack, &cfg.Shh)
 	}
 
+	// Add the faucet if requested, it relies on the full node service.
+	if ctx.GlobalBool(utils.FaucetEnabledFlag.Name) {
+		utils.RegisterFaucetService(stack, &cfg.Faucet)
+	}
+
+	// Add the user operation pool if an entrypoint is configured, it relies on
+	// the full node service.
+	if cfg.UserOp.EntryPoint != (common.Address{}) {
+		utils.RegisterUserOpService(stack, &cfg.UserOp)
+	}
+
+	// Add the watchdog if requested.
+	if ctx.GlobalBool(utils.WatchdogEnabledFlag.Name) {
+		utils.RegisterWatchdogService(stack, &cfg.Watchdog)
+	}
+
 	// Add the Matrix Stats daemon if requested.
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, cfg.Ethstats.URL)
 	}
+
+	// Add the configuration reloader and the build information, they rely on
+	// the full node service.
+	registerConfigReloader(ctx, stack, cfg)
+	registerBuildInfo(stack)
 	return stack
 }
 
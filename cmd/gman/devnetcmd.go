@@ -0,0 +1,500 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/matrix/go-matrix/accounts/keystore"
+	"github.com/matrix/go-matrix/cmd/utils"
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/p2p/discover"
+	"github.com/matrix/go-matrix/params"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	devnetDirFlag = cli.StringFlag{
+		Name:  "dir",
+		Value: "devnet",
+		Usage: "Directory holding the genesis, keys and data directories of the network",
+	}
+	devnetValidatorsFlag = cli.IntFlag{
+		Name:  "validators",
+		Value: 3,
+		Usage: "Number of validator nodes",
+	}
+	devnetFullNodesFlag = cli.IntFlag{
+		Name:  "fullnodes",
+		Value: 1,
+		Usage: "Number of full nodes",
+	}
+	devnetAccountsFlag = cli.IntFlag{
+		Name:  "accounts",
+		Value: 4,
+		Usage: "Number of accounts funded in the genesis",
+	}
+	devnetBalanceFlag = cli.Uint64Flag{
+		Name:  "balance",
+		Value: 1000000,
+		Usage: "Genesis balance of each funded account in MAN",
+	}
+	devnetSeedFlag = cli.StringFlag{
+		Name:  "seed",
+		Value: "devnet",
+		Usage: "Seed all node and account keys are derived from",
+	}
+	devnetNetworkIdFlag = cli.Uint64Flag{
+		Name:  "networkid",
+		Value: 1337,
+		Usage: "Network and chain identifier of the network",
+	}
+	devnetPortFlag = cli.IntFlag{
+		Name:  "port",
+		Value: 30310,
+		Usage: "Network listening port of the first node, the others use the following ones",
+	}
+	devnetRPCPortFlag = cli.IntFlag{
+		Name:  "rpcport",
+		Value: 8545,
+		Usage: "HTTP-RPC port of the first node, the others use the following ones",
+	}
+	devnetResetFlag = cli.BoolFlag{
+		Name:  "reset",
+		Usage: "Remove an existing network in the directory and generate a new one",
+	}
+
+	devnetCommand = cli.Command{
+		Name:     "devnet",
+		Usage:    "Run a local multi-node test network",
+		Category: "MISCELLANEOUS COMMANDS",
+		Description: `
+The devnet commands run a MATRIX network of validator and full nodes on the
+local host, each a gman process with its own data directory and ports.`,
+		Subcommands: []cli.Command{
+			{
+				Name:   "up",
+				Usage:  "Generate, start and run a local network until interrupted",
+				Action: utils.MigrateFlags(devnetUp),
+				Flags: []cli.Flag{
+					devnetDirFlag,
+					devnetValidatorsFlag,
+					devnetFullNodesFlag,
+					devnetAccountsFlag,
+					devnetBalanceFlag,
+					devnetSeedFlag,
+					devnetNetworkIdFlag,
+					devnetPortFlag,
+					devnetRPCPortFlag,
+					devnetResetFlag,
+				},
+				Description: `
+    gman devnet up --validators 3 --fullnodes 1
+
+generates a network into --dir on the first run: a genesis electing the
+validators, with their deposits and --accounts funded accounts, and a data
+directory per node initialised with it. All keys are derived from --seed, so
+the same flags always generate the same network. The keys of the funded
+accounts are written to accounts.txt, usable as gman loadtest --keys.
+
+The nodes are then started, listening on consecutive ports from --port and
+serving HTTP-RPC on consecutive ports from --rpcport, every node using the
+validators as bootnodes. Their output goes to gman.log in their data
+directories, nodes.json lists their roles, enodes and RPC endpoints.
+
+Interrupting the command stops all the nodes. Running it again restarts the
+network in the directory, --reset generates a new one instead.`,
+			},
+		},
+	}
+)
+
+const (
+	devnetValidator   = "validator"
+	devnetFullNode    = "full"
+	devnetPassword    = "devnet"
+	devnetStopTimeout = 10 * time.Second
+)
+
+var (
+	// devnetDepositAddr is the address of the deposit contract validators are
+	// elected from, see vm.MatrixDeposit.
+	devnetDepositAddr = common.BytesToAddress([]byte{10})
+
+	// devnetDeposit is the validator deposit threshold of the deposit
+	// contract, 100000 MAN.
+	devnetDeposit = new(big.Int).Mul(big.NewInt(100000), big.NewInt(params.Ether))
+)
+
+// devnetNode is a node of a local test network as listed in nodes.json.
+type devnetNode struct {
+	Name    string         `json:"name"`
+	Role    string         `json:"role"`
+	Account common.Address `json:"account"`
+	Enode   string         `json:"enode"`
+	Port    int            `json:"port"`
+	RPCPort int            `json:"rpcPort"`
+	DataDir string         `json:"datadir"`
+}
+
+// devnetUp generates a local test network unless one exists in the directory,
+// and runs its nodes until interrupted.
+func devnetUp(ctx *cli.Context) error {
+	dir, err := filepath.Abs(ctx.String(devnetDirFlag.Name))
+	if err != nil {
+		utils.Fatalf("Invalid devnet directory: %v", err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		utils.Fatalf("Failed to locate the gman executable: %v", err)
+	}
+	nodes, err := loadDevnet(dir)
+	if err == nil && ctx.Bool(devnetResetFlag.Name) {
+		if err := os.RemoveAll(dir); err != nil {
+			utils.Fatalf("Failed to remove devnet: %v", err)
+		}
+		nodes, err = nil, os.ErrNotExist
+	}
+	if os.IsNotExist(err) {
+		// Never generate into (and clean up) a directory holding anything else
+		if files, _ := ioutil.ReadDir(dir); len(files) > 0 {
+			utils.Fatalf("Directory %s is not empty and holds no devnet", dir)
+		}
+		if nodes, err = devnetGenerate(ctx, dir); err != nil {
+			os.RemoveAll(dir)
+			utils.Fatalf("Failed to generate devnet: %v", err)
+		}
+		for _, n := range nodes {
+			init := exec.Command(exe, "--datadir", n.DataDir, "init", filepath.Join(dir, "genesis.json"))
+			init.Dir = n.DataDir
+			if out, err := init.CombinedOutput(); err != nil {
+				os.RemoveAll(dir)
+				utils.Fatalf("Failed to initialise %s: %v\n%s", n.Name, err, out)
+			}
+		}
+		log.Info("Generated devnet", "dir", dir, "nodes", len(nodes))
+	} else if err != nil {
+		utils.Fatalf("Failed to load devnet: %v", err)
+	} else {
+		log.Info("Restarting devnet", "dir", dir, "nodes", len(nodes))
+	}
+	genesis, err := loadDevnetGenesis(dir)
+	if err != nil {
+		utils.Fatalf("Failed to load devnet genesis: %v", err)
+	}
+	networkId := genesis.Config.ChainId.Uint64()
+
+	// Start all the nodes, stopping the running ones if one fails to
+	var running []*devnetProcess
+	stop := func() {
+		for _, p := range running {
+			p.cmd.Process.Signal(os.Interrupt)
+		}
+		deadline := time.After(devnetStopTimeout)
+		for _, p := range running {
+			select {
+			case <-p.done:
+			case <-deadline:
+				log.Warn("Killing devnet node", "name", p.node.Name)
+				p.cmd.Process.Kill()
+				<-p.done
+			}
+		}
+	}
+	exited := make(chan *devnetNode, len(nodes))
+	for _, n := range nodes {
+		p, err := startDevnetNode(exe, n, nodes, networkId, filepath.Join(dir, "password.txt"))
+		if err != nil {
+			stop()
+			utils.Fatalf("Failed to start %s: %v", n.Name, err)
+		}
+		running = append(running, p)
+		go func() {
+			<-p.done
+			exited <- p.node
+		}()
+		fmt.Printf("%-12s %-9s %s\n%-22s http://127.0.0.1:%d\n", n.Name, n.Role, n.Enode, "", n.RPCPort)
+	}
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigc)
+
+	select {
+	case <-sigc:
+		log.Info("Stopping devnet")
+	case n := <-exited:
+		log.Error("Devnet node exited, stopping devnet", "name", n.Name, "log", filepath.Join(n.DataDir, "gman.log"))
+	}
+	stop()
+	return nil
+}
+
+// devnetProcess is the gman process running a node of a local test network.
+type devnetProcess struct {
+	node *devnetNode
+	cmd  *exec.Cmd
+	done chan struct{} // closed once the process exited
+}
+
+// startDevnetNode starts the process of a node, logging to gman.log in its
+// data directory.
+func startDevnetNode(exe string, n *devnetNode, nodes []*devnetNode, networkId uint64, password string) (*devnetProcess, error) {
+	logfile, err := os.OpenFile(filepath.Join(n.DataDir, "gman.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(exe, devnetArgs(n, nodes, networkId, password)...)
+	cmd.Dir, cmd.Stdout, cmd.Stderr = n.DataDir, logfile, logfile
+	if err := cmd.Start(); err != nil {
+		logfile.Close()
+		return nil, err
+	}
+	p := &devnetProcess{node: n, cmd: cmd, done: make(chan struct{})}
+	go func() {
+		cmd.Wait()
+		logfile.Close()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// devnetGenerate generates a local test network into the directory: the keys
+// and data directory of every node, the genesis, the keys of the funded
+// accounts and the node list.
+func devnetGenerate(ctx *cli.Context, dir string) ([]*devnetNode, error) {
+	var (
+		validators = ctx.Int(devnetValidatorsFlag.Name)
+		fullnodes  = ctx.Int(devnetFullNodesFlag.Name)
+		seed       = ctx.String(devnetSeedFlag.Name)
+		port       = ctx.Int(devnetPortFlag.Name)
+		rpcport    = ctx.Int(devnetRPCPortFlag.Name)
+		balance    = new(big.Int).Mul(new(big.Int).SetUint64(ctx.Uint64(devnetBalanceFlag.Name)), big.NewInt(params.Ether))
+	)
+	if validators < 1 {
+		return nil, fmt.Errorf("at least one validator required")
+	}
+	if fullnodes < 0 || ctx.Int(devnetAccountsFlag.Name) < 0 {
+		return nil, fmt.Errorf("negative node or account count")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	password := filepath.Join(dir, "password.txt")
+	if err := ioutil.WriteFile(password, []byte(devnetPassword+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	var (
+		nodes    []*devnetNode
+		nodeKeys = make(map[string]*ecdsa.PrivateKey)
+	)
+	for i := 0; i < validators+fullnodes; i++ {
+		n := &devnetNode{
+			Name:    fmt.Sprintf("validator-%d", i),
+			Role:    devnetValidator,
+			Port:    port + i,
+			RPCPort: rpcport + i,
+		}
+		if i >= validators {
+			n.Name, n.Role = fmt.Sprintf("node-%d", i-validators), devnetFullNode
+		}
+		n.DataDir = filepath.Join(dir, n.Name)
+
+		nodeKey := devnetKey(seed, n.Name+"/nodekey")
+		id := discover.PubkeyID(&nodeKey.PublicKey)
+		n.Enode = discover.NewNode(id, net.IPv4(127, 0, 0, 1), uint16(n.Port), uint16(n.Port)).String()
+		if err := os.MkdirAll(filepath.Join(n.DataDir, clientIdentifier), 0700); err != nil {
+			return nil, err
+		}
+		if err := crypto.SaveECDSA(filepath.Join(n.DataDir, clientIdentifier, "nodekey"), nodeKey); err != nil {
+			return nil, err
+		}
+		account := devnetKey(seed, n.Name+"/account")
+		ks := keystore.NewKeyStore(filepath.Join(n.DataDir, "keystore"), keystore.LightScryptN, keystore.LightScryptP)
+		if _, err := ks.ImportECDSA(account, devnetPassword); err != nil {
+			return nil, err
+		}
+		n.Account = crypto.PubkeyToAddress(account.PublicKey)
+		nodes = append(nodes, n)
+		nodeKeys[n.Name] = nodeKey
+	}
+	var (
+		funded []common.Address
+		keys   []string
+	)
+	for i := 0; i < ctx.Int(devnetAccountsFlag.Name); i++ {
+		key := devnetKey(seed, fmt.Sprintf("account-%d", i))
+		funded = append(funded, crypto.PubkeyToAddress(key.PublicKey))
+		keys = append(keys, common.Bytes2Hex(crypto.FromECDSA(key)))
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "accounts.txt"), []byte(strings.Join(keys, "\n")+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	genesis := devnetGenesis(nodes, nodeKeys, funded, balance, ctx.Uint64(devnetNetworkIdFlag.Name))
+	if err := writeJSON(filepath.Join(dir, "genesis.json"), genesis); err != nil {
+		return nil, err
+	}
+	if err := writeJSON(filepath.Join(dir, "nodes.json"), nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// devnetKey derives a key from the seed and the name of its use.
+func devnetKey(seed, name string) *ecdsa.PrivateKey {
+	for i := 0; ; i++ {
+		key, err := crypto.ToECDSA(crypto.Keccak256([]byte(seed), []byte(name), []byte(strconv.Itoa(i))))
+		if err == nil {
+			return key
+		}
+	}
+}
+
+// devnetGenesis assembles the genesis of a local test network: the validators
+// are elected and placed in the topology, their deposits registered with the
+// deposit contract and their accounts funded with the deposit, and the
+// funded accounts are given the balance.
+func devnetGenesis(nodes []*devnetNode, nodeKeys map[string]*ecdsa.PrivateKey, funded []common.Address, balance *big.Int, networkId uint64) *core.Genesis {
+	config := *params.AllEthashProtocolChanges
+	config.ChainId = new(big.Int).SetUint64(networkId)
+
+	genesis := &core.Genesis{
+		Config:      &config,
+		ExtraData:   make([]byte, 32),
+		GasLimit:    params.GenesisGasLimit,
+		Difficulty:  big.NewInt(1),
+		NetTopology: common.NetTopology{Type: common.NetTopoTypeAll},
+		Alloc:       make(core.GenesisAlloc),
+	}
+	var (
+		index   uint64
+		storage = make(map[common.Hash]common.Hash)
+	)
+	for _, n := range nodes {
+		if n.Role != devnetValidator {
+			continue
+		}
+		if index == 0 {
+			genesis.Leader = n.Account
+		}
+		genesis.Elect = append(genesis.Elect, common.Elect{Account: n.Account, Stock: 1, Type: common.ElectRoleValidator})
+		genesis.NetTopology.NetTopologyData = append(genesis.NetTopology.NetTopologyData, common.NetTopologyData{
+			Account:  n.Account,
+			Position: common.GeneratePosition(uint16(index), common.ElectRoleValidator),
+		})
+		genesis.Alloc[n.Account] = core.GenesisAccount{Balance: devnetDeposit}
+
+		// Register the deposit as the deposit contract would
+		id := discover.PubkeyID(&nodeKeys[n.Name].PublicKey)
+		item := make([]byte, 8)
+		binary.BigEndian.PutUint64(item, index)
+		storage[common.BytesToHash(append(n.Account[:], 'D'))] = common.BigToHash(devnetDeposit)
+		storage[common.BytesToHash(append(n.Account[:], 'N', 'X'))] = common.BytesToHash(id[:32])
+		storage[common.BytesToHash(append(n.Account[:], 'N', 'Y'))] = common.BytesToHash(id[32:])
+		storage[common.BytesToHash(append(append(devnetDepositAddr[:], 'D', 'I'), item...))] = common.BytesToHash(n.Account[:])
+		index++
+	}
+	storage[common.BytesToHash(append(devnetDepositAddr[:], 'D', 'N', 'U', 'M'))] = common.BigToHash(new(big.Int).SetUint64(index))
+	genesis.Alloc[devnetDepositAddr] = core.GenesisAccount{Balance: new(big.Int).Mul(devnetDeposit, new(big.Int).SetUint64(index)), Storage: storage}
+
+	for _, addr := range funded {
+		genesis.Alloc[addr] = core.GenesisAccount{Balance: balance}
+	}
+	return genesis
+}
+
+// devnetArgs returns the command line arguments running a node of a local
+// test network: validators are bootnodes of the others and mine with their
+// unlocked account.
+func devnetArgs(n *devnetNode, nodes []*devnetNode, networkId uint64, password string) []string {
+	var bootnodes []string
+	for _, other := range nodes {
+		if other.Role == devnetValidator && other != n {
+			bootnodes = append(bootnodes, other.Enode)
+		}
+	}
+	args := []string{
+		"--datadir", n.DataDir,
+		"--networkid", strconv.FormatUint(networkId, 10),
+		"--port", strconv.Itoa(n.Port),
+		"--nat", "none",
+		"--ipcdisable",
+		"--rpc", "--rpcaddr", "127.0.0.1", "--rpcport", strconv.Itoa(n.RPCPort),
+	}
+	if len(bootnodes) > 0 {
+		args = append(args, "--bootnodes", strings.Join(bootnodes, ","))
+	}
+	if n.Role == devnetValidator {
+		args = append(args,
+			"--unlock", n.Account.Hex(), "--password", password,
+			"--manbase", n.Account.Hex(), "--mine",
+		)
+	}
+	return args
+}
+
+// loadDevnet loads the node list of the local test network in the directory.
+func loadDevnet(dir string) ([]*devnetNode, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "nodes.json"))
+	if err != nil {
+		return nil, err
+	}
+	var nodes []*devnetNode
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		return nil, fmt.Errorf("invalid nodes.json: %v", err)
+	}
+	return nodes, nil
+}
+
+// loadDevnetGenesis loads the genesis of the local test network in the
+// directory.
+func loadDevnetGenesis(dir string) (*core.Genesis, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "genesis.json"))
+	if err != nil {
+		return nil, err
+	}
+	genesis := new(core.Genesis)
+	if err := json.Unmarshal(data, genesis); err != nil {
+		return nil, err
+	}
+	return genesis, nil
+}
+
+// writeJSON writes the indented JSON encoding of v to the file.
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
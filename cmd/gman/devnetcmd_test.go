@@ -0,0 +1,146 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matrix/go-matrix/core/state"
+	"github.com/matrix/go-matrix/core/vm"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/mandb"
+	"github.com/matrix/go-matrix/p2p/discover"
+	"gopkg.in/urfave/cli.v1"
+)
+
+func devnetContext(args ...string) *cli.Context {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range devnetCommand.Subcommands[0].Flags {
+		f.Apply(set)
+	}
+	set.Parse(args)
+	return cli.NewContext(cli.NewApp(), set, nil)
+}
+
+func TestDevnetGenerate(t *testing.T) {
+	dir := tmpdir(t)
+	defer os.RemoveAll(dir)
+
+	ctx := devnetContext("--validators", "2", "--fullnodes", "1", "--accounts", "2", "--port", "31000")
+	nodes, err := devnetGenerate(ctx, filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatalf("failed to generate devnet: %v", err)
+	}
+	if len(nodes) != 3 {
+		t.Fatalf("node count mismatch: have %d, want 3", len(nodes))
+	}
+	for i, want := range []string{"validator-0", "validator-1", "node-0"} {
+		if nodes[i].Name != want || nodes[i].Port != 31000+i || nodes[i].RPCPort != 8545+i {
+			t.Errorf("node %d: have %+v, want %s on port %d", i, nodes[i], want, 31000+i)
+		}
+	}
+	if loaded, err := loadDevnet(filepath.Join(dir, "a")); err != nil || len(loaded) != len(nodes) {
+		t.Fatalf("failed to load node list: %v", err)
+	}
+	keys, err := loadKeys(filepath.Join(dir, "a", "accounts.txt"))
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("failed to load funded keys: %v", err)
+	}
+	genesis, err := loadDevnetGenesis(filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatalf("failed to load genesis: %v", err)
+	}
+	if len(genesis.Elect) != 2 || len(genesis.NetTopology.NetTopologyData) != 2 || genesis.Leader != nodes[0].Account {
+		t.Errorf("validators not elected: %+v", genesis.Elect)
+	}
+	for _, key := range keys {
+		if balance := genesis.Alloc[crypto.PubkeyToAddress(key.PublicKey)].Balance; balance == nil || balance.Sign() == 0 {
+			t.Errorf("account %x not funded", crypto.PubkeyToAddress(key.PublicKey))
+		}
+	}
+
+	// The deposit contract lists the validators with their node ids
+	db := mandb.NewMemDatabase()
+	block := genesis.MustCommit(db)
+	statedb, err := state.New(block.Root(), state.NewDatabase(db))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract := vm.NewContract(vm.AccountRef(nodes[0].Account), vm.AccountRef(devnetDepositAddr), new(big.Int), 0)
+	deposits := new(vm.MatrixDeposit).GetValidatorDepositList(contract, statedb)
+	if len(deposits) != 2 {
+		t.Fatalf("deposit count mismatch: have %d, want 2", len(deposits))
+	}
+	for i, deposit := range deposits {
+		key, err := crypto.LoadECDSA(filepath.Join(nodes[i].DataDir, clientIdentifier, "nodekey"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deposit.Address != nodes[i].Account || deposit.NodeID != discover.PubkeyID(&key.PublicKey) {
+			t.Errorf("deposit %d: have %x/%x, want %x", i, deposit.Address, deposit.NodeID[:8], nodes[i].Account)
+		}
+	}
+
+	// The same flags generate the same network
+	again, err := devnetGenerate(ctx, filepath.Join(dir, "b"))
+	if err != nil {
+		t.Fatalf("failed to generate devnet: %v", err)
+	}
+	other, _ := loadDevnetGenesis(filepath.Join(dir, "b"))
+	if again[0].Enode != nodes[0].Enode || other.ToBlock(nil).Hash() != genesis.ToBlock(nil).Hash() {
+		t.Error("devnet generation not deterministic")
+	}
+}
+
+func TestDevnetArgs(t *testing.T) {
+	nodes := []*devnetNode{
+		{Name: "validator-0", Role: devnetValidator, Enode: "enode://v0", Port: 30310, RPCPort: 8545},
+		{Name: "validator-1", Role: devnetValidator, Enode: "enode://v1", Port: 30311, RPCPort: 8546},
+		{Name: "node-0", Role: devnetFullNode, Enode: "enode://n0", Port: 30312, RPCPort: 8547},
+	}
+	flags := func(args []string) map[string]string {
+		m := make(map[string]string)
+		for i, arg := range args {
+			if strings.HasPrefix(arg, "--") {
+				m[arg] = ""
+				if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+					m[arg] = args[i+1]
+				}
+			}
+		}
+		return m
+	}
+	validator := flags(devnetArgs(nodes[0], nodes, 1337, "password.txt"))
+	if validator["--bootnodes"] != "enode://v1" {
+		t.Errorf("validator bootnodes mismatch: have %q", validator["--bootnodes"])
+	}
+	if _, ok := validator["--mine"]; !ok || validator["--port"] != "30310" || validator["--networkid"] != "1337" {
+		t.Errorf("validator flags mismatch: %v", validator)
+	}
+	full := flags(devnetArgs(nodes[2], nodes, 1337, "password.txt"))
+	if full["--bootnodes"] != "enode://v0,enode://v1" || full["--rpcport"] != "8547" {
+		t.Errorf("full node flags mismatch: %v", full)
+	}
+	if _, ok := full["--mine"]; ok {
+		t.Error("full node mines")
+	}
+}
@@ -0,0 +1,424 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/matrix/go-matrix/cmd/utils"
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/mandb"
+	"github.com/matrix/go-matrix/node"
+	"github.com/matrix/go-matrix/p2p/discover"
+	"github.com/matrix/go-matrix/params"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	doctorJSONFlag = cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print the report as JSON",
+	}
+	doctorOfflineFlag = cli.BoolFlag{
+		Name:  "offline",
+		Usage: "Skip the checks contacting remote servers (clock drift)",
+	}
+
+	doctorCommand = cli.Command{
+		Action:    utils.MigrateFlags(doctor),
+		Name:      "doctor",
+		Usage:     "Check the local environment for problems preventing the node from syncing",
+		ArgsUsage: " ",
+		Flags:     append(append(nodeFlags, rpcFlags...), doctorJSONFlag, doctorOfflineFlag),
+		Category:  "MISCELLANEOUS COMMANDS",
+		Description: `
+The doctor command runs a series of self-tests against the configuration the
+node would be started with: data directory permissions, free disk space and
+disk latency, system clock drift, availability of the listening ports,
+consistency of the stored genesis block with the selected network and
+readability of the keystore.
+
+The command exits with a non-zero status if any check fails. Use --json to get
+a machine-readable report to attach to support requests.
+`,
+	}
+)
+
+const (
+	doctorDiskWarn  = 20 * 1024 * 1024 * 1024 // Free space below which disk space is flagged
+	doctorDiskFail  = 2 * 1024 * 1024 * 1024  // Free space below which the node can't sync
+	doctorIOBlocks  = 100                     // Number of synced writes measuring the disk
+	doctorIOPSWarn  = 100                     // Synced writes per second below which the disk is flagged
+	doctorDriftWarn = time.Second             // Clock drift worth a warning
+	doctorDriftFail = 10 * time.Second        // Clock drift preventing peer discovery
+)
+
+// Statuses of a doctor check.
+const (
+	doctorOK   = "ok"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+	doctorSkip = "skip"
+)
+
+// doctorCheck is the outcome of a single self-test.
+type doctorCheck struct {
+	Name   string                 `json:"name"`
+	Status string                 `json:"status"`
+	Detail string                 `json:"detail"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+}
+
+// doctorReport is the structured result of the doctor command.
+type doctorReport struct {
+	Version string         `json:"version"`
+	OS      string         `json:"os"`
+	Go      string         `json:"go"`
+	DataDir string         `json:"datadir"`
+	Time    time.Time      `json:"time"`
+	Checks  []*doctorCheck `json:"checks"`
+}
+
+// failures returns the number of failed checks.
+func (r *doctorReport) failures() int {
+	n := 0
+	for _, c := range r.Checks {
+		if c.Status == doctorFail {
+			n++
+		}
+	}
+	return n
+}
+
+// doctor runs the environment self-tests and prints the report.
+func doctor(ctx *cli.Context) error {
+	cfg := gmanConfig{Node: defaultNodeConfig()}
+	if file := ctx.GlobalString(configFileFlag.Name); file != "" {
+		if err := loadConfig(file, &cfg); err != nil {
+			utils.Fatalf("%v", err)
+		}
+	}
+	utils.SetNodeConfig(ctx, &cfg.Node)
+
+	report := &doctorReport{
+		Version: params.VersionWithCommit(gitCommit, gitDate),
+		OS:      runtime.GOOS + "/" + runtime.GOARCH,
+		Go:      runtime.Version(),
+		DataDir: cfg.Node.DataDir,
+		Time:    time.Now().UTC(),
+	}
+	report.Checks = append(report.Checks,
+		checkDataDir(&cfg.Node),
+		checkDiskSpace(&cfg.Node),
+		checkDiskLatency(&cfg.Node),
+		checkClockDrift(ctx.Bool(doctorOfflineFlag.Name)),
+	)
+	report.Checks = append(report.Checks, checkPorts(&cfg.Node)...)
+	report.Checks = append(report.Checks,
+		checkGenesis(ctx, &cfg.Node),
+		checkKeystore(&cfg.Node),
+	)
+
+	if ctx.Bool(doctorJSONFlag.Name) {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else {
+		fmt.Printf("Version: %s (%s, %s)\n", report.Version, report.OS, report.Go)
+		fmt.Printf("Datadir: %s\n\n", report.DataDir)
+		for _, c := range report.Checks {
+			fmt.Printf("[%-4s] %-12s %s\n", c.Status, c.Name, c.Detail)
+		}
+	}
+	if n := report.failures(); n > 0 {
+		return fmt.Errorf("%d of %d checks failed", n, len(report.Checks))
+	}
+	return nil
+}
+
+// checkDataDir verifies that the data directory exists or can be created, and
+// that files can be created in it.
+func checkDataDir(cfg *node.Config) *doctorCheck {
+	check := &doctorCheck{Name: "datadir"}
+	if cfg.DataDir == "" {
+		check.Status, check.Detail = doctorWarn, "no data directory, the node would run in memory"
+		return check
+	}
+	if err := os.MkdirAll(cfg.DataDir, 0700); err != nil {
+		check.Status, check.Detail = doctorFail, fmt.Sprintf("cannot create data directory: %v", err)
+		return check
+	}
+	f, err := ioutil.TempFile(cfg.DataDir, "doctor")
+	if err != nil {
+		check.Status, check.Detail = doctorFail, fmt.Sprintf("data directory not writable: %v", err)
+		return check
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	check.Status, check.Detail = doctorOK, "writable"
+	if info, err := os.Stat(cfg.DataDir); err == nil {
+		check.Data = map[string]interface{}{"mode": info.Mode().String()}
+		if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+			check.Status, check.Detail = doctorWarn, fmt.Sprintf("writable, but accessible by other users (%v)", info.Mode().Perm())
+		}
+	}
+	return check
+}
+
+// checkDiskSpace verifies that the file system holding the data directory has
+// enough room for the chain to grow.
+func checkDiskSpace(cfg *node.Config) *doctorCheck {
+	check := &doctorCheck{Name: "disk-space"}
+	if cfg.DataDir == "" {
+		check.Status, check.Detail = doctorSkip, "no data directory"
+		return check
+	}
+	free, err := freeDiskSpace(cfg.DataDir)
+	if err != nil {
+		check.Status, check.Detail = doctorSkip, fmt.Sprintf("cannot determine free space: %v", err)
+		return check
+	}
+	check.Data = map[string]interface{}{"free": free}
+	check.Detail = fmt.Sprintf("%s available", common.StorageSize(free))
+	switch {
+	case free < doctorDiskFail:
+		check.Status = doctorFail
+	case free < doctorDiskWarn:
+		check.Status = doctorWarn
+	default:
+		check.Status = doctorOK
+	}
+	return check
+}
+
+// checkDiskLatency measures how many synced writes per second the data
+// directory sustains, flagging network or failing disks too slow to sync on.
+func checkDiskLatency(cfg *node.Config) *doctorCheck {
+	check := &doctorCheck{Name: "disk-iops"}
+	if cfg.DataDir == "" {
+		check.Status, check.Detail = doctorSkip, "no data directory"
+		return check
+	}
+	f, err := ioutil.TempFile(cfg.DataDir, "doctor")
+	if err != nil {
+		check.Status, check.Detail = doctorSkip, fmt.Sprintf("cannot create probe file: %v", err)
+		return check
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	block := make([]byte, 4096)
+	start := time.Now()
+	for i := 0; i < doctorIOBlocks; i++ {
+		if _, err := f.Write(block); err != nil {
+			check.Status, check.Detail = doctorFail, fmt.Sprintf("write failed: %v", err)
+			return check
+		}
+		if err := f.Sync(); err != nil {
+			check.Status, check.Detail = doctorFail, fmt.Sprintf("sync failed: %v", err)
+			return check
+		}
+	}
+	elapsed := time.Since(start)
+	iops := float64(doctorIOBlocks) / elapsed.Seconds()
+
+	check.Data = map[string]interface{}{"iops": int(iops), "latency": (elapsed / doctorIOBlocks).String()}
+	check.Detail = fmt.Sprintf("%d synced writes/s, %v per write", int(iops), elapsed/doctorIOBlocks)
+	if iops < doctorIOPSWarn {
+		check.Status = doctorWarn
+	} else {
+		check.Status = doctorOK
+	}
+	return check
+}
+
+// checkClockDrift compares the local clock against NTP, since peers reject
+// discovery packets from nodes whose clock is too far off.
+func checkClockDrift(offline bool) *doctorCheck {
+	check := &doctorCheck{Name: "clock"}
+	if offline {
+		check.Status, check.Detail = doctorSkip, "offline mode"
+		return check
+	}
+	drift, err := discover.ClockDrift()
+	if err != nil {
+		check.Status, check.Detail = doctorWarn, fmt.Sprintf("cannot query NTP server: %v", err)
+		return check
+	}
+	check.Data = map[string]interface{}{"drift": drift.String()}
+	check.Detail = fmt.Sprintf("drift %v", drift)
+	if drift < 0 {
+		drift = -drift
+	}
+	switch {
+	case drift > doctorDriftFail:
+		check.Status = doctorFail
+		check.Detail += ", peers will reject the node; enable network time synchronisation"
+	case drift > doctorDriftWarn:
+		check.Status = doctorWarn
+	default:
+		check.Status = doctorOK
+	}
+	return check
+}
+
+// checkPorts verifies that the p2p and enabled RPC endpoints can be bound,
+// which fails when another process (often a running node) holds them.
+func checkPorts(cfg *node.Config) []*doctorCheck {
+	var checks []*doctorCheck
+	if cfg.P2P.ListenAddr != "" {
+		check := checkPort("p2p-tcp", "tcp", cfg.P2P.ListenAddr)
+		checks = append(checks, check)
+		if !cfg.P2P.NoDiscovery {
+			checks = append(checks, checkPort("p2p-udp", "udp", cfg.P2P.ListenAddr))
+		}
+	}
+	if cfg.HTTPHost != "" {
+		checks = append(checks, checkPort("http-rpc", "tcp", net.JoinHostPort(cfg.HTTPHost, strconv.Itoa(cfg.HTTPPort))))
+	}
+	if cfg.WSHost != "" {
+		checks = append(checks, checkPort("ws-rpc", "tcp", net.JoinHostPort(cfg.WSHost, strconv.Itoa(cfg.WSPort))))
+	}
+	return checks
+}
+
+// checkPort tries to bind the given address on the network.
+func checkPort(name, network, addr string) *doctorCheck {
+	check := &doctorCheck{Name: name, Data: map[string]interface{}{"address": addr}}
+
+	var err error
+	if network == "udp" {
+		var conn net.PacketConn
+		if conn, err = net.ListenPacket(network, addr); err == nil {
+			conn.Close()
+		}
+	} else {
+		var l net.Listener
+		if l, err = net.Listen(network, addr); err == nil {
+			l.Close()
+		}
+	}
+	if err != nil {
+		check.Status, check.Detail = doctorFail, fmt.Sprintf("cannot listen on %s/%s (is another node running?): %v", addr, network, err)
+		return check
+	}
+	check.Status, check.Detail = doctorOK, fmt.Sprintf("%s/%s available", addr, network)
+	return check
+}
+
+// checkGenesis verifies that the stored genesis block and chain configuration
+// match the network selected on the command line.
+func checkGenesis(ctx *cli.Context, cfg *node.Config) *doctorCheck {
+	check := &doctorCheck{Name: "genesis"}
+
+	name := "chaindata"
+	if ctx.GlobalBool(utils.LightModeFlag.Name) {
+		name = "lightchaindata"
+	}
+	path := cfg.ResolvePath(name)
+	if path == "" || !common.FileExist(path) {
+		check.Status, check.Detail = doctorWarn, "chain database not initialised"
+		return check
+	}
+	db, err := mandb.NewLDBDatabase(path, 16, 16)
+	if err != nil {
+		check.Status, check.Detail = doctorSkip, fmt.Sprintf("cannot open chain database (is the node running?): %v", err)
+		return check
+	}
+	defer db.Close()
+
+	config, hash, err := core.CheckGenesisBlock(db, utils.MakeGenesis(ctx))
+	if hash != (common.Hash{}) {
+		check.Data = map[string]interface{}{"hash": hash}
+	}
+	switch {
+	case err == core.ErrNoGenesis:
+		check.Status, check.Detail = doctorWarn, "chain database has no genesis block"
+	case err != nil:
+		check.Status, check.Detail = doctorFail, err.Error()
+	default:
+		check.Data["chainId"] = config.ChainId
+		check.Status, check.Detail = doctorOK, fmt.Sprintf("genesis %x, chain id %v", hash[:8], config.ChainId)
+	}
+	return check
+}
+
+// checkKeystore verifies that every key file in the keystore can be read and
+// decoded.
+func checkKeystore(cfg *node.Config) *doctorCheck {
+	check := &doctorCheck{Name: "keystore"}
+
+	_, _, keydir, err := cfg.AccountConfig()
+	if err != nil {
+		check.Status, check.Detail = doctorFail, err.Error()
+		return check
+	}
+	if keydir == "" {
+		check.Status, check.Detail = doctorSkip, "no keystore directory"
+		return check
+	}
+	files, err := ioutil.ReadDir(keydir)
+	if os.IsNotExist(err) {
+		check.Status, check.Detail = doctorOK, "no keystore yet"
+		return check
+	}
+	if err != nil {
+		check.Status, check.Detail = doctorFail, fmt.Sprintf("cannot read keystore: %v", err)
+		return check
+	}
+	var keys int
+	var unreadable []string
+	for _, fi := range files {
+		// Skip editor backups and hidden files the keystore ignores too
+		if fi.IsDir() || fi.Name()[0] == '.' || fi.Name()[len(fi.Name())-1] == '~' {
+			continue
+		}
+		blob, err := ioutil.ReadFile(filepath.Join(keydir, fi.Name()))
+		if err != nil {
+			unreadable = append(unreadable, fi.Name())
+			continue
+		}
+		var key struct {
+			Address string `json:"address"`
+		}
+		if err := json.Unmarshal(blob, &key); err != nil || !common.IsHexAddress(key.Address) {
+			unreadable = append(unreadable, fi.Name())
+			continue
+		}
+		keys++
+	}
+	check.Data = map[string]interface{}{"dir": keydir, "keys": keys}
+	if len(unreadable) > 0 {
+		check.Data["unreadable"] = unreadable
+		check.Status, check.Detail = doctorWarn, fmt.Sprintf("%d keys, %d unreadable files", keys, len(unreadable))
+		return check
+	}
+	check.Status, check.Detail = doctorOK, fmt.Sprintf("%d keys", keys)
+	return check
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matrix/go-matrix/node"
+)
+
+func TestDoctorDataDir(t *testing.T) {
+	dir := tmpdir(t)
+	defer os.RemoveAll(dir)
+
+	cfg := &node.Config{DataDir: filepath.Join(dir, "sub")}
+	if check := checkDataDir(cfg); check.Status == doctorFail {
+		t.Fatalf("fresh datadir failed: %s", check.Detail)
+	}
+	if _, err := os.Stat(cfg.DataDir); err != nil {
+		t.Errorf("datadir not created: %v", err)
+	}
+	if check := checkDiskLatency(cfg); check.Status == doctorFail {
+		t.Errorf("disk latency check failed: %s", check.Detail)
+	}
+	files, _ := ioutil.ReadDir(cfg.DataDir)
+	if len(files) != 0 {
+		t.Errorf("probe files left behind: %d", len(files))
+	}
+}
+
+func TestDoctorPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if check := checkPort("busy", "tcp", l.Addr().String()); check.Status != doctorFail {
+		t.Errorf("bound port: have status %s, want %s", check.Status, doctorFail)
+	}
+	if check := checkPort("free", "tcp", "127.0.0.1:0"); check.Status != doctorOK {
+		t.Errorf("free port: have status %s, want %s: %s", check.Status, doctorOK, check.Detail)
+	}
+}
+
+func TestDoctorKeystore(t *testing.T) {
+	dir := tmpdir(t)
+	defer os.RemoveAll(dir)
+
+	cfg := &node.Config{KeyStoreDir: filepath.Join(dir, "keystore")}
+	if check := checkKeystore(cfg); check.Status != doctorOK {
+		t.Errorf("missing keystore: have status %s, want %s", check.Status, doctorOK)
+	}
+	os.MkdirAll(cfg.KeyStoreDir, 0700)
+	ioutil.WriteFile(filepath.Join(cfg.KeyStoreDir, "good"), []byte(`{"address":"f466859ead1932d743d622cb74fc058882e8648a"}`), 0600)
+	ioutil.WriteFile(filepath.Join(cfg.KeyStoreDir, "garbage"), []byte(`{`), 0600)
+	ioutil.WriteFile(filepath.Join(cfg.KeyStoreDir, ".hidden"), []byte(`{`), 0600)
+
+	check := checkKeystore(cfg)
+	if check.Status != doctorWarn {
+		t.Errorf("corrupt keystore: have status %s, want %s", check.Status, doctorWarn)
+	}
+	if keys := check.Data["keys"]; keys != 1 {
+		t.Errorf("key count mismatch: have %v, want 1", keys)
+	}
+	if unreadable := check.Data["unreadable"].([]string); len(unreadable) != 1 || unreadable[0] != "garbage" {
+		t.Errorf("unreadable files mismatch: have %v, want [garbage]", unreadable)
+	}
+}
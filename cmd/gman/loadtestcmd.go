@@ -0,0 +1,752 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/cmd/utils"
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/core/vm"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/manclient"
+	"github.com/matrix/go-matrix/node"
+	"github.com/matrix/go-matrix/rpc"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	loadtestAttachFlag = cli.StringFlag{
+		Name:  "attach",
+		Value: node.DefaultIPCEndpoint(clientIdentifier),
+		Usage: "API endpoint of the node transactions are sent to",
+	}
+	loadtestKeysFlag = cli.StringFlag{
+		Name:  "keys",
+		Usage: "File of funded hex private keys transactions are sent from, one per line",
+	}
+	loadtestTPSFlag = cli.Float64Flag{
+		Name:  "tps",
+		Value: 10,
+		Usage: "Target number of transactions sent per second",
+	}
+	loadtestDurationFlag = cli.DurationFlag{
+		Name:  "duration",
+		Value: time.Minute,
+		Usage: "Time transactions are sent for",
+	}
+	loadtestWorkloadFlag = cli.StringFlag{
+		Name:  "workload",
+		Value: loadTransfer,
+		Usage: `Weighted transaction mix of "transfer", "mint" and "storage", e.g. "transfer:70,mint:20,storage:10"`,
+	}
+	loadtestSlotsFlag = cli.IntFlag{
+		Name:  "slots",
+		Value: 10,
+		Usage: "Number of fresh storage slots written by each storage transaction",
+	}
+	loadtestGasPriceFlag = cli.Uint64Flag{
+		Name:  "gasprice",
+		Usage: "Gas price of the transactions in wei (0 = suggested by the node)",
+	}
+	loadtestTimeoutFlag = cli.DurationFlag{
+		Name:  "timeout",
+		Value: 2 * time.Minute,
+		Usage: "Time waited for the sent transactions to be included after the last one",
+	}
+	loadtestJSONFlag = cli.BoolFlag{
+		Name:  "json",
+		Usage: "Print the statistics as JSON",
+	}
+
+	loadtestCommand = cli.Command{
+		Action:    utils.MigrateFlags(loadtest),
+		Name:      "loadtest",
+		Usage:     "Stress test a network with a generated transaction workload",
+		ArgsUsage: " ",
+		Category:  "MISCELLANEOUS COMMANDS",
+		Flags: []cli.Flag{
+			loadtestAttachFlag,
+			loadtestKeysFlag,
+			loadtestTPSFlag,
+			loadtestDurationFlag,
+			loadtestWorkloadFlag,
+			loadtestSlotsFlag,
+			loadtestGasPriceFlag,
+			loadtestTimeoutFlag,
+			loadtestJSONFlag,
+		},
+		Description: `
+The loadtest command sends a generated transaction workload to a node at a
+target rate and reports how the network kept up: the achieved send and
+inclusion rates and the latency from sending a transaction to finding its
+receipt.
+
+Transactions are signed locally with the funded keys of the --keys file, sent
+round-robin from all of them. The workload mixes three kinds of transactions:
+
+   transfer   plain value transfers between the funded accounts
+   mint       token mints updating a balance and emitting a Transfer event
+   storage    contract calls writing --slots fresh storage slots each
+
+The contracts needed are deployed before the test starts. Interrupting the
+command stops sending and reports on the transactions sent so far.
+`,
+	}
+)
+
+// Kinds of generated transactions.
+const (
+	loadTransfer = "transfer"
+	loadMint     = "mint"
+	loadStorage  = "storage"
+)
+
+const (
+	loadTransferGas = 21000
+	loadMintGas     = 100000
+	loadDeployGas   = 300000
+	loadSlotGas     = 25000 // Gas allowance of a fresh storage slot
+	loadQueue       = 64    // Transactions queued per sender before skipping ticks
+	loadPoll        = 500 * time.Millisecond
+	loadBatch       = 100 // Receipts requested per batch call
+)
+
+// loadTransferTopic is the topic of the ERC-20 Transfer event.
+var loadTransferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// loadMintCode is the runtime code of a token contract minting the amount in
+// the call data to the caller: balances[caller] += amount, emitting
+// Transfer(0, caller, amount).
+var loadMintCode = append(append([]byte{
+	byte(vm.PUSH1), 0x00, byte(vm.CALLDATALOAD), // amount
+	byte(vm.DUP1), byte(vm.CALLER), byte(vm.SLOAD), byte(vm.ADD), // amount, balance+amount
+	byte(vm.CALLER), byte(vm.SSTORE), // balances[caller] = balance+amount
+	byte(vm.PUSH1), 0x00, byte(vm.MSTORE), // memory[0] = amount
+	byte(vm.CALLER), byte(vm.PUSH1), 0x00, byte(vm.PUSH32),
+}, loadTransferTopic[:]...),
+	byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, byte(vm.LOG3), // Transfer(0, caller, amount)
+	byte(vm.STOP),
+)
+
+// loadStorageCode is the runtime code of a contract writing the block number to
+// as many fresh storage slots as the number in the call data. Slot 0 counts the
+// slots written so far.
+var loadStorageCode = []byte{
+	byte(vm.PUSH1), 0x00, byte(vm.SLOAD), // base
+	byte(vm.PUSH1), 0x00, byte(vm.CALLDATALOAD), // base, n
+	byte(vm.JUMPDEST), byte(vm.DUP1), byte(vm.ISZERO), byte(vm.PUSH1), 0x18, byte(vm.JUMPI), // 0x06: loop, exit if n == 0
+	byte(vm.NUMBER), byte(vm.DUP2), byte(vm.DUP4), byte(vm.ADD), byte(vm.SSTORE), // storage[base+n] = number
+	byte(vm.PUSH1), 0x01, byte(vm.SWAP1), byte(vm.SUB), // base, n-1
+	byte(vm.PUSH1), 0x06, byte(vm.JUMP),
+	byte(vm.JUMPDEST), byte(vm.POP), byte(vm.PUSH1), 0x00, byte(vm.CALLDATALOAD), byte(vm.ADD), // 0x18: exit, base+n
+	byte(vm.PUSH1), 0x00, byte(vm.SSTORE), // storage[0] = base+n
+	byte(vm.STOP),
+}
+
+// loadDeployCode wraps runtime code into contract creation code returning it.
+func loadDeployCode(runtime []byte) []byte {
+	init := []byte{
+		byte(vm.PUSH1), byte(len(runtime)), byte(vm.DUP1),
+		byte(vm.PUSH1), 0x0b, byte(vm.PUSH1), 0x00, byte(vm.CODECOPY), // memory[0:] = runtime
+		byte(vm.PUSH1), 0x00, byte(vm.RETURN),
+	}
+	return append(init, runtime...)
+}
+
+// loadWorkload is a kind of transaction with its weight in the workload mix.
+type loadWorkload struct {
+	kind   string
+	weight int
+}
+
+// parseWorkloads parses a weighted workload mix, e.g. "transfer:70,mint:30".
+// Kinds without weight have weight 1.
+func parseWorkloads(spec string) ([]loadWorkload, error) {
+	var (
+		workloads []loadWorkload
+		seen      = make(map[string]bool)
+	)
+	for _, part := range strings.Split(spec, ",") {
+		kind, weight := strings.TrimSpace(part), 1
+		if i := strings.Index(kind, ":"); i >= 0 {
+			n, err := strconv.Atoi(strings.TrimSpace(kind[i+1:]))
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid weight in workload %q", part)
+			}
+			kind, weight = strings.TrimSpace(kind[:i]), n
+		}
+		switch kind {
+		case loadTransfer, loadMint, loadStorage:
+		default:
+			return nil, fmt.Errorf("unknown workload %q", kind)
+		}
+		if seen[kind] {
+			return nil, fmt.Errorf("duplicate workload %q", kind)
+		}
+		seen[kind] = true
+		workloads = append(workloads, loadWorkload{kind, weight})
+	}
+	return workloads, nil
+}
+
+// workloadAt returns the kind of the i-th transaction of the mix.
+func workloadAt(workloads []loadWorkload, i int) string {
+	total := 0
+	for _, w := range workloads {
+		total += w.weight
+	}
+	n := i % total
+	for _, w := range workloads {
+		if n < w.weight {
+			return w.kind
+		}
+		n -= w.weight
+	}
+	panic("unreachable")
+}
+
+// loadKeys reads the hex private keys of a key file, skipping blank lines and
+// # comments.
+func loadKeys(path string) ([]*ecdsa.PrivateKey, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var keys []*ecdsa.PrivateKey
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, err := crypto.HexToECDSA(strings.TrimPrefix(text, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys in %s", path)
+	}
+	return keys, nil
+}
+
+// loadSender is a funded account transactions are sent from. Its transactions
+// are sent in order by a single goroutine, so the nonce needs no locking.
+type loadSender struct {
+	key   *ecdsa.PrivateKey
+	addr  common.Address
+	nonce uint64
+}
+
+// loadTx is a sent transaction tracked until its inclusion.
+type loadTx struct {
+	hash     common.Hash
+	kind     string
+	sent     time.Time
+	included time.Time // Zero while pending
+	reverted bool
+}
+
+// loadReceipt is the part of a transaction receipt checked by the tracker.
+type loadReceipt struct {
+	BlockNumber     hexutil.Uint64  `json:"blockNumber"`
+	Status          *hexutil.Uint   `json:"status"`
+	ContractAddress *common.Address `json:"contractAddress"`
+}
+
+// loadRunner sends the workload and tracks the sent transactions.
+type loadRunner struct {
+	client    *rpc.Client
+	man       *manclient.Client
+	signer    types.Signer
+	gasPrice  *big.Int
+	slots     int
+	senders   []*loadSender
+	contracts map[string]common.Address
+
+	lock    sync.Mutex
+	txs     []*loadTx
+	pending map[common.Hash]*loadTx
+	errors  int
+	skipped int
+}
+
+// loadtest sends a generated transaction workload and reports on it.
+func loadtest(ctx *cli.Context) error {
+	if !ctx.IsSet(loadtestKeysFlag.Name) {
+		utils.Fatalf("No key file specified (--%s)", loadtestKeysFlag.Name)
+	}
+	keys, err := loadKeys(ctx.String(loadtestKeysFlag.Name))
+	if err != nil {
+		utils.Fatalf("Failed to load keys: %v", err)
+	}
+	workloads, err := parseWorkloads(ctx.String(loadtestWorkloadFlag.Name))
+	if err != nil {
+		utils.Fatalf("Invalid workload: %v", err)
+	}
+	tps := ctx.Float64(loadtestTPSFlag.Name)
+	if tps <= 0 {
+		utils.Fatalf("Invalid target rate %v", tps)
+	}
+	client, err := dialRPC(ctx.String(loadtestAttachFlag.Name))
+	if err != nil {
+		utils.Fatalf("Unable to attach to gman node: %v", err)
+	}
+	defer client.Close()
+
+	runner, err := newLoadRunner(client, keys, ctx.Uint64(loadtestGasPriceFlag.Name), ctx.Int(loadtestSlotsFlag.Name))
+	if err != nil {
+		utils.Fatalf("Failed to set up load test: %v", err)
+	}
+	timeout := ctx.Duration(loadtestTimeoutFlag.Name)
+	if err := runner.deploy(workloads, timeout); err != nil {
+		utils.Fatalf("Failed to deploy contracts: %v", err)
+	}
+	// Send the workload until done or interrupted, tracking receipts meanwhile
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	done := make(chan struct{})
+	tracked := make(chan struct{})
+	go func() {
+		runner.track(done, timeout)
+		close(tracked)
+	}()
+	start := time.Now()
+	fmt.Fprintf(os.Stderr, "Sending %v tx/s from %d accounts for %v\n", tps, len(runner.senders), ctx.Duration(loadtestDurationFlag.Name))
+	end := runner.send(workloads, tps, ctx.Duration(loadtestDurationFlag.Name), interrupt)
+
+	fmt.Fprintf(os.Stderr, "Waiting up to %v for pending transactions\n", timeout)
+	close(done)
+	select {
+	case <-tracked:
+	case <-interrupt:
+	}
+	runner.lock.Lock()
+	stats := computeLoadStats(runner.txs, start, end, runner.skipped, runner.errors)
+	runner.lock.Unlock()
+
+	if ctx.Bool(loadtestJSONFlag.Name) {
+		out, _ := json.MarshalIndent(stats, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		stats.print()
+	}
+	return nil
+}
+
+// newLoadRunner sets up the senders and the transaction signing parameters.
+func newLoadRunner(client *rpc.Client, keys []*ecdsa.PrivateKey, gasPrice uint64, slots int) (*loadRunner, error) {
+	runner := &loadRunner{
+		client:    client,
+		man:       manclient.NewClient(client),
+		slots:     slots,
+		contracts: make(map[string]common.Address),
+		pending:   make(map[common.Hash]*loadTx),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var chainID *hexutil.Big
+	if err := client.CallContext(ctx, &chainID, "man_chainId"); err != nil {
+		return nil, err
+	}
+	runner.signer = types.HomesteadSigner{}
+	if chainID != nil {
+		runner.signer = types.NewEIP155Signer(chainID.ToInt())
+	}
+	runner.gasPrice = new(big.Int).SetUint64(gasPrice)
+	if gasPrice == 0 {
+		price, err := runner.man.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, err
+		}
+		runner.gasPrice = price
+	}
+	for _, key := range keys {
+		sender := &loadSender{key: key, addr: crypto.PubkeyToAddress(key.PublicKey)}
+		nonce, err := runner.man.PendingNonceAt(ctx, sender.addr)
+		if err != nil {
+			return nil, err
+		}
+		sender.nonce = nonce
+		runner.senders = append(runner.senders, sender)
+	}
+	return runner, nil
+}
+
+// deploy creates the contracts needed by the workloads from the first sender
+// and waits for them to be included.
+func (r *loadRunner) deploy(workloads []loadWorkload, timeout time.Duration) error {
+	codes := map[string][]byte{loadMint: loadMintCode, loadStorage: loadStorageCode}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	sender := r.senders[0]
+	for _, w := range workloads {
+		code, ok := codes[w.kind]
+		if !ok {
+			continue
+		}
+		tx, err := types.SignTx(types.NewContractCreation(sender.nonce, new(big.Int), loadDeployGas, r.gasPrice, loadDeployCode(code)), r.signer, sender.key)
+		if err != nil {
+			return err
+		}
+		if err := r.man.SendTransaction(ctx, tx); err != nil {
+			return err
+		}
+		sender.nonce++
+
+		fmt.Fprintf(os.Stderr, "Deploying %s contract, transaction %x\n", w.kind, tx.Hash())
+		for {
+			var receipt *loadReceipt
+			if err := r.client.CallContext(ctx, &receipt, "man_getTransactionReceipt", tx.Hash()); err != nil {
+				return err
+			}
+			if receipt != nil {
+				if receipt.ContractAddress == nil || (receipt.Status != nil && *receipt.Status == hexutil.Uint(types.ReceiptStatusFailed)) {
+					return fmt.Errorf("%s contract deployment failed", w.kind)
+				}
+				r.contracts[w.kind] = *receipt.ContractAddress
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%s contract deployment not included: %v", w.kind, ctx.Err())
+			case <-time.After(loadPoll):
+			}
+		}
+	}
+	return nil
+}
+
+// send generates the workload at the target rate for the given duration or
+// until interrupted, returning the time sending stopped. Every sender has its
+// own queue, ticks finding it full are skipped.
+func (r *loadRunner) send(workloads []loadWorkload, tps float64, duration time.Duration, interrupt chan os.Signal) time.Time {
+	var (
+		wg     sync.WaitGroup
+		queues = make([]chan string, len(r.senders))
+	)
+	for i, sender := range r.senders {
+		queues[i] = make(chan string, loadQueue)
+
+		wg.Add(1)
+		go func(sender *loadSender, queue chan string) {
+			defer wg.Done()
+			for kind := range queue {
+				r.sendTx(sender, kind)
+			}
+		}(sender, queues[i])
+	}
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / tps))
+	defer ticker.Stop()
+
+	deadline := time.After(duration)
+loop:
+	for i := 0; ; i++ {
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			break loop
+		case <-interrupt:
+			break loop
+		}
+		select {
+		case queues[i%len(queues)] <- workloadAt(workloads, i):
+		default:
+			r.lock.Lock()
+			r.skipped++
+			r.lock.Unlock()
+		}
+	}
+	end := time.Now()
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+	return end
+}
+
+// sendTx signs and sends a single transaction of the given kind.
+func (r *loadRunner) sendTx(sender *loadSender, kind string) {
+	var (
+		to    common.Address
+		value = new(big.Int)
+		gas   uint64
+		data  []byte
+	)
+	switch kind {
+	case loadTransfer:
+		// Pass funds around the senders instead of burning them
+		to, value, gas = r.senders[(r.senderIndex(sender)+1)%len(r.senders)].addr, big.NewInt(1), loadTransferGas
+	case loadMint:
+		to, gas, data = r.contracts[loadMint], loadMintGas, common.LeftPadBytes(big.NewInt(1).Bytes(), 32)
+	case loadStorage:
+		to, gas, data = r.contracts[loadStorage], uint64(2*loadTransferGas+r.slots*loadSlotGas), common.LeftPadBytes(big.NewInt(int64(r.slots)).Bytes(), 32)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := types.SignTx(types.NewTransaction(sender.nonce, to, value, gas, r.gasPrice, data), r.signer, sender.key)
+	if err == nil {
+		err = r.man.SendTransaction(ctx, tx)
+	}
+	if err != nil {
+		// Resynchronize the nonce in case the node didn't take the transaction
+		if nonce, err := r.man.PendingNonceAt(ctx, sender.addr); err == nil {
+			sender.nonce = nonce
+		}
+		r.lock.Lock()
+		r.errors++
+		r.lock.Unlock()
+		return
+	}
+	sender.nonce++
+
+	sent := &loadTx{hash: tx.Hash(), kind: kind, sent: time.Now()}
+	r.lock.Lock()
+	r.txs = append(r.txs, sent)
+	r.pending[sent.hash] = sent
+	r.lock.Unlock()
+}
+
+// senderIndex returns the position of a sender in the sender list.
+func (r *loadRunner) senderIndex(sender *loadSender) int {
+	for i, s := range r.senders {
+		if s == sender {
+			return i
+		}
+	}
+	return 0
+}
+
+// track polls the receipts of the pending transactions, recording their
+// inclusion. Once done is closed, it keeps polling until no transaction is
+// pending or the timeout expires.
+func (r *loadRunner) track(done chan struct{}, timeout time.Duration) {
+	var expire <-chan time.Time
+	for {
+		select {
+		case <-done:
+			done, expire = nil, time.After(timeout)
+		case <-expire:
+			return
+		case <-time.After(loadPoll):
+		}
+		r.lock.Lock()
+		hashes := make([]common.Hash, 0, len(r.pending))
+		for hash := range r.pending {
+			hashes = append(hashes, hash)
+		}
+		r.lock.Unlock()
+
+		if len(hashes) == 0 && done == nil {
+			return
+		}
+		for len(hashes) > 0 {
+			batch := hashes
+			if len(batch) > loadBatch {
+				batch = batch[:loadBatch]
+			}
+			hashes = hashes[len(batch):]
+
+			if err := r.trackBatch(batch); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to retrieve receipts: %v\n", err)
+				break
+			}
+		}
+	}
+}
+
+// trackBatch retrieves the receipts of a batch of pending transactions.
+func (r *loadRunner) trackBatch(hashes []common.Hash) error {
+	var (
+		receipts = make([]*loadReceipt, len(hashes))
+		reqs     = make([]rpc.BatchElem, len(hashes))
+	)
+	for i, hash := range hashes {
+		reqs[i] = rpc.BatchElem{Method: "man_getTransactionReceipt", Args: []interface{}{hash}, Result: &receipts[i]}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := r.client.BatchCallContext(ctx, reqs); err != nil {
+		return err
+	}
+	now := time.Now()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for i, hash := range hashes {
+		if reqs[i].Error != nil || receipts[i] == nil {
+			continue
+		}
+		tx := r.pending[hash]
+		tx.included = now
+		tx.reverted = receipts[i].Status != nil && *receipts[i].Status == hexutil.Uint(types.ReceiptStatusFailed)
+		delete(r.pending, hash)
+	}
+	return nil
+}
+
+// loadLatency is the distribution of the inclusion latencies in seconds.
+type loadLatency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// loadKindStats are the statistics of a single kind of transaction.
+type loadKindStats struct {
+	Sent     int `json:"sent"`
+	Included int `json:"included"`
+	Reverted int `json:"reverted"`
+}
+
+// loadStats is the outcome of a load test. Rates are in transactions per
+// second, the latency is measured with the receipt polling precision.
+type loadStats struct {
+	Duration      float64                   `json:"duration"`
+	Sent          int                       `json:"sent"`
+	Skipped       int                       `json:"skipped"`
+	Errors        int                       `json:"errors"`
+	Included      int                       `json:"included"`
+	Reverted      int                       `json:"reverted"`
+	Pending       int                       `json:"pending"`
+	SendRate      float64                   `json:"sendRate"`
+	InclusionRate float64                   `json:"inclusionRate"`
+	Latency       loadLatency               `json:"latency"`
+	Kinds         map[string]*loadKindStats `json:"kinds"`
+}
+
+// computeLoadStats aggregates the tracked transactions of a load test which
+// sent between start and end.
+func computeLoadStats(txs []*loadTx, start, end time.Time, skipped, errors int) *loadStats {
+	stats := &loadStats{
+		Duration: end.Sub(start).Seconds(),
+		Sent:     len(txs),
+		Skipped:  skipped,
+		Errors:   errors,
+		Kinds:    make(map[string]*loadKindStats),
+	}
+	var (
+		latencies []float64
+		last      time.Time
+	)
+	for _, tx := range txs {
+		kind := stats.Kinds[tx.kind]
+		if kind == nil {
+			kind = new(loadKindStats)
+			stats.Kinds[tx.kind] = kind
+		}
+		kind.Sent++
+
+		if tx.included.IsZero() {
+			stats.Pending++
+			continue
+		}
+		stats.Included++
+		kind.Included++
+		if tx.reverted {
+			stats.Reverted++
+			kind.Reverted++
+		}
+		latencies = append(latencies, tx.included.Sub(tx.sent).Seconds())
+		if tx.included.After(last) {
+			last = tx.included
+		}
+	}
+	if stats.Duration > 0 {
+		stats.SendRate = float64(stats.Sent) / stats.Duration
+	}
+	if elapsed := last.Sub(start).Seconds(); stats.Included > 0 && elapsed > 0 {
+		stats.InclusionRate = float64(stats.Included) / elapsed
+	}
+	if len(latencies) > 0 {
+		sort.Float64s(latencies)
+
+		sum := 0.0
+		for _, l := range latencies {
+			sum += l
+		}
+		percentile := func(p float64) float64 {
+			return latencies[int(p*float64(len(latencies)-1))]
+		}
+		stats.Latency = loadLatency{
+			Min:  latencies[0],
+			Mean: sum / float64(len(latencies)),
+			P50:  percentile(0.5),
+			P90:  percentile(0.9),
+			P99:  percentile(0.99),
+			Max:  latencies[len(latencies)-1],
+		}
+	}
+	return stats
+}
+
+// print writes the statistics in human readable form.
+func (s *loadStats) print() {
+	seconds := func(v float64) time.Duration {
+		return time.Duration(v * float64(time.Second)).Round(time.Millisecond)
+	}
+	fmt.Printf("Duration:   %v\n", seconds(s.Duration))
+	fmt.Printf("Sent:       %d (skipped %d, errors %d)\n", s.Sent, s.Skipped, s.Errors)
+	fmt.Printf("Included:   %d (reverted %d, pending %d)\n", s.Included, s.Reverted, s.Pending)
+	fmt.Printf("Send rate:  %.2f tx/s\n", s.SendRate)
+	fmt.Printf("Inclusion:  %.2f tx/s\n", s.InclusionRate)
+	fmt.Printf("Latency:    min %v, mean %v, p50 %v, p90 %v, p99 %v, max %v\n",
+		seconds(s.Latency.Min), seconds(s.Latency.Mean), seconds(s.Latency.P50),
+		seconds(s.Latency.P90), seconds(s.Latency.P99), seconds(s.Latency.Max))
+
+	kinds := make([]string, 0, len(s.Kinds))
+	for kind := range s.Kinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		k := s.Kinds[kind]
+		fmt.Printf("%-10s  sent %d, included %d, reverted %d\n", kind+":", k.Sent, k.Included, k.Reverted)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core/vm/runtime"
+)
+
+func TestParseWorkloads(t *testing.T) {
+	workloads, err := parseWorkloads("transfer:3, mint ,storage:1")
+	if err != nil {
+		t.Fatalf("failed to parse workloads: %v", err)
+	}
+	want := []loadWorkload{{loadTransfer, 3}, {loadMint, 1}, {loadStorage, 1}}
+	if len(workloads) != len(want) {
+		t.Fatalf("workload count mismatch: have %d, want %d", len(workloads), len(want))
+	}
+	for i := range want {
+		if workloads[i] != want[i] {
+			t.Errorf("workload %d: have %+v, want %+v", i, workloads[i], want[i])
+		}
+	}
+	// Every cycle of the mix follows the weights
+	counts := make(map[string]int)
+	for i := 0; i < 50; i++ {
+		counts[workloadAt(workloads, i)]++
+	}
+	if counts[loadTransfer] != 30 || counts[loadMint] != 10 || counts[loadStorage] != 10 {
+		t.Errorf("mix mismatch: have %v", counts)
+	}
+	for _, spec := range []string{"", "burn", "transfer:0", "transfer:x", "mint,mint"} {
+		if _, err := parseWorkloads(spec); err == nil {
+			t.Errorf("invalid workload %q accepted", spec)
+		}
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir := tmpdir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "keys")
+	ioutil.WriteFile(path, []byte("# funded\n0x289c2857d4598e37fb9647507e47a309d6133539bf21a8b9cb6df88fd5232032\n\nb71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291\n"), 0600)
+	keys, err := loadKeys(path)
+	if err != nil {
+		t.Fatalf("failed to load keys: %v", err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("key count mismatch: have %d, want 2", len(keys))
+	}
+	ioutil.WriteFile(path, []byte("0x289c\n"), 0600)
+	if _, err := loadKeys(path); err == nil {
+		t.Fatalf("invalid key accepted")
+	}
+	ioutil.WriteFile(path, []byte("# empty\n"), 0600)
+	if _, err := loadKeys(path); err == nil {
+		t.Fatalf("empty key file accepted")
+	}
+}
+
+func TestLoadContracts(t *testing.T) {
+	origin := common.HexToAddress("0x1234")
+
+	// Deployment returns the runtime code
+	for _, code := range [][]byte{loadMintCode, loadStorageCode} {
+		deployed, _, _, err := runtime.Create(loadDeployCode(code), &runtime.Config{Origin: origin})
+		if err != nil {
+			t.Fatalf("failed to deploy: %v", err)
+		}
+		if !bytes.Equal(deployed, code) {
+			t.Fatalf("deployed code mismatch: have %x, want %x", deployed, code)
+		}
+	}
+	// Minting credits the caller and emits a Transfer event
+	amount := common.LeftPadBytes(big.NewInt(5).Bytes(), 32)
+	_, statedb, err := runtime.Execute(loadMintCode, amount, &runtime.Config{Origin: origin})
+	if err != nil {
+		t.Fatalf("failed to mint: %v", err)
+	}
+	contract := common.BytesToAddress([]byte("contract"))
+	if balance := statedb.GetState(contract, origin.Hash()).Big(); balance.Int64() != 5 {
+		t.Errorf("minted balance mismatch: have %v, want 5", balance)
+	}
+	if logs := statedb.Logs(); len(logs) != 1 || logs[0].Topics[0] != loadTransferTopic || logs[0].Topics[2] != origin.Hash() {
+		t.Errorf("transfer event mismatch: have %v", logs)
+	}
+	// Storage calls write fresh slots and count them
+	count := common.LeftPadBytes(big.NewInt(3).Bytes(), 32)
+	_, statedb, err = runtime.Execute(loadStorageCode, count, &runtime.Config{Origin: origin, BlockNumber: big.NewInt(7)})
+	if err != nil {
+		t.Fatalf("failed to write storage: %v", err)
+	}
+	if written := statedb.GetState(contract, common.Hash{}).Big(); written.Int64() != 3 {
+		t.Errorf("written slot count mismatch: have %v, want 3", written)
+	}
+	for slot := int64(1); slot <= 4; slot++ {
+		value := statedb.GetState(contract, common.BigToHash(big.NewInt(slot))).Big().Int64()
+		if want := map[bool]int64{true: 7, false: 0}[slot <= 3]; value != want {
+			t.Errorf("slot %d mismatch: have %d, want %d", slot, value, want)
+		}
+	}
+}
+
+func TestLoadStats(t *testing.T) {
+	start := time.Now()
+	at := func(s float64) time.Time { return start.Add(time.Duration(s * float64(time.Second))) }
+
+	txs := []*loadTx{
+		{kind: loadTransfer, sent: at(0), included: at(2)},
+		{kind: loadTransfer, sent: at(1), included: at(2)},
+		{kind: loadMint, sent: at(2), included: at(6), reverted: true},
+		{kind: loadMint, sent: at(3)},
+	}
+	stats := computeLoadStats(txs, start, at(4), 1, 2)
+
+	if stats.Sent != 4 || stats.Included != 3 || stats.Reverted != 1 || stats.Pending != 1 || stats.Skipped != 1 || stats.Errors != 2 {
+		t.Fatalf("counts mismatch: have %+v", stats)
+	}
+	if stats.SendRate != 1 {
+		t.Errorf("send rate mismatch: have %v, want 1", stats.SendRate)
+	}
+	if stats.InclusionRate != 0.5 {
+		t.Errorf("inclusion rate mismatch: have %v, want 0.5", stats.InclusionRate)
+	}
+	want := loadLatency{Min: 1, Mean: 7.0 / 3, P50: 2, P90: 2, P99: 2, Max: 4}
+	if stats.Latency != want {
+		t.Errorf("latency mismatch: have %+v, want %+v", stats.Latency, want)
+	}
+	if k := stats.Kinds[loadMint]; k == nil || k.Sent != 2 || k.Included != 1 || k.Reverted != 1 {
+		t.Errorf("mint stats mismatch: have %+v", k)
+	}
+}
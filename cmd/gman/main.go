@@ -48,6 +48,10 @@ const (
 var (
 	// Git SHA1 commit hash of the release (set via linker flags)
 	gitCommit = ""
+	// Git commit date of the release, YYYYMMDD (set via linker flags)
+	gitDate = ""
+	// Comma separated build tags of the release (set via linker flags)
+	buildTags = ""
 	// The app that holds all commands and flags.
 	app = utils.NewApp(gitCommit, "the go-matrix command line interface")
 	// flags that configure the node
@@ -59,12 +63,36 @@ var (
 		utils.BootnodesV4Flag,
 		utils.BootnodesV5Flag,
 		utils.DataDirFlag,
+		utils.ChainDataDirFlag,
 		utils.KeyStoreDirFlag,
 		utils.NoUSBFlag,
 		utils.DashboardEnabledFlag,
 		utils.DashboardAddrFlag,
 		utils.DashboardPortFlag,
 		utils.DashboardRefreshFlag,
+		utils.FaucetEnabledFlag,
+		utils.FaucetAddrFlag,
+		utils.FaucetPortFlag,
+		utils.FaucetAccountFlag,
+		utils.FaucetAmountFlag,
+		utils.FaucetAddressCooldownFlag,
+		utils.FaucetIPCooldownFlag,
+		utils.FaucetCaptchaURLFlag,
+		utils.FaucetCaptchaSecretFlag,
+		utils.FaucetTrustedProxiesFlag,
+		utils.UserOpEntryPointFlag,
+		utils.UserOpMaxOpsFlag,
+		utils.UserOpLifetimeFlag,
+		utils.UserOpValidationGasFlag,
+		utils.UserOpBundleSizeFlag,
+		utils.UserOpBundleGasFlag,
+		utils.WatchdogEnabledFlag,
+		utils.WatchdogTimeoutFlag,
+		utils.WatchdogActionFlag,
+		utils.ServiceSuperviseFlag,
+		utils.ServiceMaxBackoffFlag,
+		utils.ServiceRestartLimitFlag,
+		utils.PluginsFlag,
 		utils.EthashCacheDirFlag,
 		utils.EthashCachesInMemoryFlag,
 		utils.EthashCachesOnDiskFlag,
@@ -81,29 +109,54 @@ var (
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolPendingLifetimeFlag,
+		utils.TxPoolRejectUnprotectedFlag,
+		utils.TxPoolUnprotectedGraceFlag,
+		utils.TxPoolSpamGuardFlag,
+		utils.TxPoolSpamShareFlag,
+		utils.TxPoolSpamWindowFlag,
+		utils.TxPoolSpamAllowFlag,
 		utils.FastSyncFlag,
 		utils.LightModeFlag,
 		utils.SyncModeFlag,
 		utils.GCModeFlag,
+		utils.FutureBlockDriftFlag,
+		utils.PinHeadFlag,
+		utils.ForkMonitorFlag,
+		utils.ForkMonitorDepthFlag,
+		utils.ForkMonitorWebhookFlag,
+		utils.BlobsFlag,
+		utils.BlobsStoreFlag,
+		utils.BlobsHorizonFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
 		utils.LightKDFFlag,
+		utils.RemoteStateFlag,
+		utils.RemoteStateCacheFlag,
 		utils.CacheFlag,
 		utils.CacheDatabaseFlag,
 		utils.CacheGCFlag,
 		utils.TrieCacheGenFlag,
 		utils.ListenPortFlag,
+		utils.ExtraListenAddrsFlag,
+		utils.CaptureDirFlag,
+		utils.CaptureProtocolsFlag,
 		utils.MaxPeersFlag,
 		utils.MaxPendingPeersFlag,
 		utils.EtherbaseFlag,
 		utils.GasPriceFlag,
 		utils.MinerThreadsFlag,
+		utils.PropagationModeFlag,
+		utils.PropagationMinFullFlag,
+		utils.PropagationValidatorsFlag,
+		utils.PropagationPriorityFlag,
 		utils.MiningEnabledFlag,
 		utils.TargetGasLimitFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
 		utils.NetrestrictFlag,
+		utils.GeoIPFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
 		utils.DeveloperFlag,
@@ -111,9 +164,20 @@ var (
 		utils.TestnetFlag,
 		utils.RinkebyFlag,
 		utils.VMEnableDebugFlag,
+		utils.VMParallelTxsFlag,
+		utils.StateAccessIndexFlag,
+		utils.TraceIndexFlag,
+		utils.VMKZGSetupFlag,
 		utils.NetworkIdFlag,
 		utils.RPCCORSDomainFlag,
 		utils.RPCVirtualHostsFlag,
+		utils.RPCCacheSizeFlag,
+		utils.RPCAttestFlag,
+		utils.RPCAPIKeysFlag,
+		utils.AdminOperatorsFlag,
+		utils.AdminThresholdFlag,
+		utils.AdminMethodsFlag,
+		utils.AdminAuditFlag,
 		utils.EthStatsURLFlag,
 		utils.MetricsEnabledFlag,
 		utils.FakePoWFlag,
@@ -131,20 +195,27 @@ var (
 		utils.RPCEnabledFlag,
 		utils.RPCListenAddrFlag,
 		utils.RPCPortFlag,
+		utils.RPCExtraListenAddrsFlag,
 		utils.RPCApiFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
+		utils.WSExtraListenAddrsFlag,
 		utils.WSApiFlag,
 		utils.WSAllowedOriginsFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
+		utils.IPCModeFlag,
+		utils.IPCGroupFlag,
 	}
 
 	whisperFlags = []cli.Flag{
 		utils.WhisperEnabledFlag,
 		utils.WhisperMaxMessageSizeFlag,
 		utils.WhisperMinPOWFlag,
+		utils.WhisperMaxFiltersFlag,
+		utils.WhisperPersistFlag,
+		utils.WhisperRetentionFlag,
 	}
 )
 
@@ -163,6 +234,8 @@ func init() {
 		copydbCommand,
 		removedbCommand,
 		dumpCommand,
+		traceBackfillCommand,
+		exportStateCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
@@ -178,6 +251,16 @@ func init() {
 		versionCommand,
 		bugCommand,
 		licenseCommand,
+		// See doctorcmd.go:
+		doctorCommand,
+		// See loadtestcmd.go:
+		loadtestCommand,
+		// See nodekeycmd.go:
+		nodekeyCommand,
+		// See devnetcmd.go:
+		devnetCommand,
+		// See replaycmd.go:
+		replayCommand,
 		// See config.go
 		dumpConfigCommand,
 	}
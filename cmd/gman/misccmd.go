@@ -112,6 +112,12 @@ func version(ctx *cli.Context) error {
 	if gitCommit != "" {
 		fmt.Println("Git Commit:", gitCommit)
 	}
+	if gitDate != "" {
+		fmt.Println("Git Commit Date:", gitDate)
+	}
+	if buildTags != "" {
+		fmt.Println("Build Tags:", buildTags)
+	}
 	fmt.Println("Architecture:", runtime.GOARCH)
 	fmt.Println("Protocol Versions:", man.ProtocolVersions)
 	fmt.Println("Network Id:", man.DefaultConfig.NetworkId)
@@ -0,0 +1,108 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/matrix/go-matrix/cmd/utils"
+	"github.com/matrix/go-matrix/console"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/node"
+	"github.com/matrix/go-matrix/p2p/discover"
+	"github.com/matrix/go-matrix/params"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	nodekeyForceFlag = cli.BoolFlag{
+		Name:  "force",
+		Usage: "Rotate without asking for confirmation",
+	}
+
+	nodekeyCommand = cli.Command{
+		Name:     "nodekey",
+		Usage:    "Manage the node key",
+		Category: "MISCELLANEOUS COMMANDS",
+		Description: `
+The node key is the private key the node identifies itself with on the p2p
+network. It is stored under <DATADIR>/gman/nodekey.`,
+		Subcommands: []cli.Command{
+			{
+				Name:   "rotate",
+				Usage:  "Replace the node key with a new one",
+				Action: utils.MigrateFlags(nodekeyRotate),
+				Flags: []cli.Flag{
+					configFileFlag,
+					utils.DataDirFlag,
+					nodekeyForceFlag,
+				},
+				Description: `
+    gman nodekey rotate
+
+generates a new node key for a stopped node. The old key is kept as
+nodekey.<timestamp>.bak next to the new one. The nodes of the discovery
+database are kept, but bonded with again under the new identity.
+
+Elected and broadcast nodes are registered by their node id. Rotating their
+key drops their role, use the running node's admin.rotateNodeKey to have this
+checked against the current election.`,
+			},
+		},
+	}
+)
+
+// nodekeyRotate replaces the node key of a stopped node.
+func nodekeyRotate(ctx *cli.Context) error {
+	cfg := gmanConfig{Node: defaultNodeConfig()}
+	if file := ctx.GlobalString(configFileFlag.Name); file != "" {
+		if err := loadConfig(file, &cfg); err != nil {
+			utils.Fatalf("%v", err)
+		}
+	}
+	utils.SetNodeConfig(ctx, &cfg.Node)
+
+	if !ctx.Bool(nodekeyForceFlag.Name) {
+		if key, err := crypto.LoadECDSA(cfg.Node.ResolvePath("nodekey")); err == nil {
+			id := discover.PubkeyID(&key.PublicKey)
+			for _, b := range params.BroadCastNodes {
+				if b.NodeID == id {
+					utils.Fatalf("Node %x is a broadcast node, use --force to rotate anyway", id[:8])
+				}
+			}
+		}
+		fmt.Println("Rotating the node key changes the identity of the node on the network.")
+		confirm, err := console.Stdin.PromptConfirm("Rotate the node key?")
+		switch {
+		case err != nil:
+			utils.Fatalf("%v", err)
+		case !confirm:
+			return nil
+		}
+	}
+	rotation, err := node.RotateNodeKey(&cfg.Node)
+	if err != nil {
+		utils.Fatalf("Failed to rotate node key: %v", err)
+	}
+	if rotation.OldID != nil {
+		fmt.Printf("Old node id: %x\n", rotation.OldID[:])
+		fmt.Printf("Backup:      %s\n", rotation.Backup)
+	}
+	fmt.Printf("New node id: %x\n", rotation.NewID[:])
+	fmt.Printf("Known nodes to bond with again: %d\n", rotation.Bonds)
+	return nil
+}
@@ -0,0 +1,378 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+
+	cli "gopkg.in/urfave/cli.v1"
+
+	"github.com/matrix/go-matrix/cmd/utils"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/internal/debug"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/man"
+	"github.com/matrix/go-matrix/node"
+	"github.com/matrix/go-matrix/p2p"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+var errNoConfigFile = errors.New("node not started with a configuration file")
+
+// reloadSetting is a configuration value that can be changed on a running node.
+type reloadSetting struct {
+	name  string                            // Path of the setting in the configuration file
+	flag  string                            // Command line flag taking precedence over the file
+	group string                            // Settings applied together
+	field func(cfg *gmanConfig) interface{} // Pointer to the setting within a configuration
+}
+
+// reloadSettings is the subset of the configuration that is safe to change
+// without restarting the node.
+var reloadSettings = []reloadSetting{
+	{"Log.Verbosity", "verbosity", "log", func(cfg *gmanConfig) interface{} { return &cfg.Log.Verbosity }},
+	{"Log.Vmodule", "vmodule", "log", func(cfg *gmanConfig) interface{} { return &cfg.Log.Vmodule }},
+	{"Node.P2P.MaxPeers", utils.MaxPeersFlag.Name, "peers", func(cfg *gmanConfig) interface{} { return &cfg.Node.P2P.MaxPeers }},
+	{"Eth.TxPool.AccountSlots", utils.TxPoolAccountSlotsFlag.Name, "txpool", func(cfg *gmanConfig) interface{} { return &cfg.Eth.TxPool.AccountSlots }},
+	{"Eth.TxPool.GlobalSlots", utils.TxPoolGlobalSlotsFlag.Name, "txpool", func(cfg *gmanConfig) interface{} { return &cfg.Eth.TxPool.GlobalSlots }},
+	{"Eth.TxPool.AccountQueue", utils.TxPoolAccountQueueFlag.Name, "txpool", func(cfg *gmanConfig) interface{} { return &cfg.Eth.TxPool.AccountQueue }},
+	{"Eth.TxPool.GlobalQueue", utils.TxPoolGlobalQueueFlag.Name, "txpool", func(cfg *gmanConfig) interface{} { return &cfg.Eth.TxPool.GlobalQueue }},
+	{"Eth.TxPool.Lifetime", utils.TxPoolLifetimeFlag.Name, "txpool", func(cfg *gmanConfig) interface{} { return &cfg.Eth.TxPool.Lifetime }},
+	{"Eth.TxPool.PendingLifetime", utils.TxPoolPendingLifetimeFlag.Name, "txpool", func(cfg *gmanConfig) interface{} { return &cfg.Eth.TxPool.PendingLifetime }},
+	{"Eth.ExtraData", utils.ExtraDataFlag.Name, "miner", func(cfg *gmanConfig) interface{} { return &cfg.Eth.ExtraData }},
+}
+
+// ConfigChange describes a setting updated by a configuration reload.
+type ConfigChange struct {
+	Setting string      `json:"setting"`
+	Old     interface{} `json:"old"`
+	New     interface{} `json:"new"`
+}
+
+// ReloadResult is the outcome of a configuration reload.
+type ReloadResult struct {
+	Changed        []ConfigChange `json:"changed"`        // Settings applied to the running node
+	UpdatedAPIKeys []string       `json:"updatedAPIKeys"` // RPC API keys added or changed in the key file
+	RemovedAPIKeys []string       `json:"removedAPIKeys"` // RPC API keys no longer in the key file
+	NeedRestart    []string       `json:"needRestart"`    // Settings changed in the file that only apply after a restart
+}
+
+// configReloader re-reads the configuration file on SIGHUP or admin_reloadConfig
+// and applies the reloadable settings to the running node.
+type configReloader struct {
+	ctx    *cli.Context
+	file   string
+	stack  *node.Node
+	matrix *man.Matrix // Nil for light clients
+	server *p2p.Server
+
+	lock sync.Mutex
+	raw  gmanConfig // Defaults and configuration file, without command line flags
+	cfg  gmanConfig // Configuration in effect
+
+	quit chan struct{}
+}
+
+// registerConfigReloader adds the configuration reloader to the stack. It
+// relies on the full node service if present.
+func registerConfigReloader(ctx *cli.Context, stack *node.Node, cfg gmanConfig) {
+	err := stack.Register(func(sctx *node.ServiceContext) (node.Service, error) {
+		r := &configReloader{
+			ctx:   ctx,
+			file:  ctx.GlobalString(configFileFlag.Name),
+			stack: stack,
+			cfg:   cfg,
+		}
+		var matrix *man.Matrix
+		if err := sctx.Service(&matrix); err == nil {
+			r.matrix = matrix
+		}
+		if r.file != "" {
+			raw, err := r.load()
+			if err != nil {
+				return nil, err
+			}
+			r.raw = raw
+		}
+		return r, nil
+	})
+	if err != nil {
+		utils.Fatalf("Failed to register the configuration reloader: %v", err)
+	}
+}
+
+// Protocols implements node.Service.
+func (r *configReloader) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, adding admin_reloadConfig.
+func (r *configReloader) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   &PrivateReloadAPI{r},
+		},
+	}
+}
+
+// Start implements node.Service, reloading the configuration on SIGHUP if the
+// node runs off a configuration file.
+func (r *configReloader) Start(server *p2p.Server) error {
+	r.server = server
+	r.quit = make(chan struct{})
+	if r.file != "" {
+		go r.loop()
+	}
+	return nil
+}
+
+// Stop implements node.Service.
+func (r *configReloader) Stop() error {
+	close(r.quit)
+	return nil
+}
+
+func (r *configReloader) loop() {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		select {
+		case <-sighup:
+			log.Info("Got SIGHUP, reloading configuration", "file", r.file)
+			if _, err := r.reload(); err != nil {
+				log.Error("Failed to reload configuration", "file", r.file, "err", err)
+			}
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// load reads the defaults and the configuration file.
+func (r *configReloader) load() (gmanConfig, error) {
+	cfg := defaultGmanConfig()
+	if err := loadConfig(r.file, &cfg); err != nil {
+		return gmanConfig{}, err
+	}
+	return cfg, nil
+}
+
+// reload re-reads the configuration file, applies the changed reloadable
+// settings and logs the differences.
+func (r *configReloader) reload() (*ReloadResult, error) {
+	if r.file == "" {
+		return nil, errNoConfigFile
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	raw, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	result := &ReloadResult{
+		Changed:     []ConfigChange{},
+		NeedRestart: restartSettings("", reflect.ValueOf(r.raw), reflect.ValueOf(raw)),
+	}
+	// Work out the new value of each reloadable setting, the flags still win
+	next := r.cfg
+	for _, setting := range reloadSettings {
+		if r.ctx.GlobalIsSet(setting.flag) {
+			continue
+		}
+		reflect.ValueOf(setting.field(&next)).Elem().Set(reflect.ValueOf(setting.field(&raw)).Elem())
+	}
+	if !r.ctx.GlobalIsSet(utils.MaxPeersFlag.Name) {
+		next.Node.P2P.MaxPeers = r.peerLimit(raw.Node.P2P.MaxPeers)
+	}
+	// Apply the changed settings group by group, keeping the old values of
+	// any group that failed
+	var errs []string
+	for _, group := range []string{"log", "peers", "txpool", "miner"} {
+		var (
+			settings []reloadSetting
+			changes  []ConfigChange
+		)
+		for _, setting := range reloadSettings {
+			if setting.group != group {
+				continue
+			}
+			prev := reflect.ValueOf(setting.field(&r.cfg)).Elem()
+			cur := reflect.ValueOf(setting.field(&next)).Elem()
+			if !reflect.DeepEqual(prev.Interface(), cur.Interface()) {
+				settings = append(settings, setting)
+				changes = append(changes, ConfigChange{setting.name, settingValue(prev.Interface()), settingValue(cur.Interface())})
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		if err := r.apply(group, &next); err != nil {
+			log.Warn("Failed to apply reloaded settings", "settings", group, "err", err)
+			errs = append(errs, err.Error())
+			continue
+		}
+		for i, change := range changes {
+			log.Info("Reloaded configuration setting", "setting", change.Setting, "old", change.Old, "new", change.New)
+			reflect.ValueOf(settings[i].field(&r.cfg)).Elem().Set(reflect.ValueOf(settings[i].field(&next)).Elem())
+		}
+		result.Changed = append(result.Changed, changes...)
+	}
+	// Pick up any changed API keys and rate limits
+	if r.cfg.Node.RPCAPIKeys != "" {
+		updated, removed, err := r.stack.ReloadAPIKeys()
+		if err != nil {
+			log.Warn("Failed to reload RPC API keys", "err", err)
+			errs = append(errs, err.Error())
+		}
+		if len(updated) > 0 || len(removed) > 0 {
+			log.Info("Reloaded RPC API keys", "updated", updated, "removed", removed)
+		}
+		result.UpdatedAPIKeys, result.RemovedAPIKeys = updated, removed
+	}
+	for _, name := range result.NeedRestart {
+		log.Warn("Configuration setting changed, restart to apply", "setting", name)
+	}
+	r.raw = raw
+
+	if len(result.Changed) == 0 && len(result.UpdatedAPIKeys) == 0 && len(result.RemovedAPIKeys) == 0 {
+		log.Info("Configuration reloaded, nothing changed", "file", r.file)
+	}
+	if len(errs) > 0 {
+		return result, errors.New(strings.Join(errs, "; "))
+	}
+	return result, nil
+}
+
+// apply pushes a group of reloadable settings to the running node.
+func (r *configReloader) apply(group string, cfg *gmanConfig) error {
+	switch group {
+	case "log":
+		verbosity, vmodule := cfg.Log.Verbosity, cfg.Log.Vmodule
+		if verbosity == 0 {
+			verbosity = r.ctx.GlobalInt("verbosity")
+		}
+		if vmodule == "" {
+			vmodule = r.ctx.GlobalString("vmodule")
+		}
+		if err := debug.Handler.Vmodule(vmodule); err != nil {
+			return err
+		}
+		debug.Handler.Verbosity(verbosity)
+
+	case "peers":
+		if r.matrix != nil {
+			if err := r.matrix.SetMaxPeers(cfg.Node.P2P.MaxPeers); err != nil {
+				return err
+			}
+		}
+		r.server.SetMaxPeers(cfg.Node.P2P.MaxPeers)
+
+	case "txpool":
+		if r.matrix == nil {
+			return errors.New("transaction pool not running")
+		}
+		r.matrix.TxPool().SetLimits(cfg.Eth.TxPool)
+
+	case "miner":
+		if r.matrix == nil {
+			return errors.New("miner not running")
+		}
+		return r.matrix.Miner().SetExtra(cfg.Eth.ExtraData)
+	}
+	return nil
+}
+
+// peerLimit converts the peer limit of the configuration file into the total
+// limit of the p2p server, the way the command line setup does.
+func (r *configReloader) peerLimit(maxPeers int) int {
+	ctx := r.ctx
+	lightClient := ctx.GlobalBool(utils.LightModeFlag.Name) || ctx.GlobalString(utils.SyncModeFlag.Name) == "light"
+	if lightClient || ctx.GlobalBool(utils.DeveloperFlag.Name) {
+		return r.cfg.Node.P2P.MaxPeers // Fixed by the mode of operation
+	}
+	if ctx.GlobalInt(utils.LightServFlag.Name) != 0 {
+		maxPeers += ctx.GlobalInt(utils.LightPeersFlag.Name)
+	}
+	return maxPeers
+}
+
+// settingValue converts a setting into a loggable value.
+func settingValue(v interface{}) interface{} {
+	if blob, ok := v.([]byte); ok {
+		return hexutil.Bytes(blob)
+	}
+	return v
+}
+
+// restartSettings returns the settings differing between two configurations
+// that only take effect after a restart.
+func restartSettings(prefix string, prev, cur reflect.Value) []string {
+	var changed []string
+	for i := 0; i < prev.NumField(); i++ {
+		field := prev.Type().Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		name := field.Name
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if reloadable(name) {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct && reloadable(name+".") {
+			changed = append(changed, restartSettings(name, prev.Field(i), cur.Field(i))...)
+			continue
+		}
+		if !reflect.DeepEqual(prev.Field(i).Interface(), cur.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// reloadable reports whether the named setting, or any setting under the given
+// prefix ending with a dot, is reloadable.
+func reloadable(name string) bool {
+	for _, setting := range reloadSettings {
+		if setting.name == name || (strings.HasSuffix(name, ".") && strings.HasPrefix(setting.name, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// PrivateReloadAPI exposes configuration reloads in the admin namespace.
+type PrivateReloadAPI struct {
+	r *configReloader
+}
+
+// ReloadConfig re-reads the configuration file and applies the log levels, peer
+// limits, transaction pool limits, miner extra-data and RPC API keys to the
+// running node. Other changed settings are reported as needing a restart.
+func (api *PrivateReloadAPI) ReloadConfig() (*ReloadResult, error) {
+	return api.r.reload()
+}
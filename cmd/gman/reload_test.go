@@ -0,0 +1,104 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	cli "gopkg.in/urfave/cli.v1"
+
+	"github.com/matrix/go-matrix/cmd/utils"
+	"github.com/matrix/go-matrix/internal/debug"
+	"github.com/matrix/go-matrix/p2p"
+)
+
+func TestConfigReload(t *testing.T) {
+	dir := tmpdir(t)
+	defer os.RemoveAll(dir)
+	defer debug.Handler.Vmodule("")
+
+	file := filepath.Join(dir, "config.toml")
+	writeConfig := func(content string) {
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig("[Node.P2P]\nMaxPeers = 10\n")
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String(configFileFlag.Name, file, "")
+	set.Int("verbosity", 3, "")
+	set.String("vmodule", "", "")
+	set.Int(utils.MaxPeersFlag.Name, 25, "")
+
+	r := &configReloader{ctx: cli.NewContext(cli.NewApp(), set, nil), file: file, server: &p2p.Server{}}
+	cfg, err := r.load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	r.raw, r.cfg = cfg, cfg
+	r.server.MaxPeers = cfg.Node.P2P.MaxPeers
+
+	// Change reloadable and restart-only settings alike
+	writeConfig("[Log]\nVmodule = \"p2p=5\"\n[Node]\nHTTPPort = 9999\n[Node.P2P]\nMaxPeers = 20\n")
+	result, err := r.reload()
+	if err != nil {
+		t.Fatalf("failed to reload config: %v", err)
+	}
+	want := []ConfigChange{
+		{"Log.Vmodule", "", "p2p=5"},
+		{"Node.P2P.MaxPeers", 10, 20},
+	}
+	if !reflect.DeepEqual(result.Changed, want) {
+		t.Errorf("changed settings mismatch: have %v, want %v", result.Changed, want)
+	}
+	if want := []string{"Node.HTTPPort"}; !reflect.DeepEqual(result.NeedRestart, want) {
+		t.Errorf("restart settings mismatch: have %v, want %v", result.NeedRestart, want)
+	}
+	if r.server.MaxPeers != 20 {
+		t.Errorf("peer limit mismatch: have %d, want 20", r.server.MaxPeers)
+	}
+	// Settings given on the command line must not be overridden by the file
+	if err := set.Set(utils.MaxPeersFlag.Name, "25"); err != nil {
+		t.Fatal(err)
+	}
+	r.ctx = cli.NewContext(cli.NewApp(), set, nil)
+
+	writeConfig("[Log]\nVmodule = \"p2p=5\"\n[Node]\nHTTPPort = 9999\n[Node.P2P]\nMaxPeers = 30\n")
+	if result, err = r.reload(); err != nil {
+		t.Fatalf("failed to reload config: %v", err)
+	}
+	if len(result.Changed) != 0 || len(result.NeedRestart) != 0 {
+		t.Errorf("unexpected changes: %v, restart %v", result.Changed, result.NeedRestart)
+	}
+	if r.server.MaxPeers != 20 {
+		t.Errorf("peer limit overridden: have %d, want 20", r.server.MaxPeers)
+	}
+	// Settings failing to apply must be reported and retried on the next reload
+	writeConfig("[Log]\nVmodule = \"p2p=5\"\n[Node]\nHTTPPort = 9999\n[Eth.TxPool]\nGlobalSlots = 1\n")
+	if _, err = r.reload(); err == nil {
+		t.Errorf("transaction pool limits applied without a pool")
+	}
+	if r.cfg.Eth.TxPool.GlobalSlots == 1 {
+		t.Errorf("failed setting recorded as applied")
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/matrix/go-matrix/cmd/utils"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/p2p"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	replayProtocolsFlag = cli.StringFlag{
+		Name:  "protocols",
+		Usage: "Comma separated protocols to replay (default = all)",
+	}
+
+	replayCommand = cli.Command{
+		Action:    utils.MigrateFlags(replayCapture),
+		Name:      "replay",
+		Usage:     "Replay recorded peer messages into the node's protocol handlers",
+		ArgsUsage: "<capture file or directory>",
+		Category:  "MISCELLANEOUS COMMANDS",
+		Flags: []cli.Flag{
+			configFileFlag,
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			replayProtocolsFlag,
+		},
+		Description: `
+    gman replay capture-01539734400000000000.rlp
+
+feeds the messages recorded with --capture.dir back into the protocol handlers
+of a node running on the local database, one session per recorded peer. The
+node is started with peer discovery disabled. For every session the number of
+delivered messages, the codes of the messages the handler sent and of the ones
+originally sent are printed, marking sessions whose responses diverged.
+
+If a directory is given, all capture files in it are replayed in the order they
+were recorded.`,
+	}
+)
+
+// replayCapture replays a message capture into the handlers of a local node.
+func replayCapture(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	msgs, err := readCapture(ctx.Args().First())
+	if err != nil {
+		if len(msgs) == 0 {
+			utils.Fatalf("Failed to read capture: %v", err)
+		}
+		log.Warn("Capture truncated, replaying the readable messages", "count", len(msgs), "err", err)
+	}
+	wanted := make(map[string]bool)
+	if ctx.IsSet(replayProtocolsFlag.Name) {
+		for _, name := range strings.Split(ctx.String(replayProtocolsFlag.Name), ",") {
+			wanted[strings.TrimSpace(name)] = true
+		}
+	}
+	// Run the handlers against the local chain, without looking for real peers
+	ctx.GlobalSet(utils.NoDiscoverFlag.Name, "true")
+	ctx.GlobalSet(utils.ListenPortFlag.Name, "0")
+
+	stack := makeFullNode(ctx)
+	if err := stack.Start(); err != nil {
+		utils.Fatalf("Error starting protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	var sessions, diverged int
+	for _, proto := range stack.Server().Protocols {
+		if len(wanted) > 0 && !wanted[proto.Name] {
+			continue
+		}
+		for _, res := range p2p.Replay(proto, msgs) {
+			sessions++
+			status := "ok"
+			if res.Diverged() {
+				status = "diverged"
+				diverged++
+			}
+			fmt.Printf("%s/%d peer %x: %s, delivered %d, sent %v, recorded %v", proto.Name, proto.Version, res.Peer[:8], status, res.Delivered, res.Sent, res.Recorded)
+			if res.Err != nil {
+				fmt.Printf(", error: %v", res.Err)
+			}
+			fmt.Println()
+		}
+	}
+	fmt.Printf("Replayed %d messages in %d sessions, %d diverged\n", len(msgs), sessions, diverged)
+	return nil
+}
+
+// readCapture decodes the messages of a capture file, or of all capture files
+// in a directory.
+func readCapture(path string) ([]*p2p.CapturedMsg, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return p2p.ReadCaptureDir(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return p2p.ReadCapture(f)
+}
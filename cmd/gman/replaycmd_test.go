@@ -0,0 +1,90 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/man"
+	"github.com/matrix/go-matrix/p2p"
+	"github.com/matrix/go-matrix/rlp"
+)
+
+// replayStatus mirrors the man protocol handshake message.
+type replayStatus struct {
+	ProtocolVersion uint32
+	NetworkId       uint64
+	TD              *big.Int
+	CurrentBlock    common.Hash
+	GenesisBlock    common.Hash
+}
+
+func TestReplayCapture(t *testing.T) {
+	dir := tmpdir(t)
+	defer os.RemoveAll(dir)
+
+	// Record the handshake of a peer on the default network
+	capture, err := p2p.NewMsgCapture(filepath.Join(dir, "capture"), nil, 1024*1024, 1)
+	if err != nil {
+		t.Fatalf("failed to create capture: %v", err)
+	}
+	status, err := rlp.EncodeToBytes(&replayStatus{
+		ProtocolVersion: 63,
+		NetworkId:       man.DefaultConfig.NetworkId,
+		TD:              new(big.Int),
+		GenesisBlock:    core.DefaultGenesisBlock().ToBlock(nil).Hash(),
+	})
+	if err != nil {
+		t.Fatalf("failed to encode status: %v", err)
+	}
+	peer := common.HexToHash("0x0102030405060708")
+	session := []struct {
+		inbound bool
+		code    uint64
+	}{
+		{false, 0}, // local status
+		{true, 0},  // remote status
+		{false, 3}, // DAO fork header challenge
+	}
+	for _, msg := range session {
+		capture.Record(&p2p.CapturedMsg{
+			Time:     uint64(time.Now().UnixNano()),
+			Peer:     peer,
+			Protocol: "man",
+			Version:  63,
+			Inbound:  msg.inbound,
+			Code:     msg.code,
+			Payload:  status,
+		})
+	}
+	if err := capture.Close(); err != nil {
+		t.Fatalf("failed to close capture: %v", err)
+	}
+	// Replay it into a fresh node, the handler must answer with its own status
+	// and challenge the peer for the DAO fork header again
+	gman := runGeth(t, "replay", "--datadir", filepath.Join(dir, "node"), "--protocols", "man", filepath.Join(dir, "capture"))
+	defer gman.ExpectExit()
+	gman.ExpectRegexp(`man/63 peer 0{16}: ok, delivered 1, sent \[0 3\], recorded \[0 3\]
+Replayed 3 messages in 1 sessions, 0 diverged
+`)
+}
@@ -69,6 +69,7 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			configFileFlag,
 			utils.DataDirFlag,
+			utils.ChainDataDirFlag,
 			utils.KeyStoreDirFlag,
 			utils.NoUSBFlag,
 			utils.NetworkIdFlag,
@@ -76,11 +77,23 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.RinkebyFlag,
 			utils.SyncModeFlag,
 			utils.GCModeFlag,
+			utils.StateAccessIndexFlag,
+			utils.TraceIndexFlag,
+			utils.FutureBlockDriftFlag,
+			utils.PinHeadFlag,
+			utils.ForkMonitorFlag,
+			utils.ForkMonitorDepthFlag,
+			utils.ForkMonitorWebhookFlag,
+			utils.BlobsFlag,
+			utils.BlobsStoreFlag,
+			utils.BlobsHorizonFlag,
 			utils.EthStatsURLFlag,
 			utils.IdentityFlag,
 			utils.LightServFlag,
 			utils.LightPeersFlag,
 			utils.LightKDFFlag,
+			utils.RemoteStateFlag,
+			utils.RemoteStateCacheFlag,
 		},
 	},
 	{Name: "DEVELOPER CHAIN",
@@ -123,6 +136,61 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TxPoolAccountQueueFlag,
 			utils.TxPoolGlobalQueueFlag,
 			utils.TxPoolLifetimeFlag,
+			utils.TxPoolPendingLifetimeFlag,
+			utils.TxPoolRejectUnprotectedFlag,
+			utils.TxPoolUnprotectedGraceFlag,
+			utils.TxPoolSpamGuardFlag,
+			utils.TxPoolSpamShareFlag,
+			utils.TxPoolSpamWindowFlag,
+			utils.TxPoolSpamAllowFlag,
+		},
+	},
+	{
+		Name: "FAUCET",
+		Flags: []cli.Flag{
+			utils.FaucetEnabledFlag,
+			utils.FaucetAddrFlag,
+			utils.FaucetPortFlag,
+			utils.FaucetAccountFlag,
+			utils.FaucetAmountFlag,
+			utils.FaucetAddressCooldownFlag,
+			utils.FaucetIPCooldownFlag,
+			utils.FaucetCaptchaURLFlag,
+			utils.FaucetCaptchaSecretFlag,
+			utils.FaucetTrustedProxiesFlag,
+		},
+	},
+	{
+		Name: "ACCOUNT ABSTRACTION (EXPERIMENTAL)",
+		Flags: []cli.Flag{
+			utils.UserOpEntryPointFlag,
+			utils.UserOpMaxOpsFlag,
+			utils.UserOpLifetimeFlag,
+			utils.UserOpValidationGasFlag,
+			utils.UserOpBundleSizeFlag,
+			utils.UserOpBundleGasFlag,
+		},
+	},
+	{
+		Name: "WATCHDOG",
+		Flags: []cli.Flag{
+			utils.WatchdogEnabledFlag,
+			utils.WatchdogTimeoutFlag,
+			utils.WatchdogActionFlag,
+		},
+	},
+	{
+		Name: "SERVICE SUPERVISION",
+		Flags: []cli.Flag{
+			utils.ServiceSuperviseFlag,
+			utils.ServiceMaxBackoffFlag,
+			utils.ServiceRestartLimitFlag,
+		},
+	},
+	{
+		Name: "PLUGINS",
+		Flags: []cli.Flag{
+			utils.PluginsFlag,
 		},
 	},
 	{
@@ -147,16 +215,27 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.RPCEnabledFlag,
 			utils.RPCListenAddrFlag,
 			utils.RPCPortFlag,
+			utils.RPCExtraListenAddrsFlag,
 			utils.RPCApiFlag,
 			utils.WSEnabledFlag,
 			utils.WSListenAddrFlag,
 			utils.WSPortFlag,
+			utils.WSExtraListenAddrsFlag,
 			utils.WSApiFlag,
 			utils.WSAllowedOriginsFlag,
 			utils.IPCDisabledFlag,
 			utils.IPCPathFlag,
+			utils.IPCModeFlag,
+			utils.IPCGroupFlag,
 			utils.RPCCORSDomainFlag,
 			utils.RPCVirtualHostsFlag,
+			utils.RPCCacheSizeFlag,
+			utils.RPCAttestFlag,
+			utils.RPCAPIKeysFlag,
+			utils.AdminOperatorsFlag,
+			utils.AdminThresholdFlag,
+			utils.AdminMethodsFlag,
+			utils.AdminAuditFlag,
 			utils.JSpathFlag,
 			utils.ExecFlag,
 			utils.PreloadJSFlag,
@@ -169,14 +248,18 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.BootnodesV4Flag,
 			utils.BootnodesV5Flag,
 			utils.ListenPortFlag,
+			utils.ExtraListenAddrsFlag,
 			utils.MaxPeersFlag,
 			utils.MaxPendingPeersFlag,
 			utils.NATFlag,
 			utils.NoDiscoverFlag,
 			utils.DiscoveryV5Flag,
 			utils.NetrestrictFlag,
+			utils.GeoIPFlag,
 			utils.NodeKeyFileFlag,
 			utils.NodeKeyHexFlag,
+			utils.CaptureDirFlag,
+			utils.CaptureProtocolsFlag,
 		},
 	},
 	{
@@ -188,6 +271,10 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TargetGasLimitFlag,
 			utils.GasPriceFlag,
 			utils.ExtraDataFlag,
+			utils.PropagationModeFlag,
+			utils.PropagationMinFullFlag,
+			utils.PropagationValidatorsFlag,
+			utils.PropagationPriorityFlag,
 			utils.TestLocalMiningFlag,
 			utils.TestHeaderGenFlag,
 			utils.TestChangeRoleFlag,
@@ -204,6 +291,8 @@ var AppHelpFlagGroups = []flagGroup{
 		Name: "VIRTUAL MACHINE",
 		Flags: []cli.Flag{
 			utils.VMEnableDebugFlag,
+			utils.VMParallelTxsFlag,
+			utils.VMKZGSetupFlag,
 		},
 	},
 	{
@@ -35,6 +35,8 @@ import (
 	"github.com/naoina/toml"
 
 	bzzapi "github.com/matrix/go-matrix/swarm/api"
+	"github.com/matrix/go-matrix/swarm/network"
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
 )
 
 var (
@@ -63,6 +65,7 @@ const (
 	SWARM_ENV_LISTEN_ADDR     = "SWARM_LISTEN_ADDR"
 	SWARM_ENV_PORT            = "SWARM_PORT"
 	SWARM_ENV_NETWORK_ID      = "SWARM_NETWORK_ID"
+	SWARM_ENV_DATADIR         = "SWARM_DATADIR"
 	SWARM_ENV_SWAP_ENABLE     = "SWARM_SWAP_ENABLE"
 	SWARM_ENV_SWAP_API        = "SWARM_SWAP_API"
 	SWARM_ENV_SYNC_ENABLE     = "SWARM_SYNC_ENABLE"
@@ -70,6 +73,12 @@ const (
 	SWARM_ENV_ENS_ADDR        = "SWARM_ENS_ADDR"
 	SWARM_ENV_CORS            = "SWARM_CORS"
 	SWARM_ENV_BOOTNODES       = "SWARM_BOOTNODES"
+	SWARM_ENV_RECEIPTS        = "SWARM_DELIVERY_RECEIPTS"
+	SWARM_ENV_VIRTUAL_NODES   = "SWARM_VIRTUAL_NODES"
+	SWARM_ENV_GATEWAY_QUOTAS  = "SWARM_GATEWAY_QUOTAS"
+	SWARM_ENV_NAMESPACED_ADDR = "SWARM_NAMESPACED_ADDR"
+	SWARM_ENV_INTEGRITY       = "SWARM_INTEGRITY_SAMPLES"
+	SWARM_ENV_ID_DIFFICULTY   = "SWARM_ID_DIFFICULTY"
 	GETH_ENV_DATADIR          = "GETH_DATADIR"
 )
 
@@ -178,6 +187,10 @@ func cmdLineOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Con
 		}
 	}
 
+	if chunkdir := ctx.GlobalString(SwarmDataDirFlag.Name); chunkdir != "" {
+		currentConfig.ChunkDir = chunkdir
+	}
+
 	bzzport := ctx.GlobalString(SwarmPortFlag.Name)
 	if len(bzzport) > 0 {
 		currentConfig.Port = bzzport
@@ -195,6 +208,30 @@ func cmdLineOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Con
 		currentConfig.SyncEnabled = true
 	}
 
+	if ctx.GlobalIsSet(SwarmReceiptsFlag.Name) {
+		currentConfig.DeliveryReceipts = true
+	}
+
+	if ctx.GlobalIsSet(SwarmVirtualNodesFlag.Name) {
+		currentConfig.VirtualNodes = ctx.GlobalInt(SwarmVirtualNodesFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmGatewayQuotasFlag.Name) {
+		currentConfig.GatewayQuotas = true
+	}
+
+	if ctx.GlobalIsSet(SwarmNamespacedAddrFlag.Name) {
+		currentConfig.NamespacedAddr = true
+	}
+
+	if ctx.GlobalIsSet(SwarmIntegritySamplesFlag.Name) {
+		currentConfig.IntegritySamples = ctx.GlobalInt(SwarmIntegritySamplesFlag.Name)
+	}
+
+	if ctx.GlobalIsSet(SwarmIDDifficultyFlag.Name) {
+		currentConfig.HiveParams.IDDifficulty = ctx.GlobalInt(SwarmIDDifficultyFlag.Name)
+	}
+
 	currentConfig.SwapApi = ctx.GlobalString(SwarmSwapAPIFlag.Name)
 	if currentConfig.SwapEnabled && currentConfig.SwapApi == "" {
 		utils.Fatalf(SWARM_ERR_SWAP_SET_NO_API)
@@ -247,6 +284,10 @@ func envVarsOverride(currentConfig *bzzapi.Config) (config *bzzapi.Config) {
 		currentConfig.Path = datadir
 	}
 
+	if chunkdir := os.Getenv(SWARM_ENV_DATADIR); chunkdir != "" {
+		currentConfig.ChunkDir = chunkdir
+	}
+
 	bzzport := os.Getenv(SWARM_ENV_PORT)
 	if len(bzzport) > 0 {
 		currentConfig.Port = bzzport
@@ -268,6 +309,42 @@ func envVarsOverride(currentConfig *bzzapi.Config) (config *bzzapi.Config) {
 		}
 	}
 
+	if receipts := os.Getenv(SWARM_ENV_RECEIPTS); receipts != "" {
+		if enabled, err := strconv.ParseBool(receipts); err == nil {
+			currentConfig.DeliveryReceipts = enabled
+		}
+	}
+
+	if virtual := os.Getenv(SWARM_ENV_VIRTUAL_NODES); virtual != "" {
+		if count, err := strconv.Atoi(virtual); err == nil {
+			currentConfig.VirtualNodes = count
+		}
+	}
+
+	if quotas := os.Getenv(SWARM_ENV_GATEWAY_QUOTAS); quotas != "" {
+		if enabled, err := strconv.ParseBool(quotas); err == nil {
+			currentConfig.GatewayQuotas = enabled
+		}
+	}
+
+	if namespaced := os.Getenv(SWARM_ENV_NAMESPACED_ADDR); namespaced != "" {
+		if enabled, err := strconv.ParseBool(namespaced); err == nil {
+			currentConfig.NamespacedAddr = enabled
+		}
+	}
+
+	if samples := os.Getenv(SWARM_ENV_INTEGRITY); samples != "" {
+		if count, err := strconv.Atoi(samples); err == nil {
+			currentConfig.IntegritySamples = count
+		}
+	}
+
+	if difficulty := os.Getenv(SWARM_ENV_ID_DIFFICULTY); difficulty != "" {
+		if bits, err := strconv.Atoi(difficulty); err == nil {
+			currentConfig.HiveParams.IDDifficulty = bits
+		}
+	}
+
 	if swapapi := os.Getenv(SWARM_ENV_SWAP_API); swapapi != "" {
 		currentConfig.SwapApi = swapapi
 	}
@@ -326,6 +403,11 @@ func checkDeprecated(ctx *cli.Context) {
 
 //validate configuration parameters
 func validateConfig(cfg *bzzapi.Config) (err error) {
+	if cfg.ChunkDir != "" {
+		if err := node.CheckDir(cfg.ChunkDir); err != nil {
+			return fmt.Errorf("invalid swarm data directory %q: %v", cfg.ChunkDir, err)
+		}
+	}
 	for _, ensAPI := range cfg.EnsAPIs {
 		if ensAPI != "" {
 			if err := validateEnsAPIs(ensAPI); err != nil {
@@ -333,6 +415,23 @@ func validateConfig(cfg *bzzapi.Config) (err error) {
 			}
 		}
 	}
+	if cfg.VirtualNodes < 0 || cfg.VirtualNodes > network.MaxVirtualNodes {
+		return fmt.Errorf("invalid number of virtual nodes %d (max %d)", cfg.VirtualNodes, network.MaxVirtualNodes)
+	}
+	if cfg.HiveParams != nil && cfg.HiveParams.KadParams != nil {
+		if _, err := kademlia.NewEvictionPolicy(cfg.HiveParams.Eviction); err != nil {
+			return err
+		}
+		if cfg.HiveParams.IDDifficulty < 0 || cfg.HiveParams.IDDifficulty > network.MaxIDDifficulty {
+			return fmt.Errorf("invalid node id difficulty %d (max %d)", cfg.HiveParams.IDDifficulty, network.MaxIDDifficulty)
+		}
+		if cfg.HiveParams.LatencyWeight < 0 || cfg.HiveParams.LatencyWeight > 1 {
+			return fmt.Errorf("invalid latency weight %v (want 0..1)", cfg.HiveParams.LatencyWeight)
+		}
+		if cfg.HiveParams.DialRate < 0 || cfg.HiveParams.DialBurst < 0 || cfg.HiveParams.DialBackoff < 0 || cfg.HiveParams.MaxDialBackoff < 0 {
+			return errors.New("invalid dial limits: negative rate, burst or backoff")
+		}
+	}
 	return nil
 }
 
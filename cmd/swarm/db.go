@@ -24,6 +24,7 @@ import (
 
 	"github.com/matrix/go-matrix/cmd/utils"
 	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/node"
 	"github.com/matrix/go-matrix/swarm/storage"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -107,6 +108,25 @@ func dbClean(ctx *cli.Context) {
 	store.Cleanup()
 }
 
+func dbMove(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 2 {
+		utils.Fatalf("invalid arguments, please specify both <chunkdb> (path to a local chunk database) and <dir> (path to move it to)")
+	}
+
+	store, err := openDbStore(args[0])
+	if err != nil {
+		utils.Fatalf("error opening local chunk database: %s", err)
+	}
+	store.Close()
+
+	if err := node.MoveDir(args[0], args[1]); err != nil {
+		utils.Fatalf("error moving local chunk database: %s", err)
+	}
+
+	log.Info(fmt.Sprintf("successfully moved chunk database to %s", args[1]))
+}
+
 func openDbStore(path string) (*storage.DbStore, error) {
 	if _, err := os.Stat(filepath.Join(path, "CURRENT")); err != nil {
 		return nil, fmt.Errorf("invalid chunkdb path: %s", err)
@@ -0,0 +1,53 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/matrix/go-matrix/cmd/utils"
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/swarm/network"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// genkey mines a node key solving the node id puzzle of --id-difficulty in the
+// network and saves it as a hex key file usable as --bzzaccount
+func genkey(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 1 {
+		utils.Fatalf("Usage: swarm genkey <keyfile>")
+	}
+	difficulty := ctx.GlobalInt(SwarmIDDifficultyFlag.Name)
+	networkId := uint64(network.NetworkId)
+	if id := ctx.GlobalInt(SwarmNetworkIdFlag.Name); id != 0 {
+		networkId = uint64(id)
+	}
+	namespaced := ctx.GlobalBool(SwarmNamespacedAddrFlag.Name)
+
+	key, err := network.GenerateSecureKey(difficulty, networkId, namespaced)
+	if err != nil {
+		utils.Fatalf("Error generating node key: %v", err)
+	}
+	if err := crypto.SaveECDSA(args[0], key); err != nil {
+		utils.Fatalf("Error saving node key: %v", err)
+	}
+	addr := network.OverlayAddr(crypto.FromECDSAPub(&key.PublicKey), networkId, namespaced)
+	fmt.Printf("Account: %x\n", crypto.PubkeyToAddress(key.PublicKey))
+	fmt.Printf("Overlay address: %v\n", common.Hash(addr).Hex())
+}
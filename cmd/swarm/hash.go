@@ -20,8 +20,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/matrix/go-matrix/cmd/utils"
+	"github.com/matrix/go-matrix/swarm/api"
 	"github.com/matrix/go-matrix/swarm/storage"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -33,13 +35,29 @@ func hash(ctx *cli.Context) {
 	}
 	f, err := os.Open(args[0])
 	if err != nil {
-		utils.Fatalf("Error opening file " + args[1])
+		utils.Fatalf("Error opening file " + args[0])
 	}
 	defer f.Close()
 
 	stat, _ := f.Stat()
-	chunker := storage.NewTreeChunker(storage.NewChunkerParams())
-	key, err := chunker.Split(f, stat.Size(), nil, nil, nil)
+	dpa := storage.NewDryRunDPA(storage.NewTreeChunker(storage.NewChunkerParams()))
+	if stat.IsDir() {
+		// directories are hashed as the manifest an upload would create
+		var index string
+		if defaultPath := ctx.GlobalString(SwarmUploadDefaultPath.Name); defaultPath != "" {
+			if index, err = filepath.Rel(args[0], defaultPath); err != nil {
+				utils.Fatalf("Invalid default path %s: %v", defaultPath, err)
+			}
+			index = filepath.ToSlash(index)
+		}
+		hash, err := api.NewFileSystem(api.NewApi(dpa, nil)).Hash(args[0], index)
+		if err != nil {
+			utils.Fatalf("%v\n", err)
+		}
+		fmt.Printf("%v\n", hash)
+		return
+	}
+	key, err := dpa.Hash(f, stat.Size())
 	if err != nil {
 		utils.Fatalf("%v\n", err)
 	} else {
@@ -52,6 +52,7 @@ const clientIdentifier = "swarm"
 
 var (
 	gitCommit        string // Git SHA1 commit hash of the release (set via linker flags)
+	gitDate          string // Git commit date of the release (set via linker flags)
 	testbetBootNodes = []string{
 		"enode://ec8ae764f7cb0417bdfb009b9d0f18ab3818a3a4e8e7c67dd5f18971a93510a2e6f43cd0b69a27e439a9629457ea804104f37c85e41eed057d3faabbf7744cdf@13.74.157.139:30429",
 		"enode://c2e1fceb3bf3be19dff71eec6cccf19f2dbf7567ee017d130240c670be8594bc9163353ca55dd8df7a4f161dd94b36d0615c17418b5a3cdcbb4e9d99dfa4de37@13.74.157.139:30430",
@@ -87,6 +88,11 @@ var (
 		Usage:  "Network identifier (integer, default 3=swarm testnet)",
 		EnvVar: SWARM_ENV_NETWORK_ID,
 	}
+	SwarmDataDirFlag = cli.StringFlag{
+		Name:   "bzz.datadir",
+		Usage:  "Directory for the swarm chunk store (default = inside the datadir)",
+		EnvVar: SWARM_ENV_DATADIR,
+	}
 	SwarmConfigPathFlag = cli.StringFlag{
 		Name:  "bzzconfig",
 		Usage: "DEPRECATED: please use --config path/to/TOML-file",
@@ -106,6 +112,36 @@ var (
 		Usage:  "Swarm Syncing enabled (default true)",
 		EnvVar: SWARM_ENV_SYNC_ENABLE,
 	}
+	SwarmReceiptsFlag = cli.BoolFlag{
+		Name:   "receipts",
+		Usage:  "Attach signed delivery receipts to the chunks served to retrieve requests",
+		EnvVar: SWARM_ENV_RECEIPTS,
+	}
+	SwarmVirtualNodesFlag = cli.IntFlag{
+		Name:   "virtualnodes",
+		Usage:  "Number of virtual nodes with their own overlay address to run in addition to the node's own (max 15)",
+		EnvVar: SWARM_ENV_VIRTUAL_NODES,
+	}
+	SwarmGatewayQuotasFlag = cli.BoolFlag{
+		Name:   "gateway-quotas",
+		Usage:  "Require API keys on the http gateway and enforce their upload, download and chunk quotas",
+		EnvVar: SWARM_ENV_GATEWAY_QUOTAS,
+	}
+	SwarmNamespacedAddrFlag = cli.BoolFlag{
+		Name:   "namespaced-addr",
+		Usage:  "Mix the network id into the overlay address and only accept peers addressed the same way",
+		EnvVar: SWARM_ENV_NAMESPACED_ADDR,
+	}
+	SwarmIntegritySamplesFlag = cli.IntFlag{
+		Name:   "integrity-samples",
+		Usage:  "Number of locally stored chunks re-hashed every hour to detect disk corruption (0 disables the checks)",
+		EnvVar: SWARM_ENV_INTEGRITY,
+	}
+	SwarmIDDifficultyFlag = cli.IntFlag{
+		Name:   "id-difficulty",
+		Usage:  "Leading zero bits required of the hashed overlay address of peers and the own node key (0 disables the node id puzzle)",
+		EnvVar: SWARM_ENV_ID_DIFFICULTY,
+	}
 	EnsAPIFlag = cli.StringSliceFlag{
 		Name:   "ens-api",
 		Usage:  "ENS API endpoint for a TLD and with contract address, can be repeated, format [tld:][contract-addr@]url",
@@ -164,7 +200,7 @@ var defaultNodeConfig = node.DefaultConfig
 // This init function sets defaults so cmd/swarm can run alongside gman.
 func init() {
 	defaultNodeConfig.Name = clientIdentifier
-	defaultNodeConfig.Version = params.VersionWithCommit(gitCommit)
+	defaultNodeConfig.Version = params.VersionWithCommit(gitCommit, gitDate)
 	defaultNodeConfig.P2P.ListenAddr = ":30399"
 	defaultNodeConfig.IPCPath = "bzzd.ipc"
 	// Set flag defaults for --help display.
@@ -212,7 +248,24 @@ Lists files and directories contained in a manifest.
 			Usage:     "print the swarm hash of a file or directory",
 			ArgsUsage: " <file>",
 			Description: `
-Prints the swarm hash of file or directory.
+Prints the swarm hash of file or directory without storing any content.
+A directory is hashed as the manifest uploading it via the bzz_upload API
+creates, with --defaultpath naming the file served for the empty path.
+`,
+		},
+		{
+			Action:    genkey,
+			Name:      "genkey",
+			Usage:     "generate a node key solving the node id puzzle",
+			ArgsUsage: "<keyfile>",
+			Description: `
+Generates a node key whose overlay address solves the node id puzzle of
+--id-difficulty in the network selected by --bzznetworkid and --namespaced-addr,
+and saves it as a hex key file to be passed as --bzzaccount.
+
+    swarm --id-difficulty 16 genkey bzz.key
+
+Mining takes 2^difficulty key generations on average.
 `,
 		},
 		{
@@ -290,6 +343,19 @@ The import may be quite large, consider piping the input through the Unix
 pv(1) tool to get a progress bar:
 
     pv chunks.tar | swarm db import ~/.matrix/swarm/bzz-KEY/chunks -
+`,
+				},
+				{
+					Action:    dbMove,
+					Name:      "move",
+					Usage:     "move a local chunk database into another directory",
+					ArgsUsage: "<chunkdb> <dir>",
+					Description: `
+Move a local chunk database into another, empty directory, for example onto a
+separate disk to be used together with --bzz.datadir. The node must not be
+running while the database is moved.
+
+    swarm db move ~/.matrix/swarm/bzz-KEY/chunks /mnt/bulk/swarm/bzz-KEY/chunks
 `,
 				},
 				{
@@ -334,6 +400,8 @@ DEPRECATED: use 'swarm db clean'.
 		utils.NATFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
+		utils.IPCModeFlag,
+		utils.IPCGroupFlag,
 		utils.PasswordFileFlag,
 		// bzzd-specific flags
 		CorsStringFlag,
@@ -343,10 +411,17 @@ DEPRECATED: use 'swarm db clean'.
 		SwarmSwapEnabledFlag,
 		SwarmSwapAPIFlag,
 		SwarmSyncEnabledFlag,
+		SwarmReceiptsFlag,
+		SwarmVirtualNodesFlag,
+		SwarmGatewayQuotasFlag,
+		SwarmNamespacedAddrFlag,
+		SwarmIntegritySamplesFlag,
+		SwarmIDDifficultyFlag,
 		SwarmListenAddrFlag,
 		SwarmPortFlag,
 		SwarmAccountFlag,
 		SwarmNetworkIdFlag,
+		SwarmDataDirFlag,
 		ChequebookAddrFlag,
 		// upload flags
 		SwarmApiFlag,
@@ -27,22 +27,29 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/matrix/go-matrix/accounts"
 	"github.com/matrix/go-matrix/accounts/keystore"
 	"github.com/matrix/go-matrix/common"
 	"github.com/matrix/go-matrix/common/fdlimit"
+	"github.com/matrix/go-matrix/common/hexutil"
 	"github.com/matrix/go-matrix/consensus"
 	"github.com/matrix/go-matrix/consensus/clique"
 	"github.com/matrix/go-matrix/consensus/manash"
 	"github.com/matrix/go-matrix/core"
 	"github.com/matrix/go-matrix/core/state"
+	"github.com/matrix/go-matrix/core/state/export"
 	"github.com/matrix/go-matrix/core/vm"
 	"github.com/matrix/go-matrix/crypto"
 	"github.com/matrix/go-matrix/dashboard"
+	"github.com/matrix/go-matrix/faucet"
 	"github.com/matrix/go-matrix/man"
 	"github.com/matrix/go-matrix/man/downloader"
+	"github.com/matrix/go-matrix/man/blobs"
+	"github.com/matrix/go-matrix/man/forkmon"
 	"github.com/matrix/go-matrix/man/gasprice"
+	"github.com/matrix/go-matrix/man/remotestate"
 	"github.com/matrix/go-matrix/mandb"
 	"github.com/matrix/go-matrix/manstats"
 	"github.com/matrix/go-matrix/les"
@@ -55,6 +62,8 @@ import (
 	"github.com/matrix/go-matrix/p2p/nat"
 	"github.com/matrix/go-matrix/p2p/netutil"
 	"github.com/matrix/go-matrix/params"
+	"github.com/matrix/go-matrix/userop"
+	"github.com/matrix/go-matrix/watchdog"
 	whisper "github.com/matrix/go-matrix/whisper/whisperv6"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -122,6 +131,10 @@ var (
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
 	}
+	ChainDataDirFlag = DirectoryFlag{
+		Name:  "datadir.chaindata",
+		Usage: "Directory for the chain databases, moved there from the datadir on first start (default = inside the datadir)",
+	}
 	NoUSBFlag = cli.BoolFlag{
 		Name:  "nousb",
 		Usage: "Disables monitoring for and managing USB hardware wallets",
@@ -164,6 +177,28 @@ var (
 		Name:  "light",
 		Usage: "Enable light client mode (replaced by --syncmode)",
 	}
+	FromSnapshotFlag = cli.StringFlag{
+		Name:  "from-snapshot",
+		Usage: "URL or path of a signed database snapshot manifest to initialize from",
+	}
+	SnapshotSignersFlag = cli.StringFlag{
+		Name:  "snapshot.signers",
+		Usage: "Comma separated list of addresses trusted to sign snapshot manifests",
+	}
+	SnapshotThresholdFlag = cli.IntFlag{
+		Name:  "snapshot.threshold",
+		Usage: "Number of distinct trusted signatures a snapshot manifest needs",
+		Value: 1,
+	}
+	SnapshotHashFlag = cli.StringFlag{
+		Name:  "snapshot.hash",
+		Usage: "Head block hash the snapshot must have (any signed snapshot if unset)",
+	}
+	ExportChunkSizeFlag = cli.IntFlag{
+		Name:  "export.chunksize",
+		Usage: "Number of accounts and storage slots per chunk of a state export",
+		Value: export.DefaultChunkSize,
+	}
 	defaultSyncMode = man.DefaultConfig.SyncMode
 	SyncModeFlag    = TextMarshalerFlag{
 		Name:  "syncmode",
@@ -175,6 +210,51 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	FutureBlockDriftFlag = cli.DurationFlag{
+		Name:  "futuredrift",
+		Usage: "Maximum time blocks may be ahead of the local clock before being rejected",
+		Value: 30 * time.Second,
+	}
+	PinHeadFlag = cli.StringFlag{
+		Name:  "pin.head",
+		Usage: "Only accept chains containing the block, refusing peers on other branches (<hash>:<number>)",
+	}
+	ForkMonitorFlag = cli.BoolFlag{
+		Name:  "forkmon",
+		Usage: "Enable the monitor alerting when the local chain splits off from the majority of peers",
+	}
+	ForkMonitorDepthFlag = cli.Uint64Flag{
+		Name:  "forkmon.depth",
+		Usage: "Number of blocks the local head may diverge from the majority branch before alerting",
+		Value: forkmon.DefaultConfig.Depth,
+	}
+	ForkMonitorWebhookFlag = cli.StringFlag{
+		Name:  "forkmon.webhook",
+		Usage: "URL fork alerts are posted to as JSON",
+	}
+	BlobsFlag = cli.BoolFlag{
+		Name:  "blobs",
+		Usage: "Enable gossiping and storing data blobs referenced by transactions",
+	}
+	BlobsStoreFlag = cli.Uint64Flag{
+		Name:  "blobs.store",
+		Usage: "Megabytes of memory allocated to stored blobs",
+		Value: blobs.DefaultConfig.StoreSize / 1024 / 1024,
+	}
+	BlobsHorizonFlag = cli.Uint64Flag{
+		Name:  "blobs.horizon",
+		Usage: "Number of blocks blobs are kept for after being received",
+		Value: blobs.DefaultConfig.Horizon,
+	}
+	RemoteStateFlag = cli.StringFlag{
+		Name:  "remotestate",
+		Usage: "Comma separated RPC endpoints of full nodes light mode state is fetched from and verified",
+	}
+	RemoteStateCacheFlag = cli.IntFlag{
+		Name:  "remotestate.cache",
+		Usage: "Number of verified accounts, storage slots and codes cached in light mode",
+		Value: remotestate.DefaultConfig.CacheSize,
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -209,6 +289,119 @@ var (
 		Usage: "Dashboard metrics collection refresh rate",
 		Value: dashboard.DefaultConfig.Refresh,
 	}
+	// Faucet settings
+	FaucetEnabledFlag = cli.BoolFlag{
+		Name:  "faucet",
+		Usage: "Enable the built-in faucet service",
+	}
+	FaucetAddrFlag = cli.StringFlag{
+		Name:  "faucet.addr",
+		Usage: "Faucet HTTP server listening interface",
+		Value: faucet.DefaultConfig.Host,
+	}
+	FaucetPortFlag = cli.IntFlag{
+		Name:  "faucet.port",
+		Usage: "Faucet HTTP server listening port",
+		Value: faucet.DefaultConfig.Port,
+	}
+	FaucetAccountFlag = cli.StringFlag{
+		Name:  "faucet.account",
+		Usage: "Unlocked account the faucet pays out from",
+	}
+	FaucetAmountFlag = BigFlag{
+		Name:  "faucet.amount",
+		Usage: "Amount of wei sent per faucet request",
+		Value: faucet.DefaultConfig.Amount,
+	}
+	FaucetAddressCooldownFlag = cli.DurationFlag{
+		Name:  "faucet.cooldown.address",
+		Usage: "Minimum time between two payouts to the same address",
+		Value: faucet.DefaultConfig.AddressCooldown,
+	}
+	FaucetIPCooldownFlag = cli.DurationFlag{
+		Name:  "faucet.cooldown.ip",
+		Usage: "Minimum time between two payouts requested from the same IP",
+		Value: faucet.DefaultConfig.IPCooldown,
+	}
+	FaucetCaptchaURLFlag = cli.StringFlag{
+		Name:  "faucet.captcha.url",
+		Usage: "reCAPTCHA compatible verification endpoint (captcha disabled if empty)",
+	}
+	FaucetCaptchaSecretFlag = cli.StringFlag{
+		Name:  "faucet.captcha.secret",
+		Usage: "Server side secret of the captcha service",
+	}
+	FaucetTrustedProxiesFlag = cli.StringFlag{
+		Name:  "faucet.trustedproxies",
+		Usage: "Comma separated reverse proxy IPs or CIDR ranges whose X-Forwarded-For header is trusted",
+	}
+	// Account abstraction settings
+	UserOpEntryPointFlag = cli.StringFlag{
+		Name:  "aa.entrypoint",
+		Usage: "Entrypoint contract enabling the experimental user operation pool",
+	}
+	UserOpMaxOpsFlag = cli.IntFlag{
+		Name:  "aa.maxops",
+		Usage: "Maximum number of user operations kept in the pool",
+		Value: userop.DefaultConfig.MaxOps,
+	}
+	UserOpLifetimeFlag = cli.DurationFlag{
+		Name:  "aa.lifetime",
+		Usage: "Maximum time user operations are pooled without being bundled",
+		Value: userop.DefaultConfig.Lifetime,
+	}
+	UserOpValidationGasFlag = cli.Uint64Flag{
+		Name:  "aa.validationgas",
+		Usage: "Gas allowance of the entrypoint simulation validating user operations",
+		Value: userop.DefaultConfig.ValidationGas,
+	}
+	UserOpBundleSizeFlag = cli.IntFlag{
+		Name:  "aa.bundlesize",
+		Usage: "Maximum number of user operations per bundle",
+		Value: userop.DefaultConfig.BundleSize,
+	}
+	UserOpBundleGasFlag = cli.Uint64Flag{
+		Name:  "aa.bundlegas",
+		Usage: "Gas limit of the bundle transactions sent by the node",
+		Value: userop.DefaultConfig.BundleGas,
+	}
+	// Watchdog settings
+	WatchdogEnabledFlag = cli.BoolFlag{
+		Name:  "watchdog",
+		Usage: "Enable the watchdog monitoring the heartbeats of the critical node loops",
+	}
+	WatchdogTimeoutFlag = cli.DurationFlag{
+		Name:  "watchdog.timeout",
+		Usage: "Time a monitored loop may go without beating before it is considered stalled",
+		Value: watchdog.DefaultConfig.Timeout,
+	}
+	WatchdogActionFlag = cli.StringFlag{
+		Name:  "watchdog.action",
+		Usage: `Remedy for stalled loops ("dump", "restart" or "exit")`,
+		Value: watchdog.DefaultConfig.Action,
+	}
+	// Service supervision settings
+	ServiceSuperviseFlag = cli.StringFlag{
+		Name:  "services.supervise",
+		Usage: `Comma separated list of services restarted on failure instead of aborting the node (e.g. "manstats.Service,whisperv6.Whisper")`,
+		Value: "",
+	}
+	ServiceMaxBackoffFlag = cli.DurationFlag{
+		Name:  "services.maxbackoff",
+		Usage: "Maximum delay between the restart attempts of a failed service",
+		Value: node.DefaultConfig.ServiceRestartMaxBackoff,
+	}
+	ServiceRestartLimitFlag = cli.IntFlag{
+		Name:  "services.restartlimit",
+		Usage: "Number of restarts after which a failing service is given up on (0 = unlimited)",
+		Value: 0,
+	}
+	// Plugin settings
+	PluginsFlag = cli.StringFlag{
+		Name:  "plugins",
+		Usage: "Comma separated list of Go plugins registering additional protocols and RPC APIs",
+		Value: "",
+	}
 	// Ethash settings
 	EthashCacheDirFlag = DirectoryFlag{
 		Name:  "manash.cachedir",
@@ -289,6 +482,39 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: man.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolPendingLifetimeFlag = cli.DurationFlag{
+		Name:  "txpool.pendinglifetime",
+		Usage: "Maximum amount of time non-local executable transactions are pending (0 = until included)",
+		Value: man.DefaultConfig.TxPool.PendingLifetime,
+	}
+	TxPoolRejectUnprotectedFlag = cli.BoolFlag{
+		Name:  "txpool.rejectunprotected",
+		Usage: "Reject transactions without EIP-155 replay protection in the pool and over RPC",
+	}
+	TxPoolUnprotectedGraceFlag = cli.StringFlag{
+		Name:  "txpool.unprotectedgrace",
+		Usage: "Comma separated hashes of unprotected transactions accepted regardless (e.g. known deployments)",
+		Value: "",
+	}
+	TxPoolSpamGuardFlag = cli.BoolFlag{
+		Name:  "txpool.spamguard",
+		Usage: "Deprioritize contracts exceeding their share of recent block gas",
+	}
+	TxPoolSpamShareFlag = cli.Float64Flag{
+		Name:  "txpool.spamshare",
+		Usage: "Share of the recent block gas a single contract may take before being deprioritized",
+		Value: man.DefaultConfig.TxPool.SpamShare,
+	}
+	TxPoolSpamWindowFlag = cli.DurationFlag{
+		Name:  "txpool.spamwindow",
+		Usage: "Span of block time the gas share of a contract is measured over",
+		Value: man.DefaultConfig.TxPool.SpamWindow,
+	}
+	TxPoolSpamAllowFlag = cli.StringFlag{
+		Name:  "txpool.spamallow",
+		Usage: "Comma separated contract addresses never deprioritized by the spam guard",
+		Value: "",
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -339,6 +565,23 @@ var (
 		Name:  "extradata",
 		Usage: "Block extra data set by the miner (default = client version)",
 	}
+	PropagationModeFlag = cli.StringFlag{
+		Name:  "propagation.mode",
+		Usage: `Propagation of sealed blocks ("sqrt" full blocks to the square root of the peers, "all" or "announce" hashes only)`,
+		Value: man.DefaultPropagationConfig.Mode,
+	}
+	PropagationMinFullFlag = cli.IntFlag{
+		Name:  "propagation.minfull",
+		Usage: "Minimum number of peers sent the full sealed block in sqrt mode",
+	}
+	PropagationValidatorsFlag = cli.BoolFlag{
+		Name:  "propagation.validators",
+		Usage: "Push sealed blocks to the connected validators first",
+	}
+	PropagationPriorityFlag = cli.StringFlag{
+		Name:  "propagation.priority",
+		Usage: "Comma separated node ids of the peers pushed sealed blocks first",
+	}
 	TestLocalMiningFlag = cli.StringFlag{
 		Name:  "testlocalmining",
 		Usage: "print a string",
@@ -367,6 +610,24 @@ var (
 		Name:  "vmdebug",
 		Usage: "Record information useful for VM and contract debugging",
 	}
+	VMParallelTxsFlag = cli.IntFlag{
+		Name:  "vm.parallel",
+		Usage: "Number of block transactions executed speculatively in parallel (0 = serial)",
+		Value: man.DefaultConfig.ParallelTxs,
+	}
+	StateAccessIndexFlag = cli.BoolFlag{
+		Name:  "state.accessindex",
+		Usage: "Index the last block accessing each account and storage slot (state expiry research)",
+	}
+	TraceIndexFlag = cli.BoolFlag{
+		Name:  "trace.index",
+		Usage: "Index the call frames of imported transactions for fast historical traces",
+	}
+	VMKZGSetupFlag = cli.StringFlag{
+		Name:  "vm.kzgsetup",
+		Usage: "Trusted setup file of the KZG commitment precompiles (required once the chain enables them)",
+		Value: "",
+	}
 	// Logging and debug settings
 	EthStatsURLFlag = cli.StringFlag{
 		Name:  "manstats",
@@ -399,6 +660,11 @@ var (
 		Usage: "HTTP-RPC server listening port",
 		Value: node.DefaultHTTPPort,
 	}
+	RPCExtraListenAddrsFlag = cli.StringFlag{
+		Name:  "rpcaddr.extra",
+		Usage: "Comma separated additional HTTP-RPC listening endpoints (host:port), sharing the primary endpoint's settings",
+		Value: "",
+	}
 	RPCCORSDomainFlag = cli.StringFlag{
 		Name:  "rpccorsdomain",
 		Usage: "Comma separated list of domains from which to accept cross origin requests (browser enforced)",
@@ -414,13 +680,58 @@ var (
 		Usage: "API's offered over the HTTP-RPC interface",
 		Value: "",
 	}
+	RPCCacheSizeFlag = cli.IntFlag{
+		Name:  "rpc.cache",
+		Usage: "Number of RPC responses kept by the response cache shared by all endpoints (0 = disabled)",
+		Value: 0,
+	}
+	RPCAttestFlag = cli.StringFlag{
+		Name:  "rpc.attest",
+		Usage: "Comma separated list of RPC methods whose replies are signed with the attestation key (e.g. man_getBlockByHash,man_getTransactionReceipt,man_getProof)",
+		Value: "",
+	}
+	RPCAPIKeysFlag = cli.StringFlag{
+		Name:  "rpc.apikeys",
+		Usage: "JSON file of API keys HTTP and WS clients must present (X-API-Key header or apikey parameter)",
+		Value: "",
+	}
+	AdminOperatorsFlag = cli.StringFlag{
+		Name:  "admin.operators",
+		Usage: "Comma separated operator addresses whose signatures guarded admin methods (debug_setHead, admin_removePeer, admin_rotateNodeKey) require",
+		Value: "",
+	}
+	AdminThresholdFlag = cli.IntFlag{
+		Name:  "admin.threshold",
+		Usage: "Number of operator signatures a request of a guarded admin method requires",
+		Value: 1,
+	}
+	AdminMethodsFlag = cli.StringFlag{
+		Name:  "admin.methods",
+		Usage: "Comma separated list of the admin methods requiring operator approval",
+		Value: strings.Join(node.DefaultAdminGuardedMethods, ","),
+	}
+	AdminAuditFlag = cli.StringFlag{
+		Name:  "admin.audit",
+		Usage: "File the requests of guarded admin methods are logged to (default = inside the datadir)",
+		Value: "",
+	}
 	IPCDisabledFlag = cli.BoolFlag{
 		Name:  "ipcdisable",
 		Usage: "Disable the IPC-RPC server",
 	}
 	IPCPathFlag = DirectoryFlag{
 		Name:  "ipcpath",
-		Usage: "Filename for IPC socket/pipe within the datadir (explicit paths escape it)",
+		Usage: "Filename for IPC socket/pipe within the datadir (explicit paths escape it, '@name' is an abstract socket on Linux)",
+	}
+	IPCModeFlag = cli.StringFlag{
+		Name:  "ipcmode",
+		Usage: "Octal file mode of the IPC socket (e.g. 0660)",
+		Value: "",
+	}
+	IPCGroupFlag = cli.StringFlag{
+		Name:  "ipcgroup",
+		Usage: "Group (name or id) owning the IPC socket",
+		Value: "",
 	}
 	WSEnabledFlag = cli.BoolFlag{
 		Name:  "ws",
@@ -436,6 +747,11 @@ var (
 		Usage: "WS-RPC server listening port",
 		Value: node.DefaultWSPort,
 	}
+	WSExtraListenAddrsFlag = cli.StringFlag{
+		Name:  "wsaddr.extra",
+		Usage: "Comma separated additional WS-RPC listening endpoints (host:port), sharing the primary endpoint's settings",
+		Value: "",
+	}
 	WSApiFlag = cli.StringFlag{
 		Name:  "wsapi",
 		Usage: "API's offered over the WS-RPC interface",
@@ -471,6 +787,20 @@ var (
 		Usage: "Network listening port",
 		Value: 30303,
 	}
+	ExtraListenAddrsFlag = cli.StringFlag{
+		Name:  "listenaddr.extra",
+		Usage: "Comma separated additional network listening addresses (host:port) for incoming peer connections",
+		Value: "",
+	}
+	CaptureDirFlag = DirectoryFlag{
+		Name:  "capture.dir",
+		Usage: "Directory to record peer protocol messages into for offline replay (rotated, debugging only)",
+	}
+	CaptureProtocolsFlag = cli.StringFlag{
+		Name:  "capture.protocols",
+		Usage: "Comma separated protocols to record (default = all)",
+		Value: "",
+	}
 	BootnodesFlag = cli.StringFlag{
 		Name:  "bootnodes",
 		Usage: "Comma separated enode URLs for P2P discovery bootstrap (set v4+v5 instead for light servers)",
@@ -511,6 +841,10 @@ var (
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
 	}
+	GeoIPFlag = cli.StringFlag{
+		Name:  "geoip",
+		Usage: "Comma separated list of MaxMind DB files (country, ASN) locating the connected peers",
+	}
 
 	// ATM the url is left to the user and deployment to
 	JSpathFlag = cli.StringFlag{
@@ -544,6 +878,20 @@ var (
 		Usage: "Minimum POW accepted",
 		Value: whisper.DefaultMinimumPoW,
 	}
+	WhisperMaxFiltersFlag = cli.IntFlag{
+		Name:  "shh.maxfilters",
+		Usage: "Maximum number of message filters per key (0 = unlimited)",
+		Value: whisper.DefaultMaxIdentityFilters,
+	}
+	WhisperPersistFlag = cli.BoolFlag{
+		Name:  "shh.persist",
+		Usage: "Keep the messages of subscribed topics on disk, to be fetched with shh_getMissedMessages after a restart",
+	}
+	WhisperRetentionFlag = cli.IntFlag{
+		Name:  "shh.retention",
+		Usage: "Seconds the persisted messages are kept",
+		Value: whisper.DefaultMessageRetention,
+	}
 )
 
 // MakeDataDir retrieves the currently requested data directory, terminating
@@ -596,6 +944,49 @@ func setNodeUserIdent(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// setServiceSupervision applies the service supervision flags to the config.
+func setServiceSupervision(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(ServiceSuperviseFlag.Name) {
+		cfg.SupervisedServices = splitAndTrim(ctx.GlobalString(ServiceSuperviseFlag.Name))
+	}
+	if ctx.GlobalIsSet(ServiceMaxBackoffFlag.Name) {
+		cfg.ServiceRestartMaxBackoff = ctx.GlobalDuration(ServiceMaxBackoffFlag.Name)
+	}
+	if ctx.GlobalIsSet(ServiceRestartLimitFlag.Name) {
+		cfg.ServiceRestartLimit = ctx.GlobalInt(ServiceRestartLimitFlag.Name)
+	}
+}
+
+// setPlugins applies the plugin flags to the config.
+func setPlugins(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(PluginsFlag.Name) {
+		cfg.Plugins = splitAndTrim(ctx.GlobalString(PluginsFlag.Name))
+	}
+}
+
+// setAdminQuorum applies the operator approval flags of the guarded admin
+// methods to the config.
+func setAdminQuorum(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(AdminOperatorsFlag.Name) {
+		cfg.AdminOperators = nil
+		for _, addr := range splitAndTrim(ctx.GlobalString(AdminOperatorsFlag.Name)) {
+			if !common.IsHexAddress(addr) {
+				Fatalf("Invalid operator address in --%s: %s", AdminOperatorsFlag.Name, addr)
+			}
+			cfg.AdminOperators = append(cfg.AdminOperators, common.HexToAddress(addr))
+		}
+	}
+	if ctx.GlobalIsSet(AdminThresholdFlag.Name) || cfg.AdminThreshold == 0 {
+		cfg.AdminThreshold = ctx.GlobalInt(AdminThresholdFlag.Name)
+	}
+	if ctx.GlobalIsSet(AdminMethodsFlag.Name) {
+		cfg.AdminGuardedMethods = splitAndTrim(ctx.GlobalString(AdminMethodsFlag.Name))
+	}
+	if ctx.GlobalIsSet(AdminAuditFlag.Name) {
+		cfg.AdminAuditLog = ctx.GlobalString(AdminAuditFlag.Name)
+	}
+}
+
 // setBootstrapNodes creates a list of bootstrap nodes from the command line
 // flags, reverting to pre-configured ones if none have been specified.
 func setBootstrapNodes(ctx *cli.Context, cfg *p2p.Config) {
@@ -660,6 +1051,19 @@ func setListenAddress(ctx *cli.Context, cfg *p2p.Config) {
 	if ctx.GlobalIsSet(ListenPortFlag.Name) {
 		cfg.ListenAddr = fmt.Sprintf(":%d", ctx.GlobalInt(ListenPortFlag.Name))
 	}
+	if ctx.GlobalIsSet(ExtraListenAddrsFlag.Name) {
+		cfg.ExtraListenAddrs = splitAndTrim(ctx.GlobalString(ExtraListenAddrsFlag.Name))
+	}
+}
+
+// setCapture configures the recording of peer messages from command line flags.
+func setCapture(ctx *cli.Context, cfg *p2p.Config) {
+	if ctx.GlobalIsSet(CaptureDirFlag.Name) {
+		cfg.CaptureDir = ctx.GlobalString(CaptureDirFlag.Name)
+	}
+	if ctx.GlobalIsSet(CaptureProtocolsFlag.Name) {
+		cfg.CaptureProtocols = splitAndTrim(ctx.GlobalString(CaptureProtocolsFlag.Name))
+	}
 }
 
 // setNAT creates a port mapper from command line flags.
@@ -675,6 +1079,32 @@ func setNAT(ctx *cli.Context, cfg *p2p.Config) {
 
 // splitAndTrim splits input separated by a comma
 // and trims excessive white space from the substrings.
+// setPropagation applies the sealed block propagation flags to the config.
+func setPropagation(ctx *cli.Context, cfg *man.PropagationConfig) {
+	if ctx.GlobalIsSet(PropagationModeFlag.Name) {
+		cfg.Mode = ctx.GlobalString(PropagationModeFlag.Name)
+		if err := man.ValidatePropagationMode(cfg.Mode); err != nil {
+			Fatalf("--%s: %v", PropagationModeFlag.Name, err)
+		}
+	}
+	if ctx.GlobalIsSet(PropagationMinFullFlag.Name) {
+		cfg.MinFull = ctx.GlobalInt(PropagationMinFullFlag.Name)
+	}
+	if ctx.GlobalIsSet(PropagationValidatorsFlag.Name) {
+		cfg.Validators = ctx.GlobalBool(PropagationValidatorsFlag.Name)
+	}
+	if ctx.GlobalIsSet(PropagationPriorityFlag.Name) {
+		cfg.Priority = nil
+		for _, hex := range splitAndTrim(ctx.GlobalString(PropagationPriorityFlag.Name)) {
+			id, err := discover.HexID(hex)
+			if err != nil {
+				Fatalf("--%s: invalid node id %q: %v", PropagationPriorityFlag.Name, hex, err)
+			}
+			cfg.Priority = append(cfg.Priority, id)
+		}
+	}
+}
+
 func splitAndTrim(input string) []string {
 	result := strings.Split(input, ",")
 	for i, r := range result {
@@ -705,6 +1135,20 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(RPCVirtualHostsFlag.Name) {
 		cfg.HTTPVirtualHosts = splitAndTrim(ctx.GlobalString(RPCVirtualHostsFlag.Name))
 	}
+	if ctx.GlobalIsSet(RPCCacheSizeFlag.Name) {
+		cfg.RPCCacheSize = ctx.GlobalInt(RPCCacheSizeFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCAttestFlag.Name) {
+		cfg.RPCAttestMethods = splitAndTrim(ctx.GlobalString(RPCAttestFlag.Name))
+	}
+	if ctx.GlobalIsSet(RPCAPIKeysFlag.Name) {
+		cfg.RPCAPIKeys = ctx.GlobalString(RPCAPIKeysFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCExtraListenAddrsFlag.Name) {
+		for _, endpoint := range splitAndTrim(ctx.GlobalString(RPCExtraListenAddrsFlag.Name)) {
+			cfg.RPCListeners = append(cfg.RPCListeners, node.ListenerConfig{Protocol: "http", Endpoint: endpoint})
+		}
+	}
 }
 
 // setWS creates the WebSocket RPC listener interface string from the set
@@ -726,6 +1170,11 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(WSApiFlag.Name) {
 		cfg.WSModules = splitAndTrim(ctx.GlobalString(WSApiFlag.Name))
 	}
+	if ctx.GlobalIsSet(WSExtraListenAddrsFlag.Name) {
+		for _, endpoint := range splitAndTrim(ctx.GlobalString(WSExtraListenAddrsFlag.Name)) {
+			cfg.RPCListeners = append(cfg.RPCListeners, node.ListenerConfig{Protocol: "ws", Endpoint: endpoint})
+		}
+	}
 }
 
 // setIPC creates an IPC path configuration from the set command line flags,
@@ -738,6 +1187,16 @@ func setIPC(ctx *cli.Context, cfg *node.Config) {
 	case ctx.GlobalIsSet(IPCPathFlag.Name):
 		cfg.IPCPath = ctx.GlobalString(IPCPathFlag.Name)
 	}
+	if ctx.GlobalIsSet(IPCModeFlag.Name) {
+		mode, err := strconv.ParseUint(ctx.GlobalString(IPCModeFlag.Name), 8, 32)
+		if err != nil || mode > 0777 {
+			Fatalf("Invalid IPC socket mode %q, expected octal permission bits", ctx.GlobalString(IPCModeFlag.Name))
+		}
+		cfg.IPCMode = os.FileMode(mode)
+	}
+	if ctx.GlobalIsSet(IPCGroupFlag.Name) {
+		cfg.IPCGroup = ctx.GlobalString(IPCGroupFlag.Name)
+	}
 }
 
 // makeDatabaseHandles raises out the number of allowed file handles per process
@@ -819,6 +1278,7 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	setListenAddress(ctx, cfg)
 	setBootstrapNodes(ctx, cfg)
 	setBootstrapNodesV5(ctx, cfg)
+	setCapture(ctx, cfg)
 
 	lightClient := ctx.GlobalBool(LightModeFlag.Name) || ctx.GlobalString(SyncModeFlag.Name) == "light"
 	lightServer := ctx.GlobalInt(LightServFlag.Name) != 0
@@ -887,6 +1347,12 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setHTTP(ctx, cfg)
 	setWS(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
+	setServiceSupervision(ctx, cfg)
+	setPlugins(ctx, cfg)
+	setAdminQuorum(ctx, cfg)
+	if ctx.GlobalIsSet(GeoIPFlag.Name) {
+		cfg.GeoIPDatabases = splitAndTrim(ctx.GlobalString(GeoIPFlag.Name))
+	}
 
 	switch {
 	case ctx.GlobalIsSet(DataDirFlag.Name):
@@ -901,6 +1367,15 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 
 	if ctx.GlobalIsSet(KeyStoreDirFlag.Name) {
 		cfg.KeyStoreDir = ctx.GlobalString(KeyStoreDirFlag.Name)
+		if err := node.CheckDir(cfg.KeyStoreDir); err != nil {
+			Fatalf("Invalid keystore directory: %v", err)
+		}
+	}
+	if ctx.GlobalIsSet(ChainDataDirFlag.Name) {
+		cfg.ChainDataDir = ctx.GlobalString(ChainDataDirFlag.Name)
+		if err := node.CheckDir(cfg.ChainDataDir); err != nil {
+			Fatalf("Invalid chain data directory: %v", err)
+		}
 	}
 	if ctx.GlobalIsSet(LightKDFFlag.Name) {
 		cfg.UseLightweightKDF = ctx.GlobalBool(LightKDFFlag.Name)
@@ -950,6 +1425,45 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolPendingLifetimeFlag.Name) {
+		cfg.PendingLifetime = ctx.GlobalDuration(TxPoolPendingLifetimeFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolRejectUnprotectedFlag.Name) {
+		cfg.RejectUnprotected = ctx.GlobalBool(TxPoolRejectUnprotectedFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolUnprotectedGraceFlag.Name) {
+		cfg.UnprotectedGrace = nil
+		for _, hash := range splitAndTrim(ctx.GlobalString(TxPoolUnprotectedGraceFlag.Name)) {
+			if !isHexHash(hash) {
+				Fatalf("Invalid transaction hash in --%s: %s", TxPoolUnprotectedGraceFlag.Name, hash)
+			}
+			cfg.UnprotectedGrace = append(cfg.UnprotectedGrace, common.HexToHash(hash))
+		}
+	}
+	if ctx.GlobalIsSet(TxPoolSpamGuardFlag.Name) {
+		cfg.SpamGuard = ctx.GlobalBool(TxPoolSpamGuardFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolSpamShareFlag.Name) {
+		cfg.SpamShare = ctx.GlobalFloat64(TxPoolSpamShareFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolSpamWindowFlag.Name) {
+		cfg.SpamWindow = ctx.GlobalDuration(TxPoolSpamWindowFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolSpamAllowFlag.Name) {
+		cfg.SpamAllow = nil
+		for _, addr := range splitAndTrim(ctx.GlobalString(TxPoolSpamAllowFlag.Name)) {
+			if !common.IsHexAddress(addr) {
+				Fatalf("Invalid contract address in --%s: %s", TxPoolSpamAllowFlag.Name, addr)
+			}
+			cfg.SpamAllow = append(cfg.SpamAllow, common.HexToAddress(addr))
+		}
+	}
+}
+
+// isHexHash reports whether s is a 0x prefixed 32 byte hex string.
+func isHexHash(s string) bool {
+	b, err := hexutil.Decode(s)
+	return err == nil && len(b) == common.HashLength
 }
 
 func setEthash(ctx *cli.Context, cfg *man.Config) {
@@ -1019,6 +1533,15 @@ func SetShhConfig(ctx *cli.Context, stack *node.Node, cfg *whisper.Config) {
 	if ctx.GlobalIsSet(WhisperMinPOWFlag.Name) {
 		cfg.MinimumAcceptedPOW = ctx.GlobalFloat64(WhisperMinPOWFlag.Name)
 	}
+	if ctx.GlobalIsSet(WhisperMaxFiltersFlag.Name) {
+		cfg.MaxIdentityFilters = ctx.GlobalInt(WhisperMaxFiltersFlag.Name)
+	}
+	if ctx.GlobalIsSet(WhisperPersistFlag.Name) {
+		cfg.PersistMessages = ctx.GlobalBool(WhisperPersistFlag.Name)
+	}
+	if ctx.GlobalIsSet(WhisperRetentionFlag.Name) {
+		cfg.MessageRetention = uint32(ctx.GlobalUint(WhisperRetentionFlag.Name))
+	}
 }
 
 // SetEthConfig applies man-related command line flags to the config.
@@ -1063,6 +1586,55 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *man.Config) {
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
 
+	if ctx.GlobalIsSet(FutureBlockDriftFlag.Name) {
+		drift := ctx.GlobalDuration(FutureBlockDriftFlag.Name)
+		cfg.FutureBlockDrift = &drift
+	}
+	if ctx.GlobalIsSet(PinHeadFlag.Name) {
+		pin, err := core.ParsePinnedBlock(ctx.GlobalString(PinHeadFlag.Name))
+		if err != nil {
+			Fatalf("Option %q: %v", PinHeadFlag.Name, err)
+		}
+		cfg.PinnedHead = pin
+	}
+	if ctx.GlobalBool(ForkMonitorFlag.Name) {
+		if cfg.ForkMonitor == nil {
+			forkcfg := forkmon.DefaultConfig
+			cfg.ForkMonitor = &forkcfg
+		}
+		if ctx.GlobalIsSet(ForkMonitorDepthFlag.Name) {
+			cfg.ForkMonitor.Depth = ctx.GlobalUint64(ForkMonitorDepthFlag.Name)
+		}
+		if ctx.GlobalIsSet(ForkMonitorWebhookFlag.Name) {
+			cfg.ForkMonitor.Webhook = ctx.GlobalString(ForkMonitorWebhookFlag.Name)
+		}
+	}
+	if ctx.GlobalBool(BlobsFlag.Name) {
+		if cfg.Blobs == nil {
+			blobcfg := blobs.DefaultConfig
+			cfg.Blobs = &blobcfg
+		}
+		if ctx.GlobalIsSet(BlobsStoreFlag.Name) {
+			cfg.Blobs.StoreSize = ctx.GlobalUint64(BlobsStoreFlag.Name) * 1024 * 1024
+		}
+		if ctx.GlobalIsSet(BlobsHorizonFlag.Name) {
+			cfg.Blobs.Horizon = ctx.GlobalUint64(BlobsHorizonFlag.Name)
+		}
+	}
+	if ctx.GlobalIsSet(RemoteStateFlag.Name) {
+		if cfg.SyncMode != downloader.LightSync {
+			Fatalf("--%s requires light mode", RemoteStateFlag.Name)
+		}
+		if cfg.RemoteState == nil {
+			remotecfg := remotestate.DefaultConfig
+			cfg.RemoteState = &remotecfg
+		}
+		cfg.RemoteState.Upstreams = strings.Split(ctx.GlobalString(RemoteStateFlag.Name), ",")
+		if ctx.GlobalIsSet(RemoteStateCacheFlag.Name) {
+			cfg.RemoteState.CacheSize = ctx.GlobalInt(RemoteStateCacheFlag.Name)
+		}
+	}
+
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cfg.TrieCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
@@ -1078,10 +1650,23 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *man.Config) {
 	if ctx.GlobalIsSet(GasPriceFlag.Name) {
 		cfg.GasPrice = GlobalBig(ctx, GasPriceFlag.Name)
 	}
+	setPropagation(ctx, &cfg.Propagation)
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
 	}
+	if ctx.GlobalIsSet(VMParallelTxsFlag.Name) {
+		cfg.ParallelTxs = ctx.GlobalInt(VMParallelTxsFlag.Name)
+	}
+	if ctx.GlobalIsSet(StateAccessIndexFlag.Name) {
+		cfg.StateAccessIndex = ctx.GlobalBool(StateAccessIndexFlag.Name)
+	}
+	if ctx.GlobalIsSet(TraceIndexFlag.Name) {
+		cfg.TraceIndex = ctx.GlobalBool(TraceIndexFlag.Name)
+	}
+	if ctx.GlobalIsSet(VMKZGSetupFlag.Name) {
+		cfg.KZGTrustedSetup = ctx.GlobalString(VMKZGSetupFlag.Name)
+	}
 
 	// Override any default configs for hard coded networks.
 	switch {
@@ -1115,6 +1700,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *man.Config) {
 		log.Info("Using developer account", "address", developer.Address)
 
 		cfg.Genesis = core.DeveloperGenesisBlock(uint64(ctx.GlobalInt(DeveloperPeriodFlag.Name)), developer.Address)
+		cfg.DevMode = true
 		if !ctx.GlobalIsSet(GasPriceFlag.Name) {
 			cfg.GasPrice = big.NewInt(1)
 		}
@@ -1132,6 +1718,83 @@ func SetDashboardConfig(ctx *cli.Context, cfg *dashboard.Config) {
 	cfg.Refresh = ctx.GlobalDuration(DashboardRefreshFlag.Name)
 }
 
+// SetFaucetConfig applies faucet related command line flags to the config.
+func SetFaucetConfig(ctx *cli.Context, cfg *faucet.Config) {
+	if ctx.GlobalIsSet(FaucetAddrFlag.Name) {
+		cfg.Host = ctx.GlobalString(FaucetAddrFlag.Name)
+	}
+	if ctx.GlobalIsSet(FaucetPortFlag.Name) {
+		cfg.Port = ctx.GlobalInt(FaucetPortFlag.Name)
+	}
+	if ctx.GlobalIsSet(FaucetAccountFlag.Name) {
+		account := ctx.GlobalString(FaucetAccountFlag.Name)
+		if !common.IsHexAddress(account) {
+			Fatalf("Invalid faucet account %q", account)
+		}
+		cfg.Account = common.HexToAddress(account)
+	}
+	if ctx.GlobalIsSet(FaucetAmountFlag.Name) {
+		cfg.Amount = GlobalBig(ctx, FaucetAmountFlag.Name)
+	}
+	if ctx.GlobalIsSet(FaucetAddressCooldownFlag.Name) {
+		cfg.AddressCooldown = ctx.GlobalDuration(FaucetAddressCooldownFlag.Name)
+	}
+	if ctx.GlobalIsSet(FaucetIPCooldownFlag.Name) {
+		cfg.IPCooldown = ctx.GlobalDuration(FaucetIPCooldownFlag.Name)
+	}
+	if ctx.GlobalIsSet(FaucetCaptchaURLFlag.Name) {
+		cfg.CaptchaURL = ctx.GlobalString(FaucetCaptchaURLFlag.Name)
+	}
+	if ctx.GlobalIsSet(FaucetCaptchaSecretFlag.Name) {
+		cfg.CaptchaSecret = ctx.GlobalString(FaucetCaptchaSecretFlag.Name)
+	}
+	if ctx.GlobalIsSet(FaucetTrustedProxiesFlag.Name) {
+		cfg.TrustedProxies = nil
+		for _, proxy := range strings.Split(ctx.GlobalString(FaucetTrustedProxiesFlag.Name), ",") {
+			if proxy = strings.TrimSpace(proxy); proxy != "" {
+				cfg.TrustedProxies = append(cfg.TrustedProxies, proxy)
+			}
+		}
+	}
+}
+
+// SetUserOpConfig applies user operation pool related command line flags to
+// the config.
+func SetUserOpConfig(ctx *cli.Context, cfg *userop.Config) {
+	if ctx.GlobalIsSet(UserOpEntryPointFlag.Name) {
+		entryPoint := ctx.GlobalString(UserOpEntryPointFlag.Name)
+		if !common.IsHexAddress(entryPoint) {
+			Fatalf("Invalid user operation entrypoint %q", entryPoint)
+		}
+		cfg.EntryPoint = common.HexToAddress(entryPoint)
+	}
+	if ctx.GlobalIsSet(UserOpMaxOpsFlag.Name) {
+		cfg.MaxOps = ctx.GlobalInt(UserOpMaxOpsFlag.Name)
+	}
+	if ctx.GlobalIsSet(UserOpLifetimeFlag.Name) {
+		cfg.Lifetime = ctx.GlobalDuration(UserOpLifetimeFlag.Name)
+	}
+	if ctx.GlobalIsSet(UserOpValidationGasFlag.Name) {
+		cfg.ValidationGas = ctx.GlobalUint64(UserOpValidationGasFlag.Name)
+	}
+	if ctx.GlobalIsSet(UserOpBundleSizeFlag.Name) {
+		cfg.BundleSize = ctx.GlobalInt(UserOpBundleSizeFlag.Name)
+	}
+	if ctx.GlobalIsSet(UserOpBundleGasFlag.Name) {
+		cfg.BundleGas = ctx.GlobalUint64(UserOpBundleGasFlag.Name)
+	}
+}
+
+// SetWatchdogConfig applies watchdog related command line flags to the config.
+func SetWatchdogConfig(ctx *cli.Context, cfg *watchdog.Config) {
+	if ctx.GlobalIsSet(WatchdogTimeoutFlag.Name) {
+		cfg.Timeout = ctx.GlobalDuration(WatchdogTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(WatchdogActionFlag.Name) {
+		cfg.Action = ctx.GlobalString(WatchdogActionFlag.Name)
+	}
+}
+
 // RegisterEthService adds an Matrix client to the stack.
 func RegisterEthService(stack *node.Node, cfg *man.Config) {
 	var err error
@@ -1164,7 +1827,13 @@ func RegisterDashboardService(stack *node.Node, cfg *dashboard.Config, commit st
 // RegisterShhService configures Whisper and adds it to the given node.
 func RegisterShhService(stack *node.Node, cfg *whisper.Config) {
 	if err := stack.Register(func(n *node.ServiceContext) (node.Service, error) {
-		return whisper.New(cfg), nil
+		shh := whisper.New(cfg)
+		if cfg.PersistMessages {
+			if err := shh.OpenMessageStore(n.ResolvePath("shhmessages"), cfg.MessageRetention, cfg.MaxTopicMessages); err != nil {
+				return nil, err
+			}
+		}
+		return shh, nil
 	}); err != nil {
 		Fatalf("Failed to register the Whisper service: %v", err)
 	}
@@ -1187,6 +1856,43 @@ func RegisterEthStatsService(stack *node.Node, url string) {
 	}
 }
 
+// RegisterFaucetService configures the faucet and adds it to the given node.
+func RegisterFaucetService(stack *node.Node, cfg *faucet.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var manServ *man.Matrix
+		if err := ctx.Service(&manServ); err != nil {
+			return nil, fmt.Errorf("faucet requires a full node: %v", err)
+		}
+		return faucet.New(cfg, manServ.APIBackend, ctx.AccountManager)
+	}); err != nil {
+		Fatalf("Failed to register the faucet service: %v", err)
+	}
+}
+
+// RegisterWatchdogService adds a watchdog monitoring the node loops to the
+// stack, dumping its diagnostics into the data directory.
+func RegisterWatchdogService(stack *node.Node, cfg *watchdog.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return watchdog.New(cfg, ctx.ResolvePath("watchdog"))
+	}); err != nil {
+		Fatalf("Failed to register the watchdog service: %v", err)
+	}
+}
+
+// RegisterUserOpService configures the user operation pool and adds it to the
+// given node.
+func RegisterUserOpService(stack *node.Node, cfg *userop.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var manServ *man.Matrix
+		if err := ctx.Service(&manServ); err != nil {
+			return nil, fmt.Errorf("user operation pool requires a full node: %v", err)
+		}
+		return userop.New(cfg, manServ.APIBackend, ctx.AccountManager)
+	}); err != nil {
+		Fatalf("Failed to register the user operation service: %v", err)
+	}
+}
+
 // SetupNetwork configures the system for either the main net or some test network.
 func SetupNetwork(ctx *cli.Context) {
 	// TODO(fjl): move target gas limit into config
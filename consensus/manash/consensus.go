@@ -289,6 +289,9 @@ func (manash *Ethash) verifyHeader(chain consensus.ChainReader, header, parent *
 	if err := misc.VerifyForkHashes(chain.Config(), header, uncle); err != nil {
 		return err
 	}
+	if err := misc.VerifyBaseFee(chain.Config(), parent, header); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -525,6 +528,7 @@ func (manash *Ethash) Prepare(chain consensus.ChainReader, header *types.Header)
 		return consensus.ErrUnknownAncestor
 	}
 	header.Difficulty = manash.CalcDifficulty(chain, header.Time.Uint64(), parent)
+	header.BaseFee = misc.CalcBaseFee(chain.Config(), parent)
 	return nil
 }
 
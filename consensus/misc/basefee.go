@@ -0,0 +1,82 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package misc
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/matrix/go-matrix/common/math"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/params"
+)
+
+// VerifyBaseFee verifies that the base fee of a header matches the one derived
+// from its parent. Headers before the dynamic fee fork must not carry one.
+func VerifyBaseFee(config *params.ChainConfig, parent, header *types.Header) error {
+	if !config.IsDynamicFee(header.Number) {
+		if header.BaseFee != nil {
+			return fmt.Errorf("unexpected base fee before dynamic fee fork: %v", header.BaseFee)
+		}
+		return nil
+	}
+	if header.BaseFee == nil {
+		return fmt.Errorf("header is missing base fee")
+	}
+	if expected := CalcBaseFee(config, parent); header.BaseFee.Cmp(expected) != 0 {
+		return fmt.Errorf("invalid base fee: have %v, want %v", header.BaseFee, expected)
+	}
+	return nil
+}
+
+// CalcBaseFee calculates the base fee of the block following parent. It returns
+// nil if the dynamic fee fork is not active for that block.
+//
+// The base fee moves towards the price at which blocks are half full: it rises
+// by up to 1/BaseFeeChangeDenominator if the parent used more than its gas
+// target and falls by up to the same ratio if it used less.
+func CalcBaseFee(config *params.ChainConfig, parent *types.Header) *big.Int {
+	next := new(big.Int).Add(parent.Number, big.NewInt(1))
+	if !config.IsDynamicFee(next) {
+		return nil
+	}
+	// The first block after the fork starts from the initial base fee
+	if !config.IsDynamicFee(parent.Number) || parent.BaseFee == nil {
+		return new(big.Int).SetUint64(params.InitialBaseFee)
+	}
+	target := parent.GasLimit / params.ElasticityMultiplier
+	if target == 0 || parent.GasUsed == target {
+		return new(big.Int).Set(parent.BaseFee)
+	}
+	var (
+		denom = new(big.Int).SetUint64(params.BaseFeeChangeDenominator)
+		delta *big.Int
+	)
+	if parent.GasUsed > target {
+		// Increase by at least one wei so congested blocks always raise the fee
+		delta = new(big.Int).SetUint64(parent.GasUsed - target)
+		delta.Mul(delta, parent.BaseFee)
+		delta.Div(delta, new(big.Int).SetUint64(target))
+		delta.Div(delta, denom)
+		return new(big.Int).Add(parent.BaseFee, math.BigMax(delta, big.NewInt(1)))
+	}
+	delta = new(big.Int).SetUint64(target - parent.GasUsed)
+	delta.Mul(delta, parent.BaseFee)
+	delta.Div(delta, new(big.Int).SetUint64(target))
+	delta.Div(delta, denom)
+	return math.BigMax(new(big.Int).Sub(parent.BaseFee, delta), big.NewInt(0))
+}
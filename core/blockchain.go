@@ -50,11 +50,19 @@ import (
 	"github.com/matrix/go-matrix/params"
 	"github.com/matrix/go-matrix/rlp"
 	"github.com/matrix/go-matrix/trie"
+	"github.com/matrix/go-matrix/watchdog"
 )
 
 var (
 	blockInsertTimer = metrics.NewRegisteredTimer("chain/inserts", nil)
 
+	pendingLogsDropCounter  = metrics.NewRegisteredCounter("chain/events/pendinglogs/drop", nil)
+	pendingStateDropCounter = metrics.NewRegisteredCounter("chain/events/pendingstate/drop", nil)
+	minedBlockDropCounter   = metrics.NewRegisteredCounter("chain/events/minedblock/drop", nil)
+
+	futureQueuedMeter   = metrics.NewRegisteredMeter("chain/future/queued", nil)
+	futureRejectedMeter = metrics.NewRegisteredMeter("chain/future/rejected", nil)
+
 	ErrNoGenesis = errors.New("Genesis not found in chain")
 )
 
@@ -106,8 +114,14 @@ type BlockChain struct {
 	chainSideFeed event.Feed
 	chainHeadFeed event.Feed
 	logsFeed      event.Feed
+	balanceFeed   event.Feed
 	scope         event.SubscriptionScope
-	genesisBlock  *types.Block
+
+	// Feeds of the block producers, delivery never blocks the producer
+	pendingLogsFeed  *event.BufferedFeed
+	pendingStateFeed *event.BufferedFeed
+	minedBlockFeed   *event.BufferedFeed
+	genesisBlock     *types.Block
 
 	mu      sync.RWMutex // global mutex for locking chain operations
 	chainmu sync.RWMutex // blockchain insertion lock
@@ -122,6 +136,11 @@ type BlockChain struct {
 	bodyRLPCache *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
 	blockCache   *lru.Cache     // Cache for the most recent entire blocks
 	futureBlocks *lru.Cache     // future blocks are blocks added for later processing
+	futureDrift  int64          // Maximum timestamp drift of quarantined future blocks (atomic, nanoseconds)
+
+	accessIndex *StateAccessIndex // Last block accessing each account and slot, nil if not indexed
+	traceIndex  *TraceIndex       // Call frames of the transactions, nil if not indexed
+	pinned      *PinnedBlock      // Block the chain must contain, nil if not pinned
 
 	quit    chan struct{} // blockchain quit channel
 	running int32         // running must be called atomically
@@ -166,9 +185,14 @@ func NewBlockChain(db mandb.Database, cacheConfig *CacheConfig, chainConfig *par
 		bodyRLPCache: bodyRLPCache,
 		blockCache:   blockCache,
 		futureBlocks: futureBlocks,
+		futureDrift:  int64(maxTimeFutureBlocks * time.Second),
 		engine:       engine,
 		vmConfig:     vmConfig,
 		badBlocks:    badBlocks,
+
+		pendingLogsFeed:  event.NewBufferedFeed(pendingLogsDropCounter),
+		pendingStateFeed: event.NewBufferedFeed(pendingStateDropCounter),
+		minedBlockFeed:   event.NewBufferedFeed(minedBlockDropCounter),
 	}
 	bc.SetValidator(NewBlockValidator(chainConfig, bc, engine))
 	bc.SetProcessor(NewStateProcessor(chainConfig, bc, engine))
@@ -372,6 +396,43 @@ func (bc *BlockChain) SetValidator(validator Validator) {
 	bc.validator = validator
 }
 
+// SetFutureBlockDrift sets the maximum time blocks may be ahead of the local
+// clock. Blocks within the drift that are still too early for the consensus
+// engine are quarantined until their time comes, blocks beyond it are rejected.
+func (bc *BlockChain) SetFutureBlockDrift(drift time.Duration) {
+	atomic.StoreInt64(&bc.futureDrift, int64(drift))
+}
+
+// FutureBlockDrift returns the maximum time blocks may be ahead of the local
+// clock.
+func (bc *BlockChain) FutureBlockDrift() time.Duration {
+	return time.Duration(atomic.LoadInt64(&bc.futureDrift))
+}
+
+// EnableStateAccessIndex starts indexing the last block accessing each account
+// and storage slot of the blocks imported from now on. It must be called
+// before blocks are imported.
+func (bc *BlockChain) EnableStateAccessIndex() {
+	bc.accessIndex = NewStateAccessIndex(bc.db)
+}
+
+// StateAccessIndex returns the state access index, nil if not enabled.
+func (bc *BlockChain) StateAccessIndex() *StateAccessIndex {
+	return bc.accessIndex
+}
+
+// EnableTraceIndex starts recording the call frames of the transactions of the
+// blocks imported from now on. It must be called before blocks are imported.
+func (bc *BlockChain) EnableTraceIndex() {
+	bc.traceIndex = NewTraceIndex(bc.db)
+	bc.traceIndex.init(bc.CurrentBlock().NumberU64())
+}
+
+// TraceIndex returns the tracing index, nil if not enabled.
+func (bc *BlockChain) TraceIndex() *TraceIndex {
+	return bc.traceIndex
+}
+
 // Validator returns the current validator.
 func (bc *BlockChain) Validator() Validator {
 	bc.procmu.RLock()
@@ -386,11 +447,21 @@ func (bc *BlockChain) Processor() Processor {
 	return bc.processor
 }
 
+// GetVMConfig returns the block chain VM config.
+func (bc *BlockChain) GetVMConfig() *vm.Config {
+	return &bc.vmConfig
+}
+
 // State returns a new mutable state based on the current HEAD block.
 func (bc *BlockChain) State() (*state.StateDB, error) {
 	return bc.StateAt(bc.CurrentBlock().Root())
 }
 
+// StateCache returns the state database shared by the states of the chain.
+func (bc *BlockChain) StateCache() state.Database {
+	return bc.stateCache
+}
+
 // StateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
 	return state.New(root, bc.stateCache)
@@ -915,9 +986,10 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	}
 	triedb := bc.stateCache.TrieDB()
 
-	// If we're running an archive node, always flush
+	// If we're running an archive node, always flush, together with the block
+	var uncache func()
 	if bc.cacheConfig.Disabled {
-		if err := triedb.Commit(root, false); err != nil {
+		if uncache, err = triedb.CommitBatch(root, batch); err != nil {
 			return NonStatTy, err
 		}
 	} else {
@@ -987,13 +1059,22 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 		rawdb.WriteTxLookupEntries(batch, block)
 		rawdb.WritePreimages(batch, block.NumberU64(), state.Preimages())
 
+		if touched := state.StopTouchTracking(); bc.accessIndex != nil && touched != nil {
+			bc.accessIndex.update(batch, block.NumberU64(), state, touched)
+		}
 		status = CanonStatTy
 	} else {
 		status = SideStatTy
 	}
+	if bc.traceIndex != nil {
+		bc.traceIndex.write(batch, block, receipts, status == CanonStatTy)
+	}
 	if err := batch.Write(); err != nil {
 		return NonStatTy, err
 	}
+	if uncache != nil {
+		uncache()
+	}
 
 	// Set new head.
 	if status == CanonStatTy {
@@ -1198,10 +1279,22 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			bc.reportBlock(block, nil, ErrBlacklistedHash)
 			return i, events, coalescedLogs, ErrBlacklistedHash
 		}
+		// Neither may blocks of branches without the pinned block be imported
+		if bc.pinned.Conflicts(block.Header()) {
+			bc.reportBlock(block, nil, ErrPinnedBlock)
+			return i, events, coalescedLogs, ErrPinnedBlock
+		}
 		// Wait for the block's verification to complete
 		bstart := time.Now()
 
 		err := <-results
+
+		// Consensus engines tolerate some clock skew, enforce the local drift
+		// limit even if it's stricter than theirs
+		drift := bc.FutureBlockDrift()
+		if err == nil && block.Time().Cmp(big.NewInt(time.Now().Add(drift).Unix())) > 0 {
+			err = consensus.ErrFutureBlock
+		}
 		if err == nil {
 			bc.dposEngine.VerifyBlock(block.Header())
 		}
@@ -1218,12 +1311,16 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			}
 
 		case err == consensus.ErrFutureBlock:
-			// Allow up to MaxFuture second in the future blocks. If this limit is exceeded
-			// the chain is discarded and processed at a later time if given.
-			max := big.NewInt(time.Now().Unix() + maxTimeFutureBlocks)
+			// Allow up to the configured drift in the future blocks. If this limit is
+			// exceeded the chain is discarded and processed at a later time if given.
+			max := big.NewInt(time.Now().Add(drift).Unix())
 			if block.Time().Cmp(max) > 0 {
+				futureRejectedMeter.Mark(1)
+				log.Warn("Rejected block too far in the future", "number", block.Number(), "hash", block.Hash(),
+					"ahead", common.PrettyDuration(time.Until(time.Unix(block.Time().Int64(), 0))), "drift", drift)
 				return i, events, coalescedLogs, fmt.Errorf("future block: %v > %v", block.Time(), max)
 			}
+			futureQueuedMeter.Mark(1)
 			bc.futureBlocks.Add(block.Hash(), block)
 			stats.queued++
 			continue
@@ -1304,6 +1401,10 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 						return i, events, coalescedLogs, err
 					}
 				}*/
+		state.StartBalanceTracking()
+		if bc.accessIndex != nil {
+			state.StartTouchTracking()
+		}
 		receipts, logs, usedGas, err := bc.processor.Process(block, state, bc.vmConfig)
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
@@ -1330,6 +1431,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			coalescedLogs = append(coalescedLogs, logs...)
 			blockInsertTimer.UpdateSince(bstart)
 			events = append(events, ChainEvent{block, block.Hash(), logs})
+			events = append(events, BalanceChangeEvent{block, state.StopBalanceTracking()})
 			lastCanon = block
 
 			// Only count canonical blocks for GC processing time
@@ -1537,6 +1639,9 @@ func (bc *BlockChain) PostChainEvents(events []interface{}, logs []*types.Log) {
 
 		case ChainSideEvent:
 			bc.chainSideFeed.Send(ev)
+
+		case BalanceChangeEvent:
+			bc.balanceFeed.Send(ev)
 		}
 	}
 }
@@ -1585,11 +1690,15 @@ func (bc *BlockChain) sendBroadTx() {
 }
 
 func (bc *BlockChain) update() {
+	heart := watchdog.Register("importer")
+	defer heart.Idle()
+
 	futureTimer := time.NewTicker(5 * time.Second)
 	defer futureTimer.Stop()
 	for {
 		select {
 		case <-futureTimer.C:
+			heart.Beat()
 			bc.procFutureBlocks()
 		case <-bc.quit:
 			return
@@ -1651,6 +1760,12 @@ Error: %v
 // because nonces can be verified sparsely, not needing to check each.
 func (bc *BlockChain) InsertHeaderChain(chain []*types.Header, checkFreq int) (int, error) {
 	start := time.Now()
+	for i, header := range chain {
+		if bc.pinned.Conflicts(header) {
+			log.Error("Header conflicts with the pinned block", "number", header.Number, "hash", header.Hash(), "pinned", bc.pinned.Hash)
+			return i, ErrPinnedBlock
+		}
+	}
 	if i, err := bc.hc.ValidateHeaderChain(chain, checkFreq); err != nil {
 		return i, err
 	}
@@ -1774,6 +1889,45 @@ func (bc *BlockChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscript
 	return bc.scope.Track(bc.logsFeed.Subscribe(ch))
 }
 
+// SubscribeBalanceChangeEvent registers a subscription of BalanceChangeEvent.
+func (bc *BlockChain) SubscribeBalanceChangeEvent(ch chan<- BalanceChangeEvent) event.Subscription {
+	return bc.scope.Track(bc.balanceFeed.Subscribe(ch))
+}
+
+// SubscribePendingLogsEvent registers a subscription of PendingLogsEvent.
+// Events not fitting into the channel's buffer are dropped.
+func (bc *BlockChain) SubscribePendingLogsEvent(ch chan<- PendingLogsEvent) event.Subscription {
+	return bc.scope.Track(bc.pendingLogsFeed.Subscribe(ch))
+}
+
+// SubscribePendingStateEvent registers a subscription of PendingStateEvent.
+// Events not fitting into the channel's buffer are dropped.
+func (bc *BlockChain) SubscribePendingStateEvent(ch chan<- PendingStateEvent) event.Subscription {
+	return bc.scope.Track(bc.pendingStateFeed.Subscribe(ch))
+}
+
+// SubscribeNewMinedBlockEvent registers a subscription of NewMinedBlockEvent.
+// Events not fitting into the channel's buffer are dropped.
+func (bc *BlockChain) SubscribeNewMinedBlockEvent(ch chan<- NewMinedBlockEvent) event.Subscription {
+	return bc.scope.Track(bc.minedBlockFeed.Subscribe(ch))
+}
+
+// PostPendingLogs announces the logs of transactions executed on top of the
+// pending state.
+func (bc *BlockChain) PostPendingLogs(logs []*types.Log) {
+	bc.pendingLogsFeed.Send(PendingLogsEvent{Logs: logs})
+}
+
+// PostPendingState announces a change of the pending state.
+func (bc *BlockChain) PostPendingState() {
+	bc.pendingStateFeed.Send(PendingStateEvent{})
+}
+
+// PostMinedBlock announces a block produced by the local node.
+func (bc *BlockChain) PostMinedBlock(block *types.Block) {
+	bc.minedBlockFeed.Send(NewMinedBlockEvent{Block: block})
+}
+
 func (bc *BlockChain) VerifyHeader(header *types.Header) error {
 	return bc.engine.VerifyHeader(bc, header, false)
 }
@@ -179,6 +179,47 @@ func TestLastBlock(t *testing.T) {
 	}
 }
 
+// Tests that blocks ahead of the local clock are quarantined within the future
+// block drift and rejected beyond it, even if the drift is disabled entirely.
+func TestFutureBlockDrift(t *testing.T) {
+	tests := []struct {
+		drift  time.Duration
+		ahead  int64 // seconds the block is ahead of the local clock
+		queued bool
+	}{
+		{maxTimeFutureBlocks * time.Second, 20, true},
+		{maxTimeFutureBlocks * time.Second, 60, false},
+		{5 * time.Second, 20, false},
+		{0, 10, false},
+		{0, 20, false},
+	}
+	for i, tt := range tests {
+		_, blockchain, err := newCanonical(manash.NewFaker(), 0, true)
+		if err != nil {
+			t.Fatalf("test %d: failed to create pristine chain: %v", i, err)
+		}
+		blockchain.SetFutureBlockDrift(tt.drift)
+
+		blocks, _ := GenerateChain(params.TestChainConfig, blockchain.CurrentBlock(), manash.NewFaker(), blockchain.db, 1, func(_ int, b *BlockGen) {
+			b.OffsetTime(time.Now().Unix() + tt.ahead - b.header.Time.Int64())
+		})
+		_, err = blockchain.InsertChain(blocks)
+		if queued := blockchain.futureBlocks.Contains(blocks[0].Hash()); queued != tt.queued {
+			t.Errorf("test %d: queued mismatch: have %v, want %v", i, queued, tt.queued)
+		}
+		if tt.queued && err != nil {
+			t.Errorf("test %d: failed to queue block: %v", i, err)
+		}
+		if !tt.queued && err == nil {
+			t.Errorf("test %d: block %ds ahead accepted with %v drift", i, tt.ahead, tt.drift)
+		}
+		if head := blockchain.CurrentBlock().NumberU64(); head != 0 {
+			t.Errorf("test %d: future block imported, head %d", i, head)
+		}
+		blockchain.Stop()
+	}
+}
+
 // Tests that given a starting canonical chain of a given size, it can be extended
 // with various length chains.
 func TestExtendCanonicalHeaders(t *testing.T) { testExtendCanonical(t, false) }
@@ -0,0 +1,123 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package core
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/core/vm"
+)
+
+// callFrameTracer is a vm.Tracer summarising the call frames of a transaction
+// for the tracing index. A frame is opened by the call or create operation of
+// its caller and closed by the first step executed at the caller's depth again,
+// which finds the outcome of the frame on top of the stack. Calls to accounts
+// without code never execute deeper and are closed right away.
+type callFrameTracer struct {
+	frames []*types.CallFrame
+	open   []int // Indexes of the frames being executed, the innermost last
+}
+
+func newCallFrameTracer() *callFrameTracer {
+	return &callFrameTracer{}
+}
+
+// CaptureStart implements vm.Tracer, opening the frame of the transaction.
+func (t *callFrameTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	typ := types.CallFrameCall
+	if create {
+		typ = types.CallFrameCreate
+	}
+	t.frames = []*types.CallFrame{{Type: typ, From: from, To: to, Value: new(big.Int).Set(value), TraceAddress: []uint64{}}}
+	t.open = []int{0}
+	return nil
+}
+
+// CaptureState implements vm.Tracer, tracking frames entered and returned from.
+func (t *callFrameTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if err != nil || len(t.open) == 0 {
+		return nil
+	}
+	// The transaction frame executes at depth 1, close the frames returned from
+	for len(t.open) > depth {
+		t.exit(stack.Back(0))
+	}
+	switch op {
+	case vm.CALL:
+		t.enter(types.CallFrameCall, contract.Address(), common.BigToAddress(stack.Back(1)), stack.Back(2))
+	case vm.CALLCODE:
+		t.enter(types.CallFrameCallCode, contract.Address(), common.BigToAddress(stack.Back(1)), stack.Back(2))
+	case vm.DELEGATECALL:
+		t.enter(types.CallFrameDelegateCall, contract.Address(), common.BigToAddress(stack.Back(1)), contract.Value())
+	case vm.STATICCALL:
+		t.enter(types.CallFrameStaticCall, contract.Address(), common.BigToAddress(stack.Back(1)), new(big.Int))
+	case vm.CREATE:
+		t.enter(types.CallFrameCreate, contract.Address(), common.Address{}, stack.Back(0))
+	case vm.SELFDESTRUCT:
+		t.enter(types.CallFrameSelfDestruct, contract.Address(), common.BigToAddress(stack.Back(0)), env.StateDB.GetBalance(contract.Address()))
+		t.open = t.open[:len(t.open)-1]
+	}
+	return nil
+}
+
+// CaptureFault implements vm.Tracer.
+func (t *callFrameTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd implements vm.Tracer, recording the outcome of the transaction.
+func (t *callFrameTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	if err != nil && len(t.frames) > 0 {
+		t.frames[0].Failed = true
+	}
+	return nil
+}
+
+// enter opens a child frame of the innermost frame being executed.
+func (t *callFrameTracer) enter(typ string, from, to common.Address, value *big.Int) {
+	parent := t.frames[t.open[len(t.open)-1]]
+
+	t.frames = append(t.frames, &types.CallFrame{
+		Type:         typ,
+		From:         from,
+		To:           to,
+		Value:        new(big.Int).Set(value),
+		TraceAddress: append(append([]uint64{}, parent.TraceAddress...), parent.Subtraces),
+	})
+	t.open = append(t.open, len(t.frames)-1)
+	parent.Subtraces++
+}
+
+// exit closes the innermost frame given the result its caller found on the
+// stack: the created address for creations, the success flag for calls.
+func (t *callFrameTracer) exit(result *big.Int) {
+	frame := t.frames[t.open[len(t.open)-1]]
+	t.open = t.open[:len(t.open)-1]
+
+	if frame.Type == types.CallFrameCreate {
+		frame.To = common.BigToAddress(result)
+	}
+	frame.Failed = result.Sign() == 0
+}
+
+// Frames returns the frames recorded, the transaction frame first.
+func (t *callFrameTracer) Frames() []*types.CallFrame {
+	return t.frames
+}
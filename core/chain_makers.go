@@ -17,6 +17,7 @@
 package core
 
 import (
+	"crypto/ecdsa"
 	"fmt"
 	"math/big"
 
@@ -26,6 +27,7 @@ import (
 	"github.com/matrix/go-matrix/core/state"
 	"github.com/matrix/go-matrix/core/types"
 	"github.com/matrix/go-matrix/core/vm"
+	"github.com/matrix/go-matrix/crypto"
 	"github.com/matrix/go-matrix/mandb"
 	"github.com/matrix/go-matrix/params"
 )
@@ -50,6 +52,7 @@ type BlockGen struct {
 	txs      []*types.Transaction
 	receipts []*types.Receipt
 	uncles   []*types.Header
+	signers  []*ecdsa.PrivateKey
 
 	config *params.ChainConfig
 	engine consensus.Engine
@@ -73,6 +76,48 @@ func (b *BlockGen) SetExtra(data []byte) {
 	b.header.Extra = data
 }
 
+// SetGasLimit sets the gas limit of the generated block, overriding the one
+// derived from the parent. It must be called before adding transactions.
+func (b *BlockGen) SetGasLimit(limit uint64) {
+	if b.gasPool != nil {
+		panic("gas limit must be set before adding transactions")
+	}
+	b.header.GasLimit = limit
+}
+
+// SetDifficulty overrides the difficulty calculated by the consensus engine.
+// OffsetTime recalculates the difficulty, so it must be called before.
+func (b *BlockGen) SetDifficulty(diff *big.Int) {
+	b.header.Difficulty = new(big.Int).Set(diff)
+}
+
+// SetLeader sets the leader of the generated block.
+func (b *BlockGen) SetLeader(addr common.Address) {
+	b.header.Leader = addr
+}
+
+// SetElect sets the election results carried by the generated block.
+func (b *BlockGen) SetElect(elect []common.Elect) {
+	b.header.Elect = elect
+}
+
+// SetNetTopology sets the network topology carried by the generated block.
+func (b *BlockGen) SetNetTopology(topology common.NetTopology) {
+	b.header.NetTopology = topology
+}
+
+// SetSigners sets the validator keys signing the generated block. The block is
+// signed once it is finalized, so that the signatures are made over the final
+// header and pass verification.
+func (b *BlockGen) SetSigners(keys ...*ecdsa.PrivateKey) {
+	b.signers = keys
+}
+
+// SetVersion sets the version field of the generated block.
+func (b *BlockGen) SetVersion(version []byte) {
+	b.header.Version = version
+}
+
 // AddTx adds a transaction to the generated block. If no coinbase has
 // been set, the block's coinbase is set to the zero address.
 //
@@ -111,6 +156,17 @@ func (b *BlockGen) Number() *big.Int {
 	return new(big.Int).Set(b.header.Number)
 }
 
+// Config returns the chain config the block is generated with, so generators
+// can decide on the block contents based on the active forks.
+func (b *BlockGen) Config() *params.ChainConfig {
+	return b.config
+}
+
+// Header returns a copy of the header of the block being generated.
+func (b *BlockGen) Header() *types.Header {
+	return types.CopyHeader(b.header)
+}
+
 // AddUncheckedReceipt forcefully adds a receipts to the block without a
 // backing transaction.
 //
@@ -211,6 +267,9 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 			if err := statedb.Database().TrieDB().Commit(root, false); err != nil {
 				panic(fmt.Sprintf("trie write error: %v", err))
 			}
+			if len(b.signers) > 0 {
+				block = signBlock(block, b.signers)
+			}
 			return block, b.receipts
 		}
 		return nil, nil
@@ -228,6 +287,22 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 	return blocks, receipts
 }
 
+// signBlock returns the block with its header signed by the given keys.
+func signBlock(block *types.Block, keys []*ecdsa.PrivateKey) *types.Block {
+	header := block.Header()
+	hash := header.HashNoSignsAndNonce()
+
+	header.Signatures = make([]common.Signature, len(keys))
+	for i, key := range keys {
+		sig, err := crypto.SignWithValidate(hash.Bytes(), true, key)
+		if err != nil {
+			panic(fmt.Sprintf("block signing error: %v", err))
+		}
+		header.Signatures[i] = common.BytesToSignature(sig)
+	}
+	return block.WithSeal(header)
+}
+
 func makeHeader(chain consensus.ChainReader, parent *types.Block, state *state.StateDB, engine consensus.Engine) *types.Header {
 	var time *big.Int
 	if parent.Time() == nil {
@@ -249,6 +324,7 @@ func makeHeader(chain consensus.ChainReader, parent *types.Block, state *state.S
 		GasLimit: CalcGasLimit(parent),
 		Number:   new(big.Int).Add(parent.Number(), common.Big1),
 		Time:     time,
+		BaseFee:  misc.CalcBaseFee(chain.Config(), parent.Header()),
 	}
 }
 
@@ -17,9 +17,13 @@
 package core
 
 import (
+	"bytes"
+	"crypto/ecdsa"
 	"fmt"
 	"math/big"
+	"testing"
 
+	"github.com/matrix/go-matrix/common"
 	"github.com/matrix/go-matrix/consensus/manash"
 	"github.com/matrix/go-matrix/core/types"
 	"github.com/matrix/go-matrix/core/vm"
@@ -98,3 +102,98 @@ func ExampleGenerateChain() {
 	// balance of addr2: 10000
 	// balance of addr3: 19687500000000001000
 }
+
+// Tests that the consensus fields scripted through the block generator end up
+// in the generated blocks, and that generators can react to fork transitions.
+func TestGenerateChainHeaderFields(t *testing.T) {
+	var (
+		db      = mandb.NewMemDatabase()
+		config  = &params.ChainConfig{HomesteadBlock: new(big.Int), EIP158Block: big.NewInt(2)}
+		genesis = (&Genesis{Config: config}).MustCommit(db)
+		leader  = common.HexToAddress("0x0100000000000000000000000000000000000000")
+		elect   = []common.Elect{{Account: leader, Stock: 1, Type: common.RoleValidator}}
+	)
+	chain, _ := GenerateChain(config, genesis, manash.NewFaker(), db, 3, func(i int, gen *BlockGen) {
+		if !gen.Config().IsEIP158(gen.Number()) {
+			return
+		}
+		gen.SetGasLimit(5000000)
+		gen.SetDifficulty(big.NewInt(12345))
+		gen.SetLeader(leader)
+		gen.SetElect(elect)
+		gen.SetVersion([]byte("1.0.0"))
+		if gen.Header().Leader != leader {
+			t.Errorf("block %d: leader not visible in pending header", i)
+		}
+	})
+	for i, block := range chain {
+		header := block.Header()
+		forked := config.IsEIP158(header.Number)
+		if have := header.Leader == leader; have != forked {
+			t.Errorf("block %d: leader set %v, want %v", i, have, forked)
+		}
+		if !forked {
+			continue
+		}
+		if header.GasLimit != 5000000 {
+			t.Errorf("block %d: gas limit mismatch: have %d, want %d", i, header.GasLimit, 5000000)
+		}
+		if header.Difficulty.Cmp(big.NewInt(12345)) != 0 {
+			t.Errorf("block %d: difficulty mismatch: have %v, want %v", i, header.Difficulty, 12345)
+		}
+		if len(header.Elect) != 1 || header.Elect[0] != elect[0] {
+			t.Errorf("block %d: elect mismatch: have %v, want %v", i, header.Elect, elect)
+		}
+		if !bytes.Equal(header.Version, []byte("1.0.0")) {
+			t.Errorf("block %d: version mismatch: have %q", i, header.Version)
+		}
+	}
+}
+
+// Tests that blocks signed through the block generator carry signatures over
+// the final header, verifying to the signing validators.
+func TestGenerateChainSigners(t *testing.T) {
+	var (
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		db      = mandb.NewMemDatabase()
+		genesis = new(Genesis).MustCommit(db)
+	)
+	chain, _ := GenerateChain(params.TestChainConfig, genesis, manash.NewFaker(), db, 3, func(i int, gen *BlockGen) {
+		gen.SetLeader(crypto.PubkeyToAddress(key1.PublicKey))
+		gen.SetExtra([]byte{byte(i)})
+		gen.SetSigners(key1, key2)
+	})
+	for i, block := range chain {
+		accounts := block.Header().SignAccounts()
+		if len(accounts) != 2 {
+			t.Fatalf("block %d: verified signature count mismatch: have %d, want 2", i, len(accounts))
+		}
+		signed := make(map[common.Address]bool)
+		for _, account := range accounts {
+			signed[account] = true
+		}
+		for _, key := range []*ecdsa.PrivateKey{key1, key2} {
+			if addr := crypto.PubkeyToAddress(key.PublicKey); !signed[addr] {
+				t.Errorf("block %d: missing signature of %x", i, addr)
+			}
+		}
+		if i > 0 && block.ParentHash() != chain[i-1].Hash() {
+			t.Errorf("block %d: parent hash mismatch: have %x, want %x", i, block.ParentHash(), chain[i-1].Hash())
+		}
+	}
+	// The generated chain must import into a test chain, without a running ca
+	// identity to check the signers against
+	importdb := mandb.NewMemDatabase()
+	new(Genesis).MustCommit(importdb)
+
+	blockchain, _ := NewBlockChain(importdb, nil, params.TestChainConfig, manash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	if i, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to import block %d: %v", i, err)
+	}
+	if head := blockchain.CurrentBlock().Hash(); head != chain[len(chain)-1].Hash() {
+		t.Errorf("head mismatch: have %x, want %x", head, chain[len(chain)-1].Hash())
+	}
+}
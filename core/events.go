@@ -18,12 +18,20 @@ package core
 
 import (
 	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core/state"
 	"github.com/matrix/go-matrix/core/types"
 )
 
 // NewTxsEvent is posted when a batch of transactions enter the transaction pool.
 type NewTxsEvent struct{ Txs []*types.Transaction }
 
+// DroppedTxsEvent is posted when a batch of transactions is dropped from the
+// transaction pool without being included in a block.
+type DroppedTxsEvent struct {
+	Txs    []*types.Transaction
+	Reason string // "expired" or "flushed"
+}
+
 //type NewSNEvent struct{ SN map[*big.Int]uint32 } //by hezi
 
 // PendingLogsEvent is posted pre mining and notifies of pending logs.
@@ -51,3 +59,10 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// BalanceChangeEvent is posted after a canonical block is written, carrying the
+// account balances changed by its transactions and finalization.
+type BalanceChangeEvent struct {
+	Block   *types.Block
+	Changes []*state.BalanceChange
+}
@@ -23,6 +23,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"strings"
 
 	"github.com/matrix/go-matrix/common"
@@ -31,10 +32,12 @@ import (
 	"github.com/matrix/go-matrix/core/rawdb"
 	"github.com/matrix/go-matrix/core/state"
 	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/crypto"
 	"github.com/matrix/go-matrix/mandb"
 	"github.com/matrix/go-matrix/log"
 	"github.com/matrix/go-matrix/params"
 	"github.com/matrix/go-matrix/rlp"
+	"github.com/matrix/go-matrix/trie"
 )
 
 //go:generate gencodec -type Genesis -field-override genesisSpecMarshaling -out gen_genesis.go
@@ -83,6 +86,76 @@ func (ga *GenesisAlloc) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// flush computes the state root of the allocation with stack tries, without
+// building the state in memory. If db is not nil, the trie nodes, contract
+// codes and key preimages are written to it as a state database commit would.
+func (ga GenesisAlloc) flush(db mandb.Putter) (common.Hash, error) {
+	type entry struct {
+		key   common.Hash
+		value []byte
+	}
+	sorted := func(entries []entry) []entry {
+		sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].key[:], entries[j].key[:]) < 0 })
+		return entries
+	}
+	var preimages map[common.Hash][]byte
+	if db != nil {
+		preimages = make(map[common.Hash][]byte)
+	}
+	accounts := make([]entry, 0, len(ga))
+	for addr, account := range ga {
+		var slots []entry
+		for key, value := range account.Storage {
+			if value == (common.Hash{}) {
+				continue
+			}
+			enc, _ := rlp.EncodeToBytes(bytes.TrimLeft(value[:], "\x00"))
+			hash := crypto.Keccak256Hash(key[:])
+			slots = append(slots, entry{hash, enc})
+			if preimages != nil {
+				preimages[hash] = common.CopyBytes(key[:])
+			}
+		}
+		storage := trie.NewStackTrie(db)
+		for _, slot := range sorted(slots) {
+			storage.Update(slot.key[:], slot.value)
+		}
+		storageRoot, err := storage.Commit()
+		if err != nil {
+			return common.Hash{}, err
+		}
+		codeHash := crypto.Keccak256(account.Code)
+		if db != nil && len(account.Code) > 0 {
+			if err := db.Put(codeHash, account.Code); err != nil {
+				return common.Hash{}, err
+			}
+		}
+		balance := account.Balance
+		if balance == nil {
+			balance = new(big.Int)
+		}
+		enc, _ := rlp.EncodeToBytes(&state.Account{
+			Nonce:    account.Nonce | params.NonceAddOne, // as stored by the state database
+			Balance:  balance,
+			Root:     storageRoot,
+			CodeHash: codeHash,
+		})
+		hash := crypto.Keccak256Hash(addr[:])
+		accounts = append(accounts, entry{hash, enc})
+		if preimages != nil {
+			preimages[hash] = common.CopyBytes(addr[:])
+		}
+	}
+	root := trie.NewStackTrie(db)
+	for _, account := range sorted(accounts) {
+		root.Update(account.key[:], account.value)
+	}
+	if db != nil {
+		rawdb.WritePreimages(db, 0, preimages)
+	}
+	return root.Commit()
+}
+
 // GenesisAccount is an account in the state of the genesis block.
 type GenesisAccount struct {
 	Code       []byte                      `json:"code,omitempty"`
@@ -211,6 +284,37 @@ func SetupGenesisBlock(db mandb.Database, genesis *Genesis) (*params.ChainConfig
 	return newcfg, stored, nil
 }
 
+// CheckGenesisBlock verifies, without writing anything, that the genesis block
+// and chain configuration stored in db are the ones SetupGenesisBlock would
+// accept for the given genesis, returning the stored configuration and hash.
+// ErrNoGenesis is returned if the database has not been initialised yet.
+func CheckGenesisBlock(db mandb.Database, genesis *Genesis) (*params.ChainConfig, common.Hash, error) {
+	stored := rawdb.ReadCanonicalHash(db, 0)
+	if (stored == common.Hash{}) {
+		return nil, stored, ErrNoGenesis
+	}
+	if genesis != nil {
+		if hash := genesis.ToBlock(nil).Hash(); hash != stored {
+			return nil, stored, &GenesisMismatchError{stored, hash}
+		}
+	}
+	storedcfg := rawdb.ReadChainConfig(db, stored)
+	if storedcfg == nil {
+		return nil, stored, fmt.Errorf("genesis block %x has no chain config", stored[:8])
+	}
+	if genesis == nil && stored != params.MainnetGenesisHash {
+		return storedcfg, stored, nil
+	}
+	height := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadHeaderHash(db))
+	if height == nil {
+		return storedcfg, stored, fmt.Errorf("missing block number for head header hash")
+	}
+	if compatErr := storedcfg.CheckCompatible(genesis.configOrDefault(stored), *height); compatErr != nil && *height != 0 && compatErr.RewindTo != 0 {
+		return storedcfg, stored, compatErr
+	}
+	return storedcfg, stored, nil
+}
+
 func (g *Genesis) configOrDefault(ghash common.Hash) *params.ChainConfig {
 	switch {
 	case g != nil:
@@ -227,19 +331,10 @@ func (g *Genesis) configOrDefault(ghash common.Hash) *params.ChainConfig {
 // ToBlock creates the genesis block and writes state of a genesis specification
 // to the given database (or discards it if nil).
 func (g *Genesis) ToBlock(db mandb.Database) *types.Block {
-	if db == nil {
-		db = mandb.NewMemDatabase()
-	}
-	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
-	for addr, account := range g.Alloc {
-		statedb.AddBalance(addr, account.Balance)
-		statedb.SetCode(addr, account.Code)
-		statedb.SetNonce(addr, account.Nonce)
-		for key, value := range account.Storage {
-			statedb.SetState(addr, key, value)
-		}
+	root, err := g.Alloc.flush(db)
+	if err != nil {
+		log.Crit("Failed to write genesis state", "err", err)
 	}
-	root := statedb.IntermediateRoot(false)
 	head := &types.Header{
 		Number:      new(big.Int).SetUint64(g.Number),
 		Nonce:       types.EncodeNonce(g.Nonce),
@@ -264,9 +359,6 @@ func (g *Genesis) ToBlock(db mandb.Database) *types.Block {
 	if g.Difficulty == nil {
 		head.Difficulty = params.GenesisDifficulty
 	}
-	statedb.Commit(false)
-	statedb.Database().TrieDB().Commit(root, true)
-
 	return types.NewBlock(head, nil, nil, nil)
 }
 
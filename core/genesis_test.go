@@ -17,6 +17,7 @@
 package core
 
 import (
+	"bytes"
 	"math/big"
 	"reflect"
 	"testing"
@@ -25,7 +26,9 @@ import (
 	"github.com/matrix/go-matrix/common"
 	"github.com/matrix/go-matrix/consensus/manash"
 	"github.com/matrix/go-matrix/core/rawdb"
+	"github.com/matrix/go-matrix/core/state"
 	"github.com/matrix/go-matrix/core/vm"
+	"github.com/matrix/go-matrix/crypto"
 	"github.com/matrix/go-matrix/mandb"
 	"github.com/matrix/go-matrix/params"
 )
@@ -162,3 +165,94 @@ func TestSetupGenesis(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckGenesisBlock(t *testing.T) {
+	db := mandb.NewMemDatabase()
+	if _, _, err := CheckGenesisBlock(db, nil); err != ErrNoGenesis {
+		t.Fatalf("empty database: have error %v, want %v", err, ErrNoGenesis)
+	}
+	genesis := DefaultTestnetGenesisBlock()
+	hash := genesis.MustCommit(db).Hash()
+
+	config, stored, err := CheckGenesisBlock(db, genesis)
+	if err != nil {
+		t.Fatalf("matching genesis: unexpected error %v", err)
+	}
+	if stored != hash || !reflect.DeepEqual(config, genesis.Config) {
+		t.Errorf("matching genesis: have %x %v, want %x %v", stored, config, hash, genesis.Config)
+	}
+	if _, _, err := CheckGenesisBlock(db, DefaultRinkebyGenesisBlock()); err == nil {
+		t.Errorf("mismatching genesis: expected error")
+	} else if _, ok := err.(*GenesisMismatchError); !ok {
+		t.Errorf("mismatching genesis: have error %T, want *GenesisMismatchError", err)
+	}
+	// The check must not initialise an empty database
+	empty := mandb.NewMemDatabase()
+	CheckGenesisBlock(empty, genesis)
+	if h := rawdb.ReadCanonicalHash(empty, 0); h != (common.Hash{}) {
+		t.Errorf("check wrote genesis block %x", h)
+	}
+}
+
+func TestGenesisAllocHash(t *testing.T) {
+	alloc := GenesisAlloc{
+		common.HexToAddress("0x01"): {Balance: big.NewInt(1)},
+		common.HexToAddress("0x02"): {Balance: big.NewInt(0), Nonce: 5, Code: []byte{0x60, 0x00}},
+		common.HexToAddress("0x03"): {
+			Balance: big.NewInt(3),
+			Storage: map[common.Hash]common.Hash{
+				common.HexToHash("0x01"): common.HexToHash("0x02"),
+				common.HexToHash("0x02"): {},
+				common.HexToHash("0xff"): common.HexToHash("0xffffffff"),
+			},
+		},
+	}
+	for i := 0; i < 100; i++ {
+		alloc[common.BigToAddress(big.NewInt(int64(0x100+i)))] = GenesisAccount{Balance: big.NewInt(int64(i))}
+	}
+	// Build the allocation in a state database for reference
+	reference, _ := state.New(common.Hash{}, state.NewDatabase(mandb.NewMemDatabase()))
+	for addr, account := range alloc {
+		reference.AddBalance(addr, account.Balance)
+		reference.SetCode(addr, account.Code)
+		reference.SetNonce(addr, account.Nonce)
+		for key, value := range account.Storage {
+			reference.SetState(addr, key, value)
+		}
+	}
+	want := reference.IntermediateRoot(false)
+
+	// Both the hashing and the committing path build the same root with stack tries
+	genesis := &Genesis{Alloc: alloc}
+	if have := genesis.ToBlock(nil).Root(); have != want {
+		t.Errorf("hashed state root mismatch: have %x, want %x", have, want)
+	}
+	db := mandb.NewMemDatabase()
+	if have := genesis.ToBlock(db).Root(); have != want {
+		t.Fatalf("committed state root mismatch: have %x, want %x", have, want)
+	}
+	// The committed state is readable through a state database
+	statedb, err := state.New(want, state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to open committed state: %v", err)
+	}
+	for addr, account := range alloc {
+		if have, want := statedb.GetBalance(addr), reference.GetBalance(addr); have.Cmp(want) != 0 {
+			t.Errorf("%x: balance mismatch: have %v, want %v", addr, have, want)
+		}
+		if have, want := statedb.GetNonce(addr), reference.GetNonce(addr); have != want {
+			t.Errorf("%x: nonce mismatch: have %d, want %d", addr, have, want)
+		}
+		if have := statedb.GetCode(addr); !bytes.Equal(have, account.Code) {
+			t.Errorf("%x: code mismatch: have %x, want %x", addr, have, account.Code)
+		}
+		for key, value := range account.Storage {
+			if have := statedb.GetState(addr, key); have != value {
+				t.Errorf("%x: slot %x mismatch: have %x, want %x", addr, key, have, value)
+			}
+		}
+		if preimage := rawdb.ReadPreimage(db, crypto.Keccak256Hash(addr[:])); !bytes.Equal(preimage, addr[:]) {
+			t.Errorf("%x: address preimage mismatch: have %x", addr, preimage)
+		}
+	}
+}
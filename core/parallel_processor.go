@@ -0,0 +1,152 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package core
+
+import (
+	"math"
+	"sync"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core/state"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/core/vm"
+	"github.com/matrix/go-matrix/metrics"
+	"github.com/matrix/go-matrix/params"
+)
+
+var (
+	parallelMergedMeter   = metrics.NewRegisteredMeter("chain/parallel/merged", nil)
+	parallelConflictMeter = metrics.NewRegisteredMeter("chain/parallel/conflicts", nil)
+)
+
+// parallelWindowFactor is the number of transactions speculated per worker
+// before the results are validated and merged into the state.
+const parallelWindowFactor = 4
+
+// speculation is the outcome of executing a transaction on a private copy of
+// the state, waiting to be validated against the transactions before it.
+type speculation struct {
+	state   *state.StateDB
+	receipt *types.Receipt
+	gas     uint64 // Gas used by the transaction
+	pooled  uint64 // Gas taken from the block gas pool
+	err     error
+}
+
+// ApplyTransactions applies a list of transactions in order to the given state,
+// numbering them from txIndex onwards, and returns their receipts.
+//
+// If cfg.ParallelTxs allows it, transactions are executed speculatively in
+// parallel on copies of the state, recording the accounts and storage slots they
+// touch. The results are then validated in order: a transaction which did not
+// read anything written by the ones before it is merged into the state, while
+// conflicting or failing ones are re-executed serially. The outcome is the same
+// as applying the transactions one by one with ApplyTransaction.
+//
+// If a transaction fails, the receipts of the ones applied before it are
+// returned along with the error, matching the changes left in the state.
+func ApplyTransactions(config *params.ChainConfig, bc *BlockChain, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, bhash common.Hash, txs types.Transactions, txIndex int, usedGas *uint64, cfg vm.Config) (types.Receipts, error) {
+	receipts := make(types.Receipts, 0, len(txs))
+
+	// Intermediate roots can't be merged and tracers aren't thread safe, so
+	// those cases always run serially
+	workers := cfg.ParallelTxs
+	if workers < 2 || len(txs) < 2 || cfg.Debug || !config.IsByzantium(header.Number) {
+		for i, tx := range txs {
+			statedb.Prepare(tx.Hash(), bhash, txIndex+i)
+			receipt, _, err := ApplyTransaction(config, bc, author, gp, statedb, header, tx, usedGas, cfg)
+			if err != nil {
+				return receipts, err
+			}
+			receipts = append(receipts, receipt)
+		}
+		return receipts, nil
+	}
+	for start := 0; start < len(txs); start += workers * parallelWindowFactor {
+		end := start + workers*parallelWindowFactor
+		if end > len(txs) {
+			end = len(txs)
+		}
+		// Speculate the whole window on top of the current state
+		specs := make([]*speculation, end-start)
+
+		var (
+			pend sync.WaitGroup
+			sem  = make(chan struct{}, workers)
+		)
+		for i := start; i < end; i++ {
+			pend.Add(1)
+			go func(i int) {
+				defer pend.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				specs[i-start] = speculate(config, bc, author, statedb, header, bhash, txs[i], txIndex+i, cfg)
+			}(i)
+		}
+		pend.Wait()
+
+		// Merge the speculations in order, re-executing the invalidated ones
+		written := state.NewAccessSet()
+		for i, spec := range specs {
+			tx := txs[start+i]
+			if spec.err == nil && !spec.state.AccessReads().Conflicts(written) && (spec.pooled == 0 || gp.Gas() >= tx.Gas()) {
+				gp.SubGas(spec.pooled)
+				statedb.Prepare(tx.Hash(), bhash, txIndex+start+i)
+				statedb.MergeSpeculative(spec.state)
+				statedb.Finalise(true)
+
+				*usedGas += spec.gas
+				spec.receipt.CumulativeGasUsed = *usedGas
+				receipts = append(receipts, spec.receipt)
+
+				written.Merge(spec.state.AccessWrites())
+				parallelMergedMeter.Mark(1)
+				continue
+			}
+			statedb.Prepare(tx.Hash(), bhash, txIndex+start+i)
+			statedb.StartAccessTracking()
+			receipt, _, err := ApplyTransaction(config, bc, author, gp, statedb, header, tx, usedGas, cfg)
+			_, writes := statedb.StopAccessTracking()
+			if err != nil {
+				return receipts, err
+			}
+			receipts = append(receipts, receipt)
+
+			written.Merge(writes)
+			parallelConflictMeter.Mark(1)
+		}
+	}
+	return receipts, nil
+}
+
+// speculate executes a transaction on a private copy of the state, tracking the
+// accounts and storage slots it accesses.
+func speculate(config *params.ChainConfig, bc *BlockChain, author *common.Address, statedb *state.StateDB, header *types.Header, bhash common.Hash, tx *types.Transaction, index int, cfg vm.Config) *speculation {
+	spec := &speculation{state: statedb.Copy()}
+	spec.state.Prepare(tx.Hash(), bhash, index)
+	spec.state.StartAccessTracking()
+
+	// The real gas pool is checked when merging, speculate with an unlimited one
+	var (
+		gp      = new(GasPool).AddGas(math.MaxUint64)
+		usedGas uint64
+	)
+	spec.receipt, spec.gas, spec.err = ApplyTransaction(config, bc, author, gp, spec.state, header, tx, &usedGas, cfg)
+	spec.pooled = math.MaxUint64 - gp.Gas()
+	return spec
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/log"
+)
+
+// PinnedBlock is a block the local chain must contain. Chains with another
+// block at its number are refused, which lets the operators of a network
+// agree on the branch to follow after a deep fork.
+type PinnedBlock struct {
+	Number uint64
+	Hash   common.Hash
+}
+
+// ParsePinnedBlock parses a pinned block given as <hash>:<number>.
+func ParsePinnedBlock(s string) (*PinnedBlock, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid pinned block %q, want <hash>:<number>", s)
+	}
+	hash, err := hexutil.Decode("0x" + strings.TrimPrefix(parts[0], "0x"))
+	if err != nil || len(hash) != common.HashLength {
+		return nil, fmt.Errorf("invalid pinned block hash %q", parts[0])
+	}
+	number, err := strconv.ParseUint(parts[1], 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pinned block number %q", parts[1])
+	}
+	return &PinnedBlock{Number: number, Hash: common.BytesToHash(hash)}, nil
+}
+
+func (pin *PinnedBlock) String() string {
+	return fmt.Sprintf("%x:%d", pin.Hash, pin.Number)
+}
+
+// Conflicts reports whether the header is another block at the pinned number.
+// A nil pinned block conflicts with nothing, and nothing conflicts with a
+// missing header, as when the local chain has not reached the pin yet.
+func (pin *PinnedBlock) Conflicts(header *types.Header) bool {
+	return pin != nil && header != nil && header.Number.Uint64() == pin.Number && header.Hash() != pin.Hash
+}
+
+// SetPinnedBlock pins the block the chain must contain. If the canonical
+// chain holds another block at its number, the chain is rewound below it so
+// that the pinned branch can be synced. It must be called before blocks are
+// imported.
+func (bc *BlockChain) SetPinnedBlock(pin *PinnedBlock) error {
+	if pin.Number == 0 && pin.Hash != bc.genesisBlock.Hash() {
+		return fmt.Errorf("pinned block %v is not the genesis block %x", pin, bc.genesisBlock.Hash())
+	}
+	bc.pinned = pin
+	if header := bc.GetHeaderByNumber(pin.Number); pin.Conflicts(header) {
+		log.Warn("Canonical chain conflicts with the pinned block, rewinding", "number", pin.Number, "hash", header.Hash(), "pinned", pin.Hash)
+		return bc.SetHead(pin.Number - 1)
+	}
+	return nil
+}
+
+// PinnedBlock returns the block the chain must contain, nil if none.
+func (bc *BlockChain) PinnedBlock() *PinnedBlock {
+	return bc.pinned
+}
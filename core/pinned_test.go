@@ -0,0 +1,100 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/consensus/manash"
+	"github.com/matrix/go-matrix/core/types"
+)
+
+func TestParsePinnedBlock(t *testing.T) {
+	hash := common.HexToHash("0x05bef30ef572270f654746da22639a7a0c97dd97a7050b9e252391996aaeb689")
+	tests := []struct {
+		input string
+		pin   *PinnedBlock
+	}{
+		{input: hash.Hex() + ":1920000", pin: &PinnedBlock{1920000, hash}},
+		{input: hash.Hex()[2:] + ":0x10", pin: &PinnedBlock{16, hash}},
+		{input: hash.Hex()},
+		{input: hash.Hex() + ":"},
+		{input: hash.Hex() + ":-1"},
+		{input: hash.Hex()[:10] + ":1"},
+		{input: "0xzz" + hash.Hex()[4:] + ":1"},
+		{input: hash.Hex() + ":1:2"},
+	}
+	for _, test := range tests {
+		pin, err := ParsePinnedBlock(test.input)
+		if test.pin == nil {
+			if err == nil {
+				t.Errorf("%q: expected error, got %v", test.input, pin)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.input, err)
+		} else if *pin != *test.pin {
+			t.Errorf("%q: pinned block mismatch: have %v, want %v", test.input, pin, test.pin)
+		}
+	}
+}
+
+func TestPinnedBlockConflicts(t *testing.T) {
+	header := &types.Header{Number: big.NewInt(10)}
+	other := &types.Header{Number: big.NewInt(10), Extra: []byte("other branch")}
+	pin := &PinnedBlock{Number: 10, Hash: header.Hash()}
+
+	if pin.Conflicts(header) {
+		t.Error("pinned block conflicts with itself")
+	}
+	if !pin.Conflicts(other) {
+		t.Error("other block at the pinned number does not conflict")
+	}
+	if pin.Conflicts(&types.Header{Number: big.NewInt(11)}) {
+		t.Error("block at another number conflicts")
+	}
+	if (*PinnedBlock)(nil).Conflicts(other) {
+		t.Error("nil pinned block conflicts")
+	}
+	if pin.Conflicts(nil) {
+		t.Error("missing header conflicts")
+	}
+}
+
+// Tests that a block can be pinned above the head of a chain that has not
+// reached it yet, as on a freshly started node.
+func TestSetPinnedBlockAboveHead(t *testing.T) {
+	_, blockchain, err := newCanonical(manash.NewFaker(), 5, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	pin := &PinnedBlock{Number: 100, Hash: common.HexToHash("0x05bef30ef572270f654746da22639a7a0c97dd97a7050b9e252391996aaeb689")}
+	if err := blockchain.SetPinnedBlock(pin); err != nil {
+		t.Fatalf("failed to pin block above the head: %v", err)
+	}
+	if head := blockchain.CurrentBlock().NumberU64(); head != 5 {
+		t.Errorf("chain head moved: have %d, want 5", head)
+	}
+	if blockchain.PinnedBlock() != pin {
+		t.Errorf("pinned block mismatch: have %v, want %v", blockchain.PinnedBlock(), pin)
+	}
+}
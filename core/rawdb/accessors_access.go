@@ -0,0 +1,109 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/log"
+)
+
+// The state access index records the number of the last block accessing each
+// account and storage slot, along with the number of entries last accessed in
+// every epoch of blocks. Entries are cleared by overwriting them with an empty
+// value, so that whole blocks can be indexed within a single batch.
+
+// ReadAccountAccess retrieves the number of the block last accessing an account.
+func ReadAccountAccess(db DatabaseReader, addr common.Address) (uint64, bool) {
+	data, _ := db.Get(append(accountAccessPrefix, addr.Bytes()...))
+	if len(data) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(data), true
+}
+
+// WriteAccountAccess stores the number of the block last accessing an account.
+func WriteAccountAccess(db DatabaseWriter, addr common.Address, number uint64) {
+	if err := db.Put(append(accountAccessPrefix, addr.Bytes()...), encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store account access", "err", err)
+	}
+}
+
+// ClearAccountAccess removes the access entry of an account.
+func ClearAccountAccess(db DatabaseWriter, addr common.Address) {
+	if err := db.Put(append(accountAccessPrefix, addr.Bytes()...), nil); err != nil {
+		log.Crit("Failed to clear account access", "err", err)
+	}
+}
+
+// ReadSlotAccess retrieves the number of the block last accessing a storage slot.
+func ReadSlotAccess(db DatabaseReader, addr common.Address, slot common.Hash) (uint64, bool) {
+	data, _ := db.Get(append(append(slotAccessPrefix, addr.Bytes()...), slot.Bytes()...))
+	if len(data) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(data), true
+}
+
+// WriteSlotAccess stores the number of the block last accessing a storage slot.
+func WriteSlotAccess(db DatabaseWriter, addr common.Address, slot common.Hash, number uint64) {
+	key := append(append(slotAccessPrefix, addr.Bytes()...), slot.Bytes()...)
+	if err := db.Put(key, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store slot access", "err", err)
+	}
+}
+
+// ClearSlotAccess removes the access entry of a storage slot.
+func ClearSlotAccess(db DatabaseWriter, addr common.Address, slot common.Hash) {
+	key := append(append(slotAccessPrefix, addr.Bytes()...), slot.Bytes()...)
+	if err := db.Put(key, nil); err != nil {
+		log.Crit("Failed to clear slot access", "err", err)
+	}
+}
+
+// ReadAccountEpochCount retrieves the number of accounts last accessed within an epoch.
+func ReadAccountEpochCount(db DatabaseReader, epoch uint64) uint64 {
+	data, _ := db.Get(append(accountEpochPrefix, encodeBlockNumber(epoch)...))
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteAccountEpochCount stores the number of accounts last accessed within an epoch.
+func WriteAccountEpochCount(db DatabaseWriter, epoch uint64, count uint64) {
+	if err := db.Put(append(accountEpochPrefix, encodeBlockNumber(epoch)...), encodeBlockNumber(count)); err != nil {
+		log.Crit("Failed to store account epoch count", "err", err)
+	}
+}
+
+// ReadSlotEpochCount retrieves the number of storage slots last accessed within an epoch.
+func ReadSlotEpochCount(db DatabaseReader, epoch uint64) uint64 {
+	data, _ := db.Get(append(slotEpochPrefix, encodeBlockNumber(epoch)...))
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteSlotEpochCount stores the number of storage slots last accessed within an epoch.
+func WriteSlotEpochCount(db DatabaseWriter, epoch uint64, count uint64) {
+	if err := db.Put(append(slotEpochPrefix, encodeBlockNumber(epoch)...), encodeBlockNumber(count)); err != nil {
+		log.Crit("Failed to store slot epoch count", "err", err)
+	}
+}
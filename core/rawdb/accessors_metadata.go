@@ -88,3 +88,28 @@ func WritePreimages(db DatabaseWriter, number uint64, preimages map[common.Hash]
 	preimageCounter.Inc(int64(len(preimages)))
 	preimageHitCounter.Inc(int64(len(preimages)))
 }
+
+// ReadABIDefinitions retrieves the JSON ABIs registered for contracts.
+func ReadABIDefinitions(db DatabaseReader) map[common.Address]string {
+	data, _ := db.Get(abiDefinitionsKey)
+	if len(data) == 0 {
+		return nil
+	}
+	var definitions map[common.Address]string
+	if err := json.Unmarshal(data, &definitions); err != nil {
+		log.Error("Invalid ABI definitions JSON", "err", err)
+		return nil
+	}
+	return definitions
+}
+
+// WriteABIDefinitions stores the JSON ABIs registered for contracts.
+func WriteABIDefinitions(db DatabaseWriter, definitions map[common.Address]string) {
+	data, err := json.Marshal(definitions)
+	if err != nil {
+		log.Crit("Failed to JSON encode ABI definitions", "err", err)
+	}
+	if err := db.Put(abiDefinitionsKey, data); err != nil {
+		log.Crit("Failed to store ABI definitions", "err", err)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/rlp"
+)
+
+// Flags of TraceAddressEntry telling the roles of an address in the call frames
+// of a block.
+const (
+	TraceAddressFrom = 1 << iota // The address made calls
+	TraceAddressTo               // The address was called, created or paid by a self destruct
+)
+
+// TraceAddressEntry is a block containing call frames of an address, stored in
+// the sections of the address in the tracing index.
+type TraceAddressEntry struct {
+	Number uint64
+	Hash   common.Hash
+	Flags  uint8
+}
+
+// ReadTraceFrames retrieves the call frames of the transactions of a block.
+func ReadTraceFrames(db DatabaseReader, hash common.Hash, number uint64) []*types.TxCallFrames {
+	data, _ := db.Get(append(append(traceFramesPrefix, encodeBlockNumber(number)...), hash[:]...))
+	if len(data) == 0 {
+		return nil
+	}
+	var frames []*types.TxCallFrames
+	if err := rlp.DecodeBytes(data, &frames); err != nil {
+		log.Error("Invalid trace frames RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return frames
+}
+
+// WriteTraceFrames stores the call frames of the transactions of a block.
+func WriteTraceFrames(db DatabaseWriter, hash common.Hash, number uint64, frames []*types.TxCallFrames) {
+	data, err := rlp.EncodeToBytes(frames)
+	if err != nil {
+		log.Crit("Failed to RLP encode trace frames", "err", err)
+	}
+	if err := db.Put(append(append(traceFramesPrefix, encodeBlockNumber(number)...), hash[:]...), data); err != nil {
+		log.Crit("Failed to store trace frames", "err", err)
+	}
+}
+
+// ReadTraceAddressSection retrieves the blocks of a section containing call
+// frames of an address, in the order they were indexed.
+func ReadTraceAddressSection(db DatabaseReader, addr common.Address, section uint64) []TraceAddressEntry {
+	data, _ := db.Get(append(append(traceAddressPrefix, addr.Bytes()...), encodeBlockNumber(section)...))
+	if len(data) == 0 {
+		return nil
+	}
+	var entries []TraceAddressEntry
+	if err := rlp.DecodeBytes(data, &entries); err != nil {
+		log.Error("Invalid trace address section RLP", "address", addr, "section", section, "err", err)
+		return nil
+	}
+	return entries
+}
+
+// WriteTraceAddressSection stores the blocks of a section containing call
+// frames of an address.
+func WriteTraceAddressSection(db DatabaseWriter, addr common.Address, section uint64, entries []TraceAddressEntry) {
+	data, err := rlp.EncodeToBytes(entries)
+	if err != nil {
+		log.Crit("Failed to RLP encode trace address section", "err", err)
+	}
+	if err := db.Put(append(append(traceAddressPrefix, addr.Bytes()...), encodeBlockNumber(section)...), data); err != nil {
+		log.Crit("Failed to store trace address section", "err", err)
+	}
+}
+
+// ReadTraceIndexTail retrieves the first block of the range of the tracing
+// index ending at the head.
+func ReadTraceIndexTail(db DatabaseReader) (uint64, bool) {
+	return readTraceIndexNumber(db, traceIndexTailKey)
+}
+
+// WriteTraceIndexTail stores the first block of the range of the tracing index
+// ending at the head.
+func WriteTraceIndexTail(db DatabaseWriter, number uint64) {
+	if err := db.Put(traceIndexTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store trace index tail", "err", err)
+	}
+}
+
+// ReadTraceIndexHead retrieves the last canonical block indexed by the tracing
+// index.
+func ReadTraceIndexHead(db DatabaseReader) (uint64, bool) {
+	return readTraceIndexNumber(db, traceIndexHeadKey)
+}
+
+// WriteTraceIndexHead stores the last canonical block indexed by the tracing
+// index.
+func WriteTraceIndexHead(db DatabaseWriter, number uint64) {
+	if err := db.Put(traceIndexHeadKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store trace index head", "err", err)
+	}
+}
+
+func readTraceIndexNumber(db DatabaseReader, key []byte) (uint64, bool) {
+	data, _ := db.Get(key)
+	if len(data) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(data), true
+}
@@ -59,6 +59,21 @@ var (
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
 
+	// State access index prefixes (use `x` + single byte, the index is optional).
+	accountAccessPrefix = []byte("xa") // accountAccessPrefix + address -> last access num (uint64 big endian)
+	slotAccessPrefix    = []byte("xs") // slotAccessPrefix + address + slot -> last access num (uint64 big endian)
+	accountEpochPrefix  = []byte("xA") // accountEpochPrefix + epoch (uint64 big endian) -> accounts last accessed
+	slotEpochPrefix     = []byte("xS") // slotEpochPrefix + epoch (uint64 big endian) -> slots last accessed
+
+	// Tracing index prefixes and keys (use `x` + single byte, the index is optional).
+	traceFramesPrefix  = []byte("xt")             // traceFramesPrefix + num (uint64 big endian) + hash -> call frames of the block transactions
+	traceAddressPrefix = []byte("xT")             // traceAddressPrefix + address + section (uint64 big endian) -> blocks with frames of the address
+	traceIndexTailKey  = []byte("TraceIndexTail") // first block of the indexed range ending at the head
+	traceIndexHeadKey  = []byte("TraceIndexHead") // last canonical block indexed
+
+	// abiDefinitionsKey tracks the contract ABIs registered through the decoding API.
+	abiDefinitionsKey = []byte("ABIDefinitions")
+
 	preimageCounter    = metrics.NewRegisteredCounter("db/preimage/total", nil)
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
 )
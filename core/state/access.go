@@ -0,0 +1,267 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package state
+
+import (
+	"math/big"
+
+	"github.com/matrix/go-matrix/common"
+)
+
+// AccessSet is a set of accounts and storage slots touched by a transaction.
+// Account entries cover the account fields (nonce, balance, code, existence),
+// slot entries cover individual storage items.
+type AccessSet struct {
+	Accounts map[common.Address]struct{}
+	Slots    map[common.Address]map[common.Hash]struct{}
+}
+
+// NewAccessSet creates an empty access set.
+func NewAccessSet() *AccessSet {
+	return &AccessSet{
+		Accounts: make(map[common.Address]struct{}),
+		Slots:    make(map[common.Address]map[common.Hash]struct{}),
+	}
+}
+
+// AddAccount inserts an account into the set.
+func (s *AccessSet) AddAccount(addr common.Address) {
+	s.Accounts[addr] = struct{}{}
+}
+
+// AddSlot inserts a storage slot of an account into the set.
+func (s *AccessSet) AddSlot(addr common.Address, key common.Hash) {
+	slots, ok := s.Slots[addr]
+	if !ok {
+		slots = make(map[common.Hash]struct{})
+		s.Slots[addr] = slots
+	}
+	slots[key] = struct{}{}
+}
+
+// Merge inserts all the entries of another set into this one.
+func (s *AccessSet) Merge(other *AccessSet) {
+	for addr := range other.Accounts {
+		s.AddAccount(addr)
+	}
+	for addr, slots := range other.Slots {
+		for key := range slots {
+			s.AddSlot(addr, key)
+		}
+	}
+}
+
+// Conflicts reports whether a transaction having read the entries of this set
+// could have observed any of the given writes. Account writes invalidate both
+// the account and all its storage, since the account may have been recreated.
+func (s *AccessSet) Conflicts(writes *AccessSet) bool {
+	for addr := range writes.Accounts {
+		if _, ok := s.Accounts[addr]; ok {
+			return true
+		}
+		if len(s.Slots[addr]) > 0 {
+			return true
+		}
+	}
+	for addr, slots := range writes.Slots {
+		read := s.Slots[addr]
+		if len(read) == 0 {
+			continue
+		}
+		for key := range slots {
+			if _, ok := read[key]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// accessTracker records the state accessed while tracking is enabled.
+type accessTracker struct {
+	reads  *AccessSet
+	writes *AccessSet
+	resets map[common.Address]struct{} // accounts (re)created, with their storage wiped
+}
+
+// StartAccessTracking starts recording the accounts and storage slots read and
+// written through the state, dropping anything recorded previously.
+func (self *StateDB) StartAccessTracking() {
+	self.access = &accessTracker{
+		reads:  NewAccessSet(),
+		writes: NewAccessSet(),
+		resets: make(map[common.Address]struct{}),
+	}
+}
+
+// StopAccessTracking stops recording state accesses and returns the sets read
+// and written since tracking was started.
+func (self *StateDB) StopAccessTracking() (reads, writes *AccessSet) {
+	if self.access == nil {
+		return NewAccessSet(), NewAccessSet()
+	}
+	reads, writes = self.access.reads, self.access.writes
+	self.access = nil
+	return reads, writes
+}
+
+// AccessReads returns the accounts and slots read since tracking was started,
+// or nil if tracking is disabled.
+func (self *StateDB) AccessReads() *AccessSet {
+	if self.access == nil {
+		return nil
+	}
+	return self.access.reads
+}
+
+// AccessWrites returns the accounts and slots written since tracking was
+// started, or nil if tracking is disabled.
+func (self *StateDB) AccessWrites() *AccessSet {
+	if self.access == nil {
+		return nil
+	}
+	return self.access.writes
+}
+
+func (self *StateDB) trackRead(addr common.Address) {
+	self.trackTouch(addr)
+	if self.access != nil {
+		self.access.reads.AddAccount(addr)
+	}
+}
+
+// trackWrite records an account modification. Modifications are always based
+// on the previous account value, so they count as reads as well.
+func (self *StateDB) trackWrite(addr common.Address) {
+	self.trackTouch(addr)
+	if self.access != nil {
+		self.access.reads.AddAccount(addr)
+		self.access.writes.AddAccount(addr)
+	}
+}
+
+func (self *StateDB) trackReset(addr common.Address) {
+	self.trackTouch(addr)
+	if self.access != nil {
+		self.access.reads.AddAccount(addr)
+		self.access.writes.AddAccount(addr)
+		self.access.resets[addr] = struct{}{}
+	}
+}
+
+func (self *StateDB) trackSlotRead(addr common.Address, key common.Hash) {
+	self.trackSlotTouch(addr, key)
+	if self.access != nil {
+		self.access.reads.AddSlot(addr, key)
+	}
+}
+
+func (self *StateDB) trackSlotWrite(addr common.Address, key common.Hash) {
+	self.trackSlotTouch(addr, key)
+	if self.access != nil {
+		self.access.writes.AddSlot(addr, key)
+	}
+}
+
+// MergeSpeculative applies the changes made by a transaction speculatively
+// executed on a copy of this state, as recorded by the copy's access tracker.
+// The copy must have been finalised after the transaction, and the caller is
+// responsible for checking that the transaction did not read anything written
+// since the copy was made. The transaction's logs are renumbered to follow the
+// ones already in this state. The merged accounts are marked dirty, so the
+// state needs to be finalised afterwards.
+func (self *StateDB) MergeSpeculative(spec *StateDB) {
+	if spec.access == nil {
+		panic("speculative state without access tracking")
+	}
+	writes := spec.access.writes
+
+	// Recreated accounts replace the local object entirely, storage included
+	for addr := range spec.access.resets {
+		if obj := spec.stateObjects[addr]; obj != nil {
+			self.stateObjects[addr] = obj.deepCopy(self)
+		} else {
+			delete(self.stateObjects, addr)
+		}
+		self.journal.dirty(addr)
+	}
+	// Modified accounts only get their account fields overwritten
+	for addr := range writes.Accounts {
+		if _, ok := spec.access.resets[addr]; ok {
+			continue
+		}
+		obj := spec.stateObjects[addr]
+		if obj == nil {
+			continue
+		}
+		local := self.stateObjects[addr]
+		if local == nil {
+			local = self.getStateObject(addr)
+		}
+		if local == nil {
+			self.stateObjects[addr] = obj.deepCopy(self)
+		} else {
+			local.data.Nonce = obj.data.Nonce
+			local.data.Balance = new(big.Int).Set(obj.data.Balance)
+			local.data.CodeHash = common.CopyBytes(obj.data.CodeHash)
+			local.code = obj.code
+			local.dirtyCode = local.dirtyCode || obj.dirtyCode
+			local.suicided = obj.suicided
+			local.deleted = obj.deleted
+		}
+		self.journal.dirty(addr)
+	}
+	// Modified storage slots are copied one by one
+	for addr, slots := range writes.Slots {
+		if _, ok := spec.access.resets[addr]; ok {
+			continue
+		}
+		obj := spec.stateObjects[addr]
+		if obj == nil {
+			continue
+		}
+		local := self.getStateObject(addr)
+		if local == nil {
+			continue
+		}
+		for key := range slots {
+			local.setState(key, obj.GetState(spec.db, key))
+		}
+		self.journal.dirty(addr)
+	}
+	// Carry over the logs and preimages produced by the transaction
+	logs := spec.logs[spec.thash]
+	for _, l := range logs {
+		l.Index = self.logSize
+		self.logSize++
+	}
+	if len(logs) > 0 {
+		self.logs[spec.thash] = append(self.logs[spec.thash], logs...)
+	}
+	for hash, preimage := range spec.preimages {
+		if _, ok := self.preimages[hash]; !ok {
+			self.preimages[hash] = preimage
+		}
+	}
+	if self.balances != nil && spec.balances != nil {
+		self.balances.merge(spec.balances)
+	}
+	if self.touched != nil {
+		self.touched.Merge(spec.access.reads)
+		self.touched.Merge(spec.access.writes)
+	}
+}
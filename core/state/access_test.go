@@ -0,0 +1,161 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package state
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/mandb"
+)
+
+var (
+	accessAlice    = common.BytesToAddress([]byte{0x01})
+	accessBob      = common.BytesToAddress([]byte{0x02})
+	accessContract = common.BytesToAddress([]byte{0x03})
+	accessFresh    = common.BytesToAddress([]byte{0x04})
+)
+
+// newAccessTestState creates a committed state with two funded accounts and a
+// contract holding two storage slots.
+func newAccessTestState(t *testing.T) *StateDB {
+	db := NewDatabase(mandb.NewMemDatabase())
+	state, _ := New(common.Hash{}, db)
+	state.AddBalance(accessAlice, big.NewInt(1000))
+	state.AddBalance(accessBob, big.NewInt(1000))
+	state.SetCode(accessContract, []byte{0x60, 0x00})
+	state.SetState(accessContract, common.Hash{1}, common.Hash{1})
+	state.SetState(accessContract, common.Hash{2}, common.Hash{2})
+
+	root, err := state.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	state, err = New(root, db)
+	if err != nil {
+		t.Fatalf("failed to reopen state: %v", err)
+	}
+	return state
+}
+
+func TestAccessTracking(t *testing.T) {
+	state := newAccessTestState(t)
+
+	state.GetBalance(accessAlice)
+	state.StartAccessTracking()
+	state.GetNonce(accessBob)
+	state.GetState(accessContract, common.Hash{1})
+	state.SetState(accessContract, common.Hash{2}, common.Hash{3})
+	state.AddBalance(accessFresh, big.NewInt(1))
+	reads, writes := state.StopAccessTracking()
+
+	if _, ok := reads.Accounts[accessAlice]; ok {
+		t.Errorf("access before tracking recorded")
+	}
+	if _, ok := reads.Accounts[accessBob]; !ok {
+		t.Errorf("account read not recorded")
+	}
+	if _, ok := reads.Slots[accessContract][common.Hash{1}]; !ok {
+		t.Errorf("slot read not recorded")
+	}
+	if _, ok := writes.Slots[accessContract][common.Hash{2}]; !ok {
+		t.Errorf("slot write not recorded")
+	}
+	if _, ok := writes.Accounts[accessContract]; ok {
+		t.Errorf("slot write recorded as account write")
+	}
+	if _, ok := writes.Accounts[accessFresh]; !ok {
+		t.Errorf("account creation not recorded")
+	}
+	if state.AccessReads() != nil {
+		t.Errorf("tracking still enabled after stop")
+	}
+}
+
+func TestAccessConflicts(t *testing.T) {
+	account := func(addr common.Address) *AccessSet {
+		set := NewAccessSet()
+		set.AddAccount(addr)
+		return set
+	}
+	slot := func(addr common.Address, key common.Hash) *AccessSet {
+		set := NewAccessSet()
+		set.AddSlot(addr, key)
+		return set
+	}
+	tests := []struct {
+		reads, writes *AccessSet
+		conflict      bool
+	}{
+		{account(accessAlice), account(accessAlice), true},
+		{account(accessAlice), account(accessBob), false},
+		{slot(accessContract, common.Hash{1}), slot(accessContract, common.Hash{1}), true},
+		{slot(accessContract, common.Hash{1}), slot(accessContract, common.Hash{2}), false},
+		{slot(accessContract, common.Hash{1}), account(accessContract), true},
+		{account(accessContract), slot(accessContract, common.Hash{1}), false},
+	}
+	for i, tt := range tests {
+		if have := tt.reads.Conflicts(tt.writes); have != tt.conflict {
+			t.Errorf("test %d: conflict mismatch: have %v, want %v", i, have, tt.conflict)
+		}
+	}
+}
+
+func TestMergeSpeculative(t *testing.T) {
+	base := newAccessTestState(t)
+	serial := base.Copy()
+
+	// Two independent changes, speculated on copies of the same base state
+	first := func(state *StateDB) {
+		state.Prepare(common.Hash{0xaa}, common.Hash{}, 0)
+		state.SubBalance(accessAlice, big.NewInt(10))
+		state.AddBalance(accessFresh, big.NewInt(10))
+		state.SetState(accessContract, common.Hash{1}, common.Hash{0x11})
+		state.AddLog(&types.Log{Address: accessContract})
+		state.Finalise(true)
+	}
+	second := func(state *StateDB) {
+		state.Prepare(common.Hash{0xbb}, common.Hash{}, 1)
+		state.SubBalance(accessBob, big.NewInt(20))
+		state.SetState(accessContract, common.Hash{2}, common.Hash{0x22})
+		state.AddLog(&types.Log{Address: accessContract})
+		state.Finalise(true)
+	}
+	first(serial)
+	second(serial)
+
+	specs := []*StateDB{base.Copy(), base.Copy()}
+	for i, apply := range []func(*StateDB){first, second} {
+		specs[i].StartAccessTracking()
+		apply(specs[i])
+	}
+	if specs[1].AccessReads().Conflicts(specs[0].AccessWrites()) {
+		t.Fatalf("independent changes reported as conflicting")
+	}
+	for _, spec := range specs {
+		base.MergeSpeculative(spec)
+		base.Finalise(true)
+	}
+	if have, want := base.IntermediateRoot(true), serial.IntermediateRoot(true); have != want {
+		t.Fatalf("merged root mismatch: have %x, want %x", have, want)
+	}
+	if logs := base.GetLogs(common.Hash{0xbb}); len(logs) != 1 || logs[0].Index != 1 {
+		t.Fatalf("merged logs not renumbered: %v", logs)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package state
+
+import (
+	"math/big"
+
+	"github.com/matrix/go-matrix/common"
+)
+
+// BalanceChange is the balance of an account before and after a transaction.
+// Changes made outside of transactions, like block rewards applied by the
+// consensus engine, have an empty transaction hash.
+type BalanceChange struct {
+	Address common.Address
+	TxHash  common.Hash
+	Prev    *big.Int
+	Balance *big.Int
+}
+
+// txBalances holds the latest balances set by a transaction, keeping the order
+// in which the accounts were first changed.
+type txBalances struct {
+	addrs    []common.Address
+	balances map[common.Address]*big.Int
+}
+
+// balanceTracker records the balances set through the state while tracking is
+// enabled, grouped by the transaction setting them.
+type balanceTracker struct {
+	origin map[common.Address]*big.Int // Balances before the first change
+	order  []common.Hash               // Transactions in the order they changed balances
+	txs    map[common.Hash]*txBalances
+}
+
+func newBalanceTracker() *balanceTracker {
+	return &balanceTracker{
+		origin: make(map[common.Address]*big.Int),
+		txs:    make(map[common.Hash]*txBalances),
+	}
+}
+
+// record notes that a transaction set the balance of an account from prev.
+func (t *balanceTracker) record(thash common.Hash, addr common.Address, prev, balance *big.Int) {
+	if _, ok := t.origin[addr]; !ok {
+		t.origin[addr] = new(big.Int).Set(prev)
+	}
+	tx, ok := t.txs[thash]
+	if !ok {
+		tx = &txBalances{balances: make(map[common.Address]*big.Int)}
+		t.txs[thash] = tx
+		t.order = append(t.order, thash)
+	}
+	if _, ok := tx.balances[addr]; !ok {
+		tx.addrs = append(tx.addrs, addr)
+	}
+	tx.balances[addr] = new(big.Int).Set(balance)
+}
+
+// merge appends the balances recorded by a speculative copy of the state.
+func (t *balanceTracker) merge(spec *balanceTracker) {
+	for _, thash := range spec.order {
+		tx := spec.txs[thash]
+		for _, addr := range tx.addrs {
+			t.record(thash, addr, spec.origin[addr], tx.balances[addr])
+		}
+	}
+}
+
+// changes returns the balance changes of every transaction in order, chaining
+// each one on the balance left by the previous one. Accounts whose balance was
+// set but ended up unchanged (e.g. reverted transfers) are omitted.
+func (t *balanceTracker) changes() []*BalanceChange {
+	var (
+		changes []*BalanceChange
+		last    = make(map[common.Address]*big.Int, len(t.origin))
+	)
+	for addr, balance := range t.origin {
+		last[addr] = balance
+	}
+	for _, thash := range t.order {
+		tx := t.txs[thash]
+		for _, addr := range tx.addrs {
+			prev, balance := last[addr], tx.balances[addr]
+			if prev.Cmp(balance) == 0 {
+				continue
+			}
+			changes = append(changes, &BalanceChange{Address: addr, TxHash: thash, Prev: prev, Balance: balance})
+			last[addr] = balance
+		}
+	}
+	return changes
+}
+
+// StartBalanceTracking starts recording the balance changes made through the
+// state, dropping anything recorded previously. Changes are attributed to the
+// transaction set by Prepare.
+func (self *StateDB) StartBalanceTracking() {
+	self.balances = newBalanceTracker()
+}
+
+// StopBalanceTracking stops recording balance changes and returns the ones
+// made since tracking was started, in transaction order.
+func (self *StateDB) StopBalanceTracking() []*BalanceChange {
+	if self.balances == nil {
+		return nil
+	}
+	changes := self.balances.changes()
+	self.balances = nil
+	return changes
+}
+
+func (self *StateDB) trackBalance(addr common.Address, prev, balance *big.Int) {
+	if self.balances != nil {
+		self.balances.record(self.thash, addr, prev, balance)
+	}
+}
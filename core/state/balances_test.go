@@ -0,0 +1,87 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package state
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+)
+
+// Tests that balance changes are attributed to the transactions making them,
+// chained across transactions and omitted when reverted.
+func TestBalanceTracking(t *testing.T) {
+	state := newAccessTestState(t)
+	state.StartBalanceTracking()
+
+	var (
+		tx1 = common.HexToHash("0x01")
+		tx2 = common.HexToHash("0x02")
+		tx3 = common.HexToHash("0x03")
+	)
+	// A transfer from alice to bob
+	state.Prepare(tx1, common.Hash{}, 0)
+	state.SubBalance(accessAlice, big.NewInt(100))
+	state.AddBalance(accessBob, big.NewInt(100))
+	state.Finalise(true)
+
+	// A reverted transfer, followed by bob self-destructing to alice
+	state.Prepare(tx2, common.Hash{}, 1)
+	snap := state.Snapshot()
+	state.SubBalance(accessBob, big.NewInt(10))
+	state.AddBalance(accessFresh, big.NewInt(10))
+	state.RevertToSnapshot(snap)
+	state.AddBalance(accessAlice, state.GetBalance(accessBob))
+	state.Suicide(accessBob)
+	state.Finalise(true)
+
+	// A transaction run speculatively on a copy and merged back
+	spec := state.Copy()
+	spec.Prepare(tx3, common.Hash{}, 2)
+	spec.StartAccessTracking()
+	spec.AddBalance(accessFresh, big.NewInt(5))
+	spec.Finalise(true)
+	state.Prepare(tx3, common.Hash{}, 2)
+	state.MergeSpeculative(spec)
+	state.Finalise(true)
+
+	// A reward outside of any transaction
+	state.Prepare(common.Hash{}, common.Hash{}, 3)
+	state.AddBalance(accessFresh, big.NewInt(1))
+
+	want := []BalanceChange{
+		{accessAlice, tx1, big.NewInt(1000), big.NewInt(900)},
+		{accessBob, tx1, big.NewInt(1000), big.NewInt(1100)},
+		{accessBob, tx2, big.NewInt(1100), big.NewInt(0)},
+		{accessAlice, tx2, big.NewInt(900), big.NewInt(2000)},
+		{accessFresh, tx3, big.NewInt(0), big.NewInt(5)},
+		{accessFresh, common.Hash{}, big.NewInt(5), big.NewInt(6)},
+	}
+	changes := state.StopBalanceTracking()
+	if len(changes) != len(want) {
+		t.Fatalf("change count mismatch: have %d, want %d", len(changes), len(want))
+	}
+	for i, change := range changes {
+		if change.Address != want[i].Address || change.TxHash != want[i].TxHash || change.Prev.Cmp(want[i].Prev) != 0 || change.Balance.Cmp(want[i].Balance) != 0 {
+			t.Errorf("change %d mismatch: have %+v, want %+v", i, *change, want[i])
+		}
+	}
+	if changes := state.StopBalanceTracking(); changes != nil {
+		t.Errorf("changes returned after tracking stopped: %v", changes)
+	}
+}
@@ -0,0 +1,429 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package export streams the accounts and storage of a state trie into
+// compressed chunk files for external databases.
+//
+// An export directory holds numbered chunks of gzipped JSON lines, one record
+// per account or storage slot in trie order, and a manifest listing the
+// chunks with their checksums. The manifest is rewritten after every chunk
+// with the position the next one starts at, so an interrupted export resumes
+// where it stopped. The state is read through its own trie iterators, block
+// import goes on while an export runs.
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core/state"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/rlp"
+	"github.com/matrix/go-matrix/trie"
+)
+
+// ManifestName is the name of the manifest file in an export directory.
+const ManifestName = "manifest.json"
+
+// DefaultChunkSize is the default number of records per chunk.
+const DefaultChunkSize = 100000
+
+var (
+	// ErrAborted is returned by Run when the export was stopped before the end.
+	ErrAborted = errors.New("state export aborted")
+
+	emptyCodeHash = crypto.Keccak256(nil)
+)
+
+// Manifest describes the chunks of an export.
+type Manifest struct {
+	Root      common.Hash `json:"root"`
+	ChunkSize int         `json:"chunkSize"`
+	Chunks    []Chunk     `json:"chunks"`
+	Accounts  uint64      `json:"accounts"`
+	Slots     uint64      `json:"slots"`
+	Next      *Position   `json:"next,omitempty"` // where the export resumes, nil once done
+	Done      bool        `json:"done"`
+}
+
+// Chunk is a file of the export.
+type Chunk struct {
+	Name    string `json:"name"`
+	Records int    `json:"records"`
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256"` // hex checksum of the compressed file
+}
+
+// Position is the place of a record in the state: an account, or a storage
+// slot of it.
+type Position struct {
+	Account common.Hash  `json:"account"`
+	Slot    *common.Hash `json:"slot,omitempty"`
+}
+
+// Record is a line of a chunk, an account or a storage slot. Accounts and
+// slots are identified by the hashes they are stored under in the tries,
+// their preimages are included when the node knows them.
+type Record struct {
+	Type string `json:"type"` // "account" or "storage"
+
+	Hash    common.Hash     `json:"hash"`
+	Address *common.Address `json:"address,omitempty"`
+
+	Nonce    uint64        `json:"nonce,omitempty"`
+	Balance  *hexutil.Big  `json:"balance,omitempty"`
+	Root     *common.Hash  `json:"root,omitempty"`
+	CodeHash *common.Hash  `json:"codeHash,omitempty"`
+	Code     hexutil.Bytes `json:"code,omitempty"`
+
+	Account *common.Hash  `json:"account,omitempty"` // the account of a storage slot
+	Key     hexutil.Bytes `json:"key,omitempty"`
+	Value   hexutil.Bytes `json:"value,omitempty"`
+}
+
+// Progress reports the state of an export.
+type Progress struct {
+	Root     common.Hash `json:"root"`
+	Chunks   int         `json:"chunks"`
+	Accounts uint64      `json:"accounts"`
+	Slots    uint64      `json:"slots"`
+	Done     bool        `json:"done"`
+}
+
+// Exporter writes the state at a root into an export directory.
+type Exporter struct {
+	db  state.Database
+	dir string
+
+	mu       sync.Mutex
+	manifest *Manifest // as last written to disk
+
+	// the chunk being written
+	file     *os.File
+	gz       *gzip.Writer
+	hash     hash.Hash // checksum of the compressed chunk
+	records  int
+	accounts uint64
+	slots    uint64
+}
+
+// New creates an exporter of the state at root into dir, records being
+// split in chunks of chunkSize. If dir holds an unfinished export of the same
+// root, it is resumed with its own chunk size.
+func New(db state.Database, root common.Hash, dir string, chunkSize int) (*Exporter, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	manifest, err := ReadManifest(dir)
+	switch {
+	case os.IsNotExist(err):
+		manifest = &Manifest{Root: root, ChunkSize: chunkSize, Next: &Position{}}
+	case err != nil:
+		return nil, err
+	case manifest.Root != root:
+		return nil, fmt.Errorf("%s holds an export of state %x", dir, manifest.Root)
+	}
+	for _, chunk := range manifest.Chunks {
+		if _, err := os.Stat(filepath.Join(dir, chunk.Name)); err != nil {
+			return nil, fmt.Errorf("chunk of the export missing: %v", err)
+		}
+	}
+	return &Exporter{db: db, dir: dir, manifest: manifest}, nil
+}
+
+// ReadManifest reads the manifest of the export in dir.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		return nil, err
+	}
+	manifest := new(Manifest)
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	return manifest, nil
+}
+
+// Verify checks the chunks of the export in dir against the checksums of its
+// manifest.
+func Verify(dir string) (*Manifest, error) {
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range manifest.Chunks {
+		f, err := os.Open(filepath.Join(dir, chunk.Name))
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != chunk.SHA256 {
+			return nil, fmt.Errorf("chunk %s checksum mismatch: have %s, want %s", chunk.Name, sum, chunk.SHA256)
+		}
+	}
+	return manifest, nil
+}
+
+// Progress returns the state of the export as of the last chunk written.
+func (e *Exporter) Progress() Progress {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return Progress{
+		Root:     e.manifest.Root,
+		Chunks:   len(e.manifest.Chunks),
+		Accounts: e.manifest.Accounts,
+		Slots:    e.manifest.Slots,
+		Done:     e.manifest.Done,
+	}
+}
+
+// Run exports the records left, writing the manifest after every chunk. It
+// returns ErrAborted if quit is closed before the export is done, the chunk
+// under way is then discarded.
+func (e *Exporter) Run(quit <-chan struct{}) error {
+	e.mu.Lock()
+	next := e.manifest.Next
+	e.mu.Unlock()
+	if next == nil {
+		return nil
+	}
+	defer e.discard()
+
+	accTrie, err := e.db.OpenTrie(e.manifest.Root)
+	if err != nil {
+		return err
+	}
+	first := true
+	it := trie.NewIterator(accTrie.NodeIterator(next.Account[:]))
+	for it.Next() {
+		addrHash := common.BytesToHash(it.Key)
+		var data state.Account
+		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
+			return fmt.Errorf("invalid account %x: %v", addrHash, err)
+		}
+		// an account cut in the middle of its storage resumes at the slot
+		var slotFrom []byte
+		if first && next.Slot != nil && addrHash == next.Account {
+			slotFrom = next.Slot[:]
+		} else {
+			record, err := e.account(accTrie, addrHash, data)
+			if err != nil {
+				return err
+			}
+			if err := e.emit(record, &Position{Account: addrHash}, quit); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		if data.Root == types.EmptyRootHash || data.Root == (common.Hash{}) {
+			continue
+		}
+		storageTrie, err := e.db.OpenStorageTrie(addrHash, data.Root)
+		if err != nil {
+			return err
+		}
+		sit := trie.NewIterator(storageTrie.NodeIterator(slotFrom))
+		for sit.Next() {
+			slot := common.BytesToHash(sit.Key)
+			_, value, _, err := rlp.Split(sit.Value)
+			if err != nil {
+				return fmt.Errorf("invalid storage slot %x of account %x: %v", slot, addrHash, err)
+			}
+			account := addrHash
+			record := &Record{Type: "storage", Hash: slot, Account: &account, Key: storageTrie.GetKey(sit.Key), Value: value}
+			if err := e.emit(record, &Position{Account: addrHash, Slot: &slot}, quit); err != nil {
+				return err
+			}
+		}
+		if sit.Err != nil {
+			return sit.Err
+		}
+	}
+	if it.Err != nil {
+		return it.Err
+	}
+	return e.seal(nil)
+}
+
+// account creates the record of an account
+func (e *Exporter) account(accTrie state.Trie, addrHash common.Hash, data state.Account) (*Record, error) {
+	root, codeHash := data.Root, common.BytesToHash(data.CodeHash)
+	balance := data.Balance
+	if balance == nil {
+		balance = new(big.Int)
+	}
+	record := &Record{
+		Type:     "account",
+		Hash:     addrHash,
+		Nonce:    data.Nonce,
+		Balance:  (*hexutil.Big)(balance),
+		Root:     &root,
+		CodeHash: &codeHash,
+	}
+	if preimage := accTrie.GetKey(addrHash[:]); preimage != nil {
+		addr := common.BytesToAddress(preimage)
+		record.Address = &addr
+	}
+	if !bytes.Equal(data.CodeHash, emptyCodeHash) {
+		code, err := e.db.ContractCode(addrHash, codeHash)
+		if err != nil {
+			return nil, fmt.Errorf("missing code of account %x: %v", addrHash, err)
+		}
+		record.Code = code
+	}
+	return record, nil
+}
+
+// emit writes a record into the current chunk, sealing it first if full.
+// pos is the position of the record, where the next chunk starts.
+func (e *Exporter) emit(record *Record, pos *Position, quit <-chan struct{}) error {
+	select {
+	case <-quit:
+		return ErrAborted
+	default:
+	}
+	if e.records == e.manifest.ChunkSize {
+		if err := e.seal(pos); err != nil {
+			return err
+		}
+	}
+	if e.file == nil {
+		if err := e.create(); err != nil {
+			return err
+		}
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := e.gz.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	e.records++
+	if record.Type == "account" {
+		e.accounts++
+	} else {
+		e.slots++
+	}
+	return nil
+}
+
+// chunkName returns the file name of the chunk with the given index
+func chunkName(index int) string {
+	return fmt.Sprintf("chunk-%06d.jsonl.gz", index)
+}
+
+// create starts a new chunk, replacing the leftovers of an interrupted run
+func (e *Exporter) create() error {
+	f, err := os.Create(filepath.Join(e.dir, chunkName(len(e.manifest.Chunks))+".tmp"))
+	if err != nil {
+		return err
+	}
+	e.file, e.hash = f, sha256.New()
+	e.gz = gzip.NewWriter(io.MultiWriter(f, e.hash))
+	e.records, e.accounts, e.slots = 0, 0, 0
+	return nil
+}
+
+// seal completes the current chunk, if any, and records it in the manifest
+// along with the position of the next one, nil at the end of the state.
+func (e *Exporter) seal(next *Position) error {
+	manifest := *e.manifest
+	manifest.Chunks = append([]Chunk{}, e.manifest.Chunks...)
+	if e.file != nil {
+		if err := e.gz.Close(); err != nil {
+			return err
+		}
+		if err := e.file.Sync(); err != nil {
+			return err
+		}
+		info, err := e.file.Stat()
+		if err != nil {
+			return err
+		}
+		if err := e.file.Close(); err != nil {
+			return err
+		}
+		name := chunkName(len(manifest.Chunks))
+		if err := os.Rename(e.file.Name(), filepath.Join(e.dir, name)); err != nil {
+			return err
+		}
+		manifest.Chunks = append(manifest.Chunks, Chunk{
+			Name:    name,
+			Records: e.records,
+			Size:    info.Size(),
+			SHA256:  hex.EncodeToString(e.hash.Sum(nil)),
+		})
+		manifest.Accounts += e.accounts
+		manifest.Slots += e.slots
+		e.file, e.gz = nil, nil
+	}
+	manifest.Next, manifest.Done = next, next == nil
+	if err := writeManifest(e.dir, &manifest); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.manifest = &manifest
+	e.mu.Unlock()
+	return nil
+}
+
+// discard drops the chunk under way, if any
+func (e *Exporter) discard() {
+	if e.file != nil {
+		e.file.Close()
+		os.Remove(e.file.Name())
+		e.file, e.gz = nil, nil
+	}
+}
+
+// writeManifest replaces the manifest of an export atomically
+func writeManifest(dir string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, ManifestName+".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, ManifestName))
+}
@@ -0,0 +1,235 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package export
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core/state"
+	"github.com/matrix/go-matrix/mandb"
+)
+
+// makeState creates a state of accounts, some with code and storage
+func makeState(t *testing.T) (state.Database, common.Hash) {
+	db := state.NewDatabase(mandb.NewMemDatabase())
+	statedb, _ := state.New(common.Hash{}, db)
+	for i := byte(0); i < 20; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		statedb.AddBalance(addr, big.NewInt(int64(i)+1))
+		statedb.SetNonce(addr, uint64(i))
+		if i%4 == 0 {
+			statedb.SetCode(addr, []byte{i, i, i})
+			for j := byte(1); j <= 5; j++ {
+				statedb.SetState(addr, common.BytesToHash([]byte{j}), common.BytesToHash([]byte{i, j}))
+			}
+		}
+	}
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := db.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to commit tries: %v", err)
+	}
+	return db, root
+}
+
+// readRecords reads the records of all chunks of an export
+func readRecords(t *testing.T, dir string) []Record {
+	manifest, err := Verify(dir)
+	if err != nil {
+		t.Fatalf("failed to verify export: %v", err)
+	}
+	var records []Record
+	for _, chunk := range manifest.Chunks {
+		f, err := os.Open(filepath.Join(dir, chunk.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		for scanner := bufio.NewScanner(gz); scanner.Scan(); n++ {
+			var record Record
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Fatalf("%s: invalid record: %v", chunk.Name, err)
+			}
+			records = append(records, record)
+		}
+		f.Close()
+		if n != chunk.Records {
+			t.Fatalf("%s: have %d records, manifest says %d", chunk.Name, n, chunk.Records)
+		}
+	}
+	return records
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "state-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestExport(t *testing.T) {
+	db, root := makeState(t)
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	exporter, err := New(db, root, dir, 7)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+	if err := exporter.Run(nil); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	progress := exporter.Progress()
+	if !progress.Done || progress.Accounts != 20 || progress.Slots != 25 {
+		t.Fatalf("have progress %+v, want done with 20 accounts and 25 slots", progress)
+	}
+	records := readRecords(t, dir)
+	if len(records) != 45 || progress.Chunks != 7 {
+		t.Fatalf("have %d records in %d chunks, want 45 in 7", len(records), progress.Chunks)
+	}
+
+	statedb, _ := state.New(root, db)
+	var account *Record
+	for i := range records {
+		record := &records[i]
+		switch record.Type {
+		case "account":
+			account = record
+			if record.Address == nil {
+				t.Fatalf("account %x without address", record.Hash)
+			}
+			if have, want := record.Balance.ToInt(), statedb.GetBalance(*record.Address); have.Cmp(want) != 0 {
+				t.Errorf("account %x: have balance %v, want %v", record.Address, have, want)
+			}
+			if have, want := []byte(record.Code), statedb.GetCode(*record.Address); string(have) != string(want) {
+				t.Errorf("account %x: have code %x, want %x", record.Address, have, want)
+			}
+		case "storage":
+			if account == nil || *record.Account != account.Hash {
+				t.Fatalf("storage slot %x out of its account", record.Hash)
+			}
+			key := common.BytesToHash(record.Key)
+			if have, want := common.BytesToHash(record.Value), statedb.GetState(*account.Address, key); have != want {
+				t.Errorf("account %x slot %x: have %x, want %x", account.Address, key, have, want)
+			}
+		default:
+			t.Fatalf("unknown record type %q", record.Type)
+		}
+	}
+
+	// a finished export is left as is
+	if err := exporter.Run(nil); err != nil {
+		t.Fatalf("rerun failed: %v", err)
+	}
+	if _, err := New(db, common.Hash{1}, dir, 7); err == nil {
+		t.Fatal("export of another root resumed")
+	}
+}
+
+// abortingDatabase stops the export when the storage of the given number of
+// accounts has been opened
+type abortingDatabase struct {
+	state.Database
+	left int
+	quit chan struct{}
+}
+
+func (db *abortingDatabase) OpenStorageTrie(addrHash, root common.Hash) (state.Trie, error) {
+	if db.left--; db.left == 0 {
+		close(db.quit)
+	}
+	return db.Database.OpenStorageTrie(addrHash, root)
+}
+
+func TestExportResume(t *testing.T) {
+	db, root := makeState(t)
+	full, partial := tempDir(t), tempDir(t)
+	defer os.RemoveAll(full)
+	defer os.RemoveAll(partial)
+
+	exporter, _ := New(db, root, full, 3)
+	if err := exporter.Run(nil); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	// chunks of 3 records get cut in the middle of the 5 slots of accounts
+	aborting := &abortingDatabase{Database: db, left: 3, quit: make(chan struct{})}
+	exporter, _ = New(aborting, root, partial, 3)
+	if err := exporter.Run(aborting.quit); err != ErrAborted {
+		t.Fatalf("have error %v, want %v", err, ErrAborted)
+	}
+	manifest, err := ReadManifest(partial)
+	if err != nil {
+		t.Fatalf("no manifest after abort: %v", err)
+	}
+	if manifest.Done || manifest.Next == nil || len(manifest.Chunks) == 0 {
+		t.Fatalf("have manifest %+v, want an unfinished export", manifest)
+	}
+
+	exporter, err = New(db, root, partial, 100)
+	if err != nil {
+		t.Fatalf("failed to resume: %v", err)
+	}
+	if err := exporter.Run(nil); err != nil {
+		t.Fatalf("resumed export failed: %v", err)
+	}
+	if have, want := readRecords(t, partial), readRecords(t, full); !reflect.DeepEqual(have, want) {
+		t.Fatalf("resumed export differs: have %d records, want %d", len(have), len(want))
+	}
+	if manifest, _ := ReadManifest(partial); manifest.ChunkSize != 3 {
+		t.Errorf("have chunk size %d after resume, want the original 3", manifest.ChunkSize)
+	}
+}
+
+func TestVerifyCorruptChunk(t *testing.T) {
+	db, root := makeState(t)
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	exporter, _ := New(db, root, dir, 10)
+	if err := exporter.Run(nil); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	name := filepath.Join(dir, chunkName(1))
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/2] ^= 0xff
+	if err := ioutil.WriteFile(name, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(dir); err == nil {
+		t.Fatal("corrupt chunk not detected")
+	}
+}
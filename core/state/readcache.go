@@ -0,0 +1,120 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package state
+
+import (
+	"github.com/hashicorp/golang-lru"
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/metrics"
+)
+
+var (
+	readCacheHitMeter  = metrics.NewRegisteredMeter("state/readcache/hit", nil)
+	readCacheMissMeter = metrics.NewRegisteredMeter("state/readcache/miss", nil)
+)
+
+// readKey identifies a value in the account trie or a storage trie by the
+// root of the trie it was read from.
+type readKey struct {
+	root common.Hash
+	key  string
+}
+
+// readCachingDB is a state database caching the raw account and storage slot
+// values read from committed tries. A trie root pins its content, so the values
+// never go stale and are shared between all states opened at the same block:
+// repeated calls against a historical block only resolve the accounts and slots
+// they touch from the trie once, instead of walking the trie nodes every time.
+type readCachingDB struct {
+	Database
+	values *lru.Cache // readKey -> []byte, nil for missing keys
+}
+
+// NewReadCachingDatabase wraps a state database, caching up to size values read
+// from the tries opened through it. It is meant for serving reads of historical
+// states, e.g. calls against old blocks on an archive node.
+func NewReadCachingDatabase(db Database, size int) (Database, error) {
+	values, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &readCachingDB{Database: db, values: values}, nil
+}
+
+// OpenTrie opens the main account trie, reading through the value cache.
+func (db *readCachingDB) OpenTrie(root common.Hash) (Trie, error) {
+	tr, err := db.Database.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	return &readCachedTrie{Trie: tr, root: root, db: db}, nil
+}
+
+// OpenStorageTrie opens the storage trie of an account, reading through the
+// value cache.
+func (db *readCachingDB) OpenStorageTrie(addrHash, root common.Hash) (Trie, error) {
+	tr, err := db.Database.OpenStorageTrie(addrHash, root)
+	if err != nil {
+		return nil, err
+	}
+	return &readCachedTrie{Trie: tr, root: root, db: db}, nil
+}
+
+// CopyTrie returns an independent copy of the given trie.
+func (db *readCachingDB) CopyTrie(t Trie) Trie {
+	if t, ok := t.(*readCachedTrie); ok {
+		return &readCachedTrie{Trie: db.Database.CopyTrie(t.Trie), root: t.root, dirty: t.dirty, db: db}
+	}
+	return db.Database.CopyTrie(t)
+}
+
+// readCachedTrie serves reads of an unmodified trie from the value cache of its
+// database. Once modified, its root no longer identifies its content and the
+// cache is bypassed.
+type readCachedTrie struct {
+	Trie
+	root  common.Hash
+	dirty bool
+	db    *readCachingDB
+}
+
+func (t *readCachedTrie) TryGet(key []byte) ([]byte, error) {
+	if t.dirty {
+		return t.Trie.TryGet(key)
+	}
+	id := readKey{t.root, string(key)}
+	if cached, ok := t.db.values.Get(id); ok {
+		readCacheHitMeter.Mark(1)
+		return cached.([]byte), nil
+	}
+	readCacheMissMeter.Mark(1)
+
+	value, err := t.Trie.TryGet(key)
+	if err == nil {
+		t.db.values.Add(id, value)
+	}
+	return value, err
+}
+
+func (t *readCachedTrie) TryUpdate(key, value []byte) error {
+	t.dirty = true
+	return t.Trie.TryUpdate(key, value)
+}
+
+func (t *readCachedTrie) TryDelete(key []byte) error {
+	t.dirty = true
+	return t.Trie.TryDelete(key)
+}
@@ -0,0 +1,82 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package state
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/mandb"
+)
+
+// Tests that states opened through the read cache see the committed values,
+// share them between each other and are not affected by modifications made in
+// other states of the same block.
+func TestReadCachingDatabase(t *testing.T) {
+	db := NewDatabase(mandb.NewMemDatabase())
+	state, _ := New(common.Hash{}, db)
+	addr := common.BytesToAddress([]byte{0x01})
+	state.SetBalance(addr, big.NewInt(42))
+	state.SetState(addr, common.Hash{1}, common.Hash{2})
+	root, _ := state.Commit(false)
+
+	cached, err := NewReadCachingDatabase(db, 16)
+	if err != nil {
+		t.Fatalf("failed to create read caching database: %v", err)
+	}
+	cache := cached.(*readCachingDB).values
+
+	first, _ := New(root, cached)
+	if balance := first.GetBalance(addr); balance.Int64() != 42 {
+		t.Fatalf("balance mismatch: have %v, want 42", balance)
+	}
+	if value := first.GetState(addr, common.Hash{1}); value != (common.Hash{2}) {
+		t.Fatalf("storage mismatch: have %x, want %x", value, common.Hash{2})
+	}
+	if missing := first.GetBalance(common.BytesToAddress([]byte{0x02})); missing.Sign() != 0 {
+		t.Fatalf("missing account balance mismatch: have %v, want 0", missing)
+	}
+	// Account, slot and missing account are cached
+	if cache.Len() != 3 {
+		t.Fatalf("cached value count mismatch: have %d, want 3", cache.Len())
+	}
+	// Modify the state and flush the changes into the tries
+	first.SetBalance(addr, big.NewInt(1))
+	first.SetState(addr, common.Hash{1}, common.Hash{3})
+	first.SetState(addr, common.Hash{4}, common.Hash{5})
+	first.IntermediateRoot(false)
+	if value := first.GetState(addr, common.Hash{4}); value != (common.Hash{5}) {
+		t.Fatalf("modified storage mismatch: have %x, want %x", value, common.Hash{5})
+	}
+	second, _ := New(root, cached)
+	if balance := second.GetBalance(addr); balance.Int64() != 42 {
+		t.Fatalf("balance leaked from other state: have %v, want 42", balance)
+	}
+	if value := second.GetState(addr, common.Hash{1}); value != (common.Hash{2}) {
+		t.Fatalf("storage leaked from other state: have %x, want %x", value, common.Hash{2})
+	}
+	if value := second.GetState(addr, common.Hash{4}); value != (common.Hash{}) {
+		t.Fatalf("storage leaked from other state: have %x, want empty", value)
+	}
+	// Proofs are still generated from the underlying tries
+	if _, err := second.GetProof(addr); err != nil {
+		t.Fatalf("failed to prove account: %v", err)
+	}
+	if _, err := second.GetStorageProof(addr, common.Hash{1}); err != nil {
+		t.Fatalf("failed to prove storage slot: %v", err)
+	}
+}
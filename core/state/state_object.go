@@ -266,6 +266,7 @@ func (self *stateObject) SetBalance(amount *big.Int) {
 }
 
 func (self *stateObject) setBalance(amount *big.Int) {
+	self.db.trackBalance(self.address, self.data.Balance, amount)
 	self.data.Balance = amount
 }
 
@@ -32,7 +32,10 @@ import (
 
 	"github.com/matrix/go-matrix/common"
 	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/crypto"
 	"github.com/matrix/go-matrix/mandb"
+	"github.com/matrix/go-matrix/rlp"
+	"github.com/matrix/go-matrix/trie"
 )
 
 // Tests that updating a state trie does not leak any database writes prior to
@@ -435,3 +438,58 @@ func TestCopyOfCopy(t *testing.T) {
 		t.Fatalf("2nd copy fail, expected 42, got %v", got)
 	}
 }
+
+// Tests that account and storage proofs verify against the committed state.
+func TestStateProofs(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(mandb.NewMemDatabase()))
+	for i := byte(0); i < 16; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		state.SetBalance(addr, big.NewInt(int64(i)))
+		state.SetState(addr, common.Hash{i}, common.Hash{i, i})
+	}
+	root, _ := state.Commit(false)
+	state, _ = New(root, state.Database())
+
+	proofDb := func(proof [][]byte) *mandb.MemDatabase {
+		db := mandb.NewMemDatabase()
+		for _, node := range proof {
+			db.Put(crypto.Keccak256(node), node)
+		}
+		return db
+	}
+	addr := common.BytesToAddress([]byte{7})
+
+	proof, err := state.GetProof(addr)
+	if err != nil {
+		t.Fatalf("failed to prove account: %v", err)
+	}
+	blob, _, err := trie.VerifyProof(root, crypto.Keccak256(addr.Bytes()), proofDb(proof))
+	if err != nil {
+		t.Fatalf("account proof rejected: %v", err)
+	}
+	var account Account
+	if err := rlp.DecodeBytes(blob, &account); err != nil {
+		t.Fatalf("failed to decode proven account: %v", err)
+	}
+	if account.Balance.Int64() != 7 {
+		t.Errorf("proven balance mismatch: have %v, want 7", account.Balance)
+	}
+	proof, err = state.GetStorageProof(addr, common.Hash{7})
+	if err != nil {
+		t.Fatalf("failed to prove storage slot: %v", err)
+	}
+	blob, _, err = trie.VerifyProof(account.Root, crypto.Keccak256(common.Hash{7}.Bytes()), proofDb(proof))
+	if err != nil {
+		t.Fatalf("storage proof rejected: %v", err)
+	}
+	var value []byte
+	if err := rlp.DecodeBytes(blob, &value); err != nil {
+		t.Fatalf("failed to decode proven slot: %v", err)
+	}
+	if common.BytesToHash(value) != (common.Hash{7, 7}) {
+		t.Errorf("proven slot mismatch: have %x", value)
+	}
+	if _, err := state.GetStorageProof(common.BytesToAddress([]byte{0xff}), common.Hash{}); err == nil {
+		t.Error("storage proof of missing account succeeded")
+	}
+}
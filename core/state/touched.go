@@ -0,0 +1,47 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package state
+
+import "github.com/matrix/go-matrix/common"
+
+// StartTouchTracking starts recording every account and storage slot accessed
+// through the state, read or written, dropping anything recorded previously.
+// Unlike access tracking, which is scoped to single transactions, the touched
+// set spans everything up to StopTouchTracking, typically a whole block.
+func (self *StateDB) StartTouchTracking() {
+	self.touched = NewAccessSet()
+}
+
+// StopTouchTracking stops recording accesses and returns the accounts and
+// slots touched since tracking was started, or nil if it wasn't.
+func (self *StateDB) StopTouchTracking() *AccessSet {
+	touched := self.touched
+	self.touched = nil
+	return touched
+}
+
+func (self *StateDB) trackTouch(addr common.Address) {
+	if self.touched != nil {
+		self.touched.AddAccount(addr)
+	}
+}
+
+func (self *StateDB) trackSlotTouch(addr common.Address, key common.Hash) {
+	if self.touched != nil {
+		self.touched.AddSlot(addr, key)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package state
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+)
+
+// Tests that touch tracking spans transactions and includes the accesses of
+// merged speculative copies, while access tracking is restarted in between.
+func TestTouchTracking(t *testing.T) {
+	state := newAccessTestState(t)
+	state.StartTouchTracking()
+
+	state.StartAccessTracking()
+	state.GetBalance(accessAlice)
+	state.StopAccessTracking()
+	state.Finalise(true)
+
+	spec := state.Copy()
+	spec.StartAccessTracking()
+	spec.AddBalance(accessBob, big.NewInt(1))
+	spec.GetState(accessContract, common.Hash{1})
+	spec.Finalise(true)
+	state.MergeSpeculative(spec)
+	state.Finalise(true)
+
+	touched := state.StopTouchTracking()
+	if touched == nil {
+		t.Fatal("no touched set returned")
+	}
+	for _, addr := range []common.Address{accessAlice, accessBob} {
+		if _, ok := touched.Accounts[addr]; !ok {
+			t.Errorf("account %x not touched", addr)
+		}
+	}
+	if _, ok := touched.Slots[accessContract][common.Hash{1}]; !ok {
+		t.Error("slot not touched")
+	}
+	if _, ok := touched.Slots[accessContract][common.Hash{2}]; ok {
+		t.Error("untouched slot recorded")
+	}
+	if state.StopTouchTracking() != nil {
+		t.Error("touched set returned after tracking stopped")
+	}
+}
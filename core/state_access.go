@@ -0,0 +1,142 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package core
+
+import (
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core/rawdb"
+	"github.com/matrix/go-matrix/core/state"
+	"github.com/matrix/go-matrix/mandb"
+)
+
+// StateAccessEpoch is the number of blocks sharing a counter of the state
+// access index. Inactivity distributions are resolved to this granularity.
+const StateAccessEpoch = 1024
+
+// StateAccessIndex records the last block accessing each account and storage
+// slot of the canonical chain, as data for designing a state expiry scheme.
+//
+// Only entries existing after the accessing block are indexed, entries
+// deleted by a later access are cleared. Accesses of blocks reorganised out
+// of the chain are kept, and storage of destructed contracts stays indexed
+// until its slots are accessed again.
+type StateAccessIndex struct {
+	db mandb.Database
+}
+
+// NewStateAccessIndex creates a state access index on top of a chain database.
+func NewStateAccessIndex(db mandb.Database) *StateAccessIndex {
+	return &StateAccessIndex{db: db}
+}
+
+// AccountAccess returns the number of the block last accessing an account.
+func (idx *StateAccessIndex) AccountAccess(addr common.Address) (uint64, bool) {
+	return rawdb.ReadAccountAccess(idx.db, addr)
+}
+
+// SlotAccess returns the number of the block last accessing a storage slot.
+func (idx *StateAccessIndex) SlotAccess(addr common.Address, slot common.Hash) (uint64, bool) {
+	return rawdb.ReadSlotAccess(idx.db, addr, slot)
+}
+
+// InactivityBucket counts the accounts and storage slots not accessed for at
+// least a number of blocks.
+type InactivityBucket struct {
+	Inactive uint64 `json:"inactive"`
+	Accounts uint64 `json:"accounts"`
+	Slots    uint64 `json:"slots"`
+}
+
+// Inactivity counts the indexed accounts and slots which were not accessed for
+// at least each of the given numbers of blocks, as of the head block. Entries
+// are counted by epoch, so those last accessed in the epoch straddling a
+// threshold are attributed to the lower bucket.
+func (idx *StateAccessIndex) Inactivity(head uint64, thresholds []uint64) []*InactivityBucket {
+	buckets := make([]*InactivityBucket, len(thresholds))
+	for i, threshold := range thresholds {
+		buckets[i] = &InactivityBucket{Inactive: threshold}
+	}
+	for epoch := uint64(0); epoch <= head/StateAccessEpoch; epoch++ {
+		accounts, slots := rawdb.ReadAccountEpochCount(idx.db, epoch), rawdb.ReadSlotEpochCount(idx.db, epoch)
+		if accounts == 0 && slots == 0 {
+			continue
+		}
+		// Entries of the epoch were accessed by its last block at the latest
+		last := (epoch+1)*StateAccessEpoch - 1
+		if last > head {
+			last = head
+		}
+		for _, bucket := range buckets {
+			if head-last >= bucket.Inactive {
+				bucket.Accounts += accounts
+				bucket.Slots += slots
+			}
+		}
+	}
+	return buckets
+}
+
+// update indexes the accounts and slots touched by a canonical block into a
+// batch, checking their existence in the state left by the block. Updates must
+// not run concurrently, they are serialised by the chain mutex.
+func (idx *StateAccessIndex) update(batch mandb.Putter, number uint64, statedb *state.StateDB, touched *state.AccessSet) {
+	var (
+		epoch    = number / StateAccessEpoch
+		accounts = make(map[uint64]int64)
+		slots    = make(map[uint64]int64)
+	)
+	for addr := range touched.Accounts {
+		last, indexed := rawdb.ReadAccountAccess(idx.db, addr)
+		if indexed {
+			accounts[last/StateAccessEpoch]--
+		}
+		if !statedb.Exist(addr) {
+			if indexed {
+				rawdb.ClearAccountAccess(batch, addr)
+			}
+			continue
+		}
+		rawdb.WriteAccountAccess(batch, addr, number)
+		accounts[epoch]++
+	}
+	for addr, keys := range touched.Slots {
+		for key := range keys {
+			last, indexed := rawdb.ReadSlotAccess(idx.db, addr, key)
+			if indexed {
+				slots[last/StateAccessEpoch]--
+			}
+			if (statedb.GetState(addr, key) == common.Hash{}) {
+				if indexed {
+					rawdb.ClearSlotAccess(batch, addr, key)
+				}
+				continue
+			}
+			rawdb.WriteSlotAccess(batch, addr, key, number)
+			slots[epoch]++
+		}
+	}
+	for epoch, delta := range accounts {
+		if delta != 0 {
+			rawdb.WriteAccountEpochCount(batch, epoch, uint64(int64(rawdb.ReadAccountEpochCount(idx.db, epoch))+delta))
+		}
+	}
+	for epoch, delta := range slots {
+		if delta != 0 {
+			rawdb.WriteSlotEpochCount(batch, epoch, uint64(int64(rawdb.ReadSlotEpochCount(idx.db, epoch))+delta))
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core/state"
+	"github.com/matrix/go-matrix/mandb"
+)
+
+// Tests that the state access index keeps the last access of existing entries
+// and the per epoch counters in sync as blocks touch the state.
+func TestStateAccessIndex(t *testing.T) {
+	var (
+		db         = mandb.NewMemDatabase()
+		index      = NewStateAccessIndex(db)
+		alice      = common.BytesToAddress([]byte{0x01})
+		bob        = common.BytesToAddress([]byte{0x02})
+		ghost      = common.BytesToAddress([]byte{0x03})
+		slot       = common.Hash{1}
+		statedb, _ = state.New(common.Hash{}, state.NewDatabase(db))
+	)
+	indexBlock := func(number uint64, touch func()) {
+		statedb.StartTouchTracking()
+		touch()
+		statedb.Finalise(true)
+		batch := db.NewBatch()
+		index.update(batch, number, statedb, statedb.StopTouchTracking())
+		if err := batch.Write(); err != nil {
+			t.Fatalf("block %d: failed to write index: %v", number, err)
+		}
+	}
+	// Block 1 creates two accounts and a slot, and only reads a missing account
+	indexBlock(1, func() {
+		statedb.AddBalance(alice, big.NewInt(1))
+		statedb.AddBalance(bob, big.NewInt(1))
+		statedb.SetState(bob, slot, common.Hash{1})
+		statedb.GetBalance(ghost)
+	})
+	// Block 2*epoch accesses alice again
+	indexBlock(2*StateAccessEpoch, func() {
+		statedb.GetBalance(alice)
+	})
+
+	if number, ok := index.AccountAccess(alice); !ok || number != 2*StateAccessEpoch {
+		t.Errorf("alice: have access %d (indexed %v), want %d", number, ok, 2*StateAccessEpoch)
+	}
+	if number, ok := index.AccountAccess(bob); !ok || number != 1 {
+		t.Errorf("bob: have access %d (indexed %v), want 1", number, ok)
+	}
+	if number, ok := index.SlotAccess(bob, slot); !ok || number != 1 {
+		t.Errorf("slot: have access %d (indexed %v), want 1", number, ok)
+	}
+	if _, ok := index.AccountAccess(ghost); ok {
+		t.Error("missing account indexed")
+	}
+	head := uint64(3 * StateAccessEpoch)
+	buckets := index.Inactivity(head, []uint64{0, StateAccessEpoch, 2 * StateAccessEpoch, 3 * StateAccessEpoch})
+	for i, want := range []InactivityBucket{
+		{Inactive: 0, Accounts: 2, Slots: 1},
+		{Inactive: StateAccessEpoch, Accounts: 1, Slots: 1},
+		{Inactive: 2 * StateAccessEpoch, Accounts: 1, Slots: 1},
+		{Inactive: 3 * StateAccessEpoch, Accounts: 0, Slots: 0},
+	} {
+		if *buckets[i] != want {
+			t.Errorf("bucket %d: have %+v, want %+v", i, *buckets[i], want)
+		}
+	}
+	// Clearing the slot and deleting bob drops them from the index
+	indexBlock(head, func() {
+		statedb.SetState(bob, slot, common.Hash{})
+		statedb.Suicide(bob)
+	})
+	if _, ok := index.AccountAccess(bob); ok {
+		t.Error("deleted account still indexed")
+	}
+	if _, ok := index.SlotAccess(bob, slot); ok {
+		t.Error("cleared slot still indexed")
+	}
+	buckets = index.Inactivity(head, []uint64{0})
+	if want := (InactivityBucket{Accounts: 1}); *buckets[0] != want {
+		t.Errorf("have %+v after deletion, want %+v", *buckets[0], want)
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core/rawdb"
+	"github.com/matrix/go-matrix/core/state"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/core/vm"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/mandb"
+)
+
+// TraceSectionSize is the number of blocks sharing an entry of the address
+// lookups of the tracing index.
+const TraceSectionSize = 1024
+
+// errTraceIndexDisabled is returned when backfilling a chain not recording the
+// tracing index.
+var errTraceIndexDisabled = errors.New("trace index disabled")
+
+// TraceIndex records a summary of the call frames of every transaction as
+// blocks are imported, so historical traces and the transactions interacting
+// with an address are looked up instead of re-executing the blocks.
+//
+// Frames are stored by block hash whatever the canonical status of the block,
+// lookups by address are filtered against the canonical chain. The index covers
+// the blocks from its tail up to the head, older ones are added by Backfill.
+type TraceIndex struct {
+	db mandb.Database
+}
+
+// NewTraceIndex creates a tracing index on top of a chain database.
+func NewTraceIndex(db mandb.Database) *TraceIndex {
+	return &TraceIndex{db: db}
+}
+
+// Tail returns the first block of the indexed range ending at the head.
+func (idx *TraceIndex) Tail() uint64 {
+	tail, _ := rawdb.ReadTraceIndexTail(idx.db)
+	return tail
+}
+
+// Frames returns the call frames of the transactions of a block, nil if the
+// block isn't indexed.
+func (idx *TraceIndex) Frames(hash common.Hash, number uint64) []*types.TxCallFrames {
+	return rawdb.ReadTraceFrames(idx.db, hash, number)
+}
+
+// Interactions returns the canonical blocks within [from, to] containing call
+// frames of an address, in increasing order, along with its roles in them.
+func (idx *TraceIndex) Interactions(addr common.Address, from, to uint64) []rawdb.TraceAddressEntry {
+	var entries []rawdb.TraceAddressEntry
+	for section := from / TraceSectionSize; section <= to/TraceSectionSize; section++ {
+		for _, entry := range rawdb.ReadTraceAddressSection(idx.db, addr, section) {
+			if entry.Number < from || entry.Number > to {
+				continue
+			}
+			if rawdb.ReadCanonicalHash(idx.db, entry.Number) != entry.Hash {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+	// Backfilled blocks are appended after the ones imported
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Number < entries[j].Number })
+	return entries
+}
+
+// init places the tail of the index when it is enabled on a chain at the given
+// head. Blocks imported while the index was disabled leave a gap, so the range
+// restarts above the head in that case.
+func (idx *TraceIndex) init(head uint64) {
+	tail, started := rawdb.ReadTraceIndexTail(idx.db)
+	last, indexed := rawdb.ReadTraceIndexHead(idx.db)
+	switch {
+	case started && indexed && last >= head:
+		// Range intact, unless the chain was rewound below the tail
+		if tail > head+1 {
+			rawdb.WriteTraceIndexTail(idx.db, head+1)
+		}
+	case started && !indexed && tail == head+1:
+		// Nothing imported since the index was enabled
+	default:
+		if started {
+			log.Warn("Trace index missing blocks, backfill required", "tail", tail, "indexed", last, "head", head)
+		}
+		rawdb.WriteTraceIndexTail(idx.db, head+1)
+	}
+}
+
+// write indexes the call frames recorded in the receipts of a block into a
+// batch. Writes must not run concurrently, they are serialised by the chain
+// mutex, or run offline when backfilling.
+func (idx *TraceIndex) write(batch mandb.Putter, block *types.Block, receipts types.Receipts, canonical bool) {
+	var (
+		hash, number = block.Hash(), block.NumberU64()
+		frames       = make([]*types.TxCallFrames, len(receipts))
+		roles        = make(map[common.Address]uint8)
+	)
+	for i, receipt := range receipts {
+		frames[i] = &types.TxCallFrames{TxHash: receipt.TxHash, Frames: receipt.CallFrames}
+		for _, frame := range receipt.CallFrames {
+			roles[frame.From] |= rawdb.TraceAddressFrom
+			roles[frame.To] |= rawdb.TraceAddressTo
+		}
+	}
+	rawdb.WriteTraceFrames(batch, hash, number, frames)
+
+	section := number / TraceSectionSize
+	for addr, flags := range roles {
+		entries := rawdb.ReadTraceAddressSection(idx.db, addr, section)
+
+		known := false
+		for _, entry := range entries {
+			if entry.Number == number && entry.Hash == hash {
+				known = true
+				break
+			}
+		}
+		if !known {
+			entries = append(entries, rawdb.TraceAddressEntry{Number: number, Hash: hash, Flags: flags})
+			rawdb.WriteTraceAddressSection(batch, addr, section, entries)
+		}
+	}
+	if canonical {
+		rawdb.WriteTraceIndexHead(batch, number)
+	}
+}
+
+// BackfillTraceIndex adds the canonical blocks from the given one up to the
+// tail to the tracing index by re-executing them, regenerating the state they
+// start from out of the closest one available. It must not run while blocks
+// are imported.
+func (bc *BlockChain) BackfillTraceIndex(from uint64) error {
+	idx := bc.traceIndex
+	if idx == nil {
+		return errTraceIndexDisabled
+	}
+	tail := idx.Tail()
+	if from >= tail {
+		return nil
+	}
+	// Find the closest state to re-execute from, the genesis one at worst
+	var (
+		database = state.NewDatabase(bc.db)
+		origin   = from
+		statedb  *state.StateDB
+		err      error
+	)
+	if origin == 0 {
+		origin = 1 // The genesis block has no transactions
+	}
+	for ; origin > 0; origin-- {
+		parent := bc.GetBlockByNumber(origin - 1)
+		if parent == nil {
+			return fmt.Errorf("block #%d not found", origin-1)
+		}
+		if statedb, err = state.New(parent.Root(), database); err == nil {
+			break
+		}
+	}
+	if statedb == nil {
+		return fmt.Errorf("historical state unavailable: %v", err)
+	}
+	var (
+		start  = time.Now()
+		logged = start
+		proot  common.Hash
+	)
+	for number := origin; number < tail; number++ {
+		if time.Since(logged) > 8*time.Second && number > origin {
+			done, total := float64(number-origin), float64(tail-origin)
+			eta := time.Duration(float64(time.Since(start)) / done * (total - done))
+			if number < from {
+				log.Info("Regenerating state for trace backfill", "block", number, "from", from, "elapsed", common.PrettyDuration(time.Since(start)), "eta", common.PrettyDuration(eta))
+			} else {
+				log.Info("Backfilling trace index", "block", number, "target", tail-1, "elapsed", common.PrettyDuration(time.Since(start)), "eta", common.PrettyDuration(eta))
+			}
+			logged = time.Now()
+		}
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			return fmt.Errorf("block #%d not found", number)
+		}
+		receipts, _, _, err := bc.Processor().Process(block, statedb, vm.Config{})
+		if err != nil {
+			return fmt.Errorf("block #%d: %v", number, err)
+		}
+		root, err := statedb.Commit(bc.chainConfig.IsEIP158(block.Number()))
+		if err != nil {
+			return err
+		}
+		if root != block.Root() {
+			return fmt.Errorf("block #%d: state root mismatch: have %x, want %x", number, root, block.Root())
+		}
+		if err := statedb.Reset(root); err != nil {
+			return err
+		}
+		database.TrieDB().Reference(root, common.Hash{})
+		database.TrieDB().Dereference(proot, common.Hash{})
+		proot = root
+
+		if number >= from {
+			batch := bc.db.NewBatch()
+			idx.write(batch, block, receipts, false)
+			if err := batch.Write(); err != nil {
+				return err
+			}
+		}
+	}
+	rawdb.WriteTraceIndexTail(bc.db, from)
+	log.Info("Trace index backfilled", "from", from, "to", tail-1, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package core
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core/rawdb"
+	"github.com/matrix/go-matrix/core/state"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/core/vm"
+	"github.com/matrix/go-matrix/mandb"
+	"github.com/matrix/go-matrix/params"
+)
+
+// Tests that the call frame tracer records nested calls with their position in
+// the call tree, including calls to accounts without code.
+func TestCallFrameTracer(t *testing.T) {
+	var (
+		origin     = common.HexToAddress("0x1111")
+		outer      = common.HexToAddress("0xaaaa")
+		inner      = common.HexToAddress("0xbbbb")
+		plain      = common.HexToAddress("0xcccc")
+		statedb, _ = state.New(common.Hash{}, state.NewDatabase(mandb.NewMemDatabase()))
+	)
+	// call returns the code calling an address with no value nor data
+	call := func(to common.Address) []byte {
+		code := []byte{0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, byte(vm.PUSH20)}
+		code = append(code, to.Bytes()...)
+		return append(code, byte(vm.GAS), byte(vm.CALL), byte(vm.POP))
+	}
+	statedb.SetCode(outer, append(append(call(inner), call(plain)...), byte(vm.STOP)))
+	statedb.SetCode(inner, append(call(plain), byte(vm.STOP)))
+
+	tracer := newCallFrameTracer()
+	ctx := vm.Context{
+		CanTransfer: CanTransfer,
+		Transfer:    Transfer,
+		Origin:      origin,
+		GasPrice:    new(big.Int),
+		BlockNumber: big.NewInt(1),
+		Time:        new(big.Int),
+		Difficulty:  new(big.Int),
+		GasLimit:    10000000,
+	}
+	evm := vm.NewEVM(ctx, statedb, params.TestChainConfig, vm.Config{Debug: true, Tracer: tracer})
+	if _, _, err := evm.Call(vm.AccountRef(origin), outer, nil, 1000000, new(big.Int)); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	want := []struct {
+		from, to  common.Address
+		address   []uint64
+		subtraces uint64
+	}{
+		{origin, outer, []uint64{}, 2},
+		{outer, inner, []uint64{0}, 1},
+		{inner, plain, []uint64{0, 0}, 0},
+		{outer, plain, []uint64{1}, 0},
+	}
+	frames := tracer.Frames()
+	if len(frames) != len(want) {
+		t.Fatalf("frame count mismatch: have %d, want %d", len(frames), len(want))
+	}
+	for i, frame := range frames {
+		if frame.Type != types.CallFrameCall || frame.From != want[i].from || frame.To != want[i].to {
+			t.Errorf("frame %d: have %s %x -> %x, want call %x -> %x", i, frame.Type, frame.From, frame.To, want[i].from, want[i].to)
+		}
+		if !reflect.DeepEqual(frame.TraceAddress, want[i].address) || frame.Subtraces != want[i].subtraces {
+			t.Errorf("frame %d: have trace address %v with %d subtraces, want %v with %d", i, frame.TraceAddress, frame.Subtraces, want[i].address, want[i].subtraces)
+		}
+		if frame.Failed {
+			t.Errorf("frame %d: marked as failed", i)
+		}
+	}
+}
+
+// Tests that the tracing index looks up the blocks interacting with an address
+// on the canonical chain only, and places its tail past blocks not indexed.
+func TestTraceIndex(t *testing.T) {
+	var (
+		db    = mandb.NewMemDatabase()
+		index = NewTraceIndex(db)
+		alice = common.BytesToAddress([]byte{0x01})
+		bob   = common.BytesToAddress([]byte{0x02})
+		carol = common.BytesToAddress([]byte{0x03})
+	)
+	index.init(0)
+	if tail := index.Tail(); tail != 1 {
+		t.Fatalf("tail mismatch: have %d, want 1", tail)
+	}
+	indexBlock := func(number uint64, extra byte, canonical bool, frames ...*types.CallFrame) *types.Block {
+		block := types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(number), Extra: []byte{extra}})
+		receipt := &types.Receipt{TxHash: common.Hash{extra}, CallFrames: frames}
+
+		batch := db.NewBatch()
+		index.write(batch, block, types.Receipts{receipt}, canonical)
+		if canonical {
+			rawdb.WriteCanonicalHash(batch, block.Hash(), number)
+		}
+		if err := batch.Write(); err != nil {
+			t.Fatalf("block %d: failed to write index: %v", number, err)
+		}
+		return block
+	}
+	transfer := func(from, to common.Address) *types.CallFrame {
+		return &types.CallFrame{Type: types.CallFrameCall, From: from, To: to, Value: new(big.Int), TraceAddress: []uint64{}}
+	}
+	first := indexBlock(1, 1, true, transfer(alice, bob))
+	indexBlock(TraceSectionSize+1, 2, true, transfer(bob, carol))
+	indexBlock(TraceSectionSize+1, 3, false, transfer(alice, carol))
+
+	entries := index.Interactions(bob, 0, 2*TraceSectionSize)
+	if len(entries) != 2 {
+		t.Fatalf("bob: have %d interactions, want 2", len(entries))
+	}
+	if entries[0].Number != 1 || entries[0].Flags != rawdb.TraceAddressTo {
+		t.Errorf("bob: first interaction mismatch: have #%d flags %d", entries[0].Number, entries[0].Flags)
+	}
+	if entries[1].Number != TraceSectionSize+1 || entries[1].Flags != rawdb.TraceAddressFrom {
+		t.Errorf("bob: second interaction mismatch: have #%d flags %d", entries[1].Number, entries[1].Flags)
+	}
+	if entries := index.Interactions(alice, 0, 2*TraceSectionSize); len(entries) != 1 || entries[0].Hash != first.Hash() {
+		t.Errorf("alice: side chain interaction returned: %v", entries)
+	}
+	if entries := index.Interactions(bob, 2, 2*TraceSectionSize); len(entries) != 1 {
+		t.Errorf("bob: range not honoured: have %d interactions, want 1", len(entries))
+	}
+	frames := index.Frames(first.Hash(), 1)
+	if len(frames) != 1 || frames[0].TxHash != (common.Hash{1}) || len(frames[0].Frames) != 1 || frames[0].Frames[0].To != bob {
+		t.Errorf("frames mismatch: %v", frames)
+	}
+	// Reopening on the indexed head keeps the range, a gap restarts it
+	index.init(TraceSectionSize + 1)
+	if tail := index.Tail(); tail != 1 {
+		t.Errorf("tail moved: have %d, want 1", tail)
+	}
+	index.init(TraceSectionSize + 5)
+	if tail := index.Tail(); tail != TraceSectionSize+6 {
+		t.Errorf("tail mismatch after gap: have %d, want %d", tail, TraceSectionSize+6)
+	}
+}
@@ -30,6 +30,7 @@ import (
 
 	"github.com/matrix/go-matrix/ca"
 	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/consensus/misc"
 	"github.com/matrix/go-matrix/core/state"
 	"github.com/matrix/go-matrix/core/types"
 	"github.com/matrix/go-matrix/event"
@@ -39,6 +40,7 @@ import (
 	"github.com/matrix/go-matrix/p2p"
 	"github.com/matrix/go-matrix/p2p/discover"
 	"github.com/matrix/go-matrix/params"
+	"github.com/matrix/go-matrix/watchdog"
 	"github.com/syndtr/goleveldb/leveldb"
 	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 )
@@ -74,10 +76,20 @@ var (
 	ErrUnderpriced = errors.New("transaction underpriced")
 	//YY 重复交易错误
 	ErrKownTransaction = errors.New("known transaction")
+	// ErrFeeCapTooLow is returned if a transaction's gas price is below the base
+	// fee of the next block after the dynamic fee fork.
+	ErrFeeCapTooLow = errors.New("fee cap less than block base fee")
+	// ErrUnprotectedTx is returned if a transaction without EIP-155 replay
+	// protection is submitted while such transactions are rejected.
+	ErrUnprotectedTx = errors.New("only replay-protected (EIP-155) transactions allowed")
 	// ErrReplaceUnderpriced is returned if a transaction is attempted to be replaced
 	// with a different one without the required price bump.
 	ErrReplaceUnderpriced = errors.New("replacement transaction underpriced")
 
+	// ErrSpamDeprioritized is returned if a transaction to a contract exceeding
+	// its share of recent block gas is submitted while the pool is full.
+	ErrSpamDeprioritized = errors.New("contract exceeds its share of recent block gas")
+
 	// ErrInsufficientFunds is returned if the total cost of executing a transaction
 	// is higher than the balance of the user's account.
 	ErrInsufficientFunds = errors.New("insufficient funds for gas * price + value")
@@ -125,6 +137,8 @@ var (
 	// General tx metrics
 	invalidTxCounter     = metrics.NewRegisteredCounter("txpool/invalid", nil)
 	underpricedTxCounter = metrics.NewRegisteredCounter("txpool/underpriced", nil)
+	expiredTxCounter     = metrics.NewRegisteredCounter("txpool/expired", nil)
+	flushedTxCounter     = metrics.NewRegisteredCounter("txpool/flushed", nil)
 )
 
 // TxStatus is the current status of a transaction as seen by the pool.
@@ -235,7 +249,30 @@ type TxPoolConfig struct {
 	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
-	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+	Lifetime        time.Duration // Maximum amount of time non-executable transaction are queued
+	PendingLifetime time.Duration // Maximum amount of time non-local executable transactions are pending (0 = unlimited)
+
+	RejectUnprotected bool          // Whether transactions without EIP-155 replay protection are rejected
+	UnprotectedGrace  []common.Hash // Unprotected transactions accepted regardless (e.g. known deployments)
+
+	SpamGuard  bool             // Whether contracts exceeding their share of recent block gas are deprioritized
+	SpamShare  float64          // Share of the recent block gas capacity a single contract may take
+	SpamWindow time.Duration    // Span of block time the share of a contract is measured over
+	SpamAllow  []common.Address // Contracts never deprioritized (e.g. system contracts)
+}
+
+// UnprotectedAllowed reports whether the given transaction may enter the pool
+// even though it lacks EIP-155 replay protection.
+func (config *TxPoolConfig) UnprotectedAllowed(hash common.Hash) bool {
+	if !config.RejectUnprotected {
+		return true
+	}
+	for _, grace := range config.UnprotectedGrace {
+		if grace == hash {
+			return true
+		}
+	}
+	return false
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -253,6 +290,9 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	GlobalQueue:  1024 * 60,
 
 	Lifetime: 3 * time.Hour,
+
+	SpamShare:  0.25,
+	SpamWindow: 10 * time.Minute,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -271,6 +311,14 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool price bump", "provided", conf.PriceBump, "updated", DefaultTxPoolConfig.PriceBump)
 		conf.PriceBump = DefaultTxPoolConfig.PriceBump
 	}
+	if conf.SpamShare <= 0 || conf.SpamShare > 1 {
+		log.Warn("Sanitizing invalid txpool spam share", "provided", conf.SpamShare, "updated", DefaultTxPoolConfig.SpamShare)
+		conf.SpamShare = DefaultTxPoolConfig.SpamShare
+	}
+	if conf.SpamWindow < time.Second {
+		log.Warn("Sanitizing invalid txpool spam window", "provided", conf.SpamWindow, "updated", DefaultTxPoolConfig.SpamWindow)
+		conf.SpamWindow = DefaultTxPoolConfig.SpamWindow
+	}
 	return conf
 }
 
@@ -287,6 +335,7 @@ type TxPool struct {
 	chain        blockChain
 	gasPrice     *big.Int
 	txFeed       event.Feed
+	dropFeed     event.Feed
 	scope        event.SubscriptionScope
 	chainHeadCh  chan ChainHeadEvent
 	chainHeadSub event.Subscription
@@ -296,9 +345,11 @@ type TxPool struct {
 	currentState  *state.StateDB      // Current state in the blockchain head
 	pendingState  *state.ManagedState // Pending state tracking virtual nonces
 	currentMaxGas uint64              // Current gas limit for transaction caps
+	pendingFee    *big.Int            // Base fee of the next block, nil before the dynamic fee fork
 
 	locals  *accountSet // Set of local transaction to exempt from eviction rules
 	journal *txJournal  // Journal of local transaction to back up to disk
+	spam    *spamGuard  // Gas share of recent recipients, nil if the spam guard is disabled
 
 	pending map[common.Address]*txList   // All currently processable transactions
 	queue   map[common.Address]*txList   // Queued but non-processable transactions
@@ -345,6 +396,9 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 	}
 	pool.locals = newAccountSet(pool.signer)
 	pool.priced = newTxPricedList(pool.all)
+	if config.SpamGuard {
+		pool.spam = newSpamGuard(&config)
+	}
 	pool.reset(nil, chain.CurrentBlock().Header())
 
 	//go pool.testList() //for test
@@ -400,6 +454,12 @@ func (pool *TxPool) loop() {
 	journal := time.NewTicker(pool.config.Rejournal)
 	defer journal.Stop()
 
+	heart := watchdog.Register("txpool")
+	defer heart.Idle()
+
+	heartbeat := time.NewTicker(watchdog.BeatInterval)
+	defer heartbeat.Stop()
+
 	// Track the previous head headers for transaction reorgs
 	head := pool.chain.CurrentBlock()
 
@@ -496,6 +556,10 @@ func (pool *TxPool) loop() {
 		case <-pool.chainHeadSub.Err():
 			return
 
+		// Signal the watchdog that the loop is still alive
+		case <-heartbeat.C:
+			heart.Beat()
+
 		// Handle stats reporting ticks
 		case <-report.C:
 			pool.mu.RLock()
@@ -508,21 +572,10 @@ func (pool *TxPool) loop() {
 				prevPending, prevQueued, prevStales = pending, queued, stales
 			}
 
-		// Handle inactive account transaction eviction
+		// Handle expired transaction eviction
 		case <-evict.C:
 			pool.mu.Lock()
-			for addr := range pool.queue {
-				// Skip local transactions from the eviction mechanism
-				if pool.locals.contains(addr) {
-					continue
-				}
-				// Any non-locals old enough should be removed
-				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
-					for _, tx := range pool.queue[addr].Flatten() {
-						pool.removeTx(tx.Hash(), true)
-					}
-				}
-			}
+			pool.expire(time.Now())
 			pool.mu.Unlock()
 
 		// Handle local transaction journal rotation
@@ -885,6 +938,10 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 
 		if depth := uint64(math.Abs(float64(oldNum) - float64(newNum))); depth > 64 {
 			log.Debug("Skipping deep transaction reorg", "depth", depth)
+		} else if pool.chain.GetBlock(oldHead.Hash(), oldNum) == nil {
+			// The old head was deleted by a SetHead rewind together with the
+			// transactions it included, there is nothing left to reinject.
+			log.Debug("Skipping transaction reorg of rewound chain", "old", oldNum, "new", newNum)
 		} else {
 			// Reorg seems shallow enough to pull in all transactions into memory
 			var discarded, included types.Transactions
@@ -934,6 +991,13 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.currentState = statedb
 	pool.pendingState = state.ManageState(statedb)
 	pool.currentMaxGas = newHead.GasLimit
+	pool.pendingFee = misc.CalcBaseFee(pool.chainconfig, newHead)
+
+	if pool.spam != nil {
+		if block := pool.chain.GetBlock(newHead.Hash(), newHead.Number.Uint64()); block != nil {
+			pool.spam.addBlock(block)
+		}
+	}
 
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
@@ -976,6 +1040,79 @@ func (pool *TxPool) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscripti
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribeDroppedTxsEvent registers a subscription of DroppedTxsEvent and
+// starts sending event to the given channel.
+func (pool *TxPool) SubscribeDroppedTxsEvent(ch chan<- DroppedTxsEvent) event.Subscription {
+	return pool.scope.Track(pool.dropFeed.Subscribe(ch))
+}
+
+// expire drops the queued transactions of non-local accounts inactive for longer
+// than the lifetime and, if a pending lifetime is configured, the non-local
+// pending transactions which entered the pool longer than that before now.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) expire(now time.Time) {
+	var expired types.Transactions
+	for addr := range pool.queue {
+		// Skip local transactions from the eviction mechanism
+		if pool.locals.contains(addr) {
+			continue
+		}
+		// Any non-locals old enough should be removed
+		if now.Sub(pool.beats[addr]) > pool.config.Lifetime {
+			for _, tx := range pool.queue[addr].Flatten() {
+				pool.removeTx(tx.Hash(), true)
+				expired = append(expired, tx)
+			}
+		}
+	}
+	if pool.config.PendingLifetime > 0 {
+		cutoff := now.Add(-pool.config.PendingLifetime)
+		for addr, list := range pool.pending {
+			if pool.locals.contains(addr) {
+				continue
+			}
+			for _, tx := range list.Flatten() {
+				if added, ok := pool.all.Added(tx.Hash()); ok && added.Before(cutoff) {
+					pool.removeTx(tx.Hash(), true)
+					expired = append(expired, tx)
+				}
+			}
+		}
+	}
+	if len(expired) == 0 {
+		return
+	}
+	log.Debug("Dropped expired transactions", "count", len(expired), "lifetime", pool.config.Lifetime, "pending", pool.config.PendingLifetime)
+	expiredTxCounter.Inc(int64(len(expired)))
+	go pool.dropFeed.Send(DroppedTxsEvent{Txs: expired, Reason: "expired"})
+}
+
+// Flush drops all pending and queued transactions of an account, local ones
+// included, and returns them.
+func (pool *TxPool) Flush(addr common.Address) types.Transactions {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	var flushed types.Transactions
+	if list := pool.pending[addr]; list != nil {
+		flushed = append(flushed, list.Flatten()...)
+	}
+	if list := pool.queue[addr]; list != nil {
+		flushed = append(flushed, list.Flatten()...)
+	}
+	for _, tx := range flushed {
+		pool.removeTx(tx.Hash(), true)
+	}
+	if len(flushed) == 0 {
+		return nil
+	}
+	log.Info("Flushed account transactions", "account", addr, "count", len(flushed))
+	flushedTxCounter.Inc(int64(len(flushed)))
+	go pool.dropFeed.Send(DroppedTxsEvent{Txs: flushed, Reason: "flushed"})
+	return flushed
+}
+
 // GasPrice returns the current gas price enforced by the transaction pool.
 func (pool *TxPool) GasPrice() *big.Int {
 	pool.mu.RLock()
@@ -997,6 +1134,24 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
 
+// SetLimits updates the slot limits and the lifetime of non-local transactions
+// from the given configuration, evicting anything above the new limits.
+func (pool *TxPool) SetLimits(config TxPoolConfig) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.config.AccountSlots = config.AccountSlots
+	pool.config.GlobalSlots = config.GlobalSlots
+	pool.config.AccountQueue = config.AccountQueue
+	pool.config.GlobalQueue = config.GlobalQueue
+	pool.config.Lifetime = config.Lifetime
+	pool.config.PendingLifetime = config.PendingLifetime
+
+	pool.promoteExecutables(nil)
+	log.Info("Transaction pool limits updated", "accountslots", config.AccountSlots, "globalslots", config.GlobalSlots,
+		"accountqueue", config.AccountQueue, "globalqueue", config.GlobalQueue, "lifetime", config.Lifetime, "pendinglifetime", config.PendingLifetime)
+}
+
 // State returns the virtual managed state of the transaction pool.
 func (pool *TxPool) State() *state.ManagedState {
 	pool.mu.RLock()
@@ -1051,17 +1206,54 @@ func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common
 // Pending retrieves all currently processable transactions, groupped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
+//
+// If the spam guard is enabled, the transactions to a deprioritized contract are
+// limited to its share of the next block, the rest of the account's
+// transactions being held back to keep the nonces contiguous.
 func (pool *TxPool) Pending() (map[common.Address]types.Transactions, error) {
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
 
+	var allowance map[common.Address]uint64 // Gas left to each deprioritized contract
+	if pool.spam != nil {
+		allowance = make(map[common.Address]uint64)
+	}
 	pending := make(map[common.Address]types.Transactions)
 	for addr, list := range pool.pending {
-		pending[addr] = list.Flatten()
+		txs := list.Flatten()
+		if allowance != nil {
+			for i, tx := range txs {
+				if !pool.spamContract(tx.To()) {
+					continue
+				}
+				left, ok := allowance[*tx.To()]
+				if !ok {
+					left = pool.spam.allowance(pool.currentMaxGas)
+				}
+				if tx.Gas() > left {
+					spamDeferredCounter.Inc(int64(len(txs) - i))
+					txs = txs[:i]
+					break
+				}
+				allowance[*tx.To()] = left - tx.Gas()
+			}
+			if len(txs) == 0 {
+				continue
+			}
+		}
+		pending[addr] = txs
 	}
 	return pending, nil
 }
 
+// spamContract reports whether transactions to the given recipient are
+// deprioritized, i.e. it is a contract exceeding its share of recent block gas.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) spamContract(to *common.Address) bool {
+	return pool.spam != nil && pool.spam.hogging(to) && pool.currentState.GetCodeSize(*to) > 0
+}
+
 //YY 获取pending中剩余的交易（广播区块头后触发）
 //区块产生后将Pending中剩余的交易放入区块定时中，如果二十个区块还没有被打包则删除，如果已经被打包了则也删除
 func (pool *TxPool) getPendingTx() {
@@ -1551,6 +1743,10 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if err != nil {
 		return ErrInvalidSender
 	}
+	// Reject transactions replayable on other chains if so configured
+	if !tx.Protected() && !pool.config.UnprotectedAllowed(tx.Hash()) {
+		return ErrUnprotectedTx
+	}
 	//YY 验证当V值大于128时，如果扩展交易为空则直接丢弃该交易并返回交易不合法
 	if tx.GetTxV().Cmp(big.NewInt(128)) > 0 && len(txEx) <= 0 {
 		return ErrTXWrongful
@@ -1560,6 +1756,10 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if /*!local && */ pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
 		return ErrUnderpriced
 	}
+	// The gas price acts as the fee cap once dynamic fees are active
+	if pool.pendingFee != nil && pool.pendingFee.Cmp(tx.GasPrice()) > 0 {
+		return ErrFeeCapTooLow
+	}
 	// Ensure the transaction adheres to nonce ordering
 	if pool.currentState.GetNonce(from) > tx.Nonce() {
 		return ErrNonceTooLow
@@ -1671,6 +1871,12 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 
 	// If the transaction pool is full, discard underpriced transactions
 	if uint64(pool.all.Count()) >= pool.config.GlobalSlots+pool.config.GlobalQueue {
+		// If the new transaction feeds a spamming contract, don't accept it
+		if !local && pool.spamContract(tx.To()) {
+			log.Trace("Discarding transaction to deprioritized contract", "hash", hash, "to", tx.To())
+			spamRejectedCounter.Inc(1)
+			return false, ErrSpamDeprioritized
+		}
 		// If the new transaction is underpriced, don't accept it
 		if !local && pool.priced.Underpriced(tx, pool.locals) {
 			log.Trace("Discarding underpriced transaction", "hash", hash, "price", tx.GasPrice())
@@ -2287,14 +2493,16 @@ func (as *accountSet) add(addr common.Address) {
 // peeking into the pool in TxPool.Get without having to acquire the widely scoped
 // TxPool.mu mutex.
 type txLookup struct {
-	all  map[common.Hash]*types.Transaction
-	lock sync.RWMutex
+	all   map[common.Hash]*types.Transaction
+	added map[common.Hash]time.Time // Time each transaction entered the pool
+	lock  sync.RWMutex
 }
 
 // newTxLookup returns a new txLookup structure.
 func newTxLookup() *txLookup {
 	return &txLookup{
-		all: make(map[common.Hash]*types.Transaction),
+		all:   make(map[common.Hash]*types.Transaction),
+		added: make(map[common.Hash]time.Time),
 	}
 }
 
@@ -2326,12 +2534,26 @@ func (t *txLookup) Count() int {
 	return len(t.all)
 }
 
+// Added returns the time the transaction with the given hash was added to the
+// lookup, if it is known.
+func (t *txLookup) Added(hash common.Hash) (time.Time, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	added, ok := t.added[hash]
+	return added, ok
+}
+
 // Add adds a transaction to the lookup.
 func (t *txLookup) Add(tx *types.Transaction) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
-	t.all[tx.Hash()] = tx
+	hash := tx.Hash()
+	t.all[hash] = tx
+	if _, ok := t.added[hash]; !ok {
+		t.added[hash] = time.Now()
+	}
 }
 
 // Remove removes a transaction from the lookup.
@@ -2340,6 +2562,7 @@ func (t *txLookup) Remove(hash common.Hash) {
 	defer t.lock.Unlock()
 
 	delete(t.all, hash)
+	delete(t.added, hash)
 }
 
 //
@@ -222,6 +222,138 @@ func TestStateChangeDuringTransactionPoolReset(t *testing.T) {
 	}
 }
 
+// Tests that transactions priced below the base fee of the next block are
+// rejected once the dynamic fee fork is active.
+func TestTransactionFeeCapTooLow(t *testing.T) {
+	t.Parallel()
+
+	config := *params.TestChainConfig
+	config.DynamicFeeBlock = big.NewInt(0)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(mandb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+	pool := NewTxPool(testTxPoolConfig, &config, blockchain, "")
+	defer pool.Stop()
+
+	if pool.pendingFee == nil || pool.pendingFee.Uint64() != params.InitialBaseFee {
+		t.Fatalf("pending base fee mismatch: have %v, want %v", pool.pendingFee, params.InitialBaseFee)
+	}
+	// Raise the base fee above the pool's minimal gas price to exercise the check
+	baseFee := new(big.Int).Mul(pool.gasPrice, big.NewInt(2))
+	pool.pendingFee = baseFee
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(from, new(big.Int).Mul(baseFee, big.NewInt(1000000)))
+
+	if err := pool.AddRemote(pricedTransaction(0, 100000, new(big.Int).Sub(baseFee, big.NewInt(1)), key)); err != ErrFeeCapTooLow {
+		t.Fatalf("adding transaction below base fee error mismatch: have %v, want %v", err, ErrFeeCapTooLow)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, baseFee, key)); err == ErrFeeCapTooLow {
+		t.Fatalf("transaction paying the base fee rejected: %v", err)
+	}
+}
+
+func TestTransactionRejectUnprotected(t *testing.T) {
+	t.Parallel()
+
+	key, _ := crypto.GenerateKey()
+	price := new(big.Int).SetUint64(testTxPoolConfig.PriceLimit)
+	nonce := params.NonceAddOne // account nonces start with the top bit set
+	grace := pricedTransaction(nonce+1, 100000, price, key)
+
+	config := testTxPoolConfig
+	config.RejectUnprotected = true
+	config.UnprotectedGrace = []common.Hash{grace.Hash()}
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(mandb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+	pool := NewTxPool(config, params.TestChainConfig, blockchain, "")
+	defer pool.Stop()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000000000000))
+
+	if err := pool.AddRemote(pricedTransaction(nonce, 100000, price, key)); err != ErrUnprotectedTx {
+		t.Fatalf("adding unprotected transaction error mismatch: have %v, want %v", err, ErrUnprotectedTx)
+	}
+	protected, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(100), 100000, price, nil), pool.signer, key)
+	if err := pool.AddRemote(protected); err != nil {
+		t.Fatalf("failed to add protected transaction: %v", err)
+	}
+	if err := pool.AddRemote(grace); err != nil {
+		t.Fatalf("failed to add grace listed unprotected transaction: %v", err)
+	}
+}
+
+// Tests that transactions to a contract exceeding its share of the recent block
+// gas are limited to its share of the next block and refused while the pool is
+// full, until the spam ages out of the window.
+func TestTransactionSpamGuard(t *testing.T) {
+	t.Parallel()
+
+	config := testTxPoolConfig
+	config.SpamGuard = true
+	config.SpamShare = 0.25
+	config.SpamWindow = time.Minute
+	config.GlobalSlots = 5
+	config.GlobalQueue = 0
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(mandb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+	pool := NewTxPool(config, params.TestChainConfig, blockchain, "")
+	defer pool.Stop()
+
+	contract := common.Address{0x01}
+	pool.currentState.SetCode(contract, []byte{0x00})
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(from, big.NewInt(1000000000000000000))
+
+	price := new(big.Int).SetUint64(testTxPoolConfig.PriceLimit)
+	spam := func(nonce uint64) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, contract, big.NewInt(0), 100000, price, nil), pool.signer, key)
+		return tx
+	}
+	block := func(number, time uint64, txs ...*types.Transaction) *types.Block {
+		header := &types.Header{Number: new(big.Int).SetUint64(number), Time: new(big.Int).SetUint64(time), GasLimit: 1000000}
+		return types.NewBlock(header, txs, nil, nil)
+	}
+	// Spend 30% of a block on the contract and fill the pool with more
+	pool.spam.addBlock(block(1, 100, spam(0), spam(1), spam(2)))
+
+	nonce := params.NonceAddOne
+	for i := uint64(0); i < 5; i++ {
+		if err := pool.AddRemote(spam(nonce + i)); err != nil {
+			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
+		}
+	}
+	if err := pool.AddRemote(spam(nonce + 5)); err != ErrSpamDeprioritized {
+		t.Fatalf("adding transaction to full pool error mismatch: have %v, want %v", err, ErrSpamDeprioritized)
+	}
+	pending, _ := pool.Pending()
+	if len(pending[from]) != 2 {
+		t.Fatalf("pending transactions of deprioritized contract mismatch: have %d, want %d", len(pending[from]), 2)
+	}
+	// Another 30% keeps the contract above its share, but once the first block
+	// leaves the window it drops below
+	pool.spam.addBlock(block(2, 130, spam(3), spam(4), spam(5)))
+	if pending, _ = pool.Pending(); len(pending[from]) != 2 {
+		t.Fatalf("pending transactions of deprioritized contract mismatch: have %d, want %d", len(pending[from]), 2)
+	}
+	pool.spam.addBlock(block(3, 170))
+	if pending, _ = pool.Pending(); len(pending[from]) != 5 {
+		t.Fatalf("pending transactions of released contract mismatch: have %d, want %d", len(pending[from]), 5)
+	}
+	// Allow-listed contracts are never deprioritized
+	config.SpamAllow = []common.Address{contract}
+	guard := newSpamGuard(&config)
+	guard.addBlock(block(1, 100, spam(0), spam(1), spam(2)))
+	if guard.hogging(&contract) {
+		t.Fatalf("allow-listed contract deprioritized")
+	}
+}
+
 func TestInvalidTransactions(t *testing.T) {
 	t.Parallel()
 
@@ -356,6 +488,39 @@ func TestTransactionChainFork(t *testing.T) {
 	}
 }
 
+// rewoundBlockChain is a test chain whose blocks above the head were deleted
+// by a SetHead rewind.
+type rewoundBlockChain struct {
+	*testBlockChain
+}
+
+func (bc *rewoundBlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return nil
+}
+
+// Tests that resetting the pool from a head deleted by a chain rewind does not
+// try to reinject the transactions of the discarded blocks.
+func TestTransactionResetAfterRewind(t *testing.T) {
+	t.Parallel()
+
+	pool, _ := setupTxPool()
+	defer pool.Stop()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(mandb.NewMemDatabase()))
+	pool.chain = &rewoundBlockChain{&testBlockChain{statedb, 1000000, new(event.Feed)}}
+
+	oldHead := &types.Header{Number: big.NewInt(5), GasLimit: 1000000}
+	newHead := &types.Header{Number: big.NewInt(2), GasLimit: 2000000}
+	pool.lockedReset(oldHead, newHead)
+
+	if pool.currentState != statedb {
+		t.Fatalf("pool state not reset to the new head")
+	}
+	if pool.currentMaxGas != newHead.GasLimit {
+		t.Fatalf("gas limit mismatch: have %d, want %d", pool.currentMaxGas, newHead.GasLimit)
+	}
+}
+
 func TestTransactionDoubleNonce(t *testing.T) {
 	t.Parallel()
 
@@ -912,6 +1077,115 @@ func testTransactionQueueTimeLimiting(t *testing.T, nolocals bool) {
 	}
 }
 
+// Tests that queued transactions of inactive accounts expire after the lifetime
+// and pending ones only after the pending lifetime if configured, except local
+// ones, that flushing an account drops all of its transactions, and that the
+// dropped transactions are announced.
+func TestTransactionExpiration(t *testing.T) {
+	t.Parallel()
+
+	pool, remote := setupTxPool()
+	defer pool.Stop()
+
+	local, _ := crypto.GenerateKey()
+	localAddr := crypto.PubkeyToAddress(local.PublicKey)
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000000000000))
+	pool.currentState.AddBalance(localAddr, big.NewInt(1000000000000000000))
+
+	events := make(chan DroppedTxsEvent, 2)
+	sub := pool.SubscribeDroppedTxsEvent(events)
+	defer sub.Unsubscribe()
+
+	price := new(big.Int).SetUint64(testTxPoolConfig.PriceLimit)
+	nonce := params.NonceAddOne // account nonces start with the top bit set
+	for _, n := range []uint64{nonce, nonce + 1} {
+		if err := pool.AddRemote(pricedTransaction(n, 100000, price, remote)); err != nil {
+			t.Fatalf("failed to add remote transaction %d: %v", n, err)
+		}
+	}
+	if err := pool.AddLocal(pricedTransaction(nonce, 100000, price, local)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	// Queue a remote transaction, the pool promotes gapped ones too
+	now := time.Now()
+	queued := pricedTransaction(nonce+3, 100000, price, remote)
+
+	pool.mu.Lock()
+	pool.enqueueTx(queued.Hash(), queued)
+	pool.beats[crypto.PubkeyToAddress(remote.PublicKey)] = now
+	pool.mu.Unlock()
+
+	if pending, queued := pool.Stats(); pending != 3 || queued != 1 {
+		t.Fatalf("pool size mismatch: have %d/%d pending/queued, want 3/1", pending, queued)
+	}
+	// Pending transactions never expire by default, queued ones after the lifetime
+	pool.mu.Lock()
+	pool.expire(now.Add(testTxPoolConfig.Lifetime / 2))
+	pool.mu.Unlock()
+
+	if pending, queued := pool.Stats(); pending != 3 || queued != 1 {
+		t.Fatalf("pool size mismatch: have %d/%d pending/queued, want 3/1", pending, queued)
+	}
+	pool.mu.Lock()
+	pool.expire(now.Add(testTxPoolConfig.Lifetime + time.Second))
+	pool.mu.Unlock()
+
+	if pending, queued := pool.Stats(); pending != 3 || queued != 0 {
+		t.Fatalf("pool size mismatch: have %d/%d pending/queued, want 3/0", pending, queued)
+	}
+	select {
+	case ev := <-events:
+		if len(ev.Txs) != 1 || ev.Reason != "expired" {
+			t.Fatalf("drop event mismatch: have %d txs %q, want 1 expired", len(ev.Txs), ev.Reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expired transactions not announced")
+	}
+	// Pending transactions younger than the pending lifetime are untouched
+	pool.mu.Lock()
+	pool.config.PendingLifetime = time.Minute
+	pool.expire(now.Add(30 * time.Second))
+	pool.mu.Unlock()
+
+	if pending, queued := pool.Stats(); pending != 3 || queued != 0 {
+		t.Fatalf("pool size mismatch: have %d/%d pending/queued, want 3/0", pending, queued)
+	}
+	// Expire the remote pending transactions
+	pool.mu.Lock()
+	pool.expire(now.Add(2 * time.Minute))
+	pool.mu.Unlock()
+
+	if pending, queued := pool.Stats(); pending != 1 || queued != 0 {
+		t.Fatalf("pool size mismatch: have %d/%d pending/queued, want 1/0", pending, queued)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	select {
+	case ev := <-events:
+		if len(ev.Txs) != 2 || ev.Reason != "expired" {
+			t.Fatalf("drop event mismatch: have %d txs %q, want 2 expired", len(ev.Txs), ev.Reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expired transactions not announced")
+	}
+	// Flush the local account too
+	if flushed := pool.Flush(localAddr); len(flushed) != 1 {
+		t.Fatalf("flushed transaction count mismatch: have %d, want 1", len(flushed))
+	}
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Fatalf("pool size mismatch: have %d/%d pending/queued, want 0/0", pending, queued)
+	}
+	select {
+	case ev := <-events:
+		if len(ev.Txs) != 1 || ev.Reason != "flushed" {
+			t.Fatalf("drop event mismatch: have %d txs %q, want 1 flushed", len(ev.Txs), ev.Reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("flushed transactions not announced")
+	}
+}
+
 // Tests that even if the transaction count belonging to a single account goes
 // above some threshold, as long as the transactions are executable, they are
 // accepted.
@@ -1052,6 +1326,50 @@ func TestTransactionPendingGlobalLimiting(t *testing.T) {
 	}
 }
 
+// Tests that lowering the pool limits at runtime evicts the transactions above
+// the new allowance.
+func TestTransactionPoolSetLimits(t *testing.T) {
+	t.Parallel()
+
+	// Create the pool with the default limits and fill it with transactions
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(mandb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain, "")
+	defer pool.Stop()
+
+	keys := make([]*ecdsa.PrivateKey, 5)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(params.Ether))
+	}
+	price := new(big.Int).SetUint64(testTxPoolConfig.PriceLimit)
+	nonce := params.NonceAddOne // account nonces start with the top bit set
+
+	txs := types.Transactions{}
+	for _, key := range keys {
+		for j := 0; j < int(testTxPoolConfig.AccountSlots)*2; j++ {
+			txs = append(txs, pricedTransaction(nonce+uint64(j), 100000, price, key))
+		}
+	}
+	pool.AddRemotes(txs)
+
+	if pending, _ := pool.Stats(); pending != len(txs) {
+		t.Fatalf("pending transactions mismatch: have %d, want %d", pending, len(txs))
+	}
+	// Lower the global limit and ensure the pool shrinks accordingly
+	config := testTxPoolConfig
+	config.GlobalSlots = config.AccountSlots * uint64(len(keys))
+	pool.SetLimits(config)
+
+	if pending, _ := pool.Stats(); pending > int(config.GlobalSlots) {
+		t.Fatalf("total pending transactions overflow allowance: %d > %d", pending, config.GlobalSlots)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that if transactions start being capped, transactions are also removed from 'all'
 func TestTransactionCapClearsFromAll(t *testing.T) {
 	t.Parallel()
@@ -0,0 +1,150 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package core
+
+import (
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/metrics"
+)
+
+var (
+	spamRecipientsGauge = metrics.NewRegisteredGauge("txpool/spam/recipients", nil) // Recipients exceeding their share
+	spamDeferredCounter = metrics.NewRegisteredCounter("txpool/spam/deferred", nil) // Held back from the miner
+	spamRejectedCounter = metrics.NewRegisteredCounter("txpool/spam/rejected", nil) // Refused while the pool is full
+)
+
+// spamBlock is the gas spent on each recipient by the transactions of a
+// recent block.
+type spamBlock struct {
+	number   uint64
+	time     uint64
+	gasLimit uint64
+	gas      map[common.Address]uint64
+}
+
+// spamGuard measures the share of the block gas of a recent time window taken
+// by the transactions sent to each recipient. Contracts exceeding the
+// configured share are deprioritized by the pool: only their share of the next
+// block is handed to the miner and new transactions to them are refused while
+// the pool is full, so a single spammer can't crowd out everybody else.
+//
+// As receipts are not available to the pool, the gas limits of the included
+// transactions stand in for the gas they used.
+type spamGuard struct {
+	share  float64
+	window uint64 // in seconds of block time
+	allow  map[common.Address]bool
+
+	blocks   []*spamBlock              // Recent blocks, ascending by number
+	gas      map[common.Address]uint64 // Gas spent on each recipient within the window
+	gasLimit uint64                    // Gas capacity of the blocks within the window
+	hogs     map[common.Address]bool   // Recipients above their share, allow-listed ones excluded
+}
+
+func newSpamGuard(config *TxPoolConfig) *spamGuard {
+	guard := &spamGuard{
+		share:  config.SpamShare,
+		window: uint64(config.SpamWindow / time.Second),
+		allow:  make(map[common.Address]bool),
+		gas:    make(map[common.Address]uint64),
+		hogs:   make(map[common.Address]bool),
+	}
+	for _, addr := range config.SpamAllow {
+		guard.allow[addr] = true
+	}
+	return guard
+}
+
+// addBlock accounts the transactions of a new head block and expires the
+// blocks falling out of the window. Blocks of the same or a higher number are
+// considered reorged out and replaced.
+func (g *spamGuard) addBlock(block *types.Block) {
+	number := block.NumberU64()
+	for len(g.blocks) > 0 && g.blocks[len(g.blocks)-1].number >= number {
+		g.remove(len(g.blocks) - 1)
+	}
+	entry := &spamBlock{
+		number:   number,
+		time:     block.Header().Time.Uint64(),
+		gasLimit: block.GasLimit(),
+		gas:      make(map[common.Address]uint64),
+	}
+	for _, tx := range block.Transactions() {
+		if to := tx.To(); to != nil {
+			entry.gas[*to] += tx.Gas()
+		}
+	}
+	g.blocks = append(g.blocks, entry)
+	g.gasLimit += entry.gasLimit
+	for addr, gas := range entry.gas {
+		g.gas[addr] += gas
+	}
+	for len(g.blocks) > 1 && g.blocks[0].time+g.window < entry.time {
+		g.remove(0)
+	}
+	g.update()
+}
+
+// remove drops the block at the given index from the window.
+func (g *spamGuard) remove(index int) {
+	entry := g.blocks[index]
+	g.blocks = append(g.blocks[:index], g.blocks[index+1:]...)
+
+	g.gasLimit -= entry.gasLimit
+	for addr, gas := range entry.gas {
+		if g.gas[addr] -= gas; g.gas[addr] == 0 {
+			delete(g.gas, addr)
+		}
+	}
+}
+
+// update recomputes the set of recipients exceeding their share of the window.
+func (g *spamGuard) update() {
+	limit := g.share * float64(g.gasLimit)
+
+	hogs := make(map[common.Address]bool)
+	for addr, gas := range g.gas {
+		if !g.allow[addr] && float64(gas) > limit {
+			hogs[addr] = true
+			if !g.hogs[addr] {
+				log.Info("Deprioritizing transactions to recipient exceeding its share of block gas", "to", addr, "gas", gas, "capacity", g.gasLimit)
+			}
+		}
+	}
+	for addr := range g.hogs {
+		if !hogs[addr] {
+			log.Info("Recipient back within its share of block gas", "to", addr)
+		}
+	}
+	g.hogs = hogs
+	spamRecipientsGauge.Update(int64(len(hogs)))
+}
+
+// hogging reports whether transactions to the given recipient are to be
+// deprioritized.
+func (g *spamGuard) hogging(to *common.Address) bool {
+	return to != nil && g.hogs[*to]
+}
+
+// allowance returns the gas of a block with the given limit that transactions
+// to a deprioritized contract may take.
+func (g *spamGuard) allowance(gasLimit uint64) uint64 {
+	return uint64(g.share * float64(gasLimit))
+}
@@ -91,6 +91,9 @@ type Header struct {
 	MixDigest common.Hash `json:"mixHash"          gencodec:"required"`
 	Nonce     BlockNonce  `json:"nonce"            gencodec:"required"`
 	Version   []byte      `json:"version"              gencodec:"required"`
+
+	// BaseFee was added by the dynamic fee fork and is ignored in legacy headers.
+	BaseFee *big.Int `json:"baseFeePerGas" rlp:"optional"`
 }
 
 // field type overrides for gencodec
@@ -101,6 +104,7 @@ type headerMarshaling struct {
 	GasUsed    hexutil.Uint64
 	Time       *hexutil.Big
 	Extra      hexutil.Bytes
+	BaseFee    *hexutil.Big
 	Hash       common.Hash `json:"hash"` // adds call to Hash() in MarshalJSON
 }
 
@@ -112,7 +116,7 @@ func (h *Header) Hash() common.Hash {
 
 // HashNoNonce returns the hash which is used as input for the proof-of-work search.
 func (h *Header) HashNoNonce() common.Hash {
-	return rlpHash([]interface{}{
+	return rlpHash(h.withBaseFee([]interface{}{
 		h.ParentHash,
 		h.UncleHash,
 		h.Leader,
@@ -130,11 +134,11 @@ func (h *Header) HashNoNonce() common.Hash {
 		h.Signatures,
 		h.Extra,
 		h.Version,
-	})
+	}))
 }
 
 func (h *Header) HashNoSignsAndNonce() common.Hash {
-	return rlpHash([]interface{}{
+	return rlpHash(h.withBaseFee([]interface{}{
 		h.ParentHash,
 		h.UncleHash,
 		h.Leader,
@@ -151,7 +155,16 @@ func (h *Header) HashNoSignsAndNonce() common.Hash {
 		h.NetTopology,
 		h.Extra,
 		h.Version,
-	})
+	}))
+}
+
+// withBaseFee appends the base fee to the hashed header fields if the header
+// has one, keeping the hashes of legacy headers unchanged.
+func (h *Header) withBaseFee(fields []interface{}) []interface{} {
+	if h.BaseFee != nil {
+		fields = append(fields, h.BaseFee)
+	}
+	return fields
 }
 
 // Size returns the approximate memory used by all internal contents. It is used
@@ -355,6 +368,9 @@ func CopyHeader(h *Header) *Header {
 		cpy.Version = make([]byte, len(h.Version))
 		copy(cpy.Version, h.Version)
 	}
+	if h.BaseFee != nil {
+		cpy.BaseFee = new(big.Int).Set(h.BaseFee)
+	}
 	return &cpy
 }
 
@@ -421,6 +437,14 @@ func (b *Block) GasUsed() uint64      { return b.header.GasUsed }
 func (b *Block) Difficulty() *big.Int { return new(big.Int).Set(b.header.Difficulty) }
 func (b *Block) Time() *big.Int       { return new(big.Int).Set(b.header.Time) }
 
+// BaseFee returns the base fee of the block, or nil before the dynamic fee fork.
+func (b *Block) BaseFee() *big.Int {
+	if b.header.BaseFee == nil {
+		return nil
+	}
+	return new(big.Int).Set(b.header.BaseFee)
+}
+
 func (b *Block) NumberU64() uint64        { return b.header.Number.Uint64() }
 func (b *Block) MixDigest() common.Hash   { return b.header.MixDigest }
 func (b *Block) Nonce() uint64            { return binary.BigEndian.Uint64(b.header.Nonce[:]) }
@@ -68,3 +68,36 @@ func TestBlockEncoding(t *testing.T) {
 		t.Errorf("encoded block mismatch:\ngot:  %x\nwant: %x", ourBlockEnc, blockEnc)
 	}
 }
+
+// Tests that the optional base fee is left out of legacy headers and survives a
+// round trip once set.
+func TestHeaderBaseFeeEncoding(t *testing.T) {
+	legacy := &Header{Difficulty: big.NewInt(1), Number: big.NewInt(1), Time: big.NewInt(1)}
+	withFee := CopyHeader(legacy)
+	withFee.BaseFee = big.NewInt(1000000000)
+
+	legacyEnc, _ := rlp.EncodeToBytes(legacy)
+	feeEnc, _ := rlp.EncodeToBytes(withFee)
+	if len(feeEnc) <= len(legacyEnc) {
+		t.Fatalf("base fee not encoded: legacy %d bytes, with fee %d bytes", len(legacyEnc), len(feeEnc))
+	}
+	var dec Header
+	if err := rlp.DecodeBytes(legacyEnc, &dec); err != nil {
+		t.Fatalf("failed to decode legacy header: %v", err)
+	}
+	if dec.BaseFee != nil {
+		t.Errorf("legacy header decoded with base fee %v", dec.BaseFee)
+	}
+	if err := rlp.DecodeBytes(feeEnc, &dec); err != nil {
+		t.Fatalf("failed to decode header with base fee: %v", err)
+	}
+	if dec.BaseFee == nil || dec.BaseFee.Cmp(withFee.BaseFee) != 0 {
+		t.Errorf("base fee mismatch: have %v, want %v", dec.BaseFee, withFee.BaseFee)
+	}
+	if dec.Hash() != withFee.Hash() {
+		t.Errorf("hash mismatch after round trip")
+	}
+	if legacy.HashNoNonce() == withFee.HashNoNonce() {
+		t.Errorf("base fee not covered by the seal hash")
+	}
+}
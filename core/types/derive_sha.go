@@ -29,13 +29,25 @@ type DerivableList interface {
 	GetRlp(i int) []byte
 }
 
+// DeriveSha computes the root of the trie mapping the RLP encoded indexes of
+// the list to its items. The RLP encoding of 0 sorts between those of 127 and
+// 128, so the items are inserted in that order into a stack trie.
 func DeriveSha(list DerivableList) common.Hash {
 	keybuf := new(bytes.Buffer)
-	trie := new(trie.Trie)
-	for i := 0; i < list.Len(); i++ {
+	trie := trie.NewStackTrie(nil)
+	insert := func(i int) {
 		keybuf.Reset()
 		rlp.Encode(keybuf, uint(i))
 		trie.Update(keybuf.Bytes(), list.GetRlp(i))
 	}
+	for i := 1; i < list.Len() && i <= 0x7f; i++ {
+		insert(i)
+	}
+	if list.Len() > 0 {
+		insert(0)
+	}
+	for i := 0x80; i < list.Len(); i++ {
+		insert(i)
+	}
 	return trie.Hash()
 }
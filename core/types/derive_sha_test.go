@@ -0,0 +1,53 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package types
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/matrix/go-matrix/rlp"
+	"github.com/matrix/go-matrix/trie"
+)
+
+type rawList [][]byte
+
+func (l rawList) Len() int            { return len(l) }
+func (l rawList) GetRlp(i int) []byte { return l[i] }
+
+func TestDeriveSha(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 2, 127, 128, 129, 300} {
+		list := make(rawList, n)
+		for i := range list {
+			list[i] = make([]byte, 1+rng.Intn(100))
+			rng.Read(list[i])
+		}
+		// the same items inserted by index into a regular trie
+		keybuf := new(bytes.Buffer)
+		want := new(trie.Trie)
+		for i := range list {
+			keybuf.Reset()
+			rlp.Encode(keybuf, uint(i))
+			want.Update(keybuf.Bytes(), list[i])
+		}
+		if have := DeriveSha(list); have != want.Hash() {
+			t.Errorf("%d items: have root %x, want %x", n, have, want.Hash())
+		}
+	}
+}
@@ -51,6 +51,7 @@ func (h Header) MarshalJSON() ([]byte, error) {
 		NetTopology common.NetTopology `json:"nettopology"        gencodec:"required"`
 		Signatures  []common.Signature `json:"signatures "        gencodec:"required"`
 		Version     []byte             `json:" version "              gencodec:"required"`
+		BaseFee     *hexutil.Big       `json:"baseFeePerGas" rlp:"optional"`
 
 		Hash common.Hash `json:"hash"`
 	}
@@ -75,6 +76,7 @@ func (h Header) MarshalJSON() ([]byte, error) {
 	enc.NetTopology = h.NetTopology
 	enc.Signatures = h.Signatures
 	enc.Version = h.Version
+	enc.BaseFee = (*hexutil.Big)(h.BaseFee)
 	enc.Hash = h.Hash()
 	return json.Marshal(&enc)
 }
@@ -102,6 +104,7 @@ func (h *Header) UnmarshalJSON(input []byte) error {
 		NetTopology *common.NetTopology `json:"nettopology"        gencodec:"required"`
 		Signatures  *[]common.Signature `json:"signatures "        gencodec:"required"`
 		Version     *[]byte             `json:" version "              gencodec:"required"`
+		BaseFee     *hexutil.Big        `json:"baseFeePerGas" rlp:"optional"`
 	}
 	//TODO: 放开注释
 	var dec Header
@@ -193,5 +196,8 @@ func (h *Header) UnmarshalJSON(input []byte) error {
 	}
 	h.NetTopology = *dec.NetTopology
 
+	if dec.BaseFee != nil {
+		h.BaseFee = (*big.Int)(dec.BaseFee)
+	}
 	return nil
 }
@@ -55,6 +55,9 @@ type Receipt struct {
 	TxHash          common.Hash    `json:"transactionHash" gencodec:"required"`
 	ContractAddress common.Address `json:"contractAddress"`
 	GasUsed         uint64         `json:"gasUsed" gencodec:"required"`
+
+	// Call frames recorded for the tracing index, never serialised
+	CallFrames []*CallFrame `json:"-"`
 }
 
 type receiptMarshaling struct {
@@ -0,0 +1,51 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package types
+
+import (
+	"math/big"
+
+	"github.com/matrix/go-matrix/common"
+)
+
+// Call frame types of the tracing index.
+const (
+	CallFrameCall         = "call"
+	CallFrameCallCode     = "callcode"
+	CallFrameDelegateCall = "delegatecall"
+	CallFrameStaticCall   = "staticcall"
+	CallFrameCreate       = "create"
+	CallFrameSelfDestruct = "selfdestruct"
+)
+
+// CallFrame summarises a message call, contract creation or self destruct made
+// while executing a transaction. The transaction itself is the first frame.
+type CallFrame struct {
+	Type         string
+	From         common.Address
+	To           common.Address // Created contract of creations, beneficiary of self destructs
+	Value        *big.Int
+	TraceAddress []uint64 // Child indexes leading to the frame from the transaction frame
+	Subtraces    uint64   // Number of child frames
+	Failed       bool
+}
+
+// TxCallFrames are the call frames of a transaction, in execution order.
+type TxCallFrames struct {
+	TxHash common.Hash
+	Frames []*CallFrame
+}
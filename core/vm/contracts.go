@@ -52,6 +52,30 @@ var PrecompiledContractsByzantium = map[common.Address]PrecompiledContract{
 	common.BytesToAddress([]byte{10}): &MatrixDeposit{},
 }
 
+// PrecompiledContractsKZG contains the pre-compiled Matrix contracts available
+// once the KZG fork enables the data availability commitment precompiles.
+var PrecompiledContractsKZG = map[common.Address]PrecompiledContract{
+	common.BytesToAddress([]byte{1}):  &ecrecover{},
+	common.BytesToAddress([]byte{2}):  &sha256hash{},
+	common.BytesToAddress([]byte{3}):  &ripemd160hash{},
+	common.BytesToAddress([]byte{4}):  &dataCopy{},
+	common.BytesToAddress([]byte{5}):  &bigModExp{},
+	common.BytesToAddress([]byte{6}):  &bn256Add{},
+	common.BytesToAddress([]byte{7}):  &bn256ScalarMul{},
+	common.BytesToAddress([]byte{8}):  &bn256Pairing{},
+	common.BytesToAddress([]byte{10}): &MatrixDeposit{},
+	common.BytesToAddress([]byte{11}): &kzgPointEvaluation{},
+}
+
+// ActivePrecompiles returns the pre-compiled contracts active under the given
+// chain rules.
+func ActivePrecompiles(rules params.Rules) map[common.Address]PrecompiledContract {
+	if rules.IsKZG {
+		return PrecompiledContractsKZG
+	}
+	return PrecompiledContractsByzantium
+}
+
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
 func RunPrecompiledContract(p PrecompiledContract, input []byte, contract *Contract, evm *EVM) (ret []byte, err error) {
 	gas := p.RequiredGas(input)
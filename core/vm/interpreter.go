@@ -35,6 +35,9 @@ type Config struct {
 	NoRecursion bool
 	// Enable recording of SHA3/keccak preimages
 	EnablePreimageRecording bool
+	// Number of transactions of a block executed speculatively in parallel,
+	// with conflicting ones re-executed serially. Zero or one means serial.
+	ParallelTxs int
 	// JumpTable contains the EVM instruction table. This
 	// may be left uninitialised and will be set to the default
 	// table.
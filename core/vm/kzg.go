@@ -0,0 +1,74 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package vm
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/matrix/go-matrix/crypto/kzg"
+	"github.com/matrix/go-matrix/params"
+)
+
+// kzgPointEvaluationInputLength is the size of a point evaluation input: the
+// commitment, the evaluation point and value, and the proof.
+const kzgPointEvaluationInputLength = 64 + 32 + 32 + 64
+
+var (
+	// errKZGBadInput is returned if the point evaluation input is malformed.
+	errKZGBadInput = errors.New("bad kzg point evaluation input")
+
+	// errKZGNoSetup is returned if the node runs without a trusted setup.
+	errKZGNoSetup = errors.New("kzg trusted setup not loaded")
+)
+
+// kzgPointEvaluation implements the KZG point evaluation precompile, verifying
+// the proof that a committed polynomial evaluates to a given value at a given
+// point against the trusted setup loaded by the node.
+type kzgPointEvaluation struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *kzgPointEvaluation) RequiredGas(input []byte) uint64 {
+	return params.KZGPointEvaluationGas
+}
+
+func (c *kzgPointEvaluation) Run(input []byte, contract *Contract, evm *EVM) ([]byte, error) {
+	if len(input) != kzgPointEvaluationInputLength {
+		return nil, errKZGBadInput
+	}
+	setup := kzg.ActiveSetup()
+	if setup == nil {
+		return nil, errKZGNoSetup
+	}
+	commitment, err := newCurvePoint(input[:64])
+	if err != nil {
+		return nil, err
+	}
+	z := new(big.Int).SetBytes(input[64:96])
+	y := new(big.Int).SetBytes(input[96:128])
+	if z.Cmp(kzg.Modulus) >= 0 || y.Cmp(kzg.Modulus) >= 0 {
+		return nil, errKZGBadInput
+	}
+	proof, err := newCurvePoint(input[128:192])
+	if err != nil {
+		return nil, err
+	}
+	if setup.Verify(commitment, z, y, proof) {
+		return true32Byte, nil
+	}
+	return false32Byte, nil
+}
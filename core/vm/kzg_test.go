@@ -0,0 +1,86 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/crypto/kzg"
+	"github.com/matrix/go-matrix/params"
+)
+
+var kzgPointEvaluationAddress = common.BytesToAddress([]byte{11})
+
+// Tests that the point evaluation precompile is only available after the fork.
+func TestKZGPrecompileActivation(t *testing.T) {
+	config := *params.TestChainConfig
+	config.KZGBlock = big.NewInt(10)
+
+	for number, want := range map[int64]bool{0: false, 9: false, 10: true, 11: true} {
+		evm := NewEVM(Context{BlockNumber: big.NewInt(number)}, nil, &config, Config{})
+		if _, have := evm.precompiles()[kzgPointEvaluationAddress]; have != want {
+			t.Errorf("block %d: precompile availability mismatch: have %v, want %v", number, have, want)
+		}
+	}
+}
+
+// Tests that the point evaluation precompile verifies proofs against the loaded
+// trusted setup.
+func TestKZGPointEvaluation(t *testing.T) {
+	defer kzg.SetTrustedSetup(nil)
+
+	var (
+		setup = kzg.NewInsecureSetup(big.NewInt(7), 4)
+		poly  = []*big.Int{big.NewInt(3), big.NewInt(1), big.NewInt(4), big.NewInt(1)}
+		z     = big.NewInt(5)
+		p     = PrecompiledContractsKZG[kzgPointEvaluationAddress]
+	)
+	commitment, err := setup.Commit(poly)
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	y, proof, err := setup.Open(poly, z)
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	input := func(y *big.Int) []byte {
+		var blob []byte
+		blob = append(blob, commitment.Marshal()...)
+		blob = append(blob, common.BigToHash(z).Bytes()...)
+		blob = append(blob, common.BigToHash(y).Bytes()...)
+		return append(blob, proof.Marshal()...)
+	}
+	if _, err := p.Run(input(y), nil, nil); err != errKZGNoSetup {
+		t.Fatalf("missing setup error mismatch: have %v, want %v", err, errKZGNoSetup)
+	}
+	kzg.SetTrustedSetup(setup)
+
+	if out, err := p.Run(input(y), nil, nil); err != nil || string(out) != string(true32Byte) {
+		t.Errorf("valid proof result mismatch: have %x, %v", out, err)
+	}
+	if out, err := p.Run(input(new(big.Int).Add(y, common.Big1)), nil, nil); err != nil || string(out) != string(false32Byte) {
+		t.Errorf("invalid proof result mismatch: have %x, %v", out, err)
+	}
+	if _, err := p.Run(input(kzg.Modulus), nil, nil); err != errKZGBadInput {
+		t.Errorf("out of field value error mismatch: have %v, want %v", err, errKZGBadInput)
+	}
+	if _, err := p.Run(input(y)[1:], nil, nil); err != errKZGBadInput {
+		t.Errorf("short input error mismatch: have %v, want %v", err, errKZGBadInput)
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package kzg implements KZG polynomial commitments over the bn256 curve, used
+// by the data availability experiments of the testnet.
+//
+// Polynomials are given by their coefficients in the scalar field of the curve,
+// lowest degree first. A commitment to p is [p(τ)]G1 and an opening proof of
+// p(z) = y is [q(τ)]G1 with q(x) = (p(x) - y) / (x - z), where τ is the secret
+// of the trusted setup.
+package kzg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sync"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/crypto/bn256"
+)
+
+// Modulus is the order of the bn256 groups, the field polynomial coefficients
+// and evaluation points live in.
+var Modulus, _ = new(big.Int).SetString("21888242871839275222246405745257275088548364400416034343698204186575808495617", 10)
+
+var (
+	errInvalidSetup     = errors.New("invalid trusted setup")
+	errPolynomialSize   = errors.New("polynomial exceeds the trusted setup")
+	errScalarNotInField = errors.New("scalar not in field")
+)
+
+var (
+	g1Generator = new(bn256.G1).ScalarBaseMult(big.NewInt(1))
+	g2Generator = new(bn256.G2).ScalarBaseMult(big.NewInt(1))
+)
+
+// TrustedSetup holds the powers of the secret τ of a setup ceremony. Committing
+// to a polynomial of degree d needs [τ^0..τ^d]G1, verifying openings needs just
+// [1]G2 and [τ]G2.
+type TrustedSetup struct {
+	G1 []*bn256.G1 // [τ^i]G1, may be empty for verify-only setups
+	G2 []*bn256.G2 // [τ^i]G2, at least the first two powers
+}
+
+// setupJSON is the file format of a trusted setup, holding the points in the
+// encoding of the bn256 precompiles.
+type setupJSON struct {
+	G1 []hexutil.Bytes `json:"g1"`
+	G2 []hexutil.Bytes `json:"g2"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s *TrustedSetup) MarshalJSON() ([]byte, error) {
+	enc := setupJSON{
+		G1: make([]hexutil.Bytes, len(s.G1)),
+		G2: make([]hexutil.Bytes, len(s.G2)),
+	}
+	for i, p := range s.G1 {
+		enc.G1[i] = p.Marshal()
+	}
+	for i, p := range s.G2 {
+		enc.G2[i] = p.Marshal()
+	}
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, validating the decoded setup.
+func (s *TrustedSetup) UnmarshalJSON(input []byte) error {
+	var dec setupJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	setup := TrustedSetup{
+		G1: make([]*bn256.G1, len(dec.G1)),
+		G2: make([]*bn256.G2, len(dec.G2)),
+	}
+	for i, blob := range dec.G1 {
+		setup.G1[i] = new(bn256.G1)
+		if _, err := setup.G1[i].Unmarshal(blob); err != nil {
+			return fmt.Errorf("g1 point %d: %v", i, err)
+		}
+	}
+	for i, blob := range dec.G2 {
+		setup.G2[i] = new(bn256.G2)
+		if _, err := setup.G2[i].Unmarshal(blob); err != nil {
+			return fmt.Errorf("g2 point %d: %v", i, err)
+		}
+	}
+	if err := setup.validate(); err != nil {
+		return err
+	}
+	*s = setup
+	return nil
+}
+
+// validate checks that the setup can verify proofs and that its powers start
+// at the group generators.
+func (s *TrustedSetup) validate() error {
+	if len(s.G2) < 2 {
+		return fmt.Errorf("%v: need at least 2 g2 points, have %d", errInvalidSetup, len(s.G2))
+	}
+	if string(s.G2[0].Marshal()) != string(g2Generator.Marshal()) {
+		return fmt.Errorf("%v: first g2 point is not the generator", errInvalidSetup)
+	}
+	if len(s.G1) > 0 && string(s.G1[0].Marshal()) != string(g1Generator.Marshal()) {
+		return fmt.Errorf("%v: first g1 point is not the generator", errInvalidSetup)
+	}
+	return nil
+}
+
+// LoadTrustedSetup reads a trusted setup from a JSON file.
+func LoadTrustedSetup(file string) (*TrustedSetup, error) {
+	blob, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	setup := new(TrustedSetup)
+	if err := json.Unmarshal(blob, setup); err != nil {
+		return nil, fmt.Errorf("trusted setup %s: %v", file, err)
+	}
+	return setup, nil
+}
+
+// NewInsecureSetup derives a setup supporting polynomials of the given degree
+// from a known secret. Anyone knowing the secret can forge proofs, so it is only
+// suitable for tests and throwaway devnets.
+func NewInsecureSetup(secret *big.Int, degree int) *TrustedSetup {
+	setup := &TrustedSetup{
+		G1: make([]*bn256.G1, degree+1),
+		G2: make([]*bn256.G2, 2),
+	}
+	power := big.NewInt(1)
+	for i := range setup.G1 {
+		setup.G1[i] = new(bn256.G1).ScalarBaseMult(power)
+		power = new(big.Int).Mod(new(big.Int).Mul(power, secret), Modulus)
+	}
+	setup.G2[0] = new(bn256.G2).ScalarBaseMult(big.NewInt(1))
+	setup.G2[1] = new(bn256.G2).ScalarBaseMult(new(big.Int).Mod(secret, Modulus))
+	return setup
+}
+
+// Hash returns the hash of the verification points of the setup, which chain
+// configurations pin to make sure all nodes verify against the same setup.
+func (s *TrustedSetup) Hash() common.Hash {
+	return crypto.Keccak256Hash(s.G2[0].Marshal(), s.G2[1].Marshal())
+}
+
+// Commit computes the commitment to a polynomial.
+func (s *TrustedSetup) Commit(poly []*big.Int) (*bn256.G1, error) {
+	if len(poly) > len(s.G1) {
+		return nil, errPolynomialSize
+	}
+	commitment := new(bn256.G1).ScalarBaseMult(new(big.Int))
+	for i, coeff := range poly {
+		if !inField(coeff) {
+			return nil, fmt.Errorf("coefficient %d: %v", i, errScalarNotInField)
+		}
+		commitment = new(bn256.G1).Add(commitment, new(bn256.G1).ScalarMult(s.G1[i], coeff))
+	}
+	return commitment, nil
+}
+
+// Open evaluates a polynomial at z, returning the value along with the proof
+// of the evaluation.
+func (s *TrustedSetup) Open(poly []*big.Int, z *big.Int) (*big.Int, *bn256.G1, error) {
+	if !inField(z) {
+		return nil, nil, errScalarNotInField
+	}
+	for i, coeff := range poly {
+		if !inField(coeff) {
+			return nil, nil, fmt.Errorf("coefficient %d: %v", i, errScalarNotInField)
+		}
+	}
+	if len(poly) == 0 {
+		proof, err := s.Commit(nil)
+		return new(big.Int), proof, err
+	}
+	// Divide by (x - z) using Horner's scheme, the remainder is p(z)
+	quotient := make([]*big.Int, len(poly)-1)
+	acc := new(big.Int).Set(poly[len(poly)-1])
+	for i := len(poly) - 2; i >= 0; i-- {
+		quotient[i] = acc
+		acc = new(big.Int).Mul(acc, z)
+		acc.Add(acc, poly[i])
+		acc.Mod(acc, Modulus)
+	}
+	proof, err := s.Commit(quotient)
+	if err != nil {
+		return nil, nil, err
+	}
+	return acc, proof, nil
+}
+
+// Verify checks the proof that the polynomial committed to evaluates to y at z,
+// i.e. that e(C - [y]G1, G2) = e(π, [τ - z]G2). It is evaluated as the single
+// pairing product e(C - [y]G1 + [z]π, G2) · e(-π, [τ]G2) = 1.
+func (s *TrustedSetup) Verify(commitment *bn256.G1, z, y *big.Int, proof *bn256.G1) bool {
+	if !inField(z) || !inField(y) {
+		return false
+	}
+	lhs := new(bn256.G1).Add(commitment, new(bn256.G1).Neg(new(bn256.G1).ScalarBaseMult(y)))
+	lhs = new(bn256.G1).Add(lhs, new(bn256.G1).ScalarMult(proof, z))
+
+	return bn256.PairingCheck(
+		[]*bn256.G1{lhs, new(bn256.G1).Neg(proof)},
+		[]*bn256.G2{s.G2[0], s.G2[1]},
+	)
+}
+
+// inField reports whether a scalar is a canonical field element.
+func inField(x *big.Int) bool {
+	return x != nil && x.Sign() >= 0 && x.Cmp(Modulus) < 0
+}
+
+var (
+	activeSetup *TrustedSetup
+	activeLock  sync.RWMutex
+)
+
+// SetTrustedSetup installs the setup the commitment precompiles verify against.
+func SetTrustedSetup(setup *TrustedSetup) {
+	activeLock.Lock()
+	defer activeLock.Unlock()
+
+	activeSetup = setup
+}
+
+// ActiveSetup returns the setup installed by SetTrustedSetup, or nil.
+func ActiveSetup() *TrustedSetup {
+	activeLock.RLock()
+	defer activeLock.RUnlock()
+
+	return activeSetup
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kzg
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+)
+
+// testPolynomial returns the polynomial 1 + 2x + ... + n x^(n-1).
+func testPolynomial(n int) []*big.Int {
+	poly := make([]*big.Int, n)
+	for i := range poly {
+		poly[i] = big.NewInt(int64(i + 1))
+	}
+	return poly
+}
+
+// Tests that opening proofs verify for the correct evaluation only.
+func TestCommitOpenVerify(t *testing.T) {
+	setup := NewInsecureSetup(big.NewInt(1337), 8)
+	poly := testPolynomial(8)
+
+	commitment, err := setup.Commit(poly)
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	for _, z := range []*big.Int{big.NewInt(0), big.NewInt(2), new(big.Int).Sub(Modulus, common.Big1)} {
+		y, proof, err := setup.Open(poly, z)
+		if err != nil {
+			t.Fatalf("z=%v: failed to open: %v", z, err)
+		}
+		// Cross check the evaluation with a naive one
+		want, power := new(big.Int), big.NewInt(1)
+		for _, coeff := range poly {
+			want.Add(want, new(big.Int).Mul(coeff, power))
+			power = new(big.Int).Mod(new(big.Int).Mul(power, z), Modulus)
+		}
+		if want.Mod(want, Modulus); y.Cmp(want) != 0 {
+			t.Fatalf("z=%v: evaluation mismatch: have %v, want %v", z, y, want)
+		}
+		if !setup.Verify(commitment, z, y, proof) {
+			t.Errorf("z=%v: valid proof rejected", z)
+		}
+		if setup.Verify(commitment, z, new(big.Int).Add(y, common.Big1), proof) {
+			t.Errorf("z=%v: proof accepted for wrong value", z)
+		}
+		if setup.Verify(commitment, new(big.Int).Add(z, common.Big1), y, proof) {
+			t.Errorf("z=%v: proof accepted for wrong point", z)
+		}
+	}
+	if _, err := setup.Commit(testPolynomial(10)); err == nil {
+		t.Errorf("committed to polynomial beyond the setup")
+	}
+	if _, _, err := setup.Open(poly, Modulus); err == nil {
+		t.Errorf("opened polynomial at point outside the field")
+	}
+}
+
+// Tests that trusted setups survive a round trip through a file, and that
+// malformed ones are rejected.
+func TestLoadTrustedSetup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kzg-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	setup := NewInsecureSetup(big.NewInt(42), 4)
+	blob, err := json.Marshal(setup)
+	if err != nil {
+		t.Fatalf("failed to encode setup: %v", err)
+	}
+	path := filepath.Join(dir, "setup.json")
+	if err := ioutil.WriteFile(path, blob, 0600); err != nil {
+		t.Fatalf("failed to write setup: %v", err)
+	}
+	loaded, err := LoadTrustedSetup(path)
+	if err != nil {
+		t.Fatalf("failed to load setup: %v", err)
+	}
+	if loaded.Hash() != setup.Hash() || len(loaded.G1) != len(setup.G1) {
+		t.Fatalf("loaded setup mismatch")
+	}
+	if setup.Hash() == NewInsecureSetup(big.NewInt(43), 4).Hash() {
+		t.Fatalf("setups of different secrets hash equal")
+	}
+	// Verify-only setups are fine, but the generators are mandatory
+	verifier := &TrustedSetup{G2: setup.G2}
+	blob, _ = json.Marshal(verifier)
+	if err := json.Unmarshal(blob, new(TrustedSetup)); err != nil {
+		t.Errorf("verify-only setup rejected: %v", err)
+	}
+	broken := &TrustedSetup{G1: setup.G1[1:], G2: setup.G2}
+	blob, _ = json.Marshal(broken)
+	if err := json.Unmarshal(blob, new(TrustedSetup)); err == nil {
+		t.Errorf("setup without g1 generator accepted")
+	}
+	broken = &TrustedSetup{G2: setup.G2[1:]}
+	blob, _ = json.Marshal(broken)
+	if err := json.Unmarshal(blob, new(TrustedSetup)); err == nil {
+		t.Errorf("setup without verification points accepted")
+	}
+}
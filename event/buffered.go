@@ -0,0 +1,83 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package event
+
+import (
+	"reflect"
+
+	"github.com/matrix/go-matrix/metrics"
+)
+
+// BufferedFeed implements one-to-many subscriptions like Feed, but never lets
+// a slow subscriber block the sender. Every subscription is served by a relay
+// which hands events over to the subscribed channel without waiting; events
+// which don't fit into the channel's buffer are dropped for that subscriber
+// and counted on the optional dropped counter.
+//
+// Subscribers should use buffered channels sized for the bursts they expect.
+//
+// The zero value is ready to use.
+type BufferedFeed struct {
+	feed    Feed
+	dropped metrics.Counter
+}
+
+// NewBufferedFeed creates a buffered feed counting dropped events on the
+// given counter.
+func NewBufferedFeed(dropped metrics.Counter) *BufferedFeed {
+	return &BufferedFeed{dropped: dropped}
+}
+
+// Subscribe adds a channel to the feed. Future sends will be delivered on the
+// channel until the subscription is canceled, as long as it has room for them.
+// All channels added must have the same element type.
+func (f *BufferedFeed) Subscribe(channel interface{}) Subscription {
+	chanval := reflect.ValueOf(channel)
+	chantyp := chanval.Type()
+	if chantyp.Kind() != reflect.Chan || chantyp.ChanDir()&reflect.SendDir == 0 {
+		panic(errBadChannel)
+	}
+	// Subscribe an unbuffered relay channel to the underlying feed. The relay
+	// goroutine is always ready to receive, so Send never waits on it.
+	relay := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, chantyp.Elem()), 0)
+	sub := f.feed.Subscribe(relay.Interface())
+
+	return NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: relay},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(quit)},
+		}
+		for {
+			chosen, value, _ := reflect.Select(cases)
+			if chosen != 0 {
+				return nil
+			}
+			if !chanval.TrySend(value) && f.dropped != nil {
+				f.dropped.Inc(1)
+			}
+		}
+	})
+}
+
+// Send delivers to all subscribed channels which have room for the value.
+// It returns the number of subscriptions that the value was handed to,
+// including the ones dropping it.
+func (f *BufferedFeed) Send(value interface{}) (nsent int) {
+	return f.feed.Send(value)
+}
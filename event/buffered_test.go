@@ -0,0 +1,91 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package event
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matrix/go-matrix/metrics"
+)
+
+func TestBufferedFeedDrops(t *testing.T) {
+	var (
+		dropped = new(metrics.StandardCounter)
+		feed    = NewBufferedFeed(dropped)
+		slow    = make(chan int, 1)
+		fast    = make(chan int, 10)
+	)
+	sub1 := feed.Subscribe(slow)
+	defer sub1.Unsubscribe()
+	sub2 := feed.Subscribe(fast)
+	defer sub2.Unsubscribe()
+
+	// Nobody reads from the slow channel, sending must not block regardless
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			feed.Send(i)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("send blocked on slow subscriber")
+	}
+	// The fast subscriber gets everything, the slow one only the first value
+	for i := 0; i < 5; i++ {
+		select {
+		case v := <-fast:
+			if v != i {
+				t.Fatalf("fast subscriber: value mismatch: have %d, want %d", v, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("fast subscriber: value %d not delivered", i)
+		}
+	}
+	if v := <-slow; v != 0 {
+		t.Fatalf("slow subscriber: value mismatch: have %d, want 0", v)
+	}
+	for start := time.Now(); dropped.Count() != 4; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("dropped count mismatch: have %d, want 4", dropped.Count())
+		}
+	}
+}
+
+func TestBufferedFeedUnsubscribe(t *testing.T) {
+	var (
+		feed BufferedFeed
+		ch   = make(chan int, 1)
+	)
+	sub := feed.Subscribe(ch)
+	sub.Unsubscribe()
+
+	// Wait for the relay to leave the underlying feed
+	for start := time.Now(); feed.Send(1) != 0; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("relay still subscribed after unsubscribe")
+		}
+	}
+	select {
+	case <-sub.Err():
+	default:
+		t.Fatal("error channel not closed after unsubscribe")
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package faucet
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/params"
+)
+
+// DefaultConfig contains default settings for the faucet.
+var DefaultConfig = Config{
+	Host:            "localhost",
+	Port:            8099,
+	Amount:          new(big.Int).Mul(big.NewInt(10), big.NewInt(params.Ether)),
+	AddressCooldown: 24 * time.Hour,
+	IPCooldown:      time.Hour,
+}
+
+// Config contains the configuration parameters of the faucet.
+type Config struct {
+	// Host is the host interface on which to start the faucet HTTP server.
+	Host string `toml:",omitempty"`
+
+	// Port is the TCP port number on which to start the faucet HTTP server.
+	Port int `toml:",omitempty"`
+
+	// Account is the funded account the faucet sends from. It must be
+	// unlocked in the node's account manager for requests to succeed.
+	Account common.Address
+
+	// Amount is the number of wei sent per successful request.
+	Amount *big.Int
+
+	// AddressCooldown is the minimum time between two payouts to the same
+	// recipient address.
+	AddressCooldown time.Duration `toml:",omitempty"`
+
+	// IPCooldown is the minimum time between two payouts requested from the
+	// same remote IP address.
+	IPCooldown time.Duration `toml:",omitempty"`
+
+	// CaptchaURL is the verification endpoint of a reCAPTCHA compatible
+	// captcha service. If empty, no captcha token is required.
+	CaptchaURL string `toml:",omitempty"`
+
+	// CaptchaSecret is the server side secret sent to the captcha service.
+	CaptchaSecret string `toml:",omitempty"`
+
+	// TrustedProxies lists the IP addresses or CIDR ranges of the reverse
+	// proxies in front of the faucet. For requests relayed by them, the IP the
+	// cooldown applies to is taken from the X-Forwarded-For header.
+	TrustedProxies []string `toml:",omitempty"`
+}
@@ -0,0 +1,82 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package faucet
+
+import (
+	"sync"
+	"time"
+)
+
+// cooldown tracks the last payout time per key (address or IP) and expires
+// entries once their cooldown period has passed.
+type cooldown struct {
+	period time.Duration
+	last   map[string]time.Time
+	lock   sync.Mutex // held by the caller around remaining/mark
+}
+
+func newCooldown(period time.Duration) *cooldown {
+	return &cooldown{
+		period: period,
+		last:   make(map[string]time.Time),
+	}
+}
+
+// remaining returns how long key still has to wait at time now, dropping its
+// entry if the cooldown has passed. The caller must hold the lock.
+func (c *cooldown) remaining(key string, now time.Time) time.Duration {
+	t, ok := c.last[key]
+	if !ok {
+		return 0
+	}
+	if wait := c.period - now.Sub(t); wait > 0 {
+		return wait
+	}
+	delete(c.last, key)
+	return 0
+}
+
+// mark records a payout for key at time now. The caller must hold the lock.
+func (c *cooldown) mark(key string, now time.Time) {
+	if c.period > 0 {
+		c.last[key] = now
+	}
+}
+
+// release forgets a payout previously marked at time at, unless a newer
+// one was recorded in the meantime.
+func (c *cooldown) release(key string, at time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if t, ok := c.last[key]; ok && t.Equal(at) {
+		delete(c.last, key)
+	}
+}
+
+// prune drops all entries whose cooldown has passed at time now, so keys that
+// never come back do not accumulate.
+func (c *cooldown) prune(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for key, t := range c.last {
+		if now.Sub(t) >= c.period {
+			delete(c.last, key)
+		}
+	}
+}
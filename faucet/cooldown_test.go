@@ -0,0 +1,92 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package faucet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCooldown(t *testing.T) {
+	c := newCooldown(time.Minute)
+	now := time.Now()
+
+	if wait := c.remaining("a", now); wait != 0 {
+		t.Fatalf("fresh key throttled for %v", wait)
+	}
+	c.mark("a", now)
+	if wait := c.remaining("a", now.Add(20*time.Second)); wait != 40*time.Second {
+		t.Fatalf("wait mismatch: have %v, want %v", wait, 40*time.Second)
+	}
+	if wait := c.remaining("b", now); wait != 0 {
+		t.Fatalf("unrelated key throttled for %v", wait)
+	}
+	if wait := c.remaining("a", now.Add(time.Minute)); wait != 0 {
+		t.Fatalf("expired key throttled for %v", wait)
+	}
+	if len(c.last) != 0 {
+		t.Fatalf("expired entry not dropped: %d left", len(c.last))
+	}
+}
+
+func TestCooldownPrune(t *testing.T) {
+	c := newCooldown(time.Minute)
+	now := time.Now()
+
+	c.mark("a", now)
+	c.mark("b", now.Add(30*time.Second))
+
+	// Looking up a key leaves the others alone
+	c.remaining("a", now.Add(time.Minute))
+	if _, ok := c.last["b"]; !ok || len(c.last) != 1 {
+		t.Fatalf("lookup touched other entries: %v", c.last)
+	}
+	c.mark("a", now)
+	c.prune(now.Add(time.Minute))
+	if _, ok := c.last["b"]; !ok || len(c.last) != 1 {
+		t.Fatalf("prune mismatch: have %v, want only b", c.last)
+	}
+	c.prune(now.Add(90 * time.Second))
+	if len(c.last) != 0 {
+		t.Fatalf("expired entries not pruned: %d left", len(c.last))
+	}
+}
+
+func TestCooldownRelease(t *testing.T) {
+	c := newCooldown(time.Minute)
+	now := time.Now()
+
+	c.mark("a", now)
+	c.release("a", now.Add(time.Second))
+	if wait := c.remaining("a", now); wait == 0 {
+		t.Fatalf("release of a different payout cleared the cooldown")
+	}
+	c.release("a", now)
+	if wait := c.remaining("a", now); wait != 0 {
+		t.Fatalf("released key still throttled for %v", wait)
+	}
+}
+
+func TestCooldownDisabled(t *testing.T) {
+	c := newCooldown(0)
+	now := time.Now()
+
+	c.mark("a", now)
+	if wait := c.remaining("a", now); wait != 0 {
+		t.Fatalf("disabled cooldown throttled for %v", wait)
+	}
+}
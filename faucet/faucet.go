@@ -0,0 +1,386 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package faucet implements a node service paying out testnet funds over HTTP.
+package faucet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/accounts"
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/metrics"
+	"github.com/matrix/go-matrix/p2p"
+	"github.com/matrix/go-matrix/params"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+// cooldownPruneInterval is how often cooldown entries of keys that never came
+// back are dropped.
+const cooldownPruneInterval = 10 * time.Minute
+
+// Timeouts of the faucet HTTP server, bounding how long a client may hold a
+// connection open. The write timeout leaves room for the captcha verification
+// and the payout of a request.
+const (
+	serverReadTimeout     = 10 * time.Second
+	serverWriteTimeout    = 30 * time.Second
+	serverIdleTimeout     = 2 * time.Minute
+	serverShutdownTimeout = 5 * time.Second
+)
+
+var (
+	fundedCounter   = metrics.NewRegisteredCounter("faucet/funded", nil)
+	throttleCounter = metrics.NewRegisteredCounter("faucet/throttled", nil)
+	failedCounter   = metrics.NewRegisteredCounter("faucet/failed", nil)
+)
+
+var (
+	errInvalidAddress = errors.New("invalid recipient address")
+	errCaptcha        = errors.New("captcha verification failed")
+)
+
+// Backend is the subset of the Matrix API backend the faucet needs to
+// assemble and submit its payout transactions.
+type Backend interface {
+	ChainConfig() *params.ChainConfig
+	CurrentBlock() *types.Block
+	SuggestPrice(ctx context.Context) (*big.Int, error)
+	GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error)
+	SendTx(ctx context.Context, signedTx *types.Transaction) error
+}
+
+// Faucet is a node service which sends a fixed amount of funds from a
+// designated account to anyone asking for it over HTTP, subject to per
+// address and per IP cooldowns and an optional captcha.
+type Faucet struct {
+	config  *Config
+	backend Backend
+	manager *accounts.Manager
+
+	addrs   *cooldown    // payout history per recipient address
+	ips     *cooldown    // payout history per requesting IP
+	proxies []*net.IPNet // reverse proxies trusted to forward the client IP
+
+	server *http.Server
+	lock   sync.Mutex // serialises payouts so nonces are never reused
+	quit   chan struct{}
+}
+
+// New creates a faucet paying out through the given backend and account manager.
+func New(config *Config, backend Backend, manager *accounts.Manager) (*Faucet, error) {
+	if config.Account == (common.Address{}) {
+		return nil, errors.New("faucet account not configured")
+	}
+	if config.Amount == nil || config.Amount.Sign() <= 0 {
+		return nil, errors.New("faucet amount must be positive")
+	}
+	proxies, err := parseProxies(config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return &Faucet{
+		config:  config,
+		backend: backend,
+		manager: manager,
+		addrs:   newCooldown(config.AddressCooldown),
+		ips:     newCooldown(config.IPCooldown),
+		proxies: proxies,
+		quit:    make(chan struct{}),
+	}, nil
+}
+
+// parseProxies parses the trusted proxy addresses and CIDR ranges.
+func parseProxies(specs []string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, spec := range specs {
+		if !strings.Contains(spec, "/") {
+			ip := net.ParseIP(spec)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", spec)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %v", spec, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// Protocols is a meaningless implementation of node.Service.
+func (f *Faucet) Protocols() []p2p.Protocol { return nil }
+
+// APIs is a meaningless implementation of node.Service.
+func (f *Faucet) APIs() []rpc.API { return nil }
+
+// Start implements node.Service, starting the faucet HTTP server.
+func (f *Faucet) Start(server *p2p.Server) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", f.config.Host, f.config.Port))
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", f.handleFund)
+	f.server = &http.Server{
+		Handler:      mux,
+		ReadTimeout:  serverReadTimeout,
+		WriteTimeout: serverWriteTimeout,
+		IdleTimeout:  serverIdleTimeout,
+	}
+	go f.server.Serve(listener)
+	go f.loop()
+
+	log.Info("Faucet started", "endpoint", listener.Addr(), "account", f.config.Account, "amount", f.config.Amount)
+	return nil
+}
+
+// Stop implements node.Service, closing the faucet HTTP server.
+func (f *Faucet) Stop() error {
+	if f.server != nil {
+		// Let in-flight payouts finish, dropping stuck connections after a while
+		ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+		if err := f.server.Shutdown(ctx); err != nil {
+			f.server.Close()
+		}
+		cancel()
+	}
+	close(f.quit)
+	log.Info("Faucet stopped")
+	return nil
+}
+
+// loop periodically drops the expired cooldown entries until the faucet stops.
+func (f *Faucet) loop() {
+	ticker := time.NewTicker(cooldownPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			f.addrs.prune(now)
+			f.ips.prune(now)
+		case <-f.quit:
+			return
+		}
+	}
+}
+
+// fundRequest is the JSON body accepted by the faucet endpoint.
+type fundRequest struct {
+	Address string `json:"address"`
+	Captcha string `json:"captcha"`
+}
+
+// fundResponse is the JSON reply of the faucet endpoint.
+type fundResponse struct {
+	Tx    *common.Hash `json:"tx,omitempty"`
+	Error string       `json:"error,omitempty"`
+	Retry uint64       `json:"retryAfter,omitempty"` // seconds until the next allowed request
+}
+
+// handleFund serves a single payout request.
+func (f *Faucet) handleFund(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req fundRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		reply(w, http.StatusBadRequest, &fundResponse{Error: err.Error()})
+		return
+	}
+	if !common.IsHexAddress(req.Address) {
+		reply(w, http.StatusBadRequest, &fundResponse{Error: errInvalidAddress.Error()})
+		return
+	}
+	if f.config.CaptchaURL != "" {
+		if err := f.verifyCaptcha(req.Captcha, r); err != nil {
+			reply(w, http.StatusForbidden, &fundResponse{Error: err.Error()})
+			return
+		}
+	}
+	addr := common.HexToAddress(req.Address)
+	ip := f.remoteIP(r)
+
+	// Reserve both cooldown slots atomically, releasing them if the payout fails
+	now := time.Now()
+	if wait := f.reserve(addr, ip, now); wait > 0 {
+		throttleCounter.Inc(1)
+		reply(w, http.StatusTooManyRequests, &fundResponse{Error: "request throttled", Retry: uint64(wait/time.Second) + 1})
+		return
+	}
+	hash, err := f.fund(r.Context(), addr)
+	if err != nil {
+		failedCounter.Inc(1)
+		f.addrs.release(addr.Hex(), now)
+		f.ips.release(ip, now)
+		log.Warn("Faucet payout failed", "recipient", addr, "ip", ip, "err", err)
+		reply(w, http.StatusInternalServerError, &fundResponse{Error: err.Error()})
+		return
+	}
+	fundedCounter.Inc(1)
+	log.Info("Faucet funded account", "recipient", addr, "ip", ip, "tx", hash)
+	reply(w, http.StatusOK, &fundResponse{Tx: &hash})
+}
+
+// reserve checks both cooldowns and, if neither is active, records a payout
+// at now for both. It returns how long the caller has to wait otherwise.
+func (f *Faucet) reserve(addr common.Address, ip string, now time.Time) time.Duration {
+	f.addrs.lock.Lock()
+	defer f.addrs.lock.Unlock()
+	f.ips.lock.Lock()
+	defer f.ips.lock.Unlock()
+
+	wait := f.addrs.remaining(addr.Hex(), now)
+	if w := f.ips.remaining(ip, now); w > wait {
+		wait = w
+	}
+	if wait > 0 {
+		return wait
+	}
+	f.addrs.mark(addr.Hex(), now)
+	f.ips.mark(ip, now)
+	return 0
+}
+
+// fund signs and submits a payout transaction to addr.
+func (f *Faucet) fund(ctx context.Context, addr common.Address) (common.Hash, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	account := accounts.Account{Address: f.config.Account}
+	wallet, err := f.manager.Find(account)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	nonce, err := f.backend.GetPoolNonce(ctx, account.Address)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	price, err := f.backend.SuggestPrice(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	tx := types.NewTransaction(nonce, addr, f.config.Amount, params.TxGas, price, nil)
+
+	var chainID *big.Int
+	if config := f.backend.ChainConfig(); config.IsEIP155(f.backend.CurrentBlock().Number()) {
+		chainID = config.ChainId
+	}
+	signed, err := wallet.SignTx(account, tx, chainID)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := f.backend.SendTx(ctx, signed); err != nil {
+		return common.Hash{}, err
+	}
+	return signed.Hash(), nil
+}
+
+// verifyCaptcha checks a captcha token against a reCAPTCHA compatible service.
+func (f *Faucet) verifyCaptcha(token string, r *http.Request) error {
+	if token == "" {
+		return errCaptcha
+	}
+	form := url.Values{}
+	form.Add("secret", f.config.CaptchaSecret)
+	form.Add("response", token)
+	form.Add("remoteip", f.remoteIP(r))
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.PostForm(f.config.CaptchaURL, form)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return err
+	}
+	if !result.Success {
+		return errCaptcha
+	}
+	return nil
+}
+
+// reply writes a JSON response with the given status code.
+func reply(w http.ResponseWriter, status int, res *fundResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+// remoteIP extracts the IP address of the requester, without the port. If the
+// request is relayed by trusted proxies, the X-Forwarded-For header is walked
+// from the right, returning the first address not belonging to a proxy.
+func (f *Faucet) remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = strings.TrimSpace(r.RemoteAddr)
+	}
+	if !f.trusted(host) {
+		return host
+	}
+	hops := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !f.trusted(hop) {
+			return hop
+		}
+		host = hop
+	}
+	return host
+}
+
+// trusted reports whether the IP address belongs to a trusted proxy.
+func (f *Faucet) trusted(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range f.proxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
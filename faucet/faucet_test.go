@@ -0,0 +1,243 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package faucet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matrix/go-matrix/accounts"
+	"github.com/matrix/go-matrix/accounts/keystore"
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/params"
+)
+
+// testBackend is a faucet backend recording the submitted transactions.
+type testBackend struct {
+	fail bool
+	sent []*types.Transaction
+}
+
+func (b *testBackend) ChainConfig() *params.ChainConfig { return params.TestChainConfig }
+
+func (b *testBackend) CurrentBlock() *types.Block {
+	return types.NewBlockWithHeader(&types.Header{Number: big.NewInt(0)})
+}
+
+func (b *testBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(params.Shannon), nil
+}
+
+func (b *testBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
+	return uint64(len(b.sent)), nil
+}
+
+func (b *testBackend) SendTx(ctx context.Context, tx *types.Transaction) error {
+	if b.fail {
+		return errors.New("pool rejected transaction")
+	}
+	b.sent = append(b.sent, tx)
+	return nil
+}
+
+// newTestFaucet creates a faucet paying out from a freshly created and
+// unlocked keystore account.
+func newTestFaucet(t *testing.T, config Config) (*Faucet, *testBackend, func()) {
+	dir, err := ioutil.TempDir("", "faucet-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	account, err := ks.NewAccount("")
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	if err := ks.Unlock(account, ""); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	config.Account = account.Address
+	config.Amount = big.NewInt(params.Ether)
+
+	backend := new(testBackend)
+	faucet, err := New(&config, backend, accounts.NewManager(ks))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return faucet, backend, func() { os.RemoveAll(dir) }
+}
+
+// request sends a payout request for addr to the faucet handler, returning the
+// status code and the decoded reply.
+func request(f *Faucet, addr common.Address, captcha string, remote string, forwarded string) (int, *fundResponse) {
+	body := fmt.Sprintf(`{"address":"%s","captcha":"%s"}`, addr.Hex(), captcha)
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.RemoteAddr = remote
+	if forwarded != "" {
+		req.Header.Set("X-Forwarded-For", forwarded)
+	}
+	rec := httptest.NewRecorder()
+	f.handleFund(rec, req)
+
+	res := new(fundResponse)
+	json.NewDecoder(rec.Body).Decode(res)
+	return rec.Code, res
+}
+
+func TestHandleFundThrottling(t *testing.T) {
+	faucet, backend, cleanup := newTestFaucet(t, Config{AddressCooldown: time.Hour, IPCooldown: time.Hour})
+	defer cleanup()
+
+	alice := common.HexToAddress("0x01")
+	bob := common.HexToAddress("0x02")
+
+	if code, res := request(faucet, alice, "", "10.0.0.1:1000", ""); code != http.StatusOK || res.Tx == nil {
+		t.Fatalf("first request: have status %d (%s), want %d", code, res.Error, http.StatusOK)
+	}
+	if len(backend.sent) != 1 || *backend.sent[0].To() != alice {
+		t.Fatalf("payout not submitted to recipient")
+	}
+	// Same address from another IP is throttled by the address cooldown
+	if code, res := request(faucet, alice, "", "10.0.0.2:1000", ""); code != http.StatusTooManyRequests || res.Retry == 0 {
+		t.Fatalf("repeated address: have status %d retry %d, want %d", code, res.Retry, http.StatusTooManyRequests)
+	}
+	// Another address from the same IP is throttled by the IP cooldown
+	if code, _ := request(faucet, bob, "", "10.0.0.1:2000", ""); code != http.StatusTooManyRequests {
+		t.Fatalf("repeated IP: have status %d, want %d", code, http.StatusTooManyRequests)
+	}
+	// A throttled request must not have reserved the second IP
+	if code, _ := request(faucet, bob, "", "10.0.0.2:1000", ""); code != http.StatusOK {
+		t.Fatalf("fresh address and IP: have status %d, want %d", code, http.StatusOK)
+	}
+	if len(backend.sent) != 2 {
+		t.Fatalf("payout count mismatch: have %d, want 2", len(backend.sent))
+	}
+}
+
+func TestHandleFundReleaseOnFailure(t *testing.T) {
+	faucet, backend, cleanup := newTestFaucet(t, Config{AddressCooldown: time.Hour, IPCooldown: time.Hour})
+	defer cleanup()
+
+	addr := common.HexToAddress("0x01")
+
+	backend.fail = true
+	if code, res := request(faucet, addr, "", "10.0.0.1:1000", ""); code != http.StatusInternalServerError || res.Error == "" {
+		t.Fatalf("failed payout: have status %d, want %d", code, http.StatusInternalServerError)
+	}
+	backend.fail = false
+	if code, res := request(faucet, addr, "", "10.0.0.1:1000", ""); code != http.StatusOK {
+		t.Fatalf("retry after failure: have status %d (%s), want %d", code, res.Error, http.StatusOK)
+	}
+}
+
+func TestHandleFundCaptcha(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("secret") != "secret" {
+			t.Errorf("captcha secret mismatch: have %q", r.PostForm.Get("secret"))
+		}
+		fmt.Fprintf(w, `{"success":%v}`, r.PostForm.Get("response") == "valid")
+	}))
+	defer server.Close()
+
+	faucet, backend, cleanup := newTestFaucet(t, Config{CaptchaURL: server.URL, CaptchaSecret: "secret"})
+	defer cleanup()
+
+	addr := common.HexToAddress("0x01")
+	for _, token := range []string{"", "invalid"} {
+		if code, res := request(faucet, addr, token, "10.0.0.1:1000", ""); code != http.StatusForbidden || res.Error != errCaptcha.Error() {
+			t.Fatalf("token %q: have status %d (%s), want %d", token, code, res.Error, http.StatusForbidden)
+		}
+	}
+	if len(backend.sent) != 0 {
+		t.Fatalf("rejected captcha paid out %d times", len(backend.sent))
+	}
+	if code, res := request(faucet, addr, "valid", "10.0.0.1:1000", ""); code != http.StatusOK {
+		t.Fatalf("valid token: have status %d (%s), want %d", code, res.Error, http.StatusOK)
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	faucet, _, cleanup := newTestFaucet(t, Config{TrustedProxies: []string{"10.0.0.1", "192.168.0.0/16"}})
+	defer cleanup()
+
+	tests := []struct {
+		remote    string
+		forwarded string
+		want      string
+	}{
+		{"1.2.3.4:1000", "", "1.2.3.4"},
+		{"1.2.3.4:1000", "5.6.7.8", "1.2.3.4"},               // untrusted peer, header ignored
+		{"10.0.0.1:1000", "", "10.0.0.1"},                    // trusted peer without header
+		{"10.0.0.1:1000", "5.6.7.8", "5.6.7.8"},              // single proxy
+		{"10.0.0.1:1000", "9.9.9.9, 5.6.7.8", "5.6.7.8"},     // spoofed hop left of the client
+		{"10.0.0.1:1000", "5.6.7.8, 192.168.1.1", "5.6.7.8"}, // proxy chain
+		{"10.0.0.2:1000", "5.6.7.8", "10.0.0.2"},             // not a proxy
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if ip := faucet.remoteIP(req); ip != tt.want {
+			t.Errorf("test %d: remote IP mismatch: have %s, want %s", i, ip, tt.want)
+		}
+	}
+	if _, err := parseProxies([]string{"not-an-ip"}); err == nil {
+		t.Errorf("invalid trusted proxy accepted")
+	}
+}
+
+// Tests that the faucet serves with bounded connection timeouts and shuts its
+// server down on stop.
+func TestServerLifecycle(t *testing.T) {
+	f, _, cleanup := newTestFaucet(t, Config{Host: "127.0.0.1", Port: 0})
+	defer cleanup()
+
+	if err := f.Start(nil); err != nil {
+		t.Fatalf("failed to start faucet: %v", err)
+	}
+	if f.server.ReadTimeout == 0 || f.server.WriteTimeout == 0 || f.server.IdleTimeout == 0 {
+		t.Errorf("unbounded server timeouts: read %v, write %v, idle %v", f.server.ReadTimeout, f.server.WriteTimeout, f.server.IdleTimeout)
+	}
+	if err := f.Stop(); err != nil {
+		t.Fatalf("failed to stop faucet: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	if err := f.server.Serve(listener); err != http.ErrServerClosed {
+		t.Errorf("server still serving after stop: %v", err)
+	}
+}
@@ -20,7 +20,9 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var (
@@ -38,14 +40,15 @@ type Environment struct {
 	Name                string // name of the environment
 	Repo                string // name of GitHub repo
 	Commit, Branch, Tag string // Git info
+	Date                string // Commit date (YYYYMMDD), used as the build date to keep builds reproducible
 	Buildnum            string
 	IsPullRequest       bool
 	IsCronJob           bool
 }
 
 func (env Environment) String() string {
-	return fmt.Sprintf("%s env (commit:%s branch:%s tag:%s buildnum:%s pr:%t)",
-		env.Name, env.Commit, env.Branch, env.Tag, env.Buildnum, env.IsPullRequest)
+	return fmt.Sprintf("%s env (commit:%s date:%s branch:%s tag:%s buildnum:%s pr:%t)",
+		env.Name, env.Commit, env.Date, env.Branch, env.Tag, env.Buildnum, env.IsPullRequest)
 }
 
 // Env returns metadata about the current CI environment, falling back to LocalEnv
@@ -57,6 +60,7 @@ func Env() Environment {
 			Name:          "travis",
 			Repo:          os.Getenv("TRAVIS_REPO_SLUG"),
 			Commit:        os.Getenv("TRAVIS_COMMIT"),
+			Date:          getDate(os.Getenv("TRAVIS_COMMIT")),
 			Branch:        os.Getenv("TRAVIS_BRANCH"),
 			Tag:           os.Getenv("TRAVIS_TAG"),
 			Buildnum:      os.Getenv("TRAVIS_BUILD_NUMBER"),
@@ -68,6 +72,7 @@ func Env() Environment {
 			Name:          "appveyor",
 			Repo:          os.Getenv("APPVEYOR_REPO_NAME"),
 			Commit:        os.Getenv("APPVEYOR_REPO_COMMIT"),
+			Date:          getDate(os.Getenv("APPVEYOR_REPO_COMMIT")),
 			Branch:        os.Getenv("APPVEYOR_REPO_BRANCH"),
 			Tag:           os.Getenv("APPVEYOR_REPO_TAG_NAME"),
 			Buildnum:      os.Getenv("APPVEYOR_BUILD_NUMBER"),
@@ -87,11 +92,13 @@ func LocalEnv() Environment {
 	if splits := strings.Split(head, " "); len(splits) == 2 {
 		head = splits[1]
 	} else {
+		env.Date = getDate(env.Commit)
 		return env
 	}
 	if env.Commit == "" {
 		env.Commit = readGitFile(head)
 	}
+	env.Date = getDate(env.Commit)
 	if env.Branch == "" {
 		if head != "HEAD" {
 			env.Branch = strings.TrimPrefix(head, "refs/heads/")
@@ -103,6 +110,26 @@ func LocalEnv() Environment {
 	return env
 }
 
+// getDate returns the committer date of a commit, formatted as YYYYMMDD. It is
+// empty if the commit is unknown or the source tree isn't a git checkout.
+func getDate(commit string) string {
+	if commit == "" {
+		return ""
+	}
+	if info, err := os.Stat(".git/objects"); err != nil || !info.IsDir() {
+		return ""
+	}
+	stamp := RunGit("show", "-s", "--format=%ct", commit)
+	if stamp == "" {
+		return ""
+	}
+	date, err := strconv.ParseInt(stamp, 10, 64)
+	if err != nil {
+		panic(fmt.Sprintf("failed to parse git commit date %q: %v", stamp, err))
+	}
+	return time.Unix(date, 0).UTC().Format("20060102")
+}
+
 func firstLine(s string) string {
 	return strings.Split(s, "\n")[0]
 }
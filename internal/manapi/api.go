@@ -22,10 +22,12 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/hashicorp/golang-lru"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/util"
 
@@ -38,11 +40,13 @@ import (
 	"github.com/matrix/go-matrix/consensus/manash"
 	"github.com/matrix/go-matrix/core"
 	"github.com/matrix/go-matrix/core/rawdb"
+	"github.com/matrix/go-matrix/core/state"
 	"github.com/matrix/go-matrix/core/types"
 	"github.com/matrix/go-matrix/core/vm"
 	"github.com/matrix/go-matrix/crypto"
 	"github.com/matrix/go-matrix/log"
 	"github.com/matrix/go-matrix/mc"
+	"github.com/matrix/go-matrix/metrics"
 	"github.com/matrix/go-matrix/p2p"
 	"github.com/matrix/go-matrix/params"
 	"github.com/matrix/go-matrix/rlp"
@@ -69,11 +73,27 @@ func (s *PublicMatrixAPI) GasPrice(ctx context.Context) (*big.Int, error) {
 	return s.b.SuggestPrice(ctx)
 }
 
+// MaxPriorityFeePerGas returns a suggestion for the priority fee to pay on top
+// of the base fee once dynamic fees are active. Before the fork it equals the
+// suggested gas price.
+func (s *PublicMatrixAPI) MaxPriorityFeePerGas(ctx context.Context) (*big.Int, error) {
+	return s.b.SuggestTipCap(ctx)
+}
+
 // ProtocolVersion returns the current Matrix protocol version this node supports
 func (s *PublicMatrixAPI) ProtocolVersion() hexutil.Uint {
 	return hexutil.Uint(s.b.ProtocolVersion())
 }
 
+// ChainId returns the chain ID transactions are signed for (EIP-155). It is
+// only reported once the current block is past the replay protection fork.
+func (s *PublicMatrixAPI) ChainId() (*hexutil.Big, error) {
+	if config := s.b.ChainConfig(); config.IsEIP155(s.b.CurrentBlock().Number()) {
+		return (*hexutil.Big)(config.ChainId), nil
+	}
+	return nil, fmt.Errorf("chain not synced beyond EIP-155 replay-protection fork block")
+}
+
 // Syncing returns false in case the node is currently not syncing with the network. It can be up to date or has not
 // yet received the latest block headers from its pears. In case it is synchronizing:
 // - startingBlock: block number this node started to synchronise from
@@ -179,6 +199,27 @@ func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
 	return content
 }
 
+// PrivateTxPoolAPI offers methods managing the content of the transaction pool.
+type PrivateTxPoolAPI struct {
+	b Backend
+}
+
+// NewPrivateTxPoolAPI creates a new tx pool service managing the transaction pool.
+func NewPrivateTxPoolAPI(b Backend) *PrivateTxPoolAPI {
+	return &PrivateTxPoolAPI{b}
+}
+
+// Flush drops all pending and queued transactions of the given account from the
+// pool and returns their hashes.
+func (s *PrivateTxPoolAPI) Flush(addr common.Address) []common.Hash {
+	txs := s.b.FlushTxs(addr)
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	return hashes
+}
+
 // PublicAccountAPI provides an API to access accounts managed by this node.
 // It offers only methods that can retrieve accounts.
 type PublicAccountAPI struct {
@@ -330,7 +371,7 @@ func (s *PrivateAccountAPI) UnlockAccount(addr common.Address, password string,
 		d = time.Duration(*duration) * time.Second
 	}
 	err := fetchKeystore(s.am).TimedUnlock(accounts.Account{Address: addr}, password, d)
-	return err == nil, err
+	return err == nil, apiError(err)
 }
 
 // LockAccount will lock the account associated with the given address when it's unlocked.
@@ -346,7 +387,7 @@ func (s *PrivateAccountAPI) signTransaction(ctx context.Context, args SendTxArgs
 	account := accounts.Account{Address: args.From}
 	wallet, err := s.am.Find(account)
 	if err != nil {
-		return nil, err
+		return nil, apiError(err)
 	}
 	// Set some sanity defaults and terminate on failure
 	if err := args.setDefaults(ctx, s.b); err != nil {
@@ -359,7 +400,8 @@ func (s *PrivateAccountAPI) signTransaction(ctx context.Context, args SendTxArgs
 	if config := s.b.ChainConfig(); config.IsEIP155(s.b.CurrentBlock().Number()) {
 		chainID = config.ChainId
 	}
-	return wallet.SignTxWithPassphrase(account, passwd, tx, chainID)
+	signed, err := wallet.SignTxWithPassphrase(account, passwd, tx, chainID)
+	return signed, apiError(err)
 }
 
 // SendTransaction will create a transaction from the given arguments and
@@ -433,12 +475,12 @@ func (s *PrivateAccountAPI) Sign(ctx context.Context, data hexutil.Bytes, addr c
 
 	wallet, err := s.b.AccountManager().Find(account)
 	if err != nil {
-		return nil, err
+		return nil, apiError(err)
 	}
 	// Assemble sign the data with the wallet
 	signature, err := wallet.SignHashWithPassphrase(account, passwd, signHash(data))
 	if err != nil {
-		return nil, err
+		return nil, apiError(err)
 	}
 	signature[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
 	return signature, nil
@@ -478,15 +520,26 @@ func (s *PrivateAccountAPI) SignAndSendTransaction(ctx context.Context, args Sen
 	return s.SendTransaction(ctx, args, passwd)
 }
 
+// headerCacheLimit is the number of RPC encoded headers cached for the header
+// only endpoints.
+const headerCacheLimit = 2048
+
+var (
+	headerCacheHitMeter  = metrics.NewRegisteredMeter("manapi/header/hit", nil)
+	headerCacheMissMeter = metrics.NewRegisteredMeter("manapi/header/miss", nil)
+)
+
 // PublicBlockChainAPI provides an API to access the Matrix blockchain.
 // It offers only methods that operate on public data that is freely available to anyone.
 type PublicBlockChainAPI struct {
-	b Backend
+	b       Backend
+	headers *lru.Cache // block hash -> RPC encoded header, immutable once sealed
 }
 
 // NewPublicBlockChainAPI creates a new Matrix blockchain API.
 func NewPublicBlockChainAPI(b Backend) *PublicBlockChainAPI {
-	return &PublicBlockChainAPI{b}
+	headers, _ := lru.New(headerCacheLimit)
+	return &PublicBlockChainAPI{b: b, headers: headers}
 }
 
 // BlockNumber returns the block number of the chain head.
@@ -534,6 +587,38 @@ func (s *PublicBlockChainAPI) GetBlockByHash(ctx context.Context, blockHash comm
 	return nil, err
 }
 
+// GetHeaderByNumber returns the requested header without assembling the block
+// body. When blockNr is -1 the chain head is returned.
+func (s *PublicBlockChainAPI) GetHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (map[string]interface{}, error) {
+	header, err := s.b.HeaderByNumber(ctx, blockNr)
+	if header == nil {
+		return nil, err
+	}
+	if blockNr == rpc.PendingBlockNumber {
+		// Pending headers change until sealed, never cache them
+		response := s.rpcMarshalHeader(header, header.Hash())
+		for _, field := range []string{"hash", "nonce", "miner"} {
+			response[field] = nil
+		}
+		return response, nil
+	}
+	return s.rpcOutputHeader(header, header.Hash()), nil
+}
+
+// GetHeaderByHash returns the requested header without assembling the block
+// body.
+func (s *PublicBlockChainAPI) GetHeaderByHash(ctx context.Context, blockHash common.Hash) (map[string]interface{}, error) {
+	if cached, ok := s.headers.Get(blockHash); ok {
+		headerCacheHitMeter.Mark(1)
+		return cached.(map[string]interface{}), nil // skip the header lookup too
+	}
+	header, err := s.b.HeaderByHash(ctx, blockHash)
+	if header == nil {
+		return nil, err
+	}
+	return s.rpcOutputHeader(header, blockHash), nil
+}
+
 // GetUncleByBlockNumberAndIndex returns the uncle block for the given block hash and index. When fullTx is true
 // all transactions in the block are returned in full detail, otherwise only the transaction hash is returned.
 func (s *PublicBlockChainAPI) GetUncleByBlockNumberAndIndex(ctx context.Context, blockNr rpc.BlockNumber, index hexutil.Uint) (map[string]interface{}, error) {
@@ -606,6 +691,105 @@ func (s *PublicBlockChainAPI) GetStorageAt(ctx context.Context, address common.A
 	return res[:], state.Error()
 }
 
+// AccountResult is the reply of man_getProof: the merkle proof of an account
+// along with the proofs of the requested storage slots.
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []string        `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is the merkle proof of a single storage slot.
+type StorageResult struct {
+	Key   string       `json:"key"`
+	Value *hexutil.Big `json:"value"`
+	Proof []string     `json:"proof"`
+}
+
+// GetProof returns the merkle proof of the given account and optionally some
+// of its storage slots, served from the proof cache when possible.
+func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNr rpc.BlockNumber) (*AccountResult, error) {
+	keys := make([]common.Hash, len(storageKeys))
+	for i, key := range storageKeys {
+		keys[i] = common.HexToHash(key)
+	}
+	// The pending state is never committed, don't cache proofs against it
+	cache := s.b.ProofCache()
+	if blockNr == rpc.PendingBlockNumber {
+		cache = nil
+	}
+	if cache != nil {
+		header, err := s.b.HeaderByNumber(ctx, blockNr)
+		if header == nil || err != nil {
+			return nil, err
+		}
+		if result := cache.get(header.Root, address, keys, storageKeys); result != nil {
+			return result, nil
+		}
+	}
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	storageHash := types.EmptyRootHash
+	codeHash := state.GetCodeHash(address)
+	storageTrie := state.StorageTrie(address)
+	if storageTrie != nil {
+		storageHash = storageTrie.Hash()
+	} else {
+		// Non-existent accounts hash to the empty code
+		codeHash = crypto.Keccak256Hash(nil)
+	}
+	storageProof := make([]StorageResult, len(keys))
+	for i, key := range keys {
+		// Slots of non-existent accounts are in the empty trie, the account proof
+		// of absence covers them
+		if storageTrie == nil {
+			storageProof[i] = StorageResult{storageKeys[i], &hexutil.Big{}, []string{}}
+			continue
+		}
+		proof, err := state.GetStorageProof(address, key)
+		if err != nil {
+			return nil, err
+		}
+		value := state.GetState(address, key)
+		storageProof[i] = StorageResult{storageKeys[i], (*hexutil.Big)(value.Big()), toHexSlice(proof)}
+	}
+	accountProof, err := state.GetProof(address)
+	if err != nil {
+		return nil, err
+	}
+	result := &AccountResult{
+		Address:      address,
+		AccountProof: toHexSlice(accountProof),
+		Balance:      (*hexutil.Big)(state.GetBalance(address)),
+		CodeHash:     codeHash,
+		Nonce:        hexutil.Uint64(state.GetNonce(address)),
+		StorageHash:  storageHash,
+		StorageProof: storageProof,
+	}
+	if err := state.Error(); err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		cache.add(header.Root, header.Number.Uint64(), keys, result)
+	}
+	return result, nil
+}
+
+// toHexSlice encodes the nodes of a merkle proof as hex strings.
+func toHexSlice(b [][]byte) []string {
+	r := make([]string, len(b))
+	for i := range b {
+		r[i] = hexutil.Encode(b[i])
+	}
+	return r
+}
+
 // CallArgs represents the arguments for a call.
 type CallArgs struct {
 	From     common.Address  `json:"from"`
@@ -623,6 +807,12 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	if state == nil || err != nil {
 		return nil, 0, false, err
 	}
+	return s.applyCall(ctx, state, header, args, vmCfg, timeout)
+}
+
+// callDefaults returns the sender, gas and gas price of a call, filling in the
+// defaults for the ones not set.
+func (s *PublicBlockChainAPI) callDefaults(args CallArgs) (common.Address, uint64, *big.Int) {
 	// Set sender address or use a default if none specified
 	addr := args.From
 	if addr == (common.Address{}) {
@@ -640,6 +830,13 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	if gasPrice.Sign() == 0 {
 		gasPrice = new(big.Int).SetUint64(defaultGasPrice)
 	}
+	return addr, gas, gasPrice
+}
+
+// applyCall executes a call on top of the given state, leaving its effects in
+// the state.
+func (s *PublicBlockChainAPI) applyCall(ctx context.Context, statedb *state.StateDB, header *types.Header, args CallArgs, vmCfg vm.Config, timeout time.Duration) ([]byte, uint64, bool, error) {
+	addr, gas, gasPrice := s.callDefaults(args)
 
 	// Create new call message
 	msg := types.NewMessage(addr, args.To, 0, args.Value.ToInt(), gas, gasPrice, args.Data, false)
@@ -657,7 +854,7 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	defer cancel()
 
 	// Get a new instance of the EVM.
-	evm, vmError, err := s.b.GetEVM(ctx, msg, state, header, vmCfg)
+	evm, vmError, err := s.b.GetEVM(ctx, msg, statedb, header, vmCfg)
 	if err != nil {
 		return nil, 0, false, err
 	}
@@ -681,10 +878,80 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
 func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, _, err := s.doCall(ctx, args, blockNr, vm.Config{}, 5*time.Second)
+	result, gas, _, err := s.doCall(ctx, args, blockNr, vm.Config{}, 5*time.Second)
+	s.b.CallStats().record(CallKindCall, args.To, args.Data, gas)
 	return (hexutil.Bytes)(result), err
 }
 
+// maxMulticallCalls is the maximum number of calls accepted by a single
+// multicall request.
+const maxMulticallCalls = 256
+
+// MulticallResult is the outcome of a single call of a multicall request.
+type MulticallResult struct {
+	ReturnData hexutil.Bytes  `json:"returnData"`
+	GasUsed    hexutil.Uint64 `json:"gasUsed"`
+	Failed     bool           `json:"failed"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// Multicall executes a list of calls against the state of the given block in one
+// request. By default every call sees the original state; if sequential is set,
+// the state effects of each call are visible to the ones following it. Only the
+// effects of the execution carry over: the gas a call buys and refunds and the
+// nonce bump of a call message are undone, and the refund counter is reset
+// between calls as between the transactions of a block. Errors of individual
+// calls are reported in their results.
+func (s *PublicBlockChainAPI) Multicall(ctx context.Context, calls []CallArgs, blockNr rpc.BlockNumber, sequential *bool) ([]MulticallResult, error) {
+	if len(calls) > maxMulticallCalls {
+		return nil, fmt.Errorf("too many calls: %d > %d", len(calls), maxMulticallCalls)
+	}
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	// The whole batch shares the timeout of a single call
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	seq := sequential != nil && *sequential
+	deleteEmpty := s.b.ChainConfig().IsEIP158(header.Number)
+
+	results := make([]MulticallResult, len(calls))
+	for i, args := range calls {
+		callState := state
+		if !seq {
+			callState = state.Copy()
+		}
+		from, _, gasPrice := s.callDefaults(args)
+		nonce, balance := callState.GetNonce(from), new(big.Int).Set(callState.GetBalance(from))
+
+		res, gas, failed, err := s.applyCall(ctx, callState, header, args, vm.Config{}, 0)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("multicall aborted at call %d: %v", i, ctx.Err())
+		}
+		results[i] = MulticallResult{ReturnData: res, GasUsed: hexutil.Uint64(gas), Failed: failed}
+		if err != nil {
+			results[i].Failed, results[i].Error = true, err.Error()
+		}
+		if seq {
+			// The sender was funded with the maximum balance for the call, keep
+			// only what the execution moved in or out of its original balance.
+			moved := new(big.Int).Sub(callState.GetBalance(from), math.MaxBig256)
+			moved.Add(moved, new(big.Int).Mul(new(big.Int).SetUint64(gas), gasPrice))
+			if balance.Add(balance, moved); balance.Sign() < 0 {
+				balance.SetUint64(0)
+			}
+			callState.SetBalance(from, balance)
+			if args.To != nil {
+				callState.SetNonce(from, nonce)
+			}
+			callState.Finalise(deleteEmpty)
+		}
+	}
+	return results, nil
+}
+
 // EstimateGas returns an estimate of the amount of gas needed to execute the
 // given transaction against the current pending block.
 func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (hexutil.Uint64, error) {
@@ -728,7 +995,9 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	// Reject the transaction as invalid if it still fails at the highest allowance
 	if hi == cap {
 		if !executable(hi) {
-			return 0, fmt.Errorf("gas required exceeds allowance or always failing transaction")
+			return 0, apiErrorWithData(errGasEstimateFail, func(reason string) interface{} {
+				return gasErrorData{Reason: reason, Cap: hexutil.Uint64(cap)}
+			})
 		}
 	}
 	return hexutil.Uint64(hi), nil
@@ -748,7 +1017,7 @@ func (s *PublicBlockChainAPI) GetSelfLevel() int {
 func (s *PublicBlockChainAPI) GetSignAccountsByNumber(ctx context.Context, blockNr rpc.BlockNumber) ([]common.VerifiedSign, error) {
 	header, err := s.b.HeaderByNumber(ctx, blockNr)
 	if header != nil {
-		return header.SignAccounts(), nil
+		return verifiedSigns(header.SignAccounts()), nil
 	}
 	return nil, err
 }
@@ -756,11 +1025,27 @@ func (s *PublicBlockChainAPI) GetSignAccountsByNumber(ctx context.Context, block
 func (s *PublicBlockChainAPI) GetSignAccountsByHash(ctx context.Context, hash common.Hash) ([]common.VerifiedSign, error) {
 	block, err := s.b.GetBlock(ctx, hash)
 	if block != nil {
-		return block.SignAccounts(), nil
+		return verifiedSigns(block.SignAccounts()), nil
 	}
 	return nil, err
 }
 
+// verifiedSigns lists the valid signatures of a block with their signers,
+// ordered by signer account.
+func verifiedSigns(accounts map[common.Signature]common.Address) []common.VerifiedSign {
+	signs := make([]common.VerifiedSign, 0, len(accounts))
+	for sign, account := range accounts {
+		signs = append(signs, common.VerifiedSign{Sign: sign, Account: account, Validate: true})
+	}
+	sort.Slice(signs, func(i, j int) bool {
+		if cmp := bytes.Compare(signs[i].Account[:], signs[j].Account[:]); cmp != 0 {
+			return cmp < 0
+		}
+		return bytes.Compare(signs[i].Sign[:], signs[j].Sign[:]) < 0
+	})
+	return signs
+}
+
 // ExecutionResult groups all structured logs emitted by the EVM
 // while replaying a transaction in debug mode as well as transaction
 // execution status, the amount of gas used and the return value
@@ -822,14 +1107,26 @@ func FormatLogs(logs []vm.StructLog) []StructLogRes {
 	return formatted
 }
 
-// rpcOutputBlock converts the given block to the RPC output which depends on fullTx. If inclTx is true transactions are
-// returned. When fullTx is true the returned block contains full transaction details, otherwise it will only contain
-// transaction hashes.
-func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx bool) (map[string]interface{}, error) {
-	head := b.Header() // copies the header once
+// rpcOutputHeader returns the RPC output of the header with the given hash,
+// served from and added to the header cache. The returned fields are shared
+// and must not be modified.
+func (s *PublicBlockChainAPI) rpcOutputHeader(head *types.Header, hash common.Hash) map[string]interface{} {
+	if cached, ok := s.headers.Get(hash); ok {
+		headerCacheHitMeter.Mark(1)
+		return cached.(map[string]interface{})
+	}
+	headerCacheMissMeter.Mark(1)
+
+	fields := s.rpcMarshalHeader(head, hash)
+	s.headers.Add(hash, fields)
+	return fields
+}
+
+// rpcMarshalHeader converts the given header to the RPC output of its fields.
+func (s *PublicBlockChainAPI) rpcMarshalHeader(head *types.Header, hash common.Hash) map[string]interface{} {
 	fields := map[string]interface{}{
 		"number":           (*hexutil.Big)(head.Number),
-		"hash":             b.Hash(),
+		"hash":             hash,
 		"parentHash":       head.ParentHash,
 		"nonce":            head.Nonce,
 		"mixHash":          head.MixDigest,
@@ -838,9 +1135,8 @@ func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx
 		"stateRoot":        head.Root,
 		"miner":            head.Coinbase,
 		"difficulty":       (*hexutil.Big)(head.Difficulty),
-		"totalDifficulty":  (*hexutil.Big)(s.b.GetTd(b.Hash())),
+		"totalDifficulty":  (*hexutil.Big)(s.b.GetTd(hash)),
 		"extraData":        hexutil.Bytes(head.Extra),
-		"size":             hexutil.Uint64(b.Size()),
 		"gasLimit":         hexutil.Uint64(head.GasLimit),
 		"gasUsed":          hexutil.Uint64(head.GasUsed),
 		"timestamp":        (*hexutil.Big)(head.Time),
@@ -852,6 +1148,18 @@ func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx
 		"signatures":       head.Signatures,
 		"version":          hexutil.Bytes(head.Version),
 	}
+	if head.BaseFee != nil {
+		fields["baseFeePerGas"] = (*hexutil.Big)(head.BaseFee)
+	}
+	return fields
+}
+
+// rpcOutputBlock converts the given block to the RPC output which depends on fullTx. If inclTx is true transactions are
+// returned. When fullTx is true the returned block contains full transaction details, otherwise it will only contain
+// transaction hashes.
+func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx bool) (map[string]interface{}, error) {
+	fields := s.rpcMarshalHeader(b.Header(), b.Hash())
+	fields["size"] = hexutil.Uint64(b.Size())
 
 	if inclTx {
 		formatTx := func(tx *types.Transaction) (interface{}, error) {
@@ -1139,14 +1447,15 @@ func (s *PublicTransactionPoolAPI) sign(addr common.Address, tx *types.Transacti
 
 	wallet, err := s.b.AccountManager().Find(account)
 	if err != nil {
-		return nil, err
+		return nil, apiError(err)
 	}
 	// Request the wallet to sign the transaction
 	var chainID *big.Int
 	if config := s.b.ChainConfig(); config.IsEIP155(s.b.CurrentBlock().Number()) {
 		chainID = config.ChainId
 	}
-	return wallet.SignTx(account, tx, chainID)
+	signed, err := wallet.SignTx(account, tx, chainID)
+	return signed, apiError(err)
 }
 
 //YY
@@ -1256,8 +1565,11 @@ func (args *SendTxArgs) toTransaction() *types.Transaction {
 
 // submitTransaction is a helper function that submits tx to txPool and logs a message.
 func submitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (common.Hash, error) {
+	if !tx.Protected() && !b.UnprotectedAllowed(tx.Hash()) {
+		return common.Hash{}, apiError(core.ErrUnprotectedTx)
+	}
 	if err := b.SendTx(ctx, tx); err != nil {
-		return common.Hash{}, err
+		return common.Hash{}, txError(ctx, b, tx, err)
 	}
 	if tx.To() == nil {
 		signer := types.MakeSigner(b.ChainConfig(), b.CurrentBlock().Number())
@@ -1282,7 +1594,7 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 
 	wallet, err := s.b.AccountManager().Find(account)
 	if err != nil {
-		return common.Hash{}, err
+		return common.Hash{}, apiError(err)
 	}
 
 	if args.Nonce == nil {
@@ -1294,8 +1606,7 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 		nc1 := params.NonceAddOne
 		nc := uint64(*args.Nonce)
 		if nc < nc1 {
-			err = errors.New("Nonce Wrongful")
-			return common.Hash{}, err
+			return common.Hash{}, apiError(errInvalidNonce)
 		}
 	}
 	//YY
@@ -1322,7 +1633,7 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 	}
 	signed, err := wallet.SignTx(account, tx, chainID)
 	if err != nil {
-		return common.Hash{}, err
+		return common.Hash{}, apiError(err)
 	}
 	return submitTransaction(ctx, s.b, signed)
 }
@@ -1334,6 +1645,7 @@ func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encod
 	if err := rlp.DecodeBytes(encodedTx, tx); err != nil {
 		return common.Hash{}, err
 	}
+	s.b.CallStats().record(CallKindSend, tx.To(), tx.Data(), tx.Gas())
 	return submitTransaction(ctx, s.b, tx)
 }
 
@@ -1352,14 +1664,14 @@ func (s *PublicTransactionPoolAPI) Sign(addr common.Address, data hexutil.Bytes)
 
 	wallet, err := s.b.AccountManager().Find(account)
 	if err != nil {
-		return nil, err
+		return nil, apiError(err)
 	}
 	// Sign the requested hash with the wallet
 	signature, err := wallet.SignHash(account, signHash(data))
 	if err == nil {
 		signature[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
 	}
-	return signature, err
+	return signature, apiError(err)
 }
 
 // SignTransactionResult represents a RLP encoded signed transaction.
@@ -1395,6 +1707,62 @@ func (s *PublicTransactionPoolAPI) SignTransaction(ctx context.Context, args Sen
 	return &SignTransactionResult{data, tx}, nil
 }
 
+// FillTransactionResult is an unsigned transaction with all fields populated,
+// along with its RLP encoding and the hash to sign for the chain it targets.
+type FillTransactionResult struct {
+	Raw      hexutil.Bytes      `json:"raw"`
+	Tx       *types.Transaction `json:"tx"`
+	ChainId  *hexutil.Big       `json:"chainId"`
+	SignHash common.Hash        `json:"signHash"`
+}
+
+// FillTransaction populates the unspecified fields of the given transaction:
+// the nonce from the pending state, the gas limit from an estimate, the gas
+// price from the oracle and the chain ID. The transaction is returned unsigned,
+// ready for signing outside of the node.
+func (s *PublicTransactionPoolAPI) FillTransaction(ctx context.Context, args SendTxArgs) (*FillTransactionResult, error) {
+	if args.Data != nil && args.Input != nil && !bytes.Equal(*args.Data, *args.Input) {
+		return nil, errors.New(`Both "data" and "input" are set and not equal. Please use "input" to pass transaction call data.`)
+	}
+	// Multi-recipient transactions can't be simulated, they keep the default gas
+	if args.Gas == nil && len(args.ExtraTo) == 0 {
+		call := CallArgs{From: args.From, To: args.To}
+		if args.Data != nil {
+			call.Data = *args.Data
+		} else if args.Input != nil {
+			call.Data = *args.Input
+		}
+		if args.Value != nil {
+			call.Value = *args.Value
+		}
+		if args.GasPrice != nil {
+			call.GasPrice = *args.GasPrice
+		}
+		gas, err := (&PublicBlockChainAPI{b: s.b}).EstimateGas(ctx, call)
+		if err != nil {
+			return nil, err
+		}
+		args.Gas = &gas
+	}
+	if err := args.setDefaults(ctx, s.b); err != nil {
+		return nil, err
+	}
+	tx := args.toTransaction()
+	data, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		config  = s.b.ChainConfig()
+		signer  = types.MakeSigner(config, s.b.CurrentBlock().Number())
+		chainID *hexutil.Big
+	)
+	if config.IsEIP155(s.b.CurrentBlock().Number()) {
+		chainID = (*hexutil.Big)(config.ChainId)
+	}
+	return &FillTransactionResult{data, tx, chainID, signer.Hash(tx)}, nil
+}
+
 // PendingTransactions returns the transactions that are in the transaction pool and have a from address that is one of
 // the accounts this node manages.
 func (s *PublicTransactionPoolAPI) PendingTransactions() ([]*RPCTransaction, error) {
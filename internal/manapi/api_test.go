@@ -0,0 +1,311 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package manapi
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/params"
+	"github.com/matrix/go-matrix/rlp"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+var (
+	// counterCode increments storage slot 0 and returns its new value
+	counterCode = common.FromHex("0x6000546001018060005560005260206000f3")
+	// balanceCode returns the balance of the address given as calldata
+	balanceCode = common.FromHex("0x6000353160005260206000f3")
+)
+
+func newMulticallTest(t *testing.T) (*testBackend, common.Address, common.Address, common.Address) {
+	var (
+		sender  = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		counter = common.HexToAddress("0x2000000000000000000000000000000000000002")
+		balance = common.HexToAddress("0x3000000000000000000000000000000000000003")
+	)
+	b := newTestBackend(t, core.GenesisAlloc{
+		sender:  {Balance: big.NewInt(1000)},
+		counter: {Balance: new(big.Int), Code: counterCode},
+		balance: {Balance: new(big.Int), Code: balanceCode},
+	})
+	return b, sender, counter, balance
+}
+
+func multicallValues(t *testing.T, results []MulticallResult) []int64 {
+	values := make([]int64, len(results))
+	for i, res := range results {
+		if res.Failed {
+			t.Fatalf("call %d failed: %s", i, res.Error)
+		}
+		values[i] = new(big.Int).SetBytes(res.ReturnData).Int64()
+	}
+	return values
+}
+
+func TestMulticall(t *testing.T) {
+	b, sender, counter, _ := newMulticallTest(t)
+	defer b.chain.Stop()
+	api := NewPublicBlockChainAPI(b)
+
+	calls := []CallArgs{
+		{From: sender, To: &counter},
+		{From: sender, To: &counter},
+		{From: sender, To: &counter},
+	}
+	// By default every call sees the original state
+	results, err := api.Multicall(context.Background(), calls, rpc.LatestBlockNumber, nil)
+	if err != nil {
+		t.Fatalf("failed to run multicall: %v", err)
+	}
+	if values := multicallValues(t, results); values[0] != 1 || values[1] != 1 || values[2] != 1 {
+		t.Errorf("independent calls interfered: have %v, want [1 1 1]", values)
+	}
+	for i, res := range results {
+		if res.GasUsed == 0 {
+			t.Errorf("call %d: no gas reported", i)
+		}
+	}
+	// Sequential calls see the effects of the ones before them
+	sequential := true
+	results, err = api.Multicall(context.Background(), calls, rpc.LatestBlockNumber, &sequential)
+	if err != nil {
+		t.Fatalf("failed to run sequential multicall: %v", err)
+	}
+	if values := multicallValues(t, results); values[0] != 1 || values[1] != 2 || values[2] != 3 {
+		t.Errorf("sequential calls mismatch: have %v, want [1 2 3]", values)
+	}
+	// Failing calls are reported in their own result
+	results, err = api.Multicall(context.Background(), []CallArgs{{From: sender, To: &counter, Gas: 1}}, rpc.LatestBlockNumber, nil)
+	if err != nil {
+		t.Fatalf("failed to run multicall: %v", err)
+	}
+	if !results[0].Failed || results[0].Error == "" {
+		t.Errorf("intrinsic gas failure not reported: %+v", results[0])
+	}
+	// Oversized batches are refused
+	if _, err := api.Multicall(context.Background(), make([]CallArgs, maxMulticallCalls+1), rpc.LatestBlockNumber, nil); err == nil {
+		t.Error("oversized batch accepted")
+	}
+}
+
+// Tests that only the execution effects of a call carry over to the next one in
+// sequential mode, not the funding and gas purchase of its sender.
+func TestMulticallSequentialSender(t *testing.T) {
+	b, sender, counter, balance := newMulticallTest(t)
+	defer b.chain.Stop()
+	api := NewPublicBlockChainAPI(b)
+
+	var (
+		other      = common.HexToAddress("0x4000000000000000000000000000000000000004")
+		recipient  = common.HexToAddress("0x5000000000000000000000000000000000000005")
+		sequential = true
+	)
+	calls := []CallArgs{
+		{From: sender, To: &counter, GasPrice: hexutil.Big(*big.NewInt(1))},
+		{From: other, To: &balance, Data: sender.Hash().Bytes()},
+		{From: sender, To: &recipient, Value: hexutil.Big(*big.NewInt(300))},
+		{From: other, To: &balance, Data: sender.Hash().Bytes()},
+		{From: other, To: &balance, Data: recipient.Hash().Bytes()},
+	}
+	results, err := api.Multicall(context.Background(), calls, rpc.LatestBlockNumber, &sequential)
+	if err != nil {
+		t.Fatalf("failed to run sequential multicall: %v", err)
+	}
+	values := multicallValues(t, results)
+	if values[1] != 1000 {
+		t.Errorf("sender balance after gas purchase: have %d, want 1000", values[1])
+	}
+	if values[3] != 700 {
+		t.Errorf("sender balance after transfer: have %d, want 700", values[3])
+	}
+	if values[4] != 300 {
+		t.Errorf("recipient balance after transfer: have %d, want 300", values[4])
+	}
+}
+
+// fillBackend serves the gas price suggestion and pool nonce used to fill in
+// transactions.
+type fillBackend struct {
+	*testBackend
+	price *big.Int
+	nonce uint64
+}
+
+func (b *fillBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(b.price), nil
+}
+
+func (b *fillBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
+	return b.nonce, nil
+}
+
+func TestFillTransaction(t *testing.T) {
+	var (
+		sender    = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		recipient = common.HexToAddress("0x2000000000000000000000000000000000000002")
+	)
+	b := &fillBackend{
+		testBackend: newTestBackend(t, core.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}}),
+		price:       big.NewInt(1),
+		nonce:       7,
+	}
+	defer b.chain.Stop()
+	api := NewPublicTransactionPoolAPI(b, new(AddrLocker))
+
+	// Unset fields are estimated and suggested, the price no lower than the floor
+	value := hexutil.Big(*big.NewInt(100))
+	res, err := api.FillTransaction(context.Background(), SendTxArgs{From: sender, To: &recipient, Value: &value})
+	if err != nil {
+		t.Fatalf("failed to fill transaction: %v", err)
+	}
+	if res.Tx.Gas() != params.TxGas {
+		t.Errorf("gas mismatch: have %d, want %d", res.Tx.Gas(), params.TxGas)
+	}
+	if res.Tx.GasPrice().Cmp(big.NewInt(18000000000)) != 0 {
+		t.Errorf("gas price mismatch: have %v, want the 18 gwei floor", res.Tx.GasPrice())
+	}
+	if res.Tx.Nonce() != 7 || res.Tx.Value().Int64() != 100 || *res.Tx.To() != recipient {
+		t.Errorf("transaction fields mismatch: nonce %d, value %v, to %x", res.Tx.Nonce(), res.Tx.Value(), res.Tx.To())
+	}
+	// The result is unsigned, with the encoding and signing hash matching the chain
+	if v, r, s := res.Tx.RawSignatureValues(); v.Sign() != 0 || r.Sign() != 0 || s.Sign() != 0 {
+		t.Error("filled transaction is signed")
+	}
+	if res.ChainId == nil || res.ChainId.ToInt().Cmp(params.TestChainConfig.ChainId) != 0 {
+		t.Errorf("chain id mismatch: have %v, want %v", res.ChainId, params.TestChainConfig.ChainId)
+	}
+	signer := types.MakeSigner(params.TestChainConfig, b.CurrentBlock().Number())
+	if res.SignHash != signer.Hash(res.Tx) {
+		t.Errorf("signing hash mismatch: have %x, want %x", res.SignHash, signer.Hash(res.Tx))
+	}
+	decoded := new(types.Transaction)
+	if err := rlp.DecodeBytes(res.Raw, decoded); err != nil {
+		t.Fatalf("failed to decode raw transaction: %v", err)
+	}
+	if decoded.Hash() != res.Tx.Hash() {
+		t.Errorf("raw encoding mismatch: have %x, want %x", decoded.Hash(), res.Tx.Hash())
+	}
+	// Explicit fields are kept as given
+	var (
+		gas   = hexutil.Uint64(50000)
+		price = hexutil.Big(*big.NewInt(20000000000))
+		nonce = hexutil.Uint64(3)
+	)
+	res, err = api.FillTransaction(context.Background(), SendTxArgs{From: sender, To: &recipient, Gas: &gas, GasPrice: &price, Nonce: &nonce})
+	if err != nil {
+		t.Fatalf("failed to fill transaction: %v", err)
+	}
+	if res.Tx.Gas() != 50000 || res.Tx.GasPrice().Cmp(price.ToInt()) != 0 || res.Tx.Nonce() != 3 {
+		t.Errorf("explicit fields overridden: gas %d, price %v, nonce %d", res.Tx.Gas(), res.Tx.GasPrice(), res.Tx.Nonce())
+	}
+	// Conflicting call data is refused
+	data, input := hexutil.Bytes{0x01}, hexutil.Bytes{0x02}
+	if _, err := api.FillTransaction(context.Background(), SendTxArgs{From: sender, To: &recipient, Data: &data, Input: &input}); err == nil {
+		t.Error("conflicting data and input accepted")
+	}
+}
+
+// Tests that the header only endpoints return the header fields of the block
+// endpoints, serve sealed headers from the cache and never cache pending ones.
+func TestGetHeader(t *testing.T) {
+	b := newTestBackend(t, nil)
+	defer b.chain.Stop()
+	blocks := b.generate(t, 2, nil)
+
+	api := NewPublicBlockChainAPI(b)
+	ctx := context.Background()
+
+	header, err := api.GetHeaderByNumber(ctx, 1)
+	if err != nil {
+		t.Fatalf("failed to retrieve header: %v", err)
+	}
+	block, err := api.GetBlockByNumber(ctx, 1, false)
+	if err != nil {
+		t.Fatalf("failed to retrieve block: %v", err)
+	}
+	for field, value := range header {
+		if !reflect.DeepEqual(value, block[field]) {
+			t.Errorf("field %q mismatch: have %v, want %v", field, value, block[field])
+		}
+	}
+	for _, field := range []string{"transactions", "uncles", "size"} {
+		if _, ok := header[field]; ok {
+			t.Errorf("header contains body field %q", field)
+		}
+	}
+	// Sealed headers are cached by hash and shared by both endpoints
+	hash := blocks[0].Hash()
+	if !api.headers.Contains(hash) {
+		t.Fatal("header not cached")
+	}
+	cached, err := api.GetHeaderByHash(ctx, hash)
+	if err != nil {
+		t.Fatalf("failed to retrieve header by hash: %v", err)
+	}
+	if reflect.ValueOf(cached).Pointer() != reflect.ValueOf(header).Pointer() {
+		t.Error("header by hash not served from the cache")
+	}
+	// Pending headers are returned without identity and kept out of the cache
+	pending, err := api.GetHeaderByNumber(ctx, rpc.PendingBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to retrieve pending header: %v", err)
+	}
+	for _, field := range []string{"hash", "nonce", "miner"} {
+		if pending[field] != nil {
+			t.Errorf("pending header field %q not cleared: %v", field, pending[field])
+		}
+	}
+	if api.headers.Contains(blocks[1].Hash()) {
+		t.Error("pending header cached")
+	}
+	if latest, _ := api.GetHeaderByNumber(ctx, rpc.LatestBlockNumber); latest["hash"] != blocks[1].Hash() {
+		t.Errorf("latest header hash mismatch: have %v, want %x", latest["hash"], blocks[1].Hash())
+	}
+	// Unknown headers are reported as missing
+	if header, err := api.GetHeaderByHash(ctx, common.Hash{1}); header != nil || err != nil {
+		t.Errorf("unknown header returned: %v, %v", header, err)
+	}
+	if header, err := api.GetHeaderByNumber(ctx, 10); header != nil || err != nil {
+		t.Errorf("future header returned: %v, %v", header, err)
+	}
+}
+
+func TestVerifiedSignsOrder(t *testing.T) {
+	accounts := make(map[common.Signature]common.Address)
+	for i := 0; i < 16; i++ {
+		accounts[common.Signature{byte(i)}] = common.Address{byte(31 * i % 16)}
+	}
+	want := verifiedSigns(accounts)
+	for i := 1; i < len(want); i++ {
+		if bytes.Compare(want[i-1].Account[:], want[i].Account[:]) >= 0 {
+			t.Fatalf("signs not ordered by account: %x before %x", want[i-1].Account, want[i].Account)
+		}
+	}
+	for i := 0; i < 8; i++ {
+		if have := verifiedSigns(accounts); !reflect.DeepEqual(have, want) {
+			t.Fatalf("sign order changed between calls")
+		}
+	}
+}
@@ -41,6 +41,7 @@ type Backend interface {
 	Downloader() *downloader.Downloader
 	ProtocolVersion() int
 	SuggestPrice(ctx context.Context) (*big.Int, error)
+	SuggestTipCap(ctx context.Context) (*big.Int, error)
 	ChainDb() mandb.Database
 	EventMux() *event.TypeMux
 	AccountManager() *accounts.Manager
@@ -48,8 +49,11 @@ type Backend interface {
 	// BlockChain API
 	SetHead(number uint64)
 	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
+	HeaderByHash(ctx context.Context, blockHash common.Hash) (*types.Header, error)
 	BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error)
 	StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error)
+	ProofCache() *ProofCache // nil if proofs are not cached
+	CallStats() *CallStats   // nil if calls are not tracked
 	GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error)
 	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
 	GetTd(blockHash common.Hash) *big.Int
@@ -60,11 +64,13 @@ type Backend interface {
 
 	// TxPool API
 	SendTx(ctx context.Context, signedTx *types.Transaction) error
+	UnprotectedAllowed(hash common.Hash) bool // Whether a transaction without replay protection is accepted
 	GetPoolTransactions() (types.Transactions, error)
 	GetPoolTransaction(txHash common.Hash) *types.Transaction
 	GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error)
 	Stats() (pending int, queued int)
 	TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions)
+	FlushTxs(addr common.Address) types.Transactions
 	SubscribeNewTxsEvent(chan<- core.NewTxsEvent) event.Subscription
 
 	SignTx(signedTx *types.Transaction, chainID *big.Int) (*types.Transaction, error) //YY
@@ -72,11 +78,14 @@ type Backend interface {
 	FetcherNotify(hash common.Hash, number uint64)                                    //YY
 
 	ChainConfig() *params.ChainConfig
+	NetworkId() uint64
 	CurrentBlock() *types.Block
 }
 
 func GetAPIs(apiBackend Backend) []rpc.API {
 	nonceLock := new(AddrLocker)
+	abis := newABIRegistry(apiBackend)
+	blockchain := NewPublicBlockChainAPI(apiBackend)
 	return []rpc.API{
 		{
 			Namespace: "man",
@@ -91,12 +100,12 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 		}, {
 			Namespace: "man",
 			Version:   "1.0",
-			Service:   NewPublicBlockChainAPI(apiBackend),
+			Service:   blockchain,
 			Public:    true,
 		},{
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   NewPublicBlockChainAPI(apiBackend),
+			Service:   blockchain,
 			Public:    true,
 		}, {
 			Namespace: "man",
@@ -113,6 +122,10 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 			Version:   "1.0",
 			Service:   NewPublicTxPoolAPI(apiBackend),
 			Public:    true,
+		}, {
+			Namespace: "txpool",
+			Version:   "1.0",
+			Service:   NewPrivateTxPoolAPI(apiBackend),
 		}, {
 			Namespace: "debug",
 			Version:   "1.0",
@@ -137,6 +150,35 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 			Version:   "1.0",
 			Service:   NewPrivateAccountAPI(apiBackend, nonceLock),
 			Public:    false,
+		}, {
+			Namespace: "man",
+			Version:   "1.0",
+			Service:   newPublicDecoderAPI(abis),
+			Public:    true,
+		}, {
+			Namespace: "eth",
+			Version:   "1.0",
+			Service:   newPublicDecoderAPI(abis),
+			Public:    true,
+		}, {
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   newPrivateDecoderAPI(abis),
+		}, {
+			Namespace: "matrix",
+			Version:   "1.0",
+			Service:   NewPublicChainInfoAPI(apiBackend),
+			Public:    true,
+		}, {
+			Namespace: "matrix",
+			Version:   "1.0",
+			Service:   NewPublicBlockStatsAPI(apiBackend),
+			Public:    true,
+		}, {
+			Namespace: "matrix",
+			Version:   "1.0",
+			Service:   NewPublicValidatorAPI(apiBackend),
+			Public:    true,
 		},
 	}
 }
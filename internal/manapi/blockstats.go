@@ -0,0 +1,235 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package manapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+const (
+	maxBlockStatsRange      = 10000 // Maximum number of blocks aggregated by a single request
+	defaultBlockStatsPoints = 500   // Data points returned if neither a bucket size nor a point limit is given
+)
+
+// BlockStatsArgs selects the blocks to aggregate and how to downsample them.
+type BlockStatsArgs struct {
+	FromBlock  *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock    *rpc.BlockNumber `json:"toBlock"`    // Defaults to the latest block
+	BucketSize hexutil.Uint64   `json:"bucketSize"` // Blocks per data point, zero derives it from maxPoints
+	MaxPoints  hexutil.Uint64   `json:"maxPoints"`  // Upper bound on the number of data points
+}
+
+// BlockInterval holds the time between consecutive blocks, in seconds.
+type BlockInterval struct {
+	Min  hexutil.Uint64 `json:"min"`
+	Max  hexutil.Uint64 `json:"max"`
+	Mean float64        `json:"mean"`
+}
+
+// BlockStatsPoint aggregates the usage statistics of a run of blocks.
+type BlockStatsPoint struct {
+	FromBlock       hexutil.Uint64 `json:"fromBlock"`
+	ToBlock         hexutil.Uint64 `json:"toBlock"`
+	GasUsed         hexutil.Uint64 `json:"gasUsed"`
+	GasLimit        hexutil.Uint64 `json:"gasLimit"`
+	GasUsedRatio    float64        `json:"gasUsedRatio"`    // Total gas used over total gas limit
+	MaxGasUsedRatio float64        `json:"maxGasUsedRatio"` // Fullness of the fullest block
+	TxCount         hexutil.Uint64 `json:"txCount"`
+	AvgGasPrice     *hexutil.Big   `json:"avgGasPrice"` // Mean price of the included transactions, nil if none
+	Interval        *BlockInterval `json:"interval"`    // Nil if no block interval is known
+}
+
+// BlockStats is the result of matrix_blockStats.
+type BlockStats struct {
+	BucketSize hexutil.Uint64     `json:"bucketSize"`
+	Points     []*BlockStatsPoint `json:"points"`
+	Summary    *BlockStatsPoint   `json:"summary"` // Aggregate of the whole range
+}
+
+// PublicBlockStatsAPI aggregates historical block usage server side, so that
+// capacity dashboards don't need to fetch every block.
+type PublicBlockStatsAPI struct {
+	b Backend
+}
+
+// NewPublicBlockStatsAPI creates a new block statistics API.
+func NewPublicBlockStatsAPI(b Backend) *PublicBlockStatsAPI {
+	return &PublicBlockStatsAPI{b}
+}
+
+// BlockStats returns the gas usage, block fullness, transaction counts, average
+// gas prices and block intervals of a range of blocks, downsampled into buckets
+// of consecutive blocks.
+func (s *PublicBlockStatsAPI) BlockStats(ctx context.Context, args BlockStatsArgs) (*BlockStats, error) {
+	if args.FromBlock == nil {
+		return nil, errors.New("missing fromBlock")
+	}
+	head := s.b.CurrentBlock().NumberU64()
+	resolve := func(number rpc.BlockNumber) uint64 {
+		if number < 0 || uint64(number) > head {
+			return head // latest, pending and future blocks
+		}
+		return uint64(number)
+	}
+	from, to := resolve(*args.FromBlock), head
+	if args.ToBlock != nil {
+		to = resolve(*args.ToBlock)
+	}
+	if from > to {
+		return nil, fmt.Errorf("fromBlock %d after toBlock %d", from, to)
+	}
+	count := to - from + 1
+	if count > maxBlockStatsRange {
+		return nil, fmt.Errorf("block range too large: %d blocks, maximum %d", count, maxBlockStatsRange)
+	}
+	bucket := uint64(args.BucketSize)
+	if bucket == 0 {
+		points := uint64(args.MaxPoints)
+		if points == 0 {
+			points = defaultBlockStatsPoints
+		}
+		bucket = (count + points - 1) / points
+	}
+	// Fetch the parent of the range to know the interval of its first block
+	var prevTime *big.Int
+	if from > 0 {
+		parent, err := s.b.HeaderByNumber(ctx, rpc.BlockNumber(from-1))
+		if err != nil {
+			return nil, err
+		}
+		if parent != nil {
+			prevTime = parent.Time
+		}
+	}
+	var (
+		stats = &BlockStats{BucketSize: hexutil.Uint64(bucket)}
+		total = new(blockStatsAcc)
+		point *blockStatsAcc
+	)
+	for number := from; number <= to; number++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		block, err := s.b.BlockByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			return nil, fmt.Errorf("block %d not found", number)
+		}
+		if point == nil {
+			point = new(blockStatsAcc)
+		}
+		point.add(block, prevTime)
+		total.add(block, prevTime)
+		prevTime = block.Time()
+
+		if (number-from+1)%bucket == 0 || number == to {
+			stats.Points = append(stats.Points, point.result())
+			point = nil
+		}
+	}
+	stats.Summary = total.result()
+	return stats, nil
+}
+
+// blockStatsAcc accumulates the statistics of consecutive blocks.
+type blockStatsAcc struct {
+	first, last       uint64
+	blocks            uint64
+	gasUsed, gasLimit uint64
+	maxRatio          float64
+	txs               uint64
+	priceSum          big.Int
+
+	intervals   uint64
+	intervalSum uint64
+	intervalMin uint64
+	intervalMax uint64
+}
+
+// add accounts a block, given the timestamp of its parent if known.
+func (acc *blockStatsAcc) add(block *types.Block, parentTime *big.Int) {
+	if acc.blocks == 0 {
+		acc.first = block.NumberU64()
+	}
+	acc.last = block.NumberU64()
+	acc.blocks++
+
+	acc.gasUsed += block.GasUsed()
+	acc.gasLimit += block.GasLimit()
+	if ratio := gasUsedRatio(block.GasUsed(), block.GasLimit()); ratio > acc.maxRatio {
+		acc.maxRatio = ratio
+	}
+	for _, tx := range block.Transactions() {
+		acc.priceSum.Add(&acc.priceSum, tx.GasPrice())
+		acc.txs++
+	}
+	if parentTime != nil {
+		var interval uint64
+		if block.Time().Cmp(parentTime) > 0 {
+			interval = new(big.Int).Sub(block.Time(), parentTime).Uint64()
+		}
+		if acc.intervals == 0 || interval < acc.intervalMin {
+			acc.intervalMin = interval
+		}
+		if interval > acc.intervalMax {
+			acc.intervalMax = interval
+		}
+		acc.intervalSum += interval
+		acc.intervals++
+	}
+}
+
+// result converts the accumulated statistics into an RPC data point.
+func (acc *blockStatsAcc) result() *BlockStatsPoint {
+	point := &BlockStatsPoint{
+		FromBlock:       hexutil.Uint64(acc.first),
+		ToBlock:         hexutil.Uint64(acc.last),
+		GasUsed:         hexutil.Uint64(acc.gasUsed),
+		GasLimit:        hexutil.Uint64(acc.gasLimit),
+		GasUsedRatio:    gasUsedRatio(acc.gasUsed, acc.gasLimit),
+		MaxGasUsedRatio: acc.maxRatio,
+		TxCount:         hexutil.Uint64(acc.txs),
+	}
+	if acc.txs > 0 {
+		avg := new(big.Int).Div(&acc.priceSum, new(big.Int).SetUint64(acc.txs))
+		point.AvgGasPrice = (*hexutil.Big)(avg)
+	}
+	if acc.intervals > 0 {
+		point.Interval = &BlockInterval{
+			Min:  hexutil.Uint64(acc.intervalMin),
+			Max:  hexutil.Uint64(acc.intervalMax),
+			Mean: float64(acc.intervalSum) / float64(acc.intervals),
+		}
+	}
+	return point
+}
+
+func gasUsedRatio(used, limit uint64) float64 {
+	if limit == 0 {
+		return 0
+	}
+	return float64(used) / float64(limit)
+}
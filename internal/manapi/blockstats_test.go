@@ -0,0 +1,101 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package manapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/params"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+func TestBlockStats(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+
+	b := newTestBackend(t, core.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}})
+	defer b.chain.Stop()
+	signer := types.HomesteadSigner{}
+	transfer := func(block *core.BlockGen, price int64) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(sender), common.Address{0xaa}, big.NewInt(1), params.TxGas, big.NewInt(price), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.AddTx(tx)
+	}
+	// Block 2 carries two transactions, block 4 one and arrives late
+	b.generate(t, 4, func(i int, block *core.BlockGen) {
+		switch i {
+		case 1:
+			transfer(block, 1)
+			transfer(block, 3)
+		case 3:
+			block.OffsetTime(20)
+			transfer(block, 2)
+		}
+	})
+	api := NewPublicBlockStatsAPI(b)
+
+	from := rpc.BlockNumber(1)
+	stats, err := api.BlockStats(context.Background(), BlockStatsArgs{FromBlock: &from, BucketSize: 2})
+	if err != nil {
+		t.Fatalf("failed to aggregate block stats: %v", err)
+	}
+	if len(stats.Points) != 2 {
+		t.Fatalf("point count mismatch: have %d, want 2", len(stats.Points))
+	}
+	first, second, summary := stats.Points[0], stats.Points[1], stats.Summary
+	if first.FromBlock != 1 || first.ToBlock != 2 || second.FromBlock != 3 || second.ToBlock != 4 {
+		t.Errorf("bucket ranges mismatch: have %d-%d and %d-%d", first.FromBlock, first.ToBlock, second.FromBlock, second.ToBlock)
+	}
+	if first.TxCount != 2 || second.TxCount != 1 || summary.TxCount != 3 {
+		t.Errorf("transaction counts mismatch: have %d, %d, %d", first.TxCount, second.TxCount, summary.TxCount)
+	}
+	if first.GasUsed != hexutil.Uint64(2*params.TxGas) || summary.GasUsed != hexutil.Uint64(3*params.TxGas) {
+		t.Errorf("gas used mismatch: have %d and %d", first.GasUsed, summary.GasUsed)
+	}
+	if first.AvgGasPrice.ToInt().Int64() != 2 || summary.AvgGasPrice.ToInt().Int64() != 2 {
+		t.Errorf("average gas price mismatch: have %v and %v", first.AvgGasPrice, summary.AvgGasPrice)
+	}
+	if first.GasUsedRatio <= 0 || first.MaxGasUsedRatio < first.GasUsedRatio {
+		t.Errorf("fullness mismatch: ratio %v, max %v", first.GasUsedRatio, first.MaxGasUsedRatio)
+	}
+	// Generated blocks are 10 seconds apart, the last one 30
+	if in := summary.Interval; in == nil || in.Min != 10 || in.Max != 30 || in.Mean != 15 {
+		t.Errorf("interval mismatch: have %+v", summary.Interval)
+	}
+	// The point count is bounded by maxPoints if no bucket size is given
+	stats, err = api.BlockStats(context.Background(), BlockStatsArgs{FromBlock: &from, MaxPoints: 3})
+	if err != nil {
+		t.Fatalf("failed to aggregate block stats: %v", err)
+	}
+	if stats.BucketSize != 2 || len(stats.Points) != 2 {
+		t.Errorf("downsampling mismatch: bucket %d, %d points", stats.BucketSize, len(stats.Points))
+	}
+	// Inverted ranges are refused
+	to := rpc.BlockNumber(0)
+	if _, err := api.BlockStats(context.Background(), BlockStatsArgs{FromBlock: &from, ToBlock: &to}); err == nil {
+		t.Error("inverted range accepted")
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package manapi
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+)
+
+// Kinds of requests tracked by the call statistics.
+const (
+	CallKindCall = "call" // man_call
+	CallKindSend = "send" // man_sendRawTransaction
+)
+
+// callSample is a single request recorded by the call statistics.
+type callSample struct {
+	time     time.Time
+	kind     string
+	to       common.Address
+	selector [4]byte
+	hasSel   bool // data was long enough to carry a method selector
+	gas      uint64
+}
+
+// CallStats keeps the targets of the latest man_call and man_sendRawTransaction
+// requests in a ring, so gateway operators can spot the contracts drawing the
+// most traffic and gas and put targeted rate limits in place. Contract creations
+// are not tracked, as they have no target.
+type CallStats struct {
+	ring []callSample
+	next int  // Position of the next sample in the ring
+	full bool // Whether the ring has wrapped around
+
+	lock sync.Mutex
+}
+
+// NewCallStats creates call statistics retaining the given number of requests.
+func NewCallStats(size int) *CallStats {
+	return &CallStats{ring: make([]callSample, size)}
+}
+
+// record adds a request to the ring, overwriting the oldest one if full.
+func (c *CallStats) record(kind string, to *common.Address, data []byte, gas uint64) {
+	if c == nil || to == nil || len(c.ring) == 0 {
+		return
+	}
+	sample := callSample{time: time.Now(), kind: kind, to: *to, gas: gas}
+	if len(data) >= 4 {
+		copy(sample.selector[:], data[:4])
+		sample.hasSel = true
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.ring[c.next] = sample
+	if c.next++; c.next == len(c.ring) {
+		c.next, c.full = 0, true
+	}
+}
+
+// CallStat aggregates the requests of a kind to a contract method.
+type CallStat struct {
+	Kind     string         `json:"kind"`
+	Contract common.Address `json:"contract"`
+	Selector hexutil.Bytes  `json:"selector"` // Empty for requests without a method selector
+	Count    hexutil.Uint64 `json:"count"`
+	AvgGas   hexutil.Uint64 `json:"avgGas"` // Gas used by calls, gas limit of transactions
+	Rate     float64        `json:"rate"`   // Requests per second over the covered period
+}
+
+// CallStatsResult is the result of debug_callStats.
+type CallStatsResult struct {
+	Since   hexutil.Uint64 `json:"since"`   // Unix time of the oldest request aggregated, zero if none
+	Samples int            `json:"samples"` // Number of requests aggregated
+	Stats   []*CallStat    `json:"stats"`   // Sorted by count, busiest first
+}
+
+// callStatKey identifies the requests aggregated by a CallStat.
+type callStatKey struct {
+	kind     string
+	to       common.Address
+	selector [4]byte
+	hasSel   bool
+}
+
+// stats aggregates the recorded requests not older than window (all of them if
+// zero), returning at most limit entries (all of them if zero).
+func (c *CallStats) stats(window time.Duration, limit int) *CallStatsResult {
+	c.lock.Lock()
+	samples := c.ring[:c.next]
+	if c.full {
+		samples = append(append([]callSample{}, c.ring[c.next:]...), c.ring[:c.next]...)
+	} else {
+		samples = append([]callSample{}, samples...)
+	}
+	c.lock.Unlock()
+
+	now := time.Now()
+	if window > 0 {
+		// Samples are in insertion order, drop the ones outside the window
+		cut := sort.Search(len(samples), func(i int) bool { return now.Sub(samples[i].time) <= window })
+		samples = samples[cut:]
+	}
+	result := &CallStatsResult{Samples: len(samples), Stats: []*CallStat{}}
+	if len(samples) == 0 {
+		return result
+	}
+	result.Since = hexutil.Uint64(samples[0].time.Unix())
+
+	var (
+		aggs = make(map[callStatKey]*CallStat)
+		gas  = make(map[callStatKey]uint64)
+	)
+	for _, sample := range samples {
+		key := callStatKey{sample.kind, sample.to, sample.selector, sample.hasSel}
+		stat := aggs[key]
+		if stat == nil {
+			stat = &CallStat{Kind: sample.kind, Contract: sample.to}
+			if sample.hasSel {
+				stat.Selector = common.CopyBytes(sample.selector[:])
+			}
+			aggs[key] = stat
+			result.Stats = append(result.Stats, stat)
+		}
+		stat.Count++
+		gas[key] += sample.gas
+	}
+	period := now.Sub(samples[0].time).Seconds()
+	if window > 0 {
+		period = window.Seconds()
+	}
+	for key, stat := range aggs {
+		stat.AvgGas = hexutil.Uint64(gas[key] / uint64(stat.Count))
+		if period > 0 {
+			stat.Rate = float64(stat.Count) / period
+		}
+	}
+	sort.SliceStable(result.Stats, func(i, j int) bool { return result.Stats[i].Count > result.Stats[j].Count })
+	if limit > 0 && len(result.Stats) > limit {
+		result.Stats = result.Stats[:limit]
+	}
+	return result
+}
+
+// CallStats returns the contracts and methods targeted by the latest man_call
+// and man_sendRawTransaction requests, with their request counts, average gas
+// and rates. Only the requests of the last window seconds are aggregated if a
+// window is given, and at most limit of the busiest entries are returned.
+func (api *PrivateDebugAPI) CallStats(window *uint64, limit *int) (*CallStatsResult, error) {
+	stats := api.b.CallStats()
+	if stats == nil {
+		return nil, errors.New("call statistics not available")
+	}
+	var (
+		d time.Duration
+		n int
+	)
+	if window != nil {
+		d = time.Duration(*window) * time.Second
+	}
+	if limit != nil {
+		n = *limit
+	}
+	return stats.stats(d, n), nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package manapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+)
+
+// Tests that requests are aggregated per kind, contract and method selector,
+// busiest first, and that contract creations are not tracked.
+func TestCallStatsAggregation(t *testing.T) {
+	var (
+		a = common.HexToAddress("0xa")
+		b = common.HexToAddress("0xb")
+	)
+	stats := NewCallStats(16)
+	stats.record(CallKindCall, &a, []byte{1, 2, 3, 4, 5}, 100)
+	stats.record(CallKindCall, &a, []byte{1, 2, 3, 4}, 300)
+	stats.record(CallKindCall, &a, []byte{1, 2, 3, 4, 9}, 200)
+	stats.record(CallKindSend, &a, []byte{1, 2, 3, 4}, 21000)
+	stats.record(CallKindCall, &b, []byte{1, 2}, 50)
+	stats.record(CallKindCall, &b, nil, 150)
+	stats.record(CallKindSend, nil, []byte{1, 2, 3, 4}, 1000000)
+
+	res := stats.stats(0, 0)
+	if res.Samples != 6 {
+		t.Fatalf("sample count mismatch: have %d, want 6", res.Samples)
+	}
+	if len(res.Stats) != 3 {
+		t.Fatalf("stat count mismatch: have %d, want 3", len(res.Stats))
+	}
+	want := []struct {
+		kind     string
+		contract common.Address
+		selector string
+		count    uint64
+		avgGas   uint64
+	}{
+		{CallKindCall, a, "0x01020304", 3, 200},
+		{CallKindCall, b, "0x", 2, 100},
+		{CallKindSend, a, "0x01020304", 1, 21000},
+	}
+	for i, w := range want {
+		stat := res.Stats[i]
+		if stat.Kind != w.kind || stat.Contract != w.contract || stat.Selector.String() != w.selector {
+			t.Errorf("stat %d: target mismatch: have %s %x %s, want %s %x %s", i, stat.Kind, stat.Contract, stat.Selector, w.kind, w.contract, w.selector)
+		}
+		if uint64(stat.Count) != w.count || uint64(stat.AvgGas) != w.avgGas {
+			t.Errorf("stat %d: count/gas mismatch: have %d/%d, want %d/%d", i, stat.Count, stat.AvgGas, w.count, w.avgGas)
+		}
+	}
+	if res := stats.stats(0, 2); len(res.Stats) != 2 || res.Stats[0].Contract != a || res.Stats[1].Contract != b {
+		t.Errorf("limited stats mismatch: have %d entries", len(res.Stats))
+	}
+}
+
+// Tests that the ring retains only the latest requests once it wraps around.
+func TestCallStatsRing(t *testing.T) {
+	stats := NewCallStats(3)
+	to := common.HexToAddress("0xa")
+	for i := 1; i <= 5; i++ {
+		stats.record(CallKindCall, &to, nil, uint64(i))
+	}
+	res := stats.stats(0, 0)
+	if res.Samples != 3 {
+		t.Fatalf("sample count mismatch: have %d, want 3", res.Samples)
+	}
+	// The oldest two requests (gas 1 and 2) are overwritten
+	if gas := res.Stats[0].AvgGas; gas != 4 {
+		t.Errorf("average gas mismatch: have %d, want 4", gas)
+	}
+	// Disabled statistics record nothing
+	var disabled *CallStats
+	disabled.record(CallKindCall, &common.Address{}, nil, 1)
+
+	empty := NewCallStats(0)
+	empty.record(CallKindCall, &common.Address{}, nil, 1)
+	if res := empty.stats(0, 0); res.Samples != 0 {
+		t.Errorf("empty ring recorded %d requests", res.Samples)
+	}
+}
+
+// Tests that only the requests within the window are aggregated, with the rate
+// computed over the window.
+func TestCallStatsWindow(t *testing.T) {
+	to := common.HexToAddress("0xa")
+
+	stats := NewCallStats(8)
+	for i := 0; i < 4; i++ {
+		stats.record(CallKindCall, &to, nil, 1)
+	}
+	// Age the first two requests out of a ten second window
+	now := time.Now()
+	stats.ring[0].time = now.Add(-time.Minute)
+	stats.ring[1].time = now.Add(-30 * time.Second)
+	stats.ring[2].time = now.Add(-5 * time.Second)
+
+	res := stats.stats(10*time.Second, 0)
+	if res.Samples != 2 {
+		t.Fatalf("sample count mismatch: have %d, want 2", res.Samples)
+	}
+	if res.Since != hexutil.Uint64(stats.ring[2].time.Unix()) {
+		t.Errorf("since mismatch: have %d, want %d", res.Since, stats.ring[2].time.Unix())
+	}
+	if rate := res.Stats[0].Rate; rate != 0.2 {
+		t.Errorf("rate mismatch: have %v, want 0.2", rate)
+	}
+	if res := stats.stats(0, 0); res.Samples != 4 || res.Since != hexutil.Uint64(stats.ring[0].time.Unix()) {
+		t.Errorf("unwindowed stats mismatch: have %d samples since %d", res.Samples, res.Since)
+	}
+}
+
+// Tests that the RPC endpoint reports missing statistics and applies its
+// optional window and limit.
+func TestCallStatsAPI(t *testing.T) {
+	b := newTestBackend(t, nil)
+	defer b.chain.Stop()
+	if _, err := NewPrivateDebugAPI(b).CallStats(nil, nil); err == nil {
+		t.Error("missing statistics reported")
+	}
+	stats := NewCallStats(8)
+	for i := byte(1); i <= 3; i++ {
+		to := common.BytesToAddress([]byte{i})
+		stats.record(CallKindCall, &to, nil, 1)
+	}
+	api := NewPrivateDebugAPI(&callStatsBackend{b, stats})
+
+	limit := 2
+	res, err := api.CallStats(nil, &limit)
+	if err != nil {
+		t.Fatalf("failed to retrieve call stats: %v", err)
+	}
+	if res.Samples != 3 || len(res.Stats) != 2 {
+		t.Errorf("stats mismatch: have %d samples in %d entries, want 3 in 2", res.Samples, len(res.Stats))
+	}
+	stats.ring[0].time = time.Now().Add(-time.Hour)
+
+	window := uint64(60)
+	if res, _ = api.CallStats(&window, nil); res.Samples != 2 {
+		t.Errorf("windowed sample count mismatch: have %d, want 2", res.Samples)
+	}
+}
+
+// callStatsBackend serves call statistics on top of the test backend.
+type callStatsBackend struct {
+	*testBackend
+	stats *CallStats
+}
+
+func (b *callStatsBackend) CallStats() *CallStats { return b.stats }
@@ -0,0 +1,76 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package manapi
+
+import (
+	"fmt"
+
+	"github.com/matrix/go-matrix/params"
+)
+
+// ChainInfo is the description of the chain the node is running on. The
+// network and chain IDs always equal the ones reported by net_version and
+// eth_chainId, even where the chain registry disagrees.
+type ChainInfo struct {
+	params.ChainInfo
+	Known    bool     `json:"known"`              // Whether the network is in the chain registry
+	Warnings []string `json:"warnings,omitempty"` // Inconsistencies between the node and the registry
+}
+
+// PublicChainInfoAPI lets wallets look up the network a node is connected to
+// in the chain registry, so they can configure themselves automatically.
+type PublicChainInfoAPI struct {
+	b Backend
+}
+
+// NewPublicChainInfoAPI creates a new chain registry API.
+func NewPublicChainInfoAPI(b Backend) *PublicChainInfoAPI {
+	return &PublicChainInfoAPI{b}
+}
+
+// ChainInfo returns the name, currency and explorer of the network the node is
+// running on, along with its network and chain IDs.
+func (s *PublicChainInfoAPI) ChainInfo() *ChainInfo {
+	var (
+		networkId = s.b.NetworkId()
+		config    = s.b.ChainConfig()
+	)
+	info, known := params.ChainByNetworkId(networkId)
+	if !known {
+		info = params.ChainInfo{
+			Name:      fmt.Sprintf("Unknown MATRIX network %d", networkId),
+			NetworkId: networkId,
+			Currency:  params.MAN,
+		}
+	}
+	result := &ChainInfo{ChainInfo: info, Known: known}
+
+	chainId := config.ChainId.Uint64()
+	if known && info.ChainId != chainId {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("registry chain ID %d for network %d differs from configured chain ID %d", info.ChainId, networkId, chainId))
+	}
+	result.ChainId = chainId
+
+	if head := s.b.CurrentBlock().Number(); !config.IsEIP155(head) {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("replay protection inactive until block %v, chain ID not yet reported by eth_chainId", config.EIP155Block))
+	}
+	return result
+}
+
+// Chains returns all networks in the chain registry.
+func (s *PublicChainInfoAPI) Chains() []params.ChainInfo {
+	return params.Chains()
+}
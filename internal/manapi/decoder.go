@@ -0,0 +1,394 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package manapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/accounts/abi"
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core/rawdb"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/core/vm"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/mandb"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+// abiRegistryDefinition is the interface of the optional on-chain registry
+// consulted when no ABI was registered locally for a contract.
+const abiRegistryDefinition = `[{"constant":true,"inputs":[{"name":"contract","type":"address"}],"name":"abiOf","outputs":[{"name":"","type":"string"}],"type":"function"}]`
+
+var (
+	errNoABI          = errors.New("no ABI known for contract")
+	errShortCalldata  = errors.New("calldata shorter than a method selector")
+	errUnknownMethod  = errors.New("method selector not found in ABI")
+	errUnknownEvent   = errors.New("event signature not found in ABI")
+	errAnonymousEvent = errors.New("log has no topics, anonymous events cannot be decoded")
+)
+
+// abiRegistry keeps the contract ABIs known to the node, keyed by address.
+// ABIs registered through the management API are persisted in the node
+// database, while the ones fetched from the on-chain registry are cached apart
+// in memory, until the registry is changed.
+type abiRegistry struct {
+	b           Backend
+	db          mandb.Database             // database persisting registrations, nil if kept in memory
+	abis        map[common.Address]abi.ABI // ABIs registered through the management API
+	definitions map[common.Address]string  // JSON definitions of the registered ABIs
+	fetched     map[common.Address]abi.ABI // ABIs cached from the registry contract
+	contract    *common.Address            // optional on-chain registry contract
+	lock        sync.RWMutex
+}
+
+// newABIRegistry creates an ABI registry, loading the ABIs registered in
+// earlier runs from the backend's database.
+func newABIRegistry(b Backend) *abiRegistry {
+	r := &abiRegistry{
+		b:           b,
+		abis:        make(map[common.Address]abi.ABI),
+		definitions: make(map[common.Address]string),
+		fetched:     make(map[common.Address]abi.ABI),
+	}
+	if b == nil {
+		return r
+	}
+	r.db = b.ChainDb()
+	for addr, definition := range rawdb.ReadABIDefinitions(r.db) {
+		def, err := abi.JSON(strings.NewReader(definition))
+		if err != nil {
+			log.Warn("Dropping invalid stored contract ABI", "contract", addr, "err", err)
+			continue
+		}
+		r.abis[addr], r.definitions[addr] = def, definition
+	}
+	return r
+}
+
+// register associates an ABI with a contract and persists the registrations.
+func (r *abiRegistry) register(addr common.Address, def abi.ABI, definition string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.abis[addr], r.definitions[addr] = def, definition
+	r.store()
+}
+
+// unregister forgets the ABI of a contract, returning whether one was registered.
+func (r *abiRegistry) unregister(addr common.Address) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	_, ok := r.abis[addr]
+	delete(r.abis, addr)
+	delete(r.definitions, addr)
+	delete(r.fetched, addr)
+	if ok {
+		r.store()
+	}
+	return ok
+}
+
+// store persists the registered ABIs. The lock must be held.
+func (r *abiRegistry) store() {
+	if r.db != nil {
+		rawdb.WriteABIDefinitions(r.db, r.definitions)
+	}
+}
+
+// lookup returns the ABI of the given contract, falling back to the on-chain
+// registry contract if one is configured.
+func (r *abiRegistry) lookup(ctx context.Context, addr common.Address) (abi.ABI, error) {
+	r.lock.RLock()
+	def, ok := r.abis[addr]
+	if !ok {
+		def, ok = r.fetched[addr]
+	}
+	contract := r.contract
+	r.lock.RUnlock()
+
+	if ok {
+		return def, nil
+	}
+	if contract == nil {
+		return abi.ABI{}, errNoABI
+	}
+	def, err := r.fetch(ctx, *contract, addr)
+	if err != nil {
+		return abi.ABI{}, err
+	}
+	// Only cache the ABI if the registry wasn't swapped during the fetch
+	r.lock.Lock()
+	if r.contract != nil && *r.contract == *contract {
+		r.fetched[addr] = def
+	}
+	r.lock.Unlock()
+	return def, nil
+}
+
+// fetch retrieves the ABI of addr from the registry contract at the latest block.
+func (r *abiRegistry) fetch(ctx context.Context, contract, addr common.Address) (abi.ABI, error) {
+	registry, err := abi.JSON(strings.NewReader(abiRegistryDefinition))
+	if err != nil {
+		return abi.ABI{}, err
+	}
+	input, err := registry.Pack("abiOf", addr)
+	if err != nil {
+		return abi.ABI{}, err
+	}
+	chain := &PublicBlockChainAPI{b: r.b}
+	output, _, failed, err := chain.doCall(ctx, CallArgs{To: &contract, Data: input}, rpc.LatestBlockNumber, vm.Config{}, 5*time.Second)
+	if err != nil {
+		return abi.ABI{}, err
+	}
+	if failed || len(output) == 0 {
+		return abi.ABI{}, errNoABI
+	}
+	var definition string
+	if err := registry.Unpack(&definition, "abiOf", output); err != nil {
+		return abi.ABI{}, err
+	}
+	if definition == "" {
+		return abi.ABI{}, errNoABI
+	}
+	log.Debug("Fetched contract ABI from registry", "contract", addr, "registry", contract)
+	return abi.JSON(strings.NewReader(definition))
+}
+
+// DecodedArgument is a single decoded ABI value.
+type DecodedArgument struct {
+	Name    string      `json:"name"`
+	Type    string      `json:"type"`
+	Indexed bool        `json:"indexed,omitempty"`
+	Value   interface{} `json:"value"`
+}
+
+// DecodedCall is the result of decoding a method invocation.
+type DecodedCall struct {
+	Name      string            `json:"name"`
+	Signature string            `json:"signature"`
+	Selector  hexutil.Bytes     `json:"selector"`
+	Arguments []DecodedArgument `json:"arguments"`
+}
+
+// DecodedLog is the result of decoding an event log.
+type DecodedLog struct {
+	Name      string            `json:"name"`
+	Signature string            `json:"signature"`
+	Topic     common.Hash       `json:"topic"`
+	Arguments []DecodedArgument `json:"arguments"`
+}
+
+// PublicDecoderAPI decodes calldata and logs against the ABIs known to the node.
+type PublicDecoderAPI struct {
+	registry *abiRegistry
+}
+
+// newPublicDecoderAPI creates a new ABI decoding API.
+func newPublicDecoderAPI(registry *abiRegistry) *PublicDecoderAPI {
+	return &PublicDecoderAPI{registry}
+}
+
+// DecodeCalldata decodes the input of a call to the contract at address to.
+func (api *PublicDecoderAPI) DecodeCalldata(ctx context.Context, to common.Address, data hexutil.Bytes) (*DecodedCall, error) {
+	if len(data) < 4 {
+		return nil, errShortCalldata
+	}
+	def, err := api.registry.lookup(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	method, err := def.MethodById(data[:4])
+	if err != nil {
+		return nil, errUnknownMethod
+	}
+	values, err := method.Inputs.UnpackValues(data[4:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack arguments of %s: %v", method.Name, err)
+	}
+	args := make([]DecodedArgument, len(method.Inputs))
+	for i, input := range method.Inputs {
+		args[i] = DecodedArgument{Name: input.Name, Type: input.Type.String(), Value: formatABIValue(values[i])}
+	}
+	return &DecodedCall{
+		Name:      method.Name,
+		Signature: method.Sig(),
+		Selector:  method.Id(),
+		Arguments: args,
+	}, nil
+}
+
+// DecodeLog decodes an event log emitted by the contract at log.Address.
+func (api *PublicDecoderAPI) DecodeLog(ctx context.Context, entry types.Log) (*DecodedLog, error) {
+	if len(entry.Topics) == 0 {
+		return nil, errAnonymousEvent
+	}
+	def, err := api.registry.lookup(ctx, entry.Address)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		event *abi.Event
+		found bool
+	)
+	for _, e := range def.Events {
+		if !e.Anonymous && e.Id() == entry.Topics[0] {
+			e := e
+			event, found = &e, true
+			break
+		}
+	}
+	if !found {
+		return nil, errUnknownEvent
+	}
+	values, err := event.Inputs.UnpackValues(entry.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack data of %s: %v", event.Name, err)
+	}
+	var (
+		args    = make([]DecodedArgument, len(event.Inputs))
+		topics  = entry.Topics[1:]
+		nonIdx  int
+		topicIx int
+	)
+	for i, input := range event.Inputs {
+		arg := DecodedArgument{Name: input.Name, Type: input.Type.String(), Indexed: input.Indexed}
+		if !input.Indexed {
+			arg.Value = formatABIValue(values[nonIdx])
+			nonIdx++
+		} else {
+			if topicIx >= len(topics) {
+				return nil, fmt.Errorf("missing topic for indexed argument %q", input.Name)
+			}
+			arg.Value = decodeTopic(input, topics[topicIx])
+			topicIx++
+		}
+		args[i] = arg
+	}
+	return &DecodedLog{
+		Name:      event.Name,
+		Signature: event.Sig(),
+		Topic:     entry.Topics[0],
+		Arguments: args,
+	}, nil
+}
+
+// decodeTopic decodes an indexed event argument. Dynamic types are stored as
+// their keccak256 hash in the topic, so only the hash can be returned for them.
+func decodeTopic(input abi.Argument, topic common.Hash) interface{} {
+	switch input.Type.T {
+	case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy:
+		return topic
+	}
+	input.Indexed = false
+	values, err := abi.Arguments{input}.UnpackValues(topic[:])
+	if err != nil || len(values) != 1 {
+		return topic
+	}
+	return formatABIValue(values[0])
+}
+
+// formatABIValue converts an unpacked ABI value into its JSON-RPC friendly form.
+func formatABIValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *big.Int:
+		return (*hexutil.Big)(v)
+	case []byte:
+		return hexutil.Bytes(v)
+	case common.Address, common.Hash, bool, string:
+		return v
+	case uint8, uint16, uint32, uint64, int8, int16, int32, int64:
+		return v
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			out := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(out), rv)
+			return hexutil.Bytes(out)
+		}
+		fallthrough
+	case reflect.Slice:
+		out := make([]interface{}, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			out[i] = formatABIValue(rv.Index(i).Interface())
+		}
+		return out
+	}
+	return value
+}
+
+// PrivateDecoderAPI manages the ABIs used by the decoding API.
+type PrivateDecoderAPI struct {
+	registry *abiRegistry
+}
+
+// newPrivateDecoderAPI creates a new ABI management API.
+func newPrivateDecoderAPI(registry *abiRegistry) *PrivateDecoderAPI {
+	return &PrivateDecoderAPI{registry}
+}
+
+// RegisterABI parses the given JSON ABI and associates it with the contract.
+func (api *PrivateDecoderAPI) RegisterABI(addr common.Address, definition string) error {
+	def, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		return fmt.Errorf("invalid ABI: %v", err)
+	}
+	api.registry.register(addr, def, definition)
+	return nil
+}
+
+// UnregisterABI forgets the ABI of the contract, returning whether one was known.
+func (api *PrivateDecoderAPI) UnregisterABI(addr common.Address) bool {
+	return api.registry.unregister(addr)
+}
+
+// ListABIs returns the addresses of all contracts with a known ABI.
+func (api *PrivateDecoderAPI) ListABIs() []common.Address {
+	api.registry.lock.RLock()
+	defer api.registry.lock.RUnlock()
+
+	addrs := make([]common.Address, 0, len(api.registry.abis)+len(api.registry.fetched))
+	for addr := range api.registry.abis {
+		addrs = append(addrs, addr)
+	}
+	for addr := range api.registry.fetched {
+		if _, ok := api.registry.abis[addr]; !ok {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// SetABIRegistry configures the on-chain registry contract consulted for
+// contracts without a locally registered ABI. A nil address disables it. The
+// ABIs fetched from the previous registry are dropped.
+func (api *PrivateDecoderAPI) SetABIRegistry(contract *common.Address) {
+	api.registry.lock.Lock()
+	api.registry.contract = contract
+	api.registry.fetched = make(map[common.Address]abi.ABI)
+	api.registry.lock.Unlock()
+}
@@ -0,0 +1,206 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package manapi
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/matrix/go-matrix/accounts/abi"
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/mandb"
+)
+
+const testTokenABI = `[
+	{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]}
+]`
+
+func newTestDecoder(t *testing.T) (*PublicDecoderAPI, *PrivateDecoderAPI, common.Address, abi.ABI) {
+	registry := newABIRegistry(nil)
+	public, private := newPublicDecoderAPI(registry), newPrivateDecoderAPI(registry)
+
+	token := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	if err := private.RegisterABI(token, testTokenABI); err != nil {
+		t.Fatalf("failed to register ABI: %v", err)
+	}
+	def, err := abi.JSON(strings.NewReader(testTokenABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return public, private, token, def
+}
+
+func TestDecodeCalldata(t *testing.T) {
+	public, _, token, def := newTestDecoder(t)
+
+	to := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	input, err := def.Pack("transfer", to, big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	call, err := public.DecodeCalldata(context.Background(), token, input)
+	if err != nil {
+		t.Fatalf("failed to decode calldata: %v", err)
+	}
+	if call.Name != "transfer" || call.Signature != "transfer(address,uint256)" {
+		t.Errorf("decoded method mismatch: have %s %s", call.Name, call.Signature)
+	}
+	if len(call.Arguments) != 2 || call.Arguments[0].Value != to || (*big.Int)(call.Arguments[1].Value.(*hexutil.Big)).Int64() != 1000 {
+		t.Errorf("decoded arguments mismatch: have %+v", call.Arguments)
+	}
+	if _, err := public.DecodeCalldata(context.Background(), token, hexutil.Bytes{0xde, 0xad, 0xbe, 0xef}); err != errUnknownMethod {
+		t.Errorf("unknown selector: have %v, want %v", err, errUnknownMethod)
+	}
+	if _, err := public.DecodeCalldata(context.Background(), token, hexutil.Bytes{0x01}); err != errShortCalldata {
+		t.Errorf("short calldata: have %v, want %v", err, errShortCalldata)
+	}
+	if _, err := public.DecodeCalldata(context.Background(), common.Address{}, input); err != errNoABI {
+		t.Errorf("unknown contract: have %v, want %v", err, errNoABI)
+	}
+}
+
+func TestDecodeLog(t *testing.T) {
+	public, _, token, def := newTestDecoder(t)
+
+	var (
+		from  = common.HexToAddress("0x3000000000000000000000000000000000000003")
+		to    = common.HexToAddress("0x4000000000000000000000000000000000000004")
+		event = def.Events["Transfer"]
+	)
+	entry := types.Log{
+		Address: token,
+		Topics:  []common.Hash{event.Id(), from.Hash(), to.Hash()},
+		Data:    common.LeftPadBytes(big.NewInt(42).Bytes(), 32),
+	}
+	decoded, err := public.DecodeLog(context.Background(), entry)
+	if err != nil {
+		t.Fatalf("failed to decode known event: %v", err)
+	}
+	if decoded.Name != "Transfer" || decoded.Signature != "Transfer(address,address,uint256)" || decoded.Topic != event.Id() {
+		t.Errorf("decoded event mismatch: have %s %s %x", decoded.Name, decoded.Signature, decoded.Topic)
+	}
+	if len(decoded.Arguments) != 3 {
+		t.Fatalf("decoded argument count mismatch: have %d, want 3", len(decoded.Arguments))
+	}
+	if arg := decoded.Arguments[0]; !arg.Indexed || arg.Value != from {
+		t.Errorf("from argument mismatch: have %+v", arg)
+	}
+	if arg := decoded.Arguments[1]; !arg.Indexed || arg.Value != to {
+		t.Errorf("to argument mismatch: have %+v", arg)
+	}
+	if arg := decoded.Arguments[2]; arg.Indexed || (*big.Int)(arg.Value.(*hexutil.Big)).Int64() != 42 {
+		t.Errorf("value argument mismatch: have %+v", arg)
+	}
+	// Unknown event signatures and anonymous logs are rejected
+	unknown := entry
+	unknown.Topics = []common.Hash{common.HexToHash("0xdeadbeef"), from.Hash(), to.Hash()}
+	if _, err := public.DecodeLog(context.Background(), unknown); err != errUnknownEvent {
+		t.Errorf("unknown event: have %v, want %v", err, errUnknownEvent)
+	}
+	anonymous := entry
+	anonymous.Topics = nil
+	if _, err := public.DecodeLog(context.Background(), anonymous); err != errAnonymousEvent {
+		t.Errorf("anonymous event: have %v, want %v", err, errAnonymousEvent)
+	}
+}
+
+func TestSetABIRegistryDropsFetched(t *testing.T) {
+	public, private, token, def := newTestDecoder(t)
+
+	// Simulate an ABI fetched from a registry contract
+	fetched := common.HexToAddress("0x5000000000000000000000000000000000000005")
+	registry := common.HexToAddress("0x6000000000000000000000000000000000000006")
+	private.SetABIRegistry(&registry)
+	private.registry.fetched[fetched] = def
+
+	input, err := def.Pack("transfer", common.Address{}, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := public.DecodeCalldata(context.Background(), fetched, input); err != nil {
+		t.Fatalf("cached registry ABI not used: %v", err)
+	}
+	if have := len(private.ListABIs()); have != 2 {
+		t.Errorf("listed ABI count mismatch: have %d, want 2", have)
+	}
+	// Switching the registry forgets its ABIs but keeps the registered ones
+	private.SetABIRegistry(nil)
+	if _, err := public.DecodeCalldata(context.Background(), fetched, input); err != errNoABI {
+		t.Errorf("fetched ABI survived registry change: have %v, want %v", err, errNoABI)
+	}
+	if _, err := public.DecodeCalldata(context.Background(), token, input); err != nil {
+		t.Errorf("registered ABI dropped with the registry: %v", err)
+	}
+}
+
+func TestABIRegistryPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "abi-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := mandb.NewLDBDatabase(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	var (
+		token   = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		dropped = common.HexToAddress("0x7000000000000000000000000000000000000007")
+	)
+	private := newPrivateDecoderAPI(newABIRegistry(&testBackend{db: db}))
+	if err := private.RegisterABI(token, testTokenABI); err != nil {
+		t.Fatalf("failed to register ABI: %v", err)
+	}
+	if err := private.RegisterABI(dropped, testTokenABI); err != nil {
+		t.Fatalf("failed to register ABI: %v", err)
+	}
+	if !private.UnregisterABI(dropped) {
+		t.Fatalf("registered ABI not found")
+	}
+	db.Close()
+
+	// Reopen the database and check the registrations survived the restart
+	if db, err = mandb.NewLDBDatabase(dir, 0, 0); err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	defer db.Close()
+
+	registry := newABIRegistry(&testBackend{db: db})
+	public, private := newPublicDecoderAPI(registry), newPrivateDecoderAPI(registry)
+	if addrs := private.ListABIs(); len(addrs) != 1 || addrs[0] != token {
+		t.Fatalf("reopened registry mismatch: have %v, want [%x]", addrs, token)
+	}
+	def, _ := abi.JSON(strings.NewReader(testTokenABI))
+	input, err := def.Pack("transfer", common.Address{}, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := public.DecodeCalldata(context.Background(), token, input); err != nil {
+		t.Errorf("reopened ABI not used: %v", err)
+	}
+	if _, err := public.DecodeCalldata(context.Background(), dropped, input); err != errNoABI {
+		t.Errorf("unregistered ABI survived the restart: have %v, want %v", err, errNoABI)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package manapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core/state"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/params"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+// maxContractAddressBatch is the maximum number of deployments resolved by a
+// single computeContractAddresses call.
+const maxContractAddressBatch = 1024
+
+// errCreate2Unsupported is returned for salted deployments, which need the
+// CREATE2 opcode (EIP-1014) this chain's EVM doesn't implement.
+var errCreate2Unsupported = errors.New("salted deployments are not supported, the EVM has no CREATE2")
+
+// ContractAddressArgs describes a contract deployment whose address should be
+// computed: a CREATE by deployer at the given nonce, defaulting to the
+// deployer's next nonce. Salt is only accepted to report that CREATE2
+// deployments are impossible on this chain.
+type ContractAddressArgs struct {
+	Deployer common.Address  `json:"deployer"`
+	Nonce    *hexutil.Uint64 `json:"nonce"`
+	Salt     *common.Hash    `json:"salt"`
+}
+
+// ComputeContractAddress returns the address a contract deployment described
+// by args ends up at, following the address derivation of the node's EVM.
+func (s *PublicBlockChainAPI) ComputeContractAddress(ctx context.Context, args ContractAddressArgs) (common.Address, error) {
+	addrs, err := s.ComputeContractAddresses(ctx, []ContractAddressArgs{args})
+	if err != nil {
+		return common.Address{}, err
+	}
+	return addrs[0], nil
+}
+
+// ComputeContractAddresses is the batch version of ComputeContractAddress,
+// resolving default nonces against a single state.
+func (s *PublicBlockChainAPI) ComputeContractAddresses(ctx context.Context, args []ContractAddressArgs) ([]common.Address, error) {
+	if len(args) > maxContractAddressBatch {
+		return nil, fmt.Errorf("too many deployments: %d > %d", len(args), maxContractAddressBatch)
+	}
+	var statedb *state.StateDB
+
+	addrs := make([]common.Address, len(args))
+	for i, arg := range args {
+		if arg.Salt != nil {
+			return nil, fmt.Errorf("deployment %d: %v", i, errCreate2Unsupported)
+		}
+		// Deployments derive from the account nonce, which the state keeps
+		// offset by NonceAddOne. Accept both forms like the pool does.
+		var nonce uint64
+		if arg.Nonce != nil {
+			if nonce = uint64(*arg.Nonce); nonce < params.NonceAddOne {
+				nonce |= params.NonceAddOne
+			}
+		} else {
+			if statedb == nil {
+				var err error
+				if statedb, _, err = s.b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber); statedb == nil || err != nil {
+					return nil, err
+				}
+			}
+			nonce = statedb.GetNonce(arg.Deployer)
+		}
+		addrs[i] = crypto.CreateAddress(arg.Deployer, nonce)
+	}
+	return addrs, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package manapi
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/params"
+)
+
+func TestComputeContractAddresses(t *testing.T) {
+	var (
+		deployer = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		fresh    = common.HexToAddress("0x2000000000000000000000000000000000000002")
+		nonce    = params.NonceAddOne + 5
+	)
+	b := newTestBackend(t, core.GenesisAlloc{
+		deployer: {Balance: big.NewInt(1), Nonce: nonce},
+	})
+	defer b.chain.Stop()
+	api := NewPublicBlockChainAPI(b)
+	explicit := hexutil.Uint64(3)
+	offset := hexutil.Uint64(params.NonceAddOne + 3)
+
+	addrs, err := api.ComputeContractAddresses(context.Background(), []ContractAddressArgs{
+		{Deployer: deployer},
+		{Deployer: fresh},
+		{Deployer: deployer, Nonce: &explicit},
+		{Deployer: deployer, Nonce: &offset},
+	})
+	if err != nil {
+		t.Fatalf("failed to compute addresses: %v", err)
+	}
+	want := []common.Address{
+		crypto.CreateAddress(deployer, nonce),
+		crypto.CreateAddress(fresh, params.NonceAddOne),
+		crypto.CreateAddress(deployer, params.NonceAddOne+3),
+		crypto.CreateAddress(deployer, params.NonceAddOne+3),
+	}
+	for i := range want {
+		if addrs[i] != want[i] {
+			t.Errorf("deployment %d: address mismatch: have %x, want %x", i, addrs[i], want[i])
+		}
+	}
+}
+
+// Tests that salted deployments are refused, as the EVM can't execute CREATE2.
+func TestComputeContractAddressSalt(t *testing.T) {
+	b := newTestBackend(t, nil)
+	defer b.chain.Stop()
+	api := NewPublicBlockChainAPI(b)
+
+	salt := common.Hash{1}
+	_, err := api.ComputeContractAddress(context.Background(), ContractAddressArgs{Salt: &salt})
+	if err == nil || !strings.Contains(err.Error(), errCreate2Unsupported.Error()) {
+		t.Fatalf("error mismatch: have %v, want %v", err, errCreate2Unsupported)
+	}
+}
+
+func TestComputeContractAddressesLimit(t *testing.T) {
+	b := newTestBackend(t, nil)
+	defer b.chain.Stop()
+	api := NewPublicBlockChainAPI(b)
+
+	if _, err := api.ComputeContractAddresses(context.Background(), make([]ContractAddressArgs, maxContractAddressBatch+1)); err == nil {
+		t.Fatalf("oversized batch accepted")
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package manapi
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/matrix/go-matrix/accounts"
+	"github.com/matrix/go-matrix/accounts/keystore"
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/core/state"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+// Error codes returned by the API on top of the JSON-RPC codes reserved by the
+// rpc package. Clients branch on them, so they must never be renumbered.
+const (
+	ErrCodeDefault = -32000 // unclassified failure
+
+	// Transaction submission
+	ErrCodeNonceTooLow        = -32010
+	ErrCodeNonceTooHigh       = -32011
+	ErrCodeInsufficientFunds  = -32012
+	ErrCodeUnderpriced        = -32013
+	ErrCodeReplaceUnderpriced = -32014
+	ErrCodeIntrinsicGas       = -32015
+	ErrCodeGasLimit           = -32016
+	ErrCodeOversizedData      = -32017
+	ErrCodeNegativeValue      = -32018
+	ErrCodeKnownTransaction   = -32019
+	ErrCodeUnprotectedTx      = -32020
+	ErrCodeInvalidSender      = -32021
+	ErrCodeFeeCapTooLow       = -32022
+	ErrCodeSpamDeprioritized  = -32023
+	ErrCodeInvalidNonce       = -32024
+
+	// Accounts
+	ErrCodeUnknownAccount  = -32030
+	ErrCodeAccountLocked   = -32031
+	ErrCodeInvalidPassword = -32032
+
+	// Execution
+	ErrCodeExecutionFailed = -32040
+)
+
+var (
+	errInvalidNonce    = errors.New("Nonce Wrongful")
+	errGasEstimateFail = errors.New("gas required exceeds allowance or always failing transaction")
+)
+
+// errorKind is the registry entry of a known error.
+type errorKind struct {
+	code   int
+	reason string // stable machine-readable identifier sent in the error data
+}
+
+// errorKinds registers every error the API reports with a dedicated code.
+// Errors missing from it are reported with ErrCodeDefault.
+var errorKinds = map[error]errorKind{
+	core.ErrNonceTooLow:        {ErrCodeNonceTooLow, "NONCE_TOO_LOW"},
+	core.ErrNonceTooHigh:       {ErrCodeNonceTooHigh, "NONCE_TOO_HIGH"},
+	core.ErrInsufficientFunds:  {ErrCodeInsufficientFunds, "INSUFFICIENT_FUNDS"},
+	core.ErrUnderpriced:        {ErrCodeUnderpriced, "UNDERPRICED"},
+	core.ErrReplaceUnderpriced: {ErrCodeReplaceUnderpriced, "REPLACEMENT_UNDERPRICED"},
+	core.ErrIntrinsicGas:       {ErrCodeIntrinsicGas, "INTRINSIC_GAS_TOO_LOW"},
+	core.ErrGasLimit:           {ErrCodeGasLimit, "EXCEEDS_BLOCK_GAS_LIMIT"},
+	core.ErrOversizedData:      {ErrCodeOversizedData, "OVERSIZED_DATA"},
+	core.ErrNegativeValue:      {ErrCodeNegativeValue, "NEGATIVE_VALUE"},
+	core.ErrKownTransaction:    {ErrCodeKnownTransaction, "KNOWN_TRANSACTION"},
+	core.ErrUnprotectedTx:      {ErrCodeUnprotectedTx, "UNPROTECTED_TRANSACTION"},
+	core.ErrInvalidSender:      {ErrCodeInvalidSender, "INVALID_SENDER"},
+	core.ErrFeeCapTooLow:       {ErrCodeFeeCapTooLow, "FEE_CAP_TOO_LOW"},
+	core.ErrSpamDeprioritized:  {ErrCodeSpamDeprioritized, "SPAM_DEPRIORITIZED"},
+	errInvalidNonce:            {ErrCodeInvalidNonce, "INVALID_NONCE"},
+
+	accounts.ErrUnknownAccount: {ErrCodeUnknownAccount, "UNKNOWN_ACCOUNT"},
+	keystore.ErrNoMatch:        {ErrCodeUnknownAccount, "UNKNOWN_ACCOUNT"},
+	keystore.ErrLocked:         {ErrCodeAccountLocked, "ACCOUNT_LOCKED"},
+	keystore.ErrDecrypt:        {ErrCodeInvalidPassword, "INVALID_PASSWORD"},
+
+	errGasEstimateFail: {ErrCodeExecutionFailed, "EXECUTION_FAILED"},
+}
+
+// APIError is an error with a stable code and a structured data object,
+// reported to clients in the code and data fields of the JSON-RPC error.
+type APIError struct {
+	Code    int
+	Message string
+	Data    interface{}
+}
+
+func (e *APIError) Error() string          { return e.Message }
+func (e *APIError) ErrorCode() int         { return e.Code }
+func (e *APIError) ErrorData() interface{} { return e.Data }
+
+// errorData is the data object common to all registered errors.
+type errorData struct {
+	Reason string `json:"reason"`
+}
+
+// balanceErrorData is the data object of ErrCodeInsufficientFunds.
+type balanceErrorData struct {
+	Reason    string       `json:"reason"`
+	Required  *hexutil.Big `json:"required"`
+	Available *hexutil.Big `json:"available"`
+}
+
+// nonceErrorData is the data object of ErrCodeNonceTooLow and ErrCodeNonceTooHigh.
+type nonceErrorData struct {
+	Reason   string         `json:"reason"`
+	Nonce    hexutil.Uint64 `json:"nonce"`
+	Expected hexutil.Uint64 `json:"expected"`
+}
+
+// gasErrorData is the data object of ErrCodeExecutionFailed raised by gas
+// estimation.
+type gasErrorData struct {
+	Reason string         `json:"reason"`
+	Cap    hexutil.Uint64 `json:"cap"`
+}
+
+// apiError converts err into an APIError carrying its registered code, or
+// returns it unchanged if it isn't registered.
+func apiError(err error) error {
+	return apiErrorWithData(err, nil)
+}
+
+// apiErrorWithData is like apiError but lets data builders extend the common
+// error data. The builder receives the registered reason.
+func apiErrorWithData(err error, data func(reason string) interface{}) error {
+	if err == nil {
+		return nil
+	}
+	kind, ok := errorKinds[err]
+	if !ok {
+		return err
+	}
+	e := &APIError{Code: kind.code, Message: err.Error(), Data: errorData{Reason: kind.reason}}
+	if data != nil {
+		e.Data = data(kind.reason)
+	}
+	return e
+}
+
+// txError converts a transaction submission error into an APIError, adding
+// the account balance or nonce the transaction was checked against.
+func txError(ctx context.Context, b Backend, tx *types.Transaction, err error) error {
+	switch err {
+	case core.ErrInsufficientFunds:
+		from, statedb, ok := txSenderState(ctx, b, tx)
+		if !ok {
+			break
+		}
+		required := tx.Cost()
+		if ex := tx.GetMatrix_EX(); len(ex) > 0 && len(ex[0].ExtraTo) > 0 {
+			required = tx.CostALL()
+		}
+		available := new(big.Int).Set(statedb.GetBalance(from))
+		return apiErrorWithData(err, func(reason string) interface{} {
+			return balanceErrorData{Reason: reason, Required: (*hexutil.Big)(required), Available: (*hexutil.Big)(available)}
+		})
+	case core.ErrNonceTooLow, core.ErrNonceTooHigh:
+		from, _, ok := txSenderState(ctx, b, tx)
+		if !ok {
+			break
+		}
+		expected, perr := b.GetPoolNonce(ctx, from)
+		if perr != nil {
+			break
+		}
+		return apiErrorWithData(err, func(reason string) interface{} {
+			return nonceErrorData{Reason: reason, Nonce: hexutil.Uint64(tx.Nonce()), Expected: hexutil.Uint64(expected)}
+		})
+	}
+	return apiError(err)
+}
+
+// txSenderState returns the sender of tx and the pending state it is
+// validated against.
+func txSenderState(ctx context.Context, b Backend, tx *types.Transaction) (common.Address, *state.StateDB, bool) {
+	signer := types.MakeSigner(b.ChainConfig(), b.CurrentBlock().Number())
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return from, nil, false
+	}
+	statedb, _, err := b.StateAndHeaderByNumber(ctx, rpc.PendingBlockNumber)
+	if statedb == nil || err != nil {
+		return from, nil, false
+	}
+	return from, statedb, true
+}
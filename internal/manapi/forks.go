@@ -0,0 +1,89 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package manapi
+
+import (
+	"math/big"
+
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/params"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+// ForkInfo is a fork of the chain and its activation block.
+type ForkInfo struct {
+	Name   string       `json:"name"`
+	Block  *hexutil.Big `json:"block"`  // Nil if the fork is not scheduled on this chain
+	Active bool         `json:"active"` // Whether the fork rules apply at the requested block
+}
+
+// ForkSchedule is the result of matrix_forkSchedule.
+type ForkSchedule struct {
+	Number hexutil.Uint64      `json:"number"` // Block the rules were evaluated at
+	Head   hexutil.Uint64      `json:"head"`
+	Rules  []string            `json:"rules"` // Names of the forks active at the block
+	Next   *ForkInfo           `json:"next"`  // Next fork activating after the block, nil if none is scheduled
+	Forks  []ForkInfo          `json:"forks"`
+	Config *params.ChainConfig `json:"config"`
+}
+
+// Config returns the chain configuration the node is running with.
+func (s *PublicMatrixAPI) Config() *params.ChainConfig {
+	return s.b.ChainConfig()
+}
+
+// ForkSchedule returns the forks of the chain and which of them are active at
+// the given block, the latest one if not given. Future blocks are allowed, so
+// tooling can find out about upcoming fork boundaries.
+func (s *PublicChainInfoAPI) ForkSchedule(number *rpc.BlockNumber) *ForkSchedule {
+	var (
+		config = s.b.ChainConfig()
+		head   = s.b.CurrentBlock().NumberU64()
+		num    = head
+	)
+	if number != nil {
+		switch *number {
+		case rpc.LatestBlockNumber:
+		case rpc.PendingBlockNumber:
+			num = head + 1
+		default:
+			num = uint64(number.Int64())
+		}
+	}
+	at := new(big.Int).SetUint64(num)
+
+	result := &ForkSchedule{
+		Number: hexutil.Uint64(num),
+		Head:   hexutil.Uint64(head),
+		Rules:  []string{},
+		Config: config,
+	}
+	for _, fork := range config.Forks() {
+		info := ForkInfo{Name: fork.Name, Active: fork.Active(at)}
+		if fork.Block != nil {
+			info.Block = (*hexutil.Big)(fork.Block)
+		}
+		if info.Active {
+			result.Rules = append(result.Rules, fork.Name)
+		} else if fork.Block != nil && (result.Next == nil || fork.Block.Cmp(result.Next.Block.ToInt()) < 0) {
+			next := info
+			result.Next = &next
+		}
+		result.Forks = append(result.Forks, info)
+	}
+	return result
+}
@@ -0,0 +1,119 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package manapi
+
+import (
+	"github.com/hashicorp/golang-lru"
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/metrics"
+)
+
+var (
+	proofCacheHitMeter  = metrics.NewRegisteredMeter("manapi/proof/hit", nil)
+	proofCacheMissMeter = metrics.NewRegisteredMeter("manapi/proof/miss", nil)
+)
+
+// ProofCache keeps recently generated account and storage proofs, so repeated
+// man_getProof queries for hot accounts don't re-traverse the state trie. The
+// witnesses are keyed by state root and thus never go stale; they are retained
+// for a window of blocks behind the chain head, which also lets a pruned node
+// keep serving proofs of recent states whose tries were already dropped.
+type ProofCache struct {
+	witnesses *lru.Cache // witnessKey -> *witness
+	retain    uint64
+}
+
+// witnessKey identifies an account proof (storage = false) or a storage slot
+// proof within a given state.
+type witnessKey struct {
+	root    common.Hash
+	addr    common.Address
+	slot    common.Hash
+	storage bool
+}
+
+// witness is a cached proof along with the number of the block it belongs to.
+type witness struct {
+	number  uint64
+	account *AccountResult
+	storage *StorageResult
+}
+
+// NewProofCache creates a proof cache holding up to size witnesses, each kept
+// for retain blocks behind the chain head.
+func NewProofCache(size int, retain uint64) (*ProofCache, error) {
+	witnesses, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &ProofCache{witnesses: witnesses, retain: retain}, nil
+}
+
+// get assembles the proof of an account and the requested storage slots from the
+// cache, returning nil unless all of them are present. Slots are looked up by
+// their normalized keys, but reported with the key strings of this request.
+func (c *ProofCache) get(root common.Hash, addr common.Address, keys []common.Hash, storageKeys []string) *AccountResult {
+	cached, ok := c.witnesses.Get(witnessKey{root: root, addr: addr})
+	if !ok {
+		proofCacheMissMeter.Mark(1)
+		return nil
+	}
+	result := *cached.(*witness).account
+	result.StorageProof = make([]StorageResult, len(keys))
+	for i, key := range keys {
+		cached, ok := c.witnesses.Get(witnessKey{root: root, addr: addr, slot: key, storage: true})
+		if !ok {
+			proofCacheMissMeter.Mark(1)
+			return nil
+		}
+		result.StorageProof[i] = *cached.(*witness).storage
+		result.StorageProof[i].Key = storageKeys[i]
+	}
+	proofCacheHitMeter.Mark(1)
+	return &result
+}
+
+// add stores the proofs of an account and its storage slots generated against
+// the state of the given block.
+func (c *ProofCache) add(root common.Hash, number uint64, keys []common.Hash, result *AccountResult) {
+	account := *result
+	account.StorageProof = nil
+	c.witnesses.Add(witnessKey{root: root, addr: result.Address}, &witness{number: number, account: &account})
+
+	for i, key := range keys {
+		storage := result.StorageProof[i]
+		c.witnesses.Add(witnessKey{root: root, addr: result.Address, slot: key, storage: true}, &witness{number: number, storage: &storage})
+	}
+}
+
+// Prune drops the witnesses of blocks that fell out of the retention window of
+// the given chain head, to be called on every new head.
+func (c *ProofCache) Prune(head uint64) {
+	if head <= c.retain {
+		return
+	}
+	limit := head - c.retain
+	for _, key := range c.witnesses.Keys() {
+		if cached, ok := c.witnesses.Peek(key); ok && cached.(*witness).number < limit {
+			c.witnesses.Remove(key)
+		}
+	}
+}
+
+// Len returns the number of cached witnesses.
+func (c *ProofCache) Len() int {
+	return c.witnesses.Len()
+}
@@ -0,0 +1,57 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package manapi
+
+import (
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+)
+
+func TestProofCacheStorageKeys(t *testing.T) {
+	cache, err := NewProofCache(16, 8)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	var (
+		root = common.Hash{0x01}
+		addr = common.Address{0x02}
+		keys = []common.Hash{common.HexToHash("0x01")}
+	)
+	cache.add(root, 1, keys, &AccountResult{
+		Address:      addr,
+		Balance:      &hexutil.Big{},
+		StorageProof: []StorageResult{{Key: "0x01", Value: &hexutil.Big{}, Proof: []string{"0xaa"}}},
+	})
+	// The same slot requested in a different spelling is served from the cache
+	// under the key of the new request
+	spelled := "0x0000000000000000000000000000000000000000000000000000000000000001"
+	result := cache.get(root, addr, keys, []string{spelled})
+	if result == nil {
+		t.Fatalf("cached proof missing")
+	}
+	if key := result.StorageProof[0].Key; key != spelled {
+		t.Fatalf("storage key mismatch: have %s, want %s", key, spelled)
+	}
+	if again := cache.get(root, addr, keys, []string{"0x1"}); again.StorageProof[0].Key != "0x1" {
+		t.Fatalf("storage key mismatch: have %s, want 0x1", again.StorageProof[0].Key)
+	}
+	if result.StorageProof[0].Key != spelled {
+		t.Fatalf("earlier result modified by a later hit")
+	}
+}
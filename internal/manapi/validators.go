@@ -0,0 +1,256 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package manapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/matrix/go-matrix/ca"
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/mc"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+const maxValidatorPerformanceRange = 10000 // Maximum number of blocks evaluated by a single request
+
+// ValidatorPerformanceArgs selects the blocks to evaluate validators over.
+type ValidatorPerformanceArgs struct {
+	FromBlock *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock   *rpc.BlockNumber `json:"toBlock"` // Defaults to the latest block
+	Duties    bool             `json:"duties"`  // Whether to include the per block duty calendar
+}
+
+// ValidatorPerformance holds the block production record of a validator.
+type ValidatorPerformance struct {
+	Address    common.Address `json:"address"`
+	Expected   hexutil.Uint64 `json:"expected"`   // Blocks the validator was first in line to propose
+	Produced   hexutil.Uint64 `json:"produced"`   // Blocks the validator proposed
+	Missed     hexutil.Uint64 `json:"missed"`     // Turns the validator lost to a leader re-election
+	AvgLatency float64        `json:"avgLatency"` // Mean seconds between the parent and the validator's blocks
+}
+
+// ValidatorDuty is an entry of the duty calendar, describing who was due to
+// propose a block and who eventually did.
+type ValidatorDuty struct {
+	Number   hexutil.Uint64   `json:"number"`
+	Expected common.Address   `json:"expected"`
+	Leader   common.Address   `json:"leader"`
+	Missed   []common.Address `json:"missed"` // Leaders skipped by re-elections before the block was made
+}
+
+// ValidatorPerformanceReport is the result of matrix_validatorPerformance.
+type ValidatorPerformanceReport struct {
+	FromBlock       hexutil.Uint64          `json:"fromBlock"`
+	ToBlock         hexutil.Uint64          `json:"toBlock"`
+	Blocks          hexutil.Uint64          `json:"blocks"`          // Validator blocks evaluated
+	BroadcastBlocks hexutil.Uint64          `json:"broadcastBlocks"` // Blocks proposed by the broadcast node
+	Unknown         hexutil.Uint64          `json:"unknown"`         // Blocks whose schedule couldn't be derived
+	Validators      []*ValidatorPerformance `json:"validators"`
+	Duties          []*ValidatorDuty        `json:"duties,omitempty"`
+}
+
+// PublicValidatorAPI reports on the reliability of the validators.
+type PublicValidatorAPI struct {
+	b Backend
+}
+
+// NewPublicValidatorAPI creates a new validator reliability API.
+func NewPublicValidatorAPI(b Backend) *PublicValidatorAPI {
+	return &PublicValidatorAPI{b}
+}
+
+// ValidatorPerformance reports, per validator, the blocks expected and produced
+// over a range, the average proposal latency and the missed turns. The leader
+// schedule is recomputed from the headers and the elected validator lists the
+// same way the verifiers rotate leaders: each block is due to the validator
+// following the previous leader, and every leader re-election passes the turn
+// on to the next one.
+func (s *PublicValidatorAPI) ValidatorPerformance(ctx context.Context, args ValidatorPerformanceArgs) (*ValidatorPerformanceReport, error) {
+	if args.FromBlock == nil {
+		return nil, errors.New("missing fromBlock")
+	}
+	head := s.b.CurrentBlock().NumberU64()
+	resolve := func(number rpc.BlockNumber) uint64 {
+		if number < 0 || uint64(number) > head {
+			return head // latest, pending and future blocks
+		}
+		return uint64(number)
+	}
+	from, to := resolve(*args.FromBlock), head
+	if args.ToBlock != nil {
+		to = resolve(*args.ToBlock)
+	}
+	if from > to {
+		return nil, fmt.Errorf("fromBlock %d after toBlock %d", from, to)
+	}
+	if count := to - from + 1; count > maxValidatorPerformanceRange {
+		return nil, fmt.Errorf("block range too large: %d blocks, maximum %d", count, maxValidatorPerformanceRange)
+	}
+	if from == 0 {
+		from = 1 // the genesis block has no leader
+	}
+	report := &ValidatorPerformanceReport{FromBlock: hexutil.Uint64(from), ToBlock: hexutil.Uint64(to)}
+	if from > to {
+		return report, nil
+	}
+	var (
+		validators = make(map[common.Address]*validatorRecord)
+		record     = func(addr common.Address) *validatorRecord {
+			if validators[addr] == nil {
+				validators[addr] = &validatorRecord{}
+			}
+			return validators[addr]
+		}
+		headers = make(map[uint64]*types.Header) // Sliding window of the last few headers
+	)
+	header := func(number uint64) (*types.Header, error) {
+		if h, ok := headers[number]; ok {
+			return h, nil
+		}
+		h, err := s.b.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return nil, err
+		}
+		if h == nil {
+			return nil, fmt.Errorf("block %d not found", number)
+		}
+		headers[number] = h
+		delete(headers, number-3)
+		return h, nil
+	}
+	for number := from; number <= to; number++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		current, err := header(number)
+		if err != nil {
+			return nil, err
+		}
+		if common.IsBroadcastNumber(number) {
+			report.BroadcastBlocks++
+			continue
+		}
+		parent, err := header(number - 1)
+		if err != nil {
+			return nil, err
+		}
+		// The turn order starts after the previous validator leader, or with the
+		// first validator right after a re-election
+		topology, err := ca.GetTopologyByNumber(common.RoleValidator, number-1)
+		if err != nil || len(topology.NodeList) == 0 {
+			report.Unknown++
+			continue
+		}
+		var base int
+		if common.IsReElectionNumber(number - 1) {
+			base = 0
+		} else {
+			prev := parent
+			if common.IsBroadcastNumber(number - 1) {
+				if prev, err = header(number - 2); err != nil {
+					return nil, err
+				}
+			}
+			if base = validatorIndex(topology.NodeList, prev.Leader); base < 0 {
+				report.Unknown++
+				continue
+			}
+			base++
+		}
+		missed, ok := leaderTurns(topology.NodeList, base, current.Leader)
+		if !ok {
+			report.Unknown++
+			continue
+		}
+		report.Blocks++
+
+		expected := topology.NodeList[base%len(topology.NodeList)].Account
+		record(expected).expected++
+		for _, addr := range missed {
+			record(addr).missed++
+		}
+		leader := record(current.Leader)
+		leader.produced++
+		if current.Time != nil && parent.Time != nil && current.Time.Cmp(parent.Time) > 0 {
+			leader.latency += current.Time.Uint64() - parent.Time.Uint64()
+		}
+		if args.Duties {
+			report.Duties = append(report.Duties, &ValidatorDuty{
+				Number:   hexutil.Uint64(number),
+				Expected: expected,
+				Leader:   current.Leader,
+				Missed:   missed,
+			})
+		}
+	}
+	report.Validators = make([]*ValidatorPerformance, 0, len(validators))
+	for addr, rec := range validators {
+		perf := &ValidatorPerformance{
+			Address:  addr,
+			Expected: hexutil.Uint64(rec.expected),
+			Produced: hexutil.Uint64(rec.produced),
+			Missed:   hexutil.Uint64(rec.missed),
+		}
+		if rec.produced > 0 {
+			perf.AvgLatency = float64(rec.latency) / float64(rec.produced)
+		}
+		report.Validators = append(report.Validators, perf)
+	}
+	sort.Slice(report.Validators, func(i, j int) bool {
+		return report.Validators[i].Address.Hex() < report.Validators[j].Address.Hex()
+	})
+	return report, nil
+}
+
+// validatorRecord accumulates the production record of a validator.
+type validatorRecord struct {
+	expected, produced, missed uint64
+	latency                    uint64 // Total seconds between parents and produced blocks
+}
+
+// validatorIndex returns the position of an account in the validator list, or
+// -1 if it isn't a validator.
+func validatorIndex(validators []mc.TopologyNodeInfo, account common.Address) int {
+	for i, v := range validators {
+		if v.Account == account {
+			return i
+		}
+	}
+	return -1
+}
+
+// leaderTurns works out the validators skipped by re-elections on the way from
+// the one due at the base index to the actual leader.
+func leaderTurns(validators []mc.TopologyNodeInfo, base int, leader common.Address) ([]common.Address, bool) {
+	index := validatorIndex(validators, leader)
+	if index < 0 {
+		return nil, false
+	}
+	size := len(validators)
+	turns := ((index-base)%size + size) % size
+
+	missed := make([]common.Address, 0, turns)
+	for i := 0; i < turns; i++ {
+		missed = append(missed, validators[(base+i)%size].Account)
+	}
+	return missed, true
+}
@@ -0,0 +1,98 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package manapi
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/mc"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+func TestLeaderTurns(t *testing.T) {
+	var (
+		a = common.Address{0x0a}
+		b = common.Address{0x0b}
+		c = common.Address{0x0c}
+		d = common.Address{0x0d}
+
+		validators = []mc.TopologyNodeInfo{{Account: a}, {Account: b}, {Account: c}}
+	)
+	tests := []struct {
+		base   int
+		leader common.Address
+		missed []common.Address
+		ok     bool
+	}{
+		{base: 0, leader: a, missed: []common.Address{}, ok: true},     // leader on time
+		{base: 1, leader: c, missed: []common.Address{b}, ok: true},    // one re-election
+		{base: 2, leader: b, missed: []common.Address{c, a}, ok: true}, // turns wrap around
+		{base: 3, leader: a, missed: []common.Address{}, ok: true},     // base past the end wraps too
+		{base: 0, leader: d, ok: false},                                // leader not a validator
+	}
+	for i, tt := range tests {
+		missed, ok := leaderTurns(validators, tt.base, tt.leader)
+		if ok != tt.ok {
+			t.Errorf("test %d: ok mismatch: have %v, want %v", i, ok, tt.ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(missed, tt.missed) {
+			t.Errorf("test %d: missed turns mismatch: have %x, want %x", i, missed, tt.missed)
+		}
+	}
+	if index := validatorIndex(validators, c); index != 2 {
+		t.Errorf("validator index mismatch: have %d, want 2", index)
+	}
+	if index := validatorIndex(validators, d); index != -1 {
+		t.Errorf("non-validator index mismatch: have %d, want -1", index)
+	}
+}
+
+// Tests that blocks whose leader schedule can't be derived are reported as such
+// instead of being attributed to any validator.
+func TestValidatorPerformanceUnknownSchedule(t *testing.T) {
+	b := newTestBackend(t, nil)
+	defer b.chain.Stop()
+	b.generate(t, 4, nil)
+	api := NewPublicValidatorAPI(b)
+
+	from := rpc.BlockNumber(0)
+	report, err := api.ValidatorPerformance(context.Background(), ValidatorPerformanceArgs{FromBlock: &from, Duties: true})
+	if err != nil {
+		t.Fatalf("failed to evaluate validators: %v", err)
+	}
+	if report.FromBlock != 1 || report.ToBlock != 4 {
+		t.Errorf("range mismatch: have %d-%d, want 1-4", report.FromBlock, report.ToBlock)
+	}
+	if report.Blocks != 0 || report.Unknown+report.BroadcastBlocks != 4 {
+		t.Errorf("block counts mismatch: %d evaluated, %d unknown, %d broadcast", report.Blocks, report.Unknown, report.BroadcastBlocks)
+	}
+	if len(report.Validators) != 0 || len(report.Duties) != 0 {
+		t.Errorf("validators reported without a schedule: %d validators, %d duties", len(report.Validators), len(report.Duties))
+	}
+	// Missing and inverted ranges are refused
+	if _, err := api.ValidatorPerformance(context.Background(), ValidatorPerformanceArgs{}); err == nil {
+		t.Error("missing fromBlock accepted")
+	}
+	from, to := rpc.BlockNumber(3), rpc.BlockNumber(2)
+	if _, err := api.ValidatorPerformance(context.Background(), ValidatorPerformanceArgs{FromBlock: &from, ToBlock: &to}); err == nil {
+		t.Error("inverted range accepted")
+	}
+}
@@ -19,19 +19,83 @@ package web3ext
 
 var Modules = map[string]string{
 	"admin":      Admin_JS,
+	"blob":       Blob_JS,
+	"bundler":    Bundler_JS,
 	"chequebook": Chequebook_JS,
 	"clique":     Clique_JS,
 	"debug":      Debug_JS,
+	"evm":        Evm_JS,
 	"man":        Eth_JS,
+	"matrix":     Matrix_JS,
 	"miner":      Miner_JS,
 	"net":        Net_JS,
 	"personal":   Personal_JS,
 	"rpc":        RPC_JS,
 	"shh":        Shh_JS,
 	"swarmfs":    SWARMFS_JS,
+	"trace":      Trace_JS,
 	"txpool":     TxPool_JS,
 }
 
+const Blob_JS = `
+web3._extend({
+	property: 'blob',
+	methods: [
+		new web3._extend.Method({
+			name: 'get',
+			call: 'blob_get',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getByTransaction',
+			call: 'blob_getByTransaction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'send',
+			call: 'blob_send',
+			params: 1
+		}),
+	],
+	properties: [
+		new web3._extend.Property({
+			name: 'status',
+			getter: 'blob_status'
+		}),
+	]
+});
+`
+
+const Bundler_JS = `
+web3._extend({
+	property: 'bundler',
+	methods: [
+		new web3._extend.Method({
+			name: 'buildBundle',
+			call: 'bundler_buildBundle',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'sendBundle',
+			call: 'bundler_sendBundle',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputAddressFormatter]
+		}),
+	],
+	properties: [
+		new web3._extend.Property({
+			name: 'pendingUserOperations',
+			getter: 'bundler_pendingUserOperations'
+		}),
+		new web3._extend.Property({
+			name: 'supportedEntryPoints',
+			getter: 'man_supportedEntryPoints'
+		}),
+	]
+});
+`
+
 const Chequebook_JS = `
 web3._extend({
 	property: 'chequebook',
@@ -123,6 +187,16 @@ web3._extend({
 			call: 'admin_removePeer',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'disableProtocol',
+			call: 'admin_disableProtocol',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'enableProtocol',
+			call: 'admin_enableProtocol',
+			params: 2
+		}),
 		new web3._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
@@ -159,6 +233,44 @@ web3._extend({
 			name: 'stopWS',
 			call: 'admin_stopWS'
 		}),
+		new web3._extend.Method({
+			name: 'addAPIKey',
+			call: 'admin_addAPIKey',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'removeAPIKey',
+			call: 'admin_removeAPIKey',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'rotateNodeKey',
+			call: 'admin_rotateNodeKey',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'reloadConfig',
+			call: 'admin_reloadConfig',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'registerABI',
+			call: 'admin_registerABI',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'unregisterABI',
+			call: 'admin_unregisterABI',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'setABIRegistry',
+			call: 'admin_setABIRegistry',
+			params: 1
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -169,10 +281,34 @@ web3._extend({
 			name: 'peers',
 			getter: 'admin_peers'
 		}),
+		new web3._extend.Property({
+			name: 'protocolInfo',
+			getter: 'admin_protocolInfo'
+		}),
 		new web3._extend.Property({
 			name: 'datadir',
 			getter: 'admin_datadir'
 		}),
+		new web3._extend.Property({
+			name: 'apiKeys',
+			getter: 'admin_apiKeys'
+		}),
+		new web3._extend.Property({
+			name: 'buildInfo',
+			getter: 'admin_buildInfo'
+		}),
+		new web3._extend.Property({
+			name: 'services',
+			getter: 'admin_services'
+		}),
+		new web3._extend.Property({
+			name: 'topology',
+			getter: 'admin_topology'
+		}),
+		new web3._extend.Property({
+			name: 'abis',
+			getter: 'admin_listABIs'
+		}),
 	]
 });
 `
@@ -242,6 +378,38 @@ web3._extend({
 			params: 0,
 			outputFormatter: console.log
 		}),
+		new web3._extend.Method({
+			name: 'heartbeats',
+			call: 'debug_heartbeats',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'forkStatus',
+			call: 'debug_forkStatus',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'exportState',
+			call: 'debug_exportState',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'stateExportStatus',
+			call: 'debug_stateExportStatus',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'abortStateExport',
+			call: 'debug_abortStateExport',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'callStats',
+			call: 'debug_callStats',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
 		new web3._extend.Method({
 			name: 'freeOSMemory',
 			call: 'debug_freeOSMemory',
@@ -373,6 +541,36 @@ web3._extend({
 			call: 'debug_storageRangeAt',
 			params: 5,
 		}),
+		new web3._extend.Method({
+			name: 'storageSize',
+			call: 'debug_storageSize',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'iterateStorage',
+			call: 'debug_iterateStorage',
+			params: 4,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'codeStats',
+			call: 'debug_codeStats',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'stateLastAccess',
+			call: 'debug_stateLastAccess',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'stateInactivity',
+			call: 'debug_stateInactivity',
+			params: 1,
+			inputFormatter: [null]
+		}),
 		new web3._extend.Method({
 			name: 'getModifiedAccountsByNumber',
 			call: 'debug_getModifiedAccountsByNumber',
@@ -390,6 +588,65 @@ web3._extend({
 });
 `
 
+const Evm_JS = `
+web3._extend({
+	property: 'evm',
+	methods: [
+		new web3._extend.Method({
+			name: 'snapshot',
+			call: 'evm_snapshot'
+		}),
+		new web3._extend.Method({
+			name: 'revert',
+			call: 'evm_revert',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'mine',
+			call: 'evm_mine',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'increaseTime',
+			call: 'evm_increaseTime',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setNextBlockTimestamp',
+			call: 'evm_setNextBlockTimestamp',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setAutomine',
+			call: 'evm_setAutomine',
+			params: 1
+		}),
+	],
+	properties: []
+});
+`
+
+const Trace_JS = `
+web3._extend({
+	property: 'trace',
+	methods: [
+		new web3._extend.Method({
+			name: 'filter',
+			call: 'trace_filter',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'interactions',
+			call: 'trace_interactions',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+	],
+	properties: []
+});
+`
+
 const Eth_JS = `
 web3._extend({
 	property: 'man',
@@ -412,6 +669,12 @@ web3._extend({
 			params: 1,
 			inputFormatter: [web3._extend.formatters.inputTransactionFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'fillTransaction',
+			call: 'man_fillTransaction',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputTransactionFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'submitTransaction',
 			call: 'man_submitTransaction',
@@ -431,6 +694,29 @@ web3._extend({
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.utils.toHex]
 		}),
+		new web3._extend.Method({
+			name: 'getHeaderByNumber',
+			call: 'man_getHeaderByNumber',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getHeaderByHash',
+			call: 'man_getHeaderByHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getProof',
+			call: 'man_getProof',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'multicall',
+			call: 'man_multicall',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
 		//hezi
 		new web3._extend.Method({
 			name: 'getTopology',
@@ -442,8 +728,54 @@ web3._extend({
 			call: 'man_getSelfLevel',
 			params: 0,
 		}),
+		new web3._extend.Method({
+			name: 'decodeCalldata',
+			call: 'man_decodeCalldata',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'decodeLog',
+			call: 'man_decodeLog',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'computeContractAddress',
+			call: 'man_computeContractAddress',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'computeContractAddresses',
+			call: 'man_computeContractAddresses',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'sendUserOperation',
+			call: 'man_sendUserOperation',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getUserOperationByHash',
+			call: 'man_getUserOperationByHash',
+			params: 1
+		}),
 	],
 	properties: [
+		new web3._extend.Property({
+			name: 'chainId',
+			getter: 'man_chainId',
+			outputFormatter: web3._extend.utils.toDecimal
+		}),
+		new web3._extend.Property({
+			name: 'config',
+			getter: 'man_config'
+		}),
+		new web3._extend.Property({
+			name: 'maxPriorityFeePerGas',
+			getter: 'man_maxPriorityFeePerGas',
+			outputFormatter: web3._extend.utils.toBigNumber
+		}),
 		new web3._extend.Property({
 			name: 'pendingTransactions',
 			getter: 'man_pendingTransactions',
@@ -634,7 +966,14 @@ web3._extend({
 const TxPool_JS = `
 web3._extend({
 	property: 'txpool',
-	methods: [],
+	methods: [
+		new web3._extend.Method({
+			name: 'flush',
+			call: 'txpool_flush',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+	],
 	properties:
 	[
 		new web3._extend.Property({
@@ -657,3 +996,39 @@ web3._extend({
 	]
 });
 `
+
+const Matrix_JS = `
+web3._extend({
+	property: 'matrix',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'blockStats',
+			call: 'matrix_blockStats',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'validatorPerformance',
+			call: 'matrix_validatorPerformance',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'forkSchedule',
+			call: 'matrix_forkSchedule',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+	],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'chainInfo',
+			getter: 'matrix_chainInfo'
+		}),
+		new web3._extend.Property({
+			name: 'chains',
+			getter: 'matrix_chains'
+		}),
+	]
+});
+`
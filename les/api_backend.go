@@ -33,6 +33,7 @@ import (
 	"github.com/matrix/go-matrix/man/gasprice"
 	"github.com/matrix/go-matrix/mandb"
 	"github.com/matrix/go-matrix/event"
+	"github.com/matrix/go-matrix/internal/manapi"
 	"github.com/matrix/go-matrix/light"
 	"github.com/matrix/go-matrix/params"
 	"github.com/matrix/go-matrix/rpc"
@@ -47,6 +48,10 @@ func (b *LesApiBackend) ChainConfig() *params.ChainConfig {
 	return b.man.chainConfig
 }
 
+func (b *LesApiBackend) NetworkId() uint64 {
+	return b.man.networkId
+}
+
 func (b *LesApiBackend) CurrentBlock() *types.Block {
 	return types.NewBlockWithHeader(b.man.BlockChain().CurrentHeader())
 }
@@ -64,6 +69,10 @@ func (b *LesApiBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNum
 	return b.man.blockchain.GetHeaderByNumberOdr(ctx, uint64(blockNr))
 }
 
+func (b *LesApiBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return b.man.blockchain.GetHeaderByHash(hash), nil
+}
+
 func (b *LesApiBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error) {
 	header, err := b.HeaderByNumber(ctx, blockNr)
 	if header == nil || err != nil {
@@ -80,6 +89,14 @@ func (b *LesApiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.
 	return light.NewState(ctx, header, b.man.odr), header, nil
 }
 
+func (b *LesApiBackend) ProofCache() *manapi.ProofCache {
+	return nil
+}
+
+func (b *LesApiBackend) CallStats() *manapi.CallStats {
+	return nil
+}
+
 func (b *LesApiBackend) GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
 	return b.man.blockchain.GetBlockByHash(ctx, blockHash)
 }
@@ -112,6 +129,10 @@ func (b *LesApiBackend) SendTx(ctx context.Context, signedTx *types.Transaction)
 	return b.man.txPool.Add(ctx, signedTx)
 }
 
+func (b *LesApiBackend) UnprotectedAllowed(hash common.Hash) bool {
+	return b.man.config.TxPool.UnprotectedAllowed(hash)
+}
+
 func (b *LesApiBackend) RemoveTx(txHash common.Hash) {
 	b.man.txPool.RemoveTx(txHash)
 }
@@ -136,6 +157,13 @@ func (b *LesApiBackend) TxPoolContent() (map[common.Address]types.Transactions,
 	return b.man.txPool.Content()
 }
 
+func (b *LesApiBackend) FlushTxs(addr common.Address) types.Transactions {
+	pending, _ := b.man.txPool.Content()
+	txs := pending[addr]
+	b.man.txPool.RemoveTransactions(txs)
+	return txs
+}
+
 func (b *LesApiBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
 	return b.man.txPool.SubscribeNewTxsEvent(ch)
 }
@@ -156,6 +184,14 @@ func (b *LesApiBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscri
 	return b.man.blockchain.SubscribeLogsEvent(ch)
 }
 
+func (b *LesApiBackend) SubscribePendingLogsEvent(ch chan<- core.PendingLogsEvent) event.Subscription {
+	return b.man.blockchain.SubscribePendingLogsEvent(ch)
+}
+
+func (b *LesApiBackend) SubscribeBalanceChangeEvent(ch chan<- core.BalanceChangeEvent) event.Subscription {
+	return b.man.blockchain.SubscribeBalanceChangeEvent(ch)
+}
+
 func (b *LesApiBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
 	return b.man.blockchain.SubscribeRemovedLogsEvent(ch)
 }
@@ -172,6 +208,10 @@ func (b *LesApiBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+func (b *LesApiBackend) SuggestTipCap(ctx context.Context) (*big.Int, error) {
+	return b.gpo.SuggestTipCap(ctx)
+}
+
 func (b *LesApiBackend) ChainDb() mandb.Database {
 	return b.man.chainDb
 }
@@ -34,6 +34,7 @@ import (
 	"github.com/matrix/go-matrix/man/downloader"
 	"github.com/matrix/go-matrix/man/filters"
 	"github.com/matrix/go-matrix/man/gasprice"
+	"github.com/matrix/go-matrix/man/remotestate"
 	"github.com/matrix/go-matrix/mandb"
 	"github.com/matrix/go-matrix/event"
 	"github.com/matrix/go-matrix/internal/manapi"
@@ -62,6 +63,7 @@ type LightMatrix struct {
 	serverPool      *serverPool
 	reqDist         *requestDistributor
 	retriever       *retrieveManager
+	remoteState     *remotestate.Backend // Verified upstream state server (nil = disabled)
 	// DB interfaces
 	chainDb mandb.Database // Block chain database
 
@@ -126,6 +128,12 @@ func New(ctx *node.ServiceContext, config *man.Config) (*LightMatrix, error) {
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 
+	if config.RemoteState != nil {
+		if leth.remoteState, err = remotestate.New(config.RemoteState, leth.blockchain); err != nil {
+			return nil, err
+		}
+		log.Info("Serving state from upstream nodes", "upstreams", len(config.RemoteState.Upstreams))
+	}
 	leth.txPool = light.NewTxPool(leth.chainConfig, leth.blockchain, leth.relay)
 	if leth.protocolManager, err = NewProtocolManager(leth.chainConfig, true, ClientProtocolVersions, config.NetworkId, leth.eventMux, leth.engine, leth.peers, leth.blockchain, nil, chainDb, leth.odr, leth.relay, quitSync, &leth.wg); err != nil {
 		return nil, err
@@ -177,7 +185,7 @@ func (s *LightDummyAPI) Mining() bool {
 // APIs returns the collection of RPC services the matrix package offers.
 // NOTE, some of these services probably need to be moved to somewhere else.
 func (s *LightMatrix) APIs() []rpc.API {
-	return append(manapi.GetAPIs(s.ApiBackend), []rpc.API{
+	apis := append(manapi.GetAPIs(s.ApiBackend), []rpc.API{
 		{
 			Namespace: "man",
 			Version:   "1.0",
@@ -191,12 +199,12 @@ func (s *LightMatrix) APIs() []rpc.API {
 		}, {
 			Namespace: "man",
 			Version:   "1.0",
-			Service:   downloader.NewPublicDownloaderAPI(s.protocolManager.downloader, s.eventMux),
+			Service:   downloader.NewPublicDownloaderAPI(s.protocolManager.downloader),
 			Public:    true,
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   downloader.NewPublicDownloaderAPI(s.protocolManager.downloader, s.eventMux),
+			Service:   downloader.NewPublicDownloaderAPI(s.protocolManager.downloader),
 			Public:    true,
 		}, {
 			Namespace: "man",
@@ -215,6 +223,23 @@ func (s *LightMatrix) APIs() []rpc.API {
 			Public:    true,
 		},
 	}...)
+	// Registered last, the remote state methods take over the ODR backed ones
+	if s.remoteState != nil {
+		apis = append(apis, []rpc.API{
+			{
+				Namespace: "man",
+				Version:   "1.0",
+				Service:   remotestate.NewPublicStateAPI(s.remoteState),
+				Public:    true,
+			}, {
+				Namespace: "eth",
+				Version:   "1.0",
+				Service:   remotestate.NewPublicStateAPI(s.remoteState),
+				Public:    true,
+			},
+		}...)
+	}
+	return apis
 }
 
 func (s *LightMatrix) ResetWithGenesisBlock(gb *types.Block) {
@@ -263,6 +288,9 @@ func (s *LightMatrix) Stop() error {
 	s.blockchain.Stop()
 	s.protocolManager.Stop()
 	s.txPool.Stop()
+	if s.remoteState != nil {
+		s.remoteState.Close()
+	}
 
 	s.eventMux.Stop()
 
@@ -509,3 +509,15 @@ func (self *LightChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscri
 func (self *LightChain) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
 	return self.scope.Track(new(event.Feed).Subscribe(ch))
 }
+
+// SubscribePendingLogsEvent implements the interface of filters.Backend
+// LightChain does not produce pending blocks, so return an empty subscription.
+func (self *LightChain) SubscribePendingLogsEvent(ch chan<- core.PendingLogsEvent) event.Subscription {
+	return self.scope.Track(new(event.Feed).Subscribe(ch))
+}
+
+// SubscribeBalanceChangeEvent implements the interface of filters.Backend
+// LightChain does not execute blocks, so return an empty subscription.
+func (self *LightChain) SubscribeBalanceChangeEvent(ch chan<- core.BalanceChangeEvent) event.Subscription {
+	return self.scope.Track(new(event.Feed).Subscribe(ch))
+}
@@ -36,6 +36,7 @@ import (
 	"github.com/matrix/go-matrix/core/state"
 	"github.com/matrix/go-matrix/core/types"
 	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/man/forkmon"
 	"github.com/matrix/go-matrix/mc"
 
 	"github.com/matrix/go-matrix/miner"
@@ -498,6 +499,19 @@ func (api *PrivateDebugAPI) GetBadBlocks(ctx context.Context) ([]core.BadBlockAr
 	return api.man.BlockChain().BadBlocks()
 }
 
+// ForkStatus returns the chain branches followed by the connected peers as seen
+// by the last run of the fork monitor.
+func (api *PrivateDebugAPI) ForkStatus() (*forkmon.Status, error) {
+	if api.man.forkMonitor == nil {
+		return nil, errors.New("fork monitor disabled")
+	}
+	status := api.man.forkMonitor.Status()
+	if status == nil {
+		return nil, errors.New("fork monitor not run yet")
+	}
+	return status, nil
+}
+
 // StorageRangeResult is the result of a debug_storageRangeAt API call.
 type StorageRangeResult struct {
 	Storage storageMap   `json:"storage"`
@@ -0,0 +1,102 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package man
+
+import (
+	"errors"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core"
+)
+
+// errStateAccessDisabled is returned by the state access queries if the node
+// doesn't index state accesses.
+var errStateAccessDisabled = errors.New("state access index disabled")
+
+// defaultInactivityThresholds are the inactivity periods, in epochs of the
+// state access index, reported if the request doesn't specify any.
+var defaultInactivityThresholds = []uint64{1, 4, 16, 64, 256, 1024}
+
+// StateAccess is the result of a debug_stateLastAccess API call.
+type StateAccess struct {
+	Address  common.Address  `json:"address"`
+	Slot     *common.Hash    `json:"slot,omitempty"`
+	Accessed *hexutil.Uint64 `json:"accessed"` // Number of the last block accessing the entry, nil if not indexed
+	Inactive *hexutil.Uint64 `json:"inactive"` // Number of blocks since the last access, nil if not indexed
+}
+
+// StateInactivity is the result of a debug_stateInactivity API call.
+type StateInactivity struct {
+	Head    hexutil.Uint64           `json:"head"`
+	Epoch   hexutil.Uint64           `json:"epoch"` // Granularity of the inactivity periods, in blocks
+	Buckets []*core.InactivityBucket `json:"buckets"`
+}
+
+// StateLastAccess returns the last block of the canonical chain accessing an
+// account, or one of its storage slots if a slot is given.
+func (api *PrivateDebugAPI) StateLastAccess(address common.Address, slot *common.Hash) (*StateAccess, error) {
+	index := api.man.BlockChain().StateAccessIndex()
+	if index == nil {
+		return nil, errStateAccessDisabled
+	}
+	var (
+		accessed uint64
+		indexed  bool
+	)
+	if slot != nil {
+		accessed, indexed = index.SlotAccess(address, *slot)
+	} else {
+		accessed, indexed = index.AccountAccess(address)
+	}
+	result := &StateAccess{Address: address, Slot: slot}
+	if indexed {
+		inactive := uint64(0)
+		if head := api.man.BlockChain().CurrentBlock().NumberU64(); head > accessed {
+			inactive = head - accessed
+		}
+		result.Accessed = (*hexutil.Uint64)(&accessed)
+		result.Inactive = (*hexutil.Uint64)(&inactive)
+	}
+	return result, nil
+}
+
+// StateInactivity returns the number of indexed accounts and storage slots not
+// accessed for at least each of the given numbers of blocks, as of the current
+// head. Without thresholds, periods growing from one epoch of the index are
+// reported.
+func (api *PrivateDebugAPI) StateInactivity(thresholds []hexutil.Uint64) (*StateInactivity, error) {
+	index := api.man.BlockChain().StateAccessIndex()
+	if index == nil {
+		return nil, errStateAccessDisabled
+	}
+	periods := make([]uint64, len(thresholds))
+	for i, threshold := range thresholds {
+		periods[i] = uint64(threshold)
+	}
+	if len(periods) == 0 {
+		for _, epochs := range defaultInactivityThresholds {
+			periods = append(periods, epochs*core.StateAccessEpoch)
+		}
+	}
+	head := api.man.BlockChain().CurrentBlock().NumberU64()
+	return &StateInactivity{
+		Head:    hexutil.Uint64(head),
+		Epoch:   core.StateAccessEpoch,
+		Buckets: index.Inactivity(head, periods),
+	}, nil
+}
@@ -19,6 +19,7 @@ package man
 import (
 	"context"
 	"math/big"
+	"sync"
 	"time"
 
 	"github.com/matrix/go-matrix/accounts"
@@ -35,6 +36,7 @@ import (
 	"github.com/matrix/go-matrix/man/gasprice"
 	"github.com/matrix/go-matrix/mandb"
 	"github.com/matrix/go-matrix/event"
+	"github.com/matrix/go-matrix/internal/manapi"
 	"github.com/matrix/go-matrix/log"
 	"github.com/matrix/go-matrix/params"
 	"github.com/matrix/go-matrix/rpc"
@@ -50,6 +52,10 @@ func (b *EthAPIBackend) ChainConfig() *params.ChainConfig {
 	return b.man.chainConfig
 }
 
+func (b *EthAPIBackend) NetworkId() uint64 {
+	return b.man.networkId
+}
+
 func (b *EthAPIBackend) CurrentBlock() *types.Block {
 	return b.man.blockchain.CurrentBlock()
 }
@@ -60,6 +66,10 @@ func (b *EthAPIBackend) SetHead(number uint64) {
 }
 
 func (b *EthAPIBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
+	// Requests cancelled by the caller do not touch the database
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	// Pending block is only known by the miner
 	if blockNr == rpc.PendingBlockNumber {
 		block := b.man.miner.PendingBlock()
@@ -72,7 +82,17 @@ func (b *EthAPIBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNum
 	return b.man.blockchain.GetHeaderByNumber(uint64(blockNr)), nil
 }
 
+func (b *EthAPIBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return b.man.blockchain.GetHeaderByHash(hash), nil
+}
+
 func (b *EthAPIBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	// Pending block is only known by the miner
 	if blockNr == rpc.PendingBlockNumber {
 		block := b.man.miner.PendingBlock()
@@ -86,6 +106,9 @@ func (b *EthAPIBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumb
 }
 
 func (b *EthAPIBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
 	// Pending state is only known by the miner
 	if blockNr == rpc.PendingBlockNumber {
 		block, state := b.man.miner.Pending()
@@ -96,15 +119,38 @@ func (b *EthAPIBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.
 	if header == nil || err != nil {
 		return nil, nil, err
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	// Archive nodes serve historical states through the read cache, so repeated
+	// calls against the same block only resolve the accounts and slots once
+	if b.man.historyState != nil && blockNr != rpc.LatestBlockNumber {
+		stateDb, err := state.New(header.Root, b.man.historyState)
+		return stateDb, header, err
+	}
 	stateDb, err := b.man.BlockChain().StateAt(header.Root)
 	return stateDb, header, err
 }
 
+func (b *EthAPIBackend) ProofCache() *manapi.ProofCache {
+	return b.man.proofCache
+}
+
+func (b *EthAPIBackend) CallStats() *manapi.CallStats {
+	return b.man.callStats
+}
+
 func (b *EthAPIBackend) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return b.man.blockchain.GetBlockByHash(hash), nil
 }
 
 func (b *EthAPIBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if number := rawdb.ReadHeaderNumber(b.man.chainDb, hash); number != nil {
 		return rawdb.ReadReceipts(b.man.chainDb, hash, *number), nil
 	}
@@ -112,6 +158,9 @@ func (b *EthAPIBackend) GetReceipts(ctx context.Context, hash common.Hash) (type
 }
 
 func (b *EthAPIBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	number := rawdb.ReadHeaderNumber(b.man.chainDb, hash)
 	if number == nil {
 		return nil, nil
@@ -132,11 +181,31 @@ func (b *EthAPIBackend) GetTd(blockHash common.Hash) *big.Int {
 }
 
 func (b *EthAPIBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
 	state.SetBalance(msg.From(), math.MaxBig256)
-	vmError := func() error { return nil }
 
 	context := core.NewEVMContext(msg, header, b.man.BlockChain(), nil)
-	return vm.NewEVM(context, state, b.man.chainConfig, vmCfg), vmError, nil
+	evm := vm.NewEVM(context, state, b.man.chainConfig, vmCfg)
+
+	// Abort the execution once the caller gives up on it. The watcher ends with
+	// the vmError call following the execution, reporting the cancellation
+	// instead of the result of an interrupted call.
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			evm.Cancel()
+		case <-done:
+		}
+	}()
+	var once sync.Once
+	vmError := func() error {
+		once.Do(func() { close(done) })
+		return ctx.Err()
+	}
+	return evm, vmError, nil
 }
 
 func (b *EthAPIBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
@@ -159,10 +228,22 @@ func (b *EthAPIBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscri
 	return b.man.BlockChain().SubscribeLogsEvent(ch)
 }
 
+func (b *EthAPIBackend) SubscribePendingLogsEvent(ch chan<- core.PendingLogsEvent) event.Subscription {
+	return b.man.BlockChain().SubscribePendingLogsEvent(ch)
+}
+
+func (b *EthAPIBackend) SubscribeBalanceChangeEvent(ch chan<- core.BalanceChangeEvent) event.Subscription {
+	return b.man.BlockChain().SubscribeBalanceChangeEvent(ch)
+}
+
 func (b *EthAPIBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
 	return b.man.txPool.AddLocal(signedTx)
 }
 
+func (b *EthAPIBackend) UnprotectedAllowed(hash common.Hash) bool {
+	return b.man.config.TxPool.UnprotectedAllowed(hash)
+}
+
 func (b *EthAPIBackend) GetPoolTransactions() (types.Transactions, error) {
 	pending, err := b.man.txPool.Pending()
 	if err != nil {
@@ -191,6 +272,10 @@ func (b *EthAPIBackend) TxPoolContent() (map[common.Address]types.Transactions,
 	return b.man.TxPool().Content()
 }
 
+func (b *EthAPIBackend) FlushTxs(addr common.Address) types.Transactions {
+	return b.man.txPool.Flush(addr)
+}
+
 func (b *EthAPIBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
 	return b.man.TxPool().SubscribeNewTxsEvent(ch)
 }
@@ -207,6 +292,10 @@ func (b *EthAPIBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+func (b *EthAPIBackend) SuggestTipCap(ctx context.Context) (*big.Int, error) {
+	return b.gpo.SuggestTipCap(ctx)
+}
+
 func (b *EthAPIBackend) ChainDb() mandb.Database {
 	return b.man.ChainDb()
 }
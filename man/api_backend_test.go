@@ -0,0 +1,92 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package man
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/consensus/manash"
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/core/vm"
+	"github.com/matrix/go-matrix/mandb"
+	"github.com/matrix/go-matrix/params"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+// Tests that cancelling the context of a call aborts the EVM executing it.
+func TestGetEVMCancel(t *testing.T) {
+	var (
+		db     = mandb.NewMemDatabase()
+		engine = manash.NewFaker()
+		gspec  = &core.Genesis{Config: params.TestChainConfig, GasLimit: 10000000}
+	)
+	gspec.MustCommit(db)
+
+	blockchain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	backend := &EthAPIBackend{man: &Matrix{chainConfig: gspec.Config, blockchain: blockchain, engine: engine}}
+	statedb, header, err := backend.StateAndHeaderByNumber(context.Background(), rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to retrieve state: %v", err)
+	}
+	// Deploy a contract looping forever: JUMPDEST, PUSH1 0, JUMP
+	loop := common.Address{0xff}
+	statedb.SetCode(loop, []byte{byte(vm.JUMPDEST), byte(vm.PUSH1), 0x00, byte(vm.JUMP)})
+	msg := types.NewMessage(common.Address{0x01}, &loop, 0, new(big.Int), math.MaxUint64/2, new(big.Int), nil, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	evm, vmError, err := backend.GetEVM(ctx, msg, statedb, header, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create EVM: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(math.MaxUint64))
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatalf("endless call returned before cancellation")
+	case <-time.After(100 * time.Millisecond):
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("call not aborted after cancellation")
+	}
+	if err := vmError(); err != context.Canceled {
+		t.Fatalf("vm error mismatch: have %v, want %v", err, context.Canceled)
+	}
+	// Cancelled callers must not get any further state or EVM
+	if _, _, err := backend.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber); err != context.Canceled {
+		t.Fatalf("state access error mismatch: have %v, want %v", err, context.Canceled)
+	}
+	if _, _, err := backend.GetEVM(ctx, msg, statedb, header, vm.Config{}); err != context.Canceled {
+		t.Fatalf("EVM creation error mismatch: have %v, want %v", err, context.Canceled)
+	}
+}
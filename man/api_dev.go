@@ -0,0 +1,320 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package man
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/matrixwork"
+)
+
+// DevQuantity is an integer argument of the developer mode RPCs. Like the test
+// chains defining the evm_* methods, it is accepted both as a JSON number and
+// as a hex encoded string.
+type DevQuantity uint64
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (q *DevQuantity) UnmarshalJSON(input []byte) error {
+	if len(input) > 0 && input[0] == '"' {
+		var v hexutil.Uint64
+		if err := json.Unmarshal(input, &v); err != nil {
+			return err
+		}
+		*q = DevQuantity(v)
+		return nil
+	}
+	v, err := strconv.ParseUint(string(input), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid quantity %s", input)
+	}
+	*q = DevQuantity(v)
+	return nil
+}
+
+// devSnapshot is a chain position saved by evm_snapshot.
+type devSnapshot struct {
+	id     uint64
+	number uint64
+	hash   common.Hash
+	root   common.Hash
+	offset int64
+}
+
+// PrivateDevAPI provides the evm_* RPCs of the ganache and hardhat test chains
+// in developer mode. Blocks are mined on demand, or as soon as transactions
+// arrive if automining, and the chain can be snapshotted, reverted and moved
+// forward in time.
+type PrivateDevAPI struct {
+	man *Matrix
+
+	automine  bool           // Whether a block is mined for every batch of new transactions
+	offset    int64          // Seconds added to the local clock when timestamping blocks
+	next      uint64         // Timestamp of the next block, 0 if not forced
+	pinned    common.Hash    // State of a reverted to head, kept in memory until built upon
+	snapshots []*devSnapshot // Snapshots not reverted yet, in increasing id order
+	lastID    uint64
+
+	lock sync.Mutex
+	quit chan struct{}
+}
+
+// NewPrivateDevAPI creates the developer mode API of the given node.
+func NewPrivateDevAPI(man *Matrix) *PrivateDevAPI {
+	return &PrivateDevAPI{
+		man:      man,
+		automine: true,
+		quit:     make(chan struct{}),
+	}
+}
+
+// start launches the automining of pooled transactions.
+func (api *PrivateDevAPI) start() {
+	txCh := make(chan core.NewTxsEvent, 16)
+	txSub := api.man.TxPool().SubscribeNewTxsEvent(txCh)
+
+	go func() {
+		defer txSub.Unsubscribe()
+		for {
+			select {
+			case <-txCh:
+				api.lock.Lock()
+				if api.automine {
+					if _, err := api.mine(); err != nil {
+						log.Error("Failed to automine developer block", "err", err)
+					}
+				}
+				api.lock.Unlock()
+			case <-txSub.Err():
+				return
+			case <-api.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop terminates the automining.
+func (api *PrivateDevAPI) stop() {
+	close(api.quit)
+}
+
+// Snapshot saves the current head of the chain, returning the id to revert to
+// it with evm_revert.
+func (api *PrivateDevAPI) Snapshot() hexutil.Uint64 {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	head := api.man.BlockChain().CurrentBlock()
+
+	// Keep the state of the snapshot in memory, the trie garbage collector would
+	// drop it once enough blocks are mined on top
+	api.man.BlockChain().StateCache().TrieDB().Reference(head.Root(), common.Hash{})
+
+	api.lastID++
+	api.snapshots = append(api.snapshots, &devSnapshot{
+		id:     api.lastID,
+		number: head.NumberU64(),
+		hash:   head.Hash(),
+		root:   head.Root(),
+		offset: api.offset,
+	})
+	return hexutil.Uint64(api.lastID)
+}
+
+// Revert rewinds the chain to the head saved by a snapshot, discarding all the
+// blocks mined since along with their transactions, and restores the clock
+// offset. The snapshot and all later ones are consumed. It returns false if the
+// snapshot is unknown.
+func (api *PrivateDevAPI) Revert(id DevQuantity) (bool, error) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	index := -1
+	for i, snap := range api.snapshots {
+		if snap.id == uint64(id) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return false, nil
+	}
+	snap, bc := api.snapshots[index], api.man.BlockChain()
+	if err := bc.SetHead(snap.number); err != nil {
+		return false, err
+	}
+	// Release the states of the consumed snapshots, apart from the new head
+	triedb := bc.StateCache().TrieDB()
+	for _, later := range api.snapshots[index+1:] {
+		triedb.Dereference(later.root, common.Hash{})
+	}
+	if api.pinned != (common.Hash{}) {
+		triedb.Dereference(api.pinned, common.Hash{})
+	}
+	api.pinned = snap.root
+	api.snapshots = api.snapshots[:index]
+	api.offset, api.next = snap.offset, 0
+
+	head := bc.CurrentBlock()
+	if head.Hash() != snap.hash {
+		return false, fmt.Errorf("rewound to block #%d [%x…] instead of snapshot [%x…]", head.NumberU64(), head.Hash().Bytes()[:4], snap.hash.Bytes()[:4])
+	}
+	// Reset the transaction pool and the other head followers
+	bc.PostChainEvents([]interface{}{core.ChainHeadEvent{Block: head}}, nil)
+
+	log.Info("Reverted developer chain", "snapshot", snap.id, "number", head.Number(), "hash", head.Hash())
+	return true, nil
+}
+
+// Mine mines a block with the pending transactions, timestamped as requested
+// if a timestamp is given.
+func (api *PrivateDevAPI) Mine(timestamp *DevQuantity) (string, error) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	if timestamp != nil {
+		if err := api.checkTimestamp(uint64(*timestamp)); err != nil {
+			return "", err
+		}
+		api.next = uint64(*timestamp)
+	}
+	if _, err := api.mine(); err != nil {
+		return "", err
+	}
+	return "0x0", nil
+}
+
+// IncreaseTime moves the clock used to timestamp blocks forward by the given
+// number of seconds, returning the total adjustment.
+func (api *PrivateDevAPI) IncreaseTime(seconds DevQuantity) int64 {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	api.offset += int64(seconds)
+	return api.offset
+}
+
+// SetNextBlockTimestamp forces the timestamp of the next block, which has to
+// be later than the one of the current head. Blocks after it are timestamped
+// relative to it.
+func (api *PrivateDevAPI) SetNextBlockTimestamp(timestamp DevQuantity) (uint64, error) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	if err := api.checkTimestamp(uint64(timestamp)); err != nil {
+		return 0, err
+	}
+	api.next = uint64(timestamp)
+	return api.next, nil
+}
+
+// SetAutomine sets whether a block is mined as soon as transactions arrive.
+func (api *PrivateDevAPI) SetAutomine(enabled bool) bool {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	api.automine = enabled
+	return true
+}
+
+// checkTimestamp ensures a requested timestamp is later than the current head.
+func (api *PrivateDevAPI) checkTimestamp(timestamp uint64) error {
+	if parent := api.man.BlockChain().CurrentBlock().Time().Uint64(); timestamp <= parent {
+		return fmt.Errorf("timestamp %d not later than the head's %d", timestamp, parent)
+	}
+	return nil
+}
+
+// mine assembles a block on top of the current head with the pending
+// transactions of the pool and inserts it into the chain. The caller must hold
+// the lock.
+func (api *PrivateDevAPI) mine() (*types.Block, error) {
+	var (
+		bc     = api.man.BlockChain()
+		engine = api.man.Engine()
+		parent = bc.CurrentBlock()
+		now    = time.Now().Unix()
+	)
+	tstamp := uint64(now + api.offset)
+	if api.next != 0 {
+		// Forced timestamps move the clock, later blocks continue from them
+		tstamp, api.next = api.next, 0
+		api.offset = int64(tstamp) - now
+	}
+	if tstamp <= parent.Time().Uint64() {
+		tstamp = parent.Time().Uint64() + 1
+	}
+	coinbase, _ := api.man.Etherbase()
+
+	header := &types.Header{
+		ParentHash:  parent.Hash(),
+		Leader:      coinbase,
+		Coinbase:    coinbase,
+		Number:      new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:    core.CalcGasLimit(parent),
+		Extra:       make([]byte, 0),
+		Time:        new(big.Int).SetUint64(tstamp),
+		NetTopology: parent.Header().NetTopology,
+		Signatures:  make([]common.Signature, 0),
+		Version:     parent.Header().Version,
+	}
+	if err := engine.Prepare(bc, header); err != nil {
+		return nil, err
+	}
+	work, err := matrixwork.NewWork(bc.Config(), bc, nil, header)
+	if err != nil {
+		return nil, err
+	}
+	txs := work.ProcessPendingTransactions(api.man.TxPool(), bc)
+	block, err := engine.Finalize(bc, header, work.State, txs, nil, work.Receipts)
+	if err != nil {
+		return nil, err
+	}
+	hash := block.Hash()
+	logs := work.State.Logs()
+	for _, l := range logs {
+		l.BlockHash = hash
+	}
+	stat, err := bc.WriteBlockWithState(block, work.Receipts, work.State)
+	if err != nil {
+		return nil, err
+	}
+	// The reverted to state isn't needed anymore once built upon
+	if api.pinned != (common.Hash{}) {
+		bc.StateCache().TrieDB().Dereference(api.pinned, common.Hash{})
+		api.pinned = common.Hash{}
+	}
+	events := []interface{}{core.ChainEvent{Block: block, Hash: hash, Logs: logs}}
+	if stat == core.CanonStatTy {
+		events = append(events, core.BalanceChangeEvent{Block: block, Changes: work.State.StopBalanceTracking()})
+		events = append(events, core.ChainHeadEvent{Block: block})
+	}
+	bc.PostChainEvents(events, logs)
+
+	log.Info("Mined developer block", "number", block.Number(), "hash", hash, "txs", len(txs), "time", tstamp)
+	return block, nil
+}
@@ -0,0 +1,199 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package man
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/consensus/manash"
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/core/vm"
+	"github.com/matrix/go-matrix/mandb"
+	"github.com/matrix/go-matrix/params"
+)
+
+// newTestDevAPI creates a developer mode API on top of a fresh chain, along with
+// a function releasing its chain, pool and pool directory.
+func newTestDevAPI(t *testing.T) (*PrivateDevAPI, func()) {
+	var (
+		db     = mandb.NewMemDatabase()
+		engine = manash.NewFaker()
+		gspec  = &core.Genesis{Config: params.TestChainConfig, GasLimit: 10000000}
+	)
+	gspec.MustCommit(db)
+
+	blockchain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "dev-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := core.NewTxPool(core.DefaultTxPoolConfig, gspec.Config, blockchain, dir+string(filepath.Separator))
+	teardown := func() {
+		pool.Stop()
+		blockchain.Stop()
+		os.RemoveAll(dir)
+	}
+	return NewPrivateDevAPI(&Matrix{
+		chainConfig: gspec.Config,
+		blockchain:  blockchain,
+		engine:      engine,
+		txPool:      pool,
+		manbase:     common.Address{0x01},
+	}), teardown
+}
+
+func TestDevQuantity(t *testing.T) {
+	tests := []struct {
+		input string
+		want  DevQuantity
+		fail  bool
+	}{
+		{input: `42`, want: 42},
+		{input: `"0x2a"`, want: 42},
+		{input: `"42"`, fail: true},
+		{input: `-1`, fail: true},
+	}
+	for i, tt := range tests {
+		var q DevQuantity
+		err := json.Unmarshal([]byte(tt.input), &q)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: %s accepted as %d", i, tt.input, q)
+			}
+			continue
+		}
+		if err != nil || q != tt.want {
+			t.Errorf("test %d: have %d (%v), want %d", i, q, err, tt.want)
+		}
+	}
+}
+
+func TestDevSnapshotRevert(t *testing.T) {
+	api, teardown := newTestDevAPI(t)
+	defer teardown()
+	bc := api.man.BlockChain()
+
+	mine := func(n int) {
+		for i := 0; i < n; i++ {
+			if _, err := api.Mine(nil); err != nil {
+				t.Fatalf("failed to mine block: %v", err)
+			}
+		}
+	}
+	mine(1)
+	first := api.Snapshot()
+	mine(2)
+	second := api.Snapshot()
+	mine(2)
+
+	if head := bc.CurrentBlock().NumberU64(); head != 5 {
+		t.Fatalf("head mismatch after mining: have #%d, want #5", head)
+	}
+	// Reverting to a snapshot rewinds the chain and consumes the later ones
+	if ok, err := api.Revert(DevQuantity(first)); !ok || err != nil {
+		t.Fatalf("failed to revert to snapshot %d: %v, %v", first, ok, err)
+	}
+	if head := bc.CurrentBlock().NumberU64(); head != 1 {
+		t.Fatalf("head mismatch after revert: have #%d, want #1", head)
+	}
+	if ok, _ := api.Revert(DevQuantity(second)); ok {
+		t.Error("reverted to a snapshot taken after the reverted to one")
+	}
+	if ok, _ := api.Revert(DevQuantity(first)); ok {
+		t.Error("reverted to a consumed snapshot")
+	}
+	if ok, _ := api.Revert(1000); ok {
+		t.Error("reverted to an unknown snapshot")
+	}
+	// The chain can be built upon after a revert
+	mine(1)
+	if head := bc.CurrentBlock().NumberU64(); head != 2 {
+		t.Fatalf("head mismatch after mining on the reverted chain: have #%d, want #2", head)
+	}
+}
+
+func TestDevTimestamps(t *testing.T) {
+	api, teardown := newTestDevAPI(t)
+	defer teardown()
+	bc := api.man.BlockChain()
+
+	now := uint64(time.Now().Unix())
+	headTime := func() uint64 { return bc.CurrentBlock().Time().Uint64() }
+
+	// Forced timestamps have to be later than the head
+	if _, err := api.SetNextBlockTimestamp(DevQuantity(headTime())); err == nil {
+		t.Error("timestamp of the head accepted for the next block")
+	}
+	next := now + 1000
+	if have, err := api.SetNextBlockTimestamp(DevQuantity(next)); err != nil || have != next {
+		t.Fatalf("failed to set next timestamp: have %d, %v", have, err)
+	}
+	if _, err := api.Mine(nil); err != nil {
+		t.Fatalf("failed to mine block: %v", err)
+	}
+	if have := headTime(); have != next {
+		t.Fatalf("forced timestamp mismatch: have %d, want %d", have, next)
+	}
+	// Later blocks continue from the forced timestamp
+	if _, err := api.Mine(nil); err != nil {
+		t.Fatalf("failed to mine block: %v", err)
+	}
+	if have := headTime(); have <= next || have > next+60 {
+		t.Errorf("block after forced timestamp: have %d, want just after %d", have, next)
+	}
+	// Increasing the time moves the following blocks forward
+	snap := api.Snapshot()
+	offset := api.IncreaseTime(0)
+	if have := api.IncreaseTime(3600); have != offset+3600 {
+		t.Errorf("clock offset mismatch: have %d, want %d", have, offset+3600)
+	}
+	before := headTime()
+	if _, err := api.Mine(nil); err != nil {
+		t.Fatalf("failed to mine block: %v", err)
+	}
+	if have := headTime(); have < next+3600 {
+		t.Errorf("increased time not applied: have %d, want at least %d", have, next+3600)
+	}
+	// Mining with an explicit timestamp forces it like the setter
+	explicit := DevQuantity(headTime() + 100)
+	if _, err := api.Mine(&explicit); err != nil {
+		t.Fatalf("failed to mine block with timestamp: %v", err)
+	}
+	if have := headTime(); have != uint64(explicit) {
+		t.Errorf("explicit timestamp mismatch: have %d, want %d", have, explicit)
+	}
+	past := DevQuantity(before)
+	if _, err := api.Mine(&past); err == nil {
+		t.Error("mined a block timestamped before the head")
+	}
+	// Reverting restores the clock offset of the snapshot
+	if ok, err := api.Revert(DevQuantity(snap)); !ok || err != nil {
+		t.Fatalf("failed to revert: %v, %v", ok, err)
+	}
+	if have := api.IncreaseTime(0); have != offset {
+		t.Errorf("clock offset after revert: have %d, want %d", have, offset)
+	}
+}
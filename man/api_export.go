@@ -0,0 +1,210 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package man
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/matrix/go-matrix/core/state/export"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+// StateExportStatus is the result of a debug_stateExportStatus API call.
+type StateExportStatus struct {
+	export.Progress
+	Dir     string `json:"dir"`
+	Running bool   `json:"running"`
+	Error   string `json:"error,omitempty"` // Reason the last run stopped, empty unless it failed
+}
+
+// stateExport is a state export run in the background
+type stateExport struct {
+	exporter *export.Exporter
+	quit     chan struct{}
+	running  bool
+	err      error
+}
+
+// stateExports tracks the state exports of the node by output directory.
+type stateExports struct {
+	exports map[string]*stateExport
+	wg      sync.WaitGroup
+	lock    sync.Mutex
+}
+
+func newStateExports() *stateExports {
+	return &stateExports{exports: make(map[string]*stateExport)}
+}
+
+// start runs the export in the background unless one is already running into
+// the same directory.
+func (s *stateExports) start(dir string, exporter *export.Exporter) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if e := s.exports[dir]; e != nil && e.running {
+		return fmt.Errorf("state export into %s already running", dir)
+	}
+	e := &stateExport{exporter: exporter, quit: make(chan struct{}), running: true}
+	s.exports[dir] = e
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		err := exporter.Run(e.quit)
+		progress := exporter.Progress()
+		if err != nil {
+			log.Warn("State export stopped", "dir", dir, "root", progress.Root, "accounts", progress.Accounts, "slots", progress.Slots, "err", err)
+		} else {
+			log.Info("State export done", "dir", dir, "root", progress.Root, "accounts", progress.Accounts, "slots", progress.Slots, "chunks", progress.Chunks)
+		}
+		s.lock.Lock()
+		e.running, e.err = false, err
+		s.lock.Unlock()
+	}()
+	return nil
+}
+
+// status returns the status of the export into dir, nil if the node hasn't
+// run any.
+func (s *stateExports) status(dir string) *StateExportStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	e := s.exports[dir]
+	if e == nil {
+		return nil
+	}
+	status := &StateExportStatus{Progress: e.exporter.Progress(), Dir: dir, Running: e.running}
+	if e.err != nil {
+		status.Error = e.err.Error()
+	}
+	return status
+}
+
+// abort stops the export into dir, reporting whether one was running.
+func (s *stateExports) abort(dir string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	e := s.exports[dir]
+	if e == nil || !e.running {
+		return false
+	}
+	select {
+	case <-e.quit:
+	default:
+		close(e.quit)
+	}
+	return true
+}
+
+// stop aborts all running exports and waits for them to return.
+func (s *stateExports) stop() {
+	s.lock.Lock()
+	for dir := range s.exports {
+		e := s.exports[dir]
+		if e.running {
+			select {
+			case <-e.quit:
+			default:
+				close(e.quit)
+			}
+		}
+	}
+	s.lock.Unlock()
+	s.wg.Wait()
+}
+
+// ExportState starts exporting the state of a block into chunks in dir,
+// resuming an earlier export into the same dir. The export runs in the
+// background reading the state concurrently with block import, its progress
+// can be followed with debug_stateExportStatus. The state of the block must
+// not be pruned while it runs; a failed export can be resumed by calling
+// ExportState again.
+func (api *PrivateDebugAPI) ExportState(blockNr rpc.BlockNumber, dir string, chunkSize *int) (*StateExportStatus, error) {
+	if blockNr == rpc.PendingBlockNumber {
+		return nil, errors.New("pending state can't be exported")
+	}
+	var block *types.Block
+	if blockNr == rpc.LatestBlockNumber {
+		block = api.man.blockchain.CurrentBlock()
+	} else {
+		block = api.man.blockchain.GetBlockByNumber(uint64(blockNr))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNr)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	size := export.DefaultChunkSize
+	if chunkSize != nil {
+		size = *chunkSize
+	}
+	exporter, err := export.New(api.man.BlockChain().StateCache(), block.Root(), dir, size)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.man.stateExports.start(dir, exporter); err != nil {
+		return nil, err
+	}
+	return api.man.stateExports.status(dir), nil
+}
+
+// StateExportStatus returns the progress of the state export into dir, either
+// run by the node or left on disk by an earlier run.
+func (api *PrivateDebugAPI) StateExportStatus(dir string) (*StateExportStatus, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if status := api.man.stateExports.status(dir); status != nil {
+		return status, nil
+	}
+	manifest, err := export.ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &StateExportStatus{
+		Progress: export.Progress{
+			Root:     manifest.Root,
+			Chunks:   len(manifest.Chunks),
+			Accounts: manifest.Accounts,
+			Slots:    manifest.Slots,
+			Done:     manifest.Done,
+		},
+		Dir: dir,
+	}, nil
+}
+
+// AbortStateExport stops the state export running into dir. The chunks
+// written so far are kept and the export can be resumed later.
+func (api *PrivateDebugAPI) AbortStateExport(dir string) (bool, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+	return api.man.stateExports.abort(dir), nil
+}
@@ -0,0 +1,277 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package man
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core/state"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/rlp"
+	"github.com/matrix/go-matrix/rpc"
+	"github.com/matrix/go-matrix/trie"
+)
+
+const (
+	// storageCheckInterval is the number of storage slots walked between two
+	// checks for cancellation and progress reporting.
+	storageCheckInterval = 4096
+
+	// storageProgressInterval is the minimum time between two progress
+	// notifications of a streamed storage walk.
+	storageProgressInterval = 3 * time.Second
+
+	// defaultStorageIterateLimit is the number of slots returned by a storage
+	// iteration if the request doesn't specify a limit.
+	defaultStorageIterateLimit = 256
+
+	// maxStorageIterateLimit is the maximum number of slots returned by a single
+	// storage iteration request.
+	maxStorageIterateLimit = 4096
+)
+
+// StorageStats is the result of a debug_storageSize API call, or a progress
+// notification of its streamed variant.
+type StorageStats struct {
+	Address      common.Address `json:"address"`
+	StorageRoot  common.Hash    `json:"storageRoot"`
+	Slots        uint64         `json:"slots"`        // Number of non-empty storage slots
+	StorageBytes uint64         `json:"storageBytes"` // Total size of the slot values, leading zeroes trimmed as stored
+	Complete     bool           `json:"complete"`     // False for intermediate progress notifications
+}
+
+// StorageSlot is a single slot of a contract storage.
+type StorageSlot struct {
+	Hash  common.Hash  `json:"hash"`          // Hashed slot key, determining the iteration order
+	Key   *common.Hash `json:"key,omitempty"` // Slot key, nil if its preimage is unknown
+	Value common.Hash  `json:"value"`
+}
+
+// StorageIteration is the result of a debug_iterateStorage API call.
+type StorageIteration struct {
+	StorageRoot common.Hash   `json:"storageRoot"`
+	Slots       []StorageSlot `json:"slots"`
+	Next        *common.Hash  `json:"next"` // Start key of the next page, nil once the storage is exhausted
+}
+
+// CodeStats is the result of a debug_codeStats API call.
+type CodeStats struct {
+	Address  common.Address `json:"address"`
+	CodeHash common.Hash    `json:"codeHash"`
+	CodeSize int            `json:"codeSize"`
+	Contract bool           `json:"contract"` // Whether the account has any code deployed
+}
+
+// StorageSize walks the storage trie of a contract at the given block and
+// returns the number of slots and bytes it holds. The walk is aborted if the
+// request is cancelled; huge contracts are better inspected through the
+// storageSizeProgress subscription.
+func (api *PrivateDebugAPI) StorageSize(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*StorageStats, error) {
+	statedb, err := api.stateAtNumber(blockNr)
+	if err != nil {
+		return nil, err
+	}
+	st := statedb.StorageTrie(address)
+	if st == nil {
+		return nil, fmt.Errorf("account %x doesn't exist", address)
+	}
+	return walkStorage(address, st, func() bool { return ctx.Err() != nil }, nil)
+}
+
+// StorageSizeProgress is the streamed variant of StorageSize, notifying the
+// partial counts every few seconds and the final result at the end of the walk.
+func (api *PrivateDebugAPI) StorageSizeProgress(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	statedb, err := api.stateAtNumber(blockNr)
+	if err != nil {
+		return nil, err
+	}
+	st := statedb.StorageTrie(address)
+	if st == nil {
+		return nil, fmt.Errorf("account %x doesn't exist", address)
+	}
+	sub := notifier.CreateSubscription()
+
+	go func() {
+		begin := time.Now()
+		aborted := func() bool {
+			select {
+			case <-notifier.Closed():
+				return true
+			default:
+				return false
+			}
+		}
+		stats, err := walkStorage(address, st, aborted, func(progress *StorageStats) {
+			notifier.Notify(sub.ID, progress)
+		})
+		if err != nil {
+			log.Warn("Storage walk failed", "address", address, "elapsed", time.Since(begin), "err", err)
+			return
+		}
+		log.Info("Storage walk finished", "address", address, "slots", stats.Slots, "bytes", stats.StorageBytes, "elapsed", time.Since(begin))
+		notifier.Notify(sub.ID, stats)
+	}()
+	return sub, nil
+}
+
+// IterateStorage returns up to limit storage slots of a contract at the given
+// block, in the order of their hashed keys and starting at startKey. Passing the
+// returned cursor as the next startKey continues the iteration, so tools can
+// enumerate huge storages incrementally.
+func (api *PrivateDebugAPI) IterateStorage(ctx context.Context, address common.Address, startKey hexutil.Bytes, limit int, blockNr rpc.BlockNumber) (*StorageIteration, error) {
+	switch {
+	case limit == 0:
+		limit = defaultStorageIterateLimit
+	case limit < 0 || limit > maxStorageIterateLimit:
+		return nil, fmt.Errorf("invalid limit %d, must be within [1, %d]", limit, maxStorageIterateLimit)
+	}
+	if len(startKey) > common.HashLength {
+		return nil, fmt.Errorf("start key too long: %d bytes", len(startKey))
+	}
+	statedb, err := api.stateAtNumber(blockNr)
+	if err != nil {
+		return nil, err
+	}
+	st := statedb.StorageTrie(address)
+	if st == nil {
+		return nil, fmt.Errorf("account %x doesn't exist", address)
+	}
+	return iterateStorage(st, startKey, limit)
+}
+
+// CodeStats returns the size and hash of the code deployed at the given
+// address and block.
+func (api *PrivateDebugAPI) CodeStats(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*CodeStats, error) {
+	statedb, err := api.stateAtNumber(blockNr)
+	if err != nil {
+		return nil, err
+	}
+	if !statedb.Exist(address) {
+		return nil, fmt.Errorf("account %x doesn't exist", address)
+	}
+	size := statedb.GetCodeSize(address)
+	return &CodeStats{
+		Address:  address,
+		CodeHash: statedb.GetCodeHash(address),
+		CodeSize: size,
+		Contract: size > 0,
+	}, statedb.Error()
+}
+
+// stateAtNumber retrieves the state of the given block, resolving the pending
+// and latest block numbers.
+func (api *PrivateDebugAPI) stateAtNumber(blockNr rpc.BlockNumber) (*state.StateDB, error) {
+	if blockNr == rpc.PendingBlockNumber {
+		_, statedb := api.man.miner.Pending()
+		return statedb, nil
+	}
+	var block *types.Block
+	if blockNr == rpc.LatestBlockNumber {
+		block = api.man.blockchain.CurrentBlock()
+	} else {
+		block = api.man.blockchain.GetBlockByNumber(uint64(blockNr))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNr)
+	}
+	return api.man.BlockChain().StateAt(block.Root())
+}
+
+// walkStorage iterates over all slots of a storage trie, counting them along
+// with the size of their values. If progress is non-nil, it is invoked with
+// the partial counts at most every storageProgressInterval. The walk fails
+// once aborted reports true.
+func walkStorage(address common.Address, st state.Trie, aborted func() bool, progress func(*StorageStats)) (*StorageStats, error) {
+	stats := &StorageStats{
+		Address:     address,
+		StorageRoot: st.Hash(),
+	}
+	var (
+		it     = trie.NewIterator(st.NodeIterator(nil))
+		logged = time.Now()
+	)
+	for it.Next() {
+		// Slot values are stored as RLP strings, only count their content
+		content, _, err := rlp.SplitString(it.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage slot %x: %v", it.Key, err)
+		}
+		stats.Slots++
+		stats.StorageBytes += uint64(len(content))
+
+		if stats.Slots%storageCheckInterval != 0 {
+			continue
+		}
+		if aborted() {
+			return nil, fmt.Errorf("storage walk aborted after %d slots", stats.Slots)
+		}
+		if progress != nil && time.Since(logged) > storageProgressInterval {
+			partial := *stats
+			progress(&partial)
+			logged = time.Now()
+		}
+	}
+	if it.Err != nil {
+		return nil, it.Err
+	}
+	stats.Complete = true
+	return stats, nil
+}
+
+// iterateStorage collects up to limit slots of a storage trie, starting at the
+// given hashed key. The trie iterator seeks straight to the start position, so
+// the cost of a page doesn't depend on how far into the storage it is.
+func iterateStorage(st state.Trie, start []byte, limit int) (*StorageIteration, error) {
+	result := &StorageIteration{
+		StorageRoot: st.Hash(),
+		Slots:       []StorageSlot{},
+	}
+	it := trie.NewIterator(st.NodeIterator(start))
+	for len(result.Slots) < limit && it.Next() {
+		content, _, err := rlp.SplitString(it.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage slot %x: %v", it.Key, err)
+		}
+		slot := StorageSlot{
+			Hash:  common.BytesToHash(it.Key),
+			Value: common.BytesToHash(content),
+		}
+		if preimage := st.GetKey(it.Key); preimage != nil {
+			key := common.BytesToHash(preimage)
+			slot.Key = &key
+		}
+		result.Slots = append(result.Slots, slot)
+	}
+	// Report the following slot as cursor for the next page
+	if it.Next() {
+		next := common.BytesToHash(it.Key)
+		result.Next = &next
+	}
+	if it.Err != nil {
+		return nil, it.Err
+	}
+	return result, nil
+}
@@ -17,6 +17,8 @@
 package man
 
 import (
+	"bytes"
+	"math/big"
 	"reflect"
 	"testing"
 
@@ -88,3 +90,88 @@ func TestStorageRangeAt(t *testing.T) {
 		}
 	}
 }
+
+func TestWalkStorage(t *testing.T) {
+	var (
+		state, _ = state.New(common.Hash{}, state.NewDatabase(mandb.NewMemDatabase()))
+		addr     = common.Address{0x01}
+		slots    = uint64(2*storageCheckInterval + 10)
+	)
+	// Values 1..n, stored without their leading zeroes
+	var size uint64
+	for i := uint64(1); i <= slots; i++ {
+		value := common.BigToHash(new(big.Int).SetUint64(i))
+		state.SetState(addr, common.BigToHash(new(big.Int).SetUint64(i)), value)
+		size += uint64(len(new(big.Int).SetUint64(i).Bytes()))
+	}
+	stats, err := walkStorage(addr, state.StorageTrie(addr), func() bool { return false }, nil)
+	if err != nil {
+		t.Fatalf("storage walk failed: %v", err)
+	}
+	if !stats.Complete || stats.Slots != slots || stats.StorageBytes != size {
+		t.Fatalf("stats mismatch: have %d slots, %d bytes, complete %v; want %d slots, %d bytes", stats.Slots, stats.StorageBytes, stats.Complete, slots, size)
+	}
+	if _, err := walkStorage(addr, state.StorageTrie(addr), func() bool { return true }, nil); err == nil {
+		t.Fatalf("aborted storage walk succeeded")
+	}
+}
+
+func TestIterateStorage(t *testing.T) {
+	var (
+		state, _ = state.New(common.Hash{}, state.NewDatabase(mandb.NewMemDatabase()))
+		addr     = common.Address{0x01}
+		slots    = 100
+	)
+	for i := 1; i <= slots; i++ {
+		state.SetState(addr, common.BigToHash(big.NewInt(int64(i))), common.BigToHash(big.NewInt(int64(2*i))))
+	}
+	st := state.StorageTrie(addr)
+
+	// Page through the storage, checking order and continuity of the pages
+	var (
+		start []byte
+		seen  []StorageSlot
+	)
+	for pages := 0; ; pages++ {
+		if pages > slots {
+			t.Fatalf("iteration didn't terminate")
+		}
+		page, err := iterateStorage(st, start, 7)
+		if err != nil {
+			t.Fatalf("page %d: iteration failed: %v", pages, err)
+		}
+		if page.StorageRoot != st.Hash() {
+			t.Fatalf("page %d: storage root mismatch: have %x, want %x", pages, page.StorageRoot, st.Hash())
+		}
+		if page.Next != nil && len(page.Slots) != 7 {
+			t.Fatalf("page %d: short page with cursor: %d slots", pages, len(page.Slots))
+		}
+		seen = append(seen, page.Slots...)
+		if page.Next == nil {
+			break
+		}
+		start = page.Next.Bytes()
+	}
+	if len(seen) != slots {
+		t.Fatalf("slot count mismatch: have %d, want %d", len(seen), slots)
+	}
+	for i, slot := range seen {
+		if i > 0 && bytes.Compare(seen[i-1].Hash[:], slot.Hash[:]) >= 0 {
+			t.Errorf("slot %d: out of order: %x after %x", i, slot.Hash, seen[i-1].Hash)
+		}
+		if slot.Key == nil {
+			t.Fatalf("slot %d: missing preimage", i)
+		}
+		if want := common.BigToHash(new(big.Int).Mul(slot.Key.Big(), big.NewInt(2))); slot.Value != want {
+			t.Errorf("slot %d: value mismatch: have %x, want %x", i, slot.Value, want)
+		}
+	}
+	// A start key past the last slot yields an empty final page
+	page, err := iterateStorage(st, bytes.Repeat([]byte{0xff}, common.HashLength), 7)
+	if err != nil {
+		t.Fatalf("iteration failed: %v", err)
+	}
+	if page.Next != nil || len(page.Slots) != 0 {
+		t.Fatalf("unexpected slots past the end: %d, cursor %v", len(page.Slots), page.Next)
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package man
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core/rawdb"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+// errTraceIndexDisabled is returned by the trace queries if the node doesn't
+// index call frames.
+var errTraceIndexDisabled = errors.New("trace index disabled")
+
+// traceFailedError is reported for the frames which didn't succeed, the index
+// doesn't record the cause.
+const traceFailedError = "execution failed"
+
+// TraceFilterArgs are the arguments of a trace_filter API call. Frames match if
+// their caller is one of the from addresses and their callee one of the to
+// addresses, empty lists matching any address.
+type TraceFilterArgs struct {
+	FromBlock   *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock     *rpc.BlockNumber `json:"toBlock"`
+	FromAddress []common.Address `json:"fromAddress"`
+	ToAddress   []common.Address `json:"toAddress"`
+	After       *uint64          `json:"after"` // Number of matching traces to skip
+	Count       *uint64          `json:"count"` // Maximum number of traces returned
+}
+
+// TraceAction describes the operation of a call frame. Calls and creations
+// fill in the sender and value, self-destructs the destroyed account along
+// with the beneficiary of its balance.
+type TraceAction struct {
+	CallType      string          `json:"callType,omitempty"`
+	From          *common.Address `json:"from,omitempty"`
+	To            *common.Address `json:"to,omitempty"`
+	Value         *hexutil.Big    `json:"value,omitempty"`
+	Address       *common.Address `json:"address,omitempty"`
+	RefundAddress *common.Address `json:"refundAddress,omitempty"`
+	Balance       *hexutil.Big    `json:"balance,omitempty"`
+}
+
+// TraceResult is the outcome of a successful call frame.
+type TraceResult struct {
+	Address *common.Address `json:"address,omitempty"` // Created contract
+}
+
+// Trace is a call frame returned by trace_filter.
+type Trace struct {
+	Action              TraceAction  `json:"action"`
+	BlockHash           common.Hash  `json:"blockHash"`
+	BlockNumber         uint64       `json:"blockNumber"`
+	Result              *TraceResult `json:"result"`
+	Error               string       `json:"error,omitempty"`
+	Subtraces           uint64       `json:"subtraces"`
+	TraceAddress        []uint64     `json:"traceAddress"`
+	TransactionHash     common.Hash  `json:"transactionHash"`
+	TransactionPosition uint64       `json:"transactionPosition"`
+	Type                string       `json:"type"`
+}
+
+// TraceInteraction is a transaction returned by trace_interactions.
+type TraceInteraction struct {
+	BlockHash           common.Hash    `json:"blockHash"`
+	BlockNumber         hexutil.Uint64 `json:"blockNumber"`
+	TransactionHash     common.Hash    `json:"transactionHash"`
+	TransactionPosition hexutil.Uint64 `json:"transactionPosition"`
+	Sent                bool           `json:"sent"`     // Address is the caller of a frame
+	Received            bool           `json:"received"` // Address is the callee of a frame
+}
+
+// PublicTraceAPI serves historical traces out of the tracing index, without
+// re-executing the blocks.
+type PublicTraceAPI struct {
+	man *Matrix
+}
+
+// NewPublicTraceAPI creates a new trace API.
+func NewPublicTraceAPI(man *Matrix) *PublicTraceAPI {
+	return &PublicTraceAPI{man: man}
+}
+
+// Filter returns the call frames of the canonical blocks within a range which
+// match the addresses of the filter, in execution order.
+func (api *PublicTraceAPI) Filter(args TraceFilterArgs) ([]*Trace, error) {
+	from, to, err := api.resolveRange(args.FromBlock, args.ToBlock)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		senders   = make(map[common.Address]bool)
+		receivers = make(map[common.Address]bool)
+	)
+	for _, addr := range args.FromAddress {
+		senders[addr] = true
+	}
+	for _, addr := range args.ToAddress {
+		receivers[addr] = true
+	}
+	// Only visit the blocks involving the addresses if the filter has any
+	var candidates []uint64
+	switch {
+	case len(args.FromAddress) > 0:
+		candidates = api.interactingBlocks(args.FromAddress, from, to)
+	case len(args.ToAddress) > 0:
+		candidates = api.interactingBlocks(args.ToAddress, from, to)
+	default:
+		for number := from; number <= to; number++ {
+			candidates = append(candidates, number)
+		}
+	}
+	var (
+		index  = api.man.BlockChain().TraceIndex()
+		skip   uint64
+		traces = []*Trace{}
+	)
+	if args.After != nil {
+		skip = *args.After
+	}
+	for _, number := range candidates {
+		hash := rawdb.ReadCanonicalHash(api.man.ChainDb(), number)
+		for i, tx := range index.Frames(hash, number) {
+			for _, frame := range tx.Frames {
+				if len(senders) > 0 && !senders[frame.From] {
+					continue
+				}
+				if len(receivers) > 0 && !receivers[frame.To] {
+					continue
+				}
+				if skip > 0 {
+					skip--
+					continue
+				}
+				if args.Count != nil && uint64(len(traces)) >= *args.Count {
+					return traces, nil
+				}
+				traces = append(traces, newTrace(frame, hash, number, tx.TxHash, uint64(i)))
+			}
+		}
+	}
+	return traces, nil
+}
+
+// Interactions returns the transactions of the canonical blocks within a range
+// with a call frame sent from or to an address.
+func (api *PublicTraceAPI) Interactions(address common.Address, fromBlock, toBlock *rpc.BlockNumber) ([]*TraceInteraction, error) {
+	from, to, err := api.resolveRange(fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		index        = api.man.BlockChain().TraceIndex()
+		interactions = []*TraceInteraction{}
+	)
+	for _, entry := range index.Interactions(address, from, to) {
+		for i, tx := range index.Frames(entry.Hash, entry.Number) {
+			interaction := &TraceInteraction{
+				BlockHash:           entry.Hash,
+				BlockNumber:         hexutil.Uint64(entry.Number),
+				TransactionHash:     tx.TxHash,
+				TransactionPosition: hexutil.Uint64(i),
+			}
+			for _, frame := range tx.Frames {
+				interaction.Sent = interaction.Sent || frame.From == address
+				interaction.Received = interaction.Received || frame.To == address
+			}
+			if interaction.Sent || interaction.Received {
+				interactions = append(interactions, interaction)
+			}
+		}
+	}
+	return interactions, nil
+}
+
+// resolveRange converts a block range of a query into numbers, both ends
+// defaulting to the current head, and checks it is covered by the index.
+func (api *PublicTraceAPI) resolveRange(fromBlock, toBlock *rpc.BlockNumber) (uint64, uint64, error) {
+	index := api.man.BlockChain().TraceIndex()
+	if index == nil {
+		return 0, 0, errTraceIndexDisabled
+	}
+	head := api.man.BlockChain().CurrentBlock().NumberU64()
+	resolve := func(number *rpc.BlockNumber) uint64 {
+		if number == nil || *number < 0 || uint64(*number) > head {
+			return head
+		}
+		return uint64(*number)
+	}
+	from, to := resolve(fromBlock), resolve(toBlock)
+	if from > to {
+		return 0, 0, fmt.Errorf("invalid block range #%d-#%d", from, to)
+	}
+	if tail := index.Tail(); from < tail {
+		return 0, 0, fmt.Errorf("blocks before #%d not indexed, run gman trace-backfill", tail)
+	}
+	return from, to, nil
+}
+
+// interactingBlocks returns the canonical blocks within [from, to] containing
+// call frames of any of the given addresses, in increasing order.
+func (api *PublicTraceAPI) interactingBlocks(addrs []common.Address, from, to uint64) []uint64 {
+	var (
+		index   = api.man.BlockChain().TraceIndex()
+		seen    = make(map[uint64]bool)
+		numbers []uint64
+	)
+	for _, addr := range addrs {
+		for _, entry := range index.Interactions(addr, from, to) {
+			if !seen[entry.Number] {
+				seen[entry.Number] = true
+				numbers = append(numbers, entry.Number)
+			}
+		}
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	return numbers
+}
+
+// newTrace converts an indexed call frame into the trace format of the API.
+func newTrace(frame *types.CallFrame, hash common.Hash, number uint64, txHash common.Hash, position uint64) *Trace {
+	var (
+		from  = frame.From
+		to    = frame.To
+		value = (*hexutil.Big)(frame.Value)
+	)
+	trace := &Trace{
+		BlockHash:           hash,
+		BlockNumber:         number,
+		Subtraces:           frame.Subtraces,
+		TraceAddress:        frame.TraceAddress,
+		TransactionHash:     txHash,
+		TransactionPosition: position,
+	}
+	switch frame.Type {
+	case types.CallFrameCreate:
+		trace.Type = "create"
+		trace.Action = TraceAction{From: &from, Value: value}
+		trace.Result = &TraceResult{Address: &to}
+	case types.CallFrameSelfDestruct:
+		trace.Type = "suicide"
+		trace.Action = TraceAction{Address: &from, RefundAddress: &to, Balance: value}
+	default:
+		trace.Type = "call"
+		trace.Action = TraceAction{CallType: frame.Type, From: &from, To: &to, Value: value}
+		trace.Result = &TraceResult{}
+	}
+	if frame.Failed {
+		trace.Result, trace.Error = nil, traceFailedError
+	}
+	return trace
+}
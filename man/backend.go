@@ -45,11 +45,15 @@ import (
 	"github.com/matrix/go-matrix/core"
 	"github.com/matrix/go-matrix/core/bloombits"
 	"github.com/matrix/go-matrix/core/rawdb"
+	"github.com/matrix/go-matrix/core/state"
 	"github.com/matrix/go-matrix/core/types"
 	"github.com/matrix/go-matrix/core/vm"
+	"github.com/matrix/go-matrix/crypto/kzg"
 	"github.com/matrix/go-matrix/depoistInfo"
 	"github.com/matrix/go-matrix/man/downloader"
 	"github.com/matrix/go-matrix/man/filters"
+	"github.com/matrix/go-matrix/man/blobs"
+	"github.com/matrix/go-matrix/man/forkmon"
 	"github.com/matrix/go-matrix/man/gasprice"
 	"github.com/matrix/go-matrix/mandb"
 	"github.com/matrix/go-matrix/event"
@@ -59,6 +63,7 @@ import (
 	"github.com/matrix/go-matrix/miner"
 	"github.com/matrix/go-matrix/node"
 	"github.com/matrix/go-matrix/p2p"
+	"github.com/matrix/go-matrix/p2p/discover"
 	"github.com/matrix/go-matrix/params"
 	"github.com/matrix/go-matrix/rlp"
 	"github.com/matrix/go-matrix/rpc"
@@ -77,6 +82,14 @@ type LesServer interface {
 	SetBloomBitsIndexer(bbIndexer *core.ChainIndexer)
 }
 
+// historyStateCacheSize is the number of account and storage slot values read
+// from historical states kept on archive nodes.
+const historyStateCacheSize = 1 << 17
+
+// callStatsSize is the number of man_call and man_sendRawTransaction requests
+// whose targets are kept for debug_callStats.
+const callStatsSize = 8192
+
 // Matrix implements the Matrix full node service.
 type Matrix struct {
 	config      *Config
@@ -109,6 +122,14 @@ type Matrix struct {
 
 	networkId     uint64
 	netRPCService *manapi.PublicNetAPI
+	rpcCache      *rpc.ResponseCache // Node wide RPC response cache (nil = disabled)
+	proofCache    *manapi.ProofCache // Recently generated account and storage proofs
+	callStats     *manapi.CallStats  // Targets of the latest calls and raw transactions
+	historyState  state.Database     // State database caching reads of historical states (nil = not archive)
+	forkMonitor   *forkmon.Monitor   // Peer branch tracker alerting on chain splits (nil = disabled)
+	blobHandler   *blobs.Handler     // Gossip and store of transaction blobs (nil = disabled)
+	devAPI        *PrivateDevAPI     // Snapshot and time travel RPCs of the developer chain (nil = not in dev mode)
+	stateExports  *stateExports      // State exports running in the background
 
 	broadTx *broadcastTx.BroadCast //YY
 
@@ -151,6 +172,9 @@ func New(ctx *node.ServiceContext, config *Config) (*Matrix, error) {
 		return nil, genesisErr
 	}
 	log.Info("Initialised chain configuration", "config", chainConfig)
+	if err := setupKZG(config, chainConfig); err != nil {
+		return nil, err
+	}
 
 	man := &Matrix{
 		config:         config,
@@ -162,6 +186,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Matrix, error) {
 		msgcenter:      ctx.MsgCenter,
 		hd:             ctx.HD,
 		signHelper:     ctx.SignHelper,
+		rpcCache:       ctx.RPCCache,
 
 		engine:        CreateConsensusEngine(ctx, &config.Ethash, chainConfig, chainDb),
 		shutdownChan:  make(chan bool),
@@ -170,8 +195,13 @@ func New(ctx *node.ServiceContext, config *Config) (*Matrix, error) {
 		manbase:     config.Etherbase,
 		bloomRequests: make(chan chan *bloombits.Retrieval),
 		bloomIndexer:  NewBloomIndexer(chainDb, params.BloomBitsBlocks),
+		stateExports:  newStateExports(),
+		callStats:     manapi.NewCallStats(callStatsSize),
 	}
 	log.Info("Initialising Matrix protocol", "versions", ProtocolVersions, "network", config.NetworkId)
+	if man.proofCache, err = manapi.NewProofCache(proofCacheSize, proofCacheBlocks); err != nil {
+		return nil, err
+	}
 
 	if !config.SkipBcVersionCheck {
 		bcVersion := rawdb.ReadDatabaseVersion(chainDb)
@@ -181,19 +211,40 @@ func New(ctx *node.ServiceContext, config *Config) (*Matrix, error) {
 		rawdb.WriteDatabaseVersion(chainDb, core.BlockChainVersion)
 	}
 	var (
-		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
+		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording, ParallelTxs: config.ParallelTxs}
 		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
 	)
 	man.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, man.chainConfig, man.engine, vmConfig)
 	if err != nil {
 		return nil, err
 	}
+	if config.NoPruning {
+		if man.historyState, err = state.NewReadCachingDatabase(man.blockchain.StateCache(), historyStateCacheSize); err != nil {
+			return nil, err
+		}
+	}
+	if config.FutureBlockDrift != nil {
+		man.blockchain.SetFutureBlockDrift(*config.FutureBlockDrift)
+	}
+	if config.StateAccessIndex {
+		man.blockchain.EnableStateAccessIndex()
+	}
+	if config.TraceIndex {
+		man.blockchain.EnableTraceIndex()
+	}
 	// Rewind the chain in case of an incompatible config upgrade.
 	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
 		log.Warn("Rewinding chain to upgrade configuration", "err", compat)
 		man.blockchain.SetHead(compat.RewindTo)
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
+	// Leave the branches without the pinned block, if any
+	if config.PinnedHead != nil {
+		if err := man.blockchain.SetPinnedBlock(config.PinnedHead); err != nil {
+			return nil, err
+		}
+		log.Info("Pinned chain to block", "number", config.PinnedHead.Number, "hash", config.PinnedHead.Hash)
+	}
 	man.bloomIndexer.Start(man.blockchain)
 
 	if config.TxPool.Journal != "" {
@@ -201,12 +252,22 @@ func New(ctx *node.ServiceContext, config *Config) (*Matrix, error) {
 	}
 	man.txPool = core.NewTxPool(config.TxPool, man.chainConfig, man.blockchain, ctx.GetConfig().DataDir)
 
-	if man.protocolManager, err = NewProtocolManager(man.chainConfig, config.SyncMode, config.NetworkId, man.eventMux, man.txPool, man.engine, man.blockchain, chainDb, ctx.MsgCenter); err != nil {
+	if man.protocolManager, err = NewProtocolManager(man.chainConfig, config.SyncMode, config.NetworkId, man.txPool, man.engine, man.blockchain, chainDb, ctx.MsgCenter); err != nil {
 		return nil, err
 	}
+	man.protocolManager.propagation = config.Propagation.sanitize()
+	if config.ForkMonitor != nil {
+		man.forkMonitor = forkmon.New(config.ForkMonitor, man.blockchain, man.protocolManager.Peers.Heads, man.protocolManager.Peers.ProbeHeaders)
+	}
+	if config.Blobs != nil {
+		man.blobHandler = blobs.NewHandler(blobs.NewStore(config.Blobs, man.knownTransaction), man.blockchain)
+	}
+	if config.DevMode {
+		man.devAPI = NewPrivateDevAPI(man)
+	}
 	//man.protocolManager.Msgcenter = ctx.MsgCenter
 	MsgCenter = ctx.MsgCenter
-	man.miner, err = miner.New(man.blockchain, man.chainConfig, man.EventMux(), man.engine, man.blockchain.DPOSEngine(), man.hd, man.CA())
+	man.miner, err = miner.New(man.blockchain, man.chainConfig, man.engine, man.blockchain.DPOSEngine(), man.hd, man.CA())
 	if err != nil {
 		return nil, err
 	}
@@ -274,6 +335,27 @@ func makeExtraData(extra []byte) []byte {
 	return extra
 }
 
+// setupKZG installs the trusted setup of the KZG commitment precompiles if the
+// chain schedules them, making sure it is the one pinned by the chain config.
+func setupKZG(config *Config, chainConfig *params.ChainConfig) error {
+	if chainConfig.KZGBlock == nil {
+		return nil
+	}
+	if config.KZGTrustedSetup == "" {
+		return fmt.Errorf("chain enables the KZG precompiles at block %v, a trusted setup is required", chainConfig.KZGBlock)
+	}
+	setup, err := kzg.LoadTrustedSetup(config.KZGTrustedSetup)
+	if err != nil {
+		return err
+	}
+	if hash := setup.Hash(); hash != chainConfig.KZGSetupHash {
+		return fmt.Errorf("KZG trusted setup mismatch: have %x, chain config wants %x", hash, chainConfig.KZGSetupHash)
+	}
+	kzg.SetTrustedSetup(setup)
+	log.Info("Loaded KZG trusted setup", "file", config.KZGTrustedSetup, "hash", chainConfig.KZGSetupHash, "fork", chainConfig.KZGBlock)
+	return nil
+}
+
 // CreateDB creates the chain database.
 func CreateDB(ctx *node.ServiceContext, config *Config, name string) (mandb.Database, error) {
 	db, err := ctx.OpenDatabase(name, config.DatabaseCache, config.DatabaseHandles)
@@ -325,6 +407,23 @@ func (s *Matrix) APIs() []rpc.API {
 	// Append any APIs exposed explicitly by the consensus engine
 	apis = append(apis, s.engine.APIs(s.BlockChain())...)
 
+	// Append the blob API if blob gossip is enabled
+	if s.blobHandler != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "blob",
+			Version:   "1.0",
+			Service:   blobs.NewPublicBlobAPI(s.blobHandler),
+			Public:    true,
+		})
+	}
+	// Append the time travel API of the developer chain
+	if s.devAPI != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "evm",
+			Version:   "1.0",
+			Service:   s.devAPI,
+		})
+	}
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -350,12 +449,12 @@ func (s *Matrix) APIs() []rpc.API {
 		}, {
 			Namespace: "man",
 			Version:   "1.0",
-			Service:   downloader.NewPublicDownloaderAPI(s.protocolManager.downloader, s.eventMux),
+			Service:   downloader.NewPublicDownloaderAPI(s.protocolManager.downloader),
 			Public:    true,
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   downloader.NewPublicDownloaderAPI(s.protocolManager.downloader, s.eventMux),
+			Service:   downloader.NewPublicDownloaderAPI(s.protocolManager.downloader),
 			Public:    true,
 		}, {
 			Namespace: "miner",
@@ -385,6 +484,11 @@ func (s *Matrix) APIs() []rpc.API {
 			Namespace: "debug",
 			Version:   "1.0",
 			Service:   NewPrivateDebugAPI(s.chainConfig, s),
+		}, {
+			Namespace: "trace",
+			Version:   "1.0",
+			Service:   NewPublicTraceAPI(s),
+			Public:    true,
 		}, {
 			Namespace: "net",
 			Version:   "1.0",
@@ -469,6 +573,7 @@ func (s *Matrix) ChainDb() mandb.Database            { return s.chainDb }
 func (s *Matrix) IsListening() bool                  { return true } // Always listening
 func (s *Matrix) EthVersion() int                    { return int(s.protocolManager.SubProtocols[0].Version) }
 func (s *Matrix) NetVersion() uint64                 { return s.networkId }
+func (s *Matrix) ChainID() *big.Int                  { return s.chainConfig.ChainId }
 func (s *Matrix) Downloader() *downloader.Downloader { return s.protocolManager.downloader }
 func (s *Matrix) CA() *ca.Identity                   { return s.ca }
 func (s *Matrix) MsgCenter() *mc.Center              { return s.msgcenter }
@@ -480,10 +585,24 @@ func (s *Matrix) TopNode() *topnode.TopNodeService   { return s.topNode }
 // Protocols implements node.Service, returning all the currently configured
 // network protocols to start.
 func (s *Matrix) Protocols() []p2p.Protocol {
-	if s.lesServer == nil {
-		return s.protocolManager.SubProtocols
+	protos := append([]p2p.Protocol(nil), s.protocolManager.SubProtocols...)
+	if s.blobHandler != nil {
+		protos = append(protos, s.blobHandler.Protocols()...)
 	}
-	return append(s.protocolManager.SubProtocols, s.lesServer.Protocols()...)
+	if s.lesServer != nil {
+		protos = append(protos, s.lesServer.Protocols()...)
+	}
+	return protos
+}
+
+// knownTransaction reports whether a transaction is pending in the pool or
+// already included in the chain, admitting the blobs referencing it.
+func (s *Matrix) knownTransaction(hash common.Hash) bool {
+	if s.txPool.Get(hash) != nil {
+		return true
+	}
+	blockHash, _, _ := rawdb.ReadTxLookupEntry(s.chainDb, hash)
+	return blockHash != (common.Hash{})
 }
 
 // Start implements node.Service, starting all internal goroutines needed by the
@@ -492,42 +611,107 @@ func (s *Matrix) Start(srvr *p2p.Server) error {
 	// Start the bloom bits servicing goroutines
 	s.startBloomHandlers()
 
+	// Make sure the local clock won't get our blocks rejected by the network
+	go s.checkClockDrift()
+
 	// Start the RPC service
 	s.netRPCService = manapi.NewPublicNetAPI(srvr, s.NetVersion())
+	if s.rpcCache != nil {
+		s.rpcCache.SetPolicy(s.rpcCachePolicy)
+		go s.rpcCacheLoop()
+	}
+	go s.proofCacheLoop()
 
 	// Figure out a max peers count based on the server limits
-	maxPeers := srvr.MaxPeers
-	if s.config.LightServ > 0 {
-		if s.config.LightPeers >= srvr.MaxPeers {
-			return fmt.Errorf("invalid peer config: light peer count (%d) >= total peer count (%d)", s.config.LightPeers, srvr.MaxPeers)
-		}
-		maxPeers -= s.config.LightPeers
+	maxPeers, err := s.manPeers(srvr.MaxPeers)
+	if err != nil {
+		return err
 	}
 	// Start the networking layer and the light server if requested
 	s.protocolManager.Start(maxPeers)
+	if s.forkMonitor != nil {
+		s.forkMonitor.Start()
+	}
+	if s.blobHandler != nil {
+		s.blobHandler.Start()
+	}
+	if s.devAPI != nil {
+		s.devAPI.start()
+	}
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
 	}
 	//s.broadTx.Start()//YY
 	return nil
 }
+
+// checkClockDrift measures the drift of the local clock against NTP and warns
+// the user if blocks sealed with it would be rejected by the network.
+func (s *Matrix) checkClockDrift() {
+	drift, err := discover.ClockDrift()
+	if err != nil {
+		log.Debug("Failed to measure clock drift", "err", err)
+		return
+	}
+	clockDriftGauge.Update(int64(drift / time.Millisecond))
+
+	if allowed := s.blockchain.FutureBlockDrift(); drift < -allowed || drift > allowed {
+		log.Warn("System clock seems off, produced blocks may be rejected", "drift", drift, "allowed", allowed)
+		log.Warn("Please enable network time synchronisation in system settings.")
+	} else {
+		log.Debug("NTP sanity check done", "drift", drift)
+	}
+}
+
 func (s *Matrix) FetcherNotify(hash common.Hash, number uint64) {
 	ids := ca.GetRolesByGroup(common.RoleValidator | common.RoleBroadcast)
 	for _, id := range ids {
 		peer := s.protocolManager.Peers.Peer(id.String()[:16])
 		if peer == nil {
-			log.Info("==========YY===========", "get PeerID is nil by Validator ID:id",id.String(),"Peers:",s.protocolManager.Peers.peers)
+			log.Info("==========YY===========", "get PeerID is nil by Validator ID:id", id.String(), "Peers:", s.protocolManager.Peers.peers)
 			continue
 		}
 		s.protocolManager.fetcher.Notify(id.String()[:16], hash, number, time.Now(), peer.RequestOneHeader, peer.RequestBodies)
 	}
 }
 
+// SetMaxPeers updates the MAN peer limit after the total peer limit of the
+// p2p server changed.
+func (s *Matrix) SetMaxPeers(total int) error {
+	maxPeers, err := s.manPeers(total)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(&s.protocolManager.maxPeers, int32(maxPeers))
+	return nil
+}
+
+// manPeers returns the number of MAN peers allowed out of the total peer
+// limit, reserving the light client slots if serving them.
+func (s *Matrix) manPeers(total int) (int, error) {
+	if s.config.LightServ > 0 {
+		if s.config.LightPeers >= total {
+			return 0, fmt.Errorf("invalid peer config: light peer count (%d) >= total peer count (%d)", s.config.LightPeers, total)
+		}
+		return total - s.config.LightPeers, nil
+	}
+	return total, nil
+}
+
 // Stop implements node.Service, terminating all internal goroutines used by the
 // Matrix protocol.
 func (s *Matrix) Stop() error {
+	if s.devAPI != nil {
+		s.devAPI.stop()
+	}
 	s.bloomIndexer.Close()
 	s.blockchain.Stop()
+	if s.forkMonitor != nil {
+		s.forkMonitor.Stop()
+	}
+	if s.blobHandler != nil {
+		s.blobHandler.Stop()
+	}
 	s.protocolManager.Stop()
 	if s.lesServer != nil {
 		s.lesServer.Stop()
@@ -535,6 +719,7 @@ func (s *Matrix) Stop() error {
 	s.txPool.Stop()
 	s.miner.Stop()
 	s.eventMux.Stop()
+	s.stateExports.stop()
 
 	s.chainDb.Close()
 	s.broadTx.Stop() //YY
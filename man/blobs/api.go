@@ -0,0 +1,91 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package blobs
+
+import (
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+)
+
+// RPCSidecar is a stored blob as returned over RPC.
+type RPCSidecar struct {
+	Hash   common.Hash    `json:"hash"`
+	TxHash common.Hash    `json:"txHash"`
+	Data   hexutil.Bytes  `json:"data"`
+	Number hexutil.Uint64 `json:"number"` // Block the blob was admitted at
+}
+
+// SendBlobArgs is a blob submitted over RPC.
+type SendBlobArgs struct {
+	TxHash common.Hash   `json:"txHash"`
+	Data   hexutil.Bytes `json:"data"`
+}
+
+// Status reports the state of the blob store and gossip.
+type Status struct {
+	Stats
+	Peers int `json:"peers"`
+}
+
+// PublicBlobAPI provides access to the locally stored blobs.
+type PublicBlobAPI struct {
+	handler *Handler
+}
+
+// NewPublicBlobAPI creates a new blob API.
+func NewPublicBlobAPI(handler *Handler) *PublicBlobAPI {
+	return &PublicBlobAPI{handler}
+}
+
+// Get returns the blob with the given hash, or nil if it isn't stored.
+func (api *PublicBlobAPI) Get(hash common.Hash) *RPCSidecar {
+	sidecar, number := api.handler.store.Get(hash)
+	if sidecar == nil {
+		return nil
+	}
+	return &RPCSidecar{
+		Hash:   hash,
+		TxHash: sidecar.TxHash,
+		Data:   sidecar.Data,
+		Number: hexutil.Uint64(number),
+	}
+}
+
+// GetByTransaction returns the hashes of the stored blobs referenced by a
+// transaction.
+func (api *PublicBlobAPI) GetByTransaction(txHash common.Hash) []common.Hash {
+	hashes := api.handler.store.ByTransaction(txHash)
+	if hashes == nil {
+		hashes = []common.Hash{}
+	}
+	return hashes
+}
+
+// Send admits a blob referenced by a known transaction into the local store
+// and announces it to the peers, returning its hash.
+func (api *PublicBlobAPI) Send(args SendBlobArgs) (common.Hash, error) {
+	return api.handler.Submit(&Sidecar{TxHash: args.TxHash, Data: args.Data})
+}
+
+// Status returns the occupancy of the blob store and the number of peers
+// speaking the blob protocol.
+func (api *PublicBlobAPI) Status() Status {
+	return Status{
+		Stats: api.handler.store.Stats(),
+		Peers: api.handler.PeerCount(),
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package blobs
+
+// DefaultConfig contains default settings for the blob store.
+var DefaultConfig = Config{
+	MaxBlobSize:   128 * 1024,
+	MaxBlobsPerTx: 4,
+	StoreSize:     256 * 1024 * 1024,
+	Horizon:       4096,
+}
+
+// Config contains the configuration parameters of the blob store.
+type Config struct {
+	// MaxBlobSize is the maximum size of the data of a single blob.
+	MaxBlobSize uint64
+
+	// MaxBlobsPerTx is the maximum number of blobs referencing the same
+	// transaction.
+	MaxBlobsPerTx int
+
+	// StoreSize is the maximum total size of the stored blob data. The oldest
+	// blobs are evicted once it is exceeded.
+	StoreSize uint64
+
+	// Horizon is the number of blocks blobs are kept for after their admission,
+	// independent of the pruning of the chain state.
+	Horizon uint64
+}
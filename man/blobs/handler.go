@@ -0,0 +1,389 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package blobs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/event"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/p2p"
+	"gopkg.in/fatih/set.v0"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	ProtocolName    = "blob" // Name of the blob gossip protocol
+	ProtocolVersion = 1      // Version of the blob gossip protocol
+	ProtocolLength  = 3      // Number of message codes used by the protocol
+)
+
+// Blob gossip protocol message codes
+const (
+	NewBlobHashesMsg = 0x00 // Announcement of newly admitted blobs
+	GetBlobsMsg      = 0x01 // Request of announced blobs
+	BlobsMsg         = 0x02 // Reply to a blob request
+)
+
+const (
+	maxMessageSize    = 16 * 1024 * 1024 // Maximum size of a protocol message
+	maxAnnounceHashes = 256              // Maximum number of hashes in an announcement
+	maxRequestHashes  = 64               // Maximum number of blobs requested at once
+	maxKnownBlobs     = 16384            // Maximum blob hashes remembered per peer
+	softResponseLimit = 2 * 1024 * 1024  // Target size of a blob reply, at least one blob is sent
+	announceQueueSize = 64               // Announcements queued per peer before dropping
+	chainHeadChanSize = 10               // Size of the chain head event channel
+)
+
+var errUnrequestedBlob = errors.New("unrequested blob")
+
+// Chain is the subset of the local chain the handler needs to prune the store.
+type Chain interface {
+	CurrentHeader() *types.Header
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// peer is a remote node speaking the blob gossip protocol.
+type peer struct {
+	id string
+	rw p2p.MsgReadWriter
+
+	known     *set.Set                 // Blob hashes known to be known by the peer
+	requested map[common.Hash]struct{} // Blob hashes requested but not yet delivered
+	lock      sync.Mutex
+
+	announce chan []common.Hash // Queue of announcements to send
+	term     chan struct{}
+}
+
+// markKnown remembers that the peer knows about a blob.
+func (p *peer) markKnown(hash common.Hash) {
+	for p.known.Size() >= maxKnownBlobs {
+		p.known.Pop()
+	}
+	p.known.Add(hash)
+}
+
+// broadcastLoop sends the queued announcements to the peer.
+func (p *peer) broadcastLoop() {
+	for {
+		select {
+		case hashes := <-p.announce:
+			if err := p2p.Send(p.rw, NewBlobHashesMsg, hashes); err != nil {
+				return
+			}
+		case <-p.term:
+			return
+		}
+	}
+}
+
+// Handler gossips blobs with the connected peers, admitting the received ones
+// into the store and pruning it as the chain progresses.
+type Handler struct {
+	store *Store
+	chain Chain
+
+	peers map[string]*peer
+	lock  sync.RWMutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewHandler creates a blob gossip handler on top of a store.
+func NewHandler(store *Store, chain Chain) *Handler {
+	return &Handler{
+		store: store,
+		chain: chain,
+		peers: make(map[string]*peer),
+		quit:  make(chan struct{}),
+	}
+}
+
+// Store returns the blob store backing the handler.
+func (h *Handler) Store() *Store {
+	return h.store
+}
+
+// Protocols returns the blob gossip protocol, run separately from the main chain
+// protocol so large blobs don't hold up block and transaction propagation.
+func (h *Handler) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{{
+		Name:    ProtocolName,
+		Version: ProtocolVersion,
+		Length:  ProtocolLength,
+		Run:     h.runPeer,
+	}}
+}
+
+// Start launches the pruning loop.
+func (h *Handler) Start() {
+	h.wg.Add(1)
+	go h.pruneLoop()
+
+	log.Info("Started blob gossip", "capacity", h.store.config.StoreSize, "horizon", h.store.config.Horizon)
+}
+
+// Stop terminates the pruning loop.
+func (h *Handler) Stop() {
+	close(h.quit)
+	h.wg.Wait()
+}
+
+// PeerCount returns the number of peers speaking the blob protocol.
+func (h *Handler) PeerCount() int {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return len(h.peers)
+}
+
+// Submit admits a locally created blob and announces it to the peers.
+func (h *Handler) Submit(sidecar *Sidecar) (common.Hash, error) {
+	if err := h.store.Add(sidecar, h.chain.CurrentHeader().Number.Uint64()); err != nil {
+		return common.Hash{}, err
+	}
+	hash := sidecar.Hash()
+	h.broadcast([]common.Hash{hash})
+	return hash, nil
+}
+
+// runPeer is the protocol handler of a single peer. It announces the stored
+// blobs, then serves the messages of the peer until it disconnects.
+func (h *Handler) runPeer(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	peer := &peer{
+		id:        fmt.Sprintf("%x", p.ID().Bytes()[:8]),
+		rw:        rw,
+		known:     set.New(),
+		requested: make(map[common.Hash]struct{}),
+		announce:  make(chan []common.Hash, announceQueueSize),
+		term:      make(chan struct{}),
+	}
+	h.lock.Lock()
+	h.peers[peer.id] = peer
+	h.lock.Unlock()
+
+	defer func() {
+		h.lock.Lock()
+		delete(h.peers, peer.id)
+		h.lock.Unlock()
+		close(peer.term)
+	}()
+	go peer.broadcastLoop()
+
+	hashes := h.store.Hashes()
+	for len(hashes) > 0 {
+		batch := hashes
+		if len(batch) > maxAnnounceHashes {
+			batch = batch[:maxAnnounceHashes]
+		}
+		h.announce(peer, batch)
+		hashes = hashes[len(batch):]
+	}
+	for {
+		if err := h.handleMsg(peer); err != nil {
+			log.Debug("Blob gossip failed", "peer", peer.id, "err", err)
+			return err
+		}
+	}
+}
+
+// handleMsg reads and processes the next message of a peer.
+func (h *Handler) handleMsg(p *peer) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	defer msg.Discard()
+
+	if msg.Size > maxMessageSize {
+		return fmt.Errorf("message too large: %v > %v", msg.Size, maxMessageSize)
+	}
+	switch msg.Code {
+	case NewBlobHashesMsg:
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return fmt.Errorf("msg %v: %v", msg, err)
+		}
+		if len(hashes) > maxAnnounceHashes {
+			return fmt.Errorf("too many announced blobs: %d", len(hashes))
+		}
+		return h.request(p, hashes)
+
+	case GetBlobsMsg:
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return fmt.Errorf("msg %v: %v", msg, err)
+		}
+		if len(hashes) > maxRequestHashes {
+			return fmt.Errorf("too many requested blobs: %d", len(hashes))
+		}
+		var (
+			blobs []*Sidecar
+			bytes int
+		)
+		for _, hash := range hashes {
+			if bytes > 0 && bytes >= softResponseLimit {
+				break
+			}
+			if sidecar, _ := h.store.Get(hash); sidecar != nil {
+				blobs = append(blobs, sidecar)
+				bytes += len(sidecar.Data)
+			}
+		}
+		return p2p.Send(p.rw, BlobsMsg, blobs)
+
+	case BlobsMsg:
+		var blobs []*Sidecar
+		if err := msg.Decode(&blobs); err != nil {
+			return fmt.Errorf("msg %v: %v", msg, err)
+		}
+		return h.deliver(p, blobs)
+
+	default:
+		return fmt.Errorf("invalid message code %d", msg.Code)
+	}
+}
+
+// request asks a peer for the announced blobs not known locally.
+func (h *Handler) request(p *peer, hashes []common.Hash) error {
+	p.lock.Lock()
+	var missing []common.Hash
+	for _, hash := range hashes {
+		p.markKnown(hash)
+		if _, ok := p.requested[hash]; ok || h.store.Has(hash) {
+			continue
+		}
+		if len(p.requested) >= maxRequestHashes {
+			break
+		}
+		p.requested[hash] = struct{}{}
+		missing = append(missing, hash)
+	}
+	p.lock.Unlock()
+
+	if len(missing) == 0 {
+		return nil
+	}
+	return p2p.Send(p.rw, GetBlobsMsg, missing)
+}
+
+// deliver admits the blobs a peer sent in reply to a request, and announces
+// the accepted ones to the other peers. Blobs failing admission because of the
+// local state (unknown transaction, per transaction limit) are dropped without
+// penalizing the peer.
+func (h *Handler) deliver(p *peer, blobs []*Sidecar) error {
+	head := h.chain.CurrentHeader().Number.Uint64()
+
+	var accepted []common.Hash
+	for _, sidecar := range blobs {
+		hash := sidecar.Hash()
+
+		p.lock.Lock()
+		_, ok := p.requested[hash]
+		delete(p.requested, hash)
+		p.markKnown(hash)
+		p.lock.Unlock()
+
+		if !ok {
+			return fmt.Errorf("%v: %x", errUnrequestedBlob, hash)
+		}
+		switch err := h.store.Add(sidecar, head); err {
+		case nil:
+			accepted = append(accepted, hash)
+		case errKnownBlob, errUnknownTx, errTooManyBlobs:
+			log.Trace("Dropped gossiped blob", "peer", p.id, "hash", hash, "err", err)
+		default:
+			return fmt.Errorf("invalid blob %x: %v", hash, err)
+		}
+	}
+	// Requests the peer couldn't serve are forgotten, so they may be retried
+	p.lock.Lock()
+	p.requested = make(map[common.Hash]struct{})
+	p.lock.Unlock()
+
+	if len(accepted) > 0 {
+		h.broadcast(accepted)
+	}
+	return nil
+}
+
+// broadcast announces blobs to all peers not knowing about them yet.
+func (h *Handler) broadcast(hashes []common.Hash) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	for _, p := range h.peers {
+		var unknown []common.Hash
+
+		p.lock.Lock()
+		for _, hash := range hashes {
+			if !p.known.Has(hash) {
+				unknown = append(unknown, hash)
+			}
+		}
+		p.lock.Unlock()
+
+		if len(unknown) > 0 {
+			h.announce(p, unknown)
+		}
+	}
+}
+
+// announce queues an announcement to a peer, dropping it if the peer is too
+// slow to keep up.
+func (h *Handler) announce(p *peer, hashes []common.Hash) {
+	p.lock.Lock()
+	for _, hash := range hashes {
+		p.markKnown(hash)
+	}
+	p.lock.Unlock()
+
+	select {
+	case p.announce <- hashes:
+	default:
+		log.Debug("Dropping blob announcement", "peer", p.id, "blobs", len(hashes))
+	}
+}
+
+// pruneLoop drops the blobs falling behind the horizon as the chain progresses.
+func (h *Handler) pruneLoop() {
+	defer h.wg.Done()
+
+	heads := make(chan core.ChainHeadEvent, chainHeadChanSize)
+	sub := h.chain.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case head := <-heads:
+			if dropped := h.store.Prune(head.Block.NumberU64()); dropped > 0 {
+				log.Debug("Pruned blobs", "dropped", dropped, "head", head.Block.NumberU64())
+			}
+		case <-sub.Err():
+			return
+		case <-h.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package blobs
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/event"
+	"github.com/matrix/go-matrix/p2p"
+	"github.com/matrix/go-matrix/p2p/discover"
+)
+
+// testChain is a chain with a settable head and a head event feed.
+type testChain struct {
+	head *types.Header
+	feed event.Feed
+}
+
+func (c *testChain) CurrentHeader() *types.Header {
+	return c.head
+}
+
+func (c *testChain) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return c.feed.Subscribe(ch)
+}
+
+// setHead moves the chain head and announces it to the subscribers.
+func (c *testChain) setHead(number int64) {
+	c.head = &types.Header{Number: big.NewInt(number)}
+	c.feed.Send(core.ChainHeadEvent{Block: types.NewBlockWithHeader(c.head)})
+}
+
+// startPeer connects a remote peer to the handler, returning the remote end of
+// the pipe and a channel receiving the result of the protocol run.
+func startPeer(h *Handler) (*p2p.MsgPipeRW, <-chan error) {
+	app, net := p2p.MsgPipe()
+	peer := p2p.NewPeer(discover.NodeID{1}, "test", nil)
+
+	errc := make(chan error, 1)
+	go func() { errc <- h.runPeer(peer, net) }()
+	return app, errc
+}
+
+// Tests that stored blobs are announced and served, and that announced blobs
+// are requested and admitted.
+func TestHandlerExchange(t *testing.T) {
+	var (
+		tx1 = common.HexToHash("0x01")
+		tx2 = common.HexToHash("0x02")
+
+		local  = &Sidecar{TxHash: tx1, Data: []byte{1, 2, 3}}
+		remote = &Sidecar{TxHash: tx2, Data: []byte{4, 5, 6}}
+	)
+	chain := &testChain{head: &types.Header{Number: big.NewInt(1)}}
+	h := NewHandler(NewStore(testConfig(), knownTxs(tx1, tx2)), chain)
+	if _, err := h.Submit(local); err != nil {
+		t.Fatalf("failed to submit local blob: %v", err)
+	}
+	app, errc := startPeer(h)
+	defer app.Close()
+
+	// The stored blob is announced on connect and served on request
+	if err := p2p.ExpectMsg(app, NewBlobHashesMsg, []common.Hash{local.Hash()}); err != nil {
+		t.Fatalf("announcement mismatch: %v", err)
+	}
+	if err := p2p.Send(app, GetBlobsMsg, []common.Hash{local.Hash(), remote.Hash()}); err != nil {
+		t.Fatalf("failed to request blobs: %v", err)
+	}
+	if err := p2p.ExpectMsg(app, BlobsMsg, []*Sidecar{local}); err != nil {
+		t.Fatalf("reply mismatch: %v", err)
+	}
+	// An announced unknown blob is requested and admitted on delivery
+	if err := p2p.Send(app, NewBlobHashesMsg, []common.Hash{local.Hash(), remote.Hash()}); err != nil {
+		t.Fatalf("failed to announce blob: %v", err)
+	}
+	if err := p2p.ExpectMsg(app, GetBlobsMsg, []common.Hash{remote.Hash()}); err != nil {
+		t.Fatalf("request mismatch: %v", err)
+	}
+	if err := p2p.Send(app, BlobsMsg, []*Sidecar{remote}); err != nil {
+		t.Fatalf("failed to deliver blob: %v", err)
+	}
+	for i := 0; i < 100 && !h.Store().Has(remote.Hash()); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !h.Store().Has(remote.Hash()) {
+		t.Fatalf("delivered blob not admitted")
+	}
+	// Unrequested blobs get the peer dropped
+	if err := p2p.Send(app, BlobsMsg, []*Sidecar{{TxHash: tx2, Data: []byte{7}}}); err != nil {
+		t.Fatalf("failed to deliver blob: %v", err)
+	}
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Fatalf("peer not dropped for unrequested blob")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("peer not dropped for unrequested blob")
+	}
+}
+
+// Tests that new chain heads prune the blobs behind the horizon.
+func TestHandlerPruning(t *testing.T) {
+	tx := common.HexToHash("0x01")
+
+	chain := &testChain{head: &types.Header{Number: big.NewInt(1)}}
+	h := NewHandler(NewStore(testConfig(), knownTxs(tx)), chain)
+	h.Start()
+	defer h.Stop()
+
+	hash, err := h.Submit(&Sidecar{TxHash: tx, Data: []byte{1}})
+	if err != nil {
+		t.Fatalf("failed to submit blob: %v", err)
+	}
+	chain.setHead(11)
+	time.Sleep(50 * time.Millisecond)
+	if !h.Store().Has(hash) {
+		t.Fatalf("blob within horizon pruned")
+	}
+	chain.setHead(12)
+	for i := 0; i < 100 && h.Store().Has(hash); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if h.Store().Has(hash) {
+		t.Fatalf("blob behind horizon not pruned")
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package blobs implements a gossip channel and a bounded local store for large
+// auxiliary data blobs referenced by transactions, as groundwork for the data
+// availability experiments of the testnet.
+package blobs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/crypto"
+)
+
+var (
+	errEmptyBlob     = errors.New("empty blob")
+	errOversizedBlob = errors.New("oversized blob")
+	errMissingTx     = errors.New("blob doesn't reference a transaction")
+	errKnownBlob     = errors.New("known blob")
+	errUnknownTx     = errors.New("blob references unknown transaction")
+	errTooManyBlobs  = errors.New("too many blobs for transaction")
+	errStoreTooSmall = errors.New("blob exceeds store capacity")
+)
+
+// Sidecar is a data blob travelling next to the transaction referencing it.
+type Sidecar struct {
+	TxHash common.Hash // Transaction referencing the blob
+	Data   []byte
+}
+
+// Hash returns the identifier of the blob, the hash of its data.
+func (s *Sidecar) Hash() common.Hash {
+	return crypto.Keccak256Hash(s.Data)
+}
+
+// TxLookup reports whether a transaction is known locally, either pending in
+// the pool or included in the chain.
+type TxLookup func(hash common.Hash) bool
+
+// Stats describes the contents of the store.
+type Stats struct {
+	Blobs    int    `json:"blobs"`
+	Bytes    uint64 `json:"bytes"`
+	Capacity uint64 `json:"capacity"`
+	Horizon  uint64 `json:"horizon"`
+	Oldest   uint64 `json:"oldest"` // Admission block of the oldest blob, zero if empty
+}
+
+// storedBlob is a blob in the store along with the head number at its admission.
+type storedBlob struct {
+	sidecar *Sidecar
+	number  uint64
+}
+
+// Store keeps the admitted blobs in memory, evicting the oldest ones once the
+// configured capacity is exceeded or they fall behind the pruning horizon.
+type Store struct {
+	config *Config
+	lookup TxLookup
+
+	blobs map[common.Hash]*storedBlob
+	byTx  map[common.Hash][]common.Hash
+	order []common.Hash // Blob hashes in admission order
+	size  uint64
+	lock  sync.RWMutex
+}
+
+// NewStore creates an empty blob store.
+func NewStore(config *Config, lookup TxLookup) *Store {
+	return &Store{
+		config: config,
+		lookup: lookup,
+		blobs:  make(map[common.Hash]*storedBlob),
+		byTx:   make(map[common.Hash][]common.Hash),
+	}
+}
+
+// Add validates a blob against the admission rules and stores it, recording the
+// given head number as its admission block.
+func (s *Store) Add(sidecar *Sidecar, head uint64) error {
+	size := uint64(len(sidecar.Data))
+	switch {
+	case size == 0:
+		return errEmptyBlob
+	case size > s.config.MaxBlobSize:
+		return fmt.Errorf("%v: %d bytes, limit %d", errOversizedBlob, size, s.config.MaxBlobSize)
+	case size > s.config.StoreSize:
+		return errStoreTooSmall
+	case sidecar.TxHash == (common.Hash{}):
+		return errMissingTx
+	}
+	hash := sidecar.Hash()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.blobs[hash]; ok {
+		return errKnownBlob
+	}
+	if len(s.byTx[sidecar.TxHash]) >= s.config.MaxBlobsPerTx {
+		return errTooManyBlobs
+	}
+	if s.lookup != nil && !s.lookup(sidecar.TxHash) {
+		return errUnknownTx
+	}
+	s.blobs[hash] = &storedBlob{sidecar: sidecar, number: head}
+	s.byTx[sidecar.TxHash] = append(s.byTx[sidecar.TxHash], hash)
+	s.order = append(s.order, hash)
+	s.size += size
+
+	for s.size > s.config.StoreSize {
+		s.dropOldest()
+	}
+	return nil
+}
+
+// Has reports whether a blob is stored.
+func (s *Store) Has(hash common.Hash) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	_, ok := s.blobs[hash]
+	return ok
+}
+
+// Get retrieves a blob along with its admission block, or nil if unknown.
+func (s *Store) Get(hash common.Hash) (*Sidecar, uint64) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if blob, ok := s.blobs[hash]; ok {
+		return blob.sidecar, blob.number
+	}
+	return nil, 0
+}
+
+// ByTransaction returns the hashes of the blobs referencing a transaction.
+func (s *Store) ByTransaction(txHash common.Hash) []common.Hash {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return append([]common.Hash(nil), s.byTx[txHash]...)
+}
+
+// Hashes returns the hashes of all stored blobs in admission order.
+func (s *Store) Hashes() []common.Hash {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return append([]common.Hash(nil), s.order...)
+}
+
+// Prune drops the blobs admitted more than the horizon before the given head,
+// returning the number of dropped blobs.
+func (s *Store) Prune(head uint64) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	dropped := 0
+	for len(s.order) > 0 {
+		if blob := s.blobs[s.order[0]]; blob.number+s.config.Horizon >= head {
+			break
+		}
+		s.dropOldest()
+		dropped++
+	}
+	return dropped
+}
+
+// Stats returns the current statistics of the store.
+func (s *Store) Stats() Stats {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	stats := Stats{
+		Blobs:    len(s.blobs),
+		Bytes:    s.size,
+		Capacity: s.config.StoreSize,
+		Horizon:  s.config.Horizon,
+	}
+	if len(s.order) > 0 {
+		stats.Oldest = s.blobs[s.order[0]].number
+	}
+	return stats
+}
+
+// dropOldest removes the blob admitted first. The lock must be held.
+func (s *Store) dropOldest() {
+	hash := s.order[0]
+	s.order = s.order[1:]
+
+	blob := s.blobs[hash]
+	delete(s.blobs, hash)
+	s.size -= uint64(len(blob.sidecar.Data))
+
+	hashes := s.byTx[blob.sidecar.TxHash]
+	for i, h := range hashes {
+		if h == hash {
+			hashes = append(hashes[:i], hashes[i+1:]...)
+			break
+		}
+	}
+	if len(hashes) == 0 {
+		delete(s.byTx, blob.sidecar.TxHash)
+	} else {
+		s.byTx[blob.sidecar.TxHash] = hashes
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package blobs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+)
+
+func testConfig() *Config {
+	return &Config{
+		MaxBlobSize:   16,
+		MaxBlobsPerTx: 2,
+		StoreSize:     48,
+		Horizon:       10,
+	}
+}
+
+// knownTxs returns a lookup accepting the given transaction hashes.
+func knownTxs(hashes ...common.Hash) TxLookup {
+	known := make(map[common.Hash]bool)
+	for _, hash := range hashes {
+		known[hash] = true
+	}
+	return func(hash common.Hash) bool { return known[hash] }
+}
+
+// Tests that blobs are only admitted if they pass the admission rules.
+func TestStoreAdmission(t *testing.T) {
+	var (
+		tx1 = common.HexToHash("0x01")
+		tx2 = common.HexToHash("0x02")
+	)
+	store := NewStore(testConfig(), knownTxs(tx1))
+
+	tests := []struct {
+		sidecar *Sidecar
+		err     bool
+	}{
+		{&Sidecar{TxHash: tx1, Data: nil}, true},                         // empty
+		{&Sidecar{TxHash: tx1, Data: bytes.Repeat([]byte{1}, 17)}, true}, // oversized
+		{&Sidecar{Data: []byte{1}}, true},                                // no transaction
+		{&Sidecar{TxHash: tx2, Data: []byte{1}}, true},                   // unknown transaction
+		{&Sidecar{TxHash: tx1, Data: []byte{1}}, false},
+		{&Sidecar{TxHash: tx1, Data: []byte{1}}, true}, // duplicate
+		{&Sidecar{TxHash: tx1, Data: []byte{2}}, false},
+		{&Sidecar{TxHash: tx1, Data: []byte{3}}, true}, // too many for the transaction
+	}
+	for i, tt := range tests {
+		if err := store.Add(tt.sidecar, 1); (err != nil) != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want error %v", i, err, tt.err)
+		}
+	}
+	if have := len(store.ByTransaction(tx1)); have != 2 {
+		t.Errorf("blobs of transaction mismatch: have %d, want 2", have)
+	}
+	if sidecar, number := store.Get((&Sidecar{Data: []byte{2}}).Hash()); sidecar == nil || number != 1 {
+		t.Errorf("stored blob mismatch: have %v at %d", sidecar, number)
+	}
+}
+
+// Tests that the oldest blobs are evicted once the store is full, and that
+// blobs behind the horizon are pruned.
+func TestStoreEvictionAndPruning(t *testing.T) {
+	var txs []common.Hash
+	for i := 0; i < 5; i++ {
+		txs = append(txs, common.BytesToHash([]byte{byte(i + 1)}))
+	}
+	store := NewStore(testConfig(), knownTxs(txs...))
+
+	var hashes []common.Hash
+	for i, tx := range txs {
+		sidecar := &Sidecar{TxHash: tx, Data: bytes.Repeat([]byte{byte(i)}, 16)}
+		if err := store.Add(sidecar, uint64(i)); err != nil {
+			t.Fatalf("blob %d: failed to add: %v", i, err)
+		}
+		hashes = append(hashes, sidecar.Hash())
+	}
+	// Three blobs fit in the store, the first two must have been evicted
+	if stats := store.Stats(); stats.Blobs != 3 || stats.Bytes != 48 || stats.Oldest != 2 {
+		t.Fatalf("stats mismatch after eviction: %+v", stats)
+	}
+	for i, hash := range hashes {
+		if have, want := store.Has(hash), i >= 2; have != want {
+			t.Errorf("blob %d: presence mismatch: have %v, want %v", i, have, want)
+		}
+	}
+	if len(store.ByTransaction(txs[0])) != 0 {
+		t.Errorf("evicted blob still indexed by transaction")
+	}
+	// Blobs admitted at block 2 and 3 fall behind the horizon at block 14
+	if dropped := store.Prune(12); dropped != 0 {
+		t.Errorf("pruned blobs within horizon: %d", dropped)
+	}
+	if dropped := store.Prune(14); dropped != 2 {
+		t.Errorf("pruned blob count mismatch: have %d, want 2", dropped)
+	}
+	if have := store.Hashes(); len(have) != 1 || have[0] != hashes[4] {
+		t.Errorf("remaining blobs mismatch: have %x", have)
+	}
+}
@@ -29,7 +29,10 @@ import (
 	"github.com/matrix/go-matrix/consensus/manash"
 	"github.com/matrix/go-matrix/core"
 	"github.com/matrix/go-matrix/man/downloader"
+	"github.com/matrix/go-matrix/man/blobs"
+	"github.com/matrix/go-matrix/man/forkmon"
 	"github.com/matrix/go-matrix/man/gasprice"
+	"github.com/matrix/go-matrix/man/remotestate"
 	"github.com/matrix/go-matrix/params"
 )
 
@@ -50,7 +53,8 @@ var DefaultConfig = Config{
 	TrieTimeout:   5 * time.Minute,
 	GasPrice:      big.NewInt(18 * params.Shannon),
 
-	TxPool: core.DefaultTxPoolConfig,
+	TxPool:      core.DefaultTxPoolConfig,
+	Propagation: DefaultPropagationConfig,
 	GPO: gasprice.Config{
 		Blocks:     20,
 		Percentile: 60,
@@ -100,6 +104,9 @@ type Config struct {
 	ExtraData    []byte         `toml:",omitempty"`
 	GasPrice     *big.Int
 
+	// Propagation of the blocks sealed by the node
+	Propagation PropagationConfig
+
 	// Ethash options
 	Ethash manash.Config
 
@@ -112,6 +119,40 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// Number of block transactions executed speculatively in parallel (0 = serial)
+	ParallelTxs int `toml:",omitempty"`
+
+	// Index the last block accessing each account and storage slot
+	StateAccessIndex bool `toml:",omitempty"`
+
+	// Index the call frames of imported transactions for historical traces
+	TraceIndex bool `toml:",omitempty"`
+
+	// Maximum time blocks may be ahead of the local clock before being rejected,
+	// nil keeps the blockchain default
+	FutureBlockDrift *time.Duration `toml:",omitempty"`
+
+	// Block the chain must contain, peers on other branches are refused
+	PinnedHead *core.PinnedBlock `toml:",omitempty"`
+
+	// Fork monitor options (nil = disabled)
+	ForkMonitor *forkmon.Config `toml:",omitempty"`
+
+	// Blob gossip and store options (nil = disabled)
+	Blobs *blobs.Config `toml:",omitempty"`
+
+	// Remote state options of light nodes, serving state RPCs from verified
+	// upstream proofs (nil = disabled)
+	RemoteState *remotestate.Config `toml:",omitempty"`
+
+	// Trusted setup file of the KZG commitment precompiles, required once the
+	// chain configuration schedules them
+	KZGTrustedSetup string `toml:",omitempty"`
+
+	// Developer mode, serving the evm_* snapshot and time travel RPCs over a
+	// chain mined on demand
+	DevMode bool `toml:"-"`
+
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
 }
@@ -21,7 +21,6 @@ import (
 	"sync"
 
 	matrix "github.com/matrix/go-matrix"
-	"github.com/matrix/go-matrix/event"
 	"github.com/matrix/go-matrix/rpc"
 )
 
@@ -29,19 +28,16 @@ import (
 // It offers only methods that operates on data that can be available to anyone without security risks.
 type PublicDownloaderAPI struct {
 	d                         *Downloader
-	mux                       *event.TypeMux
 	installSyncSubscription   chan chan interface{}
 	uninstallSyncSubscription chan *uninstallSyncSubscriptionRequest
 }
 
 // NewPublicDownloaderAPI create a new PublicDownloaderAPI. The API has an internal event loop that
-// listens for events from the downloader through the global event mux. In case it receives one of
-// these events it broadcasts it to all syncing subscriptions that are installed through the
-// installSyncSubscription channel.
-func NewPublicDownloaderAPI(d *Downloader, m *event.TypeMux) *PublicDownloaderAPI {
+// listens for sync events from the downloader. In case it receives one of these events it broadcasts
+// it to all syncing subscriptions that are installed through the installSyncSubscription channel.
+func NewPublicDownloaderAPI(d *Downloader) *PublicDownloaderAPI {
 	api := &PublicDownloaderAPI{
-		d:   d,
-		mux: m,
+		d: d,
 		installSyncSubscription:   make(chan chan interface{}),
 		uninstallSyncSubscription: make(chan *uninstallSyncSubscriptionRequest),
 	}
@@ -51,13 +47,15 @@ func NewPublicDownloaderAPI(d *Downloader, m *event.TypeMux) *PublicDownloaderAP
 	return api
 }
 
-// eventLoop runs a loop until the event mux closes. It will install and uninstall new
+// eventLoop runs a loop until the sync event subscription ends. It will install and uninstall new
 // sync subscriptions and broadcasts sync status updates to the installed sync subscriptions.
 func (api *PublicDownloaderAPI) eventLoop() {
 	var (
-		sub               = api.mux.Subscribe(StartEvent{}, DoneEvent{}, FailedEvent{})
+		events            = make(chan SyncEvent, 16)
+		sub               = api.d.SubscribeSyncEvent(events)
 		syncSubscriptions = make(map[chan interface{}]struct{})
 	)
+	defer sub.Unsubscribe()
 
 	for {
 		select {
@@ -66,20 +64,15 @@ func (api *PublicDownloaderAPI) eventLoop() {
 		case u := <-api.uninstallSyncSubscription:
 			delete(syncSubscriptions, u.c)
 			close(u.uninstalled)
-		case event := <-sub.Chan():
-			if event == nil {
-				return
-			}
-
-			var notification interface{}
-			switch event.Data.(type) {
-			case StartEvent:
+		case <-sub.Err():
+			return
+		case event := <-events:
+			var notification interface{} = false
+			if event.Syncing {
 				notification = &SyncingResult{
 					Syncing: true,
 					Status:  api.d.Progress(),
 				}
-			case DoneEvent, FailedEvent:
-				notification = false
 			}
 			// broadcast
 			for c := range syncSubscriptions {
@@ -34,6 +34,7 @@ import (
 	"github.com/matrix/go-matrix/log"
 	"github.com/matrix/go-matrix/metrics"
 	"github.com/matrix/go-matrix/params"
+	"github.com/matrix/go-matrix/watchdog"
 )
 
 var (
@@ -93,8 +94,9 @@ var (
 )
 
 type Downloader struct {
-	mode SyncMode       // Synchronisation mode defining the strategy used (per sync cycle)
-	mux  *event.TypeMux // Event multiplexer to announce sync operation events
+	mode     SyncMode            // Synchronisation mode defining the strategy used (per sync cycle)
+	syncFeed *event.BufferedFeed // Feed announcing sync cycle starts and terminations
+	heart    *watchdog.Heart     // Watchdog heart beating while a sync cycle makes progress
 
 	queue   *queue   // Scheduler for selecting the hashes to download
 	peers   *peerSet // Set of active peers from which download can proceed
@@ -198,7 +200,7 @@ type BlockChain interface {
 }
 
 // New creates a new downloader to fetch hashes and blocks from remote peers.
-func New(mode SyncMode, stateDb mandb.Database, mux *event.TypeMux, chain BlockChain, lightchain LightChain, dropPeer peerDropFn) *Downloader {
+func New(mode SyncMode, stateDb mandb.Database, chain BlockChain, lightchain LightChain, dropPeer peerDropFn) *Downloader {
 	if lightchain == nil {
 		lightchain = chain
 	}
@@ -206,7 +208,8 @@ func New(mode SyncMode, stateDb mandb.Database, mux *event.TypeMux, chain BlockC
 	dl := &Downloader{
 		mode:           mode,
 		stateDB:        stateDb,
-		mux:            mux,
+		syncFeed:       event.NewBufferedFeed(syncEventDropCounter),
+		heart:          watchdog.Register("downloader"),
 		queue:          newQueue(),
 		peers:          newPeerSet(),
 		rttEstimate:    uint64(rttMaxEstimate),
@@ -228,11 +231,22 @@ func New(mode SyncMode, stateDb mandb.Database, mux *event.TypeMux, chain BlockC
 		},
 		trackStateReq: make(chan *stateReq),
 	}
+	// A wedged sync cycle can be restarted by aborting it, the next sync
+	// attempt will start over from the local chain
+	dl.heart.OnStall(dl.Cancel)
+
 	go dl.qosTuner()
 	go dl.stateFetcher()
 	return dl
 }
 
+// SubscribeSyncEvent registers a subscription of SyncEvent. Delivery never
+// blocks the synchronisation: events not fitting into the channel's buffer
+// are dropped.
+func (d *Downloader) SubscribeSyncEvent(ch chan<- SyncEvent) event.Subscription {
+	return d.syncFeed.Subscribe(ch)
+}
+
 // Progress retrieves the synchronisation boundaries, specifically the origin
 // block where synchronisation started at (may have failed/suspended); the block
 // or header sync is currently at; and the latest known block which the sync targets.
@@ -402,15 +416,13 @@ func (d *Downloader) synchronise(id string, hash common.Hash, td *big.Int, mode
 // syncWithPeer starts a block synchronization based on the hash chain from the
 // specified peer and head hash.
 func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.Int) (err error) {
-	d.mux.Post(StartEvent{})
+	d.syncFeed.Send(SyncEvent{Syncing: true})
 	defer func() {
-		// reset on error
-		if err != nil {
-			d.mux.Post(FailedEvent{err})
-		} else {
-			d.mux.Post(DoneEvent{})
-		}
+		d.syncFeed.Send(SyncEvent{Syncing: false, Err: err})
 	}()
+	d.heart.Beat()
+	defer d.heart.Idle()
+
 	if p.version < 62 {
 		return errTooOld
 	}
@@ -1194,6 +1206,8 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 			return errCancelHeaderProcessing
 
 		case headers := <-d.headerProcCh:
+			d.heart.Beat()
+
 			// Terminate header processing if we synced up
 			if len(headers) == 0 {
 				// Notify everyone that headers are fully processed
@@ -1362,6 +1376,7 @@ func (d *Downloader) importBlockResults(results []*fetchResult) error {
 		log.Debug("Downloaded item processing failed", "number", results[index].Header.Number, "hash", results[index].Header.Hash(), "err", err)
 		return errInvalidChain
 	}
+	d.heart.Beat()
 	return nil
 }
 
@@ -1505,6 +1520,7 @@ func (d *Downloader) commitFastSyncData(results []*fetchResult, stateSync *state
 		log.Debug("Downloaded item processing failed", "number", results[index].Header.Number, "hash", results[index].Header.Hash(), "err", err)
 		return errInvalidChain
 	}
+	d.heart.Beat()
 	return nil
 }
 
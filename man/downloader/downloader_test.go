@@ -31,7 +31,6 @@ import (
 	"github.com/matrix/go-matrix/core/types"
 	"github.com/matrix/go-matrix/crypto"
 	"github.com/matrix/go-matrix/mandb"
-	"github.com/matrix/go-matrix/event"
 	"github.com/matrix/go-matrix/params"
 	"github.com/matrix/go-matrix/trie"
 )
@@ -96,7 +95,7 @@ func newTester() *downloadTester {
 	tester.stateDb = mandb.NewMemDatabase()
 	tester.stateDb.Put(genesis.Root().Bytes(), []byte{0x00})
 
-	tester.downloader = New(FullSync, tester.stateDb, new(event.TypeMux), tester, nil, tester.dropPeer)
+	tester.downloader = New(FullSync, tester.stateDb, tester, nil, tester.dropPeer)
 
 	return tester
 }
@@ -16,6 +16,9 @@
 
 package downloader
 
-type DoneEvent struct{}
-type StartEvent struct{}
-type FailedEvent struct{ Err error }
+// SyncEvent is posted on the downloader's sync feed when a synchronisation
+// cycle starts and again when it terminates.
+type SyncEvent struct {
+	Syncing bool  // Whether a cycle started (true) or terminated (false)
+	Err     error // Failure terminating the cycle, nil if it succeeded
+}
@@ -40,4 +40,6 @@ var (
 
 	stateInMeter   = metrics.NewRegisteredMeter("man/downloader/states/in", nil)
 	stateDropMeter = metrics.NewRegisteredMeter("man/downloader/states/drop", nil)
+
+	syncEventDropCounter = metrics.NewRegisteredCounter("man/downloader/events/drop", nil)
 )
@@ -304,6 +304,8 @@ func (s *stateSync) loop() (err error) {
 			return errCancelStateFetch
 
 		case req := <-s.deliver:
+			s.d.heart.Beat()
+
 			// Response, disconnect or timeout triggered, drop the peer if stalling
 			log.Trace("Received node data response", "peer", req.peer.id, "count", len(req.response), "dropped", req.dropped, "timeout", !req.dropped && req.timedOut())
 			if len(req.items) <= 2 && !req.dropped && req.timedOut() {
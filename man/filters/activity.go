@@ -0,0 +1,149 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package filters
+
+import (
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+// Roles a watched address can play in an activity notification.
+const (
+	ActivitySender    = "from"
+	ActivityRecipient = "to"
+	ActivityTopic     = "topic"
+)
+
+// AddressActivity is the notification sent to address activity subscribers
+// whenever a watched address shows up in a pending transaction, a mined
+// transaction or the topics of a mined log.
+type AddressActivity struct {
+	Address     common.Address  `json:"address"`
+	Role        string          `json:"role"`
+	Pending     bool            `json:"pending"`
+	TxHash      common.Hash     `json:"transactionHash"`
+	BlockHash   *common.Hash    `json:"blockHash,omitempty"`
+	BlockNumber *hexutil.Uint64 `json:"blockNumber,omitempty"`
+	LogIndex    *hexutil.Uint   `json:"logIndex,omitempty"`
+}
+
+// addressIndex maps every watched address to the subscriptions watching it,
+// so matching an event costs a lookup per address it touches instead of a
+// scan over all subscriptions.
+type addressIndex map[common.Address]map[rpc.ID]*subscription
+
+// add registers the subscription under each of its watched addresses.
+func (idx addressIndex) add(f *subscription) {
+	for _, addr := range f.addresses {
+		if idx[addr] == nil {
+			idx[addr] = make(map[rpc.ID]*subscription)
+		}
+		idx[addr][f.id] = f
+	}
+}
+
+// remove drops the subscription from the index, releasing addresses nobody
+// watches anymore.
+func (idx addressIndex) remove(f *subscription) {
+	for _, addr := range f.addresses {
+		delete(idx[addr], f.id)
+		if len(idx[addr]) == 0 {
+			delete(idx, addr)
+		}
+	}
+}
+
+// activityBatch collects the notifications destined to each subscription
+// while an event is being matched.
+type activityBatch map[*subscription][]*AddressActivity
+
+// match records the activity for every subscription watching addr.
+func (idx addressIndex) match(batch activityBatch, addr common.Address, activity AddressActivity) {
+	subs, ok := idx[addr]
+	if !ok {
+		return
+	}
+	activity.Address = addr
+	for _, f := range subs {
+		a := activity
+		batch[f] = append(batch[f], &a)
+	}
+}
+
+// matchTxs records the sender and recipient activity of the given
+// transactions. A nil block hash marks them as pending.
+func (idx addressIndex) matchTxs(batch activityBatch, txs types.Transactions, hash *common.Hash, number *hexutil.Uint64) {
+	for _, tx := range txs {
+		activity := AddressActivity{
+			Pending:     hash == nil,
+			TxHash:      tx.Hash(),
+			BlockHash:   hash,
+			BlockNumber: number,
+		}
+		if from, err := txSender(tx); err == nil {
+			activity.Role = ActivitySender
+			idx.match(batch, from, activity)
+		}
+		if to := tx.To(); to != nil {
+			activity.Role = ActivityRecipient
+			idx.match(batch, *to, activity)
+		}
+	}
+}
+
+// matchLogs records the activity of watched addresses appearing as indexed
+// topics of the given logs, e.g. the parties of a token transfer.
+func (idx addressIndex) matchLogs(batch activityBatch, logs []*types.Log) {
+	for _, log := range logs {
+		for _, topic := range log.Topics {
+			if !isAddressTopic(topic) {
+				continue
+			}
+			hash, number, index := log.BlockHash, hexutil.Uint64(log.BlockNumber), hexutil.Uint(log.Index)
+			idx.match(batch, common.BytesToAddress(topic[common.HashLength-common.AddressLength:]), AddressActivity{
+				Role:        ActivityTopic,
+				TxHash:      log.TxHash,
+				BlockHash:   &hash,
+				BlockNumber: &number,
+				LogIndex:    &index,
+			})
+		}
+	}
+}
+
+// isAddressTopic reports whether the topic is an address left padded to
+// the size of a hash, which is how indexed address arguments are encoded.
+func isAddressTopic(topic common.Hash) bool {
+	for _, b := range topic[:common.HashLength-common.AddressLength] {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// txSender recovers the sender of a transaction, preferring the sender cached
+// by the transaction pool or the block processor when available.
+func txSender(tx *types.Transaction) (common.Address, error) {
+	var signer types.Signer = types.HomesteadSigner{}
+	if tx.Protected() {
+		signer = types.NewEIP155Signer(tx.ChainId())
+	}
+	return types.Sender(signer, tx)
+}
@@ -30,7 +30,6 @@ import (
 	"github.com/matrix/go-matrix/common/hexutil"
 	"github.com/matrix/go-matrix/core/types"
 	"github.com/matrix/go-matrix/mandb"
-	"github.com/matrix/go-matrix/event"
 	"github.com/matrix/go-matrix/rpc"
 )
 
@@ -53,7 +52,6 @@ type filter struct {
 // information related to the Matrix protocol such als blocks, transactions and logs.
 type PublicFilterAPI struct {
 	backend   Backend
-	mux       *event.TypeMux
 	quit      chan struct{}
 	chainDb   mandb.Database
 	events    *EventSystem
@@ -65,9 +63,8 @@ type PublicFilterAPI struct {
 func NewPublicFilterAPI(backend Backend, lightMode bool) *PublicFilterAPI {
 	api := &PublicFilterAPI{
 		backend: backend,
-		mux:     backend.EventMux(),
 		chainDb: backend.ChainDb(),
-		events:  NewEventSystem(backend.EventMux(), backend, lightMode),
+		events:  NewEventSystem(backend, lightMode),
 		filters: make(map[rpc.ID]*filter),
 	}
 	go api.timeoutLoop()
@@ -271,6 +268,81 @@ func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc
 	return rpcSub, nil
 }
 
+// AddressActivity creates a subscription that fires each time one of the given
+// addresses is the sender or recipient of a transaction entering the pool or
+// included in a new block, or appears as an indexed topic of a new log.
+func (api *PublicFilterAPI) AddressActivity(ctx context.Context, addresses []common.Address) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if len(addresses) == 0 {
+		return nil, errors.New("no addresses to watch")
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		activity := make(chan []*AddressActivity, 128)
+		activitySub := api.events.SubscribeAddressActivity(addresses, activity)
+
+		for {
+			select {
+			case batch := <-activity:
+				for _, a := range batch {
+					notifier.Notify(rpcSub.ID, a)
+				}
+			case <-rpcSub.Err():
+				activitySub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				activitySub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// BalanceChanges creates a subscription that fires for each new block changing
+// the balance of one of the given addresses, once per causing transaction, with
+// the balance before and after it. Changes made outside of transactions, like
+// block rewards, are sent without a transaction hash.
+func (api *PublicFilterAPI) BalanceChanges(ctx context.Context, addresses []common.Address) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if len(addresses) == 0 {
+		return nil, errors.New("no addresses to watch")
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		balances := make(chan []*BalanceChange, 128)
+		balanceSub := api.events.SubscribeBalanceChanges(addresses, balances)
+
+		for {
+			select {
+			case changes := <-balances:
+				for _, c := range changes {
+					notifier.Notify(rpcSub.ID, c)
+				}
+			case <-rpcSub.Err():
+				balanceSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				balanceSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // FilterCriteria represents a request to create a new filter.
 // Same as matrix.FilterQuery but with UnmarshalJSON() method.
 type FilterCriteria matrix.FilterQuery
@@ -0,0 +1,67 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package filters
+
+import (
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core"
+)
+
+// BalanceChange is the notification sent to balance change subscribers for
+// every transaction of a new block changing the balance of a watched address.
+type BalanceChange struct {
+	Address     common.Address `json:"address"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	TxHash      *common.Hash   `json:"transactionHash"` // Nil for block rewards and other changes outside of transactions
+	OldBalance  *hexutil.Big   `json:"oldBalance"`
+	NewBalance  *hexutil.Big   `json:"newBalance"`
+}
+
+// balanceBatch collects the notifications destined to each subscription while
+// the balance changes of a block are being matched.
+type balanceBatch map[*subscription][]*BalanceChange
+
+// matchBalances records the balance changes of a block for every subscription
+// watching the changed accounts.
+func (idx addressIndex) matchBalances(batch balanceBatch, ev core.BalanceChangeEvent) {
+	var (
+		hash   = ev.Block.Hash()
+		number = hexutil.Uint64(ev.Block.NumberU64())
+	)
+	for _, change := range ev.Changes {
+		subs, ok := idx[change.Address]
+		if !ok {
+			continue
+		}
+		notification := &BalanceChange{
+			Address:     change.Address,
+			BlockHash:   hash,
+			BlockNumber: number,
+			OldBalance:  (*hexutil.Big)(change.Prev),
+			NewBalance:  (*hexutil.Big)(change.Balance),
+		}
+		if change.TxHash != (common.Hash{}) {
+			txHash := change.TxHash
+			notification.TxHash = &txHash
+		}
+		for _, f := range subs {
+			batch[f] = append(batch[f], notification)
+		}
+	}
+}
@@ -27,6 +27,7 @@ import (
 
 	matrix "github.com/matrix/go-matrix"
 	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
 	"github.com/matrix/go-matrix/core"
 	"github.com/matrix/go-matrix/core/rawdb"
 	"github.com/matrix/go-matrix/core/types"
@@ -53,6 +54,12 @@ const (
 	PendingTransactionsSubscription
 	// BlocksSubscription queries hashes for blocks that are imported
 	BlocksSubscription
+	// AddressActivitySubscription queries for pending and mined transactions
+	// and logs involving a set of watched addresses
+	AddressActivitySubscription
+	// BalanceChangesSubscription queries for the balance changes of a set of
+	// watched addresses in imported blocks
+	BalanceChangesSubscription
 	// LastSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -82,6 +89,9 @@ type subscription struct {
 	logs      chan []*types.Log
 	hashes    chan []common.Hash
 	headers   chan *types.Header
+	addresses []common.Address // addresses watched by activity subscriptions
+	activity  chan []*AddressActivity
+	balances  chan []*BalanceChange
 	installed chan struct{} // closed when the filter is installed
 	err       chan error    // closed when the filter is uninstalled
 }
@@ -89,44 +99,52 @@ type subscription struct {
 // EventSystem creates subscriptions, processes events and broadcasts them to the
 // subscription which match the subscription criteria.
 type EventSystem struct {
-	mux       *event.TypeMux
 	backend   Backend
 	lightMode bool
 	lastHead  *types.Header
+	watched   addressIndex // watched addresses, only accessed by the event loop
+	balanced  addressIndex // addresses watched for balance changes, only accessed by the event loop
 
 	// Subscriptions
-	txsSub        event.Subscription         // Subscription for new transaction event
-	logsSub       event.Subscription         // Subscription for new log event
-	rmLogsSub     event.Subscription         // Subscription for removed log event
-	chainSub      event.Subscription         // Subscription for new chain event
-	pendingLogSub *event.TypeMuxSubscription // Subscription for pending log event
+	txsSub        event.Subscription // Subscription for new transaction event
+	logsSub       event.Subscription // Subscription for new log event
+	rmLogsSub     event.Subscription // Subscription for removed log event
+	chainSub      event.Subscription // Subscription for new chain event
+	pendingLogSub event.Subscription // Subscription for pending log event
+	balanceSub    event.Subscription // Subscription for balance change event
 
 	// Channels
-	install   chan *subscription         // install filter for event notification
-	uninstall chan *subscription         // remove filter for event notification
-	txsCh     chan core.NewTxsEvent      // Channel to receive new transactions event
-	logsCh    chan []*types.Log          // Channel to receive new log event
-	rmLogsCh  chan core.RemovedLogsEvent // Channel to receive removed log event
-	chainCh   chan core.ChainEvent       // Channel to receive new chain event
+	install       chan *subscription           // install filter for event notification
+	uninstall     chan *subscription           // remove filter for event notification
+	txsCh         chan core.NewTxsEvent        // Channel to receive new transactions event
+	logsCh        chan []*types.Log            // Channel to receive new log event
+	rmLogsCh      chan core.RemovedLogsEvent   // Channel to receive removed log event
+	chainCh       chan core.ChainEvent         // Channel to receive new chain event
+	pendingLogsCh chan core.PendingLogsEvent   // Channel to receive pending log event
+	balanceCh     chan core.BalanceChangeEvent // Channel to receive balance change event
 }
 
-// NewEventSystem creates a new manager that listens for event on the given mux,
-// parses and filters them. It uses the all map to retrieve filter changes. The
-// work loop holds its own index that is used to forward events to filters.
+// NewEventSystem creates a new manager that listens for event on the given
+// backend, parses and filters them. It uses the all map to retrieve filter
+// changes. The work loop holds its own index that is used to forward events to
+// filters.
 //
-// The returned manager has a loop that needs to be stopped with the Stop function
-// or by stopping the given mux.
-func NewEventSystem(mux *event.TypeMux, backend Backend, lightMode bool) *EventSystem {
+// The returned manager has a loop that is stopped once the backend feeds are
+// closed.
+func NewEventSystem(backend Backend, lightMode bool) *EventSystem {
 	m := &EventSystem{
-		mux:       mux,
-		backend:   backend,
-		lightMode: lightMode,
-		install:   make(chan *subscription),
-		uninstall: make(chan *subscription),
-		txsCh:     make(chan core.NewTxsEvent, txChanSize),
-		logsCh:    make(chan []*types.Log, logsChanSize),
-		rmLogsCh:  make(chan core.RemovedLogsEvent, rmLogsChanSize),
-		chainCh:   make(chan core.ChainEvent, chainEvChanSize),
+		backend:       backend,
+		lightMode:     lightMode,
+		watched:       make(addressIndex),
+		balanced:      make(addressIndex),
+		install:       make(chan *subscription),
+		uninstall:     make(chan *subscription),
+		txsCh:         make(chan core.NewTxsEvent, txChanSize),
+		logsCh:        make(chan []*types.Log, logsChanSize),
+		rmLogsCh:      make(chan core.RemovedLogsEvent, rmLogsChanSize),
+		chainCh:       make(chan core.ChainEvent, chainEvChanSize),
+		pendingLogsCh: make(chan core.PendingLogsEvent, logsChanSize),
+		balanceCh:     make(chan core.BalanceChangeEvent, chainEvChanSize),
 	}
 
 	// Subscribe events
@@ -134,12 +152,12 @@ func NewEventSystem(mux *event.TypeMux, backend Backend, lightMode bool) *EventS
 	m.logsSub = m.backend.SubscribeLogsEvent(m.logsCh)
 	m.rmLogsSub = m.backend.SubscribeRemovedLogsEvent(m.rmLogsCh)
 	m.chainSub = m.backend.SubscribeChainEvent(m.chainCh)
-	// TODO(rjl493456442): use feed to subscribe pending log event
-	m.pendingLogSub = m.mux.Subscribe(core.PendingLogsEvent{})
+	m.pendingLogSub = m.backend.SubscribePendingLogsEvent(m.pendingLogsCh)
+	m.balanceSub = m.backend.SubscribeBalanceChangeEvent(m.balanceCh)
 
 	// Make sure none of the subscriptions are empty
 	if m.txsSub == nil || m.logsSub == nil || m.rmLogsSub == nil || m.chainSub == nil ||
-		m.pendingLogSub.Closed() {
+		m.pendingLogSub == nil || m.balanceSub == nil {
 		log.Crit("Subscribe for event system failed")
 	}
 
@@ -175,6 +193,8 @@ func (sub *Subscription) Unsubscribe() {
 			case <-sub.f.logs:
 			case <-sub.f.hashes:
 			case <-sub.f.headers:
+			case <-sub.f.activity:
+			case <-sub.f.balances:
 			}
 		}
 
@@ -314,6 +334,44 @@ func (es *EventSystem) SubscribePendingTxs(hashes chan []common.Hash) *Subscript
 	return es.subscribe(sub)
 }
 
+// SubscribeAddressActivity creates a subscription that writes a notification
+// each time one of the given addresses is the sender or recipient of a pending
+// or mined transaction, or appears as a topic of a mined log.
+func (es *EventSystem) SubscribeAddressActivity(addresses []common.Address, activity chan []*AddressActivity) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       AddressActivitySubscription,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		hashes:    make(chan []common.Hash),
+		headers:   make(chan *types.Header),
+		addresses: addresses,
+		activity:  activity,
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
+// SubscribeBalanceChanges creates a subscription that writes the balance
+// changes of the given addresses, along with the transactions causing them,
+// for each new canonical block.
+func (es *EventSystem) SubscribeBalanceChanges(addresses []common.Address, balances chan []*BalanceChange) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       BalanceChangesSubscription,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		hashes:    make(chan []common.Hash),
+		headers:   make(chan *types.Header),
+		addresses: addresses,
+		balances:  balances,
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
 type filterIndex map[Type]map[rpc.ID]*subscription
 
 // broadcast event to filters that match criteria.
@@ -337,15 +395,10 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 				f.logs <- matchedLogs
 			}
 		}
-	case *event.TypeMuxEvent:
-		switch muxe := e.Data.(type) {
-		case core.PendingLogsEvent:
-			for _, f := range filters[PendingLogsSubscription] {
-				if e.Time.After(f.created) {
-					if matchedLogs := filterLogs(muxe.Logs, nil, f.logsCrit.ToBlock, f.logsCrit.Addresses, f.logsCrit.Topics); len(matchedLogs) > 0 {
-						f.logs <- matchedLogs
-					}
-				}
+	case core.PendingLogsEvent:
+		for _, f := range filters[PendingLogsSubscription] {
+			if matchedLogs := filterLogs(e.Logs, nil, f.logsCrit.ToBlock, f.logsCrit.Addresses, f.logsCrit.Topics); len(matchedLogs) > 0 {
+				f.logs <- matchedLogs
 			}
 		}
 	case core.NewTxsEvent:
@@ -356,10 +409,25 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 		for _, f := range filters[PendingTransactionsSubscription] {
 			f.hashes <- hashes
 		}
+		if len(es.watched) > 0 {
+			batch := make(activityBatch)
+			es.watched.matchTxs(batch, e.Txs, nil, nil)
+			es.deliverActivity(batch)
+		}
 	case core.ChainEvent:
 		for _, f := range filters[BlocksSubscription] {
 			f.headers <- e.Block.Header()
 		}
+		if len(es.watched) > 0 {
+			var (
+				batch  = make(activityBatch)
+				hash   = e.Hash
+				number = hexutil.Uint64(e.Block.NumberU64())
+			)
+			es.watched.matchTxs(batch, e.Block.Transactions(), &hash, &number)
+			es.watched.matchLogs(batch, e.Logs)
+			es.deliverActivity(batch)
+		}
 		if es.lightMode && len(filters[LogsSubscription]) > 0 {
 			es.lightFilterNewHead(e.Block.Header(), func(header *types.Header, remove bool) {
 				for _, f := range filters[LogsSubscription] {
@@ -369,6 +437,21 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 				}
 			})
 		}
+	case core.BalanceChangeEvent:
+		if len(es.balanced) > 0 {
+			batch := make(balanceBatch)
+			es.balanced.matchBalances(batch, e)
+			for f, changes := range batch {
+				f.balances <- changes
+			}
+		}
+	}
+}
+
+// deliverActivity sends the matched address activity to its subscribers.
+func (es *EventSystem) deliverActivity(batch activityBatch) {
+	for f, activity := range batch {
+		f.activity <- activity
 	}
 }
 
@@ -445,7 +528,7 @@ func (es *EventSystem) lightFilterLogs(header *types.Header, addresses []common.
 	return nil
 }
 
-// eventLoop (un)installs filters and processes mux events.
+// eventLoop (un)installs filters and processes backend events.
 func (es *EventSystem) eventLoop() {
 	// Ensure all subscriptions get cleaned up
 	defer func() {
@@ -454,6 +537,7 @@ func (es *EventSystem) eventLoop() {
 		es.logsSub.Unsubscribe()
 		es.rmLogsSub.Unsubscribe()
 		es.chainSub.Unsubscribe()
+		es.balanceSub.Unsubscribe()
 	}()
 
 	index := make(filterIndex)
@@ -472,10 +556,9 @@ func (es *EventSystem) eventLoop() {
 			es.broadcast(index, ev)
 		case ev := <-es.chainCh:
 			es.broadcast(index, ev)
-		case ev, active := <-es.pendingLogSub.Chan():
-			if !active { // system stopped
-				return
-			}
+		case ev := <-es.pendingLogsCh:
+			es.broadcast(index, ev)
+		case ev := <-es.balanceCh:
 			es.broadcast(index, ev)
 
 		case f := <-es.install:
@@ -486,6 +569,12 @@ func (es *EventSystem) eventLoop() {
 			} else {
 				index[f.typ][f.id] = f
 			}
+			switch f.typ {
+			case AddressActivitySubscription:
+				es.watched.add(f)
+			case BalanceChangesSubscription:
+				es.balanced.add(f)
+			}
 			close(f.installed)
 
 		case f := <-es.uninstall:
@@ -496,6 +585,12 @@ func (es *EventSystem) eventLoop() {
 			} else {
 				delete(index[f.typ], f.id)
 			}
+			switch f.typ {
+			case AddressActivitySubscription:
+				es.watched.remove(f)
+			case BalanceChangesSubscription:
+				es.balanced.remove(f)
+			}
 			close(f.err)
 
 		// System stopped
@@ -507,6 +602,10 @@ func (es *EventSystem) eventLoop() {
 			return
 		case <-es.chainSub.Err():
 			return
+		case <-es.pendingLogSub.Err():
+			return
+		case <-es.balanceSub.Err():
+			return
 		}
 	}
 }
@@ -31,31 +31,30 @@ import (
 	"github.com/matrix/go-matrix/core"
 	"github.com/matrix/go-matrix/core/bloombits"
 	"github.com/matrix/go-matrix/core/rawdb"
+	"github.com/matrix/go-matrix/core/state"
 	"github.com/matrix/go-matrix/core/types"
-	"github.com/matrix/go-matrix/mandb"
+	"github.com/matrix/go-matrix/crypto"
 	"github.com/matrix/go-matrix/event"
+	"github.com/matrix/go-matrix/mandb"
 	"github.com/matrix/go-matrix/params"
 	"github.com/matrix/go-matrix/rpc"
 )
 
 type testBackend struct {
-	mux        *event.TypeMux
-	db         mandb.Database
-	sections   uint64
-	txFeed     *event.Feed
-	rmLogsFeed *event.Feed
-	logsFeed   *event.Feed
-	chainFeed  *event.Feed
+	pendingLogsFeed *event.Feed
+	db              mandb.Database
+	sections        uint64
+	txFeed          *event.Feed
+	rmLogsFeed      *event.Feed
+	logsFeed        *event.Feed
+	chainFeed       *event.Feed
+	balanceFeed     *event.Feed
 }
 
 func (b *testBackend) ChainDb() mandb.Database {
 	return b.db
 }
 
-func (b *testBackend) EventMux() *event.TypeMux {
-	return b.mux
-}
-
 func (b *testBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
 	var (
 		hash common.Hash
@@ -108,10 +107,18 @@ func (b *testBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscript
 	return b.logsFeed.Subscribe(ch)
 }
 
+func (b *testBackend) SubscribePendingLogsEvent(ch chan<- core.PendingLogsEvent) event.Subscription {
+	return b.pendingLogsFeed.Subscribe(ch)
+}
+
 func (b *testBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
 	return b.chainFeed.Subscribe(ch)
 }
 
+func (b *testBackend) SubscribeBalanceChangeEvent(ch chan<- core.BalanceChangeEvent) event.Subscription {
+	return b.balanceFeed.Subscribe(ch)
+}
+
 func (b *testBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, b.sections
 }
@@ -152,17 +159,17 @@ func TestBlockSubscription(t *testing.T) {
 	t.Parallel()
 
 	var (
-		mux         = new(event.TypeMux)
-		db          = mandb.NewMemDatabase()
-		txFeed      = new(event.Feed)
-		rmLogsFeed  = new(event.Feed)
-		logsFeed    = new(event.Feed)
-		chainFeed   = new(event.Feed)
-		backend     = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api         = NewPublicFilterAPI(backend, false)
-		genesis     = new(core.Genesis).MustCommit(db)
-		chain, _    = core.GenerateChain(params.TestChainConfig, genesis, manash.NewFaker(), db, 10, func(i int, gen *core.BlockGen) {})
-		chainEvents = []core.ChainEvent{}
+		pendingLogsFeed = new(event.Feed)
+		db              = mandb.NewMemDatabase()
+		txFeed          = new(event.Feed)
+		rmLogsFeed      = new(event.Feed)
+		logsFeed        = new(event.Feed)
+		chainFeed       = new(event.Feed)
+		backend         = &testBackend{pendingLogsFeed, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		api             = NewPublicFilterAPI(backend, false)
+		genesis         = new(core.Genesis).MustCommit(db)
+		chain, _        = core.GenerateChain(params.TestChainConfig, genesis, manash.NewFaker(), db, 10, func(i int, gen *core.BlockGen) {})
+		chainEvents     = []core.ChainEvent{}
 	)
 
 	for _, blk := range chain {
@@ -209,14 +216,14 @@ func TestPendingTxFilter(t *testing.T) {
 	t.Parallel()
 
 	var (
-		mux        = new(event.TypeMux)
-		db         = mandb.NewMemDatabase()
-		txFeed     = new(event.Feed)
-		rmLogsFeed = new(event.Feed)
-		logsFeed   = new(event.Feed)
-		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api        = NewPublicFilterAPI(backend, false)
+		pendingLogsFeed = new(event.Feed)
+		db              = mandb.NewMemDatabase()
+		txFeed          = new(event.Feed)
+		rmLogsFeed      = new(event.Feed)
+		logsFeed        = new(event.Feed)
+		chainFeed       = new(event.Feed)
+		backend         = &testBackend{pendingLogsFeed, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		api             = NewPublicFilterAPI(backend, false)
 
 		transactions = []*types.Transaction{
 			types.NewTransaction(0, common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268"), new(big.Int), 0, new(big.Int), nil),
@@ -265,18 +272,151 @@ func TestPendingTxFilter(t *testing.T) {
 	}
 }
 
+// TestAddressActivitySubscription tests that address activity subscriptions
+// are notified about watched addresses in pending transactions, mined
+// transactions and mined log topics, and about nothing else.
+func TestAddressActivitySubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		pendingLogsFeed = new(event.Feed)
+		db              = mandb.NewMemDatabase()
+		txFeed          = new(event.Feed)
+		rmLogsFeed      = new(event.Feed)
+		logsFeed        = new(event.Feed)
+		chainFeed       = new(event.Feed)
+		backend         = &testBackend{pendingLogsFeed, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		api             = NewPublicFilterAPI(backend, false)
+
+		key, _    = crypto.GenerateKey()
+		sender    = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268")
+		other     = common.HexToAddress("0x0000000000000000000000000000000000001337")
+	)
+	sign := func(nonce uint64, to common.Address) *types.Transaction {
+		tx, err := types.SignTx(types.NewTransaction(nonce, to, new(big.Int), 21000, new(big.Int), nil), types.HomesteadSigner{}, key)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		return tx
+	}
+	var (
+		pendingTx  = sign(0, recipient)
+		ignoredTx  = types.NewTransaction(0, other, new(big.Int), 0, new(big.Int), nil)
+		minedTx    = sign(1, other)
+		block      = types.NewBlock(&types.Header{Number: big.NewInt(1)}, []*types.Transaction{minedTx}, nil, nil)
+		transferLg = &types.Log{
+			Address:     other,
+			Topics:      []common.Hash{common.HexToHash("0xddf252ad"), other.Hash(), recipient.Hash()},
+			BlockNumber: 1,
+			BlockHash:   block.Hash(),
+			TxHash:      minedTx.Hash(),
+			Index:       3,
+		}
+	)
+
+	activity := make(chan []*AddressActivity)
+	sub := api.events.SubscribeAddressActivity([]common.Address{sender, recipient}, activity)
+	defer sub.Unsubscribe()
+
+	// Unrelated transactions must not trigger notifications
+	txFeed.Send(core.NewTxsEvent{Txs: []*types.Transaction{ignoredTx}})
+
+	expect := func(want []AddressActivity) {
+		select {
+		case got := <-activity:
+			if len(got) != len(want) {
+				t.Fatalf("activity count mismatch: have %d, want %d", len(got), len(want))
+			}
+			for i, a := range got {
+				if a.Address != want[i].Address || a.Role != want[i].Role || a.Pending != want[i].Pending || a.TxHash != want[i].TxHash {
+					t.Errorf("activity %d mismatch: have %+v, want %+v", i, *a, want[i])
+				}
+				if !a.Pending && (a.BlockHash == nil || *a.BlockHash != block.Hash() || a.BlockNumber == nil || uint64(*a.BlockNumber) != 1) {
+					t.Errorf("activity %d: invalid block reference", i)
+				}
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for address activity")
+		}
+	}
+
+	txFeed.Send(core.NewTxsEvent{Txs: []*types.Transaction{pendingTx}})
+	expect([]AddressActivity{
+		{Address: sender, Role: ActivitySender, Pending: true, TxHash: pendingTx.Hash()},
+		{Address: recipient, Role: ActivityRecipient, Pending: true, TxHash: pendingTx.Hash()},
+	})
+
+	chainFeed.Send(core.ChainEvent{Block: block, Hash: block.Hash(), Logs: []*types.Log{transferLg}})
+	expect([]AddressActivity{
+		{Address: sender, Role: ActivitySender, TxHash: minedTx.Hash()},
+		{Address: recipient, Role: ActivityTopic, TxHash: minedTx.Hash()},
+	})
+}
+
+// TestBalanceChangesSubscription tests that balance change subscriptions are
+// notified about the changes of watched addresses only.
+func TestBalanceChangesSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		pendingLogsFeed = new(event.Feed)
+		db              = mandb.NewMemDatabase()
+		txFeed          = new(event.Feed)
+		rmLogsFeed      = new(event.Feed)
+		logsFeed        = new(event.Feed)
+		chainFeed       = new(event.Feed)
+		balanceFeed     = new(event.Feed)
+		backend         = &testBackend{pendingLogsFeed, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, balanceFeed}
+		api             = NewPublicFilterAPI(backend, false)
+
+		watched = common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268")
+		other   = common.HexToAddress("0x0000000000000000000000000000000000001337")
+		txHash  = common.HexToHash("0x01")
+		block   = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(7)})
+	)
+	balances := make(chan []*BalanceChange)
+	sub := api.events.SubscribeBalanceChanges([]common.Address{watched}, balances)
+	defer sub.Unsubscribe()
+
+	balanceFeed.Send(core.BalanceChangeEvent{Block: block, Changes: []*state.BalanceChange{
+		{Address: other, TxHash: txHash, Prev: big.NewInt(10), Balance: big.NewInt(5)},
+		{Address: watched, TxHash: txHash, Prev: big.NewInt(0), Balance: big.NewInt(5)},
+		{Address: watched, Prev: big.NewInt(5), Balance: big.NewInt(8)},
+	}})
+	select {
+	case changes := <-balances:
+		if len(changes) != 2 {
+			t.Fatalf("change count mismatch: have %d, want 2", len(changes))
+		}
+		for i, c := range changes {
+			if c.Address != watched || c.BlockHash != block.Hash() || uint64(c.BlockNumber) != 7 {
+				t.Errorf("change %d: invalid block or address: %+v", i, c)
+			}
+		}
+		if changes[0].TxHash == nil || *changes[0].TxHash != txHash || changes[0].OldBalance.ToInt().Int64() != 0 || changes[0].NewBalance.ToInt().Int64() != 5 {
+			t.Errorf("transfer change mismatch: %+v", changes[0])
+		}
+		if changes[1].TxHash != nil || changes[1].OldBalance.ToInt().Int64() != 5 || changes[1].NewBalance.ToInt().Int64() != 8 {
+			t.Errorf("reward change mismatch: %+v", changes[1])
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timeout waiting for balance changes")
+	}
+}
+
 // TestLogFilterCreation test whether a given filter criteria makes sense.
 // If not it must return an error.
 func TestLogFilterCreation(t *testing.T) {
 	var (
-		mux        = new(event.TypeMux)
-		db         = mandb.NewMemDatabase()
-		txFeed     = new(event.Feed)
-		rmLogsFeed = new(event.Feed)
-		logsFeed   = new(event.Feed)
-		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api        = NewPublicFilterAPI(backend, false)
+		pendingLogsFeed = new(event.Feed)
+		db              = mandb.NewMemDatabase()
+		txFeed          = new(event.Feed)
+		rmLogsFeed      = new(event.Feed)
+		logsFeed        = new(event.Feed)
+		chainFeed       = new(event.Feed)
+		backend         = &testBackend{pendingLogsFeed, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		api             = NewPublicFilterAPI(backend, false)
 
 		testCases = []struct {
 			crit    FilterCriteria
@@ -318,14 +458,14 @@ func TestInvalidLogFilterCreation(t *testing.T) {
 	t.Parallel()
 
 	var (
-		mux        = new(event.TypeMux)
-		db         = mandb.NewMemDatabase()
-		txFeed     = new(event.Feed)
-		rmLogsFeed = new(event.Feed)
-		logsFeed   = new(event.Feed)
-		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api        = NewPublicFilterAPI(backend, false)
+		pendingLogsFeed = new(event.Feed)
+		db              = mandb.NewMemDatabase()
+		txFeed          = new(event.Feed)
+		rmLogsFeed      = new(event.Feed)
+		logsFeed        = new(event.Feed)
+		chainFeed       = new(event.Feed)
+		backend         = &testBackend{pendingLogsFeed, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		api             = NewPublicFilterAPI(backend, false)
 	)
 
 	// different situations where log filter creation should fail.
@@ -348,14 +488,14 @@ func TestLogFilter(t *testing.T) {
 	t.Parallel()
 
 	var (
-		mux        = new(event.TypeMux)
-		db         = mandb.NewMemDatabase()
-		txFeed     = new(event.Feed)
-		rmLogsFeed = new(event.Feed)
-		logsFeed   = new(event.Feed)
-		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api        = NewPublicFilterAPI(backend, false)
+		pendingLogsFeed = new(event.Feed)
+		db              = mandb.NewMemDatabase()
+		txFeed          = new(event.Feed)
+		rmLogsFeed      = new(event.Feed)
+		logsFeed        = new(event.Feed)
+		chainFeed       = new(event.Feed)
+		backend         = &testBackend{pendingLogsFeed, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		api             = NewPublicFilterAPI(backend, false)
 
 		firstAddr      = common.HexToAddress("0x1111111111111111111111111111111111111111")
 		secondAddr     = common.HexToAddress("0x2222222222222222222222222222222222222222")
@@ -421,8 +561,8 @@ func TestLogFilter(t *testing.T) {
 	if nsend := logsFeed.Send(allLogs); nsend == 0 {
 		t.Fatal("Shoud have at least one subscription")
 	}
-	if err := mux.Post(core.PendingLogsEvent{Logs: allLogs}); err != nil {
-		t.Fatal(err)
+	if nsend := pendingLogsFeed.Send(core.PendingLogsEvent{Logs: allLogs}); nsend == 0 {
+		t.Fatal("Shoud have at least one subscription")
 	}
 
 	for i, tt := range testCases {
@@ -467,14 +607,14 @@ func TestPendingLogsSubscription(t *testing.T) {
 	t.Parallel()
 
 	var (
-		mux        = new(event.TypeMux)
-		db         = mandb.NewMemDatabase()
-		txFeed     = new(event.Feed)
-		rmLogsFeed = new(event.Feed)
-		logsFeed   = new(event.Feed)
-		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		api        = NewPublicFilterAPI(backend, false)
+		pendingLogsFeed = new(event.Feed)
+		db              = mandb.NewMemDatabase()
+		txFeed          = new(event.Feed)
+		rmLogsFeed      = new(event.Feed)
+		logsFeed        = new(event.Feed)
+		chainFeed       = new(event.Feed)
+		backend         = &testBackend{pendingLogsFeed, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		api             = NewPublicFilterAPI(backend, false)
 
 		firstAddr      = common.HexToAddress("0x1111111111111111111111111111111111111111")
 		secondAddr     = common.HexToAddress("0x2222222222222222222222222222222222222222")
@@ -574,8 +714,6 @@ func TestPendingLogsSubscription(t *testing.T) {
 	time.Sleep(1 * time.Second)
 	// allLogs are type of core.PendingLogsEvent
 	for _, l := range allLogs {
-		if err := mux.Post(l); err != nil {
-			t.Fatal(err)
-		}
+		pendingLogsFeed.Send(l)
 	}
 }
@@ -29,8 +29,8 @@ import (
 	"github.com/matrix/go-matrix/core/rawdb"
 	"github.com/matrix/go-matrix/core/types"
 	"github.com/matrix/go-matrix/crypto"
-	"github.com/matrix/go-matrix/mandb"
 	"github.com/matrix/go-matrix/event"
+	"github.com/matrix/go-matrix/mandb"
 	"github.com/matrix/go-matrix/params"
 )
 
@@ -51,18 +51,18 @@ func BenchmarkFilters(b *testing.B) {
 	defer os.RemoveAll(dir)
 
 	var (
-		db, _      = mandb.NewLDBDatabase(dir, 0, 0)
-		mux        = new(event.TypeMux)
-		txFeed     = new(event.Feed)
-		rmLogsFeed = new(event.Feed)
-		logsFeed   = new(event.Feed)
-		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		key1, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
-		addr1      = crypto.PubkeyToAddress(key1.PublicKey)
-		addr2      = common.BytesToAddress([]byte("jeff"))
-		addr3      = common.BytesToAddress([]byte("matrix"))
-		addr4      = common.BytesToAddress([]byte("random addresses please"))
+		db, _           = mandb.NewLDBDatabase(dir, 0, 0)
+		pendingLogsFeed = new(event.Feed)
+		txFeed          = new(event.Feed)
+		rmLogsFeed      = new(event.Feed)
+		logsFeed        = new(event.Feed)
+		chainFeed       = new(event.Feed)
+		backend         = &testBackend{pendingLogsFeed, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		key1, _         = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr1           = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2           = common.BytesToAddress([]byte("jeff"))
+		addr3           = common.BytesToAddress([]byte("matrix"))
+		addr4           = common.BytesToAddress([]byte("random addresses please"))
 	)
 	defer db.Close()
 
@@ -110,15 +110,15 @@ func TestFilters(t *testing.T) {
 	defer os.RemoveAll(dir)
 
 	var (
-		db, _      = mandb.NewLDBDatabase(dir, 0, 0)
-		mux        = new(event.TypeMux)
-		txFeed     = new(event.Feed)
-		rmLogsFeed = new(event.Feed)
-		logsFeed   = new(event.Feed)
-		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
-		key1, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
-		addr       = crypto.PubkeyToAddress(key1.PublicKey)
+		db, _           = mandb.NewLDBDatabase(dir, 0, 0)
+		pendingLogsFeed = new(event.Feed)
+		txFeed          = new(event.Feed)
+		rmLogsFeed      = new(event.Feed)
+		logsFeed        = new(event.Feed)
+		chainFeed       = new(event.Feed)
+		backend         = &testBackend{pendingLogsFeed, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
+		key1, _         = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr            = crypto.PubkeyToAddress(key1.PublicKey)
 
 		hash1 = common.BytesToHash([]byte("topic1"))
 		hash2 = common.BytesToHash([]byte("topic2"))
@@ -229,4 +229,17 @@ func TestFilters(t *testing.T) {
 	if len(logs) != 0 {
 		t.Error("expected 0 log, got", len(logs))
 	}
+
+	// a cancelled query stops scanning the chain
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	filter = New(backend, 0, -1, []common.Address{addr}, [][]common.Hash{{hash1, hash2, hash3, hash4}})
+
+	logs, err = filter.Logs(ctx)
+	if err != context.Canceled {
+		t.Errorf("expected error %v, got %v", context.Canceled, err)
+	}
+	if len(logs) != 0 {
+		t.Error("expected 0 log, got", len(logs))
+	}
 }
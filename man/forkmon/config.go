@@ -0,0 +1,38 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package forkmon
+
+import "time"
+
+// DefaultConfig contains default settings for the fork monitor.
+var DefaultConfig = Config{
+	Depth:    6,
+	Interval: 15 * time.Second,
+}
+
+// Config contains the configuration parameters of the fork monitor.
+type Config struct {
+	// Depth is the number of blocks the local head may diverge from the branch
+	// followed by the majority of the peers before an alert is raised.
+	Depth uint64
+
+	// Interval is the time between two checks of the peer heads.
+	Interval time.Duration `toml:",omitempty"`
+
+	// Webhook is an optional URL alerts are posted to as JSON.
+	Webhook string `toml:",omitempty"`
+}
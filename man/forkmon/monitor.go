@@ -0,0 +1,441 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package forkmon tracks the chain branches followed by the connected peers and
+// raises alerts when the local head splits off from the majority of them.
+package forkmon
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/metrics"
+)
+
+const (
+	maxForkWalk    = 1024             // Maximum number of blocks walked back looking for a fork point
+	maxProbeWalk   = 64               // Maximum number of headers fetched from a peer looking for a fork point
+	maxProbes      = 16               // Maximum number of peers asked for their head per check
+	webhookTimeout = 10 * time.Second // Maximum time allowed for delivering an alert to the webhook
+)
+
+// probeDeadline is the maximum time a check waits for the peers to answer the
+// head probes, which all run concurrently.
+var probeDeadline = 5 * time.Second
+
+var (
+	branchesGauge   = metrics.NewRegisteredGauge("man/forkmon/branches", nil)
+	shareGauge      = metrics.NewRegisteredGauge("man/forkmon/share", nil) // Percentage of the peers following the local branch
+	divergenceGauge = metrics.NewRegisteredGauge("man/forkmon/divergence", nil)
+	alertMeter      = metrics.NewRegisteredMeter("man/forkmon/alerts", nil)
+)
+
+// Chain is the subset of the local chain the monitor needs to place the peer
+// heads relative to the local head.
+type Chain interface {
+	CurrentHeader() *types.Header
+	GetHeaderByHash(hash common.Hash) *types.Header
+	GetHeaderByNumber(number uint64) *types.Header
+}
+
+// PeerHeads returns the head hashes announced by the connected peers, keyed by
+// peer id.
+type PeerHeads func() map[string]common.Hash
+
+// HeaderProber retrieves a batch of headers from a peer, walking back from the
+// given hash. It is used to place peer heads not known locally.
+type HeaderProber func(peer string, origin common.Hash, amount int) ([]*types.Header, error)
+
+// Branch is a chain branch followed by a group of peers.
+type Branch struct {
+	Local    bool        `json:"local"`    // Whether the branch contains the local head
+	Root     common.Hash `json:"root"`     // First block after the fork point, zero for the local branch
+	Ancestor uint64      `json:"ancestor"` // Number of the last block shared with the local chain
+	Peers    []string    `json:"peers"`
+}
+
+// Status is the outcome of a fork check.
+type Status struct {
+	Head       common.Hash `json:"head"`
+	Number     uint64      `json:"number"`
+	Branches   []*Branch   `json:"branches"`   // Branches sorted by peer count, local branch first on ties
+	Unknown    int         `json:"unknown"`    // Peers whose head block could not be placed
+	Divergence uint64      `json:"divergence"` // Blocks the local head diverged from the majority branch
+	Alert      bool        `json:"alert"`
+	Checked    time.Time   `json:"checked"`
+}
+
+// alert is the JSON payload posted to the webhook.
+type alert struct {
+	Alert      bool        `json:"alert"`
+	Head       common.Hash `json:"head"`
+	Number     uint64      `json:"number"`
+	Divergence uint64      `json:"divergence"`
+	Majority   common.Hash `json:"majority"`
+	Peers      int         `json:"peers"`
+	Total      int         `json:"total"`
+}
+
+// fork is the placement of a head relative to the local chain.
+type fork struct {
+	root     common.Hash // First block after the fork point, zero on the local branch
+	ancestor uint64      // Number of the last block shared with the local chain
+}
+
+// Monitor periodically compares the head of the local chain with the heads of
+// the connected peers.
+type Monitor struct {
+	config *Config
+	chain  Chain
+	heads  PeerHeads
+	probe  HeaderProber
+	client *http.Client
+
+	status *Status
+	lock   sync.RWMutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a fork monitor for the given chain and peer set. Peer heads not
+// known locally are placed by asking the peers for their headers, unless probe
+// is nil.
+func New(config *Config, chain Chain, heads PeerHeads, probe HeaderProber) *Monitor {
+	return &Monitor{
+		config: config,
+		chain:  chain,
+		heads:  heads,
+		probe:  probe,
+		client: &http.Client{Timeout: webhookTimeout},
+		quit:   make(chan struct{}),
+	}
+}
+
+// Start launches the monitoring loop.
+func (m *Monitor) Start() {
+	m.wg.Add(1)
+	go m.loop()
+
+	log.Info("Started fork monitor", "depth", m.config.Depth, "interval", m.config.Interval)
+}
+
+// Stop terminates the monitoring loop.
+func (m *Monitor) Stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
+
+// Status returns the outcome of the last fork check, or nil if none ran yet.
+func (m *Monitor) Status() *Status {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.status
+}
+
+// loop checks the peer heads in the configured interval.
+func (m *Monitor) loop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.update(m.check())
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// update stores the outcome of a check and raises or clears the alert if the
+// state changed since the previous one.
+func (m *Monitor) update(status *Status) {
+	m.lock.Lock()
+	prev := m.status
+	m.status = status
+	m.lock.Unlock()
+
+	branchesGauge.Update(int64(len(status.Branches)))
+	divergenceGauge.Update(int64(status.Divergence))
+	if total := status.classified(); total > 0 {
+		for _, branch := range status.Branches {
+			if branch.Local {
+				shareGauge.Update(int64(100 * len(branch.Peers) / total))
+			}
+		}
+	}
+	if status.Alert == (prev != nil && prev.Alert) {
+		return
+	}
+	majority := status.Branches[0]
+	if status.Alert {
+		alertMeter.Mark(1)
+		log.Error("Local chain diverged from the majority of peers", "number", status.Number, "head", status.Head,
+			"divergence", status.Divergence, "ancestor", majority.Ancestor, "majority", majority.Root,
+			"peers", len(majority.Peers), "total", status.classified())
+	} else {
+		log.Info("Local chain rejoined the majority of peers", "number", status.Number, "head", status.Head)
+	}
+	if m.config.Webhook != "" {
+		go m.notify(&alert{
+			Alert:      status.Alert,
+			Head:       status.Head,
+			Number:     status.Number,
+			Divergence: status.Divergence,
+			Majority:   majority.Root,
+			Peers:      len(majority.Peers),
+			Total:      status.classified(),
+		})
+	}
+}
+
+// notify posts an alert to the configured webhook.
+func (m *Monitor) notify(a *alert) {
+	blob, err := json.Marshal(a)
+	if err != nil {
+		log.Error("Failed to encode fork alert", "err", err)
+		return
+	}
+	res, err := m.client.Post(m.config.Webhook, "application/json", bytes.NewReader(blob))
+	if err != nil {
+		log.Warn("Failed to deliver fork alert", "err", err)
+		return
+	}
+	res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		log.Warn("Fork alert rejected by webhook", "status", res.Status)
+	}
+}
+
+// check groups the peers by the branch their head is on and determines how far
+// the local head diverged from the branch followed by the majority.
+func (m *Monitor) check() *Status {
+	head := m.chain.CurrentHeader()
+	status := &Status{
+		Head:    head.Hash(),
+		Number:  head.Number.Uint64(),
+		Checked: time.Now(),
+	}
+	local := &Branch{Local: true, Ancestor: status.Number}
+	branches := make(map[common.Hash]*Branch)
+
+	var (
+		heads   = m.heads()
+		forks   = make(map[common.Hash]fork)   // Peers often share heads, walk each once
+		unknown = make(map[common.Hash]string) // Heads not imported locally, with the peer to probe
+	)
+	for id, hash := range heads {
+		if _, ok := forks[hash]; ok {
+			continue
+		}
+		if header := m.chain.GetHeaderByHash(hash); header != nil {
+			var f fork
+			f.root, f.ancestor = m.forkPoint(header)
+			forks[hash] = f
+		} else if prev, ok := unknown[hash]; !ok || id < prev {
+			unknown[hash] = id
+		}
+	}
+	// The peers with heads not imported locally are either ahead of the local
+	// head or on a branch this node never saw, ask them
+	for hash, f := range m.probeForks(unknown, status.Number) {
+		forks[hash] = f
+	}
+	for id, hash := range heads {
+		f, ok := forks[hash]
+		if !ok {
+			status.Unknown++
+			continue
+		}
+		root, ancestor := f.root, f.ancestor
+		if root == (common.Hash{}) {
+			local.Peers = append(local.Peers, id)
+			continue
+		}
+		branch, ok := branches[root]
+		if !ok {
+			branch = &Branch{Root: root, Ancestor: ancestor}
+			branches[root] = branch
+		}
+		branch.Peers = append(branch.Peers, id)
+	}
+	status.Branches = append(status.Branches, local)
+	for _, branch := range branches {
+		status.Branches = append(status.Branches, branch)
+	}
+	for _, branch := range status.Branches {
+		sort.Strings(branch.Peers)
+	}
+	sort.SliceStable(status.Branches, func(i, j int) bool {
+		bi, bj := status.Branches[i], status.Branches[j]
+		if len(bi.Peers) != len(bj.Peers) {
+			return len(bi.Peers) > len(bj.Peers)
+		}
+		if bi.Local != bj.Local {
+			return bi.Local
+		}
+		return bytes.Compare(bi.Root[:], bj.Root[:]) < 0
+	})
+	// Alert if a strict majority follows a branch the local head left long ago
+	if majority := status.Branches[0]; !majority.Local && 2*len(majority.Peers) > status.classified() {
+		if status.Number > majority.Ancestor {
+			status.Divergence = status.Number - majority.Ancestor
+		}
+		status.Alert = status.Divergence > m.config.Depth
+	}
+	return status
+}
+
+// probeForks places the given heads by probing the peers announcing them, all
+// at once and waiting at most probeDeadline. Heads which couldn't be placed in
+// time are left out of the result.
+func (m *Monitor) probeForks(heads map[common.Hash]string, head uint64) map[common.Hash]fork {
+	if m.probe == nil || len(heads) == 0 {
+		return nil
+	}
+	type result struct {
+		hash common.Hash
+		fork fork
+		ok   bool
+	}
+	results := make(chan result, len(heads))
+	probes := 0
+	for hash, id := range heads {
+		if probes >= maxProbes {
+			break
+		}
+		probes++
+
+		m.wg.Add(1)
+		go func(id string, hash common.Hash) {
+			defer m.wg.Done()
+
+			r := result{hash: hash}
+			r.fork.root, r.fork.ancestor, r.ok = m.remoteForkPoint(id, hash, head)
+
+			// Branching off at the local head means being ahead on it
+			if r.ok && r.fork.ancestor == head {
+				r.fork.root = common.Hash{}
+			}
+			results <- r
+		}(id, hash)
+	}
+	deadline := time.NewTimer(probeDeadline)
+	defer deadline.Stop()
+
+	forks := make(map[common.Hash]fork)
+	for i := 0; i < probes; i++ {
+		select {
+		case r := <-results:
+			if r.ok {
+				forks[r.hash] = r.fork
+			}
+		case <-deadline.C:
+			log.Debug("Peer head probes timed out", "pending", probes-i)
+			return forks
+		case <-m.quit:
+			return forks
+		}
+	}
+	return forks
+}
+
+// forkPoint walks back from a header until it reaches the local canonical
+// chain, returning the first block after the fork point and the number of the
+// common ancestor. The root is zero if the header is on the canonical chain.
+func (m *Monitor) forkPoint(header *types.Header) (common.Hash, uint64) {
+	var root common.Hash
+	for i := 0; i < maxForkWalk; i++ {
+		number := header.Number.Uint64()
+		if canon := m.chain.GetHeaderByNumber(number); canon != nil && canon.Hash() == header.Hash() {
+			return root, number
+		}
+		root = header.Hash()
+		if number == 0 {
+			break
+		}
+		parent := m.chain.GetHeaderByHash(header.ParentHash)
+		if parent == nil {
+			break
+		}
+		header = parent
+	}
+	// Fork point too deep or missing, its parent is the best ancestor estimate
+	number := header.Number.Uint64()
+	if number > 0 {
+		number--
+	}
+	return root, number
+}
+
+// remoteForkPoint asks a peer for the headers leading to its head and walks
+// them back until reaching a block known locally, returning the same as
+// forkPoint. It reports false if the peer failed to deliver a chain of headers
+// ending in the head, or if the headers stay too far above the local head to
+// tell whether the peer is just ahead on the local chain.
+func (m *Monitor) remoteForkPoint(id string, hash common.Hash, head uint64) (common.Hash, uint64, bool) {
+	headers, err := m.probe(id, hash, maxProbeWalk)
+	if err != nil || len(headers) == 0 || headers[0].Hash() != hash {
+		log.Debug("Failed to probe peer head", "peer", id, "hash", hash, "err", err)
+		return common.Hash{}, 0, false
+	}
+	for i, header := range headers {
+		if i > 0 && headers[i-1].ParentHash != header.Hash() {
+			log.Debug("Peer delivered disconnected headers", "peer", id, "hash", hash)
+			return common.Hash{}, 0, false
+		}
+		if parent := m.chain.GetHeaderByHash(header.ParentHash); parent != nil {
+			root, ancestor := m.forkPoint(parent)
+			if root == (common.Hash{}) {
+				root = header.Hash()
+			}
+			return root, ancestor, true
+		}
+	}
+	// Fork point beyond the fetched headers, the oldest one's parent is the best
+	// ancestor estimate. If that is not above the local head, the unknown parent
+	// can't be on the local chain.
+	oldest := headers[len(headers)-1]
+	number := oldest.Number.Uint64()
+	if number > head+1 {
+		return common.Hash{}, 0, false
+	}
+	if number > 0 {
+		number--
+	}
+	return oldest.Hash(), number, true
+}
+
+// classified returns the number of peers whose head could be placed.
+func (s *Status) classified() int {
+	total := 0
+	for _, branch := range s.Branches {
+		total += len(branch.Peers)
+	}
+	return total
+}
@@ -0,0 +1,273 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package forkmon
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core/types"
+)
+
+// testChain is a header store with a canonical chain and arbitrary side chains.
+type testChain struct {
+	headers   map[common.Hash]*types.Header
+	canonical []*types.Header
+}
+
+func newTestChain(length int) *testChain {
+	chain := &testChain{headers: make(map[common.Hash]*types.Header)}
+	chain.canonical = chain.extend(nil, length, 0)
+	return chain
+}
+
+// extend creates n headers on top of parent, using seed to make them unique.
+func (c *testChain) extend(parent *types.Header, n int, seed int64) []*types.Header {
+	var headers []*types.Header
+	for i := 0; i < n; i++ {
+		header := &types.Header{Number: big.NewInt(0), Time: big.NewInt(seed)}
+		if parent != nil {
+			header.ParentHash = parent.Hash()
+			header.Number = new(big.Int).Add(parent.Number, common.Big1)
+		}
+		c.headers[header.Hash()] = header
+		headers = append(headers, header)
+		parent = header
+	}
+	return headers
+}
+
+func (c *testChain) CurrentHeader() *types.Header {
+	return c.canonical[len(c.canonical)-1]
+}
+
+func (c *testChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	return c.headers[hash]
+}
+
+func (c *testChain) GetHeaderByNumber(number uint64) *types.Header {
+	if number >= uint64(len(c.canonical)) {
+		return nil
+	}
+	return c.canonical[number]
+}
+
+// Tests that peers are grouped into the correct branches and alerts are only
+// raised if the majority split off deeper than the allowed depth.
+func TestForkCheck(t *testing.T) {
+	chain := newTestChain(20)
+	// Side branch forking off after block 9, one block longer than the local one
+	side := chain.extend(chain.canonical[9], 11, 1)
+
+	heads := map[string]common.Hash{
+		"a": chain.canonical[19].Hash(),
+		"b": chain.canonical[15].Hash(), // Lagging behind, but on the local branch
+		"c": side[10].Hash(),
+		"d": side[10].Hash(),
+		"e": side[7].Hash(), // Lagging behind on the side branch
+		"f": common.HexToHash("0xdeadbeef"),
+	}
+	monitor := New(&Config{Depth: 6, Interval: time.Second}, chain, func() map[string]common.Hash { return heads }, nil)
+
+	status := monitor.check()
+	if len(status.Branches) != 2 {
+		t.Fatalf("branch count mismatch: have %d, want 2", len(status.Branches))
+	}
+	if status.Unknown != 1 {
+		t.Errorf("unknown peer count mismatch: have %d, want 1", status.Unknown)
+	}
+	majority := status.Branches[0]
+	if majority.Local || majority.Root != side[0].Hash() || majority.Ancestor != 9 || len(majority.Peers) != 3 {
+		t.Errorf("majority branch mismatch: local %v, root %x, ancestor %d, peers %v", majority.Local, majority.Root, majority.Ancestor, majority.Peers)
+	}
+	if local := status.Branches[1]; !local.Local || len(local.Peers) != 2 {
+		t.Errorf("local branch mismatch: local %v, peers %v", local.Local, local.Peers)
+	}
+	if status.Divergence != 10 || !status.Alert {
+		t.Errorf("alert mismatch: divergence %d, alert %v", status.Divergence, status.Alert)
+	}
+	// Raising the allowed depth should silence the alert
+	monitor.config.Depth = 10
+	if status := monitor.check(); status.Alert {
+		t.Errorf("alert raised within the allowed depth")
+	}
+	// A tie is not a majority
+	heads["g"] = chain.canonical[19].Hash()
+	monitor.config.Depth = 0
+	if status := monitor.check(); status.Alert || !status.Branches[0].Local {
+		t.Errorf("alert raised without a majority")
+	}
+}
+
+// testProber serves headers of branches not imported into the local chain.
+func testProber(remote *testChain) HeaderProber {
+	return func(peer string, origin common.Hash, amount int) ([]*types.Header, error) {
+		var headers []*types.Header
+		for header := remote.headers[origin]; header != nil && len(headers) < amount; header = remote.headers[header.ParentHash] {
+			headers = append(headers, header)
+		}
+		if len(headers) == 0 {
+			return nil, errors.New("unknown head")
+		}
+		return headers, nil
+	}
+}
+
+// Tests that peers on branches never imported locally are placed by probing
+// their headers, instead of being ignored as unknown.
+func TestForkCheckUnimported(t *testing.T) {
+	chain := newTestChain(20)
+
+	// Branches only the peers know about: one forking off after block 9 and
+	// longer than the local one, one merely ahead of the local head
+	remote := &testChain{headers: make(map[common.Hash]*types.Header)}
+	side := remote.extend(chain.canonical[9], 12, 1)
+	ahead := remote.extend(chain.canonical[19], 2, 2)
+
+	heads := map[string]common.Hash{
+		"a": side[11].Hash(),
+		"b": side[11].Hash(),
+		"c": side[10].Hash(),
+		"d": ahead[1].Hash(), // Ahead, but on the local branch
+		"e": common.HexToHash("0xdeadbeef"),
+	}
+	monitor := New(&Config{Depth: 6, Interval: time.Second}, chain, func() map[string]common.Hash { return heads }, testProber(remote))
+
+	status := monitor.check()
+	if len(status.Branches) != 2 {
+		t.Fatalf("branch count mismatch: have %d, want 2", len(status.Branches))
+	}
+	if status.Unknown != 1 {
+		t.Errorf("unknown peer count mismatch: have %d, want 1", status.Unknown)
+	}
+	majority := status.Branches[0]
+	if majority.Local || majority.Root != side[0].Hash() || majority.Ancestor != 9 || len(majority.Peers) != 3 {
+		t.Errorf("majority branch mismatch: local %v, root %x, ancestor %d, peers %v", majority.Local, majority.Root, majority.Ancestor, majority.Peers)
+	}
+	if local := status.Branches[1]; !local.Local || len(local.Peers) != 1 {
+		t.Errorf("local branch mismatch: local %v, peers %v", local.Local, local.Peers)
+	}
+	if status.Divergence != 10 || !status.Alert {
+		t.Errorf("alert mismatch: divergence %d, alert %v", status.Divergence, status.Alert)
+	}
+	// Without probing, the split goes unnoticed
+	monitor.probe = nil
+	if status := monitor.check(); status.Alert || status.Unknown != 5 {
+		t.Errorf("unprobed check mismatch: alert %v, unknown %d", status.Alert, status.Unknown)
+	}
+}
+
+// Tests that alerts and recoveries are delivered to the webhook once per
+// state change.
+// Tests that peer heads are probed concurrently and that a check doesn't wait
+// for unresponsive peers beyond the probe deadline.
+func TestForkCheckProbeDeadline(t *testing.T) {
+	defer func(deadline time.Duration) { probeDeadline = deadline }(probeDeadline)
+	probeDeadline = 500 * time.Millisecond
+
+	chain := newTestChain(20)
+	remote := &testChain{headers: make(map[common.Hash]*types.Header)}
+
+	// Many peers on distinct side branches answering slowly, one never
+	heads := make(map[string]common.Hash)
+	for i := 0; i < maxProbes-1; i++ {
+		side := remote.extend(chain.canonical[9], 12, int64(i+1))
+		heads[string('a'+rune(i))] = side[11].Hash()
+	}
+	stuck := remote.extend(chain.canonical[9], 12, 0xff)
+	heads["stuck"] = stuck[11].Hash()
+
+	serve := testProber(remote)
+	release := make(chan struct{})
+
+	probe := func(peer string, origin common.Hash, amount int) ([]*types.Header, error) {
+		if peer == "stuck" {
+			<-release
+			return nil, errors.New("terminated")
+		}
+		time.Sleep(200 * time.Millisecond)
+		return serve(peer, origin, amount)
+	}
+	monitor := New(&Config{Depth: 6, Interval: time.Second}, chain, func() map[string]common.Hash { return heads }, probe)
+
+	start := time.Now()
+	status := monitor.check()
+	if elapsed := time.Since(start); elapsed > probeDeadline+200*time.Millisecond {
+		t.Errorf("check took %v, want at most the %v probe deadline", elapsed, probeDeadline)
+	}
+	if status.Unknown != 1 {
+		t.Errorf("unknown peer count mismatch: have %d, want 1", status.Unknown)
+	}
+	if status.classified() != maxProbes-1 {
+		t.Errorf("placed peer count mismatch: have %d, want %d", status.classified(), maxProbes-1)
+	}
+	// The abandoned probe terminates with the peer
+	close(release)
+	monitor.wg.Wait()
+}
+
+func TestForkWebhook(t *testing.T) {
+	alerts := make(chan alert, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a alert
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			t.Errorf("failed to decode alert: %v", err)
+		}
+		alerts <- a
+	}))
+	defer server.Close()
+
+	chain := newTestChain(10)
+	side := chain.extend(chain.canonical[2], 8, 1)
+
+	heads := map[string]common.Hash{"a": side[7].Hash()}
+	monitor := New(&Config{Depth: 3, Interval: time.Second, Webhook: server.URL}, chain, func() map[string]common.Hash { return heads }, nil)
+
+	monitor.update(monitor.check())
+	monitor.update(monitor.check())
+
+	select {
+	case a := <-alerts:
+		if !a.Alert || a.Divergence != 7 || a.Majority != side[0].Hash() || a.Peers != 1 || a.Total != 1 {
+			t.Errorf("alert mismatch: %+v", a)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("alert not delivered")
+	}
+	heads["a"] = chain.canonical[9].Hash()
+	monitor.update(monitor.check())
+
+	select {
+	case a := <-alerts:
+		if a.Alert {
+			t.Errorf("recovery mismatch: %+v", a)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("recovery not delivered")
+	}
+	select {
+	case a := <-alerts:
+		t.Errorf("unexpected webhook delivery: %+v", a)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
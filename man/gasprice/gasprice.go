@@ -23,6 +23,7 @@ import (
 	"sync"
 
 	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/consensus/misc"
 	"github.com/matrix/go-matrix/core/types"
 	"github.com/matrix/go-matrix/internal/manapi"
 	"github.com/matrix/go-matrix/params"
@@ -73,6 +74,29 @@ func NewOracle(backend manapi.Backend, params Config) *Oracle {
 	}
 }
 
+// SuggestTipCap returns the recommended priority fee: the part of the suggested
+// gas price exceeding the base fee of the next block. Before the dynamic fee
+// fork there is no base fee and the whole gas price is returned.
+func (gpo *Oracle) SuggestTipCap(ctx context.Context) (*big.Int, error) {
+	price, err := gpo.SuggestPrice(ctx)
+	if err != nil || price == nil {
+		return price, err
+	}
+	head, err := gpo.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil || head == nil {
+		return price, err
+	}
+	baseFee := misc.CalcBaseFee(gpo.backend.ChainConfig(), head)
+	if baseFee == nil {
+		return price, nil
+	}
+	tip := new(big.Int).Sub(price, baseFee)
+	if tip.Sign() < 0 {
+		tip.SetUint64(0)
+	}
+	return tip, nil
+}
+
 // SuggestPrice returns the recommended gas price.
 func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	gpo.cacheLock.RLock()
@@ -19,13 +19,17 @@ package man
 
 import (
 	"math/big"
+	"time"
 
 	"github.com/matrix/go-matrix/common"
 	"github.com/matrix/go-matrix/common/hexutil"
 	"github.com/matrix/go-matrix/consensus/manash"
 	"github.com/matrix/go-matrix/core"
 	"github.com/matrix/go-matrix/man/downloader"
+	"github.com/matrix/go-matrix/man/blobs"
+	"github.com/matrix/go-matrix/man/forkmon"
 	"github.com/matrix/go-matrix/man/gasprice"
+	"github.com/matrix/go-matrix/man/remotestate"
 )
 
 var _ = (*configMarshaling)(nil)
@@ -44,11 +48,22 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		MinerThreads            int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes  `toml:",omitempty"`
 		GasPrice                *big.Int
+		Propagation             PropagationConfig
 		Ethash                  manash.Config
 		TxPool                  core.TxPoolConfig
 		GPO                     gasprice.Config
 		EnablePreimageRecording bool
-		DocRoot                 string `toml:"-"`
+		ParallelTxs             int                 `toml:",omitempty"`
+		StateAccessIndex        bool                `toml:",omitempty"`
+		TraceIndex              bool                `toml:",omitempty"`
+		FutureBlockDrift        *time.Duration      `toml:",omitempty"`
+		PinnedHead              *core.PinnedBlock   `toml:",omitempty"`
+		ForkMonitor             *forkmon.Config     `toml:",omitempty"`
+		Blobs                   *blobs.Config       `toml:",omitempty"`
+		RemoteState             *remotestate.Config `toml:",omitempty"`
+		KZGTrustedSetup         string              `toml:",omitempty"`
+		DevMode                 bool                `toml:"-"`
+		DocRoot                 string              `toml:"-"`
 	}
 	var enc Config
 	enc.Genesis = c.Genesis
@@ -63,10 +78,21 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.MinerThreads = c.MinerThreads
 	enc.ExtraData = c.ExtraData
 	enc.GasPrice = c.GasPrice
+	enc.Propagation = c.Propagation
 	enc.Ethash = c.Ethash
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
+	enc.ParallelTxs = c.ParallelTxs
+	enc.StateAccessIndex = c.StateAccessIndex
+	enc.TraceIndex = c.TraceIndex
+	enc.FutureBlockDrift = c.FutureBlockDrift
+	enc.PinnedHead = c.PinnedHead
+	enc.ForkMonitor = c.ForkMonitor
+	enc.Blobs = c.Blobs
+	enc.RemoteState = c.RemoteState
+	enc.KZGTrustedSetup = c.KZGTrustedSetup
+	enc.DevMode = c.DevMode
 	enc.DocRoot = c.DocRoot
 	return &enc, nil
 }
@@ -85,11 +111,22 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		MinerThreads            *int            `toml:",omitempty"`
 		ExtraData               *hexutil.Bytes  `toml:",omitempty"`
 		GasPrice                *big.Int
+		Propagation             *PropagationConfig
 		Ethash                  *manash.Config
 		TxPool                  *core.TxPoolConfig
 		GPO                     *gasprice.Config
 		EnablePreimageRecording *bool
-		DocRoot                 *string `toml:"-"`
+		ParallelTxs             *int                `toml:",omitempty"`
+		StateAccessIndex        *bool               `toml:",omitempty"`
+		TraceIndex              *bool               `toml:",omitempty"`
+		FutureBlockDrift        *time.Duration      `toml:",omitempty"`
+		PinnedHead              *core.PinnedBlock   `toml:",omitempty"`
+		ForkMonitor             *forkmon.Config     `toml:",omitempty"`
+		Blobs                   *blobs.Config       `toml:",omitempty"`
+		RemoteState             *remotestate.Config `toml:",omitempty"`
+		KZGTrustedSetup         *string             `toml:",omitempty"`
+		DevMode                 *bool               `toml:"-"`
+		DocRoot                 *string             `toml:"-"`
 	}
 	var dec Config
 	if err := unmarshal(&dec); err != nil {
@@ -131,6 +168,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.GasPrice != nil {
 		c.GasPrice = dec.GasPrice
 	}
+	if dec.Propagation != nil {
+		c.Propagation = *dec.Propagation
+	}
 	if dec.Ethash != nil {
 		c.Ethash = *dec.Ethash
 	}
@@ -143,6 +183,36 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.EnablePreimageRecording != nil {
 		c.EnablePreimageRecording = *dec.EnablePreimageRecording
 	}
+	if dec.ParallelTxs != nil {
+		c.ParallelTxs = *dec.ParallelTxs
+	}
+	if dec.StateAccessIndex != nil {
+		c.StateAccessIndex = *dec.StateAccessIndex
+	}
+	if dec.TraceIndex != nil {
+		c.TraceIndex = *dec.TraceIndex
+	}
+	if dec.FutureBlockDrift != nil {
+		c.FutureBlockDrift = dec.FutureBlockDrift
+	}
+	if dec.PinnedHead != nil {
+		c.PinnedHead = dec.PinnedHead
+	}
+	if dec.ForkMonitor != nil {
+		c.ForkMonitor = dec.ForkMonitor
+	}
+	if dec.Blobs != nil {
+		c.Blobs = dec.Blobs
+	}
+	if dec.RemoteState != nil {
+		c.RemoteState = dec.RemoteState
+	}
+	if dec.KZGTrustedSetup != nil {
+		c.KZGTrustedSetup = *dec.KZGTrustedSetup
+	}
+	if dec.DevMode != nil {
+		c.DevMode = *dec.DevMode
+	}
 	if dec.DocRoot != nil {
 		c.DocRoot = *dec.DocRoot
 	}
@@ -52,10 +52,14 @@ const (
 	// txChanSize is the size of channel listening to NewTxsEvent.
 	// The number is referenced from the size of tx pool.
 	txChanSize = 4096
+
+	// minedBlockChanSize is the size of channel listening to NewMinedBlockEvent.
+	minedBlockChanSize = 16
 )
 
 var (
 	daoChallengeTimeout = 15 * time.Second // Time allowance for a node to reply to the DAO handshake challenge
+	pinChallengeTimeout = 15 * time.Second // Time allowance for a node to reply to the pinned block challenge
 )
 
 // errIncompatibleConfig is returned if the requested protocols and configs are
@@ -79,7 +83,7 @@ type ProtocolManager struct {
 	txpool      txPool
 	blockchain  *core.BlockChain
 	chainconfig *params.ChainConfig
-	maxPeers    int
+	maxPeers    int32 // Maximum number of MAN peers (accessed atomically)
 
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
@@ -87,10 +91,11 @@ type ProtocolManager struct {
 	Peers        *peerSet
 	SubProtocols []p2p.Protocol
 
-	eventMux      *event.TypeMux
 	txsCh         chan core.NewTxsEvent
 	txsSub        event.Subscription
-	minedBlockSub *event.TypeMuxSubscription
+	minedBlockCh  chan core.NewMinedBlockEvent
+	minedBlockSub event.Subscription
+	propagation   PropagationConfig // Propagation of the blocks sealed by the node
 
 	// channels for fetcher, syncer, txsyncLoop
 	newPeerCh   chan *peer
@@ -106,11 +111,10 @@ type ProtocolManager struct {
 
 // NewProtocolManager returns a new Matrix sub protocol manager. The Matrix sub protocol manages peers capable
 // with the Matrix network.
-func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, networkId uint64, mux *event.TypeMux, txpool txPool, engine consensus.Engine, blockchain *core.BlockChain, chaindb mandb.Database, MsgCenter *mc.Center) (*ProtocolManager, error) {
+func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, networkId uint64, txpool txPool, engine consensus.Engine, blockchain *core.BlockChain, chaindb mandb.Database, MsgCenter *mc.Center) (*ProtocolManager, error) {
 	// Create the protocol manager with the base fields
 	manager := &ProtocolManager{
 		networkId:   networkId,
-		eventMux:    mux,
 		txpool:      txpool,
 		blockchain:  blockchain,
 		chainconfig: config,
@@ -120,6 +124,7 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		noMorePeers: make(chan struct{}),
 		txsyncCh:    make(chan *txsync),
 		quitSync:    make(chan struct{}),
+		propagation: DefaultPropagationConfig,
 		Msgcenter:   MsgCenter,
 	}
 	// Figure out whether to allow fast sync or not
@@ -170,7 +175,7 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		return nil, errIncompatibleConfig
 	}
 	// Construct the different synchronisation mechanisms
-	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.removePeer)
+	manager.downloader = downloader.New(mode, chaindb, blockchain, nil, manager.removePeer)
 
 	validator := func(header *types.Header) error {
 		if header.IsBroadcastHeader() || header.IsReElectionHeader() {
@@ -244,7 +249,7 @@ func (pm *ProtocolManager) removePeer(id string) {
 }*/
 
 func (pm *ProtocolManager) Start(maxPeers int) {
-	pm.maxPeers = maxPeers
+	atomic.StoreInt32(&pm.maxPeers, int32(maxPeers))
 
 	// broadcast transactions
 	pm.txsCh = make(chan core.NewTxsEvent, txChanSize)
@@ -252,7 +257,8 @@ func (pm *ProtocolManager) Start(maxPeers int) {
 	go pm.txBroadcastLoop()
 
 	// broadcast mined blocks
-	pm.minedBlockSub = pm.eventMux.Subscribe(core.NewMinedBlockEvent{})
+	pm.minedBlockCh = make(chan core.NewMinedBlockEvent, minedBlockChanSize)
+	pm.minedBlockSub = pm.blockchain.SubscribeNewMinedBlockEvent(pm.minedBlockCh)
 	go pm.minedBroadcastLoop()
 
 	// start sync handlers
@@ -299,7 +305,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	//	pm.Msgcenter = p.Msgcenter
 	// Ignore maxPeers if this is a trusted peer
 	//	if pm.peers.Len() >= pm.maxPeers && !p.Peer.Info().Network.Trusted {
-	if pm.Peers.Len() >= pm.maxPeers && !p.Peer.Info().Network.Trusted {
+	if pm.Peers.Len() >= int(atomic.LoadInt32(&pm.maxPeers)) && !p.Peer.Info().Network.Trusted {
 		return p2p.DiscTooManyPeers
 	}
 	p.Log().Debug("Matrix peer connected", "name", p.Name())
@@ -355,6 +361,22 @@ func (pm *ProtocolManager) handle(p *peer) error {
 			}
 		}()
 	}
+	// If the chain is pinned, validate that the remote peer is on its branch
+	if pin := pm.blockchain.PinnedBlock(); pin != nil {
+		if err := p.RequestHeadersByNumber(pin.Number, 1, 0, false); err != nil {
+			return err
+		}
+		p.pinDrop = time.AfterFunc(pinChallengeTimeout, func() {
+			p.Log().Debug("Timed out pinned block check, dropping")
+			pm.removePeer(p.id)
+		})
+		defer func() {
+			if p.pinDrop != nil {
+				p.pinDrop.Stop()
+				p.pinDrop = nil
+			}
+		}()
+	}
 	// main loop. handle incoming messages.
 	for {
 		if err := pm.handleMsg(p); err != nil {
@@ -470,6 +492,24 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		if err := msg.Decode(&headers); err != nil {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
+		// Replies to a fork monitor probe are consumed, the sync never asked for them
+		if p.deliverProbe(headers) {
+			return nil
+		}
+
+		// If we're expecting the pinned block check, validate the peer's branch. An
+		// empty reply means the peer has not reached the pinned block yet
+		if p.pinDrop != nil && (len(headers) == 0 || len(headers) == 1 && headers[0].Number.Uint64() == pm.blockchain.PinnedBlock().Number) {
+			p.pinDrop.Stop()
+			p.pinDrop = nil
+
+			if len(headers) == 1 && pm.blockchain.PinnedBlock().Conflicts(headers[0]) {
+				p.Log().Debug("Verified to be on a branch without the pinned block, dropping")
+				return core.ErrPinnedBlock
+			}
+			p.Log().Debug("Verified not to conflict with the pinned block")
+			return nil
+		}
 		// If no headers were received, but we're expending a DAO fork check, maybe it's that
 		if len(headers) == 0 && p.forkDrop != nil {
 			// Possibly an empty reply to the fork header checks, sanity check TDs
@@ -854,15 +894,17 @@ func (pm *ProtocolManager) BroadcastTxs(txs types.Transactions) {
 
 // Mined broadcast loop
 func (pm *ProtocolManager) minedBroadcastLoop() {
-	// automatically stops if unsubscribe
-	for obj := range pm.minedBlockSub.Chan() {
-		switch ev := obj.Data.(type) {
-		case core.NewMinedBlockEvent:
-			pm.BroadcastBlock(ev.Block, true)  // First propagate block to peers
-			pm.BroadcastBlock(ev.Block, false) // Only then announce to the rest
+	for {
+		select {
+		case ev := <-pm.minedBlockCh:
+			pm.propagateMinedBlock(ev.Block)
 			//TOdo: broadcast block header
 			//pm.BroadcastBlockHeader(ev.Block, true)
 			//pm.BroadcastBlockHeader(ev.Block, false)
+
+		// Err() channel will be closed when unsubscribing.
+		case <-pm.minedBlockSub.Err():
+			return
 		}
 	}
 }
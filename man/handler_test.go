@@ -32,7 +32,6 @@ import (
 	"github.com/matrix/go-matrix/crypto"
 	"github.com/matrix/go-matrix/man/downloader"
 	"github.com/matrix/go-matrix/mandb"
-	"github.com/matrix/go-matrix/event"
 	"github.com/matrix/go-matrix/p2p"
 	"github.com/matrix/go-matrix/params"
 )
@@ -466,7 +465,6 @@ func testDAOChallenge(t *testing.T, localForked, remoteForked bool, timeout bool
 	}
 	// Create a DAO aware protocol manager
 	var (
-		evmux         = new(event.TypeMux)
 		pow           = manash.NewFaker()
 		db            = mandb.NewMemDatabase()
 		config        = &params.ChainConfig{DAOForkBlock: big.NewInt(1), DAOForkSupport: localForked}
@@ -474,7 +472,7 @@ func testDAOChallenge(t *testing.T, localForked, remoteForked bool, timeout bool
 		genesis       = gspec.MustCommit(db)
 		blockchain, _ = core.NewBlockChain(db, nil, config, pow, vm.Config{})
 	)
-	pm, err := NewProtocolManager(config, downloader.FullSync, DefaultConfig.NetworkId, evmux, new(testTxPool), pow, blockchain, db)
+	pm, err := NewProtocolManager(config, downloader.FullSync, DefaultConfig.NetworkId, new(testTxPool), pow, blockchain, db, nil)
 	if err != nil {
 		t.Fatalf("failed to start test protocol manager: %v", err)
 	}
@@ -511,12 +509,85 @@ func testDAOChallenge(t *testing.T, localForked, remoteForked bool, timeout bool
 	}
 	// Verify that depending on fork side, the remote peer is maintained or dropped
 	if localForked == remoteForked && !timeout {
-		if peers := pm.peers.Len(); peers != 1 {
+		if peers := pm.Peers.Len(); peers != 1 {
 			t.Fatalf("peer count mismatch: have %d, want %d", peers, 1)
 		}
 	} else {
-		if peers := pm.peers.Len(); peers != 0 {
+		if peers := pm.Peers.Len(); peers != 0 {
 			t.Fatalf("peer count mismatch: have %d, want %d", peers, 0)
 		}
 	}
 }
+
+// Tests that the replies to a header probe are consumed by the probe, while
+// other header batches still reach the sync.
+func TestProbeHeaders(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 8, nil, nil)
+	defer pm.Stop()
+
+	peer, _ := newTestPeer("peer", man63, pm, true)
+	defer peer.close()
+
+	var (
+		head   = pm.blockchain.GetHeaderByNumber(5)
+		parent = pm.blockchain.GetHeaderByNumber(4)
+		other  = pm.blockchain.GetHeaderByNumber(3)
+	)
+	type result struct {
+		headers []*types.Header
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		headers, err := peer.peer.ProbeHeaders(head.Hash(), 1)
+		done <- result{headers, err}
+	}()
+	// Wait for the probe request, walking back from the probed header
+	for {
+		msg, err := peer.app.ReadMsg()
+		if err != nil {
+			t.Fatalf("failed to read probe request: %v", err)
+		}
+		if msg.Code != GetBlockHeadersMsg {
+			msg.Discard()
+			continue
+		}
+		var query getBlockHeadersData
+		if err := msg.Decode(&query); err != nil {
+			t.Fatalf("failed to decode probe request: %v", err)
+		}
+		if query.Origin.Hash != head.Hash() || query.Amount != 2 || !query.Reverse {
+			t.Fatalf("probe request mismatch: have %+v", query)
+		}
+		break
+	}
+	// Neither a lone header nor an unrelated batch is taken by the probe
+	if peer.peer.deliverProbe([]*types.Header{head}) {
+		t.Error("lone header delivered to the probe")
+	}
+	if peer.peer.deliverProbe([]*types.Header{other, pm.blockchain.GetHeaderByNumber(2)}) {
+		t.Error("unrelated headers delivered to the probe")
+	}
+	if peer.peer.deliverProbe([]*types.Header{head, other}) {
+		t.Error("headers not walking back delivered to the probe")
+	}
+	// The actual reply completes the probe
+	if err := p2p.Send(peer.app, BlockHeadersMsg, []*types.Header{head, parent}); err != nil {
+		t.Fatalf("failed to send probe reply: %v", err)
+	}
+	select {
+	case res := <-done:
+		if res.err != nil {
+			t.Fatalf("probe failed: %v", res.err)
+		}
+		if len(res.headers) != 2 || res.headers[0].Hash() != head.Hash() || res.headers[1].Hash() != parent.Hash() {
+			t.Errorf("probe reply mismatch: have %d headers", len(res.headers))
+		}
+	case <-time.After(probeTimeout):
+		t.Fatal("probe reply not delivered")
+	}
+	// Once answered, the same headers are left to the sync
+	if peer.peer.deliverProbe([]*types.Header{head, parent}) {
+		t.Error("headers delivered to a finished probe")
+	}
+}
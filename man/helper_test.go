@@ -51,7 +51,6 @@ var (
 // channels for different events.
 func newTestProtocolManager(mode downloader.SyncMode, blocks int, generator func(int, *core.BlockGen), newtx chan<- []*types.Transaction) (*ProtocolManager, *mandb.MemDatabase, error) {
 	var (
-		evmux  = new(event.TypeMux)
 		engine = manash.NewFaker()
 		db     = mandb.NewMemDatabase()
 		gspec  = &core.Genesis{
@@ -66,7 +65,7 @@ func newTestProtocolManager(mode downloader.SyncMode, blocks int, generator func
 		panic(err)
 	}
 
-	pm, err := NewProtocolManager(gspec.Config, mode, DefaultConfig.NetworkId, evmux, &testTxPool{added: newtx}, engine, blockchain, db)
+	pm, err := NewProtocolManager(gspec.Config, mode, DefaultConfig.NetworkId, &testTxPool{added: newtx}, engine, blockchain, db, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -96,15 +95,17 @@ type testTxPool struct {
 }
 
 // AddRemotes appends a batch of transactions to the pool, and notifies any
-// listeners if the addition channel is non nil
+// listeners if the addition channel is non nil, as well as the subscribers of
+// new transaction events
 func (p *testTxPool) AddRemotes(txs []*types.Transaction) []error {
 	p.lock.Lock()
-	defer p.lock.Unlock()
-
 	p.pool = append(p.pool, txs...)
 	if p.added != nil {
 		p.added <- txs
 	}
+	p.lock.Unlock()
+
+	p.txFeed.Send(core.NewTxsEvent{Txs: txs})
 	return make([]error, len(txs))
 }
 
@@ -128,6 +129,9 @@ func (p *testTxPool) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subs
 	return p.txFeed.Subscribe(ch)
 }
 
+// ProcessMsg ignores the transaction pool messages relayed by the peers.
+func (p *testTxPool) ProcessMsg(m core.NetworkMsgData) {}
+
 // newTestTransaction create a new dummy transaction.
 func newTestTransaction(from *ecdsa.PrivateKey, nonce uint64, datasize int) *types.Transaction {
 	tx := types.NewTransaction(nonce, common.Address{}, big.NewInt(0), 100000, big.NewInt(0), make([]byte, datasize))
@@ -54,6 +54,8 @@ var (
 	miscInTrafficMeter        = metrics.NewRegisteredMeter("man/misc/in/traffic", nil)
 	miscOutPacketsMeter       = metrics.NewRegisteredMeter("man/misc/out/packets", nil)
 	miscOutTrafficMeter       = metrics.NewRegisteredMeter("man/misc/out/traffic", nil)
+
+	clockDriftGauge = metrics.NewRegisteredGauge("man/clock/drift", nil) // Local clock drift against NTP in milliseconds
 )
 
 // meteredMsgReadWriter is a wrapper around a p2p.MsgReadWriter, capable of
@@ -37,6 +37,8 @@ var (
 	errClosed            = errors.New("peer set is closed")
 	errAlreadyRegistered = errors.New("peer is already registered")
 	errNotRegistered     = errors.New("peer is not registered")
+	errProbePending      = errors.New("header probe already pending")
+	errProbeTimeout      = errors.New("header probe timed out")
 )
 
 const (
@@ -59,6 +61,7 @@ const (
 	maxQueuedAnns = 4
 
 	handshakeTimeout = 5 * time.Second
+	probeTimeout     = 5 * time.Second // Maximum time to wait for a header probe reply
 )
 
 // PeerInfo represents a short summary of the Matrix sub-protocol metadata known
@@ -84,10 +87,13 @@ type peer struct {
 
 	version  int         // Protocol version negotiated
 	forkDrop *time.Timer // Timed connection dropper if forks aren't validated in time
+	pinDrop  *time.Timer // Timed connection dropper if the pinned block isn't validated in time
 
-	head common.Hash
-	td   *big.Int
-	lock sync.RWMutex
+	head    common.Hash
+	td      *big.Int
+	probe   common.Hash          // First header hash expected by the pending header probe
+	probeCh chan []*types.Header // Delivery channel of the pending header probe, nil if none
+	lock    sync.RWMutex
 
 	knownTxs    *set.Set                  // Set of transaction hashes known to be known by this peer
 	knownBlocks *set.Set                  // Set of block hashes known to be known by this peer
@@ -344,6 +350,64 @@ func (p *peer) RequestHeadersByHash(origin common.Hash, amount int, skip int, re
 	return p2p.Send(p.rw, GetBlockHeadersMsg, &getBlockHeadersData{Origin: hashOrNumber{Hash: origin}, Amount: uint64(amount), Skip: uint64(skip), Reverse: reverse})
 }
 
+// ProbeHeaders retrieves a batch of headers walking back from the given hash,
+// waiting for the peer's reply. It is used to place peer heads which are not
+// known locally, outside of any sync. At least two headers are requested, so
+// the reply can't be mistaken for a single header request of the downloader
+// or the fetcher.
+func (p *peer) ProbeHeaders(origin common.Hash, amount int) ([]*types.Header, error) {
+	if amount < 2 {
+		amount = 2
+	}
+	ch := make(chan []*types.Header, 1)
+
+	p.lock.Lock()
+	if p.probeCh != nil {
+		p.lock.Unlock()
+		return nil, errProbePending
+	}
+	p.probe, p.probeCh = origin, ch
+	p.lock.Unlock()
+
+	defer func() {
+		p.lock.Lock()
+		p.probeCh = nil
+		p.lock.Unlock()
+	}()
+	if err := p.RequestHeadersByHash(origin, amount, 0, true); err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(probeTimeout)
+	defer timer.Stop()
+
+	select {
+	case headers := <-ch:
+		return headers, nil
+	case <-timer.C:
+		return nil, errProbeTimeout
+	case <-p.term:
+		return nil, errClosed
+	}
+}
+
+// deliverProbe hands a batch of headers to the pending header probe if it is
+// the reply to it, reporting whether it was. Only replies walking back from the
+// probed hash match, so replies to the sync requests are never taken.
+func (p *peer) deliverProbe(headers []*types.Header) bool {
+	if len(headers) < 2 || headers[1].Hash() != headers[0].ParentHash {
+		return false
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.probeCh == nil || headers[0].Hash() != p.probe {
+		return false
+	}
+	p.probeCh <- headers
+	p.probeCh = nil
+	return true
+}
+
 // RequestHeadersByNumber fetches a batch of blocks' headers corresponding to the
 // specified header query, based on the number of an origin block.
 func (p *peer) RequestHeadersByNumber(origin uint64, amount int, skip int, reverse bool) error {
@@ -565,6 +629,29 @@ func (ps *peerSet) Close() {
 	ps.closed = true
 }
 
+// Heads retrieves the current head hashes of all the registered peers, keyed by
+// peer id.
+func (ps *peerSet) Heads() map[string]common.Hash {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	heads := make(map[string]common.Hash, len(ps.peers))
+	for id, p := range ps.peers {
+		heads[id], _ = p.Head()
+	}
+	return heads
+}
+
+// ProbeHeaders retrieves a batch of headers walking back from the given hash
+// from a registered peer.
+func (ps *peerSet) ProbeHeaders(id string, origin common.Hash, amount int) ([]*types.Header, error) {
+	p := ps.Peer(id)
+	if p == nil {
+		return nil, errNotRegistered
+	}
+	return p.ProbeHeaders(origin, amount)
+}
+
 func (ps *peerSet) PeersAll() []*peer {
 	ps.lock.RLock()
 	defer ps.lock.RUnlock()
@@ -0,0 +1,53 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package man
+
+import (
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/log"
+)
+
+const (
+	// proofCacheSize is the number of account and storage proofs kept for
+	// repeated man_getProof queries.
+	proofCacheSize = 4096
+
+	// proofCacheBlocks is the number of blocks behind the chain head for which
+	// generated proofs are retained.
+	proofCacheBlocks = 128
+)
+
+// proofCacheLoop drops the proofs of states that fell behind the retention
+// window of the proof cache on every new chain head.
+func (s *Matrix) proofCacheLoop() {
+	headCh := make(chan core.ChainHeadEvent, 16)
+	headSub := s.blockchain.SubscribeChainHeadEvent(headCh)
+	defer headSub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-headCh:
+			s.proofCache.Prune(ev.Block.NumberU64())
+		case err := <-headSub.Err():
+			if err != nil {
+				log.Warn("Proof cache head subscription failed", "err", err)
+			}
+			return
+		case <-s.shutdownChan:
+			return
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package man
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/matrix/go-matrix/ca"
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/metrics"
+	"github.com/matrix/go-matrix/p2p/discover"
+)
+
+// Propagation modes of the blocks sealed by the node
+const (
+	PropagateSqrt     = "sqrt"     // full block to the square root of the peers, hash to the rest
+	PropagateAll      = "all"      // full block to all peers
+	PropagateAnnounce = "announce" // hash to all peers, the full block to the priority peers only
+)
+
+var (
+	propMinedPriorityTimer = metrics.NewRegisteredTimer("man/prop/mined/priority", nil) // Time from sealing to pushing the priority peers
+	propMinedFullTimer     = metrics.NewRegisteredTimer("man/prop/mined/full", nil)     // Time from sealing to pushing the full block to all recipients
+	propMinedAnnounceTimer = metrics.NewRegisteredTimer("man/prop/mined/announce", nil) // Time from sealing to announcing the hash to the rest
+	propMinedPriorityMeter = metrics.NewRegisteredMeter("man/prop/mined/priority/peers", nil)
+	propMinedFullMeter     = metrics.NewRegisteredMeter("man/prop/mined/full/peers", nil)
+	propMinedAnnounceMeter = metrics.NewRegisteredMeter("man/prop/mined/announce/peers", nil)
+)
+
+// PropagationConfig controls how the blocks sealed by the node are sent to
+// its peers. Relayed blocks are always propagated the default way.
+type PropagationConfig struct {
+	Mode       string            // One of PropagateSqrt, PropagateAll or PropagateAnnounce
+	MinFull    int               `toml:",omitempty"` // Minimum number of peers pushed the full block in sqrt mode
+	Validators bool              `toml:",omitempty"` // Push the full block to the connected validators of the current topology first
+	Priority   []discover.NodeID `toml:",omitempty"` // Peers pushed the full block first, whatever the mode
+}
+
+// DefaultPropagationConfig propagates sealed blocks like relayed ones.
+var DefaultPropagationConfig = PropagationConfig{
+	Mode: PropagateSqrt,
+}
+
+// sanitize checks the mode and limits, falling back to the defaults.
+func (config *PropagationConfig) sanitize() PropagationConfig {
+	conf := *config
+	switch conf.Mode {
+	case PropagateSqrt, PropagateAll, PropagateAnnounce:
+	case "":
+		conf.Mode = DefaultPropagationConfig.Mode
+	default:
+		log.Warn("Sanitizing invalid block propagation mode", "provided", conf.Mode, "updated", DefaultPropagationConfig.Mode)
+		conf.Mode = DefaultPropagationConfig.Mode
+	}
+	if conf.MinFull < 0 {
+		log.Warn("Sanitizing invalid block propagation minimum", "provided", conf.MinFull, "updated", 0)
+		conf.MinFull = 0
+	}
+	return conf
+}
+
+// ValidatePropagationMode returns an error if mode isn't a propagation mode.
+func ValidatePropagationMode(mode string) error {
+	switch mode {
+	case PropagateSqrt, PropagateAll, PropagateAnnounce:
+		return nil
+	}
+	return fmt.Errorf("invalid block propagation mode %q, want %q, %q or %q", mode, PropagateSqrt, PropagateAll, PropagateAnnounce)
+}
+
+// plan splits the peers not knowing a sealed block into those pushed the full
+// block first, those pushed it next and those only announced its hash.
+func (config *PropagationConfig) plan(peers []*peer, priority map[discover.NodeID]bool) (first, full, announce []*peer) {
+	var rest []*peer
+	for _, p := range peers {
+		if priority[p.ID()] {
+			first = append(first, p)
+		} else {
+			rest = append(rest, p)
+		}
+	}
+	var n int
+	switch config.Mode {
+	case PropagateAll:
+		n = len(rest)
+	case PropagateAnnounce:
+		n = 0
+	default:
+		n = int(math.Sqrt(float64(len(peers))))
+		if n < config.MinFull {
+			n = config.MinFull
+		}
+		if n > len(rest) {
+			n = len(rest)
+		}
+	}
+	return first, rest[:n], rest[n:]
+}
+
+// priorityPeers returns the ids of the peers sealed blocks are pushed to first
+func (config *PropagationConfig) priorityPeers() map[discover.NodeID]bool {
+	ids := make(map[discover.NodeID]bool, len(config.Priority))
+	for _, id := range config.Priority {
+		ids[id] = true
+	}
+	if config.Validators {
+		for _, id := range ca.GetRolesByGroup(common.RoleValidator) {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// propagateMinedBlock sends a block sealed by the node to its peers according
+// to the propagation config: the full block to the priority peers first, then
+// to the peers chosen by the mode, and its hash to the rest.
+func (pm *ProtocolManager) propagateMinedBlock(block *types.Block) {
+	start := time.Now()
+	hash := block.Hash()
+
+	parent := pm.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		log.Error("Propagating dangling block", "number", block.Number(), "hash", hash)
+		return
+	}
+	td := new(big.Int).Add(block.Difficulty(), pm.blockchain.GetTd(block.ParentHash(), block.NumberU64()-1))
+
+	first, full, announce := pm.propagation.plan(pm.Peers.PeersWithoutBlock(hash), pm.propagation.priorityPeers())
+	for _, peer := range first {
+		peer.AsyncSendNewBlock(block, td)
+	}
+	propMinedPriorityTimer.UpdateSince(start)
+	propMinedPriorityMeter.Mark(int64(len(first)))
+
+	for _, peer := range full {
+		peer.AsyncSendNewBlock(block, td)
+	}
+	propMinedFullTimer.UpdateSince(start)
+	propMinedFullMeter.Mark(int64(len(full)))
+
+	// peers can only fetch announced blocks from us once they are in our chain
+	if !pm.blockchain.HasBlock(hash, block.NumberU64()) {
+		announce = nil
+	}
+	for _, peer := range announce {
+		peer.AsyncSendNewBlockHash(block)
+	}
+	propMinedAnnounceTimer.UpdateSince(start)
+	propMinedAnnounceMeter.Mark(int64(len(announce)))
+
+	log.Debug("Propagated sealed block", "number", block.Number(), "hash", hash, "mode", pm.propagation.Mode,
+		"priority", len(first), "full", len(full), "announced", len(announce), "elapsed", common.PrettyDuration(time.Since(start)))
+}
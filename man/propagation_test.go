@@ -0,0 +1,74 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package man
+
+import (
+	"testing"
+
+	"github.com/matrix/go-matrix/p2p"
+	"github.com/matrix/go-matrix/p2p/discover"
+)
+
+func testPropagationPeers(n int) []*peer {
+	peers := make([]*peer, n)
+	for i := range peers {
+		var id discover.NodeID
+		id[0] = byte(i)
+		peers[i] = newPeer(man63, p2p.NewPeer(id, "", nil), nil)
+	}
+	return peers
+}
+
+func TestPropagationPlan(t *testing.T) {
+	peers := testPropagationPeers(16)
+	priority := map[discover.NodeID]bool{peers[3].ID(): true, peers[9].ID(): true}
+
+	tests := []struct {
+		config                PropagationConfig
+		priority              map[discover.NodeID]bool
+		first, full, announce int
+	}{
+		{PropagationConfig{Mode: PropagateSqrt}, nil, 0, 4, 12},
+		{PropagationConfig{Mode: PropagateSqrt}, priority, 2, 4, 10},
+		{PropagationConfig{Mode: PropagateSqrt, MinFull: 8}, nil, 0, 8, 8},
+		{PropagationConfig{Mode: PropagateSqrt, MinFull: 20}, priority, 2, 14, 0},
+		{PropagationConfig{Mode: PropagateAll}, priority, 2, 14, 0},
+		{PropagationConfig{Mode: PropagateAnnounce}, nil, 0, 0, 16},
+		{PropagationConfig{Mode: PropagateAnnounce}, priority, 2, 0, 14},
+	}
+	for i, tt := range tests {
+		first, full, announce := tt.config.plan(peers, tt.priority)
+		if len(first) != tt.first || len(full) != tt.full || len(announce) != tt.announce {
+			t.Errorf("test %d: have %d/%d/%d peers, want %d/%d/%d", i, len(first), len(full), len(announce), tt.first, tt.full, tt.announce)
+		}
+		for _, p := range first {
+			if !tt.priority[p.ID()] {
+				t.Errorf("test %d: peer %v pushed first without priority", i, p.ID())
+			}
+		}
+	}
+}
+
+func TestPropagationSanitize(t *testing.T) {
+	config := PropagationConfig{Mode: "flood", MinFull: -1}
+	if sane := config.sanitize(); sane.Mode != PropagateSqrt || sane.MinFull != 0 {
+		t.Errorf("have %+v, want the default mode and no minimum", sane)
+	}
+	if err := ValidatePropagationMode("flood"); err == nil {
+		t.Error("invalid mode accepted")
+	}
+}
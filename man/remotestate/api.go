@@ -0,0 +1,114 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package remotestate
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/rpc"
+	"github.com/matrix/go-matrix/trie/proof"
+)
+
+// PublicStateAPI answers the state methods of the man namespace from verified
+// upstream state. Registered after the default APIs, it takes over their state
+// methods.
+type PublicStateAPI struct {
+	b *Backend
+}
+
+// NewPublicStateAPI creates a new remote state API.
+func NewPublicStateAPI(b *Backend) *PublicStateAPI {
+	return &PublicStateAPI{b}
+}
+
+// GetBalance returns the amount of wei for the given address in the state of the
+// given block number.
+func (api *PublicStateAPI) GetBalance(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*big.Int, error) {
+	header, err := api.b.Header(blockNr)
+	if err != nil {
+		return nil, err
+	}
+	account, err := api.b.Account(ctx, header, address)
+	if err != nil {
+		return nil, err
+	}
+	return account.Balance.ToInt(), nil
+}
+
+// GetTransactionCount returns the number of transactions the given address has
+// sent for the given block number.
+func (api *PublicStateAPI) GetTransactionCount(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (*hexutil.Uint64, error) {
+	header, err := api.b.Header(blockNr)
+	if err != nil {
+		return nil, err
+	}
+	account, err := api.b.Account(ctx, header, address)
+	if err != nil {
+		return nil, err
+	}
+	nonce := account.Nonce
+	return &nonce, nil
+}
+
+// GetCode returns the code stored at the given address in the state for the
+// given block number.
+func (api *PublicStateAPI) GetCode(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
+	header, err := api.b.Header(blockNr)
+	if err != nil {
+		return nil, err
+	}
+	return api.b.Code(ctx, header, address)
+}
+
+// GetStorageAt returns the storage from the state at the given address, key and
+// block number.
+func (api *PublicStateAPI) GetStorageAt(ctx context.Context, address common.Address, key string, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
+	header, err := api.b.Header(blockNr)
+	if err != nil {
+		return nil, err
+	}
+	value, err := api.b.Storage(ctx, header, address, common.HexToHash(key))
+	if err != nil {
+		return nil, err
+	}
+	return value[:], nil
+}
+
+// GetProof returns the merkle proof of the given account and optionally some
+// of its storage slots, verified against the local header chain.
+func (api *PublicStateAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNr rpc.BlockNumber) (*proof.AccountResult, error) {
+	header, err := api.b.Header(blockNr)
+	if err != nil {
+		return nil, err
+	}
+	slots := make([]common.Hash, len(storageKeys))
+	for i, key := range storageKeys {
+		slots[i] = common.HexToHash(key)
+	}
+	result, err := api.b.Proof(ctx, header, address, slots)
+	if err != nil {
+		return nil, err
+	}
+	// Report the keys as requested, like a full node does
+	for i, key := range storageKeys {
+		result.StorageProof[i].Key = key
+	}
+	return result, nil
+}
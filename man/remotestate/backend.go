@@ -0,0 +1,266 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package remotestate implements a read-through state backend for nodes
+// without local state, such as light clients. State is fetched on demand from
+// upstream full nodes as merkle proofs, verified against the local header
+// chain and cached, turning the node into a verifying RPC proxy.
+package remotestate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/rpc"
+	"github.com/matrix/go-matrix/trie/proof"
+)
+
+var (
+	errNoUpstreams  = errors.New("no upstream nodes configured")
+	errUnknownBlock = errors.New("unknown block")
+
+	// emptyRoot is the root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCodeHash is the code hash of accounts without code.
+	emptyCodeHash = crypto.Keccak256Hash(nil)
+)
+
+// HeaderReader is the local header chain state proofs are verified against.
+type HeaderReader interface {
+	CurrentHeader() *types.Header
+	GetHeaderByNumber(number uint64) *types.Header
+}
+
+// accountKey identifies an account in a state trie.
+type accountKey struct {
+	root    common.Hash
+	address common.Address
+}
+
+// slotKey identifies a slot in a storage trie.
+type slotKey struct {
+	root common.Hash
+	slot common.Hash
+}
+
+// Backend serves verified state from upstream full nodes.
+type Backend struct {
+	headers HeaderReader
+	urls    []string
+	clients []*rpc.Client
+	timeout time.Duration
+	next    uint32 // Index of the upstream tried first, the last one that succeeded
+
+	accounts *lru.Cache // accountKey -> *proof.AccountResult, without storage proofs
+	slots    *lru.Cache // slotKey -> common.Hash
+	codes    *lru.Cache // code hash -> []byte
+}
+
+// New creates a remote state backend verifying against the given header chain,
+// connecting to all configured upstreams.
+func New(config *Config, headers HeaderReader) (*Backend, error) {
+	if len(config.Upstreams) == 0 {
+		return nil, errNoUpstreams
+	}
+	clients := make([]*rpc.Client, 0, len(config.Upstreams))
+	for _, url := range config.Upstreams {
+		client, err := rpc.Dial(url)
+		if err != nil {
+			for _, client := range clients {
+				client.Close()
+			}
+			return nil, fmt.Errorf("failed to dial upstream %s: %v", url, err)
+		}
+		clients = append(clients, client)
+	}
+	return newBackend(config, headers, config.Upstreams, clients), nil
+}
+
+// newBackend creates a remote state backend over already connected upstreams.
+func newBackend(config *Config, headers HeaderReader, urls []string, clients []*rpc.Client) *Backend {
+	size, timeout := config.CacheSize, config.Timeout
+	if size <= 0 {
+		size = DefaultConfig.CacheSize
+	}
+	if timeout <= 0 {
+		timeout = DefaultConfig.Timeout
+	}
+	accounts, _ := lru.New(size)
+	slots, _ := lru.New(size)
+	codes, _ := lru.New(size)
+
+	return &Backend{
+		headers:  headers,
+		urls:     urls,
+		clients:  clients,
+		timeout:  timeout,
+		accounts: accounts,
+		slots:    slots,
+		codes:    codes,
+	}
+}
+
+// Close disconnects from the upstreams.
+func (b *Backend) Close() {
+	for _, client := range b.clients {
+		client.Close()
+	}
+}
+
+// Header resolves a block number to a header of the local chain. Without local
+// state there is no pending block, it resolves to the head.
+func (b *Backend) Header(number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
+		return b.headers.CurrentHeader(), nil
+	}
+	if header := b.headers.GetHeaderByNumber(uint64(number)); header != nil {
+		return header, nil
+	}
+	return nil, errUnknownBlock
+}
+
+// Account returns the verified account at the given header. Non-existent
+// accounts are reported as empty ones, like a full node does.
+func (b *Backend) Account(ctx context.Context, header *types.Header, address common.Address) (*proof.AccountResult, error) {
+	if cached, ok := b.accounts.Get(accountKey{header.Root, address}); ok {
+		return cached.(*proof.AccountResult), nil
+	}
+	return b.Proof(ctx, header, address, nil)
+}
+
+// Storage returns the verified value of a storage slot at the given header.
+func (b *Backend) Storage(ctx context.Context, header *types.Header, address common.Address, slot common.Hash) (common.Hash, error) {
+	if cached, ok := b.accounts.Get(accountKey{header.Root, address}); ok {
+		root := cached.(*proof.AccountResult).StorageHash
+		if root == emptyRoot {
+			return common.Hash{}, nil
+		}
+		if value, ok := b.slots.Get(slotKey{root, slot}); ok {
+			return value.(common.Hash), nil
+		}
+	}
+	result, err := b.Proof(ctx, header, address, []common.Hash{slot})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BigToHash(result.StorageProof[0].Value.ToInt()), nil
+}
+
+// Code returns the verified contract code of an account at the given header.
+func (b *Backend) Code(ctx context.Context, header *types.Header, address common.Address) ([]byte, error) {
+	account, err := b.Account(ctx, header, address)
+	if err != nil {
+		return nil, err
+	}
+	if account.CodeHash == emptyCodeHash {
+		return nil, nil
+	}
+	if cached, ok := b.codes.Get(account.CodeHash); ok {
+		return cached.([]byte), nil
+	}
+	var code hexutil.Bytes
+	err = b.call(ctx, func(ctx context.Context, client *rpc.Client) error {
+		if err := client.CallContext(ctx, &code, "man_getCode", address, hexutil.EncodeBig(header.Number)); err != nil {
+			return err
+		}
+		if hash := crypto.Keccak256Hash(code); hash != account.CodeHash {
+			return fmt.Errorf("code hash mismatch: have %x, want %x", hash, account.CodeHash)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.codes.Add(account.CodeHash, []byte(code))
+	return code, nil
+}
+
+// Proof fetches the merkle proof of an account and some of its storage slots
+// at the given header, verifies it against the header's state root and caches
+// the proven values.
+func (b *Backend) Proof(ctx context.Context, header *types.Header, address common.Address, slots []common.Hash) (*proof.AccountResult, error) {
+	keys := make([]string, len(slots))
+	for i, slot := range slots {
+		keys[i] = slot.Hex()
+	}
+	var result *proof.AccountResult
+	err := b.call(ctx, func(ctx context.Context, client *rpc.Client) error {
+		result = new(proof.AccountResult)
+		if err := client.CallContext(ctx, result, "man_getProof", address, keys, hexutil.EncodeBig(header.Number)); err != nil {
+			return err
+		}
+		// Make sure the proof covers what was asked for before checking it
+		if result.Address != address {
+			return fmt.Errorf("account mismatch: have %x, want %x", result.Address, address)
+		}
+		if len(result.StorageProof) != len(slots) {
+			return fmt.Errorf("storage proof count mismatch: have %d, want %d", len(result.StorageProof), len(slots))
+		}
+		for i, slot := range slots {
+			if key := common.HexToHash(result.StorageProof[i].Key); key != slot {
+				return fmt.Errorf("storage slot mismatch: have %x, want %x", key, slot)
+			}
+		}
+		return result.Verify(header.Root)
+	})
+	if err != nil {
+		return nil, err
+	}
+	account := *result
+	account.StorageProof = nil
+	b.accounts.Add(accountKey{header.Root, address}, &account)
+
+	for i, slot := range slots {
+		b.slots.Add(slotKey{result.StorageHash, slot}, common.BigToHash(result.StorageProof[i].Value.ToInt()))
+	}
+	return result, nil
+}
+
+// call runs a request against the upstreams in turn, starting with the last one
+// that succeeded, until one of them succeeds.
+func (b *Backend) call(ctx context.Context, request func(ctx context.Context, client *rpc.Client) error) error {
+	var (
+		start = int(atomic.LoadUint32(&b.next))
+		err   error
+	)
+	for i := range b.clients {
+		index := (start + i) % len(b.clients)
+
+		reqctx, cancel := context.WithTimeout(ctx, b.timeout)
+		err = request(reqctx, b.clients[index])
+		cancel()
+
+		if err == nil {
+			atomic.StoreUint32(&b.next, uint32(index))
+			return nil
+		}
+		log.Warn("Remote state request failed", "upstream", b.urls[index], "err", err)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return err
+}
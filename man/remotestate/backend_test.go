@@ -0,0 +1,265 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package remotestate
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core/state"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/mandb"
+	"github.com/matrix/go-matrix/params"
+	"github.com/matrix/go-matrix/rpc"
+	"github.com/matrix/go-matrix/trie/proof"
+)
+
+var (
+	testContract = common.HexToAddress("0x01")
+	testAccount  = common.HexToAddress("0x02")
+	testCode     = []byte{0x60, 0x00, 0x60, 0x00}
+	testSlot     = common.HexToHash("0x01")
+)
+
+// testChain is a header chain of a single block.
+type testChain struct {
+	header *types.Header
+}
+
+func (c *testChain) CurrentHeader() *types.Header { return c.header }
+
+func (c *testChain) GetHeaderByNumber(number uint64) *types.Header {
+	if number == c.header.Number.Uint64() {
+		return c.header
+	}
+	return nil
+}
+
+// UpstreamAPI serves the state methods of a full node, counting the requests
+// and optionally tampering with the replies.
+type UpstreamAPI struct {
+	state    *state.StateDB
+	tamper   bool
+	requests int
+}
+
+func (u *UpstreamAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNr rpc.BlockNumber) (*proof.AccountResult, error) {
+	u.requests++
+
+	accountProof, err := u.state.GetProof(address)
+	if err != nil {
+		return nil, err
+	}
+	result := &proof.AccountResult{
+		Address:      address,
+		AccountProof: toHex(accountProof),
+		Balance:      (*hexutil.Big)(u.state.GetBalance(address)),
+		CodeHash:     crypto.Keccak256Hash(nil),
+		Nonce:        hexutil.Uint64(u.state.GetNonce(address)),
+		StorageHash:  emptyRoot,
+	}
+	tr := u.state.StorageTrie(address)
+	if tr != nil {
+		result.CodeHash = u.state.GetCodeHash(address)
+		result.StorageHash = tr.Hash()
+	}
+	for _, key := range storageKeys {
+		// Slots of non-existent accounts are proven by the account's absence
+		if tr == nil {
+			result.StorageProof = append(result.StorageProof, proof.StorageResult{Key: key, Value: &hexutil.Big{}, Proof: []string{}})
+			continue
+		}
+		slot := common.HexToHash(key)
+		storageProof, err := u.state.GetStorageProof(address, slot)
+		if err != nil {
+			return nil, err
+		}
+		result.StorageProof = append(result.StorageProof, proof.StorageResult{
+			Key:   key,
+			Value: (*hexutil.Big)(u.state.GetState(address, slot).Big()),
+			Proof: toHex(storageProof),
+		})
+	}
+	if u.tamper {
+		result.Balance = (*hexutil.Big)(new(big.Int).Add(result.Balance.ToInt(), big.NewInt(1)))
+	}
+	return result, nil
+}
+
+func (u *UpstreamAPI) GetCode(ctx context.Context, address common.Address, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
+	u.requests++
+
+	code := u.state.GetCode(address)
+	if u.tamper && len(code) > 0 {
+		code = append([]byte{0x00}, code...)
+	}
+	return code, nil
+}
+
+func toHex(nodes [][]byte) []string {
+	hex := make([]string, len(nodes))
+	for i, node := range nodes {
+		hex[i] = hexutil.Encode(node)
+	}
+	return hex
+}
+
+// newTestBackend creates a remote state backend over in-process upstreams
+// serving a committed test state.
+func newTestBackend(t *testing.T, upstreams ...*UpstreamAPI) (*Backend, *types.Header) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(mandb.NewMemDatabase()))
+	statedb.SetBalance(testContract, big.NewInt(1000))
+	statedb.SetNonce(testContract, 3)
+	statedb.SetCode(testContract, testCode)
+	statedb.SetState(testContract, testSlot, common.HexToHash("0xdead"))
+	statedb.SetBalance(testAccount, big.NewInt(42))
+
+	root, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	var (
+		urls    []string
+		clients []*rpc.Client
+	)
+	for _, upstream := range upstreams {
+		upstream.state = statedb
+
+		server := rpc.NewServer()
+		if err := server.RegisterName("man", upstream); err != nil {
+			t.Fatalf("failed to register upstream: %v", err)
+		}
+		urls, clients = append(urls, "inproc"), append(clients, rpc.DialInProc(server))
+	}
+	header := &types.Header{Number: big.NewInt(10), Root: root}
+	return newBackend(&DefaultConfig, &testChain{header}, urls, clients), header
+}
+
+func TestRemoteState(t *testing.T) {
+	upstream := new(UpstreamAPI)
+	backend, _ := newTestBackend(t, upstream)
+	defer backend.Close()
+
+	api := NewPublicStateAPI(backend)
+	ctx := context.Background()
+
+	balance, err := api.GetBalance(ctx, testContract, rpc.LatestBlockNumber)
+	if err != nil || balance.Cmp(big.NewInt(1000)) != 0 {
+		t.Fatalf("balance mismatch: have %v, %v, want 1000", balance, err)
+	}
+	nonce, err := api.GetTransactionCount(ctx, testContract, 10)
+	if want := upstream.state.GetNonce(testContract); err != nil || uint64(*nonce) != want {
+		t.Fatalf("nonce mismatch: have %v, %v, want %d", nonce, err, want)
+	}
+	code, err := api.GetCode(ctx, testContract, rpc.LatestBlockNumber)
+	if err != nil || !bytes.Equal(code, testCode) {
+		t.Fatalf("code mismatch: have %x, %v, want %x", code, err, testCode)
+	}
+	value, err := api.GetStorageAt(ctx, testContract, testSlot.Hex(), rpc.LatestBlockNumber)
+	if err != nil || common.BytesToHash(value) != common.HexToHash("0xdead") {
+		t.Fatalf("storage mismatch: have %x, %v, want 0xdead", value, err)
+	}
+	// Verified values are served from the cache
+	requests := upstream.requests
+	api.GetBalance(ctx, testContract, rpc.LatestBlockNumber)
+	api.GetCode(ctx, testContract, rpc.LatestBlockNumber)
+	api.GetStorageAt(ctx, testContract, testSlot.Hex(), rpc.LatestBlockNumber)
+	if upstream.requests != requests {
+		t.Fatalf("cached values requested again: %d requests, want %d", upstream.requests, requests)
+	}
+	// Accounts without code or storage, or not existing at all
+	if code, err := api.GetCode(ctx, testAccount, rpc.LatestBlockNumber); err != nil || len(code) != 0 {
+		t.Fatalf("empty code mismatch: have %x, %v", code, err)
+	}
+	if value, err := api.GetStorageAt(ctx, testAccount, testSlot.Hex(), rpc.LatestBlockNumber); err != nil || common.BytesToHash(value) != (common.Hash{}) {
+		t.Fatalf("empty storage mismatch: have %x, %v", value, err)
+	}
+	missing := common.HexToAddress("0x03")
+	if nonce, err := api.GetTransactionCount(ctx, missing, rpc.LatestBlockNumber); err != nil || uint64(*nonce) != params.NonceAddOne {
+		t.Fatalf("missing account nonce mismatch: have %v, %v", nonce, err)
+	}
+	if value, err := api.GetStorageAt(ctx, missing, testSlot.Hex(), rpc.LatestBlockNumber); err != nil || common.BytesToHash(value) != (common.Hash{}) {
+		t.Fatalf("missing account storage mismatch: have %x, %v", value, err)
+	}
+	if _, err := api.GetBalance(ctx, testContract, 11); err != errUnknownBlock {
+		t.Fatalf("unknown block error mismatch: have %v, want %v", err, errUnknownBlock)
+	}
+}
+
+// Tests that storage of accounts missing from the state is served from the proof
+// of the account's absence, without fetching the account first.
+func TestRemoteStateMissingStorage(t *testing.T) {
+	upstream := new(UpstreamAPI)
+	backend, header := newTestBackend(t, upstream)
+	defer backend.Close()
+
+	missing := common.HexToAddress("0x03")
+	value, err := backend.Storage(context.Background(), header, missing, testSlot)
+	if err != nil || value != (common.Hash{}) {
+		t.Fatalf("missing account storage mismatch: have %x, %v", value, err)
+	}
+	result, err := backend.Proof(context.Background(), header, missing, []common.Hash{testSlot, common.HexToHash("0x02")})
+	if err != nil {
+		t.Fatalf("failed to prove missing account: %v", err)
+	}
+	if result.StorageHash != emptyRoot || len(result.StorageProof) != 2 {
+		t.Fatalf("missing account proof mismatch: storage hash %x, %d slots", result.StorageHash, len(result.StorageProof))
+	}
+	for _, slot := range result.StorageProof {
+		if slot.Value.ToInt().Sign() != 0 || len(slot.Proof) != 0 {
+			t.Fatalf("missing account slot %s mismatch: value %v, proof %v", slot.Key, slot.Value, slot.Proof)
+		}
+	}
+}
+
+func TestRemoteStateTampered(t *testing.T) {
+	// A lying upstream alone is rejected
+	backend, _ := newTestBackend(t, &UpstreamAPI{tamper: true})
+	defer backend.Close()
+
+	api := NewPublicStateAPI(backend)
+	ctx := context.Background()
+
+	if _, err := api.GetBalance(ctx, testContract, rpc.LatestBlockNumber); err == nil {
+		t.Fatalf("tampered balance accepted")
+	}
+	// With an honest one, requests fail over to it
+	liar, honest := &UpstreamAPI{tamper: true}, new(UpstreamAPI)
+	backend, _ = newTestBackend(t, liar, honest)
+	defer backend.Close()
+
+	api = NewPublicStateAPI(backend)
+	if balance, err := api.GetBalance(ctx, testContract, rpc.LatestBlockNumber); err != nil || balance.Cmp(big.NewInt(1000)) != 0 {
+		t.Fatalf("balance mismatch: have %v, %v, want 1000", balance, err)
+	}
+	if code, err := api.GetCode(ctx, testContract, rpc.LatestBlockNumber); err != nil || !bytes.Equal(code, testCode) {
+		t.Fatalf("code mismatch: have %x, %v, want %x", code, err, testCode)
+	}
+	// The honest upstream is preferred from then on
+	requests := liar.requests
+	if _, err := api.GetProof(ctx, testAccount, []string{testSlot.Hex()}, rpc.LatestBlockNumber); err != nil {
+		t.Fatalf("failed to get proof: %v", err)
+	}
+	if liar.requests != requests {
+		t.Fatalf("failed upstream retried: %d requests, want %d", liar.requests, requests)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package remotestate
+
+import "time"
+
+// DefaultConfig contains default settings for the remote state backend.
+var DefaultConfig = Config{
+	CacheSize: 4096,
+	Timeout:   10 * time.Second,
+}
+
+// Config contains the configuration parameters of the remote state backend.
+type Config struct {
+	// Upstreams are the RPC endpoints of the full nodes state is fetched from,
+	// tried in turn until one serves a valid proof.
+	Upstreams []string
+
+	// CacheSize is the number of verified accounts, storage slots and contract
+	// codes kept in memory.
+	CacheSize int `toml:",omitempty"`
+
+	// Timeout is the time allowed for a single upstream request.
+	Timeout time.Duration `toml:",omitempty"`
+}
@@ -0,0 +1,159 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package man
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/core/rawdb"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+// rpcCacheConfirmations is the number of blocks after which a block is deemed
+// final, so replies referencing it by number may be cached across head changes.
+const rpcCacheConfirmations = 64
+
+// rpcCacheByHash lists the methods whose replies only depend on an immutable
+// block or transaction hash.
+var rpcCacheByHash = map[string]bool{
+	"getBlockByHash":                       true,
+	"getBlockTransactionCountByHash":       true,
+	"getTransactionByBlockHashAndIndex":    true,
+	"getRawTransactionByBlockHashAndIndex": true,
+	"getUncleByBlockHashAndIndex":          true,
+	"getUncleCountByBlockHash":             true,
+	"getSignAccountsByHash":                true,
+}
+
+// rpcCacheByNumber lists the methods taking a block number argument, mapped to
+// the position of that argument.
+var rpcCacheByNumber = map[string]int{
+	"getBlockByNumber":                       0,
+	"getBlockTransactionCountByNumber":       0,
+	"getTransactionByBlockNumberAndIndex":    0,
+	"getRawTransactionByBlockNumberAndIndex": 0,
+	"getUncleByBlockNumberAndIndex":          0,
+	"getUncleCountByBlockNumber":             0,
+	"getSignAccountsByNumber":                0,
+	"getBalance":                             1,
+	"getCode":                                1,
+	"getTransactionCount":                    1,
+	"call":                                   1,
+	"getStorageAt":                           2,
+}
+
+// rpcCacheByHead lists the parameterless methods whose replies only change
+// with the chain head.
+var rpcCacheByHead = map[string]bool{
+	"blockNumber": true,
+	"gasPrice":    true,
+}
+
+// rpcCachePolicy decides which chain queries may be served from the node's RPC
+// response cache and for how long. Replies depending on the chain head are tagged
+// with the head hash and replies referencing a block by number with the
+// canonical hash at that number, so rewinds and reorgs never serve replies of
+// blocks that are no longer canonical.
+func (s *Matrix) rpcCachePolicy(method string, params json.RawMessage) (rpc.CacheMode, string) {
+	sep := strings.Index(method, "_")
+	if sep < 0 {
+		return rpc.CacheNone, ""
+	}
+	namespace, name := method[:sep], method[sep+1:]
+
+	switch namespace {
+	case "net":
+		if name == "version" {
+			return rpc.CachePinned, ""
+		}
+		return rpc.CacheNone, ""
+	case "man", "eth":
+	default:
+		return rpc.CacheNone, ""
+	}
+	if name == "protocolVersion" {
+		return rpc.CachePinned, ""
+	}
+	if rpcCacheByHash[name] {
+		return rpc.CachePinned, ""
+	}
+	head := s.blockchain.CurrentBlock()
+	headTag := string(head.Hash().Bytes())
+	if rpcCacheByHead[name] {
+		return rpc.CacheUntilHead, headTag
+	}
+	pos, ok := rpcCacheByNumber[name]
+	if !ok {
+		return rpc.CacheNone, ""
+	}
+	var args []json.RawMessage
+	if err := json.Unmarshal(params, &args); err != nil {
+		return rpc.CacheNone, ""
+	}
+	if len(args) <= pos {
+		// Calls without a block number default to the latest block
+		return rpc.CacheUntilHead, headTag
+	}
+	var number rpc.BlockNumber
+	if err := json.Unmarshal(args[pos], &number); err != nil {
+		return rpc.CacheNone, ""
+	}
+	switch number {
+	case rpc.PendingBlockNumber:
+		return rpc.CacheNone, ""
+	case rpc.LatestBlockNumber:
+		return rpc.CacheUntilHead, headTag
+	}
+	switch {
+	case uint64(number)+rpcCacheConfirmations <= head.NumberU64():
+		hash := rawdb.ReadCanonicalHash(s.chainDb, uint64(number))
+		if hash == (common.Hash{}) {
+			return rpc.CacheNone, ""
+		}
+		return rpc.CachePinned, string(hash.Bytes())
+	case uint64(number) <= head.NumberU64():
+		return rpc.CacheUntilHead, headTag
+	default:
+		return rpc.CacheNone, ""
+	}
+}
+
+// rpcCacheLoop drops the head dependent replies of the RPC response cache on
+// every new chain head.
+func (s *Matrix) rpcCacheLoop() {
+	headCh := make(chan core.ChainHeadEvent, 16)
+	headSub := s.blockchain.SubscribeChainHeadEvent(headCh)
+	defer headSub.Unsubscribe()
+
+	for {
+		select {
+		case <-headCh:
+			s.rpcCache.Purge()
+		case err := <-headSub.Err():
+			if err != nil {
+				log.Warn("RPC cache head subscription failed", "err", err)
+			}
+			return
+		case <-s.shutdownChan:
+			return
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package man
+
+import (
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/consensus/manash"
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/core/vm"
+	"github.com/matrix/go-matrix/mandb"
+	"github.com/matrix/go-matrix/params"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+// RPCCacheTestService serves the canonical hash of a block by number, counting
+// the calls reaching it past the cache.
+type RPCCacheTestService struct {
+	chain *core.BlockChain
+	calls int
+}
+
+func (s *RPCCacheTestService) GetBlockByNumber(number rpc.BlockNumber) *common.Hash {
+	s.calls++
+	block := s.chain.GetBlockByNumber(uint64(number))
+	if block == nil {
+		return nil
+	}
+	hash := block.Hash()
+	return &hash
+}
+
+// Tests that replies pinned by block number are not served once the chain is
+// rewound below that number and rebuilt on a different branch.
+func TestRPCCacheRewind(t *testing.T) {
+	var (
+		db     = mandb.NewMemDatabase()
+		engine = manash.NewFaker()
+		gspec  = &core.Genesis{Config: params.TestChainConfig, GasLimit: 10000000}
+		number = hexutil.Uint64(10)
+	)
+	genesis := gspec.MustCommit(db)
+
+	blockchain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, engine, db, 80, nil)
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	cache, err := rpc.NewResponseCache(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	man := &Matrix{chainDb: db, blockchain: blockchain}
+	cache.SetPolicy(man.rpcCachePolicy)
+
+	service := &RPCCacheTestService{chain: blockchain}
+	server := rpc.NewServer()
+	if err := server.RegisterName("man", service); err != nil {
+		t.Fatal(err)
+	}
+	server.SetCache(cache)
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	get := func() *common.Hash {
+		var hash *common.Hash
+		if err := client.Call(&hash, "man_getBlockByNumber", number); err != nil {
+			t.Fatalf("failed to retrieve block: %v", err)
+		}
+		return hash
+	}
+	if hash := get(); hash == nil || *hash != blocks[number-1].Hash() {
+		t.Fatalf("block hash mismatch: have %v, want %x", hash, blocks[number-1].Hash())
+	}
+	get()
+	if service.calls != 1 {
+		t.Fatalf("final block not served from the cache: %d calls", service.calls)
+	}
+	// Rewind below the pinned block and rebuild the chain on another branch
+	if err := blockchain.SetHead(5); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if hash := get(); hash != nil {
+		t.Fatalf("rewound block served: %x", *hash)
+	}
+	fork, _ := core.GenerateChain(gspec.Config, blockchain.CurrentBlock(), engine, db, 80, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	if _, err := blockchain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	want := fork[number-6].Hash()
+	if hash := get(); hash == nil || *hash != want {
+		t.Fatalf("block hash mismatch after rewind: have %v, want %x", hash, want)
+	}
+	calls := service.calls
+	if hash := get(); hash == nil || *hash != want || service.calls != calls {
+		t.Fatalf("rebuilt block not served from the cache: have %v, %d calls", hash, service.calls-calls)
+	}
+}
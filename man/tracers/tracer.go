@@ -299,6 +299,8 @@ type Tracer struct {
 	ctx map[string]interface{} // Transaction context gathered throughout execution
 	err error                  // Error, if one has occurred
 
+	precompiles map[common.Address]vm.PrecompiledContract // Precompiles active at the traced block
+
 	interrupt uint32 // Atomic flag to signal execution interruption
 	reason    error  // Textual reason for the interruption
 }
@@ -319,6 +321,7 @@ func New(code string) (*Tracer, error) {
 		memoryWrapper:   new(memoryWrapper),
 		contractWrapper: new(contractWrapper),
 		dbWrapper:       new(dbWrapper),
+		precompiles:     vm.PrecompiledContractsByzantium,
 		pcValue:         new(uint),
 		gasValue:        new(uint),
 		costValue:       new(uint),
@@ -366,7 +369,7 @@ func New(code string) (*Tracer, error) {
 		return 1
 	})
 	tracer.vm.PushGlobalGoFunction("isPrecompiled", func(ctx *duktape.Context) int {
-		_, ok := vm.PrecompiledContractsByzantium[common.BytesToAddress(popSlice(ctx))]
+		_, ok := tracer.precompiles[common.BytesToAddress(popSlice(ctx))]
 		ctx.PushBoolean(ok)
 		return 1
 	})
@@ -515,6 +518,7 @@ func (jst *Tracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost
 		// Initialize the context if it wasn't done yet
 		if !jst.inited {
 			jst.ctx["block"] = env.BlockNumber.Uint64()
+			jst.precompiles = vm.ActivePrecompiles(env.ChainConfig().Rules(env.BlockNumber))
 			jst.inited = true
 		}
 		// If tracing was interrupted, set the error and stop
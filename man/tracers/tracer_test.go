@@ -138,3 +138,36 @@ func TestHaltBetweenSteps(t *testing.T) {
 		t.Errorf("Expected timeout error, got %v", err)
 	}
 }
+
+func TestIsPrecompiled(t *testing.T) {
+	config := *params.TestChainConfig
+	config.KZGBlock = big.NewInt(100)
+
+	tests := []struct {
+		number *big.Int
+		want   string
+	}{
+		{big.NewInt(99), "false"},
+		{big.NewInt(100), "true"},
+	}
+	for i, tt := range tests {
+		tracer, err := New("{addr: toAddress('0x000000000000000000000000000000000000000b'), res: null, step: function() { this.res = isPrecompiled(this.addr); }, fault: function() {}, result: function() { return this.res; }}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		env := vm.NewEVM(vm.Context{BlockNumber: tt.number}, nil, &config, vm.Config{Debug: true, Tracer: tracer})
+		contract := vm.NewContract(account{}, account{}, big.NewInt(0), 10000)
+		contract.Code = []byte{byte(vm.STOP)}
+
+		if _, err := env.Interpreter().Run(contract, []byte{}); err != nil {
+			t.Fatal(err)
+		}
+		ret, err := tracer.GetResult()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(ret) != tt.want {
+			t.Errorf("test %d: isPrecompiled at block %v = %s, want %s", i, tt.number, ret, tt.want)
+		}
+	}
+}
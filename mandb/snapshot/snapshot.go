@@ -0,0 +1,305 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package snapshot implements downloading and verifying signed chain database
+// snapshots, allowing new nodes to bootstrap from a trusted copy of the
+// database instead of syncing from genesis.
+//
+// A snapshot is published as a JSON manifest listing every database file with
+// its size and SHA256 hash. The manifest is signed by one or more publisher
+// keys; a node only accepts it if enough signatures recover to addresses the
+// operator explicitly trusts. File paths are relative to the database directory
+// and are downloaded from the same location relative to the manifest URL.
+package snapshot
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/log"
+)
+
+var (
+	errNoSigners      = errors.New("no trusted snapshot signers configured")
+	errNotEnoughSigs  = errors.New("not enough trusted signatures on snapshot manifest")
+	errEmptySnapshot  = errors.New("snapshot manifest lists no files")
+	errDestinationSet = errors.New("destination already contains a database")
+	errHashMismatch   = errors.New("snapshot head hash mismatch")
+)
+
+// File is a single database file listed in a snapshot manifest.
+type File struct {
+	Path   string `json:"path"`   // slash separated, relative to the snapshot root
+	Size   int64  `json:"size"`   // size of the file in bytes
+	SHA256 string `json:"sha256"` // hex encoded SHA256 of the file contents
+}
+
+// Manifest describes a signed database snapshot.
+type Manifest struct {
+	Network    uint64          `json:"network"`    // network id the snapshot belongs to
+	Number     uint64          `json:"number"`     // head block number of the snapshot
+	Hash       common.Hash     `json:"hash"`       // head block hash of the snapshot
+	Created    time.Time       `json:"created"`    // creation time of the snapshot
+	Files      []File          `json:"files"`      // files making up the database
+	Signatures []hexutil.Bytes `json:"signatures"` // publisher signatures over SigHash
+}
+
+// SigHash returns the hash signed by snapshot publishers: the Keccak256 of the
+// JSON encoding of the manifest with its signatures stripped.
+func (m *Manifest) SigHash() common.Hash {
+	unsigned := *m
+	unsigned.Signatures = nil
+	blob, _ := json.Marshal(&unsigned)
+	return crypto.Keccak256Hash(blob)
+}
+
+// Sign adds a publisher signature to the manifest.
+func (m *Manifest) Sign(key *ecdsa.PrivateKey) error {
+	hash := m.SigHash()
+	sig, err := crypto.Sign(hash[:], key)
+	if err != nil {
+		return err
+	}
+	m.Signatures = append(m.Signatures, sig)
+	return nil
+}
+
+// Verify checks that at least threshold distinct trusted signers signed the
+// manifest and that the file list is well formed.
+func (m *Manifest) Verify(signers []common.Address, threshold int) error {
+	if len(signers) == 0 {
+		return errNoSigners
+	}
+	if threshold < 1 {
+		threshold = 1
+	}
+	trusted := make(map[common.Address]bool)
+	for _, signer := range signers {
+		trusted[signer] = true
+	}
+	hash := m.SigHash()
+	seen := make(map[common.Address]bool)
+	for _, sig := range m.Signatures {
+		pub, err := crypto.SigToPub(hash[:], sig)
+		if err != nil {
+			continue
+		}
+		if signer := crypto.PubkeyToAddress(*pub); trusted[signer] {
+			seen[signer] = true
+		}
+	}
+	if len(seen) < threshold {
+		return fmt.Errorf("%v: have %d, want %d", errNotEnoughSigs, len(seen), threshold)
+	}
+	if len(m.Files) == 0 {
+		return errEmptySnapshot
+	}
+	for _, file := range m.Files {
+		if err := checkPath(file.Path); err != nil {
+			return err
+		}
+		if raw, err := hex.DecodeString(file.SHA256); err != nil || len(raw) != sha256.Size {
+			return fmt.Errorf("invalid hash for snapshot file %q", file.Path)
+		}
+	}
+	return nil
+}
+
+// checkPath ensures a manifest path cannot escape the destination directory.
+func checkPath(p string) error {
+	if p == "" || path.IsAbs(p) || strings.Contains(p, "\\") {
+		return fmt.Errorf("invalid snapshot file path %q", p)
+	}
+	for _, elem := range strings.Split(p, "/") {
+		if elem == ".." || elem == "." || elem == "" {
+			return fmt.Errorf("invalid snapshot file path %q", p)
+		}
+	}
+	return nil
+}
+
+// Build creates an unsigned manifest for all files below dir.
+func Build(dir string, network, number uint64, hash common.Hash) (*Manifest, error) {
+	m := &Manifest{Network: network, Number: number, Hash: hash, Created: time.Now().UTC()}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		hasher := sha256.New()
+		size, err := io.Copy(hasher, f)
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, File{Path: filepath.ToSlash(rel), Size: size, SHA256: hex.EncodeToString(hasher.Sum(nil))})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	return m, nil
+}
+
+// Fetcher downloads snapshots over HTTP(S) or from the local file system.
+type Fetcher struct {
+	Client    *http.Client
+	Signers   []common.Address // addresses trusted to sign snapshot manifests
+	Threshold int              // number of distinct trusted signatures required
+	Hash      common.Hash      // head block hash the snapshot must have, any if zero
+}
+
+// Manifest downloads and verifies the manifest at the given location, refusing
+// it unless its head block is the requested one.
+func (f *Fetcher) Manifest(location string) (*Manifest, error) {
+	rc, err := f.open(location)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	m := new(Manifest)
+	if err := json.NewDecoder(io.LimitReader(rc, 64*1024*1024)).Decode(m); err != nil {
+		return nil, fmt.Errorf("invalid snapshot manifest: %v", err)
+	}
+	if err := m.Verify(f.Signers, f.Threshold); err != nil {
+		return nil, err
+	}
+	if f.Hash != (common.Hash{}) && m.Hash != f.Hash {
+		return nil, fmt.Errorf("%v: have %x, want %x", errHashMismatch, m.Hash, f.Hash)
+	}
+	return m, nil
+}
+
+// Download fetches every file of a verified manifest published at location
+// into dest. Files are first written to a temporary directory next to dest and
+// only moved into place once all of them matched their manifest hash, so an
+// interrupted or tampered download never leaves a partial database behind.
+func (f *Fetcher) Download(location string, m *Manifest, dest string) error {
+	if entries, err := ioutil.ReadDir(dest); err == nil && len(entries) > 0 {
+		return fmt.Errorf("%v: %s", errDestinationSet, dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempDir(filepath.Dir(dest), ".snapshot-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	var total int64
+	for _, file := range m.Files {
+		total += file.Size
+	}
+	var done int64
+	for i, file := range m.Files {
+		log.Info("Downloading snapshot file", "file", file.Path, "index", i+1, "files", len(m.Files), "progress", fmt.Sprintf("%.2f%%", 100*float64(done)/float64(total+1)))
+		if err := f.fetchFile(resolve(location, file.Path), filepath.Join(tmp, filepath.FromSlash(file.Path)), file); err != nil {
+			return err
+		}
+		done += file.Size
+	}
+	os.RemoveAll(dest)
+	return os.Rename(tmp, dest)
+}
+
+// fetchFile downloads a single file, verifying its size and hash on the fly.
+func (f *Fetcher) fetchFile(location, target string, file File) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return err
+	}
+	rc, err := f.open(location)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hasher), io.LimitReader(rc, file.Size+1))
+	if err != nil {
+		return err
+	}
+	if size != file.Size {
+		return fmt.Errorf("snapshot file %q size mismatch: have %d, want %d", file.Path, size, file.Size)
+	}
+	if have := hex.EncodeToString(hasher.Sum(nil)); have != strings.ToLower(file.SHA256) {
+		return fmt.Errorf("snapshot file %q hash mismatch: have %s, want %s", file.Path, have, file.SHA256)
+	}
+	return out.Sync()
+}
+
+// open returns a reader for an http(s) URL or a local path.
+func (f *Fetcher) open(location string) (io.ReadCloser, error) {
+	u, err := url.Parse(location)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return os.Open(strings.TrimPrefix(location, "file://"))
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("failed to fetch %s: %s", location, res.Status)
+	}
+	return res.Body, nil
+}
+
+// resolve returns the location of a manifest relative file.
+func resolve(manifest, file string) string {
+	if u, err := url.Parse(manifest); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		ref, _ := url.Parse(file)
+		return u.ResolveReference(ref).String()
+	}
+	return filepath.Join(filepath.Dir(strings.TrimPrefix(manifest, "file://")), filepath.FromSlash(file))
+}
@@ -0,0 +1,167 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package snapshot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/crypto"
+)
+
+// makeSnapshot creates a signed snapshot of a small fake database in a
+// temporary directory, returning the directory and the signer address.
+func makeSnapshot(t *testing.T) (string, common.Address) {
+	dir, err := ioutil.TempDir("", "snapshot-src-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"chaindata/000001.ldb": "block data",
+		"chaindata/CURRENT":    "MANIFEST-000002\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, "db", filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0700)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := Build(filepath.Join(dir, "db"), 1, 100, common.HexToHash("0x01"))
+	if err != nil {
+		t.Fatalf("failed to build manifest: %v", err)
+	}
+	key, _ := crypto.GenerateKey()
+	if err := m.Sign(key); err != nil {
+		t.Fatalf("failed to sign manifest: %v", err)
+	}
+	blob, _ := json.Marshal(m)
+	if err := ioutil.WriteFile(filepath.Join(dir, "db", "manifest.json"), blob, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return dir, crypto.PubkeyToAddress(key.PublicKey)
+}
+
+func TestDownload(t *testing.T) {
+	src, signer := makeSnapshot(t)
+	defer os.RemoveAll(src)
+
+	server := httptest.NewServer(http.FileServer(http.Dir(filepath.Join(src, "db"))))
+	defer server.Close()
+
+	dest, _ := ioutil.TempDir("", "snapshot-dst-")
+	defer os.RemoveAll(dest)
+
+	fetcher := &Fetcher{Signers: []common.Address{signer}, Threshold: 1}
+	m, err := fetcher.Manifest(server.URL + "/manifest.json")
+	if err != nil {
+		t.Fatalf("failed to fetch manifest: %v", err)
+	}
+	if len(m.Files) != 2 {
+		t.Fatalf("manifest file count mismatch: have %d, want 2", len(m.Files))
+	}
+	if err := fetcher.Download(server.URL+"/manifest.json", m, filepath.Join(dest, "gman")); err != nil {
+		t.Fatalf("failed to download snapshot: %v", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dest, "gman", "chaindata", "000001.ldb"))
+	if err != nil || string(data) != "block data" {
+		t.Fatalf("downloaded file mismatch: %q, %v", data, err)
+	}
+}
+
+func TestDownloadTampered(t *testing.T) {
+	src, signer := makeSnapshot(t)
+	defer os.RemoveAll(src)
+
+	fetcher := &Fetcher{Signers: []common.Address{signer}}
+	manifest := filepath.Join(src, "db", "manifest.json")
+	m, err := fetcher.Manifest(manifest)
+	if err != nil {
+		t.Fatalf("failed to load manifest: %v", err)
+	}
+	// Same size, different contents
+	ioutil.WriteFile(filepath.Join(src, "db", "chaindata", "000001.ldb"), []byte("bad  data"+" "), 0600)
+
+	dest := filepath.Join(src, "dest")
+	if err := fetcher.Download(manifest, m, dest); err == nil {
+		t.Fatalf("tampered snapshot accepted")
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatalf("partial snapshot left behind: %v", err)
+	}
+}
+
+func TestManifestHash(t *testing.T) {
+	src, signer := makeSnapshot(t)
+	defer os.RemoveAll(src)
+
+	manifest := filepath.Join(src, "db", "manifest.json")
+
+	fetcher := &Fetcher{Signers: []common.Address{signer}, Hash: common.HexToHash("0x01")}
+	if _, err := fetcher.Manifest(manifest); err != nil {
+		t.Errorf("requested snapshot rejected: %v", err)
+	}
+	fetcher.Hash = common.HexToHash("0x02")
+	if _, err := fetcher.Manifest(manifest); err == nil {
+		t.Errorf("snapshot of another head accepted")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	src, signer := makeSnapshot(t)
+	defer os.RemoveAll(src)
+
+	blob, _ := ioutil.ReadFile(filepath.Join(src, "db", "manifest.json"))
+	m := new(Manifest)
+	json.Unmarshal(blob, m)
+
+	if err := m.Verify(nil, 1); err != errNoSigners {
+		t.Errorf("missing signers: have %v, want %v", err, errNoSigners)
+	}
+	if err := m.Verify([]common.Address{common.HexToAddress("0x02")}, 1); err == nil {
+		t.Errorf("untrusted signer accepted")
+	}
+	if err := m.Verify([]common.Address{signer}, 2); err == nil {
+		t.Errorf("threshold not enforced")
+	}
+	if err := m.Verify([]common.Address{signer}, 1); err != nil {
+		t.Errorf("valid manifest rejected: %v", err)
+	}
+	m.Number++
+	if err := m.Verify([]common.Address{signer}, 1); err == nil {
+		t.Errorf("modified manifest accepted")
+	}
+}
+
+func TestCheckPath(t *testing.T) {
+	for _, p := range []string{"", "/etc/passwd", "../x", "a/../../x", "a//b", "./a", "a\\b"} {
+		if checkPath(p) == nil {
+			t.Errorf("path %q accepted", p)
+		}
+	}
+	for _, p := range []string{"a", "chaindata/000001.ldb"} {
+		if err := checkPath(p); err != nil {
+			t.Errorf("path %q rejected: %v", p, err)
+		}
+	}
+}
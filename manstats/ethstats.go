@@ -28,6 +28,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/matrix/go-matrix/common"
@@ -80,6 +81,10 @@ type Service struct {
 
 	pongCh chan struct{} // Pong notifications are fed into this channel
 	histCh chan []uint64 // History request block numbers are fed into this channel
+
+	quit   chan struct{}  // Terminates the reporting loop
+	faults chan error     // Crashes of the reporting loop, reported to the node
+	wg     sync.WaitGroup // Reporting loop, waited for on Stop
 }
 
 // New returns a monitoring service ready for stats reporting.
@@ -106,6 +111,7 @@ func New(url string, manServ *man.Matrix, lesServ *les.LightMatrix) (*Service, e
 		host:   parts[4],
 		pongCh: make(chan struct{}),
 		histCh: make(chan []uint64, 1),
+		faults: make(chan error, 1),
 	}, nil
 }
 
@@ -120,7 +126,10 @@ func (s *Service) APIs() []rpc.API { return nil }
 // Start implements node.Service, starting up the monitoring and reporting daemon.
 func (s *Service) Start(server *p2p.Server) error {
 	s.server = server
-	go s.loop()
+	s.quit = make(chan struct{})
+
+	s.wg.Add(1)
+	go s.loop(s.quit)
 
 	log.Info("Stats daemon started")
 	return nil
@@ -128,13 +137,34 @@ func (s *Service) Start(server *p2p.Server) error {
 
 // Stop implements node.Service, terminating the monitoring and reporting daemon.
 func (s *Service) Stop() error {
+	if s.quit != nil {
+		close(s.quit)
+		s.wg.Wait()
+		s.quit = nil
+	}
 	log.Info("Stats daemon stopped")
 	return nil
 }
 
+// Faults implements node.FaultReporter, delivering the crashes of the reporting
+// loop so a supervising node can restart the daemon.
+func (s *Service) Faults() <-chan error {
+	return s.faults
+}
+
 // loop keeps trying to connect to the netstats server, reporting chain events
 // until termination.
-func (s *Service) loop() {
+func (s *Service) loop(quit chan struct{}) {
+	defer s.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("Stats daemon crashed", "err", r)
+			select {
+			case s.faults <- fmt.Errorf("stats reporting crashed: %v", r):
+			default:
+			}
+		}
+	}()
 	// Subscribe to chain events to execute updates on
 	var blockchain blockChain
 	var txpool txPool
@@ -185,11 +215,13 @@ func (s *Service) loop() {
 				default:
 				}
 
-			// node stopped
+			// node or daemon stopped
 			case <-txSub.Err():
 				break HandleLoop
 			case <-headSub.Err():
 				break HandleLoop
+			case <-quit:
+				break HandleLoop
 			}
 		}
 		close(quitCh)
@@ -220,15 +252,23 @@ func (s *Service) loop() {
 		}
 		if err != nil {
 			log.Warn("Stats server unreachable", "err", err)
-			time.Sleep(10 * time.Second)
-			continue
+			select {
+			case <-time.After(10 * time.Second):
+				continue
+			case <-quitCh:
+				return
+			}
 		}
 		// Authenticate the client with the server
 		if err = s.login(conn); err != nil {
 			log.Warn("Stats login failed", "err", err)
 			conn.Close()
-			time.Sleep(10 * time.Second)
-			continue
+			select {
+			case <-time.After(10 * time.Second):
+				continue
+			case <-quitCh:
+				return
+			}
 		}
 		go s.readLoop(conn)
 
@@ -244,6 +284,7 @@ func (s *Service) loop() {
 		for err == nil {
 			select {
 			case <-quitCh:
+				fullReport.Stop()
 				conn.Close()
 				return
 
@@ -269,6 +310,7 @@ func (s *Service) loop() {
 			}
 		}
 		// Make sure the connection is closed
+		fullReport.Stop()
 		conn.Close()
 	}
 }
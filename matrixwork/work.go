@@ -30,7 +30,6 @@ import (
 	"github.com/matrix/go-matrix/core/state"
 	"github.com/matrix/go-matrix/core/types"
 	"github.com/matrix/go-matrix/core/vm"
-	"github.com/matrix/go-matrix/event"
 	"github.com/matrix/go-matrix/log"
 	"github.com/matrix/go-matrix/params"
 )
@@ -74,10 +73,15 @@ func NewWork(config *params.ChainConfig, bc *core.BlockChain, gasPool *core.GasP
 	if err != nil {
 		return nil, err
 	}
+	// Record the balance changes, announced if the block makes it into the chain
+	Work.State.StartBalanceTracking()
+	if bc.StateAccessIndex() != nil {
+		Work.State.StartTouchTracking()
+	}
 	return Work, nil
 }
 
-func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsByPriceAndNonce, bc *core.BlockChain, coinbase common.Address) (listN []uint32, retTxs []*types.Transaction) {
+func (env *Work) commitTransactions(txs *types.TransactionsByPriceAndNonce, bc *core.BlockChain, coinbase common.Address) (listN []uint32, retTxs []*types.Transaction) {
 	if env.gasPool == nil {
 		env.gasPool = new(core.GasPool).AddGas(env.header.GasLimit)
 	}
@@ -163,14 +167,12 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 			cpy[i] = new(types.Log)
 			*cpy[i] = *l
 		}
-		go func(logs []*types.Log, tcount int) {
-			if len(logs) > 0 {
-				mux.Post(core.PendingLogsEvent{Logs: logs})
-			}
-			if tcount > 0 {
-				mux.Post(core.PendingStateEvent{})
-			}
-		}(cpy, env.tcount)
+		if len(cpy) > 0 {
+			bc.PostPendingLogs(cpy)
+		}
+		if env.tcount > 0 {
+			bc.PostPendingState()
+		}
 	}
 	return listN, retTxs
 }
@@ -198,7 +200,7 @@ type retStruct struct {
 	txs []*types.Transaction
 }
 
-func (self *Work) ProcessTransactions(mux *event.TypeMux, tp *core.TxPool, bc *core.BlockChain) ([]uint32, []*types.Transaction) {
+func (self *Work) ProcessTransactions(tp *core.TxPool, bc *core.BlockChain) ([]uint32, []*types.Transaction) {
 
 	//ret := make(chan *retStruct, 1)
 	//tm := time.NewTimer(time.Second * 5)
@@ -214,7 +216,7 @@ func (self *Work) ProcessTransactions(mux *event.TypeMux, tp *core.TxPool, bc *c
 	//	log.INFO("===========", "ProcessTransactions:pending:", len(pending))
 	//	txs := types.NewTransactionsByPriceAndNonce(self.signer, pending)
 	//	log.INFO("===========", "ProcessTransactions:txs:", txs)
-	//	a, b := self.commitTransactions(mux, txs, bc, common.Address{})
+	//	a, b := self.commitTransactions(txs, bc, common.Address{})
 	//	ret1 <- &retStruct{a, b}
 	//}(ret)
 	//select {
@@ -234,12 +236,27 @@ func (self *Work) ProcessTransactions(mux *event.TypeMux, tp *core.TxPool, bc *c
 	log.INFO("===========", "ProcessTransactions:pending:", len(pending))
 	txs := types.NewTransactionsByPriceAndNonce(self.signer, pending)
 	//log.INFO("===========", "ProcessTransactions:txs:", txs)
-	return self.commitTransactions(mux, txs, bc, common.Address{})
+	return self.commitTransactions(txs, bc, common.Address{})
+}
+
+// ProcessPendingTransactions executes the pending transactions of the pool in
+// price and nonce order, crediting the fees to the header's coinbase, and
+// returns the ones included. Unlike ProcessTransactions it does not require the
+// consensus numbers assigned during the transaction flood, so it suits chains
+// sealed locally such as the developer chain.
+func (self *Work) ProcessPendingTransactions(tp *core.TxPool, bc *core.BlockChain) []*types.Transaction {
+	pending, err := tp.Pending()
+	if err != nil {
+		log.Error("Failed to fetch pending transactions", "err", err)
+		return nil
+	}
+	self.commitTransactions(types.NewTransactionsByPriceAndNonce(self.signer, pending), bc, self.header.Coinbase)
+	return self.txs
 }
 
 /*//==============================================================================//
 //Leader
-func (self *Work) ProcessTransactions(mux *event.TypeMux, tp *core.TxPool, bc *core.BlockChain) ([]uint32, []*types.Transaction) {
+func (self *Work) ProcessTransactions(tp *core.TxPool, bc *core.BlockChain) ([]uint32, []*types.Transaction) {
 	pending, err := tp.Pending()
 	if err != nil {
 		log.Error("Failed to fetch pending transactions", "err", err)
@@ -248,12 +265,12 @@ func (self *Work) ProcessTransactions(mux *event.TypeMux, tp *core.TxPool, bc *c
 	log.INFO("===========", "ProcessTransactions:pending:", len(pending))
 	txs := types.NewTransactionsByPriceAndNonce(self.signer, pending)
 	log.INFO("===========", "ProcessTransactions:txs:", txs)
-	return self.commitTransactions(mux, txs, bc, common.Address{})
+	return self.commitTransactions(txs, bc, common.Address{})
 
 }*/
 
 //Broadcast
-func (self *Work) ProcessBroadcastTransactions(mux *event.TypeMux, txs []*types.Transaction, bc *core.BlockChain) {
+func (self *Work) ProcessBroadcastTransactions(txs []*types.Transaction, bc *core.BlockChain) {
 
 	for _, tx := range txs {
 		//log.INFO("========","ProcessBroadcastTransactions:tx",tx)
@@ -264,29 +281,31 @@ func (self *Work) ProcessBroadcastTransactions(mux *event.TypeMux, txs []*types.
 	return
 }
 
-func (env *Work) ConsensusTransactions(mux *event.TypeMux, txs []*types.Transaction, bc *core.BlockChain) error {
+func (env *Work) ConsensusTransactions(txs []*types.Transaction, bc *core.BlockChain) error {
 	if env.gasPool == nil {
 		env.gasPool = new(core.GasPool).AddGas(env.header.GasLimit)
 	}
 	var coalescedLogs []*types.Log
 
-	for _, tx := range txs {
-		// If we don't have enough gas for any further transactions then we're done
-		if env.gasPool.Gas() < params.TxGas {
-			log.Trace("Not enough gas for further transactions", "have", env.gasPool, "want", params.TxGas)
-			return errors.New("Not enough gas for further transactions")
-		}
-
-		// Start executing the transaction
-		env.State.Prepare(tx.Hash(), common.Hash{}, env.tcount)
-		err, logs := env.commitTransaction(tx, bc, common.Address{}, env.gasPool)
-		if err == nil {
-			//log.Info("=========","ConsensusTransactions:tx.N",tx.N)
-			env.tcount++
-			coalescedLogs = append(coalescedLogs, logs...)
-		} else {
-			return err
-		}
+	// If we don't have enough gas for the transactions then we're done
+	if len(txs) > 0 && env.gasPool.Gas() < params.TxGas {
+		log.Trace("Not enough gas for further transactions", "have", env.gasPool, "want", params.TxGas)
+		return errors.New("Not enough gas for further transactions")
+	}
+	// Execute the whole list at once, in parallel if the chain is configured so
+	coinbase := common.Address{}
+	cfg := vm.Config{ParallelTxs: bc.GetVMConfig().ParallelTxs}
+	receipts, err := core.ApplyTransactions(env.config, bc, &coinbase, env.gasPool, env.State, env.header, common.Hash{}, txs, env.tcount, &env.header.GasUsed, cfg)
+
+	// Record the transactions applied before any failure, they are in the state
+	for i, receipt := range receipts {
+		env.txs = append(env.txs, txs[i])
+		env.Receipts = append(env.Receipts, receipt)
+		env.tcount++
+		coalescedLogs = append(coalescedLogs, receipt.Logs...)
+	}
+	if err != nil {
+		return err
 	}
 
 	if len(coalescedLogs) > 0 || env.tcount > 0 {
@@ -298,14 +317,12 @@ func (env *Work) ConsensusTransactions(mux *event.TypeMux, txs []*types.Transact
 			cpy[i] = new(types.Log)
 			*cpy[i] = *l
 		}
-		go func(logs []*types.Log, tcount int) {
-			if len(logs) > 0 {
-				mux.Post(core.PendingLogsEvent{Logs: logs})
-			}
-			if tcount > 0 {
-				mux.Post(core.PendingStateEvent{})
-			}
-		}(cpy, env.tcount)
+		if len(cpy) > 0 {
+			bc.PostPendingLogs(cpy)
+		}
+		if env.tcount > 0 {
+			bc.PostPendingState()
+		}
 	}
 
 	return nil
@@ -0,0 +1,142 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package matrixwork
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/consensus/manash"
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/core/vm"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/mandb"
+	"github.com/matrix/go-matrix/params"
+)
+
+// newTestWork creates a chain funding the given keys and a work environment on
+// top of its genesis, executing transactions with the given parallelism.
+func newTestWork(t *testing.T, keys []*ecdsa.PrivateKey, parallel int) (*Work, *core.BlockChain) {
+	alloc := make(core.GenesisAlloc)
+	for _, key := range keys {
+		alloc[crypto.PubkeyToAddress(key.PublicKey)] = core.GenesisAccount{Balance: big.NewInt(1000000000)}
+	}
+	db := mandb.NewMemDatabase()
+	gspec := &core.Genesis{Config: params.TestChainConfig, GasLimit: 10000000, Alloc: alloc}
+	genesis := gspec.MustCommit(db)
+
+	bc, err := core.NewBlockChain(db, nil, gspec.Config, manash.NewFaker(), vm.Config{ParallelTxs: parallel})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	header := &types.Header{
+		ParentHash: genesis.Hash(),
+		Number:     big.NewInt(1),
+		GasLimit:   genesis.GasLimit(),
+		Difficulty: big.NewInt(1),
+		Time:       big.NewInt(1),
+	}
+	work, err := NewWork(gspec.Config, bc, nil, header)
+	if err != nil {
+		t.Fatalf("failed to create work: %v", err)
+	}
+	return work, bc
+}
+
+// testConsensusTxs signs a batch of transfers between the keys, several of them
+// depending on each other so that parallel execution has conflicts to resolve.
+// If badAt is not negative, the transaction at that position has a nonce gap.
+func testConsensusTxs(t *testing.T, keys []*ecdsa.PrivateKey, count, badAt int) []*types.Transaction {
+	signer := types.NewEIP155Signer(params.TestChainConfig.ChainId)
+	nonces := make([]uint64, len(keys))
+	for i := range nonces {
+		nonces[i] = params.NonceAddOne // as stored by the state database
+	}
+
+	txs := make([]*types.Transaction, count)
+	for i := range txs {
+		from, to := i%len(keys), (i+1)%len(keys)
+		nonce := nonces[from]
+		if i == badAt {
+			nonce += 10
+		}
+		nonces[from]++
+
+		tx := types.NewTransaction(nonce, crypto.PubkeyToAddress(keys[to].PublicKey), big.NewInt(int64(i+1)), params.TxGas, big.NewInt(1), nil)
+		signed, err := types.SignTx(tx, signer, keys[from])
+		if err != nil {
+			t.Fatalf("failed to sign transaction %d: %v", i, err)
+		}
+		txs[i] = signed
+	}
+	return txs
+}
+
+// Tests that executing consensus transactions in parallel leaves the work in
+// the same state as executing them serially, also when a transaction fails.
+func TestConsensusTransactionsParallel(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 4)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	tests := []struct {
+		badAt   int
+		applied int
+	}{
+		{badAt: -1, applied: 24},
+		{badAt: 13, applied: 13},
+	}
+	for i, tt := range tests {
+		txs := testConsensusTxs(t, keys, 24, tt.badAt)
+
+		var (
+			roots    []common.Hash
+			receipts []common.Hash
+			works    []*Work
+		)
+		for _, parallel := range []int{0, 4} {
+			work, bc := newTestWork(t, keys, parallel)
+			err := work.ConsensusTransactions(txs, bc)
+			if (err != nil) != (tt.badAt >= 0) {
+				t.Fatalf("test %d, parallel %d: error mismatch: have %v, want failure %v", i, parallel, err, tt.badAt >= 0)
+			}
+			if len(work.txs) != tt.applied || len(work.Receipts) != tt.applied || work.tcount != tt.applied {
+				t.Fatalf("test %d, parallel %d: applied mismatch: txs %d, receipts %d, count %d, want %d", i, parallel, len(work.txs), len(work.Receipts), work.tcount, tt.applied)
+			}
+			roots = append(roots, work.State.IntermediateRoot(true))
+			receipts = append(receipts, types.DeriveSha(types.Receipts(work.Receipts)))
+			works = append(works, work)
+			bc.Stop()
+		}
+		if roots[0] != roots[1] {
+			t.Errorf("test %d: state root mismatch: serial %x, parallel %x", i, roots[0], roots[1])
+		}
+		if receipts[0] != receipts[1] {
+			t.Errorf("test %d: receipt root mismatch: serial %x, parallel %x", i, receipts[0], receipts[1])
+		}
+		if works[0].header.GasUsed != works[1].header.GasUsed {
+			t.Errorf("test %d: gas used mismatch: serial %d, parallel %d", i, works[0].header.GasUsed, works[1].header.GasUsed)
+		}
+		for j := range works[0].txs {
+			if works[0].txs[j].Hash() != works[1].txs[j].Hash() {
+				t.Errorf("test %d: transaction %d mismatch: serial %x, parallel %x", i, j, works[0].txs[j].Hash(), works[1].txs[j].Hash())
+			}
+		}
+	}
+}
@@ -28,7 +28,6 @@ import (
 	"github.com/matrix/go-matrix/core"
 	"github.com/matrix/go-matrix/core/state"
 	"github.com/matrix/go-matrix/core/types"
-	"github.com/matrix/go-matrix/event"
 	"github.com/matrix/go-matrix/hd"
 	"github.com/matrix/go-matrix/log"
 	"github.com/matrix/go-matrix/mc"
@@ -51,8 +50,6 @@ var (
 
 // Miner creates blocks and searches for proof-of-work values.
 type Miner struct {
-	mux *event.TypeMux
-
 	worker *worker
 
 	coinbase common.Address
@@ -71,9 +68,8 @@ type Miner struct {
 
 func (s *Miner) Getworker() *worker { return s.worker }
 
-func New(bc *core.BlockChain, config *params.ChainConfig, mux *event.TypeMux, engine consensus.Engine, dposEngine consensus.DPOSEngine, hd *hd.HD, ca *ca.Identity) (*Miner, error) {
+func New(bc *core.BlockChain, config *params.ChainConfig, engine consensus.Engine, dposEngine consensus.DPOSEngine, hd *hd.HD, ca *ca.Identity) (*Miner, error) {
 	miner := &Miner{
-		mux:    mux,
 		engine: engine,
 
 		canStart:    1,
@@ -82,61 +78,17 @@ func New(bc *core.BlockChain, config *params.ChainConfig, mux *event.TypeMux, en
 		ca:          ca,
 	}
 	var err error
-	miner.worker, err = newWorker(config, engine, dposEngine, common.Address{}, mux, hd, ca)
+	miner.worker, err = newWorker(config, engine, dposEngine, common.Address{}, hd, ca)
 	if err != nil {
 		log.DEBUG(ModuleMiner, "创建work失败")
 		return miner, err
 	}
 	miner.Register(NewCpuAgent(bc, engine))
-	//go miner.update()
 	log.DEBUG(ModuleMiner, "创建miner成功")
 	log.INFO(ModuleMiner, "�󹤷��񴴽��ɹ�", nil)
 	return miner, nil
 }
 
-/*
-func (self *Miner) downloadStartEventHandler() {
-	atomic.StoreInt32(&self.canStart, 0)
-	if self.Mining() {
-		self.Stop()
-		atomic.StoreInt32(&self.shouldStart, 1)
-		log.Info("Mining aborted due to sync")
-	}
-
-}
-
-func (self *Miner) downloadDoneEventHandler() {
-	shouldStart := atomic.LoadInt32(&self.shouldStart) == 1
-
-	atomic.StoreInt32(&self.canStart, 1)
-	atomic.StoreInt32(&self.shouldStart, 0)
-	if shouldStart {
-		self.Start(self.coinbase)
-	}
-
-}
-
-// update keeps track of the downloader events. Please be aware that this is a one shot type of update loop.
-// It's entered once and as soon as `Done` or `Failed` has been broadcasted the events are unregistered and
-// the loop is exited. This to prevent a major security vuln where external parties can DOS you with blocks
-// and halt your mining operation for as long as the DOS continues.
-func (self *Miner) update() {
-	events := self.mux.Subscribe(downloader.StartEvent{}, downloader.DoneEvent{}, downloader.FailedEvent{})
-out:
-	for ev := range events.Chan() {
-		switch ev.Data.(type) {
-		case downloader.StartEvent:
-			self.downloadStartEventHandler()
-		case downloader.DoneEvent, downloader.FailedEvent:
-			self.downloadDoneEventHandler()
-			// unsubscribe. we're only interested in this event once
-			events.Unsubscribe()
-			// stop immediately and ignore all further pending events
-			break out
-		}
-	}
-}*/
-
 //Start
 func (self *Miner) Start(coinbase common.Address) {
 	atomic.StoreInt32(&self.shouldStart, 1)
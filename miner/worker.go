@@ -33,6 +33,7 @@ import (
 	"github.com/matrix/go-matrix/log"
 	"github.com/matrix/go-matrix/mc"
 	"github.com/matrix/go-matrix/params"
+	"github.com/matrix/go-matrix/watchdog"
 	"gopkg.in/fatih/set.v0"
 )
 
@@ -87,9 +88,6 @@ type worker struct {
 
 	mu sync.Mutex
 
-	// update loop
-	mux *event.TypeMux
-
 	wg sync.WaitGroup
 
 	agents map[Agent]struct{}
@@ -130,11 +128,10 @@ type worker struct {
 	ca         *ca.Identity
 }
 
-func newWorker(config *params.ChainConfig, engine consensus.Engine, dposEngine consensus.DPOSEngine, coinbase common.Address, mux *event.TypeMux, hd *hd.HD, ca *ca.Identity) (*worker, error) {
+func newWorker(config *params.ChainConfig, engine consensus.Engine, dposEngine consensus.DPOSEngine, coinbase common.Address, hd *hd.HD, ca *ca.Identity) (*worker, error) {
 	worker := &worker{
 		config: config,
 		engine: engine,
-		mux:    mux,
 
 		miningRequestCh:      make(chan *mc.HD_MiningReqMsg, 100),
 		roleUpdateCh:         make(chan *mc.RoleUpdatedMsg, 100),
@@ -200,6 +197,12 @@ func (self *worker) init_SubscribeEvent() error {
 func (self *worker) Getmining() int32 { return atomic.LoadInt32(&self.mining) }
 
 func (self *worker) update() {
+	heart := watchdog.Register("miner")
+	defer heart.Idle()
+
+	heartbeat := time.NewTicker(watchdog.BeatInterval)
+	defer heartbeat.Stop()
+
 	defer func() {
 		if self.localMiningRequestSub != nil {
 			self.localMiningRequestSub.Unsubscribe()
@@ -227,6 +230,9 @@ func (self *worker) update() {
 			self.minningReq = *data.BlockMainData
 			self.BroadcastHashLocalMiningReqMsgHandle(data.BlockMainData.Header)
 
+		case <-heartbeat.C:
+			heart.Beat()
+
 		case <-self.localMiningRequestSub.Err():
 			return
 		case <-self.miningRequestSub.Err():
@@ -18,15 +18,19 @@ package node
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/matrix/go-matrix/ca"
+	"github.com/matrix/go-matrix/common"
 	"github.com/matrix/go-matrix/common/hexutil"
 	"github.com/matrix/go-matrix/crypto"
 	"github.com/matrix/go-matrix/metrics"
 	"github.com/matrix/go-matrix/p2p"
 	"github.com/matrix/go-matrix/p2p/discover"
+	"github.com/matrix/go-matrix/params"
 	"github.com/matrix/go-matrix/rpc"
 )
 
@@ -75,6 +79,31 @@ func (api *PrivateAdminAPI) RemovePeer(url string) (bool, error) {
 	return true, nil
 }
 
+// DisableProtocol stops offering a subprotocol version to new peers and drops
+// the peers currently running it.
+func (api *PrivateAdminAPI) DisableProtocol(name string, version uint) (bool, error) {
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	if err := server.DisableProtocol(name, version); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// EnableProtocol offers a previously disabled subprotocol version to new peers.
+func (api *PrivateAdminAPI) EnableProtocol(name string, version uint) (bool, error) {
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	if err := server.EnableProtocol(name, version); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // PeerEvents creates an RPC subscription which receives peer events from the
 // node's p2p.Server
 func (api *PrivateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
@@ -229,6 +258,86 @@ func (api *PrivateAdminAPI) StopWS() (bool, error) {
 	return true, nil
 }
 
+// AddAPIKey admits a new API key to the HTTP and websocket endpoints, replacing
+// any key with the same name, and persists it to the key file.
+func (api *PrivateAdminAPI) AddAPIKey(key rpc.APIKey) (bool, error) {
+	api.node.lock.Lock()
+	defer api.node.lock.Unlock()
+
+	if api.node.apiKeyGate == nil {
+		return false, errAPIKeysDisabled
+	}
+	if err := api.node.apiKeyGate.Add(key); err != nil {
+		return false, err
+	}
+	return true, api.node.saveAPIKeys()
+}
+
+// RemoveAPIKey revokes the API key with the given name.
+func (api *PrivateAdminAPI) RemoveAPIKey(name string) (bool, error) {
+	api.node.lock.Lock()
+	defer api.node.lock.Unlock()
+
+	if api.node.apiKeyGate == nil {
+		return false, errAPIKeysDisabled
+	}
+	if !api.node.apiKeyGate.Remove(name) {
+		return false, fmt.Errorf("unknown API key %q", name)
+	}
+	return true, api.node.saveAPIKeys()
+}
+
+// APIKeys reports the usage counters of the admitted API keys.
+func (api *PrivateAdminAPI) APIKeys() ([]rpc.APIKeyUsage, error) {
+	api.node.lock.RLock()
+	defer api.node.lock.RUnlock()
+
+	if api.node.apiKeyGate == nil {
+		return nil, errAPIKeysDisabled
+	}
+	return api.node.apiKeyGate.Usage(), nil
+}
+
+// Services reports the state of the registered services, along with the restart
+// history of the supervised ones.
+func (api *PrivateAdminAPI) Services() ([]ServiceStatus, error) {
+	api.node.lock.RLock()
+	defer api.node.lock.RUnlock()
+
+	if api.node.supervisor == nil {
+		return nil, ErrNodeStopped
+	}
+	return api.node.supervisor.status(), nil
+}
+
+// Topology reports the distribution of the connected peers over countries,
+// autonomous systems and latencies.
+func (api *PrivateAdminAPI) Topology() (*Topology, error) {
+	return api.node.Topology()
+}
+
+// RotateNodeKey replaces the node key, restarting the p2p server under the new
+// identity. Elected and broadcast nodes are registered by their node id, so the
+// rotation is refused for them unless forced.
+func (api *PrivateAdminAPI) RotateNodeKey(force *bool) (*NodeKeyRotation, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	if force == nil || !*force {
+		if role := ca.GetRole(); role > common.RoleBucket {
+			return nil, fmt.Errorf("node has elected role %v, rotating the key drops it", role)
+		}
+		self := server.Self().ID
+		for _, b := range params.BroadCastNodes {
+			if b.NodeID == self {
+				return nil, errors.New("node is a broadcast node, rotating the key drops it")
+			}
+		}
+	}
+	return api.node.RotateNodeKey()
+}
+
 // PublicAdminAPI is the collection of administrative API methods exposed over
 // both secure and unsecure RPC channels.
 type PublicAdminAPI struct {
@@ -261,6 +370,16 @@ func (api *PublicAdminAPI) NodeInfo() (*p2p.NodeInfo, error) {
 	return server.NodeInfo(), nil
 }
 
+// ProtocolInfo retrieves the supported subprotocol versions and the versions
+// negotiated with each connected peer.
+func (api *PublicAdminAPI) ProtocolInfo() ([]*p2p.ProtocolInfo, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.ProtocolInfos(), nil
+}
+
 // Datadir retrieves the current data directory the node is using.
 func (api *PublicAdminAPI) Datadir() string {
 	return api.node.DataDir()
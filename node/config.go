@@ -24,6 +24,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/matrix/go-matrix/accounts"
 	"github.com/matrix/go-matrix/accounts/keystore"
@@ -41,6 +42,8 @@ const (
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+	datadirAdminAuditLog   = "admin-audit.log"    // Path within the datadir to the audit trail of guarded methods
+	datadirAttestKey       = "attestkey"          // Path within the datadir to the RPC attestation key
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -78,6 +81,12 @@ type Config struct {
 	// is created by New and destroyed when the node is stopped.
 	KeyStoreDir string `toml:",omitempty"`
 
+	// ChainDataDir is the file system folder holding the chain databases, so that
+	// they can be placed on another filesystem than the rest of DataDir. Relative
+	// paths are resolved against the current directory. If empty, the databases
+	// are kept in the instance directory inside DataDir.
+	ChainDataDir string `toml:",omitempty"`
+
 	// UseLightweightKDF lowers the memory and CPU requirements of the key store
 	// scrypt KDF at the expense of security.
 	UseLightweightKDF bool `toml:",omitempty"`
@@ -89,8 +98,17 @@ type Config struct {
 	// a simple file name, it is placed inside the data directory (or on the root
 	// pipe path on Windows), whereas if it's a resolvable path name (absolute or
 	// relative), then that specific path is enforced. An empty path disables IPC.
+	// On Linux, a name starting with '@' denotes an abstract socket, which only
+	// accepts the connections of processes running as the same user as the node.
 	IPCPath string `toml:",omitempty"`
 
+	// IPCMode is the file mode of the IPC socket (0 = owner only, 0600).
+	IPCMode os.FileMode `toml:",omitempty"`
+
+	// IPCGroup is the group (name or numeric id) owning the IPC socket. Together
+	// with IPCMode it grants other users on the machine access to the node.
+	IPCGroup string `toml:",omitempty"`
+
 	// HTTPHost is the host interface on which to start the HTTP RPC server. If this
 	// field is empty, no HTTP API endpoint will be started.
 	HTTPHost string `toml:",omitempty"`
@@ -119,6 +137,42 @@ type Config struct {
 	// exposed.
 	HTTPModules []string `toml:",omitempty"`
 
+	// RPCCacheSize is the number of replies kept by the response cache shared by
+	// all RPC endpoints. Zero disables the cache; services decide which of their
+	// methods are cacheable.
+	RPCCacheSize int `toml:",omitempty"`
+
+	// RPCAttestMethods is the list of RPC methods whose replies are signed with
+	// the attestation key, so light clients and aggregators can tell which node
+	// vouched for a result. Empty disables attestation.
+	RPCAttestMethods []string `toml:",omitempty"`
+
+	// RPCListeners are additional HTTP and websocket RPC listeners, e.g. on a VPN
+	// interface or an IPv6 address next to the primary endpoints.
+	RPCListeners []ListenerConfig `toml:",omitempty"`
+
+	// RPCAPIKeys is the JSON file listing the API keys clients of the HTTP and
+	// websocket endpoints must present. Empty leaves the endpoints open. Keys
+	// added or revoked through the admin API are written back to the file.
+	RPCAPIKeys string `toml:",omitempty"`
+
+	// AdminOperators are the addresses of the operator keys approving requests of
+	// the AdminGuardedMethods. Empty leaves these methods unguarded.
+	AdminOperators []common.Address `toml:",omitempty"`
+
+	// AdminThreshold is the number of distinct operators whose signatures a
+	// request of a guarded method needs.
+	AdminThreshold int `toml:",omitempty"`
+
+	// AdminGuardedMethods lists the methods requiring operator approval if
+	// AdminOperators are configured, DefaultAdminGuardedMethods if empty.
+	AdminGuardedMethods []string `toml:",omitempty"`
+
+	// AdminAuditLog is the file the approved and refused requests of guarded
+	// methods are appended to, admin-audit.log in the data directory if empty.
+	// The approvals it records can't be replayed after a restart.
+	AdminAuditLog string `toml:",omitempty"`
+
 	// WSHost is the host interface on which to start the websocket RPC server. If
 	// this field is empty, no websocket API endpoint will be started.
 	WSHost string `toml:",omitempty"`
@@ -145,10 +199,47 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// SupervisedServices lists the services (by type name, e.g. "manstats.Service"
+	// or "whisperv6.Whisper") whose failures don't bring the node down. A failed
+	// start is retried with backoff, and services reporting runtime faults are
+	// restarted in place.
+	SupervisedServices []string `toml:",omitempty"`
+
+	// ServiceRestartBackoff is the delay before the first restart attempt of a
+	// failed supervised service, doubling on every consecutive failure up to
+	// ServiceRestartMaxBackoff. Zero values pick the defaults.
+	ServiceRestartBackoff    time.Duration `toml:",omitempty"`
+	ServiceRestartMaxBackoff time.Duration `toml:",omitempty"`
+
+	// ServiceRestartLimit is the number of restarts after which a supervised
+	// service is left failed. Zero means no limit.
+	ServiceRestartLimit int `toml:",omitempty"`
+
+	// GeoIPDatabases lists MaxMind DB files (e.g. GeoLite2-Country and
+	// GeoLite2-ASN) locating the connected peers for admin_topology.
+	GeoIPDatabases []string `toml:",omitempty"`
+
+	// Plugins lists Go plugins (built with -buildmode=plugin against the same
+	// source tree) to load at startup. Each exports a Service constructor, which
+	// is registered after the built-in services and may contribute additional
+	// devp2p subprotocols and RPC namespaces. Loading plugins requires a cgo
+	// build on Linux, macOS or FreeBSD.
+	Plugins []string `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 }
 
+// ListenerConfig describes an additional RPC listener. Unset restrictions
+// default to those of the primary endpoint of the same protocol.
+type ListenerConfig struct {
+	Protocol     string   // Either "http" or "ws"
+	Endpoint     string   // Interface and port to listen on (e.g. "10.8.0.1:8545")
+	Modules      []string `toml:",omitempty"` // API modules exposed on the listener
+	Origins      []string `toml:",omitempty"` // CORS domains (http) or accepted origins (ws)
+	VirtualHosts []string `toml:",omitempty"` // Accepted virtual hostnames (http only)
+}
+
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
 // account the set data folders as well as the designated platform we're currently
 // running on.
@@ -164,6 +255,10 @@ func (c *Config) IPCEndpoint() string {
 		}
 		return `\\.\pipe\` + c.IPCPath
 	}
+	// Abstract sockets have no place in the filesystem
+	if runtime.GOOS == "linux" && strings.HasPrefix(c.IPCPath, "@") {
+		return c.IPCPath
+	}
 	// Resolve names into the data directory full paths otherwise
 	if filepath.Base(c.IPCPath) == c.IPCPath {
 		if c.DataDir == "" {
@@ -253,6 +348,12 @@ func (c *Config) name() string {
 	return c.Name
 }
 
+// These databases are placed in ChainDataDir if configured.
+var isChainDatabase = map[string]bool{
+	"chaindata":      true,
+	"lightchaindata": true,
+}
+
 // These resources are resolved differently for "gman" instances.
 var isOldGmanResource = map[string]bool{
 	"chaindata":          true,
@@ -262,7 +363,14 @@ var isOldGmanResource = map[string]bool{
 	"trusted-nodes.json": true,
 }
 
-// resolvePath resolves path in the instance directory.
+// ResolvePath resolves path in the instance directory without requiring the
+// protocol stack to be created.
+func (c *Config) ResolvePath(path string) string {
+	return c.resolvePath(path)
+}
+
+// resolvePath resolves path in the instance directory, or in ChainDataDir for
+// the chain databases.
 func (c *Config) resolvePath(path string) string {
 	if filepath.IsAbs(path) {
 		return path
@@ -270,6 +378,15 @@ func (c *Config) resolvePath(path string) string {
 	if c.DataDir == "" {
 		return ""
 	}
+	if c.ChainDataDir != "" && isChainDatabase[path] {
+		return filepath.Join(c.ChainDataDir, path)
+	}
+	return c.defaultPath(path)
+}
+
+// defaultPath resolves the relative path in the instance directory, ignoring
+// ChainDataDir.
+func (c *Config) defaultPath(path string) string {
 	// Backwards-compatibility: ensure that data directory files created
 	// by gman 1.4 are used if they exist.
 	if c.name() == "gman" && isOldGmanResource[path] {
@@ -330,6 +447,35 @@ func (c *Config) NodeKey() *ecdsa.PrivateKey {
 	return key
 }
 
+// AttestKey retrieves the key RPC replies are attested with. It is kept apart
+// from the node key in the data directory, generating and storing a new one if
+// none exists yet, or is ephemeral if no datadir is being used.
+func (c *Config) AttestKey() *ecdsa.PrivateKey {
+	if c.DataDir == "" {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			log.Crit(fmt.Sprintf("Failed to generate ephemeral attestation key: %v", err))
+		}
+		return key
+	}
+	keyfile := c.resolvePath(datadirAttestKey)
+	if key, err := crypto.LoadECDSA(keyfile); err == nil {
+		return key
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		log.Crit(fmt.Sprintf("Failed to generate attestation key: %v", err))
+	}
+	if err := os.MkdirAll(c.instanceDir(), 0700); err != nil {
+		log.Error(fmt.Sprintf("Failed to persist attestation key: %v", err))
+		return key
+	}
+	if err := crypto.SaveECDSA(keyfile, key); err != nil {
+		log.Error(fmt.Sprintf("Failed to persist attestation key: %v", err))
+	}
+	return key
+}
+
 // StaticNodes returns a list of node enode URLs configured as static nodes.
 func (c *Config) StaticNodes() []*discover.Node {
 	return c.parsePersistentNodes(c.resolvePath(datadirStaticNodes))
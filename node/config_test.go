@@ -148,3 +148,24 @@ func TestNodeKeyPersistency(t *testing.T) {
 		t.Fatalf("ephemeral node key persisted to disk")
 	}
 }
+
+// Tests that the attestation key is persisted apart from the node key.
+func TestAttestKeyPersistency(t *testing.T) {
+	dir, err := ioutil.TempDir("", "node-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &Config{Name: "unit-test", DataDir: dir}
+	key := config.AttestKey()
+	if _, err := os.Stat(filepath.Join(dir, "unit-test", datadirAttestKey)); err != nil {
+		t.Fatalf("attestation key not persisted to data directory: %v", err)
+	}
+	if reloaded := config.AttestKey(); !bytes.Equal(crypto.FromECDSA(reloaded), crypto.FromECDSA(key)) {
+		t.Fatalf("persisted attestation key mismatch")
+	}
+	if node := config.NodeKey(); bytes.Equal(crypto.FromECDSA(node), crypto.FromECDSA(key)) {
+		t.Fatalf("attestation key shared with the node key")
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/matrix/go-matrix/p2p"
 	"github.com/matrix/go-matrix/p2p/nat"
@@ -33,6 +34,11 @@ const (
 	DefaultWSPort   = 8546        // Default TCP port for the websocket RPC server
 )
 
+// DefaultAdminGuardedMethods are the methods requiring operator approval if
+// admin operators are configured: rewinding the chain, dropping peers and
+// rotating the node key.
+var DefaultAdminGuardedMethods = []string{"debug_setHead", "admin_removePeer", "admin_rotateNodeKey"}
+
 // DefaultConfig contains reasonable default settings.
 var DefaultConfig = Config{
 	DataDir:          DefaultDataDir(),
@@ -41,6 +47,10 @@ var DefaultConfig = Config{
 	HTTPVirtualHosts: []string{"localhost"},
 	WSPort:           DefaultWSPort,
 	WSModules:        []string{"net", "web3"},
+
+	ServiceRestartBackoff:    time.Second,
+	ServiceRestartMaxBackoff: 5 * time.Minute,
+
 	P2P: p2p.Config{
 		ListenAddr: ":30303",
 		MaxPeers:   10000,
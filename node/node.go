@@ -17,8 +17,12 @@
 package node
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
 	"net"
 	"os"
 	"path/filepath"
@@ -37,6 +41,8 @@ import (
 	"github.com/matrix/go-matrix/internal/debug"
 	"github.com/matrix/go-matrix/log"
 	"github.com/matrix/go-matrix/p2p"
+	"github.com/matrix/go-matrix/p2p/discover"
+	"github.com/matrix/go-matrix/p2p/geoip"
 	"github.com/matrix/go-matrix/rpc"
 	"github.com/prometheus/prometheus/util/flock"
 )
@@ -55,9 +61,15 @@ type Node struct {
 
 	serviceFuncs []ServiceConstructor     // Service constructors (in dependency order)
 	services     map[reflect.Type]Service // Currently running services
+	supervisor   *supervisor              // Supervisor restarting failed services
 
-	rpcAPIs       []rpc.API   // List of APIs currently provided by the node
-	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
+	rpcAPIs       []rpc.API           // List of APIs currently provided by the node
+	rpcCache      *rpc.ResponseCache  // Response cache shared by all RPC endpoints (nil = disabled)
+	apiKeyGate    *rpc.APIKeyGate     // API key gate of the HTTP and websocket endpoints (nil = open)
+	adminQuorum   *rpc.OperatorQuorum // Operator approval of guarded methods on all endpoints (nil = unguarded)
+	adminAudit    *os.File            // Audit trail of the guarded methods
+	geoip         *geoip.DB           // GeoIP databases locating the peers (nil = disabled)
+	inprocHandler *rpc.Server         // In-process RPC request handler to process the API requests
 
 	ipcEndpoint string       // IPC endpoint to listen at (empty = IPC disabled)
 	ipcListener net.Listener // IPC RPC listener socket to serve API requests
@@ -72,6 +84,9 @@ type Node struct {
 	wsListener net.Listener // Websocket RPC listener socket to server API requests
 	wsHandler  *rpc.Server  // Websocket RPC request handler to process the API requests
 
+	extraListeners []net.Listener // Additional HTTP and websocket RPC listener sockets
+	extraHandlers  []*rpc.Server  // RPC request handlers of the additional listeners
+
 	MsgCenter  *mc.Center
 	hd         *hd.HD
 	signHelper *signhelper.SignHelper
@@ -159,6 +174,11 @@ func (n *Node) Start() error {
 	if n.server != nil {
 		return ErrNodeRunning
 	}
+	// Load the plugins first, their services are constructed after the built-in ones
+	plugins, err := n.loadPlugins()
+	if err != nil {
+		return err
+	}
 	if err := n.openDataDir(); err != nil {
 		return err
 	}
@@ -182,9 +202,40 @@ func (n *Node) Start() error {
 	running := p2p.ServerP2p
 	n.log.Info("Starting peer-to-peer node", "instance", n.serverConfig.Name)
 
+	// Load the API keys guarding the public RPC endpoints
+	n.apiKeyGate = nil
+	if n.config.RPCAPIKeys != "" {
+		gate, err := n.openAPIKeyGate()
+		if err != nil {
+			return err
+		}
+		n.apiKeyGate = gate
+	}
+	// Open the GeoIP databases used to report the network topology
+	n.geoip = nil
+	if len(n.config.GeoIPDatabases) > 0 {
+		db, err := geoip.OpenDB(n.config.GeoIPDatabases)
+		if err != nil {
+			return err
+		}
+		n.log.Info("Loaded GeoIP databases", "types", db.Types())
+		n.geoip = db
+	}
+	// Create the RPC response cache before the services, so they can install
+	// their caching policies
+	n.rpcCache = nil
+	if n.config.RPCCacheSize > 0 {
+		cache, err := rpc.NewResponseCache(n.config.RPCCacheSize)
+		if err != nil {
+			return err
+		}
+		n.rpcCache = cache
+	}
+
 	// Otherwise copy and specialize the P2P configuration
 	services := make(map[reflect.Type]Service)
-	for _, constructor := range n.serviceFuncs {
+	constructors := append(append([]ServiceConstructor{}, n.serviceFuncs...), plugins...)
+	for _, constructor := range constructors {
 		// Create a new context for the particular service
 		ctx := &ServiceContext{
 			config:         n.config,
@@ -194,6 +245,7 @@ func (n *Node) Start() error {
 			MsgCenter:      n.MsgCenter,
 			HD:             n.hd,
 			SignHelper:     n.signHelper,
+			RPCCache:       n.rpcCache,
 		}
 		for kind, s := range services { // copy needed for threaded access
 			ctx.services[kind] = s
@@ -209,6 +261,11 @@ func (n *Node) Start() error {
 		}
 		services[kind] = service
 	}
+	// Require operator approval of the guarded admin methods, scoped to the
+	// node and the chain it runs
+	if err := n.openAdminQuorum(serviceChainID(services)); err != nil {
+		return err
+	}
 	// Gather the protocols and start the freshly assembled P2P server
 	for _, service := range services {
 		running.Protocols = append(running.Protocols, service.Protocols()...)
@@ -216,29 +273,24 @@ func (n *Node) Start() error {
 	if err := running.Start(); err != nil {
 		return convertFileLockError(err)
 	}
-	// Start each of the services
-	started := []reflect.Type{}
-	for kind, service := range services {
+	// Start each of the services, supervised ones may fail without aborting
+	supervisor := newSupervisor(n.config, running, services, n.log)
+	for kind := range services {
 		// Start the next service, stopping all previous upon failure
-		if err := service.Start(running); err != nil {
-			for _, kind := range started {
-				services[kind].Stop()
-			}
+		if err := supervisor.start(kind); err != nil {
+			n.stopServices(supervisor, services)
 			running.Stop()
 
 			return err
 		}
-		// Mark the service started for potential cleanup
-		started = append(started, kind)
 	}
 	// Lastly start the configured RPC interfaces
 	if err := n.startRPC(services); err != nil {
-		for _, service := range services {
-			service.Stop()
-		}
+		n.stopServices(supervisor, services)
 		running.Stop()
 		return err
 	}
+	supervisor.watch()
 	//var bc *core.BlockChain
 	//boot := boot.New(bc, n.Server().NodeInfo().ID)
 	//boot.Run()
@@ -247,6 +299,7 @@ func (n *Node) Start() error {
 
 	// Finish initializing the startup
 	n.services = services
+	n.supervisor = supervisor
 	n.server = running
 	n.stop = make(chan struct{})
 
@@ -301,11 +354,41 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 		n.stopInProc()
 		return err
 	}
+	if err := n.startExtraListeners(apis); err != nil {
+		n.stopWS()
+		n.stopHTTP()
+		n.stopIPC()
+		n.stopInProc()
+		return err
+	}
 	// All API endpoints started successfully
+	handlers := n.rpcHandlers()
+	if n.rpcCache != nil {
+		for _, handler := range handlers {
+			if handler != nil {
+				handler.SetCache(n.rpcCache)
+			}
+		}
+	}
+	if len(n.config.RPCAttestMethods) > 0 {
+		signer := rpc.NewResponseSigner(n.config.AttestKey(), serviceChainID(services), n.config.RPCAttestMethods)
+		for _, handler := range handlers {
+			if handler != nil {
+				handler.SetSigner(signer)
+			}
+		}
+		n.log.Info("RPC response attestation enabled", "signer", signer.Address(), "methods", n.config.RPCAttestMethods)
+	}
 	n.rpcAPIs = apis
 	return nil
 }
 
+// rpcHandlers returns the request handlers of all RPC endpoints, nil for the
+// endpoints not running.
+func (n *Node) rpcHandlers() []*rpc.Server {
+	return append([]*rpc.Server{n.inprocHandler, n.ipcHandler, n.httpHandler, n.wsHandler}, n.extraHandlers...)
+}
+
 // startInProc initializes an in-process RPC endpoint.
 func (n *Node) startInProc(apis []rpc.API) error {
 	// Register all the APIs exposed by the services
@@ -316,6 +399,7 @@ func (n *Node) startInProc(apis []rpc.API) error {
 		}
 		n.log.Debug("InProc registered", "service", api.Service, "namespace", api.Namespace)
 	}
+	handler.SetQuorum(n.adminQuorum)
 	n.inprocHandler = handler
 	return nil
 }
@@ -337,6 +421,14 @@ func (n *Node) startIPC(apis []rpc.API) error {
 	if err != nil {
 		return err
 	}
+	if n.config.IPCMode != 0 || n.config.IPCGroup != "" {
+		if err := rpc.SetIPCPermissions(n.ipcEndpoint, n.config.IPCMode, n.config.IPCGroup); err != nil {
+			listener.Close()
+			handler.Stop()
+			return err
+		}
+	}
+	handler.SetQuorum(n.adminQuorum)
 	n.ipcListener = listener
 	n.ipcHandler = handler
 	n.log.Info("IPC endpoint opened", "url", n.ipcEndpoint)
@@ -363,11 +455,12 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts)
+	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts, n.apiKeyGate)
 	if err != nil {
 		return err
 	}
 	n.log.Info("HTTP endpoint opened", "url", fmt.Sprintf("http://%s", endpoint), "cors", strings.Join(cors, ","), "vhosts", strings.Join(vhosts, ","))
+	handler.SetQuorum(n.adminQuorum)
 	// All listeners booted successfully
 	n.httpEndpoint = endpoint
 	n.httpListener = listener
@@ -396,11 +489,12 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartWSEndpoint(endpoint, apis, modules, wsOrigins, exposeAll)
+	listener, handler, err := rpc.StartWSEndpoint(endpoint, apis, modules, wsOrigins, exposeAll, n.apiKeyGate)
 	if err != nil {
 		return err
 	}
 	n.log.Info("WebSocket endpoint opened", "url", fmt.Sprintf("ws://%s", listener.Addr()))
+	handler.SetQuorum(n.adminQuorum)
 	// All listeners booted successfully
 	n.wsEndpoint = endpoint
 	n.wsListener = listener
@@ -423,6 +517,65 @@ func (n *Node) stopWS() {
 	}
 }
 
+// startExtraListeners starts the additional HTTP and websocket RPC listeners.
+// Restrictions a listener leaves unset default to those of the primary endpoint
+// of its protocol.
+func (n *Node) startExtraListeners(apis []rpc.API) error {
+	for _, cfg := range n.config.RPCListeners {
+		var (
+			listener net.Listener
+			handler  *rpc.Server
+			err      error
+		)
+		switch cfg.Protocol {
+		case "http":
+			modules, cors, vhosts := cfg.Modules, cfg.Origins, cfg.VirtualHosts
+			if modules == nil {
+				modules = n.config.HTTPModules
+			}
+			if cors == nil {
+				cors = n.config.HTTPCors
+			}
+			if vhosts == nil {
+				vhosts = n.config.HTTPVirtualHosts
+			}
+			listener, handler, err = rpc.StartHTTPEndpoint(cfg.Endpoint, apis, modules, cors, vhosts, n.apiKeyGate)
+		case "ws":
+			modules, origins := cfg.Modules, cfg.Origins
+			if modules == nil {
+				modules = n.config.WSModules
+			}
+			if origins == nil {
+				origins = n.config.WSOrigins
+			}
+			listener, handler, err = rpc.StartWSEndpoint(cfg.Endpoint, apis, modules, origins, n.config.WSExposeAll && cfg.Modules == nil, n.apiKeyGate)
+		default:
+			err = fmt.Errorf("unknown RPC listener protocol %q", cfg.Protocol)
+		}
+		if err != nil {
+			n.stopExtraListeners()
+			return err
+		}
+		handler.SetQuorum(n.adminQuorum)
+		n.log.Info("Additional RPC endpoint opened", "url", fmt.Sprintf("%s://%s", cfg.Protocol, cfg.Endpoint))
+		n.extraListeners = append(n.extraListeners, listener)
+		n.extraHandlers = append(n.extraHandlers, handler)
+	}
+	return nil
+}
+
+// stopExtraListeners terminates the additional RPC listeners.
+func (n *Node) stopExtraListeners() {
+	for _, listener := range n.extraListeners {
+		listener.Close()
+		n.log.Info("Additional RPC endpoint closed", "addr", listener.Addr())
+	}
+	for _, handler := range n.extraHandlers {
+		handler.Stop()
+	}
+	n.extraListeners, n.extraHandlers = nil, nil
+}
+
 // Stop terminates a running node along with all it's services. In the node was
 // not started, an error is returned.
 func (n *Node) Stop() error {
@@ -435,22 +588,21 @@ func (n *Node) Stop() error {
 	}
 
 	// Terminate the API, services and the p2p server.
+	n.stopExtraListeners()
 	n.stopWS()
 	n.stopHTTP()
 	n.stopIPC()
 	n.rpcAPIs = nil
 	failure := &StopError{
-		Services: make(map[reflect.Type]error),
-	}
-	for kind, service := range n.services {
-		if err := service.Stop(); err != nil {
-			failure.Services[kind] = err
-		}
+		Services: n.stopServices(n.supervisor, n.services),
 	}
 	n.server.Stop()
 	n.services = nil
+	n.supervisor = nil
 	n.server = nil
 
+	n.closeAdminQuorum()
+
 	// stop ca
 	ca.Stop()
 	// Release instance directory lock.
@@ -479,6 +631,23 @@ func (n *Node) Stop() error {
 	return nil
 }
 
+// stopServices halts the supervisor, then stops all the services it considers
+// running, returning the errors of those that failed to stop.
+func (n *Node) stopServices(supervisor *supervisor, services map[reflect.Type]Service) map[reflect.Type]error {
+	supervisor.stop()
+
+	failures := make(map[reflect.Type]error)
+	for kind, service := range services {
+		if !supervisor.running(kind) {
+			continue
+		}
+		if err := service.Stop(); err != nil {
+			failures[kind] = err
+		}
+	}
+	return failures
+}
+
 // Wait blocks the thread until the node is stopped. If the node is not running
 // at the time of invocation, the method immediately returns.
 func (n *Node) Wait() {
@@ -602,9 +771,155 @@ func (n *Node) OpenDatabase(name string, cache, handles int) (mandb.Database, er
 	if n.config.DataDir == "" {
 		return mandb.NewMemDatabase(), nil
 	}
+	if err := n.config.migrateChainData(name); err != nil {
+		return nil, err
+	}
 	return mandb.NewLDBDatabase(n.config.resolvePath(name), cache, handles)
 }
 
+// openAPIKeyGate creates the API key gate of the public RPC endpoints from the
+// configured key file. A missing file yields a gate without any keys.
+func (n *Node) openAPIKeyGate() (*rpc.APIKeyGate, error) {
+	path := n.config.resolvePath(n.config.RPCAPIKeys)
+	keys, err := rpc.LoadAPIKeys(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	n.log.Info("Loaded RPC API keys", "path", path, "keys", len(keys))
+	return rpc.NewAPIKeyGate(keys)
+}
+
+// chainService is implemented by services running a blockchain, whose chain ID
+// scopes the operator approvals of the node.
+type chainService interface {
+	ChainID() *big.Int
+}
+
+// serviceChainID returns the chain ID of the first service running a blockchain,
+// nil if there is none.
+func serviceChainID(services map[reflect.Type]Service) *big.Int {
+	for _, service := range services {
+		if chain, ok := service.(chainService); ok {
+			return chain.ChainID()
+		}
+	}
+	return nil
+}
+
+// openAdminQuorum sets up the operator quorum approving the guarded admin
+// methods of the node on the given chain along with its audit trail, if admin
+// operators are configured. The nonces of approvals recorded in the audit trail
+// stay used until they expire.
+func (n *Node) openAdminQuorum(chainID *big.Int) error {
+	n.adminQuorum, n.adminAudit = nil, nil
+	if len(n.config.AdminOperators) == 0 {
+		return nil
+	}
+	methods := n.config.AdminGuardedMethods
+	if len(methods) == 0 {
+		methods = DefaultAdminGuardedMethods
+	}
+	path := n.config.AdminAuditLog
+	if path == "" {
+		path = datadirAdminAuditLog
+	}
+	var audit io.Writer
+	if path = n.config.resolvePath(path); path != "" {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return err
+		}
+		n.adminAudit, audit = file, file
+	}
+	scope := rpc.AuthorizationScope{
+		ChainID: chainID,
+		Node:    discover.PubkeyID(&n.serverConfig.PrivateKey.PublicKey).String(),
+	}
+	quorum, err := rpc.NewOperatorQuorum(n.config.AdminThreshold, n.config.AdminOperators, methods, scope, audit)
+	if err != nil {
+		n.closeAdminQuorum()
+		return err
+	}
+	if n.adminAudit != nil {
+		if err := quorum.LoadUsed(n.adminAudit); err != nil {
+			n.closeAdminQuorum()
+			return err
+		}
+	}
+	n.adminQuorum = quorum
+	n.log.Info("Guarded admin methods require operator approval", "threshold", n.config.AdminThreshold, "operators", len(n.config.AdminOperators), "methods", methods, "chain", chainID, "node", scope.Node, "audit", path)
+	return nil
+}
+
+// closeAdminQuorum closes the audit trail of the guarded admin methods.
+func (n *Node) closeAdminQuorum() {
+	if n.adminAudit != nil {
+		if err := n.adminAudit.Close(); err != nil {
+			n.log.Error("Failed to close admin audit trail", "err", err)
+		}
+		n.adminAudit = nil
+	}
+}
+
+// saveAPIKeys writes the keys admitted by the API key gate back to the key file.
+func (n *Node) saveAPIKeys() error {
+	blob, err := json.MarshalIndent(n.apiKeyGate.Keys(), "", "  ")
+	if err != nil {
+		return err
+	}
+	path := n.config.resolvePath(n.config.RPCAPIKeys)
+	if err := ioutil.WriteFile(path+".tmp", blob, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// ReloadAPIKeys re-reads the API key file, admitting new and changed keys and
+// revoking the ones no longer listed. Unchanged keys keep their rate limit and
+// usage state. The names of the touched keys are returned.
+func (n *Node) ReloadAPIKeys() (updated, removed []string, err error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.apiKeyGate == nil {
+		return nil, nil, errAPIKeysDisabled
+	}
+	path := n.config.resolvePath(n.config.RPCAPIKeys)
+	keys, err := rpc.LoadAPIKeys(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	// Validate the whole file before touching the live gate
+	if _, err := rpc.NewAPIKeyGate(keys); err != nil {
+		return nil, nil, err
+	}
+	listed := make(map[string]rpc.APIKey)
+	for _, key := range keys {
+		listed[key.Name] = key
+	}
+	// Revoke the dropped keys first, their secrets may have been reassigned
+	for _, key := range n.apiKeyGate.Keys() {
+		if _, ok := listed[key.Name]; !ok {
+			n.apiKeyGate.Remove(key.Name)
+			removed = append(removed, key.Name)
+			continue
+		}
+		if reflect.DeepEqual(listed[key.Name], key) {
+			delete(listed, key.Name)
+		}
+	}
+	for _, key := range keys {
+		if _, ok := listed[key.Name]; !ok {
+			continue
+		}
+		if err := n.apiKeyGate.Add(key); err != nil {
+			return updated, removed, err
+		}
+		updated = append(updated, key.Name)
+	}
+	return updated, removed, nil
+}
+
 // ResolvePath returns the absolute path of a resource in the instance directory.
 func (n *Node) ResolvePath(x string) string {
 	return n.config.resolvePath(x)
@@ -17,15 +17,22 @@
 package node
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"math/big"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
 	"github.com/matrix/go-matrix/crypto"
 	"github.com/matrix/go-matrix/p2p"
+	"github.com/matrix/go-matrix/p2p/discover"
 	"github.com/matrix/go-matrix/rpc"
 )
 
@@ -573,3 +580,173 @@ func TestAPIGather(t *testing.T) {
 		}
 	}
 }
+
+// Tests that reloading the API key file syncs the gate of a running node,
+// leaving unchanged keys alone.
+func TestNodeReloadAPIKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyfile := filepath.Join(dir, "apikeys.json")
+
+	stack, err := New(&Config{DataDir: dir, RPCAPIKeys: keyfile})
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if _, _, err := stack.ReloadAPIKeys(); err != errAPIKeysDisabled {
+		t.Fatalf("reload failure mismatch: have %v, want %v", err, errAPIKeysDisabled)
+	}
+	writeKeys := func(keys []rpc.APIKey) {
+		blob, _ := json.Marshal(keys)
+		if err := ioutil.WriteFile(keyfile, blob, 0600); err != nil {
+			t.Fatalf("failed to write key file: %v", err)
+		}
+	}
+	writeKeys([]rpc.APIKey{
+		{Name: "alice", Key: "secret-a", RateLimit: 10},
+		{Name: "bob", Key: "secret-b"},
+	})
+	if stack.apiKeyGate, err = stack.openAPIKeyGate(); err != nil {
+		t.Fatalf("failed to open key gate: %v", err)
+	}
+	// Change one key, drop another and add a third one
+	writeKeys([]rpc.APIKey{
+		{Name: "alice", Key: "secret-a", RateLimit: 20},
+		{Name: "carol", Key: "secret-b"},
+	})
+	updated, removed, err := stack.ReloadAPIKeys()
+	if err != nil {
+		t.Fatalf("failed to reload keys: %v", err)
+	}
+	if want := []string{"alice", "carol"}; !reflect.DeepEqual(updated, want) {
+		t.Errorf("updated keys mismatch: have %v, want %v", updated, want)
+	}
+	if want := []string{"bob"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed keys mismatch: have %v, want %v", removed, want)
+	}
+	// Reloading the same file again should be a noop
+	if updated, removed, err = stack.ReloadAPIKeys(); err != nil || len(updated)+len(removed) != 0 {
+		t.Errorf("repeated reload mismatch: updated %v, removed %v, err %v", updated, removed, err)
+	}
+	// Invalid files must leave the gate intact
+	writeKeys([]rpc.APIKey{{Name: "dave", Key: "secret-a"}, {Name: "erin", Key: "secret-a"}})
+	if _, _, err := stack.ReloadAPIKeys(); err == nil {
+		t.Fatalf("invalid key file accepted")
+	}
+	if keys := stack.apiKeyGate.Keys(); len(keys) != 2 || keys[0].RateLimit != 20 {
+		t.Errorf("key gate modified by invalid file: %v", keys)
+	}
+}
+
+// Tests that configured admin operators guard the default methods with an
+// audit trail in the data directory.
+func TestNodeAdminQuorum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	operators := []common.Address{{1}, {2}, {3}}
+	stack, err := New(&Config{DataDir: dir, AdminOperators: operators, AdminThreshold: 4})
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := os.MkdirAll(stack.config.instanceDir(), 0700); err != nil {
+		t.Fatalf("failed to create instance directory: %v", err)
+	}
+	stack.serverConfig.PrivateKey = stack.config.NodeKey()
+	if err := stack.openAdminQuorum(big.NewInt(1)); err == nil {
+		t.Fatalf("threshold above the number of operators accepted")
+	}
+	stack.config.AdminThreshold = 2
+	if err := stack.openAdminQuorum(big.NewInt(1)); err != nil {
+		t.Fatalf("failed to open admin quorum: %v", err)
+	}
+	defer stack.closeAdminQuorum()
+
+	want := rpc.AuthorizationScope{ChainID: big.NewInt(1), Node: discover.PubkeyID(&stack.serverConfig.PrivateKey.PublicKey).String()}
+	if scope := stack.adminQuorum.Scope(); scope.Node != want.Node || scope.ChainID.Cmp(want.ChainID) != 0 {
+		t.Errorf("scope mismatch: have %v, want %v", scope, want)
+	}
+
+	for _, method := range DefaultAdminGuardedMethods {
+		if !stack.adminQuorum.Covers(method) {
+			t.Errorf("method %s not guarded", method)
+		}
+	}
+	if stack.adminQuorum.Covers("admin_addPeer") {
+		t.Errorf("unexpected guarded method admin_addPeer")
+	}
+	if _, err := os.Stat(stack.config.resolvePath(datadirAdminAuditLog)); err != nil {
+		t.Errorf("audit trail not created: %v", err)
+	}
+	// Without operators the methods are left unguarded
+	stack.closeAdminQuorum()
+	stack.config.AdminOperators = nil
+	if err := stack.openAdminQuorum(big.NewInt(1)); err != nil || stack.adminQuorum != nil || stack.adminAudit != nil {
+		t.Errorf("unguarded node has quorum %v, audit %v, err %v", stack.adminQuorum, stack.adminAudit, err)
+	}
+}
+
+// GuardedService is a stand-in for the guarded debug_setHead method.
+type GuardedService struct{}
+
+func (GuardedService) SetHead(number hexutil.Uint64) {}
+
+// Tests that an approval used before a restart of the node can't be replayed
+// after it.
+func TestNodeAdminQuorumRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, _ := crypto.GenerateKey()
+	stack, err := New(&Config{DataDir: dir, AdminOperators: []common.Address{crypto.PubkeyToAddress(key.PublicKey)}, AdminThreshold: 1})
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := os.MkdirAll(stack.config.instanceDir(), 0700); err != nil {
+		t.Fatalf("failed to create instance directory: %v", err)
+	}
+	stack.serverConfig.PrivateKey = stack.config.NodeKey()
+
+	expiry := uint64(time.Now().Add(time.Minute).Unix())
+	params := json.RawMessage(`["0x1"]`)
+	call := func(scope rpc.AuthorizationScope) error {
+		sig, err := rpc.SignAuthorization(key, scope, "debug_setHead", params, 1, expiry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := rpc.NewServer()
+		if err := server.RegisterName("debug", GuardedService{}); err != nil {
+			t.Fatal(err)
+		}
+		server.SetQuorum(stack.adminQuorum)
+		client := rpc.DialInProc(server)
+		defer client.Close()
+
+		auth := &rpc.Authorization{Nonce: 1, Expiry: hexutil.Uint64(expiry), Signatures: []hexutil.Bytes{sig}}
+		return client.CallAuthorizedContext(context.Background(), nil, auth, "debug_setHead", "0x1")
+	}
+	if err := stack.openAdminQuorum(big.NewInt(1)); err != nil {
+		t.Fatalf("failed to open admin quorum: %v", err)
+	}
+	if err := call(stack.adminQuorum.Scope()); err != nil {
+		t.Fatalf("approved request refused: %v", err)
+	}
+	stack.closeAdminQuorum()
+
+	if err := stack.openAdminQuorum(big.NewInt(1)); err != nil {
+		t.Fatalf("failed to reopen admin quorum: %v", err)
+	}
+	defer stack.closeAdminQuorum()
+	if err := call(stack.adminQuorum.Scope()); err == nil {
+		t.Fatalf("approval replayed after restart")
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package node
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/matrix/go-matrix/ca"
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/p2p/discover"
+	"github.com/prometheus/prometheus/util/flock"
+)
+
+var (
+	errNodeKeyFixed     = errors.New("node key configured explicitly, cannot rotate")
+	errNodeKeyEphemeral = errors.New("node key is ephemeral, nothing to rotate")
+)
+
+// NodeKeyRotation describes a replaced node key.
+type NodeKeyRotation struct {
+	OldID  *discover.NodeID `json:"oldId"`           // nil if no key was persisted yet
+	NewID  discover.NodeID  `json:"newId"`           // Identity derived from the new key
+	Enode  string           `json:"enode,omitempty"` // Enode URL of the restarted server
+	Backup string           `json:"backup"`          // Path the old key was moved to
+	Bonds  int              `json:"bonds"`           // Node database entries to bond with again
+}
+
+// rotateNodeKey replaces the persisted node key with a freshly generated one,
+// keeping a copy of the old key next to it.
+func (c *Config) rotateNodeKey() (*ecdsa.PrivateKey, *NodeKeyRotation, error) {
+	if c.P2P.PrivateKey != nil {
+		return nil, nil, errNodeKeyFixed
+	}
+	if c.DataDir == "" {
+		return nil, nil, errNodeKeyEphemeral
+	}
+	if err := os.MkdirAll(c.instanceDir(), 0700); err != nil {
+		return nil, nil, err
+	}
+	rotation := new(NodeKeyRotation)
+
+	keyfile := c.resolvePath(datadirPrivateKey)
+	old, err := crypto.LoadECDSA(keyfile)
+	switch {
+	case err == nil:
+		id := discover.PubkeyID(&old.PublicKey)
+		rotation.OldID = &id
+	case !os.IsNotExist(err):
+		// Never overwrite a key we can't read
+		return nil, nil, fmt.Errorf("invalid node key %s: %v", keyfile, err)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	rotation.NewID = discover.PubkeyID(&key.PublicKey)
+
+	if old != nil {
+		blob, err := ioutil.ReadFile(keyfile)
+		if err != nil {
+			return nil, nil, err
+		}
+		rotation.Backup = fmt.Sprintf("%s.%d.bak", keyfile, time.Now().Unix())
+		if err := ioutil.WriteFile(rotation.Backup, blob, 0600); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := crypto.SaveECDSA(keyfile+".tmp", key); err != nil {
+		return nil, nil, err
+	}
+	if err := os.Rename(keyfile+".tmp", keyfile); err != nil {
+		return nil, nil, err
+	}
+	return key, rotation, nil
+}
+
+// RotateNodeKey replaces the node key persisted in the data directory of a
+// stopped node. The old key is backed up and the nodes of the discovery database
+// are marked to be bonded with again, as they only know the old identity. It
+// fails if a node is running on the data directory.
+func RotateNodeKey(c *Config) (*NodeKeyRotation, error) {
+	if c.P2P.PrivateKey != nil {
+		return nil, errNodeKeyFixed
+	}
+	if c.DataDir == "" {
+		return nil, errNodeKeyEphemeral
+	}
+	if err := os.MkdirAll(c.instanceDir(), 0700); err != nil {
+		return nil, err
+	}
+	release, _, err := flock.New(filepath.Join(c.instanceDir(), "LOCK"))
+	if err != nil {
+		return nil, convertFileLockError(err)
+	}
+	defer release.Release()
+
+	_, rotation, err := c.rotateNodeKey()
+	if err != nil {
+		return nil, err
+	}
+	if db := c.NodeDB(); common.FileExist(db) {
+		if rotation.Bonds, err = discover.ResetBonds(db); err != nil {
+			return rotation, err
+		}
+	}
+	return rotation, nil
+}
+
+// RotateNodeKey replaces the node key of the running node. The p2p server is
+// restarted with the new identity, dropping all peers, which are then dialed
+// again and handshaken with under the new identity. The discovery database is
+// kept, but its nodes are bonded with again before use.
+func (n *Node) RotateNodeKey() (*NodeKeyRotation, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.server == nil {
+		return nil, ErrNodeStopped
+	}
+	key, rotation, err := n.config.rotateNodeKey()
+	if err != nil {
+		return nil, err
+	}
+	n.log.Warn("Rotating node key, dropping all peers", "old", rotation.OldID, "new", rotation.NewID, "backup", rotation.Backup)
+
+	n.server.Stop()
+	if n.serverConfig.NodeDatabase != "" {
+		if rotation.Bonds, err = discover.ResetBonds(n.serverConfig.NodeDatabase); err != nil {
+			n.log.Error("Failed to reset discovery bonds", "err", err)
+		}
+	}
+	n.serverConfig.PrivateKey = key
+	n.server.PrivateKey = key
+	if err := n.server.Start(); err != nil {
+		return rotation, convertFileLockError(err)
+	}
+	self := n.server.Self()
+	ca.SetSelf(self.ID)
+
+	// Operator approvals are bound to the node ID
+	if n.adminQuorum != nil {
+		scope := n.adminQuorum.Scope()
+		scope.Node = self.ID.String()
+		n.adminQuorum.SetScope(scope)
+	}
+
+	rotation.Enode = self.String()
+	n.log.Info("Node key rotated", "self", rotation.Enode, "bonds", rotation.Bonds)
+	return rotation, nil
+}
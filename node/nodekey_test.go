@@ -0,0 +1,115 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package node
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/p2p/discover"
+)
+
+// Tests that the node key of a stopped node can be rotated, keeping a backup of
+// the old key.
+func TestRotateNodeKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &Config{Name: "unit-test", DataDir: dir}
+	old := config.NodeKey()
+	keyfile := filepath.Join(dir, "unit-test", datadirPrivateKey)
+	blob, err := ioutil.ReadFile(keyfile)
+	if err != nil {
+		t.Fatalf("failed to read node key: %v", err)
+	}
+	rotation, err := RotateNodeKey(config)
+	if err != nil {
+		t.Fatalf("failed to rotate node key: %v", err)
+	}
+	if rotation.OldID == nil || *rotation.OldID != discover.PubkeyID(&old.PublicKey) {
+		t.Errorf("old id mismatch: have %v, want %x", rotation.OldID, discover.PubkeyID(&old.PublicKey))
+	}
+	backup, err := ioutil.ReadFile(rotation.Backup)
+	if err != nil {
+		t.Fatalf("failed to read backup: %v", err)
+	}
+	if !bytes.Equal(backup, blob) {
+		t.Errorf("backup mismatch: have %s, want %s", backup, blob)
+	}
+	key, err := crypto.LoadECDSA(keyfile)
+	if err != nil {
+		t.Fatalf("failed to load rotated key: %v", err)
+	}
+	if id := discover.PubkeyID(&key.PublicKey); id != rotation.NewID || id == *rotation.OldID {
+		t.Errorf("new id mismatch: have %x, want %x", id, rotation.NewID)
+	}
+	if key := config.NodeKey(); discover.PubkeyID(&key.PublicKey) != rotation.NewID {
+		t.Errorf("rotated key not used by the config")
+	}
+	// Keys not persisted in the data directory can't be rotated
+	if _, err := RotateNodeKey(&Config{DataDir: dir, P2P: testNodeConfig().P2P}); err != errNodeKeyFixed {
+		t.Errorf("fixed key rotation error mismatch: have %v, want %v", err, errNodeKeyFixed)
+	}
+	if _, err := RotateNodeKey(&Config{}); err != errNodeKeyEphemeral {
+		t.Errorf("ephemeral key rotation error mismatch: have %v, want %v", err, errNodeKeyEphemeral)
+	}
+}
+
+// Tests that the node key of a running node can be rotated, restarting the p2p
+// server with the new identity, but not offline while the node holds the data
+// directory.
+func TestNodeRotateNodeKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	stack, err := New(&Config{DataDir: dir})
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if _, err := stack.RotateNodeKey(); err != ErrNodeStopped {
+		t.Fatalf("stopped rotation error mismatch: have %v, want %v", err, ErrNodeStopped)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	old := stack.Server().Self().ID
+	rotation, err := stack.RotateNodeKey()
+	if err != nil {
+		t.Fatalf("failed to rotate node key: %v", err)
+	}
+	if *rotation.OldID != old {
+		t.Errorf("old id mismatch: have %x, want %x", *rotation.OldID, old)
+	}
+	if id := stack.Server().Self().ID; id != rotation.NewID {
+		t.Errorf("server id mismatch: have %x, want %x", id, rotation.NewID)
+	}
+	if _, err := RotateNodeKey(&Config{DataDir: dir}); err != ErrDatadirUsed {
+		t.Errorf("offline rotation error mismatch: have %v, want %v", err, ErrDatadirUsed)
+	}
+}
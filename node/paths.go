@@ -0,0 +1,165 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package node
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/log"
+)
+
+// CheckDir ensures that path is a directory the node can write into, creating
+// it if it does not exist yet. It is used to validate user supplied storage
+// locations up front instead of failing halfway through startup.
+func CheckDir(path string) error {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	f, err := ioutil.TempFile(path, ".writecheck")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %v", path, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// MoveDir moves the contents of the src directory into dst, which must either
+// not exist or be empty. Entries are renamed where possible and copied over when
+// the two directories live on different filesystems. The emptied src directory
+// is removed afterwards.
+func MoveDir(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", src)
+	}
+	if absSrc, absDst := absPath(src), absPath(dst); absSrc == absDst {
+		return fmt.Errorf("source and destination are the same directory: %s", absSrc)
+	}
+	if err := CheckDir(dst); err != nil {
+		return err
+	}
+	existing, err := ioutil.ReadDir(dst)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("destination %s is not empty", dst)
+	}
+	entries, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		from, to := filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())
+		if err := os.Rename(from, to); err == nil {
+			continue
+		}
+		// Renaming fails across filesystems, fall back to copying
+		if err := copyPath(from, to); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(from); err != nil {
+			return err
+		}
+	}
+	return os.Remove(src)
+}
+
+// migrateChainData moves the chain database name from its default location in
+// the instance directory into ChainDataDir, the first time the database is
+// opened after ChainDataDir was configured.
+func (c *Config) migrateChainData(name string) error {
+	if c.ChainDataDir == "" || !isChainDatabase[name] {
+		return nil
+	}
+	oldpath, newpath := c.defaultPath(name), c.resolvePath(name)
+	if common.FileExist(newpath) || !common.FileExist(oldpath) {
+		return nil
+	}
+	log.Info("Moving chain database", "database", name, "from", oldpath, "to", newpath)
+	if err := MoveDir(oldpath, newpath); err != nil {
+		return fmt.Errorf("failed to move %s to %s: %v", oldpath, newpath, err)
+	}
+	return nil
+}
+
+// absPath returns the cleaned absolute form of path, or the cleaned path itself
+// if it cannot be made absolute.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// copyPath recursively copies the file or directory at src to dst, preserving
+// permissions. Only regular files and directories are supported.
+func copyPath(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		default:
+			return fmt.Errorf("unsupported file type: %s", path)
+		}
+	})
+}
+
+// copyFile copies the regular file src to dst with the given permissions.
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
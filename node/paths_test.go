@@ -0,0 +1,157 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := CheckDir(filepath.Join(dir, "a", "b")); err != nil {
+		t.Errorf("missing directory rejected: %v", err)
+	}
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := CheckDir(file); err == nil {
+		t.Error("regular file accepted as directory")
+	}
+}
+
+func TestMoveDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0700); err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "sub", "key"), []byte("secret"), 0600); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+	if err := MoveDir(src, src); err == nil {
+		t.Error("moving a directory onto itself succeeded")
+	}
+	if err := MoveDir(src, dst); err != nil {
+		t.Fatalf("failed to move directory: %v", err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("source directory still present: %v", err)
+	}
+	if blob, err := ioutil.ReadFile(filepath.Join(dst, "sub", "key")); err != nil || string(blob) != "secret" {
+		t.Errorf("moved file mismatch: have %q, err %v", blob, err)
+	}
+	// Moving into a non-empty directory must be refused
+	if err := os.MkdirAll(src, 0700); err != nil {
+		t.Fatalf("failed to recreate source: %v", err)
+	}
+	if err := MoveDir(src, dst); err == nil {
+		t.Error("moving into a non-empty directory succeeded")
+	}
+}
+
+func TestCopyPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0700); err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "sub", "chunk"), []byte("data"), 0640); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+	if err := copyPath(src, dst); err != nil {
+		t.Fatalf("failed to copy directory: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dst, "sub", "chunk"))
+	if err != nil {
+		t.Fatalf("copied file missing: %v", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("permission mismatch: have %v, want %v", info.Mode().Perm(), os.FileMode(0640))
+	}
+}
+
+// Tests that an existing chain database is moved into the configured chain data
+// directory the first time it is opened.
+func TestChainDataMigration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := testNodeConfig()
+	config.DataDir = filepath.Join(dir, "data")
+
+	// Create a chain database at the default location
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	db, err := stack.OpenDatabase("chaindata", 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.Put([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("failed to write database: %v", err)
+	}
+	db.Close()
+	oldpath := config.ResolvePath("chaindata")
+
+	// Reopen it with a chain data directory configured
+	config.ChainDataDir = filepath.Join(dir, "chain")
+	stack, err = New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if path, want := config.ResolvePath("chaindata"), filepath.Join(config.ChainDataDir, "chaindata"); path != want {
+		t.Errorf("chain database path mismatch: have %s, want %s", path, want)
+	}
+	db, err = stack.OpenDatabase("chaindata", 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open moved database: %v", err)
+	}
+	defer db.Close()
+
+	if value, err := db.Get([]byte("key")); err != nil || string(value) != "value" {
+		t.Errorf("moved database content mismatch: have %q, err %v", value, err)
+	}
+	if _, err := os.Stat(oldpath); !os.IsNotExist(err) {
+		t.Errorf("database still present at the default location: %v", err)
+	}
+	if path := config.ResolvePath("nodekey"); filepath.Dir(path) == filepath.Join(config.ChainDataDir) {
+		t.Errorf("non-chain resource resolved into the chain data directory: %s", path)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package node
+
+import "fmt"
+
+// PluginSymbol is the symbol a node plugin exports its service constructor as,
+// either as a function or as a ServiceConstructor variable:
+//
+//   func Service(ctx *node.ServiceContext) (node.Service, error)
+const PluginSymbol = "Service"
+
+// pluginConstructor converts the symbol exported by a plugin into a service
+// constructor.
+func pluginConstructor(sym interface{}) (ServiceConstructor, error) {
+	switch constructor := sym.(type) {
+	case func(*ServiceContext) (Service, error):
+		return constructor, nil
+	case *ServiceConstructor:
+		if *constructor == nil {
+			return nil, fmt.Errorf("%s is nil", PluginSymbol)
+		}
+		return *constructor, nil
+	case *func(*ServiceContext) (Service, error):
+		if *constructor == nil {
+			return nil, fmt.Errorf("%s is nil", PluginSymbol)
+		}
+		return *constructor, nil
+	default:
+		return nil, fmt.Errorf("%s has type %T, want %T", PluginSymbol, sym, ServiceConstructor(nil))
+	}
+}
+
+// loadPlugins opens the configured plugins, returning their service constructors.
+func (n *Node) loadPlugins() ([]ServiceConstructor, error) {
+	var constructors []ServiceConstructor
+	for _, path := range n.config.Plugins {
+		constructor, err := loadPlugin(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load plugin: %v", err)
+		}
+		n.log.Info("Loaded node plugin", "path", path)
+		constructors = append(constructors, constructor)
+	}
+	return constructors, nil
+}
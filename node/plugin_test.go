@@ -0,0 +1,67 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package node
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// Tests that the service constructors exported by plugins are recognized both as
+// functions and as variables.
+func TestPluginConstructor(t *testing.T) {
+	var (
+		constructor    ServiceConstructor = NewNoopService
+		constructorFn                     = NewNoopService
+		nilConstructor ServiceConstructor
+	)
+	valid := []interface{}{NewNoopService, &constructor, &constructorFn}
+	for i, sym := range valid {
+		fn, err := pluginConstructor(sym)
+		if err != nil {
+			t.Errorf("symbol %d: failed to convert: %v", i, err)
+			continue
+		}
+		if service, err := fn(nil); err != nil || service == nil {
+			t.Errorf("symbol %d: constructor failed: %v", i, err)
+		}
+	}
+	invalid := []interface{}{&nilConstructor, func() {}, new(int)}
+	for i, sym := range invalid {
+		if _, err := pluginConstructor(sym); err == nil {
+			t.Errorf("symbol %d: invalid symbol accepted", i)
+		}
+	}
+}
+
+// Tests that a node refuses to start if a configured plugin can't be loaded.
+func TestNodeMissingPlugin(t *testing.T) {
+	config := testNodeConfig()
+	config.Plugins = []string{filepath.Join("testdata", "missing.so")}
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Start(); err == nil {
+		stack.Stop()
+		t.Fatalf("node started with missing plugin")
+	}
+	if stack.Server() != nil {
+		t.Errorf("p2p server running after failed start")
+	}
+}
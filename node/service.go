@@ -44,6 +44,7 @@ type ServiceContext struct {
 	MsgCenter      *mc.Center
 	HD             *hd.HD
 	SignHelper     *signhelper.SignHelper
+	RPCCache       *rpc.ResponseCache // RPC response cache, nil if disabled
 }
 
 func (ctx *ServiceContext) GetConfig() *Config {
@@ -94,7 +95,8 @@ type ServiceConstructor func(ctx *ServiceContext) (Service, error)
 // Start method.
 //
 // • Restart logic is not required as the node will create a fresh instance
-// every time a service is started.
+// every time a service is started, unless the service is supervised and reports
+// runtime faults (see FaultReporter).
 type Service interface {
 	// Protocols retrieves the P2P protocols the service wishes to start.
 	Protocols() []p2p.Protocol
@@ -110,3 +112,13 @@ type Service interface {
 	// are all terminated.
 	Stop() error
 }
+
+// FaultReporter is implemented by services able to detect runtime failures they
+// cannot recover from on their own, such as a crashed background loop. If the
+// service is supervised, the node stops and starts the same instance again on
+// every reported fault, so Start must be callable after Stop.
+type FaultReporter interface {
+	// Faults returns the channel the service reports its failures on. The same
+	// channel is used for the whole lifetime of the instance.
+	Faults() <-chan error
+}
@@ -0,0 +1,306 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package node
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/p2p"
+)
+
+// Service states reported by admin_services.
+const (
+	ServiceRunning = "running" // Service started and hasn't reported a fault since
+	ServiceFailed  = "failed"  // Service failed, possibly waiting for a restart
+	ServiceStopped = "stopped" // Service not started (yet)
+)
+
+// ServiceStatus is the state of a registered service as seen by the supervisor.
+type ServiceStatus struct {
+	Name        string     `json:"name"`
+	Supervised  bool       `json:"supervised"`
+	State       string     `json:"state"`
+	Restarts    int        `json:"restarts"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastFailure *time.Time `json:"lastFailure,omitempty"`
+	NextRestart *time.Time `json:"nextRestart,omitempty"`
+}
+
+// serviceName returns the name a service is configured and reported by, which
+// is its type name without the pointer, e.g. "whisperv6.Whisper".
+func serviceName(kind reflect.Type) string {
+	return strings.TrimPrefix(kind.String(), "*")
+}
+
+// supervisedService is the bookkeeping of a single service.
+type supervisedService struct {
+	name       string
+	service    Service
+	supervised bool
+
+	state       string
+	started     time.Time // Time of the last successful start
+	restarts    int       // Number of successful restarts
+	failures    int       // Number of consecutive failures, drives the backoff
+	lastErr     error
+	lastFailure time.Time
+	nextRestart time.Time
+}
+
+// supervisor starts the services of a node, and restarts the supervised ones
+// with exponential backoff when they fail to start or report a runtime fault.
+type supervisor struct {
+	server     *p2p.Server
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	limit      int
+	log        log.Logger
+
+	lock     sync.Mutex
+	services map[reflect.Type]*supervisedService
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// newSupervisor creates a supervisor for the given services, marking the ones
+// named in the node configuration as supervised.
+func newSupervisor(config *Config, server *p2p.Server, services map[reflect.Type]Service, logger log.Logger) *supervisor {
+	s := &supervisor{
+		server:     server,
+		minBackoff: config.ServiceRestartBackoff,
+		maxBackoff: config.ServiceRestartMaxBackoff,
+		limit:      config.ServiceRestartLimit,
+		log:        logger,
+		services:   make(map[reflect.Type]*supervisedService),
+		quit:       make(chan struct{}),
+	}
+	if s.minBackoff <= 0 {
+		s.minBackoff = DefaultConfig.ServiceRestartBackoff
+	}
+	if s.maxBackoff <= 0 {
+		s.maxBackoff = DefaultConfig.ServiceRestartMaxBackoff
+	}
+	if s.maxBackoff < s.minBackoff {
+		s.maxBackoff = s.minBackoff
+	}
+	supervised := make(map[string]bool)
+	for _, name := range config.SupervisedServices {
+		supervised[name] = true
+	}
+	for kind, service := range services {
+		name := serviceName(kind)
+		s.services[kind] = &supervisedService{
+			name:       name,
+			service:    service,
+			supervised: supervised[name],
+			state:      ServiceStopped,
+		}
+		delete(supervised, name)
+	}
+	for name := range supervised {
+		logger.Warn("Unknown supervised service", "name", name)
+	}
+	return s
+}
+
+// start starts a single service. Failures of unsupervised services are returned
+// to abort the node startup, those of supervised ones schedule a retry instead.
+func (s *supervisor) start(kind reflect.Type) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entry := s.services[kind]
+	if err := safeStart(entry.service, s.server); err != nil {
+		if !entry.supervised {
+			return err
+		}
+		s.fail(entry, err)
+		return nil
+	}
+	entry.state, entry.started = ServiceRunning, time.Now()
+	return nil
+}
+
+// watch starts listening for the runtime faults of the supervised services.
+func (s *supervisor) watch() {
+	for _, entry := range s.services {
+		if reporter, ok := entry.service.(FaultReporter); ok && entry.supervised {
+			s.wg.Add(1)
+			go s.watchFaults(entry, reporter.Faults())
+		}
+	}
+}
+
+// watchFaults restarts a supervised service whenever it reports a fault.
+func (s *supervisor) watchFaults(entry *supervisedService, faults <-chan error) {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case err, ok := <-faults:
+			if !ok {
+				return
+			}
+			s.lock.Lock()
+			if entry.state == ServiceRunning {
+				s.fail(entry, err)
+			}
+			s.lock.Unlock()
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// fail records the failure of a service and schedules its restart unless the
+// restart limit has been reached. The lock must be held.
+func (s *supervisor) fail(entry *supervisedService, err error) {
+	running := entry.state == ServiceRunning
+
+	// Services that stayed up for long enough start over with a short backoff
+	if running && time.Since(entry.started) > s.maxBackoff {
+		entry.failures = 0
+	}
+	entry.state, entry.lastErr, entry.lastFailure = ServiceFailed, err, time.Now()
+	entry.failures++
+
+	if s.limit > 0 && entry.restarts >= s.limit {
+		entry.nextRestart = time.Time{}
+		s.log.Error("Service failed, restart limit reached", "service", entry.name, "restarts", entry.restarts, "err", err)
+		return
+	}
+	delay := s.backoff(entry.failures)
+	entry.nextRestart = entry.lastFailure.Add(delay)
+	s.log.Warn("Service failed, scheduling restart", "service", entry.name, "in", delay, "err", err)
+
+	s.wg.Add(1)
+	go s.restart(entry, delay, running)
+}
+
+// backoff returns the delay before the restart following the given number of
+// consecutive failures.
+func (s *supervisor) backoff(failures int) time.Duration {
+	delay := s.minBackoff
+	for i := 1; i < failures && delay < s.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > s.maxBackoff {
+		delay = s.maxBackoff
+	}
+	return delay
+}
+
+// restart waits for the backoff to pass, then stops the failed service if it
+// was running and starts it again.
+func (s *supervisor) restart(entry *supervisedService, delay time.Duration, running bool) {
+	defer s.wg.Done()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-s.quit:
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if running {
+		if err := safeStop(entry.service); err != nil {
+			s.log.Warn("Failed to stop faulty service", "service", entry.name, "err", err)
+		}
+	}
+	if err := safeStart(entry.service, s.server); err != nil {
+		s.fail(entry, err)
+		return
+	}
+	entry.state, entry.started, entry.nextRestart = ServiceRunning, time.Now(), time.Time{}
+	entry.restarts++
+	s.log.Info("Service restarted", "service", entry.name, "restarts", entry.restarts)
+}
+
+// stop terminates the fault watchers and pending restarts, blocking until all
+// of them return. Services are left as they are.
+func (s *supervisor) stop() {
+	close(s.quit)
+	s.wg.Wait()
+}
+
+// running reports whether a service is currently running and thus needs to be
+// stopped on shutdown.
+func (s *supervisor) running(kind reflect.Type) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entry, ok := s.services[kind]
+	return ok && entry.state == ServiceRunning
+}
+
+// status reports the state of all services, sorted by name.
+func (s *supervisor) status() []ServiceStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	status := make([]ServiceStatus, 0, len(s.services))
+	for _, entry := range s.services {
+		stat := ServiceStatus{
+			Name:       entry.name,
+			Supervised: entry.supervised,
+			State:      entry.state,
+			Restarts:   entry.restarts,
+		}
+		if entry.lastErr != nil {
+			failure := entry.lastFailure
+			stat.LastError, stat.LastFailure = entry.lastErr.Error(), &failure
+		}
+		if !entry.nextRestart.IsZero() {
+			next := entry.nextRestart
+			stat.NextRestart = &next
+		}
+		status = append(status, stat)
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Name < status[j].Name })
+	return status
+}
+
+// safeStart starts a service, converting a panic into an error.
+func safeStart(service Service, server *p2p.Server) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic during start: %v", r)
+		}
+	}()
+	return service.Start(server)
+}
+
+// safeStop stops a service, converting a panic into an error.
+func safeStop(service Service) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic during stop: %v", r)
+		}
+	}()
+	return service.Stop()
+}
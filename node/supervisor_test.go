@@ -0,0 +1,179 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package node
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/p2p"
+)
+
+// faultyService is a service failing a configurable number of starts and able
+// to report runtime faults.
+type faultyService struct {
+	NoopService
+
+	lock     sync.Mutex
+	failures int // Number of upcoming starts to fail
+	panics   bool
+	starts   int
+	stops    int
+	faults   chan error
+}
+
+func (s *faultyService) Start(*p2p.Server) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		if s.panics {
+			panic("boom")
+		}
+		return errors.New("start failed")
+	}
+	s.starts++
+	return nil
+}
+
+func (s *faultyService) Stop() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.stops++
+	return nil
+}
+
+func (s *faultyService) Faults() <-chan error { return s.faults }
+
+func (s *faultyService) counts() (int, int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.starts, s.stops
+}
+
+// newTestSupervisor creates a supervisor over a single faulty service.
+func newTestSupervisor(service *faultyService, supervised bool, limit int) (*supervisor, reflect.Type) {
+	config := &Config{
+		ServiceRestartBackoff:    10 * time.Millisecond,
+		ServiceRestartMaxBackoff: 40 * time.Millisecond,
+		ServiceRestartLimit:      limit,
+	}
+	if supervised {
+		config.SupervisedServices = []string{"node.faultyService"}
+	}
+	kind := reflect.TypeOf(service)
+	return newSupervisor(config, nil, map[reflect.Type]Service{kind: service}, log.New()), kind
+}
+
+// waitServiceState waits until the single service of the supervisor reaches the
+// given state.
+func waitServiceState(t *testing.T, s *supervisor, state string) ServiceStatus {
+	for i := 0; i < 100; i++ {
+		if status := s.status()[0]; status.State == state {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("service didn't reach state %q: %+v", state, s.status()[0])
+	return ServiceStatus{}
+}
+
+// Tests that start failures of unsupervised services are returned, whereas those
+// of supervised ones are retried with backoff.
+func TestSupervisorStartFailure(t *testing.T) {
+	service := &faultyService{failures: 1, panics: true}
+	s, kind := newTestSupervisor(service, false, 0)
+	if err := s.start(kind); err == nil {
+		t.Fatalf("unsupervised start failure not reported")
+	}
+	s.stop()
+
+	service = &faultyService{failures: 2}
+	s, kind = newTestSupervisor(service, true, 0)
+	defer s.stop()
+
+	if err := s.start(kind); err != nil {
+		t.Fatalf("supervised start failure reported: %v", err)
+	}
+	if s.running(kind) {
+		t.Fatalf("failed service reported running")
+	}
+	status := waitServiceState(t, s, ServiceRunning)
+	if status.Name != "node.faultyService" || !status.Supervised {
+		t.Errorf("service identity mismatch: %+v", status)
+	}
+	if status.Restarts != 1 {
+		t.Errorf("restart count mismatch: have %d, want 1", status.Restarts)
+	}
+	if status.LastError != "start failed" || status.LastFailure == nil || status.NextRestart != nil {
+		t.Errorf("failure details mismatch: %+v", status)
+	}
+	if starts, stops := service.counts(); starts != 1 || stops != 0 {
+		t.Errorf("lifecycle mismatch: have %d starts and %d stops, want 1 and 0", starts, stops)
+	}
+}
+
+// Tests that supervised services reporting a fault are restarted in place, and
+// left failed once the restart limit is reached.
+func TestSupervisorFaultRestart(t *testing.T) {
+	service := &faultyService{faults: make(chan error)}
+	s, kind := newTestSupervisor(service, true, 1)
+	defer s.stop()
+
+	if err := s.start(kind); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+	s.watch()
+
+	service.faults <- errors.New("loop crashed")
+	status := waitServiceState(t, s, ServiceRunning)
+	for status.Restarts == 0 {
+		time.Sleep(10 * time.Millisecond)
+		status = s.status()[0]
+	}
+	if starts, stops := service.counts(); starts != 2 || stops != 1 {
+		t.Errorf("lifecycle mismatch: have %d starts and %d stops, want 2 and 1", starts, stops)
+	}
+	service.faults <- errors.New("loop crashed again")
+	status = waitServiceState(t, s, ServiceFailed)
+	if status.Restarts != 1 || status.NextRestart != nil {
+		t.Errorf("restart limit not honoured: %+v", status)
+	}
+	if s.running(kind) {
+		t.Errorf("service given up on reported running")
+	}
+}
+
+// Tests that the restart backoff doubles up to the configured maximum.
+func TestSupervisorBackoff(t *testing.T) {
+	s, _ := newTestSupervisor(&faultyService{}, true, 0)
+	defer s.stop()
+
+	want := []time.Duration{10, 20, 40, 40, 40}
+	for i, delay := range want {
+		if have := s.backoff(i + 1); have != delay*time.Millisecond {
+			t.Errorf("failure %d: backoff mismatch: have %v, want %v", i+1, have, delay*time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package node
+
+import (
+	"net"
+	"sort"
+	"time"
+
+	"github.com/matrix/go-matrix/p2p"
+	"github.com/matrix/go-matrix/p2p/geoip"
+)
+
+// latencyBuckets are the upper bounds of the latency buckets of the topology.
+var latencyBuckets = []time.Duration{
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// topologyUnknown is the country and latency bucket of peers not located or
+// not measured yet.
+const topologyUnknown = "unknown"
+
+// Topology is the geographic and network distribution of the connected peers.
+type Topology struct {
+	Peers     int             `json:"peers"`
+	Located   int             `json:"located"`   // Peers found in the GeoIP databases
+	Countries map[string]int  `json:"countries"` // Peers per ISO country code
+	ASNs      []*TopologyASN  `json:"asns"`      // Autonomous systems, most peers first
+	Latency   map[string]int  `json:"latency"`   // Peers per round trip time bucket
+	PeerList  []*TopologyPeer `json:"peerList"`
+}
+
+// TopologyASN is the number of peers within an autonomous system.
+type TopologyASN struct {
+	Number       uint   `json:"number"`
+	Organization string `json:"organization,omitempty"`
+	Peers        int    `json:"peers"`
+}
+
+// TopologyPeer is a connected peer along with its location.
+type TopologyPeer struct {
+	ID            string `json:"id"`
+	RemoteAddress string `json:"remoteAddress"`
+	Latency       string `json:"latency,omitempty"`
+	geoip.Location
+}
+
+// latencyBucket returns the name of the bucket a round trip time falls in.
+func latencyBucket(latency time.Duration) string {
+	if latency <= 0 {
+		return topologyUnknown
+	}
+	for _, bound := range latencyBuckets {
+		if latency < bound {
+			return "<" + bound.String()
+		}
+	}
+	return ">=" + latencyBuckets[len(latencyBuckets)-1].String()
+}
+
+// Topology reports the distribution of the connected peers over countries,
+// autonomous systems and latencies. Peers are only located if GeoIP databases
+// are configured.
+func (n *Node) Topology() (*Topology, error) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	if n.server == nil {
+		return nil, ErrNodeStopped
+	}
+	return buildTopology(n.server.Peers(), n.geoip), nil
+}
+
+// buildTopology aggregates the locations and latencies of peers.
+func buildTopology(peers []*p2p.Peer, db *geoip.DB) *Topology {
+	topo := &Topology{
+		Peers:     len(peers),
+		Countries: make(map[string]int),
+		Latency:   make(map[string]int),
+		PeerList:  make([]*TopologyPeer, 0, len(peers)),
+	}
+	asns := make(map[uint]*TopologyASN)
+	for _, peer := range peers {
+		info := &TopologyPeer{
+			ID:            peer.ID().String(),
+			RemoteAddress: peer.RemoteAddr().String(),
+		}
+		if latency := peer.Latency(); latency > 0 {
+			info.Latency = latency.String()
+		}
+		topo.Latency[latencyBucket(peer.Latency())]++
+
+		if tcp, ok := peer.RemoteAddr().(*net.TCPAddr); ok && db != nil {
+			// lookup errors leave the peer unlocated
+			if loc, err := db.Locate(tcp.IP); err == nil {
+				info.Location = *loc
+			}
+		}
+		if info.Country != "" || info.ASN != 0 {
+			topo.Located++
+		}
+		country := info.Country
+		if country == "" {
+			country = topologyUnknown
+		}
+		topo.Countries[country]++
+
+		if info.ASN != 0 {
+			asn := asns[info.ASN]
+			if asn == nil {
+				asn = &TopologyASN{Number: info.ASN, Organization: info.Organization}
+				asns[info.ASN] = asn
+				topo.ASNs = append(topo.ASNs, asn)
+			}
+			asn.Peers++
+		}
+		topo.PeerList = append(topo.PeerList, info)
+	}
+	sort.Slice(topo.ASNs, func(i, j int) bool {
+		if topo.ASNs[i].Peers != topo.ASNs[j].Peers {
+			return topo.ASNs[i].Peers > topo.ASNs[j].Peers
+		}
+		return topo.ASNs[i].Number < topo.ASNs[j].Number
+	})
+	return topo
+}
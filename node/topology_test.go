@@ -0,0 +1,59 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matrix/go-matrix/p2p"
+	"github.com/matrix/go-matrix/p2p/discover"
+)
+
+func TestLatencyBucket(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                      "unknown",
+		10 * time.Millisecond:  "<50ms",
+		50 * time.Millisecond:  "<100ms",
+		499 * time.Millisecond: "<500ms",
+		999 * time.Millisecond: "<1s",
+		3 * time.Second:        ">=1s",
+	}
+	for latency, want := range tests {
+		if have := latencyBucket(latency); have != want {
+			t.Errorf("%v: bucket mismatch: have %q, want %q", latency, have, want)
+		}
+	}
+}
+
+// Tests that peers neither located nor measured are reported as unknown.
+func TestBuildTopology(t *testing.T) {
+	var peers []*p2p.Peer
+	for i := 0; i < 3; i++ {
+		peers = append(peers, p2p.NewPeer(discover.NodeID{byte(i)}, "test", nil))
+	}
+	topo := buildTopology(peers, nil)
+	if topo.Peers != 3 || topo.Located != 0 || len(topo.PeerList) != 3 {
+		t.Errorf("have %d peers (%d located, %d listed), want 3 (0 located, 3 listed)", topo.Peers, topo.Located, len(topo.PeerList))
+	}
+	if topo.Countries["unknown"] != 3 || topo.Latency["unknown"] != 3 || len(topo.ASNs) != 0 {
+		t.Errorf("unexpected distribution: countries %v, latency %v, asns %v", topo.Countries, topo.Latency, topo.ASNs)
+	}
+	if topo.PeerList[1].ID != peers[1].ID().String() {
+		t.Errorf("peer id mismatch: have %s, want %s", topo.PeerList[1].ID, peers[1].ID())
+	}
+}
@@ -82,7 +82,7 @@ func (b *Bucket) Start() {
 		b.log.Info("buckets stop!")
 		b.sub.Unsubscribe()
 
-		close(b.quit)
+		// quit is kept open, the bucket is started again with the server
 		close(b.blockChain)
 	}()
 
@@ -0,0 +1,189 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p2p
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ProtocolInfo describes a supported subprotocol along with the versions
+// negotiated with the connected peers.
+type ProtocolInfo struct {
+	Name     string                 `json:"name"`
+	Versions []*ProtocolVersionInfo `json:"versions"` // Supported versions, highest first
+	Peers    map[string]uint        `json:"peers"`    // Negotiated version keyed by peer id
+}
+
+// ProtocolVersionInfo describes a single version of a subprotocol.
+type ProtocolVersionInfo struct {
+	Version uint   `json:"version"`
+	Length  uint64 `json:"length"`  // Number of message codes used by the version
+	Enabled bool   `json:"enabled"` // Whether the version is offered to new peers
+	Peers   int    `json:"peers"`   // Number of peers running the version
+}
+
+// ProtocolInfos returns every supported subprotocol with its versions and the
+// versions negotiated with the currently connected peers.
+func (srv *Server) ProtocolInfos() []*ProtocolInfo {
+	var (
+		infos []*ProtocolInfo
+		index = make(map[string]*ProtocolInfo)
+	)
+	for _, proto := range srv.Protocols {
+		info, ok := index[proto.Name]
+		if !ok {
+			info = &ProtocolInfo{Name: proto.Name, Peers: make(map[string]uint)}
+			index[proto.Name] = info
+			infos = append(infos, info)
+		}
+		info.Versions = append(info.Versions, &ProtocolVersionInfo{
+			Version: proto.Version,
+			Length:  proto.Length,
+			Enabled: !srv.protocolDisabled(proto.cap()),
+		})
+	}
+	for _, peer := range srv.Peers() {
+		for name, proto := range peer.running {
+			info, ok := index[name]
+			if !ok {
+				continue
+			}
+			info.Peers[peer.ID().String()] = proto.Version
+			for _, version := range info.Versions {
+				if version.Version == proto.Version {
+					version.Peers++
+				}
+			}
+		}
+	}
+	for _, info := range infos {
+		sort.Slice(info.Versions, func(i, j int) bool { return info.Versions[i].Version > info.Versions[j].Version })
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// DisableProtocol stops offering a subprotocol version to new peers and
+// disconnects the peers currently running it, so they reconnect negotiating
+// one of the remaining versions. The last enabled version of a subprotocol
+// cannot be disabled.
+func (srv *Server) DisableProtocol(name string, version uint) error {
+	cap := Cap{Name: name, Version: version}
+
+	srv.capsLock.Lock()
+	if !srv.supportsProtocol(cap) {
+		srv.capsLock.Unlock()
+		return fmt.Errorf("unknown protocol %v", cap)
+	}
+	enabled := 0
+	for _, proto := range srv.Protocols {
+		if _, disabled := srv.disabledCaps[proto.cap()]; proto.Name == name && !disabled {
+			enabled++
+		}
+	}
+	if _, disabled := srv.disabledCaps[cap]; !disabled && enabled == 1 {
+		srv.capsLock.Unlock()
+		return fmt.Errorf("cannot disable last enabled version of %s", name)
+	}
+	if srv.disabledCaps == nil {
+		srv.disabledCaps = make(map[Cap]struct{})
+	}
+	srv.disabledCaps[cap] = struct{}{}
+	srv.capsLock.Unlock()
+
+	srv.log.Info("Disabled protocol version", "protocol", cap)
+	for _, peer := range srv.Peers() {
+		if proto, ok := peer.running[name]; ok && proto.Version == version {
+			peer.Disconnect(DiscRequested)
+		}
+	}
+	return nil
+}
+
+// EnableProtocol offers a previously disabled subprotocol version to new peers
+// again. Connected peers are kept, even if they negotiated a lower version.
+func (srv *Server) EnableProtocol(name string, version uint) error {
+	cap := Cap{Name: name, Version: version}
+
+	srv.capsLock.Lock()
+	defer srv.capsLock.Unlock()
+
+	if !srv.supportsProtocol(cap) {
+		return fmt.Errorf("unknown protocol %v", cap)
+	}
+	if _, ok := srv.disabledCaps[cap]; ok {
+		delete(srv.disabledCaps, cap)
+		srv.log.Info("Enabled protocol version", "protocol", cap)
+	}
+	return nil
+}
+
+// supportsProtocol reports whether the server runs the given subprotocol
+// version, regardless of whether it is enabled.
+func (srv *Server) supportsProtocol(cap Cap) bool {
+	for _, proto := range srv.Protocols {
+		if proto.cap() == cap {
+			return true
+		}
+	}
+	return false
+}
+
+// protocolDisabled reports whether the given subprotocol version was disabled.
+func (srv *Server) protocolDisabled(cap Cap) bool {
+	srv.capsLock.RLock()
+	defer srv.capsLock.RUnlock()
+
+	_, disabled := srv.disabledCaps[cap]
+	return disabled
+}
+
+// enabledProtocols returns the subprotocols offered to new peers.
+func (srv *Server) enabledProtocols() []Protocol {
+	srv.capsLock.RLock()
+	defer srv.capsLock.RUnlock()
+
+	if len(srv.disabledCaps) == 0 {
+		return srv.Protocols
+	}
+	protos := make([]Protocol, 0, len(srv.Protocols))
+	for _, proto := range srv.Protocols {
+		if _, disabled := srv.disabledCaps[proto.cap()]; !disabled {
+			protos = append(protos, proto)
+		}
+	}
+	return protos
+}
+
+// handshake returns the protocol handshake advertising the enabled subprotocols.
+func (srv *Server) handshake() *protoHandshake {
+	srv.capsLock.RLock()
+	defer srv.capsLock.RUnlock()
+
+	if len(srv.disabledCaps) == 0 {
+		return srv.ourHandshake
+	}
+	hs := *srv.ourHandshake
+	hs.Caps = make([]Cap, 0, len(srv.ourHandshake.Caps))
+	for _, cap := range srv.ourHandshake.Caps {
+		if _, disabled := srv.disabledCaps[cap]; !disabled {
+			hs.Caps = append(hs.Caps, cap)
+		}
+	}
+	return &hs
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p2p
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/matrix/go-matrix/log"
+)
+
+func TestServerDisableProtocol(t *testing.T) {
+	v1, v2 := discard, discard
+	v1.Version, v2.Version = 1, 2
+
+	srv := &Server{
+		Config: Config{
+			PrivateKey: newkey(),
+			MaxPeers:   10,
+			NoDial:     true,
+			Protocols:  []Protocol{v1, v2},
+		},
+		log: log.New(),
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("couldn't start server: %v", err)
+	}
+	defer srv.Stop()
+
+	if err := srv.DisableProtocol("discard", 3); err == nil {
+		t.Error("disabling unknown protocol version succeeded")
+	}
+	if err := srv.DisableProtocol("discard", 2); err != nil {
+		t.Fatalf("couldn't disable protocol: %v", err)
+	}
+	if err := srv.DisableProtocol("discard", 1); err == nil {
+		t.Error("disabling last enabled protocol version succeeded")
+	}
+	if caps, want := srv.handshake().Caps, []Cap{{"discard", 1}}; !reflect.DeepEqual(caps, want) {
+		t.Errorf("handshake caps mismatch: got %v, want %v", caps, want)
+	}
+	if n := countMatchingProtocols(srv.enabledProtocols(), []Cap{{"discard", 2}}); n != 0 {
+		t.Errorf("disabled protocol version matched %d times", n)
+	}
+	if len(srv.ourHandshake.Caps) != 2 {
+		t.Errorf("base handshake modified: %v", srv.ourHandshake.Caps)
+	}
+	infos := srv.ProtocolInfos()
+	want := []*ProtocolInfo{{
+		Name: "discard",
+		Versions: []*ProtocolVersionInfo{
+			{Version: 2, Length: 1, Enabled: false},
+			{Version: 1, Length: 1, Enabled: true},
+		},
+		Peers: map[string]uint{},
+	}}
+	if !reflect.DeepEqual(infos, want) {
+		t.Errorf("protocol info mismatch: got %+v, want %+v", infos[0], want[0])
+	}
+	if err := srv.EnableProtocol("discard", 2); err != nil {
+		t.Fatalf("couldn't enable protocol: %v", err)
+	}
+	if caps := srv.handshake().Caps; len(caps) != 2 {
+		t.Errorf("handshake caps mismatch after enabling: %v", caps)
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p2p
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/p2p/discover"
+	"github.com/matrix/go-matrix/rlp"
+)
+
+const (
+	captureFileLimit = 64 * 1024 * 1024 // Size after which a capture file is rotated
+	captureFileCount = 8                // Number of capture files kept on disk
+)
+
+// CapturedMsg is a protocol message recorded by a MsgCapture.
+//
+// Only sub-protocol messages are recorded: the RLPx handshake and the base
+// protocol messages carrying the node identities never reach the recorder,
+// and peers are identified by the hash of their node ID.
+type CapturedMsg struct {
+	Time     uint64      // Unix time of the message in nanoseconds
+	Peer     common.Hash // Keccak256 hash of the remote node ID
+	Protocol string      // Name of the sub-protocol
+	Version  uint        // Version of the sub-protocol
+	Inbound  bool        // Whether the message was received from the peer
+	Code     uint64      // Message code relative to the sub-protocol
+	Payload  []byte      // RLP encoded message content
+}
+
+// MsgCapture records the messages of selected sub-protocols to a set of
+// rotating files in a directory, to be read back with ReadCaptureDir.
+type MsgCapture struct {
+	dir       string
+	protocols map[string]bool // Protocols to record, all if empty
+	fileLimit int64
+	fileCount int
+
+	lock   sync.Mutex
+	file   *os.File
+	size   int64
+	failed bool // Set after a write error to only warn once
+}
+
+// NewMsgCapture creates a message recorder writing into dir. Messages of the
+// named protocols are recorded, or of all protocols if none are given. Files
+// are rotated after reaching fileLimit bytes, keeping the newest fileCount.
+func NewMsgCapture(dir string, protocols []string, fileLimit int64, fileCount int) (*MsgCapture, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	c := &MsgCapture{
+		dir:       dir,
+		protocols: make(map[string]bool),
+		fileLimit: fileLimit,
+		fileCount: fileCount,
+	}
+	for _, name := range protocols {
+		c.protocols[name] = true
+	}
+	if err := c.rotate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Wants reports whether messages of the given protocol are recorded.
+func (c *MsgCapture) Wants(protocol string) bool {
+	return len(c.protocols) == 0 || c.protocols[protocol]
+}
+
+// Record appends a message to the current capture file. Failures are logged
+// but never reported, recording must not interfere with the peer.
+func (c *MsgCapture) Record(msg *CapturedMsg) {
+	blob, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		log.Warn("Failed to encode captured message", "protocol", msg.Protocol, "code", msg.Code, "err", err)
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.file == nil {
+		return
+	}
+	if c.size > 0 && c.size+int64(len(blob)) > c.fileLimit {
+		if err := c.rotate(); err != nil {
+			c.fail(err)
+			return
+		}
+	}
+	n, err := c.file.Write(blob)
+	c.size += int64(n)
+	if err != nil {
+		c.fail(err)
+	}
+}
+
+// fail reports a capture write error once.
+func (c *MsgCapture) fail(err error) {
+	if !c.failed {
+		log.Warn("Failed to write message capture", "dir", c.dir, "err", err)
+		c.failed = true
+	}
+}
+
+// rotate closes the current capture file, starts a new one and deletes the
+// oldest files exceeding the retention count.
+func (c *MsgCapture) rotate() error {
+	if c.file != nil {
+		c.file.Close()
+		c.file = nil
+	}
+	file, err := os.OpenFile(filepath.Join(c.dir, fmt.Sprintf("capture-%020d.rlp", time.Now().UnixNano())), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	c.file, c.size = file, 0
+
+	files, err := captureFiles(c.dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > c.fileCount {
+		os.Remove(files[0])
+		files = files[1:]
+	}
+	return nil
+}
+
+// Close flushes and closes the current capture file.
+func (c *MsgCapture) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+// captureFiles returns the capture files in dir, oldest first.
+func captureFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "capture-*.rlp"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ReadCapture decodes all messages of a capture stream.
+func ReadCapture(r io.Reader) ([]*CapturedMsg, error) {
+	var (
+		stream = rlp.NewStream(r, 0)
+		msgs   []*CapturedMsg
+	)
+	for {
+		msg := new(CapturedMsg)
+		if err := stream.Decode(msg); err == io.EOF {
+			return msgs, nil
+		} else if err != nil {
+			// A truncated tail is expected if the node crashed mid-write
+			return msgs, err
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+// ReadCaptureDir decodes the messages of all capture files in dir, in the
+// order they were recorded.
+func ReadCaptureDir(dir string) ([]*CapturedMsg, error) {
+	files, err := captureFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	var msgs []*CapturedMsg
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return msgs, err
+		}
+		part, err := ReadCapture(f)
+		f.Close()
+		msgs = append(msgs, part...)
+		if err != nil {
+			return msgs, fmt.Errorf("%s: %v", filepath.Base(path), err)
+		}
+	}
+	return msgs, nil
+}
+
+// msgRecorder wraps a MsgReadWriter and records every message sent or
+// received through it.
+type msgRecorder struct {
+	MsgReadWriter
+
+	capture *MsgCapture
+	peer    common.Hash
+	proto   Protocol
+}
+
+// newMsgRecorder returns a msgRecorder recording the messages exchanged with
+// the given peer over the given protocol.
+func newMsgRecorder(rw MsgReadWriter, capture *MsgCapture, peerID discover.NodeID, proto Protocol) *msgRecorder {
+	return &msgRecorder{
+		MsgReadWriter: rw,
+		capture:       capture,
+		peer:          crypto.Keccak256Hash(peerID[:]),
+		proto:         proto,
+	}
+}
+
+// ReadMsg reads a message from the underlying MsgReadWriter and records it.
+func (rec *msgRecorder) ReadMsg() (Msg, error) {
+	msg, err := rec.MsgReadWriter.ReadMsg()
+	if err != nil {
+		return msg, err
+	}
+	payload, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return msg, err
+	}
+	msg.Payload = bytes.NewReader(payload)
+	rec.record(true, msg.Code, payload)
+	return msg, nil
+}
+
+// WriteMsg writes a message to the underlying MsgReadWriter and records it.
+func (rec *msgRecorder) WriteMsg(msg Msg) error {
+	payload, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return err
+	}
+	msg.Payload = bytes.NewReader(payload)
+	if err := rec.MsgReadWriter.WriteMsg(msg); err != nil {
+		return err
+	}
+	rec.record(false, msg.Code, payload)
+	return nil
+}
+
+func (rec *msgRecorder) record(inbound bool, code uint64, payload []byte) {
+	rec.capture.Record(&CapturedMsg{
+		Time:     uint64(time.Now().UnixNano()),
+		Peer:     rec.peer,
+		Protocol: rec.proto.Name,
+		Version:  rec.proto.Version,
+		Inbound:  inbound,
+		Code:     code,
+		Payload:  payload,
+	})
+}
+
+// Close closes the underlying MsgReadWriter if it implements the io.Closer
+// interface
+func (rec *msgRecorder) Close() error {
+	if v, ok := rec.MsgReadWriter.(io.Closer); ok {
+		return v.Close()
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p2p
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/p2p/discover"
+	"github.com/matrix/go-matrix/rlp"
+)
+
+func TestMsgCaptureRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	capture, err := NewMsgCapture(dir, nil, 256, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 32; i++ {
+		capture.Record(&CapturedMsg{Protocol: "test", Code: uint64(i), Payload: make([]byte, 64)})
+	}
+	capture.Close()
+
+	files, _ := captureFiles(dir)
+	if len(files) != 2 {
+		t.Fatalf("capture file count mismatch: have %d, want 2", len(files))
+	}
+	msgs, err := ReadCaptureDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read capture: %v", err)
+	}
+	if len(msgs) == 0 || len(msgs) == 32 {
+		t.Fatalf("rotation didn't drop old messages: have %d", len(msgs))
+	}
+	for i, msg := range msgs {
+		if want := uint64(32 - len(msgs) + i); msg.Code != want {
+			t.Errorf("message %d: code mismatch: have %d, want %d", i, msg.Code, want)
+		}
+	}
+}
+
+func TestMsgRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	capture, err := NewMsgCapture(dir, []string{"test"}, captureFileLimit, captureFileCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capture.Wants("other") {
+		t.Errorf("unselected protocol is recorded")
+	}
+	var (
+		id        = discover.NodeID{1}
+		local, rw = MsgPipe()
+		recorder  = newMsgRecorder(local, capture, id, Protocol{Name: "test", Version: 1})
+	)
+	go func() {
+		Send(rw, 1, "ping")
+		ExpectMsg(rw, 2, "pong")
+	}()
+	if err := ExpectMsg(recorder, 1, "ping"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Send(recorder, 2, "pong"); err != nil {
+		t.Fatal(err)
+	}
+	capture.Close()
+
+	msgs, err := ReadCaptureDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read capture: %v", err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("recorded message count mismatch: have %d, want 2", len(msgs))
+	}
+	for i, want := range []struct {
+		inbound bool
+		code    uint64
+		content string
+	}{{true, 1, "ping"}, {false, 2, "pong"}} {
+		msg := msgs[i]
+		if msg.Inbound != want.inbound || msg.Code != want.code || msg.Protocol != "test" || msg.Version != 1 {
+			t.Errorf("message %d mismatch: %+v", i, msg)
+		}
+		if msg.Peer != crypto.Keccak256Hash(id[:]) {
+			t.Errorf("message %d: peer not hashed: %x", i, msg.Peer)
+		}
+		if content := msgContent(t, msg.Payload); content != want.content {
+			t.Errorf("message %d: content mismatch: have %q, want %q", i, content, want.content)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var (
+		peerA = common.Hash{0xa}
+		peerB = common.Hash{0xb}
+		ping  = msgPayload(t, "ping")
+		pong  = msgPayload(t, "pong")
+		msgs  = []*CapturedMsg{
+			{Peer: peerA, Protocol: "test", Version: 1, Inbound: true, Code: 1, Payload: ping},
+			{Peer: peerA, Protocol: "test", Version: 1, Code: 2, Payload: pong},
+			{Peer: peerB, Protocol: "test", Version: 1, Inbound: true, Code: 1, Payload: ping},
+			{Peer: peerB, Protocol: "test", Version: 1, Code: 2, Payload: pong},
+			{Peer: peerA, Protocol: "test", Version: 1, Inbound: true, Code: 1, Payload: ping},
+			{Peer: peerA, Protocol: "test", Version: 1, Code: 2, Payload: pong},
+			{Peer: peerA, Protocol: "other", Version: 1, Inbound: true, Code: 1, Payload: ping},
+		}
+	)
+	// ponger answers every ping, except the second one of each peer if buggy
+	ponger := func(buggy bool) Protocol {
+		return Protocol{Name: "test", Version: 1, Length: 3, Run: func(p *Peer, rw MsgReadWriter) error {
+			for n := 0; ; n++ {
+				msg, err := rw.ReadMsg()
+				if err != nil {
+					return err
+				}
+				msg.Discard()
+				if buggy && n == 1 {
+					continue
+				}
+				if err := Send(rw, 2, "pong"); err != nil {
+					return err
+				}
+			}
+		}}
+	}
+	results := Replay(ponger(false), msgs)
+	if len(results) != 2 || results[0].Peer != peerA || results[1].Peer != peerB {
+		t.Fatalf("replay sessions mismatch: %v", results)
+	}
+	for i, want := range []int{2, 1} {
+		if results[i].Delivered != want {
+			t.Errorf("session %d: delivered %d messages, want %d", i, results[i].Delivered, want)
+		}
+		if results[i].Diverged() {
+			t.Errorf("session %d: diverged: sent %v, recorded %v", i, results[i].Sent, results[i].Recorded)
+		}
+		if results[i].Err != nil {
+			t.Errorf("session %d: handler failed: %v", i, results[i].Err)
+		}
+	}
+	results = Replay(ponger(true), msgs)
+	if !results[0].Diverged() || results[1].Diverged() {
+		t.Errorf("buggy handler divergence mismatch: have %v/%v, want true/false", results[0].Diverged(), results[1].Diverged())
+	}
+}
+
+func msgPayload(t *testing.T, content string) []byte {
+	payload, err := rlp.EncodeToBytes(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+func msgContent(t *testing.T, payload []byte) string {
+	var content string
+	if err := rlp.DecodeBytes(payload, &content); err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
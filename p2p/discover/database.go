@@ -363,6 +363,39 @@ func nextNode(it iterator.Iterator) *Node {
 	return nil
 }
 
+// ResetBonds marks all nodes of the persistent node database at path as
+// having failed findnode, so that they are bonded with again before being
+// queried. This is needed after the local node changed its identity, as the
+// remote nodes do not know the new one. The nodes are kept as seeds. Returns
+// the number of nodes reset.
+func ResetBonds(path string) (int, error) {
+	db, err := newPersistentNodeDB(path, Version, NodeID{})
+	if err != nil {
+		return 0, err
+	}
+	defer db.close()
+
+	var ids []NodeID
+	it := db.lvl.NewIterator(util.BytesPrefix(nodeDBItemPrefix), nil)
+	for it.Next() {
+		if id, field := splitKey(it.Key()); field == nodeDBDiscoverRoot {
+			ids = append(ids, id)
+		}
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		if db.findFails(id) == 0 {
+			if err := db.updateFindFails(id, 1); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(ids), nil
+}
+
 // close flushes and closes the database files.
 func (db *nodeDB) close() {
 	close(db.quit)
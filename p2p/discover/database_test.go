@@ -297,6 +297,62 @@ func TestNodeDBPersistency(t *testing.T) {
 	db.close()
 }
 
+func TestNodeDBResetBonds(t *testing.T) {
+	root, err := ioutil.TempDir("", "nodedb-")
+	if err != nil {
+		t.Fatalf("failed to create temporary data folder: %v", err)
+	}
+	defer os.RemoveAll(root)
+	path := filepath.Join(root, "database")
+
+	db, err := newNodeDB(path, Version, NodeID{})
+	if err != nil {
+		t.Fatalf("failed to create persistent database: %v", err)
+	}
+	for i, seed := range nodeDBSeedQueryNodes {
+		if err := db.updateNode(seed.node); err != nil {
+			t.Fatalf("node %d: failed to insert: %v", i, err)
+		}
+		if err := db.updateBondTime(seed.node.ID, seed.pong); err != nil {
+			t.Fatalf("node %d: failed to insert bondTime: %v", i, err)
+		}
+	}
+	if err := db.updateFindFails(nodeDBSeedQueryNodes[0].node.ID, 3); err != nil {
+		t.Fatalf("failed to update find fails: %v", err)
+	}
+	db.close()
+
+	n, err := ResetBonds(path)
+	if err != nil {
+		t.Fatalf("failed to reset bonds: %v", err)
+	}
+	if n != len(nodeDBSeedQueryNodes) {
+		t.Errorf("reset %d nodes, want %d", n, len(nodeDBSeedQueryNodes))
+	}
+
+	db, err = newNodeDB(path, Version, NodeID{})
+	if err != nil {
+		t.Fatalf("failed to open persistent database: %v", err)
+	}
+	defer db.close()
+	for i, seed := range nodeDBSeedQueryNodes {
+		want := 1
+		if i == 0 {
+			want = 3
+		}
+		if fails := db.findFails(seed.node.ID); fails != want {
+			t.Errorf("node %d: have %d find fails, want %d", i, fails, want)
+		}
+		if db.node(seed.node.ID) == nil {
+			t.Errorf("node %d: not kept", i)
+		}
+	}
+	// the nodes are still usable as seeds
+	if seeds := db.querySeeds(len(nodeDBSeedQueryNodes)*2, time.Hour); len(seeds) == 0 {
+		t.Error("no seeds after reset")
+	}
+}
+
 var nodeDBExpirationNodes = []struct {
 	node *Node
 	pong time.Time
@@ -56,6 +56,12 @@ func checkClockDrift() {
 	}
 }
 
+// ClockDrift queries an NTP server and returns the measured drift of the local
+// clock, positive if the local clock is ahead.
+func ClockDrift() (time.Duration, error) {
+	return sntpDrift(ntpChecks)
+}
+
 // sntpDrift does a naive time resolution against an NTP server and returns the
 // measured drift. This method uses the simple version of NTP. It's not precise
 // but should be fine for these purposes.
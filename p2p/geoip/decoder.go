@@ -0,0 +1,216 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package geoip
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Types of the MaxMind DB data section.
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// maxDepth bounds the nesting of maps and arrays, guarding against corrupt
+// databases referring to themselves.
+const maxDepth = 32
+
+var errTruncated = errors.New("truncated data section")
+
+// decoder reads values of a MaxMind DB data section. Pointers are offsets
+// into the same section.
+type decoder struct {
+	buf []byte
+}
+
+// decode returns the value at offset and the offset following it. Maps decode
+// to map[string]interface{}, arrays to []interface{}, unsigned integers to
+// uint64 (uint128 to []byte), signed ones to int64 and floats to float64.
+func (d *decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, errors.New("data section nested too deep")
+	}
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if typ == typePointer {
+		// size holds the pointer, the value continues after it
+		val, _, err := d.decode(size, depth+1)
+		return val, offset, err
+	}
+	return d.decodeValue(typ, size, offset, depth)
+}
+
+// control parses the control byte(s) at offset, returning the type and size of
+// the value and the offset of its payload. For pointers the size is the target.
+func (d *decoder) control(offset uint) (int, uint, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, 0, 0, errTruncated
+	}
+	ctrl := d.buf[offset]
+	offset++
+
+	typ := int(ctrl >> 5)
+	if typ == typePointer {
+		return d.pointer(ctrl, offset)
+	}
+	if typ == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return 0, 0, 0, errTruncated
+		}
+		typ = 7 + int(d.buf[offset])
+		offset++
+	}
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28 // number of bytes of the size extension
+		if offset+n > uint(len(d.buf)) {
+			return 0, 0, 0, errTruncated
+		}
+		ext := uint(uintFromBytes(d.buf[offset : offset+n]))
+		offset += n
+		switch n {
+		case 1:
+			size = 29 + ext
+		case 2:
+			size = 285 + ext
+		case 3:
+			size = 65821 + ext
+		}
+	}
+	return typ, size, offset, nil
+}
+
+// pointer decodes a pointer whose control byte was ctrl.
+func (d *decoder) pointer(ctrl byte, offset uint) (int, uint, uint, error) {
+	n := uint((ctrl>>3)&0x3) + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, 0, errTruncated
+	}
+	val := uint(uintFromBytes(d.buf[offset : offset+n]))
+	switch n {
+	case 1:
+		val |= uint(ctrl&0x7) << 8
+	case 2:
+		val = (val | uint(ctrl&0x7)<<16) + 2048
+	case 3:
+		val = (val | uint(ctrl&0x7)<<24) + 526336
+	}
+	return typePointer, val, offset + n, nil
+}
+
+func (d *decoder) decodeValue(typ int, size, offset uint, depth int) (interface{}, uint, error) {
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key of type %T", key)
+			}
+			val, next, err := d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[k], offset = val, next
+		}
+		return m, offset, nil
+
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			val, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a, offset = append(a, val), next
+		}
+		return a, offset, nil
+
+	case typeBool:
+		return size != 0, offset, nil
+
+	case typeContainer, typeEndMarker:
+		return nil, 0, fmt.Errorf("unexpected data type %d", typ)
+	}
+	// all other types have a payload of size bytes
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, errTruncated
+	}
+	payload := d.buf[offset : offset+size]
+	offset += size
+
+	switch typ {
+	case typeString:
+		return string(payload), offset, nil
+	case typeBytes, typeUint128:
+		return append([]byte{}, payload...), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("double of size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(payload)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("float of size %d", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(payload))), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("unsigned integer of size %d", size)
+		}
+		return uintFromBytes(payload), offset, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("int32 of size %d", size)
+		}
+		return int64(int32(uint32(uintFromBytes(payload)))), offset, nil
+	}
+	return nil, 0, fmt.Errorf("unknown data type %d", typ)
+}
+
+// uintFromBytes decodes a big endian unsigned integer of up to 8 bytes.
+func uintFromBytes(b []byte) uint64 {
+	var val uint64
+	for _, c := range b {
+		val = val<<8 | uint64(c)
+	}
+	return val
+}
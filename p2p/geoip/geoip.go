@@ -0,0 +1,254 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package geoip locates IP addresses in MaxMind DB (MMDB) files, such as the
+// GeoLite2 country and ASN databases.
+package geoip
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+)
+
+// metadataMarker precedes the metadata section at the end of a database.
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// metadataMaxSize bounds the tail of the file searched for the metadata.
+const metadataMaxSize = 128 * 1024
+
+// dataSectionSeparator is the number of zero bytes between the search tree
+// and the data section.
+const dataSectionSeparator = 16
+
+// Location is what the databases know about an IP address. Fields missing from
+// the databases are left empty.
+type Location struct {
+	Country      string `json:"country,omitempty"`      // ISO 3166-1 country code
+	ASN          uint   `json:"asn,omitempty"`          // Autonomous system number
+	Organization string `json:"organization,omitempty"` // Organization owning the autonomous system
+}
+
+// Reader looks up addresses in a single MaxMind DB file.
+type Reader struct {
+	Type string // Database type from the metadata, e.g. "GeoLite2-Country"
+
+	tree       []byte
+	data       decoder
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	ipv4Start  uint // node the IPv4 addresses start at in an IPv6 tree
+}
+
+// Open reads the MaxMind DB file at path into memory.
+func Open(path string) (*Reader, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return r, nil
+}
+
+// NewReader parses a MaxMind DB held in buf.
+func NewReader(buf []byte) (*Reader, error) {
+	tail := buf
+	if len(tail) > metadataMaxSize {
+		tail = tail[len(tail)-metadataMaxSize:]
+	}
+	pos := bytes.LastIndex(tail, metadataMarker)
+	if pos < 0 {
+		return nil, errors.New("not a MaxMind DB, no metadata found")
+	}
+	metaStart := len(buf) - len(tail) + pos + len(metadataMarker)
+	meta := decoder{buf[metaStart:]}
+	val, _, err := meta.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %v", err)
+	}
+	fields, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid metadata: not a map")
+	}
+	r := &Reader{
+		nodeCount:  uint(uintField(fields, "node_count")),
+		recordSize: uint(uintField(fields, "record_size")),
+		ipVersion:  uint(uintField(fields, "ip_version")),
+	}
+	r.Type, _ = fields["database_type"].(string)
+
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported IP version %d", r.ipVersion)
+	}
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+dataSectionSeparator > uint(metaStart-len(metadataMarker)) {
+		return nil, errors.New("search tree exceeds the database")
+	}
+	r.tree = buf[:treeSize]
+	r.data = decoder{buf[treeSize+dataSectionSeparator : metaStart-len(metadataMarker)]}
+
+	// IPv4 addresses are kept in the ::/96 subtree of IPv6 databases
+	if r.ipVersion == 6 {
+		for i := 0; i < 96 && r.ipv4Start < r.nodeCount; i++ {
+			r.ipv4Start = r.record(r.ipv4Start, 0)
+		}
+	}
+	return r, nil
+}
+
+// uintField returns the unsigned integer field key of m, zero if missing.
+func uintField(m map[string]interface{}, key string) uint64 {
+	n, _ := m[key].(uint64)
+	return n
+}
+
+// record returns the left (bit 0) or right (bit 1) record of a tree node.
+func (r *Reader) record(node uint, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		off := node*6 + bit*3
+		return uint(uintFromBytes(r.tree[off : off+3]))
+	case 28:
+		off := node * 7
+		if bit == 0 {
+			return uint(r.tree[off+3]&0xf0)<<20 | uint(uintFromBytes(r.tree[off:off+3]))
+		}
+		return uint(r.tree[off+3]&0x0f)<<24 | uint(uintFromBytes(r.tree[off+4:off+7]))
+	default:
+		off := node*8 + bit*4
+		return uint(uintFromBytes(r.tree[off : off+4]))
+	}
+}
+
+// Lookup returns the data record of the network ip belongs to, nil if the
+// database has none.
+func (r *Reader) Lookup(ip net.IP) (map[string]interface{}, error) {
+	var (
+		addr = ip.To4()
+		node uint
+	)
+	switch {
+	case addr != nil && r.ipVersion == 6:
+		node = r.ipv4Start
+	case addr == nil && r.ipVersion == 4:
+		return nil, nil // IPv6 addresses are not in IPv4 databases
+	case addr == nil:
+		if addr = ip.To16(); addr == nil {
+			return nil, fmt.Errorf("invalid IP address %v", ip)
+		}
+	}
+	for i := 0; i < len(addr)*8 && node < r.nodeCount; i++ {
+		bit := uint(addr[i/8]>>(7-uint(i%8))) & 1
+		node = r.record(node, bit)
+	}
+	switch {
+	case node == r.nodeCount:
+		return nil, nil // not found
+	case node < r.nodeCount:
+		return nil, errors.New("invalid search tree, no record for address")
+	}
+	offset := node - r.nodeCount - dataSectionSeparator
+	val, _, err := r.data.decode(offset, 0)
+	if err != nil {
+		return nil, err
+	}
+	rec, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("record of type %T", val)
+	}
+	return rec, nil
+}
+
+// DB combines several MaxMind DB files, e.g. a country and an ASN database.
+type DB struct {
+	readers []*Reader
+}
+
+// OpenDB opens the MaxMind DB files at paths.
+func OpenDB(paths []string) (*DB, error) {
+	db := new(DB)
+	for _, path := range paths {
+		r, err := Open(path)
+		if err != nil {
+			return nil, err
+		}
+		db.readers = append(db.readers, r)
+	}
+	return db, nil
+}
+
+// Types returns the database types of the files of db.
+func (db *DB) Types() []string {
+	types := make([]string, len(db.readers))
+	for i, r := range db.readers {
+		types[i] = r.Type
+	}
+	return types
+}
+
+// Locate returns what the databases know about ip. Databases failing the lookup
+// are skipped, the first error is returned along with the partial location.
+func (db *DB) Locate(ip net.IP) (*Location, error) {
+	var (
+		loc      = new(Location)
+		firstErr error
+	)
+	for _, r := range db.readers {
+		rec, err := r.Lookup(ip)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if rec == nil {
+			continue
+		}
+		if loc.Country == "" {
+			loc.Country = countryCode(rec)
+		}
+		if loc.ASN == 0 {
+			loc.ASN = uint(uintField(rec, "autonomous_system_number"))
+			loc.Organization, _ = rec["autonomous_system_organization"].(string)
+		}
+	}
+	return loc, firstErr
+}
+
+// countryCode extracts the ISO code of the country of a record, falling back to
+// the country the network is registered in.
+func countryCode(rec map[string]interface{}) string {
+	for _, key := range []string{"country", "registered_country"} {
+		if country, ok := rec[key].(map[string]interface{}); ok {
+			if code, ok := country["iso_code"].(string); ok && code != "" {
+				return strings.ToUpper(code)
+			}
+		}
+	}
+	return ""
+}
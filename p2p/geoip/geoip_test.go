@@ -0,0 +1,318 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package geoip
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// encodeControl writes the control bytes of a data section value.
+func encodeControl(buf *bytes.Buffer, typ int, size int) {
+	ctrl := byte(typ << 5)
+	if typ > 7 {
+		ctrl = 0
+	}
+	var ext []byte
+	switch {
+	case size < 29:
+		ctrl |= byte(size)
+	case size < 285:
+		ctrl |= 29
+		ext = []byte{byte(size - 29)}
+	case size < 65821:
+		ctrl |= 30
+		ext = []byte{byte((size - 285) >> 8), byte(size - 285)}
+	default:
+		ctrl |= 31
+		ext = []byte{byte((size - 65821) >> 16), byte((size - 65821) >> 8), byte(size - 65821)}
+	}
+	buf.WriteByte(ctrl)
+	if typ > 7 {
+		buf.WriteByte(byte(typ - 7))
+	}
+	buf.Write(ext)
+}
+
+// encodeValue writes a value in the MaxMind DB data section format.
+func encodeValue(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case string:
+		encodeControl(buf, typeString, len(v))
+		buf.WriteString(v)
+	case uint16, uint32, uint64:
+		n := reflect.ValueOf(v).Uint()
+		var b []byte
+		for ; n > 0; n >>= 8 {
+			b = append([]byte{byte(n)}, b...)
+		}
+		typ := map[reflect.Kind]int{reflect.Uint16: typeUint16, reflect.Uint32: typeUint32, reflect.Uint64: typeUint64}[reflect.TypeOf(v).Kind()]
+		encodeControl(buf, typ, len(b))
+		buf.Write(b)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		encodeControl(buf, typeBool, size)
+	case []interface{}:
+		encodeControl(buf, typeArray, len(v))
+		for _, e := range v {
+			encodeValue(buf, e)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		encodeControl(buf, typeMap, len(v))
+		for _, k := range keys {
+			encodeValue(buf, k)
+			encodeValue(buf, v[k])
+		}
+	default:
+		panic("unsupported value")
+	}
+}
+
+// trieNode is a node of the search tree of a test database.
+type trieNode struct {
+	children [2]*trieNode
+	data     int // offset of the record in the data section, -1 for inner nodes
+	id       int
+}
+
+// testNetwork is a network and the record of a test database.
+type testNetwork struct {
+	cidr   string
+	record map[string]interface{}
+}
+
+// buildDB assembles a MaxMind DB holding the given networks. IPv4 networks are
+// stored in the ::/96 subtree of IPv6 databases.
+func buildDB(t *testing.T, ipVersion, recordSize int, networks []testNetwork) []byte {
+	var (
+		data bytes.Buffer
+		root = &trieNode{data: -1}
+	)
+	for _, n := range networks {
+		_, ipnet, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ip := ipnet.IP
+		ones, _ := ipnet.Mask.Size()
+		if ipVersion == 6 {
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, ones = append(make(net.IP, 12), ip4...), ones+96
+			} else {
+				ip = ip.To16()
+			}
+		}
+		node := root
+		for i := 0; i < ones; i++ {
+			bit := (ip[i/8] >> (7 - uint(i%8))) & 1
+			if node.children[bit] == nil {
+				node.children[bit] = &trieNode{data: -1}
+			}
+			node = node.children[bit]
+		}
+		node.data = data.Len()
+		encodeValue(&data, n.record)
+	}
+	// number the inner nodes breadth first
+	var nodes []*trieNode
+	for queue := []*trieNode{root}; len(queue) > 0; queue = queue[1:] {
+		if node := queue[0]; node.data < 0 {
+			node.id = len(nodes)
+			nodes = append(nodes, node)
+			for _, child := range node.children {
+				if child != nil {
+					queue = append(queue, child)
+				}
+			}
+		}
+	}
+	value := func(child *trieNode) uint {
+		switch {
+		case child == nil:
+			return uint(len(nodes))
+		case child.data >= 0:
+			return uint(len(nodes) + dataSectionSeparator + child.data)
+		default:
+			return uint(child.id)
+		}
+	}
+	var db bytes.Buffer
+	for _, node := range nodes {
+		left, right := value(node.children[0]), value(node.children[1])
+		switch recordSize {
+		case 24:
+			db.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			db.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(left>>20)&0xf0 | byte(right>>24)&0x0f, byte(right >> 16), byte(right >> 8), byte(right)})
+		case 32:
+			db.Write([]byte{byte(left >> 24), byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 24), byte(right >> 16), byte(right >> 8), byte(right)})
+		}
+	}
+	db.Write(make([]byte, dataSectionSeparator))
+	db.Write(data.Bytes())
+	db.Write(metadataMarker)
+	encodeValue(&db, map[string]interface{}{
+		"node_count":    uint32(len(nodes)),
+		"record_size":   uint16(recordSize),
+		"ip_version":    uint16(ipVersion),
+		"database_type": "Test-Country-ASN",
+		"languages":     []interface{}{"en"},
+	})
+	return db.Bytes()
+}
+
+var testNetworks = []testNetwork{
+	{"1.2.3.0/24", map[string]interface{}{
+		"country":                        map[string]interface{}{"iso_code": "DE", "geoname_id": uint32(2921044)},
+		"autonomous_system_number":       uint32(64500),
+		"autonomous_system_organization": "Example Networks",
+	}},
+	{"5.6.0.0/16", map[string]interface{}{
+		"registered_country": map[string]interface{}{"iso_code": "sg"},
+		"is_anycast":         true,
+	}},
+	{"2001:db8::/32", map[string]interface{}{
+		"country": map[string]interface{}{"iso_code": "JP"},
+	}},
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want Location
+	}{
+		{"1.2.3.4", Location{Country: "DE", ASN: 64500, Organization: "Example Networks"}},
+		{"5.6.7.8", Location{Country: "SG"}},
+		{"2001:db8::1", Location{Country: "JP"}},
+		{"1.2.4.1", Location{}},
+		{"2001:db9::1", Location{}},
+	}
+	for _, recordSize := range []int{24, 28, 32} {
+		r, err := NewReader(buildDB(t, 6, recordSize, testNetworks))
+		if err != nil {
+			t.Fatalf("record size %d: failed to open database: %v", recordSize, err)
+		}
+		if r.Type != "Test-Country-ASN" {
+			t.Errorf("record size %d: database type mismatch: have %q", recordSize, r.Type)
+		}
+		db := &DB{readers: []*Reader{r}}
+		for _, tt := range tests {
+			loc, err := db.Locate(net.ParseIP(tt.ip))
+			if err != nil {
+				t.Errorf("record size %d, %s: lookup failed: %v", recordSize, tt.ip, err)
+				continue
+			}
+			if *loc != tt.want {
+				t.Errorf("record size %d, %s: location mismatch: have %+v, want %+v", recordSize, tt.ip, *loc, tt.want)
+			}
+		}
+	}
+}
+
+// Tests that the locations of several databases are merged, the first one
+// knowing a field taking precedence.
+func TestOpenDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	country := buildDB(t, 6, 24, []testNetwork{
+		{"10.0.0.0/8", map[string]interface{}{"country": map[string]interface{}{"iso_code": "FR"}}},
+	})
+	asn := buildDB(t, 4, 28, []testNetwork{
+		{"10.1.0.0/16", map[string]interface{}{"autonomous_system_number": uint32(65001), "autonomous_system_organization": "Test AS"}},
+		{"10.2.0.0/16", map[string]interface{}{"country": map[string]interface{}{"iso_code": "US"}}},
+	})
+	paths := []string{filepath.Join(dir, "country.mmdb"), filepath.Join(dir, "asn.mmdb")}
+	ioutil.WriteFile(paths[0], country, 0600)
+	ioutil.WriteFile(paths[1], asn, 0600)
+
+	db, err := OpenDB(paths)
+	if err != nil {
+		t.Fatalf("failed to open databases: %v", err)
+	}
+	if loc, err := db.Locate(net.ParseIP("10.1.2.3")); err != nil || *loc != (Location{Country: "FR", ASN: 65001, Organization: "Test AS"}) {
+		t.Errorf("location mismatch: have %+v, %v", loc, err)
+	}
+	if loc, err := db.Locate(net.ParseIP("10.2.2.3")); err != nil || loc.Country != "FR" {
+		t.Errorf("location mismatch: have %+v, %v", loc, err)
+	}
+	// IPv6 addresses are not in IPv4 databases
+	if loc, err := db.Locate(net.ParseIP("::1")); err != nil || *loc != (Location{}) {
+		t.Errorf("location mismatch: have %+v, %v", loc, err)
+	}
+	if _, err := OpenDB([]string{filepath.Join(dir, "missing.mmdb")}); err == nil {
+		t.Error("missing database opened")
+	}
+}
+
+func TestInvalidDB(t *testing.T) {
+	valid := buildDB(t, 6, 24, testNetworks)
+	tests := map[string][]byte{
+		"empty":     nil,
+		"no marker": valid[:len(valid)/2],
+		"truncated": valid[len(valid)-40:],
+	}
+	for name, buf := range tests {
+		if _, err := NewReader(buf); err == nil {
+			t.Errorf("%s: invalid database opened", name)
+		}
+	}
+}
+
+func TestDecodePointer(t *testing.T) {
+	// a map whose value is a pointer to the string at offset 0
+	var buf bytes.Buffer
+	encodeValue(&buf, "shared")
+	start := uint(buf.Len())
+	encodeControl(&buf, typeMap, 1)
+	encodeValue(&buf, "key")
+	buf.Write([]byte{typePointer << 5, 0})
+
+	d := decoder{buf.Bytes()}
+	val, next, err := d.decode(start, 0)
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if !reflect.DeepEqual(val, map[string]interface{}{"key": "shared"}) {
+		t.Errorf("value mismatch: have %v", val)
+	}
+	if next != uint(buf.Len()) {
+		t.Errorf("next offset mismatch: have %d, want %d", next, buf.Len())
+	}
+	// a pointer to itself must not loop forever
+	d = decoder{[]byte{typePointer << 5, 0}}
+	if _, _, err := d.decode(0, 0); err == nil {
+		t.Error("self referencing pointer decoded")
+	}
+}
@@ -66,8 +66,8 @@ func (l *Linker) Start() {
 	defer func() {
 		l.sub.Unsubscribe()
 
+		// quit is kept open, the linker is started again with the server
 		close(l.roleChan)
-		close(l.quit)
 	}()
 
 	l.roleChan = make(chan mc.BlockToLinker)
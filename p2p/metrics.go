@@ -29,6 +29,8 @@ var (
 	ingressTrafficMeter = metrics.NewRegisteredMeter("p2p/InboundTraffic", nil)
 	egressConnectMeter  = metrics.NewRegisteredMeter("p2p/OutboundConnects", nil)
 	egressTrafficMeter  = metrics.NewRegisteredMeter("p2p/OutboundTraffic", nil)
+
+	protocolPanicCounter = metrics.NewRegisteredCounter("p2p/ProtocolPanics", nil)
 )
 
 // meteredConn is a wrapper around a network TCP connection that meters both the
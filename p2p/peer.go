@@ -20,13 +20,16 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"runtime/debug"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/matrix/go-matrix/common/mclock"
 	"github.com/matrix/go-matrix/event"
 	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/metrics"
 	"github.com/matrix/go-matrix/p2p/discover"
 	"github.com/matrix/go-matrix/rlp"
 )
@@ -95,6 +98,10 @@ type PeerEvent struct {
 
 // Peer represents a connected remote node.
 type Peer struct {
+	// accessed atomically, kept first for 64 bit alignment
+	pingSent int64 // time of the unanswered ping in unix nanoseconds, 0 if none
+	latency  int64 // round trip time of the last answered ping in nanoseconds
+
 	msgReadWriter MsgReadWriter
 
 	rw      *conn
@@ -109,6 +116,9 @@ type Peer struct {
 
 	// events receives message send / receive events if set
 	events *event.Feed
+
+	// capture records the messages of selected protocols if set
+	capture *MsgCapture
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -160,6 +170,12 @@ func (p *Peer) String() string {
 	return fmt.Sprintf("Peer %x %v", p.rw.id[:8], p.RemoteAddr())
 }
 
+// Latency returns the round trip time of the last ping answered by the peer,
+// zero until the first pong arrives.
+func (p *Peer) Latency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&p.latency))
+}
+
 // Inbound returns true if the peer is an inbound connection
 func (p *Peer) Inbound() bool {
 	return p.rw.flags&inboundConn != 0
@@ -245,6 +261,7 @@ func (p *Peer) pingLoop() {
 	for {
 		select {
 		case <-ping.C:
+			atomic.StoreInt64(&p.pingSent, time.Now().UnixNano())
 			if err := SendItems(p.rw, pingMsg); err != nil {
 				p.protoErr <- err
 				return
@@ -272,11 +289,21 @@ func (p *Peer) readLoop(errc chan<- error) {
 	}
 }
 
-func (p *Peer) handle(msg Msg) error {
+func (p *Peer) handle(msg Msg) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = p.protocolPanic("p2p", r)
+		}
+	}()
 	switch {
 	case msg.Code == pingMsg:
 		msg.Discard()
 		go SendItems(p.rw, pongMsg)
+	case msg.Code == pongMsg:
+		if sent := atomic.SwapInt64(&p.pingSent, 0); sent != 0 {
+			atomic.StoreInt64(&p.latency, msg.ReceivedAt.UnixNano()-sent)
+		}
+		return msg.Discard()
 	case msg.Code == discMsg:
 		var reason [1]DiscReason
 		// This is the last message. We don't need to discard or
@@ -350,10 +377,13 @@ func (p *Peer) startProtocols(writeStart <-chan struct{}, writeErr chan<- error)
 		if p.events != nil {
 			rw = newMsgEventer(rw, p.events, p.ID(), proto.Name)
 		}
+		if p.capture != nil && p.capture.Wants(proto.Name) {
+			rw = newMsgRecorder(rw, p.capture, p.ID(), proto.Protocol)
+		}
 		p.msgReadWriter = rw
 		p.log.Trace(fmt.Sprintf("Starting protocol %s/%d", proto.Name, proto.Version))
 		go func() {
-			err := proto.Run(p, rw)
+			err := p.runProtocol(proto, rw)
 			if err == nil {
 				p.log.Trace(fmt.Sprintf("Protocol %s/%d returned", proto.Name, proto.Version))
 				err = errProtocolReturned
@@ -366,6 +396,27 @@ func (p *Peer) startProtocols(writeStart <-chan struct{}, writeErr chan<- error)
 	}
 }
 
+// runProtocol runs the handler of a protocol for the peer. A panic of the
+// handler, e.g. on a malformed message, is turned into a protocol error so
+// only the offending peer gets disconnected instead of the whole node crashing.
+func (p *Peer) runProtocol(proto *protoRW, rw MsgReadWriter) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = p.protocolPanic(proto.Name, r)
+		}
+	}()
+	return proto.Run(p, rw)
+}
+
+// protocolPanic records a recovered panic of a protocol handler and returns
+// the error the peer is dropped with.
+func (p *Peer) protocolPanic(proto string, r interface{}) error {
+	protocolPanicCounter.Inc(1)
+	metrics.GetOrRegisterCounter("p2p/ProtocolPanics/"+proto, nil).Inc(1)
+	p.log.Error("Protocol handler panicked, dropping peer", "protocol", proto, "err", r, "stack", string(debug.Stack()))
+	return newPeerError(errProtocolPanic, "%s: %v", proto, r)
+}
+
 // getProto finds the protocol responsible for handling
 // the given message code.
 func (p *Peer) getProto(code uint64) (*protoRW, error) {
@@ -429,6 +480,7 @@ type PeerInfo struct {
 		Inbound       bool   `json:"inbound"`
 		Trusted       bool   `json:"trusted"`
 		Static        bool   `json:"static"`
+		Latency       string `json:"latency,omitempty"` // Round trip time of the last ping
 	} `json:"network"`
 	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
 }
@@ -452,6 +504,9 @@ func (p *Peer) Info() *PeerInfo {
 	info.Network.Inbound = p.rw.is(inboundConn)
 	info.Network.Trusted = p.rw.is(trustedConn)
 	info.Network.Static = p.rw.is(staticDialedConn)
+	if latency := p.Latency(); latency > 0 {
+		info.Network.Latency = latency.String()
+	}
 
 	// Gather all the running protocol infos
 	for _, proto := range p.running {
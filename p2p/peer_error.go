@@ -24,11 +24,13 @@ import (
 const (
 	errInvalidMsgCode = iota
 	errInvalidMsg
+	errProtocolPanic
 )
 
 var errorToString = map[int]string{
 	errInvalidMsgCode: "invalid message code",
 	errInvalidMsg:     "invalid message",
+	errProtocolPanic:  "protocol handler panicked",
 }
 
 type peerError struct {
@@ -109,7 +111,7 @@ func discReasonForError(err error) DiscReason {
 	peerError, ok := err.(*peerError)
 	if ok {
 		switch peerError.code {
-		case errInvalidMsgCode, errInvalidMsg:
+		case errInvalidMsgCode, errInvalidMsg, errProtocolPanic:
 			return DiscProtocolError
 		default:
 			return DiscSubprotocolError
@@ -22,6 +22,7 @@ import (
 	"math/rand"
 	"net"
 	"reflect"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -120,6 +121,40 @@ func TestPeerProtoEncodeMsg(t *testing.T) {
 	}
 }
 
+func TestPeerProtoPanic(t *testing.T) {
+	proto := Protocol{
+		Name:   "a",
+		Length: 2,
+		Run: func(peer *Peer, rw MsgReadWriter) error {
+			msg, err := rw.ReadMsg()
+			if err != nil {
+				return err
+			}
+			var truncated []uint
+			msg.Decode(&truncated)
+			_ = truncated[3] // simulates a handler trusting a malformed message
+			return nil
+		},
+	}
+	closer, rw, _, errc := testPeer([]Protocol{proto})
+	defer closer()
+
+	Send(rw, baseProtocolLength, []uint{1})
+
+	select {
+	case err := <-errc:
+		perr, ok := err.(*peerError)
+		if !ok || perr.code != errProtocolPanic {
+			t.Fatalf("peer returned error %v, want protocol panic", err)
+		}
+		if reason := discReasonForError(err); reason != DiscProtocolError {
+			t.Errorf("disconnect reason mismatch: have %v, want %v", reason, DiscProtocolError)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("peer not dropped after handler panic")
+	}
+}
+
 func TestPeerPing(t *testing.T) {
 	closer, rw, _, _ := testPeer(nil)
 	defer closer()
@@ -131,6 +166,37 @@ func TestPeerPing(t *testing.T) {
 	}
 }
 
+func TestPeerLatency(t *testing.T) {
+	closer, rw, peer, _ := testPeer(nil)
+	defer closer()
+
+	// pong sends a pong, followed by a ping whose answer ensures the peer
+	// handled the pong
+	pong := func() {
+		if err := SendItems(rw, pongMsg); err != nil {
+			t.Fatal(err)
+		}
+		if err := SendItems(rw, pingMsg); err != nil {
+			t.Fatal(err)
+		}
+		if err := ExpectMsg(rw, pongMsg, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pong()
+	if latency := peer.Latency(); latency != 0 {
+		t.Errorf("unsolicited pong measured latency %v", latency)
+	}
+	atomic.StoreInt64(&peer.pingSent, time.Now().Add(-50*time.Millisecond).UnixNano())
+	pong()
+	if latency := peer.Latency(); latency < 50*time.Millisecond || latency > time.Second {
+		t.Errorf("latency mismatch: have %v, want ~50ms", latency)
+	}
+	if info := peer.Info(); info.Network.Latency == "" {
+		t.Error("latency missing from peer info")
+	}
+}
+
 func TestPeerDisconnect(t *testing.T) {
 	closer, rw, _, disc := testPeer(nil)
 	defer closer()
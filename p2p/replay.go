@@ -0,0 +1,159 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p2p
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/p2p/discover"
+)
+
+// replaySettle is how long a replayed handler may stay silent after the last
+// recorded message before its session is closed.
+const replaySettle = 100 * time.Millisecond
+
+// ReplayResult summarises the replay of the messages recorded for one peer.
+type ReplayResult struct {
+	Peer      common.Hash // Hash of the recorded peer's node ID
+	Delivered int         // Number of recorded inbound messages fed to the handler
+	Sent      []uint64    // Codes of the messages sent by the replayed handler
+	Recorded  []uint64    // Codes of the messages originally sent to the peer
+	Err       error       // Error returned by the protocol handler
+}
+
+// Diverged reports whether the replayed handler sent different messages than
+// the ones recorded.
+func (r *ReplayResult) Diverged() bool {
+	if len(r.Sent) != len(r.Recorded) {
+		return true
+	}
+	for i := range r.Sent {
+		if r.Sent[i] != r.Recorded[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Replay feeds captured messages back into a protocol handler, so problems seen
+// on a live node can be reproduced offline. Every recorded peer of the protocol
+// gets its own session in which its inbound messages are delivered in their
+// original order, while the messages the handler sends are collected for
+// comparison with the recorded ones.
+func Replay(proto Protocol, msgs []*CapturedMsg) []*ReplayResult {
+	var (
+		order    []common.Hash
+		sessions = make(map[common.Hash][]*CapturedMsg)
+	)
+	for _, msg := range msgs {
+		if msg.Protocol != proto.Name || msg.Version != proto.Version {
+			continue
+		}
+		if _, ok := sessions[msg.Peer]; !ok {
+			order = append(order, msg.Peer)
+		}
+		sessions[msg.Peer] = append(sessions[msg.Peer], msg)
+	}
+	results := make([]*ReplayResult, 0, len(order))
+	for _, peer := range order {
+		results = append(results, replaySession(proto, peer, sessions[peer]))
+	}
+	return results
+}
+
+// replaySession runs the protocol handler against the messages of one peer.
+func replaySession(proto Protocol, peer common.Hash, msgs []*CapturedMsg) *ReplayResult {
+	result := &ReplayResult{Peer: peer}
+
+	var id discover.NodeID
+	copy(id[:], peer[:])
+	app, net := MsgPipe()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- proto.Run(NewPeer(id, "replay", []Cap{proto.cap()}), app)
+		app.Close()
+	}()
+	sent := make(chan uint64)
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		for {
+			msg, err := net.ReadMsg()
+			if err != nil {
+				return
+			}
+			msg.Discard()
+			sent <- msg.Code
+		}
+	}()
+	collect := func(code uint64) { result.Sent = append(result.Sent, code) }
+
+	// Deliver the recorded inbound messages, collecting the responses meanwhile
+	feed := make(chan error, 1)
+	go func() {
+		for _, msg := range msgs {
+			if !msg.Inbound {
+				continue
+			}
+			err := net.WriteMsg(Msg{Code: msg.Code, Size: uint32(len(msg.Payload)), Payload: bytes.NewReader(msg.Payload), ReceivedAt: time.Unix(0, int64(msg.Time))})
+			if err != nil {
+				feed <- err
+				return
+			}
+			result.Delivered++
+		}
+		feed <- nil
+	}()
+	for feeding := true; feeding; {
+		select {
+		case code := <-sent:
+			collect(code)
+		case <-feed:
+			feeding = false
+		}
+	}
+	// Let the handler answer the last messages before ending the session
+	for settled := false; !settled; {
+		select {
+		case code := <-sent:
+			collect(code)
+		case <-time.After(replaySettle):
+			settled = true
+		}
+	}
+	net.Close()
+	for running := true; running; {
+		select {
+		case code := <-sent:
+			collect(code)
+		case <-drained:
+			running = false
+		}
+	}
+	if err := <-done; err != ErrPipeClosed {
+		result.Err = err
+	}
+	for _, msg := range msgs {
+		if !msg.Inbound {
+			result.Recorded = append(result.Recorded, msg.Code)
+		}
+	}
+	return result
+}
@@ -123,6 +123,11 @@ type Config struct {
 	// the server is started.
 	ListenAddr string
 
+	// ExtraListenAddrs are additional addresses the server accepts incoming
+	// connections on, e.g. a VPN interface or an IPv6 address next to the
+	// primary one. The advertised node record always uses ListenAddr.
+	ExtraListenAddrs []string `toml:",omitempty"`
+
 	// If set to a non-nil value, the given NAT port mapper
 	// is used to make the listening port available to the
 	// Internet.
@@ -139,6 +144,15 @@ type Config struct {
 	// whenever a message is sent to or received from a peer
 	EnableMsgEvents bool
 
+	// CaptureDir, if set, is the directory where the messages exchanged with
+	// peers are recorded for offline replay. Files are rotated, keeping only
+	// the most recent traffic.
+	CaptureDir string `toml:",omitempty"`
+
+	// CaptureProtocols restricts the recording to the named sub-protocols.
+	// All sub-protocols are recorded if empty.
+	CaptureProtocols []string `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 }
@@ -160,6 +174,12 @@ type Server struct {
 	listener     net.Listener
 	ourHandshake *protoHandshake
 	lastLookup   time.Time
+
+	extraListeners []net.Listener // Listeners opened on ExtraListenAddrs
+	capture        *MsgCapture    // Message recorder if CaptureDir is set
+
+	capsLock     sync.RWMutex     // Protects disabledCaps
+	disabledCaps map[Cap]struct{} // Protocol versions not offered to new peers
 	DiscV5       *discv5.Network
 
 	// These are for Peers, PeerCount (and nothing else).
@@ -284,6 +304,25 @@ func (srv *Server) PeerCount() int {
 	return count
 }
 
+// SetMaxPeers changes the maximum number of connected peers. Lowering the limit
+// doesn't drop any peers, the excess drains away as connections close. The
+// number of dialed connections stays at the share computed on startup.
+func (srv *Server) SetMaxPeers(n int) {
+	srv.lock.Lock()
+	if !srv.running {
+		srv.MaxPeers = n
+		srv.lock.Unlock()
+		return
+	}
+	srv.lock.Unlock()
+
+	select {
+	case srv.peerOp <- func(map[discover.NodeID]*Peer) { srv.MaxPeers = n }:
+		<-srv.peerOpDone
+	case <-srv.quit:
+	}
+}
+
 // AddPeer connects to the given node and maintains the connection until the
 // server is shut down. If the connection fails for any reason, the server will
 // attempt to reconnect the peer.
@@ -351,8 +390,16 @@ func (srv *Server) Stop() {
 		// this unblocks listener Accept
 		srv.listener.Close()
 	}
+	for _, listener := range srv.extraListeners {
+		listener.Close()
+	}
+	srv.extraListeners = nil
 	close(srv.quit)
 	srv.loopWG.Wait()
+	if srv.capture != nil {
+		srv.capture.Close()
+		srv.capture = nil
+	}
 }
 
 // sharedUDPConn implements a shared connection. Write sends messages to the underlying connection while read returns
@@ -416,6 +463,13 @@ func (srv *Server) Start() (err error) {
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
 
+	if srv.CaptureDir != "" {
+		if srv.capture, err = NewMsgCapture(srv.CaptureDir, srv.CaptureProtocols, captureFileLimit, captureFileCount); err != nil {
+			return err
+		}
+		srv.log.Warn("Recording peer messages", "dir", srv.CaptureDir, "protocols", srv.CaptureProtocols)
+	}
+
 	var (
 		conn      *net.UDPConn
 		sconn     *sharedUDPConn
@@ -530,7 +584,22 @@ func (srv *Server) startListening() error {
 	srv.ListenAddr = laddr.String()
 	srv.listener = listener
 	srv.loopWG.Add(1)
-	go srv.listenLoop()
+	go srv.listenLoop(listener)
+
+	// Launch any additional listeners, without NAT mapping
+	for _, addr := range srv.ExtraListenAddrs {
+		extra, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range append(srv.extraListeners, listener) {
+				l.Close()
+			}
+			srv.extraListeners = nil
+			return err
+		}
+		srv.extraListeners = append(srv.extraListeners, extra)
+		srv.loopWG.Add(1)
+		go srv.listenLoop(extra)
+	}
 	// Map the TCP listening port if NAT is configured.
 	if !laddr.IP.IsLoopback() && srv.NAT != nil {
 		srv.loopWG.Add(1)
@@ -649,12 +718,13 @@ running:
 			err := srv.protoHandshakeChecks(peers, inboundCount, c)
 			if err == nil {
 				// The handshakes are done and it passed all checks.
-				p := newPeer(c, srv.Protocols)
+				p := newPeer(c, srv.enabledProtocols())
 				// If message events are enabled, pass the peerFeed
 				// to the peer
 				if srv.EnableMsgEvents {
 					p.events = &srv.peerFeed
 				}
+				p.capture = srv.capture
 				name := truncateName(c.name)
 				srv.log.Debug("Adding p2p peer", "name", name, "addr", c.fd.RemoteAddr(), "peers", len(peers)+1)
 				go srv.runPeer(p)
@@ -710,7 +780,7 @@ running:
 
 func (srv *Server) protoHandshakeChecks(peers map[discover.NodeID]*Peer, inboundCount int, c *conn) error {
 	// Drop connections with no matching protocols.
-	if len(srv.Protocols) > 0 && countMatchingProtocols(srv.Protocols, c.caps) == 0 {
+	if len(srv.Protocols) > 0 && countMatchingProtocols(srv.enabledProtocols(), c.caps) == 0 {
 		return DiscUselessPeer
 	}
 	// Repeat the encryption handshake checks because the
@@ -754,9 +824,13 @@ type tempError interface {
 
 // listenLoop runs in its own goroutine and accepts
 // inbound connections.
-func (srv *Server) listenLoop() {
+func (srv *Server) listenLoop(listener net.Listener) {
 	defer srv.loopWG.Done()
-	srv.log.Info("RLPx listener up", "self", srv.makeSelf(srv.listener, srv.ntab))
+	if listener == srv.listener {
+		srv.log.Info("RLPx listener up", "self", srv.makeSelf(srv.listener, srv.ntab))
+	} else {
+		srv.log.Info("Additional RLPx listener up", "addr", listener.Addr())
+	}
 
 	tokens := defaultMaxPendingPeers
 	if srv.MaxPendingPeers > 0 {
@@ -776,7 +850,7 @@ func (srv *Server) listenLoop() {
 			err error
 		)
 		for {
-			fd, err = srv.listener.Accept()
+			fd, err = listener.Accept()
 			if tempErr, ok := err.(tempError); ok && tempErr.Temporary() {
 				srv.log.Debug("Temporary read error", "err", err)
 				continue
@@ -849,7 +923,7 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *discover.Node) e
 		return err
 	}
 	// Run the protocol handshake
-	phs, err := c.doProtoHandshake(srv.ourHandshake)
+	phs, err := c.doProtoHandshake(srv.handshake())
 	if err != nil {
 		clog.Trace("Failed proto handshake", "err", err)
 		return err
@@ -122,6 +122,52 @@ func TestServerListen(t *testing.T) {
 	}
 }
 
+// Tests that connections are accepted on the additional listen addresses too.
+func TestServerExtraListeners(t *testing.T) {
+	accepted := make(chan net.Conn, 1)
+	srv := &Server{
+		Config: Config{
+			PrivateKey:       newkey(),
+			ListenAddr:       "127.0.0.1:0",
+			ExtraListenAddrs: []string{"127.0.0.1:0"},
+		},
+		newTransport: func(fd net.Conn) transport {
+			accepted <- fd
+			return newTestTransport(randomID(), fd)
+		},
+		quit: make(chan struct{}),
+		log:  log.New(),
+	}
+	if err := srv.startListening(); err != nil {
+		t.Fatalf("could not start listeners: %v", err)
+	}
+	if len(srv.extraListeners) != 1 {
+		t.Fatalf("extra listener count mismatch: have %d, want 1", len(srv.extraListeners))
+	}
+	extra := srv.extraListeners[0].Addr().String()
+	defer func() {
+		srv.listener.Close()
+		srv.extraListeners[0].Close()
+		close(srv.quit)
+		srv.loopWG.Wait()
+	}()
+
+	conn, err := net.DialTimeout("tcp", extra, 5*time.Second)
+	if err != nil {
+		t.Fatalf("could not dial extra listener: %v", err)
+	}
+	defer conn.Close()
+
+	select {
+	case fd := <-accepted:
+		if fd.LocalAddr().String() != extra {
+			t.Errorf("connection accepted on wrong address: have %v, want %v", fd.LocalAddr(), extra)
+		}
+	case <-time.After(time.Second):
+		t.Error("extra listener did not accept within one second")
+	}
+}
+
 func TestServerDial(t *testing.T) {
 	// run a one-shot TCP server to handle the connection.
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
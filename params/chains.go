@@ -0,0 +1,91 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package params
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Currency describes the native currency of a chain.
+type Currency struct {
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+}
+
+// ChainInfo is the human-readable description of a MATRIX network, used by
+// wallets to configure themselves for the node they are connected to.
+type ChainInfo struct {
+	Name      string   `json:"name"`
+	NetworkId uint64   `json:"networkId"` // Network ID the peers of the chain handshake with (net_version)
+	ChainId   uint64   `json:"chainId"`   // Chain ID transactions are signed for (eth_chainId)
+	Currency  Currency `json:"currency"`
+	Explorer  string   `json:"explorer,omitempty"` // Block explorer URL, if any
+}
+
+// MAN is the native currency of the MATRIX networks.
+var MAN = Currency{Name: "MATRIX", Symbol: "MAN", Decimals: 18}
+
+var (
+	chainsLock sync.RWMutex
+	chains     = map[uint64]*ChainInfo{
+		1:    {Name: "MATRIX Mainnet", NetworkId: 1, ChainId: MainnetChainConfig.ChainId.Uint64(), Currency: MAN},
+		3:    {Name: "MATRIX Testnet", NetworkId: 3, ChainId: TestnetChainConfig.ChainId.Uint64(), Currency: MAN},
+		4:    {Name: "MATRIX Rinkeby", NetworkId: 4, ChainId: RinkebyChainConfig.ChainId.Uint64(), Currency: MAN},
+		1337: {Name: "MATRIX Developer", NetworkId: 1337, ChainId: AllCliqueProtocolChanges.ChainId.Uint64(), Currency: MAN},
+	}
+)
+
+// RegisterChain adds a network to the chain registry, replacing any chain
+// known with the same network ID.
+func RegisterChain(info ChainInfo) error {
+	if info.Name == "" {
+		return fmt.Errorf("chain with network ID %d has no name", info.NetworkId)
+	}
+	chainsLock.Lock()
+	defer chainsLock.Unlock()
+
+	chains[info.NetworkId] = &info
+	return nil
+}
+
+// ChainByNetworkId returns the registered chain with the given network ID,
+// or false if the network is unknown.
+func ChainByNetworkId(networkId uint64) (ChainInfo, bool) {
+	chainsLock.RLock()
+	defer chainsLock.RUnlock()
+
+	info, ok := chains[networkId]
+	if !ok {
+		return ChainInfo{}, false
+	}
+	return *info, true
+}
+
+// Chains returns all registered chains ordered by network ID.
+func Chains() []ChainInfo {
+	chainsLock.RLock()
+	defer chainsLock.RUnlock()
+
+	list := make([]ChainInfo, 0, len(chains))
+	for _, info := range chains {
+		list = append(list, *info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].NetworkId < list[j].NetworkId })
+	return list
+}
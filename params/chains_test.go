@@ -0,0 +1,55 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package params
+
+import "testing"
+
+func TestChainRegistry(t *testing.T) {
+	for _, config := range []struct {
+		networkId uint64
+		chain     *ChainConfig
+	}{{1, MainnetChainConfig}, {3, TestnetChainConfig}, {4, RinkebyChainConfig}} {
+		info, ok := ChainByNetworkId(config.networkId)
+		if !ok {
+			t.Fatalf("network %d not registered", config.networkId)
+		}
+		if info.ChainId != config.chain.ChainId.Uint64() {
+			t.Errorf("network %d: chain ID mismatch: have %d, want %d", config.networkId, info.ChainId, config.chain.ChainId)
+		}
+		if info.Currency.Symbol != "MAN" {
+			t.Errorf("network %d: currency mismatch: have %s, want MAN", config.networkId, info.Currency.Symbol)
+		}
+	}
+	if _, ok := ChainByNetworkId(4242); ok {
+		t.Fatal("unexpected chain for unregistered network")
+	}
+	if err := RegisterChain(ChainInfo{NetworkId: 4242}); err == nil {
+		t.Fatal("expected error registering chain without name")
+	}
+	custom := ChainInfo{Name: "MATRIX Local", NetworkId: 4242, ChainId: 4242, Currency: MAN, Explorer: "http://localhost:8000"}
+	if err := RegisterChain(custom); err != nil {
+		t.Fatalf("failed to register chain: %v", err)
+	}
+	if info, ok := ChainByNetworkId(4242); !ok || info != custom {
+		t.Fatalf("registered chain mismatch: have %+v, want %+v", info, custom)
+	}
+	chains := Chains()
+	for i := 1; i < len(chains); i++ {
+		if chains[i-1].NetworkId >= chains[i].NetworkId {
+			t.Fatalf("chains not ordered by network ID: %d before %d", chains[i-1].NetworkId, chains[i].NetworkId)
+		}
+	}
+}
@@ -85,16 +85,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, common.Hash{}, new(EthashConfig), nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Matrix core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, common.Hash{}, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, common.Hash{}, new(EthashConfig), nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -120,6 +120,13 @@ type ChainConfig struct {
 
 	ByzantiumBlock      *big.Int `json:"byzantiumBlock,omitempty"`      // Byzantium switch block (nil = no fork, 0 = already on byzantium)
 	ConstantinopleBlock *big.Int `json:"constantinopleBlock,omitempty"` // Constantinople switch block (nil = no fork, 0 = already activated)
+	DynamicFeeBlock     *big.Int `json:"dynamicFeeBlock,omitempty"`     // Dynamic fee switch block (nil = no fork, 0 = already activated)
+
+	// KZG enables the polynomial commitment precompiles for data availability
+	// experiments. Nodes must load the trusted setup whose verification points
+	// hash to KZGSetupHash.
+	KZGBlock     *big.Int    `json:"kzgBlock,omitempty"`     // KZG precompiles switch block (nil = no fork, 0 = already activated)
+	KZGSetupHash common.Hash `json:"kzgSetupHash,omitempty"` // Hash of the trusted setup verification points
 
 	// Various consensus engines
 	Ethash *EthashConfig `json:"manash,omitempty"`
@@ -156,7 +163,7 @@ func (c *ChainConfig) String() string {
 	default:
 		engine = "unknown"
 	}
-	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Engine: %v}",
+	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v DynamicFee: %v KZG: %v Engine: %v}",
 		c.ChainId,
 		c.HomesteadBlock,
 		c.DAOForkBlock,
@@ -166,6 +173,8 @@ func (c *ChainConfig) String() string {
 		c.EIP158Block,
 		c.ByzantiumBlock,
 		c.ConstantinopleBlock,
+		c.DynamicFeeBlock,
+		c.KZGBlock,
 		engine,
 	)
 }
@@ -200,6 +209,16 @@ func (c *ChainConfig) IsConstantinople(num *big.Int) bool {
 	return isForked(c.ConstantinopleBlock, num)
 }
 
+// IsDynamicFee returns whether num is either equal to the dynamic fee fork block or greater.
+func (c *ChainConfig) IsDynamicFee(num *big.Int) bool {
+	return isForked(c.DynamicFeeBlock, num)
+}
+
+// IsKZG returns whether num is either equal to the KZG precompiles fork block or greater.
+func (c *ChainConfig) IsKZG(num *big.Int) bool {
+	return isForked(c.KZGBlock, num)
+}
+
 // GasTable returns the gas table corresponding to the current phase (homestead or homestead reprice).
 //
 // The returned GasTable's fields shouldn't, under any circumstances, be changed.
@@ -263,9 +282,45 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.ConstantinopleBlock, newcfg.ConstantinopleBlock, head) {
 		return newCompatError("Constantinople fork block", c.ConstantinopleBlock, newcfg.ConstantinopleBlock)
 	}
+	if isForkIncompatible(c.DynamicFeeBlock, newcfg.DynamicFeeBlock, head) {
+		return newCompatError("Dynamic fee fork block", c.DynamicFeeBlock, newcfg.DynamicFeeBlock)
+	}
+	if isForkIncompatible(c.KZGBlock, newcfg.KZGBlock, head) {
+		return newCompatError("KZG fork block", c.KZGBlock, newcfg.KZGBlock)
+	}
+	if c.IsKZG(head) && c.KZGSetupHash != newcfg.KZGSetupHash {
+		return newCompatError("KZG trusted setup", c.KZGBlock, newcfg.KZGBlock)
+	}
 	return nil
 }
 
+// Fork is a change of the chain rules scheduled at a block.
+type Fork struct {
+	Name  string   `json:"name"`
+	Block *big.Int `json:"block"` // nil if the fork is not scheduled
+}
+
+// Active returns whether the fork is active at the given block.
+func (f Fork) Active(num *big.Int) bool {
+	return isForked(f.Block, num)
+}
+
+// Forks returns the forks of the chain in the order they must activate,
+// including the ones not scheduled on this chain.
+func (c *ChainConfig) Forks() []Fork {
+	return []Fork{
+		{"homestead", c.HomesteadBlock},
+		{"daoFork", c.DAOForkBlock},
+		{"eip150", c.EIP150Block},
+		{"eip155", c.EIP155Block},
+		{"eip158", c.EIP158Block},
+		{"byzantium", c.ByzantiumBlock},
+		{"constantinople", c.ConstantinopleBlock},
+		{"dynamicFee", c.DynamicFeeBlock},
+		{"kzg", c.KZGBlock},
+	}
+}
+
 // isForkIncompatible returns true if a fork scheduled at s1 cannot be rescheduled to
 // block s2 because head is already past the fork.
 func isForkIncompatible(s1, s2, head *big.Int) bool {
@@ -329,7 +384,7 @@ func (err *ConfigCompatError) Error() string {
 type Rules struct {
 	ChainId                                   *big.Int
 	IsHomestead, IsEIP150, IsEIP155, IsEIP158 bool
-	IsByzantium                               bool
+	IsByzantium, IsKZG                        bool
 }
 
 func (c *ChainConfig) Rules(num *big.Int) Rules {
@@ -337,5 +392,5 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 	if chainId == nil {
 		chainId = new(big.Int)
 	}
-	return Rules{ChainId: new(big.Int).Set(chainId), IsHomestead: c.IsHomestead(num), IsEIP150: c.IsEIP150(num), IsEIP155: c.IsEIP155(num), IsEIP158: c.IsEIP158(num), IsByzantium: c.IsByzantium(num)}
+	return Rules{ChainId: new(big.Int).Set(chainId), IsHomestead: c.IsHomestead(num), IsEIP150: c.IsEIP150(num), IsEIP155: c.IsEIP155(num), IsEIP158: c.IsEIP158(num), IsByzantium: c.IsByzantium(num), IsKZG: c.IsKZG(num)}
 }
@@ -79,3 +79,20 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+func TestForks(t *testing.T) {
+	config := &ChainConfig{HomesteadBlock: big.NewInt(0), EIP150Block: big.NewInt(10), ByzantiumBlock: big.NewInt(20)}
+	active := make(map[string]bool)
+	for _, fork := range config.Forks() {
+		active[fork.Name] = fork.Active(big.NewInt(15))
+	}
+	want := map[string]bool{"homestead": true, "eip150": true}
+	for name, on := range active {
+		if on != want[name] {
+			t.Errorf("fork %s: have active %v at block 15, want %v", name, on, want[name])
+		}
+	}
+	if len(active) != len(config.Forks()) {
+		t.Errorf("duplicate fork names in %v", config.Forks())
+	}
+}
@@ -30,6 +30,10 @@ const (
 	MinGasLimit          uint64 = 5000    // Minimum the gas limit may ever be.
 	GenesisGasLimit      uint64 = 4712388 // Gas limit of the Genesis block.
 
+	InitialBaseFee           uint64 = 1000000000 // Base fee of the first block after the dynamic fee fork.
+	BaseFeeChangeDenominator uint64 = 8          // Bounds the amount the base fee can change between blocks.
+	ElasticityMultiplier     uint64 = 2          // Bounds the maximum gas limit a block may use relative to its gas target.
+
 	MaximumExtraDataSize  uint64 = 32    // Maximum size extra data may be after Genesis.
 	ExpByteGas            uint64 = 10    // Times ceil(log256(exponent)) for the EXP instruction.
 	SloadGas              uint64 = 50    // Multiplied by the number of 32-byte words that are copied (round up) for any *COPY operation and added.
@@ -80,6 +84,7 @@ const (
 	Bn256ScalarMulGas       uint64 = 40000  // Gas needed for an elliptic curve scalar multiplication
 	Bn256PairingBaseGas     uint64 = 100000 // Base price for an elliptic curve pairing check
 	Bn256PairingPerPointGas uint64 = 80000  // Per-point price for an elliptic curve pairing check
+	KZGPointEvaluationGas   uint64 = 340000 // Gas needed to verify a KZG opening proof (two pairings, two scalar multiplications)
 
 	//YY
 	TxCount              uint64 = 1000                  //一对多交易最多可以支持1000笔(包括扩展之外的那一个交易)
@@ -36,10 +36,15 @@ var Version = func() string {
 	return v
 }()
 
-func VersionWithCommit(gitCommit string) string {
+// VersionWithCommit returns the version string extended with the short commit
+// hash and the commit date of the build, if known.
+func VersionWithCommit(gitCommit, gitDate string) string {
 	vsn := Version
 	if len(gitCommit) >= 8 {
 		vsn += "-" + gitCommit[:8]
 	}
+	if gitDate != "" {
+		vsn += "-" + gitDate
+	}
 	return vsn
 }
@@ -81,12 +81,19 @@ type Decoder interface {
 // error if there are too few or too many elements.
 //
 // The decoding of struct fields honours certain struct tags, "tail",
-// "nil" and "-".
+// "optional", "nil" and "-".
 //
 // The "-" tag ignores fields.
 //
 // For an explanation of "tail", see the example.
 //
+// The "optional" tag allows trailing fields to be absent from the input
+// list, in which case they are set to their zero value. Once a field is
+// optional, all subsequent fields must be optional too. When encoding,
+// trailing optional fields holding their zero value are left out, which
+// lets new fields be appended to a struct without changing the encoding
+// of existing values.
+//
 // The "nil" tag applies to pointer-typed fields and changes the decoding
 // rules for the field such that input values of size zero decode as a nil
 // pointer. This tag can be useful when decoding recursive types.
@@ -438,9 +445,17 @@ func makeStructDecoder(typ reflect.Type) (decoder, error) {
 		if _, err := s.List(); err != nil {
 			return wrapStreamError(err, typ)
 		}
-		for _, f := range fields {
+		for i, f := range fields {
 			err := f.info.decoder(s, val.Field(f.index))
 			if err == EOL {
+				if f.optional {
+					// The remaining fields are optional as well, zero them
+					for _, rest := range fields[i:] {
+						rv := val.Field(rest.index)
+						rv.Set(reflect.Zero(rv.Type()))
+					}
+					break
+				}
 				return &decodeError{msg: "too few elements", typ: typ}
 			} else if err != nil {
 				return addErrorContext(err, "."+typ.Field(f.index).Name)
@@ -529,10 +529,31 @@ func makeStructWriter(typ reflect.Type) (writer, error) {
 	if err != nil {
 		return nil, err
 	}
+	firstOptional := firstOptionalField(fields)
+	if firstOptional == len(fields) {
+		writer := func(val reflect.Value, w *encbuf) error {
+			lh := w.list()
+			for _, f := range fields {
+				if err := f.info.writer(val.Field(f.index), w); err != nil {
+					return err
+				}
+			}
+			w.listEnd(lh)
+			return nil
+		}
+		return writer, nil
+	}
+	// Trailing optional fields holding their zero value are omitted
 	writer := func(val reflect.Value, w *encbuf) error {
+		last := len(fields) - 1
+		for ; last >= firstOptional; last-- {
+			if !isZeroField(val.Field(fields[last].index)) {
+				break
+			}
+		}
 		lh := w.list()
-		for _, f := range fields {
-			if err := f.info.writer(val.Field(f.index), w); err != nil {
+		for i := 0; i <= last; i++ {
+			if err := fields[i].info.writer(val.Field(fields[i].index), w); err != nil {
 				return err
 			}
 		}
@@ -542,6 +563,15 @@ func makeStructWriter(typ reflect.Type) (writer, error) {
 	return writer, nil
 }
 
+// isZeroField reports whether an optional struct field holds its zero value.
+func isZeroField(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Interface, reflect.Map:
+		return v.IsNil()
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
 func makePtrWriter(typ reflect.Type) (writer, error) {
 	etypeinfo, err := cachedTypeInfo1(typ.Elem(), tags{})
 	if err != nil {
@@ -0,0 +1,271 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/metrics"
+)
+
+// apiKeyHeader is the HTTP header carrying the API key of a request. Clients
+// unable to set headers (e.g. browser websockets) may use the apikey URL query
+// parameter instead.
+const apiKeyHeader = "X-API-Key"
+
+// apiKeyContextKey is the context key of the API key presented by a client.
+type apiKeyContextKey struct{}
+
+// APIKey describes the access a gateway client is granted.
+type APIKey struct {
+	Name      string   `json:"name"`                // Label used in logs and metrics
+	Key       string   `json:"key"`                 // Secret presented by the client
+	Methods   []string `json:"methods,omitempty"`   // Allowed methods ("man_call") or namespaces ("man"), empty = all
+	RateLimit float64  `json:"rateLimit,omitempty"` // Sustained requests per second, zero = unlimited
+	Burst     int      `json:"burst,omitempty"`     // Requests allowed at once, defaults to one second worth of the rate
+}
+
+// APIKeyUsage holds the usage counters of an API key.
+type APIKeyUsage struct {
+	Name     string `json:"name"`
+	Requests uint64 `json:"requests"` // Requests served
+	Denied   uint64 `json:"denied"`   // Requests for methods the key doesn't allow
+	Limited  uint64 `json:"limited"`  // Requests rejected by the rate limit
+}
+
+// apiKeyState tracks the rate limit and usage of a single key.
+type apiKeyState struct {
+	APIKey
+	methods map[string]bool
+
+	tokens float64   // Requests left in the current burst
+	last   time.Time // Last time the bucket was refilled
+	usage  APIKeyUsage
+
+	requestMeter metrics.Meter
+	deniedMeter  metrics.Meter
+	limitedMeter metrics.Meter
+}
+
+// APIKeyGate authorizes requests of public RPC endpoints by API key, enforcing
+// per key method allow-lists and rate limits.
+type APIKeyGate struct {
+	lock sync.Mutex
+	keys map[string]*apiKeyState // Key states indexed by secret
+}
+
+// NewAPIKeyGate creates a gate admitting the given keys.
+func NewAPIKeyGate(keys []APIKey) (*APIKeyGate, error) {
+	gate := &APIKeyGate{keys: make(map[string]*apiKeyState)}
+	for _, key := range keys {
+		if err := gate.Add(key); err != nil {
+			return nil, err
+		}
+	}
+	return gate, nil
+}
+
+// LoadAPIKeys reads a JSON list of API keys from a file.
+func LoadAPIKeys(path string) ([]APIKey, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(blob, &keys); err != nil {
+		return nil, fmt.Errorf("invalid API key file %s: %v", path, err)
+	}
+	return keys, nil
+}
+
+// Add admits a new key, replacing any key with the same name.
+func (g *APIKeyGate) Add(key APIKey) error {
+	if key.Name == "" || key.Key == "" {
+		return errors.New("API key needs a name and a secret")
+	}
+	if key.RateLimit < 0 || key.Burst < 0 {
+		return errors.New("negative API key rate limit")
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if existing, ok := g.keys[key.Key]; ok && existing.Name != key.Name {
+		return fmt.Errorf("API key secret already used by %q", existing.Name)
+	}
+	g.remove(key.Name)
+
+	state := &apiKeyState{
+		APIKey:       key,
+		methods:      make(map[string]bool),
+		last:         time.Now(),
+		usage:        APIKeyUsage{Name: key.Name},
+		requestMeter: metrics.GetOrRegisterMeter("rpc/apikey/"+key.Name+"/requests", nil),
+		deniedMeter:  metrics.GetOrRegisterMeter("rpc/apikey/"+key.Name+"/denied", nil),
+		limitedMeter: metrics.GetOrRegisterMeter("rpc/apikey/"+key.Name+"/limited", nil),
+	}
+	for _, method := range key.Methods {
+		state.methods[method] = true
+	}
+	state.tokens = state.burst()
+	g.keys[key.Key] = state
+	return nil
+}
+
+// Remove revokes the key with the given name, reporting whether it existed.
+func (g *APIKeyGate) Remove(name string) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return g.remove(name)
+}
+
+func (g *APIKeyGate) remove(name string) bool {
+	for secret, state := range g.keys {
+		if state.Name == name {
+			delete(g.keys, secret)
+			return true
+		}
+	}
+	return false
+}
+
+// Keys returns the admitted keys, sorted by name.
+func (g *APIKeyGate) Keys() []APIKey {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	keys := make([]APIKey, 0, len(g.keys))
+	for _, state := range g.keys {
+		keys = append(keys, state.APIKey)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	return keys
+}
+
+// Usage returns the usage counters of the admitted keys, sorted by name.
+func (g *APIKeyGate) Usage() []APIKeyUsage {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	usage := make([]APIKeyUsage, 0, len(g.keys))
+	for _, state := range g.keys {
+		usage = append(usage, state.usage)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Name < usage[j].Name })
+	return usage
+}
+
+// authorize checks whether the key presented with a request may call method.
+func (g *APIKeyGate) authorize(ctx context.Context, method string) Error {
+	secret, _ := ctx.Value(apiKeyContextKey{}).(string)
+	if secret == "" {
+		return &unauthorizedError{"missing API key"}
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	state, ok := g.keys[secret]
+	if !ok {
+		return &unauthorizedError{"invalid API key"}
+	}
+	if !state.allows(method) {
+		state.usage.Denied++
+		state.deniedMeter.Mark(1)
+		return &unauthorizedError{fmt.Sprintf("method %s not allowed for API key %s", method, state.Name)}
+	}
+	if !state.take(time.Now()) {
+		state.usage.Limited++
+		state.limitedMeter.Mark(1)
+		return &limitExceededError{fmt.Sprintf("rate limit of API key %s exceeded", state.Name)}
+	}
+	state.usage.Requests++
+	state.requestMeter.Mark(1)
+	return nil
+}
+
+// allows tells whether the key may call method, listed either by full name or
+// by namespace.
+func (s *apiKeyState) allows(method string) bool {
+	if len(s.methods) == 0 || s.methods[method] {
+		return true
+	}
+	if sep := strings.Index(method, serviceMethodSeparator); sep > 0 {
+		return s.methods[method[:sep]]
+	}
+	return false
+}
+
+// burst returns the number of requests the key may issue at once.
+func (s *apiKeyState) burst() float64 {
+	if s.Burst > 0 {
+		return float64(s.Burst)
+	}
+	if s.RateLimit < 1 {
+		return 1
+	}
+	return s.RateLimit
+}
+
+// take refills the key's token bucket and consumes a request from it.
+func (s *apiKeyState) take(now time.Time) bool {
+	if s.RateLimit == 0 {
+		return true
+	}
+	s.tokens += now.Sub(s.last).Seconds() * s.RateLimit
+	if burst := s.burst(); s.tokens > burst {
+		s.tokens = burst
+	}
+	s.last = now
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
+
+// SetAPIKeyGate installs a gate every request must pass. A nil gate admits all
+// requests.
+func (s *Server) SetAPIKeyGate(gate *APIKeyGate) {
+	s.gate.Store(gate)
+}
+
+// apiKeyGate returns the installed API key gate, if any.
+func (s *Server) apiKeyGate() *APIKeyGate {
+	gate, _ := s.gate.Load().(*APIKeyGate)
+	return gate
+}
+
+// withAPIKey stores the API key presented with an HTTP or websocket request in
+// the request context.
+func withAPIKey(ctx context.Context, r *http.Request) context.Context {
+	key := r.Header.Get(apiKeyHeader)
+	if key == "" {
+		key = r.URL.Query().Get("apikey")
+	}
+	if key == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, apiKeyContextKey{}, key)
+}
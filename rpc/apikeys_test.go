@@ -0,0 +1,119 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// gatedCall issues a request against an HTTP endpoint, presenting the given API
+// key, and returns the error code of the reply (zero on success).
+func gatedCall(t *testing.T, url, key, method string) int {
+	body, _ := json.Marshal(map[string]interface{}{"id": 1, "jsonrpc": "2.0", "method": method, "params": []string{"a"}})
+	req, _ := http.NewRequest("POST", url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(apiKeyHeader, key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var reply struct {
+		Error *jsonError `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Error == nil {
+		return 0
+	}
+	return reply.Error.Code
+}
+
+func TestAPIKeyGate(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+
+	if err := server.RegisterName("test", new(CacheService)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterName("other", new(CacheService)); err != nil {
+		t.Fatal(err)
+	}
+	gate, err := NewAPIKeyGate([]APIKey{
+		{Name: "full", Key: "secret-full"},
+		{Name: "limited", Key: "secret-limited", Methods: []string{"test"}, RateLimit: 0.001, Burst: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetAPIKeyGate(gate)
+
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+
+	// Requests without a valid key are rejected
+	if code := gatedCall(t, httpsrv.URL, "", "test_count"); code != -32002 {
+		t.Errorf("missing key: have code %d, want -32002", code)
+	}
+	if code := gatedCall(t, httpsrv.URL, "secret-bogus", "test_count"); code != -32002 {
+		t.Errorf("invalid key: have code %d, want -32002", code)
+	}
+	// Unrestricted keys may call anything, restricted ones only their namespaces
+	if code := gatedCall(t, httpsrv.URL, "secret-full", "other_count"); code != 0 {
+		t.Errorf("full key: have code %d, want success", code)
+	}
+	if code := gatedCall(t, httpsrv.URL, "secret-limited", "other_count"); code != -32002 {
+		t.Errorf("disallowed namespace: have code %d, want -32002", code)
+	}
+	// The burst is served, after which the rate limit kicks in
+	for i := 0; i < 2; i++ {
+		if code := gatedCall(t, httpsrv.URL, "secret-limited", "test_count"); code != 0 {
+			t.Errorf("request %d within burst: have code %d, want success", i, code)
+		}
+	}
+	if code := gatedCall(t, httpsrv.URL, "secret-limited", "test_count"); code != -32005 {
+		t.Errorf("request over limit: have code %d, want -32005", code)
+	}
+	want := []APIKeyUsage{
+		{Name: "full", Requests: 1},
+		{Name: "limited", Requests: 2, Denied: 1, Limited: 1},
+	}
+	usage := gate.Usage()
+	if len(usage) != len(want) {
+		t.Fatalf("usage length mismatch: have %d, want %d", len(usage), len(want))
+	}
+	for i := range want {
+		if usage[i] != want[i] {
+			t.Errorf("usage %d mismatch: have %+v, want %+v", i, usage[i], want[i])
+		}
+	}
+	// Revoked keys are rejected, secrets can't be shared between names
+	gate.Remove("full")
+	if code := gatedCall(t, httpsrv.URL, "secret-full", "test_count"); code != -32002 {
+		t.Errorf("revoked key: have code %d, want -32002", code)
+	}
+	if err := gate.Add(APIKey{Name: "copy", Key: "secret-limited"}); err == nil {
+		t.Error("duplicate secret accepted")
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package rpc
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/common/math"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/metrics"
+)
+
+var attestMeter = metrics.NewRegisteredMeter("rpc/attest", nil)
+
+var (
+	errAttestationMismatch = errors.New("attestation hash mismatch")
+	errAttestationMissing  = errors.New("response carries no attestation")
+)
+
+// Attestation is the signature a node attaches to selected replies, letting
+// light clients and aggregators tell which node vouched for a result and
+// detect replies that were tampered with on the way.
+type Attestation struct {
+	Signer    common.Address `json:"signer"`
+	Hash      common.Hash    `json:"hash"`
+	Signature hexutil.Bytes  `json:"signature"`
+}
+
+// attestationDomain separates attestation signatures from any other message
+// signed with the same key.
+const attestationDomain = "matrix rpc attestation"
+
+// ResponseSigner signs the replies of a configured set of methods with a
+// dedicated attestation key.
+type ResponseSigner struct {
+	key     *ecdsa.PrivateKey
+	signer  common.Address
+	chainID *big.Int
+	methods map[string]bool
+}
+
+// NewResponseSigner creates a signer attesting the replies of the given methods
+// (full names, e.g. "man_getBlockByHash") on the given chain with key.
+func NewResponseSigner(key *ecdsa.PrivateKey, chainID *big.Int, methods []string) *ResponseSigner {
+	if chainID == nil {
+		chainID = new(big.Int)
+	}
+	s := &ResponseSigner{
+		key:     key,
+		signer:  crypto.PubkeyToAddress(key.PublicKey),
+		chainID: new(big.Int).Set(chainID),
+		methods: make(map[string]bool),
+	}
+	for _, method := range methods {
+		s.methods[method] = true
+	}
+	return s
+}
+
+// Address returns the address replies are attested by.
+func (s *ResponseSigner) Address() common.Address {
+	return s.signer
+}
+
+// Covers tells whether replies of the given method are attested.
+func (s *ResponseSigner) Covers(method string) bool {
+	return s.methods[method]
+}
+
+// attest signs the JSON encoded result of a call.
+func (s *ResponseSigner) attest(method string, params, result json.RawMessage) (*Attestation, error) {
+	hash := attestationHash(s.chainID, method, params, result)
+	sig, err := crypto.Sign(hash[:], s.key)
+	if err != nil {
+		return nil, err
+	}
+	return &Attestation{Signer: s.signer, Hash: hash, Signature: sig}, nil
+}
+
+// attestationHash binds a result to the call it answers on the given chain.
+// Parameters and result are hashed in their compact JSON encoding, so replies
+// stay verifiable whatever whitespace the client sent.
+func attestationHash(chainID *big.Int, method string, params, result json.RawMessage) common.Hash {
+	return crypto.Keccak256Hash(
+		[]byte(attestationDomain), []byte{0},
+		math.PaddedBigBytes(chainID, 32),
+		[]byte(method), []byte{0},
+		compactJSON(params), []byte{0},
+		compactJSON(result),
+	)
+}
+
+// compactJSON returns the compact encoding of a JSON value, or the value as is
+// if it is not valid JSON.
+func compactJSON(value json.RawMessage) []byte {
+	compact := new(bytes.Buffer)
+	if err := json.Compact(compact, value); err != nil {
+		return value
+	}
+	return compact.Bytes()
+}
+
+// VerifyAttestation checks that att signs the given call and result on the
+// given chain, returning the address of the attesting node.
+func VerifyAttestation(chainID *big.Int, method string, params, result json.RawMessage, att *Attestation) (common.Address, error) {
+	if att == nil {
+		return common.Address{}, errAttestationMissing
+	}
+	if chainID == nil {
+		chainID = new(big.Int)
+	}
+	hash := attestationHash(chainID, method, params, result)
+	if hash != att.Hash {
+		return common.Address{}, errAttestationMismatch
+	}
+	pub, err := crypto.SigToPub(hash[:], att.Signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	signer := crypto.PubkeyToAddress(*pub)
+	if signer != att.Signer {
+		return common.Address{}, fmt.Errorf("attestation signed by %x, not %x", signer, att.Signer)
+	}
+	return signer, nil
+}
+
+// SetSigner installs a signer attesting the replies of its configured methods.
+// A nil signer disables attestation.
+func (s *Server) SetSigner(signer *ResponseSigner) {
+	s.signer.Store(signer)
+}
+
+// responseSigner returns the installed response signer, if any.
+func (s *Server) responseSigner() *ResponseSigner {
+	signer, _ := s.signer.Load().(*ResponseSigner)
+	return signer
+}
+
+// createResponse assembles the success reply of a regular call, attaching an
+// attestation if the method is covered by the installed signer.
+func (s *Server) createResponse(codec ServerCodec, req *serverRequest, result interface{}) interface{} {
+	resp := codec.CreateResponse(req.id, result)
+	signer := s.responseSigner()
+	if signer == nil || !signer.Covers(req.method) {
+		return resp
+	}
+	success, ok := resp.(*jsonSuccessResponse)
+	if !ok {
+		return resp
+	}
+	encoded, err := json.Marshal(success.Result)
+	if err != nil {
+		return resp
+	}
+	params, _ := req.params.(json.RawMessage)
+	att, err := signer.attest(req.method, params, encoded)
+	if err != nil {
+		return resp
+	}
+	attestMeter.Mark(1)
+	return &jsonSuccessResponse{Version: success.Version, Id: success.Id, Result: json.RawMessage(encoded), Attestation: att}
+}
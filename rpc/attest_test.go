@@ -0,0 +1,96 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package rpc
+
+import (
+	"encoding/json"
+	"math/big"
+	"net"
+	"testing"
+
+	"github.com/matrix/go-matrix/crypto"
+)
+
+// attestedReply is a success response with its result kept in raw form.
+type attestedReply struct {
+	Result      json.RawMessage `json:"result"`
+	Attestation *Attestation    `json:"attestation"`
+}
+
+func callRaw(t *testing.T, server *Server, method string, params json.RawMessage) attestedReply {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	request := map[string]interface{}{"id": 1, "jsonrpc": "2.0", "method": method, "params": params}
+	if err := json.NewEncoder(clientConn).Encode(request); err != nil {
+		t.Fatal(err)
+	}
+	var reply attestedReply
+	if err := json.NewDecoder(clientConn).Decode(&reply); err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+func TestResponseAttestation(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	chainID := big.NewInt(1)
+	signer := NewResponseSigner(key, chainID, []string{"test_count"})
+
+	server := NewServer()
+	if err := server.RegisterName("test", new(CacheService)); err != nil {
+		t.Fatal(err)
+	}
+	server.SetSigner(signer)
+
+	// Covered methods carry a verifiable attestation
+	params := json.RawMessage(`["a"]`)
+	reply := callRaw(t, server, "test_count", params)
+	if reply.Attestation == nil {
+		t.Fatal("covered method not attested")
+	}
+	addr, err := VerifyAttestation(chainID, "test_count", params, reply.Result, reply.Attestation)
+	if err != nil {
+		t.Fatalf("attestation rejected: %v", err)
+	}
+	if addr != signer.Address() {
+		t.Errorf("signer mismatch: have %x, want %x", addr, signer.Address())
+	}
+	// Parameters are compared in their compact encoding
+	if _, err := VerifyAttestation(chainID, "test_count", json.RawMessage(`[ "a" ]`), reply.Result, reply.Attestation); err != nil {
+		t.Errorf("attestation rejected for reformatted params: %v", err)
+	}
+	// Tampering with the result, the call or the chain is detected
+	if _, err := VerifyAttestation(chainID, "test_count", params, json.RawMessage("42"), reply.Attestation); err == nil {
+		t.Error("tampered result accepted")
+	}
+	if _, err := VerifyAttestation(chainID, "test_count", json.RawMessage(`["b"]`), reply.Result, reply.Attestation); err == nil {
+		t.Error("attestation accepted for other params")
+	}
+	if _, err := VerifyAttestation(big.NewInt(2), "test_count", params, reply.Result, reply.Attestation); err == nil {
+		t.Error("attestation accepted on another chain")
+	}
+	// Other methods and servers without signer reply as before
+	if reply := callRaw(t, server, "test_missing", nil); reply.Attestation != nil {
+		t.Error("uncovered method attested")
+	}
+	server.SetSigner(nil)
+	if reply := callRaw(t, server, "test_count", params); reply.Attestation != nil {
+		t.Error("reply attested after removing the signer")
+	}
+}
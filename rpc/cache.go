@@ -0,0 +1,173 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package rpc
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hashicorp/golang-lru"
+	"github.com/matrix/go-matrix/metrics"
+)
+
+var (
+	cacheHitMeter  = metrics.NewRegisteredMeter("rpc/cache/hit", nil)
+	cacheMissMeter = metrics.NewRegisteredMeter("rpc/cache/miss", nil)
+)
+
+// CacheMode tells the response cache for how long a reply stays valid.
+type CacheMode int
+
+const (
+	// CacheNone disables caching of the reply.
+	CacheNone CacheMode = iota
+
+	// CacheUntilHead caches the reply until the next Purge, which the owner
+	// of the cache issues whenever the chain head changes.
+	CacheUntilHead
+
+	// CachePinned caches the reply until it is evicted by newer entries,
+	// for results that can never change (e.g. blocks by hash).
+	CachePinned
+)
+
+// CachePolicy decides whether the reply to a call may be cached, given the
+// full method name (e.g. "man_getBlockByNumber") and the raw JSON parameters.
+// Along with the mode it returns a tag folded into the cache key, binding the
+// reply to the state it was computed against (e.g. the canonical hash of a
+// block referenced by number), so that a reply is never served once the tag
+// of the same call changes. The tag may be empty.
+type CachePolicy func(method string, params json.RawMessage) (CacheMode, string)
+
+// ResponseCache is an in-memory cache of RPC replies, shared between all the
+// servers of a node. Only successful replies of methods accepted by the policy
+// are cached; head dependent entries are dropped on Purge.
+type ResponseCache struct {
+	policy     CachePolicy
+	pinned     *lru.Cache   // entries surviving head changes
+	volatile   *lru.Cache   // entries dropped on every Purge
+	generation uint64       // incremented on every Purge, atomically accessed
+	lock       sync.RWMutex // protects the policy and orders Purge against stores
+}
+
+// NewResponseCache creates a cache holding up to size entries in each of its
+// pinned and head dependent tiers. Nothing is cached until a policy is set.
+func NewResponseCache(size int) (*ResponseCache, error) {
+	pinned, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	volatile, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &ResponseCache{pinned: pinned, volatile: volatile}, nil
+}
+
+// SetPolicy installs the policy deciding which calls are cacheable.
+func (c *ResponseCache) SetPolicy(policy CachePolicy) {
+	c.lock.Lock()
+	c.policy = policy
+	c.lock.Unlock()
+}
+
+// Purge drops all head dependent entries, to be called on every new head.
+func (c *ResponseCache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	atomic.AddUint64(&c.generation, 1)
+	c.volatile.Purge()
+}
+
+// Len returns the number of cached replies.
+func (c *ResponseCache) Len() int {
+	return c.pinned.Len() + c.volatile.Len()
+}
+
+// cacheTicket identifies a cacheable call while it is being executed.
+type cacheTicket struct {
+	key        string
+	mode       CacheMode
+	generation uint64
+}
+
+// lookup returns the cached reply of a call if present. Otherwise, for
+// cacheable calls, it returns a ticket to store the reply with once computed.
+func (c *ResponseCache) lookup(method string, params interface{}) (interface{}, *cacheTicket) {
+	c.lock.RLock()
+	policy := c.policy
+	c.lock.RUnlock()
+
+	if policy == nil {
+		return nil, nil
+	}
+	var raw json.RawMessage
+	switch p := params.(type) {
+	case json.RawMessage:
+		raw = p
+	case nil:
+	default:
+		return nil, nil
+	}
+	mode, tag := policy(method, raw)
+	if mode == CacheNone {
+		return nil, nil
+	}
+	key := method + "\x00" + string(raw) + "\x00" + tag
+	ticket := &cacheTicket{key: key, mode: mode, generation: atomic.LoadUint64(&c.generation)}
+
+	tier := c.pinned
+	if mode == CacheUntilHead {
+		tier = c.volatile
+	}
+	if result, ok := tier.Get(key); ok {
+		cacheHitMeter.Mark(1)
+		return result, nil
+	}
+	cacheMissMeter.Mark(1)
+	return nil, ticket
+}
+
+// store caches the reply of a call unless the head changed since the ticket
+// was issued, in which case a head dependent reply may already be stale.
+func (c *ResponseCache) store(ticket *cacheTicket, result interface{}) {
+	switch ticket.mode {
+	case CachePinned:
+		c.pinned.Add(ticket.key, result)
+	case CacheUntilHead:
+		c.lock.RLock()
+		if atomic.LoadUint64(&c.generation) == ticket.generation {
+			c.volatile.Add(ticket.key, result)
+		}
+		c.lock.RUnlock()
+	}
+}
+
+// isCacheable reports whether a reply may be cached. Empty replies (e.g. an
+// unknown block) are never cached since they may be filled in later.
+func isCacheable(reply reflect.Value) bool {
+	switch reply.Kind() {
+	case reflect.Invalid:
+		return false
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return !reply.IsNil()
+	}
+	return true
+}
@@ -0,0 +1,167 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type CacheService struct {
+	calls int
+}
+
+func (s *CacheService) Count(tag string) int {
+	s.calls++
+	return s.calls
+}
+
+func (s *CacheService) Missing() *Result {
+	s.calls++
+	return nil
+}
+
+func newCacheTestClient(t *testing.T, policy CachePolicy) (*Client, *ResponseCache) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(CacheService)); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := NewResponseCache(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.SetPolicy(policy)
+	server.SetCache(cache)
+
+	return DialInProc(server), cache
+}
+
+func callCount(t *testing.T, client *Client, tag string) int {
+	var result int
+	if err := client.Call(&result, "test_count", tag); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestResponseCachePinned(t *testing.T) {
+	client, cache := newCacheTestClient(t, func(method string, params json.RawMessage) (CacheMode, string) {
+		if method != "test_count" {
+			t.Errorf("unexpected method name %q", method)
+		}
+		return CachePinned, ""
+	})
+	defer client.Close()
+
+	if n := callCount(t, client, "a"); n != 1 {
+		t.Fatalf("first call: have %d, want 1", n)
+	}
+	if n := callCount(t, client, "a"); n != 1 {
+		t.Fatalf("cached call: have %d, want 1", n)
+	}
+	if n := callCount(t, client, "b"); n != 2 {
+		t.Fatalf("call with other params: have %d, want 2", n)
+	}
+	cache.Purge()
+	if n := callCount(t, client, "a"); n != 1 {
+		t.Fatalf("pinned call after purge: have %d, want 1", n)
+	}
+}
+
+func TestResponseCacheTag(t *testing.T) {
+	tag := "a"
+	client, _ := newCacheTestClient(t, func(string, json.RawMessage) (CacheMode, string) {
+		return CachePinned, tag
+	})
+	defer client.Close()
+
+	if n := callCount(t, client, "x"); n != 1 {
+		t.Fatalf("first call: have %d, want 1", n)
+	}
+	// A changed tag must not be served the reply cached under the old one
+	tag = "b"
+	if n := callCount(t, client, "x"); n != 2 {
+		t.Fatalf("call with new tag: have %d, want 2", n)
+	}
+	tag = "a"
+	if n := callCount(t, client, "x"); n != 1 {
+		t.Fatalf("call with old tag: have %d, want 1", n)
+	}
+}
+
+func TestResponseCacheUntilHead(t *testing.T) {
+	client, cache := newCacheTestClient(t, func(method string, params json.RawMessage) (CacheMode, string) {
+		if string(params) == `["none"]` {
+			return CacheNone, ""
+		}
+		return CacheUntilHead, ""
+	})
+	defer client.Close()
+
+	if n := callCount(t, client, "a"); n != 1 {
+		t.Fatalf("first call: have %d, want 1", n)
+	}
+	if n := callCount(t, client, "a"); n != 1 {
+		t.Fatalf("cached call: have %d, want 1", n)
+	}
+	if n := callCount(t, client, "none"); n != 2 {
+		t.Fatalf("uncacheable call: have %d, want 2", n)
+	}
+	if n := callCount(t, client, "none"); n != 3 {
+		t.Fatalf("repeated uncacheable call: have %d, want 3", n)
+	}
+	cache.Purge()
+	if n := callCount(t, client, "a"); n != 4 {
+		t.Fatalf("call after purge: have %d, want 4", n)
+	}
+}
+
+func TestResponseCacheSkipsEmptyReplies(t *testing.T) {
+	client, cache := newCacheTestClient(t, func(string, json.RawMessage) (CacheMode, string) {
+		return CachePinned, ""
+	})
+	defer client.Close()
+
+	var result *Result
+	for i := 0; i < 2; i++ {
+		if err := client.Call(&result, "test_missing"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cache.Len() != 0 {
+		t.Fatalf("empty reply cached: %d entries", cache.Len())
+	}
+}
+
+func TestResponseCacheStaleStore(t *testing.T) {
+	cache, err := NewResponseCache(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.SetPolicy(func(string, json.RawMessage) (CacheMode, string) { return CacheUntilHead, "" })
+
+	_, ticket := cache.lookup("test_count", json.RawMessage(`["a"]`))
+	if ticket == nil {
+		t.Fatal("no ticket issued for cacheable call")
+	}
+	// A head change while the call executes must discard its reply
+	cache.Purge()
+	cache.store(ticket, 1)
+	if cache.Len() != 0 {
+		t.Fatalf("stale reply cached: %d entries", cache.Len())
+	}
+}
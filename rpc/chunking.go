@@ -0,0 +1,144 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+/*
+Results of debug calls like traces and state dumps can exceed the frame size
+limit of WebSocket clients. A client able to reassemble them announces the
+largest frame payload it accepts, in bytes, with the ChunkSizeHeader header of
+the WebSocket handshake. Messages larger than that are then sent as a run of
+notifications of the rpc_chunk method, each carrying a piece of the JSON text
+of the message:
+
+  {"jsonrpc":"2.0","method":"rpc_chunk","params":{"stream":1,"seq":0,"last":false,"data":"eyJqc29ucnBjIjoi..."}}
+
+stream numbers the chunked messages of the connection, seq the chunks of a
+message from zero, last marks its final chunk and data holds the piece,
+base64 encoded. The chunks of a message are sent consecutively, without other
+messages in between, and concatenating their data yields the original message.
+Sizes below MinChunkSize are raised to it.
+*/
+
+const (
+	ChunkSizeHeader = "X-Rpc-Chunk-Size" // WebSocket handshake header announcing the accepted frame size
+	MinChunkSize    = 1024               // smallest frame size messages are split to
+
+	chunkMethod   = "rpc_chunk"
+	chunkOverhead = 128 // room for the envelope around the encoded data
+)
+
+var errChunkSequence = errors.New("rpc_chunk out of sequence")
+
+// chunkParams is the payload of a chunk notification
+type chunkParams struct {
+	Stream uint64 `json:"stream"`
+	Seq    int    `json:"seq"`
+	Last   bool   `json:"last"`
+	Data   []byte `json:"data"`
+}
+
+type chunkMessage struct {
+	Version string       `json:"jsonrpc"`
+	Method  string       `json:"method"`
+	Params  *chunkParams `json:"params"`
+}
+
+// requestedChunkSize returns the frame size announced by the client in the
+// handshake request, 0 if the client can't reassemble chunked messages
+func requestedChunkSize(req *http.Request) int {
+	if req == nil {
+		return 0
+	}
+	size, err := strconv.Atoi(req.Header.Get(ChunkSizeHeader))
+	if err != nil || size <= 0 {
+		return 0
+	}
+	if size < MinChunkSize {
+		size = MinChunkSize
+	}
+	return size
+}
+
+// chunkingEncoder wraps the encoder of a connection to split messages larger
+// than size into chunks. The codec serializes the calls to the encoder.
+func chunkingEncoder(encode func(v interface{}) error, size int) func(v interface{}) error {
+	var stream uint64
+	piece := (size - chunkOverhead) / 4 * 3 // base64 encodes 3 bytes into 4
+	return func(v interface{}) error {
+		msg, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if len(msg) <= size {
+			return encode(json.RawMessage(msg))
+		}
+		stream++
+		for seq := 0; len(msg) > 0; seq++ {
+			n := piece
+			if n > len(msg) {
+				n = len(msg)
+			}
+			chunk := &chunkMessage{
+				Version: jsonrpcVersion,
+				Method:  chunkMethod,
+				Params:  &chunkParams{Stream: stream, Seq: seq, Last: n == len(msg), Data: msg[:n]},
+			}
+			if err := encode(chunk); err != nil {
+				return err
+			}
+			msg = msg[n:]
+		}
+		return nil
+	}
+}
+
+// chunkAssembler reassembles chunked messages on the client side
+type chunkAssembler struct {
+	stream uint64
+	seq    int
+	buf    []byte
+}
+
+// add adds the chunk carried by a rpc_chunk notification, returning the
+// reassembled message once complete
+func (a *chunkAssembler) add(params json.RawMessage) ([]byte, error) {
+	var chunk chunkParams
+	if err := json.Unmarshal(params, &chunk); err != nil {
+		return nil, err
+	}
+	if chunk.Seq == 0 {
+		a.stream, a.seq, a.buf = chunk.Stream, 0, a.buf[:0]
+	}
+	if chunk.Stream != a.stream || chunk.Seq != a.seq {
+		return nil, errChunkSequence
+	}
+	a.seq++
+	a.buf = append(a.buf, chunk.Data...)
+	if !chunk.Last {
+		return nil, nil
+	}
+	msg := a.buf
+	a.buf = nil
+	return msg, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestWebsocketChunking(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	defer server.Stop()
+	hs := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	defer hs.Close()
+	endpoint := "ws://" + hs.Listener.Addr().String()
+
+	large := strings.Repeat("\"chunked\" ünïcode ", 2000)
+
+	// the client reassembles the chunks transparently
+	client, err := DialWebsocketChunked(context.Background(), endpoint, "", MinChunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	for _, str := range []string{"small", large} {
+		var result Result
+		if err := client.Call(&result, "service_echo", str, 1, &Args{"x"}); err != nil {
+			t.Fatal(err)
+		}
+		if result.String != str {
+			t.Fatalf("have echo of %d bytes, want %d", len(result.String), len(str))
+		}
+	}
+
+	// the frames on the wire respect the announced size
+	config, _ := websocket.NewConfig(endpoint, "http://localhost")
+	config.Header.Set(ChunkSizeHeader, strconv.Itoa(MinChunkSize))
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	req, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "service_echo", "params": []interface{}{large, 1, &Args{"x"}}})
+	if _, err := conn.Write(req); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		assembler chunkAssembler
+		frames    int
+	)
+	for {
+		var frame []byte
+		if err := websocket.Message.Receive(conn, &frame); err != nil {
+			t.Fatal(err)
+		}
+		if len(frame) > MinChunkSize {
+			t.Fatalf("frame of %d bytes exceeds chunk size %d", len(frame), MinChunkSize)
+		}
+		var msg jsonrpcMessage
+		if err := json.Unmarshal(frame, &msg); err != nil || msg.Method != chunkMethod {
+			t.Fatalf("frame %s is not a chunk (%v)", frame, err)
+		}
+		frames++
+		whole, err := assembler.add(msg.Params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if whole == nil {
+			continue
+		}
+		var resp struct{ Result Result }
+		if err := json.Unmarshal(whole, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Result.String != large {
+			t.Fatalf("reassembled result differs")
+		}
+		break
+	}
+	if frames < len(large)/MinChunkSize {
+		t.Errorf("response sent in %d frames only", frames)
+	}
+}
+
+func TestChunkAssemblerSequence(t *testing.T) {
+	var a chunkAssembler
+	chunk := func(stream uint64, seq int, last bool) json.RawMessage {
+		enc, _ := json.Marshal(&chunkParams{Stream: stream, Seq: seq, Last: last, Data: []byte("x")})
+		return enc
+	}
+	if _, err := a.add(chunk(1, 0, false)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.add(chunk(1, 2, true)); err != errChunkSequence {
+		t.Errorf("skipped chunk: have error %v, want %v", err, errChunkSequence)
+	}
+	if _, err := a.add(chunk(2, 1, true)); err != errChunkSequence {
+		t.Errorf("chunk of other stream: have error %v, want %v", err, errChunkSequence)
+	}
+}
@@ -86,6 +86,8 @@ type jsonrpcMessage struct {
 	Params  json.RawMessage `json:"params,omitempty"`
 	Error   *jsonError      `json:"error,omitempty"`
 	Result  json.RawMessage `json:"result,omitempty"`
+
+	Authorization *Authorization `json:"authorization,omitempty"`
 }
 
 func (msg *jsonrpcMessage) isNotification() bool {
@@ -293,6 +295,24 @@ func (c *Client) CallContext(ctx context.Context, result interface{}, method str
 	if err != nil {
 		return err
 	}
+	return c.call(ctx, result, msg)
+}
+
+// CallAuthorizedContext performs a JSON-RPC call of a method guarded by an
+// operator quorum, sending the approval of the operators along. The operators
+// sign the arguments as encoded in a JSON array, see AuthorizationHash.
+func (c *Client) CallAuthorizedContext(ctx context.Context, result interface{}, auth *Authorization, method string, args ...interface{}) error {
+	msg, err := c.newMessage(method, args...)
+	if err != nil {
+		return err
+	}
+	msg.Authorization = auth
+	return c.call(ctx, result, msg)
+}
+
+// call sends a request message and waits for the reply.
+func (c *Client) call(ctx context.Context, result interface{}, msg *jsonrpcMessage) error {
+	var err error
 	op := &requestOp{ids: []json.RawMessage{msg.ID}, resp: make(chan *jsonrpcMessage, 1)}
 
 	if c.isHTTP {
@@ -674,14 +694,11 @@ func (c *Client) handleResponse(msg *jsonrpcMessage) {
 
 func (c *Client) read(conn net.Conn) error {
 	var (
-		buf json.RawMessage
-		dec = json.NewDecoder(conn)
+		buf    json.RawMessage
+		dec    = json.NewDecoder(conn)
+		chunks chunkAssembler
 	)
-	readMessage := func() (rs []*jsonrpcMessage, err error) {
-		buf = buf[:0]
-		if err = dec.Decode(&buf); err != nil {
-			return nil, err
-		}
+	decode := func(buf []byte) (rs []*jsonrpcMessage, err error) {
 		if isBatch(buf) {
 			err = json.Unmarshal(buf, &rs)
 		} else {
@@ -690,6 +707,25 @@ func (c *Client) read(conn net.Conn) error {
 		}
 		return rs, err
 	}
+	readMessage := func() (rs []*jsonrpcMessage, err error) {
+		for {
+			buf = buf[:0]
+			if err = dec.Decode(&buf); err != nil {
+				return nil, err
+			}
+			if rs, err = decode(buf); err != nil || len(rs) != 1 || rs[0].Method != chunkMethod {
+				return rs, err
+			}
+			// reassemble the messages the server split into chunks
+			msg, err := chunks.add(rs[0].Params)
+			if err != nil {
+				return nil, err
+			}
+			if msg != nil {
+				return decode(msg)
+			}
+		}
+	}
 
 	for {
 		resp, err := readMessage()
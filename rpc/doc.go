@@ -94,5 +94,9 @@ Subscriptions are deleted when:
  - the connection which was used to create the subscription is closed. This can be initiated
    by the client and server. The server will close the connection on a write error or when
    the queue of buffered notifications gets too big.
+
+WebSocket clients may announce the largest frame they accept with the X-Rpc-Chunk-Size
+handshake header. Larger responses are then split into rpc_chunk notifications, see
+ChunkSizeHeader for the envelope. DialWebsocketChunked creates a client doing so.
 */
 package rpc
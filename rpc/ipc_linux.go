@@ -0,0 +1,87 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package rpc
+
+import (
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/matrix/go-matrix/log"
+)
+
+// isAbstractSocket reports whether endpoint names a Linux abstract socket, which
+// lives outside the filesystem.
+func isAbstractSocket(endpoint string) bool {
+	return strings.HasPrefix(endpoint, "@")
+}
+
+// abstractListen creates an abstract Unix socket. Abstract sockets have no file
+// permissions, so connections are only accepted from processes running as the
+// same user as the node, as checked on the peer credentials.
+func abstractListen(endpoint string) (net.Listener, error) {
+	l, err := net.Listen("unix", endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &peerCredListener{Listener: l, uid: uint32(os.Getuid())}, nil
+}
+
+// peerCredListener is a Unix socket listener dropping the connections of
+// processes running as another user than uid.
+type peerCredListener struct {
+	net.Listener
+	uid uint32
+}
+
+// Accept waits for and returns the next connection of an allowed user.
+func (l *peerCredListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		uid, err := peerUid(conn)
+		if err == nil && uid == l.uid {
+			return conn, nil
+		}
+		log.Warn("Rejected IPC connection from another user", "uid", uid, "err", err)
+		conn.Close()
+	}
+}
+
+// peerUid returns the user id of the process on the other end of a Unix socket.
+func peerUid(conn net.Conn) (uint32, error) {
+	raw, err := conn.(*net.UnixConn).SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var (
+		cred    *syscall.Ucred
+		credErr error
+	)
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return 0, err
+	}
+	if credErr != nil {
+		return 0, credErr
+	}
+	return cred.Uid, nil
+}
@@ -20,26 +20,68 @@ package rpc
 
 import (
 	"context"
+	"errors"
 	"net"
 	"os"
+	"os/user"
 	"path/filepath"
+	"strconv"
+	"syscall"
 )
 
 // ipcListen will create a Unix socket on the given endpoint.
 func ipcListen(endpoint string) (net.Listener, error) {
+	if isAbstractSocket(endpoint) {
+		return abstractListen(endpoint)
+	}
 	// Ensure the IPC path exists and remove any previous leftover
 	if err := os.MkdirAll(filepath.Dir(endpoint), 0751); err != nil {
 		return nil, err
 	}
 	os.Remove(endpoint)
+
+	// Create the socket owner only from the start, so it is never reachable by
+	// other users before its mode is fixed up
+	umask := syscall.Umask(0177)
 	l, err := net.Listen("unix", endpoint)
+	syscall.Umask(umask)
 	if err != nil {
 		return nil, err
 	}
-	os.Chmod(endpoint, 0600)
+	if err := os.Chmod(endpoint, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
 	return l, nil
 }
 
+// SetIPCPermissions changes the file mode and, if group is not empty, the owning
+// group (name or numeric id) of the Unix socket at endpoint.
+func SetIPCPermissions(endpoint string, mode os.FileMode, group string) error {
+	if isAbstractSocket(endpoint) {
+		return errors.New("abstract IPC sockets have no file permissions")
+	}
+	if group != "" {
+		gid, err := strconv.Atoi(group)
+		if err != nil {
+			grp, err := user.LookupGroup(group)
+			if err != nil {
+				return err
+			}
+			if gid, err = strconv.Atoi(grp.Gid); err != nil {
+				return err
+			}
+		}
+		if err := os.Chown(endpoint, -1, gid); err != nil {
+			return err
+		}
+	}
+	if mode != 0 {
+		return os.Chmod(endpoint, mode)
+	}
+	return nil
+}
+
 // newIPCConnection will connect to a Unix socket on the given endpoint.
 func newIPCConnection(ctx context.Context, endpoint string) (net.Conn, error) {
 	return dialContext(ctx, "unix", endpoint)
@@ -0,0 +1,90 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+// +build darwin dragonfly freebsd linux nacl netbsd openbsd solaris
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSetIPCPermissions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipc-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	endpoint := filepath.Join(dir, "test.ipc")
+	listener, err := ipcListen(endpoint)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	if err := SetIPCPermissions(endpoint, 0660, fmt.Sprint(os.Getgid())); err != nil {
+		t.Fatalf("failed to set permissions: %v", err)
+	}
+	info, err := os.Stat(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0660 {
+		t.Errorf("socket mode mismatch: have %o, want 660", perm)
+	}
+	if err := SetIPCPermissions(endpoint, 0, "no-such-group-for-ipc-test"); err == nil {
+		t.Error("unknown group accepted")
+	}
+}
+
+func TestAbstractIPCSocket(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("abstract sockets are Linux only")
+	}
+	endpoint := fmt.Sprintf("@gman-test-%d", os.Getpid())
+
+	server := NewServer()
+	defer server.Stop()
+	listener, err := ipcListen(endpoint)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go server.ServeListener(listener)
+
+	if _, err := os.Stat(endpoint); !os.IsNotExist(err) {
+		t.Errorf("abstract socket created a file: %v", err)
+	}
+	client, err := DialIPC(context.Background(), endpoint)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	var modules map[string]string
+	if err := client.Call(&modules, "rpc_modules"); err != nil {
+		t.Fatalf("call over abstract socket failed: %v", err)
+	}
+	if err := SetIPCPermissions(endpoint, 0660, ""); err == nil {
+		t.Error("permissions set on abstract socket")
+	}
+}
@@ -20,7 +20,9 @@ package rpc
 
 import (
 	"context"
+	"errors"
 	"net"
+	"os"
 	"time"
 
 	"gopkg.in/natefinch/npipe.v2"
@@ -35,6 +37,11 @@ func ipcListen(endpoint string) (net.Listener, error) {
 	return npipe.Listen(endpoint)
 }
 
+// SetIPCPermissions is not supported for named pipes.
+func SetIPCPermissions(endpoint string, mode os.FileMode, group string) error {
+	return errors.New("IPC permissions are not supported on Windows")
+}
+
 // newIPCConnection will connect to a named pipe with the given endpoint as name.
 func newIPCConnection(ctx context.Context, endpoint string) (net.Conn, error) {
 	timeout := defaultPipeDialTimeout
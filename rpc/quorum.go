@@ -0,0 +1,278 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/common/math"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/metrics"
+)
+
+// MaxAuthorizationLifetime bounds how far in the future an authorization may
+// expire, which also bounds the nonces remembered against replays.
+const MaxAuthorizationLifetime = time.Hour
+
+var (
+	quorumApprovedMeter = metrics.NewRegisteredMeter("rpc/quorum/approved", nil)
+	quorumRefusedMeter  = metrics.NewRegisteredMeter("rpc/quorum/refused", nil)
+)
+
+// Authorization carries the operator signatures approving a single request of
+// a method guarded by an OperatorQuorum. It is sent next to the parameters in
+// the "authorization" member of the request.
+type Authorization struct {
+	Nonce      hexutil.Uint64  `json:"nonce"`  // unique per approved request, prevents replays
+	Expiry     hexutil.Uint64  `json:"expiry"` // unix time after which the approval is void
+	Signatures []hexutil.Bytes `json:"signatures"`
+}
+
+// AuthorizationScope identifies the node approvals are valid for, so that an
+// approval can't be replayed on other nodes sharing the same operators.
+type AuthorizationScope struct {
+	ChainID *big.Int // chain the node runs, nil if none
+	Node    string   // hex encoded node ID
+}
+
+// AuthorizationHash is the hash operators sign to approve a request to the node
+// identified by scope. The parameters are hashed in their compact JSON encoding.
+func AuthorizationHash(scope AuthorizationScope, method string, params json.RawMessage, nonce, expiry uint64) common.Hash {
+	compact := new(bytes.Buffer)
+	if err := json.Compact(compact, params); err != nil || compact.Len() == 0 {
+		compact.Reset()
+		compact.WriteString("null")
+	}
+	chainID := new(big.Int)
+	if scope.ChainID != nil {
+		chainID = scope.ChainID
+	}
+	var tail [16]byte
+	binary.BigEndian.PutUint64(tail[:8], nonce)
+	binary.BigEndian.PutUint64(tail[8:], expiry)
+	return crypto.Keccak256Hash([]byte("matrix admin authorization"), []byte{0}, math.PaddedBigBytes(chainID, 32), []byte(scope.Node), []byte{0},
+		[]byte(method), []byte{0}, compact.Bytes(), []byte{0}, tail[:])
+}
+
+// SignAuthorization signs the approval of a request to the node identified by
+// scope with an operator key.
+func SignAuthorization(key *ecdsa.PrivateKey, scope AuthorizationScope, method string, params json.RawMessage, nonce, expiry uint64) (hexutil.Bytes, error) {
+	hash := AuthorizationHash(scope, method, params, nonce, expiry)
+	return crypto.Sign(hash[:], key)
+}
+
+// AuditEntry records a request of a guarded method, approved or refused.
+type AuditEntry struct {
+	Time     time.Time        `json:"time"`
+	Method   string           `json:"method"`
+	Params   json.RawMessage  `json:"params,omitempty"`
+	Nonce    hexutil.Uint64   `json:"nonce"`
+	Expiry   hexutil.Uint64   `json:"expiry"`
+	Signers  []common.Address `json:"signers"` // operators whose signatures were valid
+	Approved bool             `json:"approved"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// OperatorQuorum guards sensitive methods, admitting their requests only if
+// approved by at least threshold of the configured operator keys. Every
+// request of a guarded method is appended to the audit trail as a line of JSON.
+type OperatorQuorum struct {
+	threshold int
+	operators map[common.Address]bool
+	methods   map[string]bool
+	scope     AuthorizationScope
+	audit     io.Writer // nil if requests are logged only
+
+	used map[uint64]time.Time // nonces of approved requests until they expire
+	lock sync.Mutex
+}
+
+// NewOperatorQuorum creates a quorum of threshold out of the operators guarding
+// the given methods (full names, e.g. "debug_setHead") of the node identified
+// by scope.
+func NewOperatorQuorum(threshold int, operators []common.Address, methods []string, scope AuthorizationScope, audit io.Writer) (*OperatorQuorum, error) {
+	q := &OperatorQuorum{
+		threshold: threshold,
+		operators: make(map[common.Address]bool),
+		methods:   make(map[string]bool),
+		scope:     scope,
+		audit:     audit,
+		used:      make(map[uint64]time.Time),
+	}
+	for _, operator := range operators {
+		q.operators[operator] = true
+	}
+	if threshold < 1 || threshold > len(q.operators) {
+		return nil, fmt.Errorf("invalid threshold %d of %d operators", threshold, len(q.operators))
+	}
+	for _, method := range methods {
+		q.methods[method] = true
+	}
+	return q, nil
+}
+
+// Covers tells whether requests of the given method need to be approved.
+func (q *OperatorQuorum) Covers(method string) bool {
+	return q.methods[method]
+}
+
+// Scope returns the node approvals are accepted for.
+func (q *OperatorQuorum) Scope() AuthorizationScope {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.scope
+}
+
+// SetScope changes the node approvals are accepted for, e.g. after its node key
+// was rotated.
+func (q *OperatorQuorum) SetScope(scope AuthorizationScope) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.scope = scope
+}
+
+// LoadUsed restores the nonces of the approved requests from an audit trail
+// written by a previous run, so that their approvals can't be replayed after
+// a restart until they expire. Malformed lines are skipped.
+func (q *OperatorQuorum) LoadUsed(trail io.Reader) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	now := time.Now()
+	scanner := bufio.NewScanner(trail)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || !entry.Approved {
+			continue
+		}
+		if expiry := time.Unix(int64(entry.Expiry), 0); now.Before(expiry) {
+			q.used[uint64(entry.Nonce)] = expiry
+		}
+	}
+	return scanner.Err()
+}
+
+// authorize checks the approval of a request of a guarded method, recording
+// the outcome in the audit trail.
+func (q *OperatorQuorum) authorize(method string, params json.RawMessage, auth *Authorization) Error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	now := time.Now()
+	entry := &AuditEntry{Time: now, Method: method, Params: params, Signers: []common.Address{}}
+	err := q.verify(now, method, params, auth, entry)
+	if err == nil {
+		entry.Approved = true
+		q.used[uint64(auth.Nonce)] = time.Unix(int64(auth.Expiry), 0)
+		quorumApprovedMeter.Mark(1)
+		log.Warn("Guarded RPC method approved", "method", method, "nonce", entry.Nonce, "signers", entry.Signers)
+	} else {
+		entry.Error = err.Error()
+		quorumRefusedMeter.Mark(1)
+		log.Warn("Guarded RPC method refused", "method", method, "nonce", entry.Nonce, "signers", entry.Signers, "err", err)
+	}
+	q.record(entry)
+	if err != nil {
+		return &unauthorizedError{err.Error()}
+	}
+	return nil
+}
+
+// verify checks an authorization against the request, filling in the nonce
+// and valid signers of the audit entry.
+// caller holds the lock
+func (q *OperatorQuorum) verify(now time.Time, method string, params json.RawMessage, auth *Authorization, entry *AuditEntry) error {
+	if auth == nil {
+		return fmt.Errorf("method %s requires the approval of %d operators", method, q.threshold)
+	}
+	entry.Nonce, entry.Expiry = auth.Nonce, auth.Expiry
+
+	for nonce, expiry := range q.used {
+		if now.After(expiry) {
+			delete(q.used, nonce)
+		}
+	}
+	expiry := time.Unix(int64(auth.Expiry), 0)
+	switch {
+	case now.After(expiry):
+		return errors.New("authorization expired")
+	case expiry.Sub(now) > MaxAuthorizationLifetime:
+		return fmt.Errorf("authorization expires in more than %v", MaxAuthorizationLifetime)
+	}
+	if _, ok := q.used[uint64(auth.Nonce)]; ok {
+		return fmt.Errorf("authorization nonce %d already used", auth.Nonce)
+	}
+	hash := AuthorizationHash(q.scope, method, params, uint64(auth.Nonce), uint64(auth.Expiry))
+	seen := make(map[common.Address]bool)
+	for _, sig := range auth.Signatures {
+		pub, err := crypto.SigToPub(hash[:], sig)
+		if err != nil {
+			continue
+		}
+		signer := crypto.PubkeyToAddress(*pub)
+		if q.operators[signer] && !seen[signer] {
+			seen[signer] = true
+			entry.Signers = append(entry.Signers, signer)
+		}
+	}
+	if len(entry.Signers) < q.threshold {
+		return fmt.Errorf("approved by %d of %d required operators", len(entry.Signers), q.threshold)
+	}
+	return nil
+}
+
+// record appends an entry to the audit trail.
+// caller holds the lock
+func (q *OperatorQuorum) record(entry *AuditEntry) {
+	if q.audit == nil {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err == nil {
+		_, err = q.audit.Write(append(line, '\n'))
+	}
+	if err != nil {
+		log.Error("Failed to write RPC audit trail", "method", entry.Method, "err", err)
+	}
+}
+
+// SetQuorum installs the operator quorum approving requests of guarded
+// methods. A nil quorum guards no methods.
+func (s *Server) SetQuorum(q *OperatorQuorum) {
+	s.quorum.Store(q)
+}
+
+// operatorQuorum returns the installed operator quorum, if any.
+func (s *Server) operatorQuorum() *OperatorQuorum {
+	q, _ := s.quorum.Load().(*OperatorQuorum)
+	return q
+}
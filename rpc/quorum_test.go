@@ -0,0 +1,176 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/crypto"
+)
+
+func TestOperatorQuorum(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 3)
+	operators := make([]common.Address, len(keys))
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		operators[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	audit := new(bytes.Buffer)
+	scope := AuthorizationScope{ChainID: big.NewInt(1), Node: "01"}
+	quorum, err := NewOperatorQuorum(2, operators, []string{"test_count"}, scope, audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer()
+	if err := server.RegisterName("test", new(CacheService)); err != nil {
+		t.Fatal(err)
+	}
+	server.SetQuorum(quorum)
+	client := DialInProc(server)
+	defer client.Close()
+
+	expiry := uint64(time.Now().Add(time.Minute).Unix())
+	approveFor := func(scope AuthorizationScope, nonce uint64, signers ...*ecdsa.PrivateKey) *Authorization {
+		auth := &Authorization{Nonce: hexutil.Uint64(nonce), Expiry: hexutil.Uint64(expiry)}
+		for _, key := range signers {
+			sig, err := SignAuthorization(key, scope, "test_count", json.RawMessage(`["a"]`), nonce, expiry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			auth.Signatures = append(auth.Signatures, sig)
+		}
+		return auth
+	}
+	approve := func(nonce uint64, signers ...*ecdsa.PrivateKey) *Authorization {
+		return approveFor(scope, nonce, signers...)
+	}
+	outsider, _ := crypto.GenerateKey()
+	tests := []struct {
+		auth *Authorization
+		ok   bool
+	}{
+		{nil, false},
+		{approve(1, keys[0]), false},
+		{approve(1, keys[0], keys[0]), false},
+		{approve(1, keys[0], outsider), false},
+		{approve(1, keys[0], keys[2]), true},
+		{approve(1, keys[0], keys[2]), false}, // replayed
+		{approve(2, keys[0], keys[1], keys[2]), true},
+	}
+	for i, test := range tests {
+		var n int
+		err := client.CallAuthorizedContext(context.Background(), &n, test.auth, "test_count", "a")
+		if (err == nil) != test.ok {
+			t.Errorf("test %d: have error %v, want ok %v", i, err, test.ok)
+		}
+	}
+	// approvals are bound to the arguments
+	if err := client.CallAuthorizedContext(context.Background(), nil, approve(3, keys[0], keys[1]), "test_count", "b"); err == nil {
+		t.Error("approval accepted for other arguments")
+	}
+	// approvals are bound to the node and the chain
+	for _, other := range []AuthorizationScope{{ChainID: big.NewInt(1), Node: "02"}, {ChainID: big.NewInt(2), Node: "01"}, {Node: "01"}} {
+		if err := client.CallAuthorizedContext(context.Background(), nil, approveFor(other, 4, keys[0], keys[1]), "test_count", "a"); err == nil {
+			t.Errorf("approval for %v accepted", other)
+		}
+	}
+	// unguarded methods need no approval
+	if err := client.Call(nil, "test_missing"); err != nil {
+		t.Errorf("unguarded method refused: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(audit.String()), "\n")
+	if len(lines) != len(tests)+4 {
+		t.Fatalf("have %d audit entries, want %d", len(lines), len(tests)+4)
+	}
+	for i, line := range lines[:len(tests)] {
+		var entry AuditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("entry %d: %v", i, err)
+		}
+		if entry.Method != "test_count" || entry.Approved != tests[i].ok || (entry.Error == "") != tests[i].ok {
+			t.Errorf("entry %d: unexpected %s", i, line)
+		}
+	}
+}
+
+// Tests that approvals recorded in the audit trail can't be replayed after a
+// restart until they expire.
+func TestOperatorQuorumRestart(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	operators := []common.Address{crypto.PubkeyToAddress(key.PublicKey)}
+	scope := AuthorizationScope{ChainID: big.NewInt(1), Node: "01"}
+
+	params := json.RawMessage(`["a"]`)
+	approve := func(nonce uint64, expiry time.Time) *Authorization {
+		sig, err := SignAuthorization(key, scope, "test_count", params, nonce, uint64(expiry.Unix()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Authorization{Nonce: hexutil.Uint64(nonce), Expiry: hexutil.Uint64(expiry.Unix()), Signatures: []hexutil.Bytes{sig}}
+	}
+	audit := new(bytes.Buffer)
+	quorum, err := NewOperatorQuorum(1, operators, []string{"test_count"}, scope, audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live, expiring := approve(1, time.Now().Add(time.Minute)), approve(2, time.Now().Add(time.Second))
+	for _, auth := range []*Authorization{live, expiring} {
+		if err := quorum.authorize("test_count", params, auth); err != nil {
+			t.Fatalf("approval %d refused: %v", auth.Nonce, err)
+		}
+	}
+	audit.WriteString("garbage\n")
+
+	restarted, err := NewOperatorQuorum(1, operators, []string{"test_count"}, scope, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if err := restarted.LoadUsed(bytes.NewReader(audit.Bytes())); err != nil {
+		t.Fatalf("failed to load audit trail: %v", err)
+	}
+	if _, ok := restarted.used[1]; !ok {
+		t.Errorf("live approval not restored")
+	}
+	if _, ok := restarted.used[2]; ok {
+		t.Errorf("expired approval restored")
+	}
+	if err := restarted.authorize("test_count", params, live); err == nil {
+		t.Errorf("approval replayed after restart")
+	}
+}
+
+func TestOperatorQuorumThreshold(t *testing.T) {
+	operators := []common.Address{{1}, {2}, {2}}
+	for _, threshold := range []int{0, 3} {
+		if _, err := NewOperatorQuorum(threshold, operators, nil, AuthorizationScope{}, nil); err == nil {
+			t.Errorf("threshold %d of 2 distinct operators accepted", threshold)
+		}
+	}
+	if _, err := NewOperatorQuorum(2, operators, nil, AuthorizationScope{}, nil); err != nil {
+		t.Errorf("threshold 2 of 2 refused: %v", err)
+	}
+}
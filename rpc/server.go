@@ -18,6 +18,7 @@ package rpc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"runtime"
@@ -58,6 +59,17 @@ func NewServer() *Server {
 	return server
 }
 
+// SetCache installs a response cache consulted for every regular method call.
+func (s *Server) SetCache(cache *ResponseCache) {
+	s.cache.Store(cache)
+}
+
+// responseCache returns the installed response cache, if any.
+func (s *Server) responseCache() *ResponseCache {
+	cache, _ := s.cache.Load().(*ResponseCache)
+	return cache
+}
+
 // RPCService gives meta information about the server.
 // e.g. gives information about the loaded modules.
 type RPCService struct {
@@ -167,7 +179,9 @@ func (s *Server) serveRequest(ctx context.Context, codec ServerCodec, singleShot
 				log.Debug(fmt.Sprintf("read error %v\n", err))
 				codec.Write(codec.CreateErrorResponse(nil, err))
 			}
-			// Error or end of stream, wait for requests and tear down
+			// Error or end of stream, abort running requests, wait for them
+			// and tear down
+			cancel()
 			pend.Wait()
 			return nil
 		}
@@ -254,6 +268,20 @@ func (s *Server) createSubscription(ctx context.Context, c ServerCodec, req *ser
 	return reply[0].Interface().(*Subscription).ID, nil
 }
 
+// callbackErrorResponse creates the response for an error returned by a callback.
+// Errors implementing Error keep their own code instead of the generic callback
+// error code, and errors implementing DataError send their data along.
+func callbackErrorResponse(codec ServerCodec, id interface{}, err error) interface{} {
+	rpcErr, ok := err.(Error)
+	if !ok {
+		rpcErr = &callbackError{err.Error()}
+	}
+	if de, ok := err.(DataError); ok {
+		return codec.CreateErrorResponseWithInfo(id, rpcErr, de.ErrorData())
+	}
+	return codec.CreateErrorResponse(id, rpcErr)
+}
+
 // handle executes a request and returns the response from the callback.
 func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func()) {
 	if req.err != nil {
@@ -277,10 +305,22 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		return codec.CreateErrorResponse(&req.id, &invalidParamsError{"Expected subscription id as first argument"}), nil
 	}
 
+	if gate := s.apiKeyGate(); gate != nil {
+		if err := gate.authorize(ctx, req.method); err != nil {
+			return codec.CreateErrorResponse(&req.id, err), nil
+		}
+	}
+	if quorum := s.operatorQuorum(); quorum != nil && quorum.Covers(req.method) {
+		params, _ := req.params.(json.RawMessage)
+		if err := quorum.authorize(req.method, params, req.auth); err != nil {
+			return codec.CreateErrorResponse(&req.id, err), nil
+		}
+	}
+
 	if req.callb.isSubscribe {
 		subid, err := s.createSubscription(ctx, codec, req)
 		if err != nil {
-			return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
+			return callbackErrorResponse(codec, &req.id, err), nil
 		}
 
 		// active the subscription after the sub id was successfully sent to the client
@@ -300,6 +340,15 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 		return codec.CreateErrorResponse(&req.id, rpcErr), nil
 	}
 
+	// serve the reply from the response cache if possible
+	var ticket *cacheTicket
+	if cache := s.responseCache(); cache != nil {
+		var cached interface{}
+		if cached, ticket = cache.lookup(req.method, req.params); cached != nil {
+			return s.createResponse(codec, req, cached), nil
+		}
+	}
+
 	arguments := []reflect.Value{req.callb.rcvr}
 	if req.callb.hasCtx {
 		arguments = append(arguments, reflect.ValueOf(ctx))
@@ -317,11 +366,14 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 	if req.callb.errPos >= 0 { // test if method returned an error
 		if !reply[req.callb.errPos].IsNil() {
 			e := reply[req.callb.errPos].Interface().(error)
-			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
-			return res, nil
+			return callbackErrorResponse(codec, &req.id, e), nil
 		}
 	}
-	return codec.CreateResponse(req.id, reply[0].Interface()), nil
+	result := reply[0].Interface()
+	if ticket != nil && isCacheable(reply[0]) {
+		s.responseCache().store(ticket, result)
+	}
+	return s.createResponse(codec, req, result), nil
 }
 
 // exec executes the given request and writes the result back using the codec.
@@ -411,7 +463,7 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 
 		if r.isPubSub { // man_subscribe, r.method contains the subscription method name
 			if callb, ok := svc.subscriptions[r.method]; ok {
-				requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+				requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb, method: r.service + subscribeMethodSuffix}
 				if r.params != nil && len(callb.argTypes) > 0 {
 					argTypes := []reflect.Type{reflect.TypeOf("")}
 					argTypes = append(argTypes, callb.argTypes...)
@@ -428,7 +480,7 @@ func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error)
 		}
 
 		if callb, ok := svc.callbacks[r.method]; ok { // lookup RPC method
-			requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+			requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb, method: r.service + serviceMethodSeparator + r.method, params: r.params, auth: r.auth}
 			if r.params != nil && len(callb.argTypes) > 0 {
 				if args, err := codec.ParseRequestArguments(callb.argTypes, r.params); err == nil {
 					requests[i].args = args
@@ -160,3 +160,73 @@ func TestServerMethodExecution(t *testing.T) {
 func TestServerMethodWithCtx(t *testing.T) {
 	testServerMethodExecution(t, "echoWithCtx")
 }
+
+func TestServerCancelOnClose(t *testing.T) {
+	server := NewServer()
+	service := new(Service)
+	if err := server.RegisterName("test", service); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	clientConn, serverConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+		close(done)
+	}()
+
+	request := map[string]interface{}{
+		"id":      1,
+		"method":  "test_sleep",
+		"version": "2.0",
+		"params":  []interface{}{time.Minute},
+	}
+	if err := json.NewEncoder(clientConn).Encode(request); err != nil {
+		t.Fatal(err)
+	}
+	// the running call is cancelled when the client goes away
+	clientConn.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("call not cancelled after the connection was closed")
+	}
+}
+
+type testDataError struct{}
+
+func (e *testDataError) Error() string          { return "insufficient funds" }
+func (e *testDataError) ErrorCode() int         { return -32010 }
+func (e *testDataError) ErrorData() interface{} { return map[string]int{"required": 2, "available": 1} }
+
+type DataErrorService struct{}
+
+func (s *DataErrorService) Fail() error { return &testDataError{} }
+
+func TestServerErrorData(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(DataErrorService)); err != nil {
+		t.Fatal(err)
+	}
+	client := DialInProc(server)
+	defer client.Close()
+
+	err := client.Call(nil, "test_fail")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if err.Error() != "insufficient funds" {
+		t.Errorf("message mismatch: have %q, want %q", err.Error(), "insufficient funds")
+	}
+	if rpcErr, ok := err.(Error); !ok || rpcErr.ErrorCode() != -32010 {
+		t.Errorf("error code mismatch: have %v, want %d", err, -32010)
+	}
+	dataErr, ok := err.(DataError)
+	if !ok {
+		t.Fatalf("error %T does not carry data", err)
+	}
+	want := map[string]interface{}{"required": float64(2), "available": float64(1)}
+	if !reflect.DeepEqual(dataErr.ErrorData(), want) {
+		t.Errorf("error data mismatch: have %v, want %v", dataErr.ErrorData(), want)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/matrix/go-matrix/common/hexutil"
 	"gopkg.in/fatih/set.v0"
@@ -61,6 +62,10 @@ type serverRequest struct {
 	args          []reflect.Value
 	isUnsubscribe bool
 	err           Error
+
+	method string         // full method name, used as response cache key and for API key checks
+	params interface{}    // raw request parameters, used as response cache key
+	auth   *Authorization // operator approval of a guarded method
 }
 
 type serviceRegistry map[string]*service // collection of services
@@ -74,6 +79,11 @@ type Server struct {
 	run      int32
 	codecsMu sync.Mutex
 	codecs   *set.Set
+
+	cache  atomic.Value // *ResponseCache shared with the other servers of the node
+	signer atomic.Value // *ResponseSigner attesting selected replies
+	gate   atomic.Value // *APIKeyGate authorizing requests of public endpoints
+	quorum atomic.Value // *OperatorQuorum approving requests of guarded methods
 }
 
 // rpcRequest represents a raw incoming RPC request
@@ -83,7 +93,8 @@ type rpcRequest struct {
 	id       interface{}
 	isPubSub bool
 	params   interface{}
-	err      Error // invalid batch element
+	auth     *Authorization // operator approval of a guarded method
+	err      Error          // invalid batch element
 }
 
 // Error wraps RPC errors, which contain an error code in addition to the message.
@@ -92,6 +103,14 @@ type Error interface {
 	ErrorCode() int // returns the code
 }
 
+// DataError is implemented by errors carrying a structured data object, which
+// is sent along in the data field of the error response so clients can act on
+// the failure without parsing the message.
+type DataError interface {
+	Error() string          // returns the message
+	ErrorData() interface{} // returns the error data
+}
+
 // ServerCodec implements reading, parsing and writing RPC messages for the server side of
 // a RPC session. Implementations must be go-routine safe since the codec can be called in
 // multiple go-routines concurrently.
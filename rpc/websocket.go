@@ -26,6 +26,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -65,10 +66,15 @@ func (srv *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 			encoder := func(v interface{}) error {
 				return websocketJSONCodec.Send(conn, v)
 			}
+			if size := requestedChunkSize(conn.Request()); size > 0 {
+				encoder = chunkingEncoder(encoder, size)
+			}
 			decoder := func(v interface{}) error {
 				return websocketJSONCodec.Receive(conn, v)
 			}
-			srv.ServeCodec(NewCodec(conn, encoder, decoder), OptionMethodInvocation|OptionSubscriptions)
+			codec := NewCodec(conn, encoder, decoder)
+			defer codec.Close()
+			srv.serveRequest(withAPIKey(context.Background(), conn.Request()), codec, false, OptionMethodInvocation|OptionSubscriptions)
 		},
 	}
 }
@@ -124,6 +130,17 @@ func wsHandshakeValidator(allowedOrigins []string) func(*websocket.Config, *http
 // The context is used for the initial connection establishment. It does not
 // affect subsequent interactions with the client.
 func DialWebsocket(ctx context.Context, endpoint, origin string) (*Client, error) {
+	return dialWebsocket(ctx, endpoint, origin, 0)
+}
+
+// DialWebsocketChunked is like DialWebsocket, but asks the server to split
+// messages larger than chunkSize bytes into chunks, which the client
+// reassembles. See ChunkSizeHeader.
+func DialWebsocketChunked(ctx context.Context, endpoint, origin string, chunkSize int) (*Client, error) {
+	return dialWebsocket(ctx, endpoint, origin, chunkSize)
+}
+
+func dialWebsocket(ctx context.Context, endpoint, origin string, chunkSize int) (*Client, error) {
 	if origin == "" {
 		var err error
 		if origin, err = os.Hostname(); err != nil {
@@ -139,6 +156,9 @@ func DialWebsocket(ctx context.Context, endpoint, origin string) (*Client, error
 	if err != nil {
 		return nil, err
 	}
+	if chunkSize > 0 {
+		config.Header.Set(ChunkSizeHeader, strconv.Itoa(chunkSize))
+	}
 
 	return newClient(ctx, func(ctx context.Context) (net.Conn, error) {
 		return wsDialContext(ctx, config)
@@ -171,6 +171,11 @@ func (self *Api) Store(data io.Reader, size int64, wg *sync.WaitGroup) (key stor
 	return self.dpa.Store(data, size, wg, nil)
 }
 
+// Hash computes the key Store would return for the data without storing it
+func (self *Api) Hash(data io.Reader, size int64) (storage.Key, error) {
+	return self.dpa.Hash(data, size)
+}
+
 type ErrResolve error
 
 // DNS Resolver
@@ -232,6 +237,16 @@ func (self *Api) Put(content, contentType string) (storage.Key, error) {
 // to resolve basePath to content using dpa retrieve
 // it returns a section reader, mimeType, status and an error
 func (self *Api) Get(key storage.Key, path string) (reader storage.LazySectionReader, mimeType string, status int, err error) {
+	reader, entry, status, err := self.GetEntry(key, path)
+	if entry != nil {
+		mimeType = entry.ContentType
+	}
+	return
+}
+
+// GetEntry resolves a path of a manifest as Get does, returning the manifest
+// entry served along with its content
+func (self *Api) GetEntry(key storage.Key, path string) (reader storage.LazySectionReader, entry *ManifestEntry, status int, err error) {
 	apiGetCount.Inc(1)
 	trie, err := loadManifest(self.dpa, key, nil)
 	if err != nil {
@@ -243,17 +258,17 @@ func (self *Api) Get(key storage.Key, path string) (reader storage.LazySectionRe
 
 	log.Trace(fmt.Sprintf("getEntry(%s)", path))
 
-	entry, _ := trie.getEntry(path)
+	found, _ := trie.getEntry(path)
 
-	if entry != nil {
+	if found != nil {
+		entry = &found.ManifestEntry
 		key = common.Hex2Bytes(entry.Hash)
 		status = entry.Status
 		if status == http.StatusMultipleChoices {
 			apiGetHttp300.Inc(1)
 			return
 		} else {
-			mimeType = entry.ContentType
-			log.Trace(fmt.Sprintf("content lookup key: '%v' (%v)", key, mimeType))
+			log.Trace(fmt.Sprintf("content lookup key: '%v' (%v)", key, entry.ContentType))
 			reader = self.dpa.Retrieve(key)
 		}
 	} else {
@@ -376,6 +376,9 @@ func (c *Client) TarUpload(hash string, uploader Uploader) (string, error) {
 				"user.swarm.content-type": file.ContentType,
 			},
 		}
+		for name, value := range file.Headers {
+			hdr.Xattrs[api.HeaderXattrPrefix+name] = value
+		}
 		if err := tw.WriteHeader(hdr); err != nil {
 			return err
 		}
@@ -431,6 +434,9 @@ func (c *Client) MultipartUpload(hash string, uploader Uploader) (string, error)
 		hdr.Set("Content-Disposition", fmt.Sprintf("form-data; name=%q", file.Path))
 		hdr.Set("Content-Type", file.ContentType)
 		hdr.Set("Content-Length", strconv.FormatInt(file.Size, 10))
+		for name, value := range file.Headers {
+			hdr.Set(api.UploadHeaderPrefix+name, value)
+		}
 		w, err := mw.CreatePart(hdr)
 		if err != nil {
 			return err
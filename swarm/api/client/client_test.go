@@ -19,6 +19,7 @@ package client
 import (
 	"bytes"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -323,3 +324,64 @@ func TestClientMultipartUpload(t *testing.T) {
 		checkDownloadFile(file)
 	}
 }
+
+// TestClientUploadHeaders tests that the response headers of uploaded files
+// are served by the gateway and that their content type is detected if not
+// given, for both tar and multipart uploads
+func TestClientUploadHeaders(t *testing.T) {
+	srv := testutil.NewTestSwarmServer(t)
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	data := []byte("<!DOCTYPE html><html><body>swarm</body></html>")
+	headers := map[string]string{
+		"Cache-Control":           "max-age=3600",
+		"Content-Security-Policy": "default-src 'self'",
+	}
+	uploader := func(headers map[string]string) Uploader {
+		return UploaderFunc(func(upload UploadFn) error {
+			for _, name := range []string{"index.html", "page"} {
+				file := &File{
+					ReadCloser: ioutil.NopCloser(bytes.NewReader(data)),
+					ManifestEntry: api.ManifestEntry{
+						Path:    name,
+						Size:    int64(len(data)),
+						Headers: headers,
+					},
+				}
+				if err := upload(file); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	for name, upload := range map[string]func(string, Uploader) (string, error){
+		"tar":       client.TarUpload,
+		"multipart": client.MultipartUpload,
+	} {
+		hash, err := upload("", uploader(headers))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, path := range []string{"index.html", "page"} {
+			res, err := http.Get(srv.URL + "/bzz:/" + hash + "/" + path)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			res.Body.Close()
+			if ctype := res.Header.Get("Content-Type"); ctype != "text/html; charset=utf-8" {
+				t.Errorf("%s: %s: have content type %q, want text/html", name, path, ctype)
+			}
+			for key, value := range headers {
+				if have := res.Header.Get(key); have != value {
+					t.Errorf("%s: %s: have header %s %q, want %q", name, path, key, have, value)
+				}
+			}
+		}
+		// headers managed by the gateway are rejected
+		if _, err := upload("", uploader(map[string]string{"Content-Length": "1"})); err == nil {
+			t.Errorf("%s: reserved header accepted", name)
+		}
+	}
+}
@@ -50,6 +50,7 @@ type Config struct {
 	EnsRoot     common.Address
 	EnsAPIs     []string
 	Path        string
+	ChunkDir    string // Root of the chunk store if kept apart from Path
 	ListenAddr  string
 	Port        string
 	PublicKey   string
@@ -61,6 +62,11 @@ type Config struct {
 	Cors        string
 	BzzAccount  string
 	BootNodes   string
+
+	DeliveryReceipts bool // attach signed receipts to the chunks served to retrieve requests
+	VirtualNodes     int  // overlay identities run in addition to the node's own
+	GatewayQuotas    bool // require API keys on the http gateway and enforce their quotas
+	NamespacedAddr   bool // mix the network id into the overlay address, see network.OverlayAddr
 }
 
 //create a default config with all parameters to set to defaults
@@ -98,10 +104,18 @@ func (self *Config) Init(prvKey *ecdsa.PrivateKey) {
 		log.Error(fmt.Sprintf("Error creating root swarm data directory: %v", err))
 		return
 	}
+	chunkPath := self.Path
+	if self.ChunkDir != "" {
+		chunkPath = filepath.Join(self.ChunkDir, "bzz-"+common.Bytes2Hex(address.Bytes()))
+		if err := os.MkdirAll(chunkPath, os.ModePerm); err != nil {
+			log.Error(fmt.Sprintf("Error creating swarm chunk data directory: %v", err))
+			return
+		}
+	}
 
 	pubkey := crypto.FromECDSAPub(&prvKey.PublicKey)
 	pubkeyhex := common.ToHex(pubkey)
-	keyhex := crypto.Keccak256Hash(pubkey).Hex()
+	keyhex := common.Hash(network.OverlayAddr(pubkey, self.NetworkId, self.NamespacedAddr)).Hex()
 
 	self.PublicKey = pubkeyhex
 	self.BzzKey = keyhex
@@ -109,5 +123,5 @@ func (self *Config) Init(prvKey *ecdsa.PrivateKey) {
 	self.Swap.Init(self.Contract, prvKey)
 	self.SyncParams.Init(self.Path)
 	self.HiveParams.Init(self.Path)
-	self.StoreParams.Init(self.Path)
+	self.StoreParams.Init(chunkPath)
 }
@@ -47,6 +47,11 @@ func NewFileSystem(api *Api) *FileSystem {
 //
 // DEPRECATED: Use the HTTP API instead
 func (self *FileSystem) Upload(lpath, index string) (string, error) {
+	return self.upload(lpath, index, nil)
+}
+
+// upload is Upload counting the chunks of the files in progress
+func (self *FileSystem) upload(lpath, index string, progress *storage.Progress) (string, error) {
 	var list []*manifestTrieEntry
 	localpath, err := filepath.Abs(filepath.Clean(lpath))
 	if err != nil {
@@ -113,7 +118,7 @@ func (self *FileSystem) Upload(lpath, index string) (string, error) {
 				stat, _ := f.Stat()
 				var hash storage.Key
 				wg := &sync.WaitGroup{}
-				hash, err = self.api.dpa.Store(f, stat.Size(), wg, nil)
+				hash, err = self.api.dpa.StoreWithProgress(f, stat.Size(), wg, progress)
 				if hash != nil {
 					list[i].Hash = hash.String()
 				}
@@ -169,6 +174,15 @@ func (self *FileSystem) Upload(lpath, index string) (string, error) {
 	return hs, err2
 }
 
+// Hash returns the root hash of the manifest Upload creates for the local
+// file or directory without storing any of the content
+func (self *FileSystem) Hash(lpath, index string) (string, error) {
+	dpa := storage.NewDryRunDPA(self.api.dpa.Chunker)
+	dpa.Start()
+	defer dpa.Stop()
+	return NewFileSystem(NewApi(dpa, nil)).Upload(lpath, index)
+}
+
 // Download replicates the manifest basePath structure on the local filesystem
 // under localpath
 //
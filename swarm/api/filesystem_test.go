@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/matrix/go-matrix/common"
 	"github.com/matrix/go-matrix/swarm/storage"
@@ -192,3 +193,61 @@ func TestApiFileUploadWithRootFile(t *testing.T) {
 		checkResponse(t, resp, exp)
 	})
 }
+
+func TestApiDirHash(t *testing.T) {
+	testFileSystem(t, func(fs *FileSystem) {
+		dir := filepath.Join("testdata", "test0")
+		hash, err := fs.Hash(dir, "index.html")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if count := fs.api.dpa.ChunkStore.(*storage.LocalStore).DbCounter(); count != 0 {
+			t.Fatalf("dry run stored %d chunks", count)
+		}
+		bzzhash, err := fs.Upload(dir, "index.html")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if hash != bzzhash {
+			t.Fatalf("precomputed hash %v, uploaded as %v", hash, bzzhash)
+		}
+	})
+}
+
+func TestApiUploadAsync(t *testing.T) {
+	testApi(t, func(api *Api) {
+		jobs := NewUploadJobs(api)
+		done := make(chan *UploadStatus, 1)
+		sub := jobs.feed.Subscribe(done)
+		defer sub.Unsubscribe()
+
+		dir := filepath.Join("testdata", "test0")
+		id := jobs.UploadAsync(dir, "")
+		var status *UploadStatus
+		select {
+		case status = <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("upload job not done")
+		}
+		if status.Id != id || !status.Done || status.Error != "" {
+			t.Fatalf("unexpected final status %+v", status)
+		}
+		if status.Chunks == 0 || status.Stored != status.Chunks {
+			t.Fatalf("have %d/%d chunks stored", status.Stored, status.Chunks)
+		}
+		bzzhash, err := NewFileSystem(api).Upload(dir, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if status.Hash != bzzhash {
+			t.Fatalf("upload job hash %v, want %v", status.Hash, bzzhash)
+		}
+
+		if _, err := jobs.UploadStatus(id + 1); err == nil {
+			t.Fatal("expected error for unknown upload job")
+		}
+		if uploads := jobs.Uploads(); len(uploads) != 1 || uploads[0].Id != id {
+			t.Fatalf("unexpected upload jobs %v", uploads)
+		}
+	})
+}
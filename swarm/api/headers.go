@@ -0,0 +1,142 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package api
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// HeaderXattrPrefix prefixes the tar extended attributes carrying the
+	// response headers of the uploaded entries
+	HeaderXattrPrefix = "user.swarm.header."
+	// UploadHeaderPrefix prefixes the request or multipart part headers
+	// carrying the response headers of the uploaded entries
+	UploadHeaderPrefix = "X-Swarm-Header-"
+)
+
+// reservedHeaders are the response headers the gateway manages itself, which
+// manifest entries can't set
+var reservedHeaders = map[string]bool{
+	"Accept-Ranges":                    true,
+	"Access-Control-Allow-Credentials": true,
+	"Access-Control-Allow-Headers":     true,
+	"Access-Control-Allow-Methods":     true,
+	"Access-Control-Allow-Origin":      true,
+	"Connection":                       true,
+	"Content-Length":                   true,
+	"Content-Range":                    true,
+	"Content-Type":                     true,
+	"Date":                             true,
+	"Keep-Alive":                       true,
+	"Set-Cookie":                       true,
+	"Trailer":                          true,
+	"Transfer-Encoding":                true,
+	"Upgrade":                          true,
+}
+
+// ValidateHeaders checks the response headers of a manifest entry can be set
+// by the gateway
+func ValidateHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		if !validHeaderName(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if reservedHeaders[textproto.CanonicalMIMEHeaderKey(name)] {
+			return fmt.Errorf("header %s is set by the gateway", name)
+		}
+		if strings.ContainsAny(value, "\r\n\x00") {
+			return fmt.Errorf("invalid value of header %s", name)
+		}
+	}
+	return nil
+}
+
+// validHeaderName reports whether name is a valid header field name token
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// HeadersFromXattrs collects the response headers of an entry uploaded in a
+// tar stream from the extended attributes of its tar header
+func HeadersFromXattrs(xattrs map[string]string) (map[string]string, error) {
+	var headers map[string]string
+	for key, value := range xattrs {
+		if !strings.HasPrefix(key, HeaderXattrPrefix) {
+			continue
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[textproto.CanonicalMIMEHeaderKey(key[len(HeaderXattrPrefix):])] = value
+	}
+	return headers, ValidateHeaders(headers)
+}
+
+// HeadersFromUpload collects the response headers of an uploaded entry from
+// the prefixed headers of the upload request or multipart part
+func HeadersFromUpload(upload map[string][]string) (map[string]string, error) {
+	var headers map[string]string
+	for key, values := range upload {
+		key = textproto.CanonicalMIMEHeaderKey(key)
+		if !strings.HasPrefix(key, UploadHeaderPrefix) || len(values) == 0 {
+			continue
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[key[len(UploadHeaderPrefix):]] = values[0]
+	}
+	return headers, ValidateHeaders(headers)
+}
+
+// SetHeaders sets the response headers of a manifest entry, skipping the
+// invalid ones which may be found in manifests not uploaded via the gateway
+func SetHeaders(h http.Header, entry *ManifestEntry) {
+	for name, value := range entry.Headers {
+		if ValidateHeaders(map[string]string{name: value}) != nil {
+			continue
+		}
+		h.Set(name, value)
+	}
+}
+
+// DetectContentType returns the content type of a file given its path and
+// its first bytes, preferring the type registered for its extension as the
+// content of stylesheets or scripts is only sniffed as text
+func DetectContentType(path string, head []byte) string {
+	if ctype := mime.TypeByExtension(filepath.Ext(path)); ctype != "" {
+		return ctype
+	}
+	return http.DetectContentType(head)
+}
@@ -0,0 +1,76 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/matrix/go-matrix/swarm/api"
+)
+
+// QuotaKeyHeader is the header carrying the API key of a request to a gateway
+// with quotas. Clients which cannot set headers may pass the key in the
+// api_key query parameter instead.
+const QuotaKeyHeader = "X-Swarm-Api-Key"
+
+// quotaKey returns the API key of the request, requests without one being
+// anonymous
+func quotaKey(r *http.Request) string {
+	if key := r.Header.Get(QuotaKeyHeader); key != "" {
+		return key
+	}
+	if key := r.URL.Query().Get("api_key"); key != "" {
+		return key
+	}
+	return api.AnonymousQuotaKey
+}
+
+// quotaReader accounts the body of an upload to the API key of the request,
+// failing once the upload quota is exceeded
+type quotaReader struct {
+	io.ReadCloser
+	quotas *api.Quotas
+	key    string
+	n      int64 // bytes read so far
+}
+
+func (r *quotaReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if qerr := r.quotas.ChargeUpload(r.key, int64(n)); qerr != nil {
+			return 0, qerr
+		}
+		r.n += int64(n)
+	}
+	return n, err
+}
+
+// quotaWriter accounts the response to a download to the API key of the
+// request, failing once the download quota is exceeded
+type quotaWriter struct {
+	http.ResponseWriter
+	quotas *api.Quotas
+	key    string
+}
+
+func (w *quotaWriter) Write(p []byte) (int, error) {
+	if err := w.quotas.ChargeDownload(w.key, int64(len(p))); err != nil {
+		return 0, err
+	}
+	return w.ResponseWriter.Write(p)
+}
@@ -71,6 +71,7 @@ var (
 type ServerConfig struct {
 	Addr       string
 	CorsString string
+	Quotas     *api.Quotas // API keys and their quotas, nil if not required
 }
 
 // browser API for registering bzz url scheme handlers:
@@ -90,17 +91,24 @@ func StartHttpServer(api *api.Api, config *ServerConfig) {
 		MaxAge:         600,
 		AllowedHeaders: []string{"*"},
 	})
-	hdlr := c.Handler(NewServer(api))
+	hdlr := c.Handler(NewQuotaServer(api, config.Quotas))
 
 	go http.ListenAndServe(config.Addr, hdlr)
 }
 
 func NewServer(api *api.Api) *Server {
-	return &Server{api}
+	return &Server{api: api}
+}
+
+// NewQuotaServer returns a server which requires requests to carry an API key
+// and accounts them against its quota. If quotas is nil no key is required.
+func NewQuotaServer(api *api.Api, quotas *api.Quotas) *Server {
+	return &Server{api: api, quotas: quotas}
 }
 
 type Server struct {
-	api *api.Api
+	api    *api.Api
+	quotas *api.Quotas
 }
 
 // Request wraps http.Request and also includes the parsed bzz URI
@@ -110,6 +118,22 @@ type Request struct {
 	uri *api.URI
 }
 
+// quitC returns a channel closed once the client of the request goes away, so
+// that the chunk retrievals waiting on it are aborted. The returned function
+// releases the channel when the request is served.
+func (r *Request) quitC() (chan bool, func()) {
+	quitC := make(chan bool)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-r.Context().Done():
+			close(quitC)
+		case <-done:
+		}
+	}()
+	return quitC, func() { close(done) }
+}
+
 // HandlePostRaw handles a POST request to a raw bzz-raw:/ URI, stores the request
 // body in swarm and returns the resulting storage key as a text/plain response
 func (s *Server) HandlePostRaw(w http.ResponseWriter, r *Request) {
@@ -209,6 +233,11 @@ func (s *Server) handleTarUpload(req *Request, mw *api.ManifestWriter) error {
 			continue
 		}
 
+		headers, err := api.HeadersFromXattrs(hdr.Xattrs)
+		if err != nil {
+			return fmt.Errorf("error reading headers of %s: %s", hdr.Name, err)
+		}
+
 		// add the entry under the path from the request
 		path := path.Join(req.uri.Path, hdr.Name)
 		entry := &api.ManifestEntry{
@@ -217,6 +246,7 @@ func (s *Server) handleTarUpload(req *Request, mw *api.ManifestWriter) error {
 			Mode:        hdr.Mode,
 			Size:        hdr.Size,
 			ModTime:     hdr.ModTime,
+			Headers:     headers,
 		}
 		s.logDebug("adding %s (%d bytes) to new manifest", entry.Path, entry.Size)
 		contentKey, err := mw.AddEntry(tr, entry)
@@ -263,6 +293,11 @@ func (s *Server) handleMultipartUpload(req *Request, boundary string, mw *api.Ma
 			reader = tmp
 		}
 
+		headers, err := api.HeadersFromUpload(part.Header)
+		if err != nil {
+			return fmt.Errorf("error reading multipart headers: %s", err)
+		}
+
 		// add the entry under the path from the request
 		name := part.FileName()
 		if name == "" {
@@ -274,6 +309,7 @@ func (s *Server) handleMultipartUpload(req *Request, boundary string, mw *api.Ma
 			ContentType: part.Header.Get("Content-Type"),
 			Size:        size,
 			ModTime:     time.Now(),
+			Headers:     headers,
 		}
 		s.logDebug("adding %s (%d bytes) to new manifest", entry.Path, entry.Size)
 		contentKey, err := mw.AddEntry(reader, entry)
@@ -285,12 +321,17 @@ func (s *Server) handleMultipartUpload(req *Request, boundary string, mw *api.Ma
 }
 
 func (s *Server) handleDirectUpload(req *Request, mw *api.ManifestWriter) error {
+	headers, err := api.HeadersFromUpload(req.Header)
+	if err != nil {
+		return err
+	}
 	key, err := mw.AddEntry(req.Body, &api.ManifestEntry{
 		Path:        req.uri.Path,
 		ContentType: req.Header.Get("Content-Type"),
 		Mode:        0644,
 		Size:        req.ContentLength,
 		ModTime:     time.Now(),
+		Headers:     headers,
 	})
 	if err != nil {
 		return err
@@ -383,7 +424,9 @@ func (s *Server) HandleGet(w http.ResponseWriter, r *Request) {
 
 	// check the root chunk exists by retrieving the file's size
 	reader := s.api.Retrieve(key)
-	if _, err := reader.Size(nil); err != nil {
+	quitC, release := r.quitC()
+	defer release()
+	if _, err := reader.Size(quitC); err != nil {
 		getFail.Inc(1)
 		s.NotFound(w, r, fmt.Errorf("Root chunk not found %s: %s", key, err))
 		return
@@ -425,7 +468,9 @@ func (s *Server) HandleGetFiles(w http.ResponseWriter, r *Request) {
 		return
 	}
 
-	walker, err := s.api.NewManifestWalker(key, nil)
+	quitC, release := r.quitC()
+	defer release()
+	walker, err := s.api.NewManifestWalker(key, quitC)
 	if err != nil {
 		getFilesFail.Inc(1)
 		s.Error(w, r, err)
@@ -445,7 +490,7 @@ func (s *Server) HandleGetFiles(w http.ResponseWriter, r *Request) {
 
 		// retrieve the entry's key and size
 		reader := s.api.Retrieve(storage.Key(common.Hex2Bytes(entry.Hash)))
-		size, err := reader.Size(nil)
+		size, err := reader.Size(quitC)
 		if err != nil {
 			return err
 		}
@@ -602,7 +647,7 @@ func (s *Server) HandleGetFile(w http.ResponseWriter, r *Request) {
 		return
 	}
 
-	reader, contentType, status, err := s.api.Get(key, r.uri.Path)
+	reader, entry, status, err := s.api.GetEntry(key, r.uri.Path)
 	if err != nil {
 		switch status {
 		case http.StatusNotFound:
@@ -633,13 +678,16 @@ func (s *Server) HandleGetFile(w http.ResponseWriter, r *Request) {
 	}
 
 	// check the root chunk exists by retrieving the file's size
-	if _, err := reader.Size(nil); err != nil {
+	quitC, release := r.quitC()
+	defer release()
+	if _, err := reader.Size(quitC); err != nil {
 		getFileNotFound.Inc(1)
 		s.NotFound(w, r, fmt.Errorf("File not found %s: %s", r.uri, err))
 		return
 	}
 
-	w.Header().Set("Content-Type", contentType)
+	api.SetHeaders(w.Header(), entry)
+	w.Header().Set("Content-Type", entry.ContentType)
 
 	http.ServeContent(w, &r.Request, "", time.Now(), reader)
 }
@@ -678,6 +726,29 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	s.logDebug("%s request received for %s", r.Method, uri)
 
+	if s.quotas != nil {
+		key := quotaKey(r)
+		var err error
+		switch r.Method {
+		case "POST", "PUT":
+			err = s.quotas.CheckUpload(key, r.ContentLength)
+		default:
+			err = s.quotas.CheckDownload(key)
+		}
+		if err != nil {
+			s.QuotaError(w, req, err)
+			return
+		}
+		switch r.Method {
+		case "POST", "PUT":
+			body := &quotaReader{ReadCloser: r.Body, quotas: s.quotas, key: key}
+			req.Body = body
+			defer func() { s.quotas.ChargeChunks(key, body.n) }()
+		default:
+			w = &quotaWriter{ResponseWriter: w, quotas: s.quotas, key: key}
+		}
+	}
+
 	switch r.Method {
 	case "POST":
 		if uri.Raw() || uri.DeprecatedRaw() {
@@ -764,6 +835,14 @@ func (s *Server) Error(w http.ResponseWriter, r *Request, err error) {
 	ShowError(w, r, fmt.Sprintf("Error serving %s %s: %s", r.Request.Method, r.uri, err), http.StatusInternalServerError)
 }
 
+func (s *Server) QuotaError(w http.ResponseWriter, r *Request, err error) {
+	status := http.StatusForbidden
+	if err == api.ErrQuotaKeyUnknown {
+		status = http.StatusUnauthorized
+	}
+	ShowError(w, r, fmt.Sprintf("Error serving %s %s: %s", r.Request.Method, r.uri, err), status)
+}
+
 func (s *Server) NotFound(w http.ResponseWriter, r *Request, err error) {
 	ShowError(w, r, fmt.Sprintf("NOT FOUND error serving %s %s: %s", r.Request.Method, r.uri, err), http.StatusNotFound)
 }
@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -29,6 +31,7 @@ import (
 	"github.com/matrix/go-matrix/common"
 	"github.com/matrix/go-matrix/swarm/api"
 	swarm "github.com/matrix/go-matrix/swarm/api/client"
+	httpapi "github.com/matrix/go-matrix/swarm/api/http"
 	"github.com/matrix/go-matrix/swarm/storage"
 	"github.com/matrix/go-matrix/swarm/testutil"
 )
@@ -318,3 +321,79 @@ func TestBzzRootRedirect(t *testing.T) {
 		t.Fatalf("expected response to equal %q, got %q", data, gotData)
 	}
 }
+
+func TestBzzQuotas(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-quotas-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	quotas, err := api.NewQuotas(filepath.Join(dir, "bzz-quotas.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	quotas.Set("key", api.Quota{UploadBytes: 100, DownloadBytes: 160})
+
+	srv := testutil.NewTestSwarmQuotaServer(t, quotas)
+	defer srv.Close()
+
+	do := func(method, url, key string, body string) (int, string) {
+		req, err := http.NewRequest(method, srv.URL+url, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != "" {
+			req.Header.Set(httpapi.QuotaKeyHeader, key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(data)
+	}
+
+	data := strings.Repeat("x", 80)
+	if status, _ := do("POST", "/bzz-raw:/", "", data); status != http.StatusUnauthorized {
+		t.Fatalf("have status %d for anonymous upload, want %d", status, http.StatusUnauthorized)
+	}
+	if status, _ := do("POST", "/bzz-raw:/", "wrong", data); status != http.StatusUnauthorized {
+		t.Fatalf("have status %d for unknown key, want %d", status, http.StatusUnauthorized)
+	}
+	status, hash := do("POST", "/bzz-raw:/", "key", data)
+	if status != http.StatusOK {
+		t.Fatalf("have status %d for upload, want %d", status, http.StatusOK)
+	}
+	if status, _ := do("POST", "/bzz-raw:/", "key", data); status != http.StatusForbidden {
+		t.Fatalf("have status %d for upload over quota, want %d", status, http.StatusForbidden)
+	}
+
+	// the key may download its upload twice, but not a third time
+	if status, body := do("GET", "/bzz-raw:/"+hash, "key", ""); status != http.StatusOK || body != data {
+		t.Fatalf("have status %d and content %q, want %d and %q", status, body, http.StatusOK, data)
+	}
+	if status, body := do("GET", "/bzz-raw:/"+hash+"?api_key=key", "", ""); status != http.StatusOK || body != data {
+		t.Fatalf("have status %d and content %q, want %d and %q", status, body, http.StatusOK, data)
+	}
+	if status, _ := do("GET", "/bzz-raw:/"+hash, "key", ""); status != http.StatusForbidden {
+		t.Fatalf("have status %d for download over quota, want %d", status, http.StatusForbidden)
+	}
+
+	usage, err := quotas.Usage("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Uploaded != int64(len(data)) || usage.Chunks != 1 || usage.Downloaded != 160 {
+		t.Errorf("have usage %+v", usage)
+	}
+
+	// anonymous requests are accounted to the anonymous key once it has a quota
+	quotas.Set(api.AnonymousQuotaKey, api.Quota{})
+	if status, body := do("GET", "/bzz-raw:/"+hash, "", ""); status != http.StatusOK || body != data {
+		t.Fatalf("have status %d and content %q for anonymous download, want %d and %q", status, body, http.StatusOK, data)
+	}
+}
@@ -17,6 +17,7 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
@@ -34,6 +35,9 @@ import (
 
 const (
 	ManifestType = "application/bzz-manifest+json"
+
+	// number of leading bytes of the content used to detect its type
+	sniffLen = 512
 )
 
 // Manifest represents a swarm manifest
@@ -50,6 +54,8 @@ type ManifestEntry struct {
 	Size        int64     `json:"size,omitempty"`
 	ModTime     time.Time `json:"mod_time,omitempty"`
 	Status      int       `json:"status,omitempty"`
+
+	Headers map[string]string `json:"headers,omitempty"` // response headers set by the gateway when serving the entry
 }
 
 // ManifestList represents the result of listing files in a manifest
@@ -85,6 +91,13 @@ func (a *Api) NewManifestWriter(key storage.Key, quitC chan bool) (*ManifestWrit
 
 // AddEntry stores the given data and adds the resulting key to the manifest
 func (m *ManifestWriter) AddEntry(data io.Reader, e *ManifestEntry) (storage.Key, error) {
+	if e.ContentType == "" {
+		// sniff the content of the entry if the uploader didn't tell its type
+		buf := bufio.NewReaderSize(data, sniffLen)
+		head, _ := buf.Peek(sniffLen)
+		e.ContentType = DetectContentType(e.Path, head)
+		data = buf
+	}
 	key, err := m.api.Store(data, e.Size, nil)
 	if err != nil {
 		return nil, err
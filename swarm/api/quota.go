@@ -0,0 +1,391 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package api
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/swarm/storage"
+)
+
+// AnonymousQuotaKey is the API key requests without a key are accounted to.
+// A gateway with quotas only serves anonymous requests once a quota is set
+// for it.
+const AnonymousQuotaKey = "anonymous"
+
+// quotaSaveInterval is how often the accounting of requests is flushed to
+// disk; changes made through the management API are saved immediately
+const quotaSaveInterval = time.Minute
+
+var (
+	ErrQuotaKeyUnknown = errors.New("unknown API key")
+	ErrQuotaExceeded   = errors.New("quota exceeded")
+)
+
+// Quota limits what an API key may upload to and download from the gateway.
+// A zero limit is unlimited.
+type Quota struct {
+	UploadBytes   int64 `json:"uploadBytes"`
+	DownloadBytes int64 `json:"downloadBytes"`
+	Chunks        int64 `json:"chunks"` // chunks of the uploaded content
+}
+
+// QuotaUsage is the quota of an API key and how much of it has been used
+type QuotaUsage struct {
+	Key        string    `json:"key"`
+	Quota      Quota     `json:"quota"`
+	Uploaded   int64     `json:"uploaded"`
+	Downloaded int64     `json:"downloaded"`
+	Chunks     int64     `json:"chunks"`
+	Created    time.Time `json:"created"`
+}
+
+/*
+Quotas keeps the API keys of a shared gateway together with their quotas and
+accounts the bytes uploaded and downloaded and the chunks stored with each
+key. The accounting is persisted to a json file so that it survives restarts.
+
+Limits are checked when a request starts and enforced while its content is
+transferred, so concurrent requests with the same key may overshoot the chunk
+limit by the chunks of one upload.
+*/
+type Quotas struct {
+	path string
+
+	lock  sync.Mutex
+	keys  map[string]*QuotaUsage
+	dirty bool
+	saved time.Time
+}
+
+// NewQuotas returns the quotas persisted at path, which need not exist yet
+func NewQuotas(path string) (*Quotas, error) {
+	self := &Quotas{
+		path:  path,
+		keys:  make(map[string]*QuotaUsage),
+		saved: time.Now(),
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return self, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []*QuotaUsage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("invalid quota file %s: %v", path, err)
+	}
+	for _, usage := range keys {
+		self.keys[usage.Key] = usage
+	}
+	log.Info(fmt.Sprintf("loaded %d gateway API keys from %s", len(keys), path))
+	return self, nil
+}
+
+// Set sets the quota of the key, adding the key if it is not known yet
+func (self *Quotas) Set(key string, quota Quota) *QuotaUsage {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	usage, ok := self.keys[key]
+	if !ok {
+		usage = &QuotaUsage{Key: key, Created: time.Now()}
+		self.keys[key] = usage
+	}
+	usage.Quota = quota
+	self.dirty = true
+	return usage.copy()
+}
+
+// Remove removes the key and reports whether it was known
+func (self *Quotas) Remove(key string) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if _, ok := self.keys[key]; !ok {
+		return false
+	}
+	delete(self.keys, key)
+	self.dirty = true
+	return true
+}
+
+// Reset clears the usage accounted to the key
+func (self *Quotas) Reset(key string) (*QuotaUsage, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	usage, ok := self.keys[key]
+	if !ok {
+		return nil, ErrQuotaKeyUnknown
+	}
+	usage.Uploaded, usage.Downloaded, usage.Chunks = 0, 0, 0
+	self.dirty = true
+	return usage.copy(), nil
+}
+
+// Usage returns the quota of the key and its usage
+func (self *Quotas) Usage(key string) (*QuotaUsage, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	usage, ok := self.keys[key]
+	if !ok {
+		return nil, ErrQuotaKeyUnknown
+	}
+	return usage.copy(), nil
+}
+
+// List returns the quotas and usage of all keys ordered by key
+func (self *Quotas) List() []*QuotaUsage {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	list := make([]*QuotaUsage, 0, len(self.keys))
+	for _, usage := range self.keys {
+		list = append(list, usage.copy())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+// CheckUpload checks that the key may upload content of the given size,
+// which is negative if not known in advance
+func (self *Quotas) CheckUpload(key string, size int64) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	usage, ok := self.keys[key]
+	if !ok {
+		return ErrQuotaKeyUnknown
+	}
+	// any upload, including one of unknown size, stores at least one byte in
+	// one chunk
+	chunks := chunkCount(size)
+	if size < 1 {
+		size = 1
+	}
+	if chunks < 1 {
+		chunks = 1
+	}
+	if exceeds(usage.Uploaded, size, usage.Quota.UploadBytes) || exceeds(usage.Chunks, chunks, usage.Quota.Chunks) {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// CheckDownload checks that the key may download content
+func (self *Quotas) CheckDownload(key string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	usage, ok := self.keys[key]
+	if !ok {
+		return ErrQuotaKeyUnknown
+	}
+	if exceeds(usage.Downloaded, 1, usage.Quota.DownloadBytes) {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// ChargeUpload accounts n uploaded bytes to the key. If they exceed its
+// quota, nothing is accounted and ErrQuotaExceeded is returned.
+func (self *Quotas) ChargeUpload(key string, n int64) error {
+	return self.charge(key, func(usage *QuotaUsage) error {
+		if exceeds(usage.Uploaded, n, usage.Quota.UploadBytes) {
+			return ErrQuotaExceeded
+		}
+		usage.Uploaded += n
+		return nil
+	})
+}
+
+// ChargeDownload accounts n downloaded bytes to the key. If they exceed its
+// quota, nothing is accounted and ErrQuotaExceeded is returned.
+func (self *Quotas) ChargeDownload(key string, n int64) error {
+	return self.charge(key, func(usage *QuotaUsage) error {
+		if exceeds(usage.Downloaded, n, usage.Quota.DownloadBytes) {
+			return ErrQuotaExceeded
+		}
+		usage.Downloaded += n
+		return nil
+	})
+}
+
+// ChargeChunks accounts the chunks content of the given size is stored in to
+// the key. The chunks are already stored, so they are accounted even if they
+// exceed the quota.
+func (self *Quotas) ChargeChunks(key string, size int64) {
+	self.charge(key, func(usage *QuotaUsage) error {
+		usage.Chunks += chunkCount(size)
+		return nil
+	})
+}
+
+func (self *Quotas) charge(key string, update func(*QuotaUsage) error) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	usage, ok := self.keys[key]
+	if !ok {
+		return ErrQuotaKeyUnknown
+	}
+	if err := update(usage); err != nil {
+		return err
+	}
+	self.dirty = true
+	if time.Since(self.saved) > quotaSaveInterval {
+		if err := self.saveLocked(); err != nil {
+			log.Warn(fmt.Sprintf("unable to save gateway quotas to %s: %v", self.path, err))
+		}
+	}
+	return nil
+}
+
+// Save writes the quotas and their usage to disk if they changed
+func (self *Quotas) Save() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.saveLocked()
+}
+
+// saveLocked writes the quotas to a temporary file which is renamed into
+// place, so a crash while saving leaves the previous file intact
+// caller must hold the lock of the quotas
+func (self *Quotas) saveLocked() error {
+	if !self.dirty {
+		return nil
+	}
+	keys := make([]*QuotaUsage, 0, len(self.keys))
+	for _, usage := range self.keys {
+		keys = append(keys, usage)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := self.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, self.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	self.dirty = false
+	self.saved = time.Now()
+	return nil
+}
+
+func (self *QuotaUsage) copy() *QuotaUsage {
+	usage := *self
+	return &usage
+}
+
+// exceeds reports whether adding n to used exceeds the limit, zero limits
+// being unlimited
+func exceeds(used, n, limit int64) bool {
+	return limit > 0 && used+n > limit
+}
+
+// chunkCount returns the number of chunks the chunker splits content of the
+// given size into with the default chunker parameters, counting the
+// intermediate chunks of the tree
+func chunkCount(size int64) int64 {
+	const chunkSize = storage.DefaultBranches * 32 // branches of 32 byte hashes
+	if size <= 0 {
+		return 0
+	}
+	n := (size + chunkSize - 1) / chunkSize
+	count := n
+	for n > 1 {
+		n = (n + storage.DefaultBranches - 1) / storage.DefaultBranches
+		count += n
+	}
+	return count
+}
+
+// QuotaControl is the admin API to manage the API keys of the gateway
+type QuotaControl struct {
+	quotas *Quotas
+}
+
+func NewQuotaControl(quotas *Quotas) *QuotaControl {
+	return &QuotaControl{quotas}
+}
+
+// NewQuotaKey creates a random API key with the given quota
+func (self *QuotaControl) NewQuotaKey(quota Quota) (*QuotaUsage, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	return self.SetQuota(common.Bytes2Hex(buf), quota)
+}
+
+// SetQuota sets the quota of the API key, adding the key if it is not known
+// yet. Use AnonymousQuotaKey to allow requests without a key.
+func (self *QuotaControl) SetQuota(key string, quota Quota) (*QuotaUsage, error) {
+	if key == "" {
+		return nil, errors.New("empty API key")
+	}
+	if quota.UploadBytes < 0 || quota.DownloadBytes < 0 || quota.Chunks < 0 {
+		return nil, errors.New("negative quota")
+	}
+	usage := self.quotas.Set(key, quota)
+	return usage, self.quotas.Save()
+}
+
+// RemoveQuota removes the API key
+func (self *QuotaControl) RemoveQuota(key string) error {
+	if !self.quotas.Remove(key) {
+		return ErrQuotaKeyUnknown
+	}
+	return self.quotas.Save()
+}
+
+// ResetQuotaUsage clears the usage accounted to the API key
+func (self *QuotaControl) ResetQuotaUsage(key string) (*QuotaUsage, error) {
+	usage, err := self.quotas.Reset(key)
+	if err != nil {
+		return nil, err
+	}
+	return usage, self.quotas.Save()
+}
+
+// Quota returns the quota and usage of the API key
+func (self *QuotaControl) Quota(key string) (*QuotaUsage, error) {
+	return self.quotas.Usage(key)
+}
+
+// Quotas returns the quotas and usage of all API keys
+func (self *QuotaControl) Quotas() []*QuotaUsage {
+	return self.quotas.List()
+}
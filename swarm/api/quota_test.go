@@ -0,0 +1,137 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChunkCount(t *testing.T) {
+	for _, test := range []struct {
+		size, chunks int64
+	}{
+		{0, 0},
+		{1, 1},
+		{4096, 1},
+		{4097, 3},
+		{128 * 4096, 129},
+		{128*4096 + 1, 132},
+	} {
+		if have := chunkCount(test.size); have != test.chunks {
+			t.Errorf("size %d: have %d chunks, want %d", test.size, have, test.chunks)
+		}
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-quotas-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bzz-quotas.json")
+
+	quotas, err := NewQuotas(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	control := NewQuotaControl(quotas)
+	if err := quotas.CheckDownload("key"); err != ErrQuotaKeyUnknown {
+		t.Fatalf("have error %v for unknown key, want %v", err, ErrQuotaKeyUnknown)
+	}
+	if _, err := control.SetQuota("", Quota{}); err == nil {
+		t.Fatal("expected error setting quota of empty key")
+	}
+	if _, err := control.SetQuota("key", Quota{UploadBytes: 10000, DownloadBytes: 100, Chunks: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	// uploads
+	if err := quotas.CheckUpload("key", 10001); err != ErrQuotaExceeded {
+		t.Errorf("have error %v for upload over quota, want %v", err, ErrQuotaExceeded)
+	}
+	if err := quotas.CheckUpload("key", 4097); err != nil {
+		t.Errorf("unexpected error for upload within quota: %v", err)
+	}
+	if err := quotas.ChargeUpload("key", 4097); err != nil {
+		t.Fatal(err)
+	}
+	quotas.ChargeChunks("key", 4097)
+	if err := quotas.CheckUpload("key", 1); err != ErrQuotaExceeded {
+		t.Errorf("have error %v for upload over chunk quota, want %v", err, ErrQuotaExceeded)
+	}
+	if err := quotas.ChargeUpload("key", 6000); err != ErrQuotaExceeded {
+		t.Errorf("have error %v charging upload over quota, want %v", err, ErrQuotaExceeded)
+	}
+
+	// downloads
+	if err := quotas.ChargeDownload("key", 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := quotas.CheckDownload("key"); err != ErrQuotaExceeded {
+		t.Errorf("have error %v for download over quota, want %v", err, ErrQuotaExceeded)
+	}
+
+	usage, err := control.Quota("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Uploaded != 4097 || usage.Downloaded != 100 || usage.Chunks != 3 {
+		t.Errorf("have usage %d/%d/%d, want 4097/100/3", usage.Uploaded, usage.Downloaded, usage.Chunks)
+	}
+
+	// the usage survives a restart
+	if err := quotas.Save(); err != nil {
+		t.Fatal(err)
+	}
+	quotas, err = NewQuotas(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	control = NewQuotaControl(quotas)
+	reloaded, err := control.Quota("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Quota != usage.Quota || reloaded.Uploaded != usage.Uploaded || reloaded.Downloaded != usage.Downloaded || reloaded.Chunks != usage.Chunks {
+		t.Errorf("have reloaded usage %+v, want %+v", reloaded, usage)
+	}
+
+	if usage, err := control.ResetQuotaUsage("key"); err != nil || usage.Uploaded != 0 || usage.Downloaded != 0 || usage.Chunks != 0 {
+		t.Errorf("have usage %+v (%v) after reset", usage, err)
+	}
+	if err := quotas.CheckDownload("key"); err != nil {
+		t.Errorf("unexpected error after reset: %v", err)
+	}
+
+	generated, err := control.NewQuotaKey(Quota{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := control.Quotas(); len(list) != 2 {
+		t.Errorf("have %d keys, want 2", len(list))
+	}
+	if err := control.RemoveQuota(generated.Key); err != nil {
+		t.Fatal(err)
+	}
+	if err := control.RemoveQuota(generated.Key); err != ErrQuotaKeyUnknown {
+		t.Errorf("have error %v removing unknown key, want %v", err, ErrQuotaKeyUnknown)
+	}
+}
@@ -17,7 +17,11 @@
 package api
 
 import (
+	"context"
+
+	"github.com/matrix/go-matrix/rpc"
 	"github.com/matrix/go-matrix/swarm/network"
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
 )
 
 type Control struct {
@@ -44,3 +48,84 @@ func (self *Control) SwapEnabled(on bool) {
 func (self *Control) Hive() string {
 	return self.hive.String()
 }
+
+func (self *Control) Histogram() *kademlia.Histogram {
+	return self.hive.Histogram()
+}
+
+// Kademlia returns the routing table: the connected and known nodes per bin,
+// their connection state and the node the table would connect to next
+func (self *Control) Kademlia() *kademlia.TableInfo {
+	return self.hive.Table()
+}
+
+// Health reports the healthiness of the kademlia table given the addresses of
+// the nodes of the network, or the ones known to the node if null
+func (self *Control) Health(knownPeers []kademlia.Address) *kademlia.NodeHealth {
+	return self.hive.Health(knownPeers)
+}
+
+// TableParams returns the parameters shaping the kademlia table
+func (self *Control) TableParams() kademlia.TableParams {
+	return self.hive.TableParams()
+}
+
+// Reconfigure changes the deepest bin, neighbourhood size and bucket size of
+// the kademlia table without restarting the node, zero values keeping the
+// current ones. It returns the peers dropped from overfull bins.
+func (self *Control) Reconfigure(params kademlia.TableParams) ([]kademlia.Address, error) {
+	return self.hive.Reconfigure(params)
+}
+
+// Pin protects the node of the overlay address from being evicted from the
+// kademlia table
+func (self *Control) Pin(addr kademlia.Address) error {
+	return self.hive.Pin(addr)
+}
+
+// PinNode pins the node of the enode url, returning its overlay addresses if
+// already known. Otherwise the node is dialled and pinned once connected.
+func (self *Control) PinNode(url string) ([]kademlia.Address, error) {
+	return self.hive.PinNode(url)
+}
+
+// Unpin lifts the protection of the node of the overlay address, it returns
+// false if it was not pinned
+func (self *Control) Unpin(addr kademlia.Address) bool {
+	return self.hive.Unpin(addr)
+}
+
+// Pinned returns the overlay addresses of the pinned nodes
+func (self *Control) Pinned() []kademlia.Address {
+	return self.hive.Pinned()
+}
+
+// TableEvents creates an RPC subscription notified of the changes of the
+// kademlia table: peers added and removed, depth and neighbourhood changes
+func (self *Control) TableEvents(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan kademlia.TableEvent)
+		sub := self.hive.SubscribeTableEvents(events)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events:
+				notifier.Notify(rpcSub.ID, ev)
+			case <-sub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
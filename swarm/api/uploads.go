@@ -0,0 +1,197 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/event"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/rpc"
+	"github.com/matrix/go-matrix/swarm/storage"
+)
+
+// maxUploadJobs is the number of finished upload jobs kept for status queries
+const maxUploadJobs = 100
+
+// UploadStatus is the progress of an upload job
+type UploadStatus struct {
+	Id      uint64    `json:"id"`
+	Path    string    `json:"path"`
+	Hash    string    `json:"hash,omitempty"` // manifest hash, set once done
+	Chunks  int64     `json:"chunks"`         // chunks of the content produced so far
+	Stored  int64     `json:"stored"`         // chunks stored locally
+	Synced  int64     `json:"synced"`         // chunks handed on to peers for syncing
+	Percent float64   `json:"percent"`        // share of the chunks synced to the network
+	Done    bool      `json:"done"`           // all chunks stored locally and the manifest created
+	Error   string    `json:"error,omitempty"`
+	Started time.Time `json:"started"`
+}
+
+type uploadJob struct {
+	status   UploadStatus // guarded by the lock of the jobs
+	progress *storage.Progress
+}
+
+/*
+UploadJobs runs uploads of local files and directories in the background.
+The progress of an upload is reported as the number of chunks of its content
+stored locally and synced to the network, and a subscription notifies about
+uploads completing.
+*/
+type UploadJobs struct {
+	fs *FileSystem
+
+	lock   sync.Mutex
+	jobs   map[uint64]*uploadJob
+	lastId uint64
+	feed   event.Feed
+}
+
+func NewUploadJobs(api *Api) *UploadJobs {
+	return &UploadJobs{
+		fs:   NewFileSystem(api),
+		jobs: make(map[uint64]*uploadJob),
+	}
+}
+
+// UploadAsync starts uploading the local file or directory as Upload does and
+// returns the id of the upload job
+func (self *UploadJobs) UploadAsync(lpath, index string) uint64 {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.lastId++
+	job := &uploadJob{
+		status: UploadStatus{
+			Id:      self.lastId,
+			Path:    lpath,
+			Started: time.Now(),
+		},
+		progress: &storage.Progress{},
+	}
+	self.jobs[job.status.Id] = job
+	self.prune()
+
+	go func() {
+		hash, err := self.fs.upload(lpath, index, job.progress)
+		status := self.finish(job, hash, err)
+		log.Debug(fmt.Sprintf("upload job %d of %s done: %v, %d chunks", status.Id, lpath, hash, status.Chunks))
+		self.feed.Send(status)
+	}()
+	return job.status.Id
+}
+
+// finish records the result of the job and returns its status
+func (self *UploadJobs) finish(job *uploadJob, hash string, err error) *UploadStatus {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	job.status.Done = true
+	job.status.Hash = hash
+	if err != nil {
+		job.status.Error = err.Error()
+	}
+	return job.statusLocked()
+}
+
+// statusLocked returns the current status of the job
+// caller must hold the lock of the jobs
+func (self *uploadJob) statusLocked() *UploadStatus {
+	status := self.status
+	status.Chunks, status.Stored, status.Synced = self.progress.Counts()
+	if status.Chunks > 0 {
+		status.Percent = float64(status.Synced) * 100 / float64(status.Chunks)
+	}
+	return &status
+}
+
+// prune forgets the oldest finished jobs beyond maxUploadJobs
+// caller must hold the lock
+func (self *UploadJobs) prune() {
+	var done []uint64
+	for id, job := range self.jobs {
+		if job.status.Done {
+			done = append(done, id)
+		}
+	}
+	if len(done) <= maxUploadJobs {
+		return
+	}
+	sort.Slice(done, func(i, j int) bool { return done[i] < done[j] })
+	for _, id := range done[:len(done)-maxUploadJobs] {
+		delete(self.jobs, id)
+	}
+}
+
+// UploadStatus returns the progress of the upload job
+func (self *UploadJobs) UploadStatus(id uint64) (*UploadStatus, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	job, ok := self.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown upload job %d", id)
+	}
+	return job.statusLocked(), nil
+}
+
+// Uploads returns the progress of the upload jobs known, oldest first
+func (self *UploadJobs) Uploads() []*UploadStatus {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	statuses := make([]*UploadStatus, 0, len(self.jobs))
+	for _, job := range self.jobs {
+		statuses = append(statuses, job.statusLocked())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Id < statuses[j].Id })
+	return statuses
+}
+
+// UploadDone creates an RPC subscription notified with the final status of
+// upload jobs as they complete
+func (self *UploadJobs) UploadDone(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		statuses := make(chan *UploadStatus)
+		sub := self.feed.Subscribe(statuses)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case status := <-statuses:
+				notifier.Notify(rpcSub.ID, status)
+			case <-sub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
@@ -24,9 +24,18 @@ import (
 
 	"github.com/matrix/go-matrix/log"
 	"github.com/matrix/go-matrix/metrics"
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
 	"github.com/matrix/go-matrix/swarm/storage"
 )
 
+// the filter of retrieve requests seen recently, sized for the requests
+// arriving within the rotation interval on a busy node
+const (
+	requestFilterCapacity      = 1 << 16
+	requestFilterFalsePositive = 0.0001
+	requestFilterRotation      = 10 * time.Minute
+)
+
 //metrics variables
 var (
 	syncReceiveCount  = metrics.NewRegisteredCounter("network.sync.recv.count", nil)
@@ -34,6 +43,7 @@ var (
 	syncSendCount     = metrics.NewRegisteredCounter("network.sync.send.count", nil)
 	syncSendRefused   = metrics.NewRegisteredCounter("network.sync.send.refused", nil)
 	syncSendNotFound  = metrics.NewRegisteredCounter("network.sync.send.notfound", nil)
+	requestDuplicate  = metrics.NewRegisteredCounter("network.request.duplicate", nil)
 )
 
 // Handler for storage/retrieval related protocol requests
@@ -42,6 +52,7 @@ type Depo struct {
 	hashfunc   storage.SwarmHasher
 	localStore storage.ChunkStore
 	netStore   storage.ChunkStore
+	requests   *kademlia.AddressFilter // requester, key and id of the retrieve requests seen recently
 }
 
 func NewDepo(hash storage.SwarmHasher, localStore, remoteStore storage.ChunkStore) *Depo {
@@ -49,6 +60,7 @@ func NewDepo(hash storage.SwarmHasher, localStore, remoteStore storage.ChunkStor
 		hashfunc:   hash,
 		localStore: localStore,
 		netStore:   remoteStore, // entrypoint internal
+		requests:   kademlia.NewAddressFilter(requestFilterCapacity, requestFilterFalsePositive, requestFilterRotation),
 	}
 }
 
@@ -136,15 +148,20 @@ func (self *Depo) HandleStoreRequestMsg(req *storeRequestMsgData, p *peer) {
 	hasher := self.hashfunc()
 	hasher.Write(req.SData)
 	if !bytes.Equal(hasher.Sum(nil), req.Key) {
-		// data does not validate, ignore
-		// TODO: peer should be penalised/dropped?
+		// data does not validate, ignore and penalise the peer, dropping it
+		// once its reputation is exhausted
 		log.Warn(fmt.Sprintf("Depo.HandleStoreRequest: chunk invalid. store request ignored: %v", req))
+		p.hive.recordProtocolError(p)
 		return
 	}
 
 	if islocal {
 		return
 	}
+	if chunk.SData == nil && chunk.Req != nil {
+		// a delivery for an open request counts as a successful retrieval
+		p.hive.recordRetrieval(p)
+	}
 	// update chunk with size and data
 	chunk.SData = req.SData // protocol validates that SData is minimum 9 bytes long (int64 size  + at least one byte of data)
 	chunk.Size = int64(binary.LittleEndian.Uint64(req.SData[0:8]))
@@ -185,6 +202,7 @@ func (self *Depo) HandleRetrieveRequestMsg(req *retrieveRequestMsgData, p *peer)
 				requestTimeout: req.timeout, //
 			}
 			syncSendCount.Inc(1)
+			p.expectRetrieval(chunk.Key)
 			p.syncer.addRequest(sreq, DeliverReq)
 		} else {
 			syncSendRefused.Inc(1)
@@ -223,10 +241,46 @@ func (self *Depo) searchTimeout(rs *storage.RequestStatus, req *retrieveRequestM
 /*
 adds a new peer to an existing open request
 only add if less than requesterCount peers forwarded the same request id so far
+and the peer is not already waiting for the same request id
+the filter only tells new requests apart cheaply, possible duplicates are
+checked against the pending requesters so a false positive never drops a peer
 note this is done irrespective of status (searching or found)
 */
 func (self *Depo) addRequester(rs *storage.RequestStatus, req *retrieveRequestMsgData) {
-	log.Trace(fmt.Sprintf("Depo.addRequester: key %v - add peer to req.Id %v", req.Key.Log(), req.Id))
 	list := rs.Requesters[req.Id]
+	if self.requests.TestAndAdd(requestFilterKey(req)) && hasRequester(list, req) {
+		requestDuplicate.Inc(1)
+		log.Debug(fmt.Sprintf("Depo.addRequester: key %v - dropped duplicate req.Id %v from %v", req.Key.Log(), req.Id, req.from))
+		return
+	}
+	log.Trace(fmt.Sprintf("Depo.addRequester: key %v - add peer to req.Id %v", req.Key.Log(), req.Id))
 	rs.Requesters[req.Id] = append(list, req)
 }
+
+// hasRequester tells whether the sender of req is among the pending requesters
+func hasRequester(list []interface{}, req *retrieveRequestMsgData) bool {
+	for _, r := range list {
+		if pending, ok := r.(*retrieveRequestMsgData); ok && requesterAddr(pending) == requesterAddr(req) {
+			return true
+		}
+	}
+	return false
+}
+
+// requesterAddr returns the address of the peer sending req, zero for requests
+// of the local node
+func requesterAddr(req *retrieveRequestMsgData) (from kademlia.Address) {
+	if req.from != nil {
+		from = req.from.Addr()
+	}
+	return from
+}
+
+// requestFilterKey identifies a retrieve request by its requester, key and id
+func requestFilterKey(req *retrieveRequestMsgData) []byte {
+	from := requesterAddr(req)
+	key := make([]byte, 0, len(from)+len(req.Key)+8)
+	key = append(key, from[:]...)
+	key = append(key, req.Key...)
+	return binary.BigEndian.AppendUint64(key, req.Id)
+}
@@ -0,0 +1,61 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package network
+
+import (
+	"testing"
+
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
+	"github.com/matrix/go-matrix/swarm/storage"
+)
+
+func testRequester(b byte) *peer {
+	return &peer{bzz: &bzz{remoteAddr: &peerAddr{Addr: kademlia.Address{b}}}}
+}
+
+func TestAddRequester(t *testing.T) {
+	depo := NewDepo(nil, nil, nil)
+	rs := &storage.RequestStatus{Requesters: make(map[uint64][]interface{})}
+	key := storage.Key(make([]byte, 32))
+
+	add := func(id uint64, from *peer) {
+		depo.addRequester(rs, &retrieveRequestMsgData{Id: id, Key: key, from: from})
+	}
+	// Distinct peers are all recorded, no matter how many forward the request
+	for i := byte(1); i <= requesterCount+2; i++ {
+		add(1, testRequester(i))
+	}
+	if have := len(rs.Requesters[1]); have != requesterCount+2 {
+		t.Fatalf("requester count mismatch: have %d, want %d", have, requesterCount+2)
+	}
+	// A peer repeating a pending request is recorded once
+	add(1, testRequester(1))
+	if have := len(rs.Requesters[1]); have != requesterCount+2 {
+		t.Errorf("duplicate requester recorded: have %d requesters", have)
+	}
+	// A retry after the request was served is recorded again
+	delete(rs.Requesters, 1)
+	add(1, testRequester(1))
+	if have := len(rs.Requesters[1]); have != 1 {
+		t.Errorf("retry dropped: have %d requesters, want 1", have)
+	}
+	// The same peer asking under another id is a new requester
+	add(2, testRequester(1))
+	if have := len(rs.Requesters[2]); have != 1 {
+		t.Errorf("requester of other id dropped: have %d requesters, want 1", have)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package network
+
+import (
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/metrics"
+	"github.com/matrix/go-matrix/p2p/discover"
+)
+
+var (
+	dialAttemptCounter   = metrics.NewRegisteredCounter("network.dial.attempt.count", nil)
+	dialSuccessCounter   = metrics.NewRegisteredCounter("network.dial.success.count", nil)
+	dialThrottledCounter = metrics.NewRegisteredCounter("network.dial.throttled.count", nil)
+)
+
+// default dial limits of the hive
+const (
+	dialRate       = 1.0 // dials per second
+	dialBurst      = 8
+	dialBackoff    = 5 * time.Second
+	maxDialBackoff = 10 * time.Minute
+)
+
+/*
+dialLimiter keeps the hive from hammering peers when the network is small and
+the table keeps suggesting the same few nodes.
+
+Dials draw tokens from a bucket refilled at DialRate per second holding at most
+DialBurst of them. On top of that a node dialled but not connected is not
+dialled again before its backoff passed: DialBackoff after the first attempt,
+doubled for every further attempt up to MaxDialBackoff. The backoff is forgotten
+once the node connects, or once it was not dialled for MaxDialBackoff.
+*/
+type dialLimiter struct {
+	rate       float64
+	burst      float64
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	tokens float64
+	last   time.Time // last refill of the bucket
+	peers  map[discover.NodeID]*dialState
+	lock   sync.Mutex
+}
+
+// dialState is the backoff of a node dialled but not connected yet
+type dialState struct {
+	attempts int
+	last     time.Time // last attempt
+	next     time.Time // earliest next attempt
+}
+
+func newDialLimiter(params *HiveParams) *dialLimiter {
+	burst := float64(params.DialBurst)
+	if burst < 1 {
+		burst = 1
+	}
+	maxBackoff := params.MaxDialBackoff
+	if maxBackoff < params.DialBackoff {
+		maxBackoff = params.DialBackoff
+	}
+	return &dialLimiter{
+		rate:       params.DialRate,
+		burst:      burst,
+		backoff:    params.DialBackoff,
+		maxBackoff: maxBackoff,
+		tokens:     burst,
+		peers:      make(map[discover.NodeID]*dialState),
+	}
+}
+
+// allow reports whether the node may be dialled now and if so records the
+// attempt
+func (self *dialLimiter) allow(id discover.NodeID, now time.Time) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.expire(now)
+
+	state := self.peers[id]
+	if state != nil && now.Before(state.next) {
+		dialThrottledCounter.Inc(1)
+		return false
+	}
+	if self.rate > 0 {
+		if !self.last.IsZero() {
+			self.tokens += now.Sub(self.last).Seconds() * self.rate
+			if self.tokens > self.burst {
+				self.tokens = self.burst
+			}
+		}
+		self.last = now
+		if self.tokens < 1 {
+			dialThrottledCounter.Inc(1)
+			return false
+		}
+		self.tokens--
+	}
+
+	if state == nil {
+		state = &dialState{}
+		self.peers[id] = state
+	}
+	state.attempts++
+	state.last = now
+	state.next = now.Add(self.backoffAfter(state.attempts))
+	dialAttemptCounter.Inc(1)
+	return true
+}
+
+// backoffAfter returns the wait before the next dial of a node after the given
+// number of attempts
+func (self *dialLimiter) backoffAfter(attempts int) time.Duration {
+	wait := self.backoff
+	for i := 1; i < attempts && wait < self.maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > self.maxBackoff {
+		wait = self.maxBackoff
+	}
+	return wait
+}
+
+// connected resets the backoff of the node, counting a successful dial if it
+// was dialled
+func (self *dialLimiter) connected(id discover.NodeID) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if _, ok := self.peers[id]; ok {
+		dialSuccessCounter.Inc(1)
+		delete(self.peers, id)
+	}
+}
+
+// expire forgets the backoff of nodes not dialled for the maximum backoff,
+// caller must hold the lock
+func (self *dialLimiter) expire(now time.Time) {
+	for id, state := range self.peers {
+		if now.Sub(state.last) > self.maxBackoff {
+			delete(self.peers, id)
+		}
+	}
+}
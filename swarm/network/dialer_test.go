@@ -0,0 +1,106 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matrix/go-matrix/p2p/discover"
+)
+
+func TestDialLimiterRate(t *testing.T) {
+	params := NewDefaultHiveParams()
+	params.DialRate = 2
+	params.DialBurst = 3
+	params.DialBackoff = 0
+	dialer := newDialLimiter(params)
+
+	now := time.Now()
+	var id discover.NodeID
+	for i := 0; i < 3; i++ {
+		id[0] = byte(i)
+		if !dialer.allow(id, now) {
+			t.Fatalf("dial %d within the burst throttled", i)
+		}
+	}
+	id[0] = 3
+	if dialer.allow(id, now) {
+		t.Fatal("dial beyond the burst allowed")
+	}
+	// half a second refills one token at two dials per second
+	now = now.Add(500 * time.Millisecond)
+	if !dialer.allow(id, now) {
+		t.Fatal("dial after the refill throttled")
+	}
+	if dialer.allow(id, now) {
+		t.Fatal("second dial after the refill allowed")
+	}
+	// the bucket never holds more than the burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if !dialer.allow(id, now) {
+			t.Fatalf("dial %d after an idle hour throttled", i)
+		}
+	}
+	if dialer.allow(id, now) {
+		t.Fatal("dial beyond the burst after an idle hour allowed")
+	}
+}
+
+func TestDialLimiterBackoff(t *testing.T) {
+	params := NewDefaultHiveParams()
+	params.DialRate = 0
+	params.DialBackoff = time.Second
+	params.MaxDialBackoff = 4 * time.Second
+	dialer := newDialLimiter(params)
+
+	now := time.Now()
+	var id, other discover.NodeID
+	id[0], other[0] = 1, 2
+	// the node is backed off one, two, four and at most four seconds
+	for _, wait := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		if !dialer.allow(id, now) {
+			t.Fatalf("dial after %v backoff throttled", wait)
+		}
+		if dialer.allow(id, now.Add(wait-time.Millisecond)) {
+			t.Fatalf("dial within %v backoff allowed", wait)
+		}
+		now = now.Add(wait)
+	}
+	// other nodes are not held back
+	if !dialer.allow(other, now) {
+		t.Fatal("dial of another node throttled")
+	}
+	// a connection resets the backoff
+	if !dialer.allow(id, now) {
+		t.Fatal("dial after backoff throttled")
+	}
+	dialer.connected(id)
+	if !dialer.allow(id, now) {
+		t.Fatal("dial after connection throttled")
+	}
+	if dialer.allow(id, now) {
+		t.Fatal("redial after reset allowed")
+	}
+	// and so does not dialling the node for the maximum backoff
+	now = now.Add(params.MaxDialBackoff + time.Millisecond)
+	dialer.allow(other, now)
+	if n := len(dialer.peers); n != 1 {
+		t.Fatalf("have %d backed off nodes after expiry, want 1", n)
+	}
+}
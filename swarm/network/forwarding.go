@@ -37,11 +37,11 @@ which uses an MSB logarithmic distance-based semi-permanent Kademlia table for
 */
 
 type forwarder struct {
-	hive *Hive
+	hives Hives
 }
 
-func NewForwarder(hive *Hive) *forwarder {
-	return &forwarder{hive: hive}
+func NewForwarder(hives ...*Hive) *forwarder {
+	return &forwarder{hives: hives}
 }
 
 // generate a unique id uint64
@@ -55,7 +55,7 @@ var searchTimeout = 3 * time.Second
 // forwarding logic
 // logic propagating retrieve requests to peers given by the kademlia hive
 func (self *forwarder) Retrieve(chunk *storage.Chunk) {
-	peers := self.hive.getPeers(chunk.Key, 0)
+	peers := self.hives.getPeers(chunk.Key, 0)
 	log.Trace(fmt.Sprintf("forwarder.Retrieve: %v - received %d peers from KΛÐΞMLIΛ...", chunk.Key.Log(), len(peers)))
 OUT:
 	for _, p := range peers {
@@ -97,7 +97,7 @@ func (self *forwarder) Store(chunk *storage.Chunk) {
 	if chunk.Source != nil {
 		source = chunk.Source.(*peer)
 	}
-	for _, p := range self.hive.getPeers(chunk.Key, 0) {
+	for _, p := range self.hives.getPeers(chunk.Key, 0) {
 		log.Trace(fmt.Sprintf("forwarder.Store: %v %v", p, chunk))
 
 		if p.syncer != nil && (source == nil || p.Addr() != source.Addr()) {
@@ -106,6 +106,9 @@ func (self *forwarder) Store(chunk *storage.Chunk) {
 		}
 	}
 	log.Trace(fmt.Sprintf("forwarder.Store: sent to %v peers (chunk = %v)", n, chunk))
+	if n > 0 {
+		chunk.Progress.AddSynced()
+	}
 }
 
 // once a chunk is found deliver it to its requesters unless timed out
@@ -125,6 +128,7 @@ func (self *forwarder) Deliver(chunk *storage.Chunk) {
 			if req.timeout == nil || req.timeout.After(time.Now()) {
 				log.Trace(fmt.Sprintf("forwarder.Deliver: %v -> %v", req.Id, req.from))
 				msg.Id = uint64(id)
+				req.from.expectRetrieval(chunk.Key)
 				Deliver(req.from, msg, DeliverReq)
 				n++
 				counter--
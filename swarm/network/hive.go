@@ -19,10 +19,13 @@ package network
 import (
 	"fmt"
 	"math/rand"
+	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/event"
 	"github.com/matrix/go-matrix/log"
 	"github.com/matrix/go-matrix/metrics"
 	"github.com/matrix/go-matrix/p2p/discover"
@@ -40,6 +43,11 @@ import (
 // connections and disconnections are reported and relayed
 // to keep the nodetable uptodate
 
+// protocolErrorDropScore is the reputation score below which a peer breaking
+// the protocol is dropped; a peer without retrievals to its credit is dropped
+// on its third protocol error
+const protocolErrorDropScore = -10.0
+
 var (
 	peersNumGauge     = metrics.NewRegisteredGauge("network.peers.num", nil)
 	addPeerCounter    = metrics.NewRegisteredCounter("network.addpeer.count", nil)
@@ -52,11 +60,21 @@ type Hive struct {
 	id           discover.NodeID
 	addr         kademlia.Address
 	kad          *kademlia.Kademlia
+	refresh      *kademlia.RefreshScheduler
+	registry     *AddrRegistry // node ids and underlay of the overlay addresses seen, shared by virtual nodes
+	dialer       *dialLimiter  // rate and backoff of the dials, shared by virtual nodes
 	path         string
 	quit         chan bool
 	toggle       chan bool
 	more         chan bool
 
+	connectPeer func(string) error
+	pins        map[discover.NodeID]string // enodes pinned before their overlay address is known
+	pinLock     sync.Mutex
+
+	namespaced bool   // overlay addresses mix in the network id, see OverlayAddr
+	networkId  uint64 // network id of namespaced overlay addresses
+
 	// for testing only
 	swapEnabled bool
 	syncEnabled bool
@@ -74,6 +92,13 @@ const (
 type HiveParams struct {
 	CallInterval uint64
 	KadDbPath    string
+	AddrDbPath   string // persisted address registry, see AddrRegistry
+
+	// dial limits, see dialLimiter
+	DialRate       float64       // dials per second on average, 0 for no limit
+	DialBurst      int           // dials allowed in a row before the rate applies
+	DialBackoff    time.Duration // wait before redialling a node not connected, doubled per attempt
+	MaxDialBackoff time.Duration // cap of the wait before redialling a node
 	*kademlia.KadParams
 }
 
@@ -85,8 +110,12 @@ func NewDefaultHiveParams() *HiveParams {
 	// kad.ProxBinSize = proxBinSize
 
 	return &HiveParams{
-		CallInterval: callInterval,
-		KadParams:    kad,
+		CallInterval:   callInterval,
+		DialRate:       dialRate,
+		DialBurst:      dialBurst,
+		DialBackoff:    dialBackoff,
+		MaxDialBackoff: maxDialBackoff,
+		KadParams:      kad,
 	}
 }
 
@@ -94,6 +123,7 @@ func NewDefaultHiveParams() *HiveParams {
 //have been evaluated
 func (self *HiveParams) Init(path string) {
 	self.KadDbPath = filepath.Join(path, "bzz-peers.json")
+	self.AddrDbPath = filepath.Join(path, "bzz-addrs.json")
 }
 
 func NewHive(addr common.Hash, params *HiveParams, swapEnabled, syncEnabled bool) *Hive {
@@ -101,6 +131,10 @@ func NewHive(addr common.Hash, params *HiveParams, swapEnabled, syncEnabled bool
 	return &Hive{
 		callInterval: params.CallInterval,
 		kad:          kad,
+		refresh:      kademlia.NewRefreshScheduler(kad),
+		registry:     NewAddrRegistry(params.AddrDbPath),
+		dialer:       newDialLimiter(params),
+		pins:         make(map[discover.NodeID]string),
 		addr:         kad.Addr(),
 		path:         params.KadDbPath,
 		swapEnabled:  swapEnabled,
@@ -124,6 +158,11 @@ func (self *Hive) BlockNetworkWrite(on bool) {
 	self.blockWrite = on
 }
 
+// Registry returns the registry of the node ids of overlay addresses
+func (self *Hive) Registry() *AddrRegistry {
+	return self.registry
+}
+
 // public accessor to the hive base address
 func (self *Hive) Addr() kademlia.Address {
 	return self.addr
@@ -139,13 +178,22 @@ func (self *Hive) Start(id discover.NodeID, listenAddr func() string, connectPee
 	self.quit = make(chan bool)
 	self.id = id
 	self.listenAddr = listenAddr
+	self.pinLock.Lock()
+	self.connectPeer = connectPeer
+	self.pinLock.Unlock()
 	err = self.kad.Load(self.path, nil)
 	if err != nil {
 		log.Warn(fmt.Sprintf("Warning: error reading kaddb '%s' (skipping): %v", self.path, err))
 		err = nil
 	}
+	if err := self.registry.Load(); err != nil && !os.IsNotExist(err) {
+		log.Warn(fmt.Sprintf("Warning: error reading address registry (skipping): %v", err))
+	}
 	// this loop is doing bootstrapping and maintains a healthy table
 	go self.keepAlive()
+	if self.kad.Params().RefreshInterval > 0 {
+		go self.refreshLoop()
+	}
 	go func() {
 		// whenever toggled ask kademlia about most preferred peer
 		for alive := range self.more {
@@ -156,15 +204,21 @@ func (self *Hive) Start(id discover.NodeID, listenAddr func() string, connectPee
 			}
 			node, need, proxLimit := self.kad.Suggest()
 
-			if node != nil && len(node.Url) > 0 {
-				log.Trace(fmt.Sprintf("call known bee %v", node.Url))
-				// enode or any lower level connection address is unnecessary in future
-				// discovery table is used to look it up.
-				connectPeer(node.Url)
+			if node != nil {
+				url := node.Url
+				if len(url) == 0 {
+					// recover the underlay of nodes only known by overlay address
+					url = self.registry.Url(node.Addr)
+				}
+				if len(url) > 0 {
+					// enode or any lower level connection address is unnecessary in future
+					// discovery table is used to look it up.
+					self.dial(url, connectPeer)
+				}
 			}
 			if need {
 				// a random peer is taken from the table
-				peers := self.kad.FindClosest(kademlia.RandomAddressAt(self.addr, rand.Intn(self.kad.MaxProx)), 1)
+				peers := self.kad.FindClosest(kademlia.RandomAddressAt(self.addr, rand.Intn(self.kad.TableParams().MaxProx)), 1)
 				if len(peers) > 0 {
 					// a random address at prox bin 0 is sent for lookup
 					randAddr := kademlia.RandomAddressAt(self.addr, proxLimit)
@@ -191,6 +245,23 @@ func (self *Hive) Start(id discover.NodeID, listenAddr func() string, connectPee
 	return
 }
 
+// dial connects the node of the enode url unless the dial limiter holds it back
+func (self *Hive) dial(url string, connectPeer func(string) error) {
+	node, err := discover.ParseNode(url)
+	if err != nil {
+		log.Debug(fmt.Sprintf("invalid bee url %v: %v", url, err))
+		return
+	}
+	if !self.dialer.allow(node.ID, time.Now()) {
+		log.Trace(fmt.Sprintf("call to known bee %v throttled", url))
+		return
+	}
+	log.Trace(fmt.Sprintf("call known bee %v", url))
+	if err := connectPeer(url); err != nil {
+		log.Debug(fmt.Sprintf("unable to call bee %v: %v", url, err))
+	}
+}
+
 // keepAlive is a forever loop
 // in its awake state it periodically triggers connection attempts
 // by writing to self.more until Kademlia Table is saturated
@@ -202,6 +273,7 @@ func (self *Hive) keepAlive() {
 		peersNumGauge.Update(int64(self.kad.Count()))
 		select {
 		case <-alarm:
+			self.sampleLatencies()
 			if self.kad.DBCount() > 0 {
 				select {
 				case self.more <- true:
@@ -223,10 +295,35 @@ func (self *Hive) keepAlive() {
 	}
 }
 
+// refreshLoop looks up random addresses in the bins of the table as the
+// refresh scheduler makes them due, sparse bins more often than full ones
+func (self *Hive) refreshLoop() {
+	for {
+		po, wait := self.refresh.Next(time.Now())
+		select {
+		case <-time.After(wait):
+		case <-self.quit:
+			return
+		}
+		target := self.refresh.Target(po)
+		if peers := self.kad.FindClosest(target, 1); len(peers) > 0 {
+			if p, ok := peers[0].(*peer); ok {
+				log.Trace(fmt.Sprintf("refresh PO%03d: look up %v via %v", po, target, p))
+				p.retrieve(&retrieveRequestMsgData{Key: storage.Key(target[:])})
+			}
+		}
+		self.refresh.Refreshed(po, time.Now())
+	}
+}
+
 func (self *Hive) Stop() error {
 	// closing toggle channel quits the updateloop
 	close(self.quit)
-	return self.kad.Save(self.path, saveSync)
+	err := self.kad.Save(self.path, saveSync)
+	if rerr := self.registry.Save(); err == nil {
+		err = rerr
+	}
+	return err
 }
 
 // called at the end of a successful protocol handshake
@@ -239,6 +336,10 @@ func (self *Hive) addPeer(p *peer) error {
 		}
 	}()
 	log.Trace(fmt.Sprintf("hi new bee %v", p))
+	if p.peer != nil {
+		self.dialer.connected(p.peer.ID())
+		self.resolvePin(p.peer.ID(), p)
+	}
 	err := self.kad.On(p, loadSync)
 	if err != nil {
 		return err
@@ -258,6 +359,7 @@ func (self *Hive) addPeer(p *peer) error {
 func (self *Hive) removePeer(p *peer) {
 	removePeerCounter.Inc(1)
 	log.Debug(fmt.Sprintf("bee %v removed", p))
+	self.recordLatency(p)
 	self.kad.Off(p, saveSync)
 	select {
 	case self.more <- true:
@@ -268,6 +370,44 @@ func (self *Hive) removePeer(p *peer) {
 	}
 }
 
+// recordLatency samples the round trip time measured by the p2p layer into
+// the reputation of the peer
+func (self *Hive) recordLatency(p *peer) {
+	if p.peer == nil {
+		return
+	}
+	self.kad.RecordLatency(p.Addr(), p.peer.Latency())
+}
+
+// sampleLatencies records the current round trip time of all connected
+// peers, so that latency is known for suggestions even without retrievals
+func (self *Hive) sampleLatencies() {
+	self.kad.EachNode(func(node kademlia.Node, _ int) bool {
+		if p, ok := node.(*peer); ok {
+			self.recordLatency(p)
+		}
+		return true
+	})
+}
+
+// called after a chunk requested from the peer was delivered and validated
+func (self *Hive) recordRetrieval(p *peer) {
+	self.kad.RecordRetrieval(p.Addr())
+	self.recordLatency(p)
+}
+
+// called after the peer sent a message breaking the bzz protocol, dropping
+// peers whose reputation fell below protocolErrorDropScore
+func (self *Hive) recordProtocolError(p *peer) {
+	self.kad.RecordProtocolError(p.Addr())
+	score := self.kad.Score(p.Addr())
+	log.Debug(fmt.Sprintf("protocol error from %v, score now %.2f", p, score))
+	if score < protocolErrorDropScore {
+		log.Debug(fmt.Sprintf("dropping %v for repeated protocol errors", p))
+		p.Drop()
+	}
+}
+
 // Retrieve a list of live peers that are closer to target than us
 func (self *Hive) getPeers(target storage.Key, max int) (peers []*peer) {
 	var addr kademlia.Address
@@ -308,6 +448,14 @@ func (self *Hive) HandlePeersMsg(req *peersMsgData, from *peer) {
 			log.Trace(fmt.Sprintf("invalid peer IP %v from %v: %v", from.remoteAddr.IP, p.IP, err))
 			continue
 		}
+		if !self.validAddr(p) {
+			log.Trace(fmt.Sprintf("overlay address %v of peer %v from %v not in namespace %d", p.Addr, p, from, self.networkId))
+			continue
+		}
+		if !self.validID(p) {
+			log.Trace(fmt.Sprintf("node id of peer %v from %v does not solve the id puzzle", p, from))
+			continue
+		}
 		nrs = append(nrs, newNodeRecord(p))
 	}
 	self.kad.Add(nrs)
@@ -401,3 +549,92 @@ func (self *Hive) peers(req *retrieveRequestMsgData) {
 func (self *Hive) String() string {
 	return self.kad.String()
 }
+
+// Health reports the healthiness of the kademlia table, see kademlia.Health
+func (self *Hive) Health(knownPeers []kademlia.Address) *kademlia.NodeHealth {
+	return self.kad.Health(knownPeers)
+}
+
+// Histogram returns the population of the kademlia table per proximity order
+func (self *Hive) Histogram() *kademlia.Histogram {
+	return self.kad.Histogram()
+}
+
+// Table returns a snapshot of the kademlia table, see kademlia.Table
+func (self *Hive) Table() *kademlia.TableInfo {
+	return self.kad.Table()
+}
+
+// TableParams returns the parameters shaping the kademlia table
+func (self *Hive) TableParams() kademlia.TableParams {
+	return self.kad.TableParams()
+}
+
+// Reconfigure changes the shape of the kademlia table at runtime, see
+// kademlia.Reconfigure
+func (self *Hive) Reconfigure(params kademlia.TableParams) ([]kademlia.Address, error) {
+	return self.kad.Reconfigure(params)
+}
+
+// Pin protects the node of the overlay address from eviction, see
+// kademlia.Pin
+func (self *Hive) Pin(addr kademlia.Address) error {
+	return self.kad.Pin(addr, self.registry.Url(addr))
+}
+
+// PinNode pins the overlay addresses of the node of the enode url. If none
+// is known yet the node is dialled and its address pinned once the
+// handshake reveals it. It returns the addresses pinned right away.
+func (self *Hive) PinNode(url string) ([]kademlia.Address, error) {
+	node, err := discover.ParseNode(url)
+	if err != nil {
+		return nil, err
+	}
+	addrs := self.registry.Addrs(node.ID)
+	for _, addr := range addrs {
+		if err := self.kad.Pin(addr, self.registry.Url(addr)); err != nil {
+			return nil, err
+		}
+	}
+	if len(addrs) > 0 {
+		return addrs, nil
+	}
+	self.pinLock.Lock()
+	self.pins[node.ID] = url
+	connectPeer := self.connectPeer
+	self.pinLock.Unlock()
+	if connectPeer != nil {
+		if err := connectPeer(url); err != nil {
+			log.Warn(fmt.Sprintf("unable to connect pinned node %v: %v", url, err))
+		}
+	}
+	return nil, nil
+}
+
+// resolvePin pins the address of a peer pinned by enode
+func (self *Hive) resolvePin(id discover.NodeID, p *peer) {
+	self.pinLock.Lock()
+	_, ok := self.pins[id]
+	delete(self.pins, id)
+	self.pinLock.Unlock()
+	if ok {
+		log.Info(fmt.Sprintf("pinned node %v has overlay address %v", id, p.Addr()))
+		self.kad.Pin(p.Addr(), p.Url())
+	}
+}
+
+// Unpin lifts the protection of the node of the overlay address
+func (self *Hive) Unpin(addr kademlia.Address) bool {
+	return self.kad.Unpin(addr)
+}
+
+// Pinned returns the overlay addresses of the pinned nodes
+func (self *Hive) Pinned() []kademlia.Address {
+	return self.kad.Pinned()
+}
+
+// SubscribeTableEvents registers a channel notified of the changes of the
+// kademlia table, see kademlia.SubscribeEvents
+func (self *Hive) SubscribeTableEvents(ch chan<- kademlia.TableEvent) event.Subscription {
+	return self.kad.SubscribeEvents(ch)
+}
@@ -17,7 +17,11 @@
 package kademlia
 
 import (
+	crand "crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"math/bits"
 	"math/rand"
 	"strings"
 
@@ -30,13 +34,52 @@ func (a Address) String() string {
 	return fmt.Sprintf("%x", a[:])
 }
 
-func (a *Address) MarshalJSON() (out []byte, err error) {
+// MarshalText encodes the address as hex without 0x prefix, as String does
+func (a Address) MarshalText() ([]byte, error) {
+	out := make([]byte, hex.EncodedLen(len(a)))
+	hex.Encode(out, a[:])
+	return out, nil
+}
+
+// UnmarshalText parses an address of exactly 64 hex digits, with or without
+// 0x prefix
+func (a *Address) UnmarshalText(input []byte) error {
+	if len(input) >= 2 && input[0] == '0' && (input[1] == 'x' || input[1] == 'X') {
+		input = input[2:]
+	}
+	if want := hex.EncodedLen(len(a)); len(input) != want {
+		return fmt.Errorf("invalid address %q: have %d hex digits, want %d", abbreviate(input), len(input), want)
+	}
+	var addr Address
+	if _, err := hex.Decode(addr[:], input); err != nil {
+		return fmt.Errorf("invalid address %q: %v", abbreviate(input), err)
+	}
+	*a = addr
+	return nil
+}
+
+func (a Address) MarshalJSON() (out []byte, err error) {
 	return []byte(`"` + a.String() + `"`), nil
 }
 
+// UnmarshalJSON parses an address from a JSON string, null is left as is
 func (a *Address) UnmarshalJSON(value []byte) error {
-	*a = Address(common.HexToHash(string(value[1 : len(value)-1])))
-	return nil
+	if string(value) == "null" {
+		return nil
+	}
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return fmt.Errorf("invalid address %q: not a JSON string", abbreviate(value))
+	}
+	return a.UnmarshalText(value[1 : len(value)-1])
+}
+
+// abbreviate shortens malformed input quoted in errors
+func abbreviate(input []byte) string {
+	const max = 80
+	if len(input) > max {
+		return string(input[:max]) + "..."
+	}
+	return string(input)
 }
 
 // the string form of the binary representation of an address (only first 8 bits)
@@ -62,14 +105,20 @@ It is calculated by counting the number of common leading zeros in the (MSB)
 binary representation of the x^y.
 
 (0 farthest, 255 closest, 256 self)
+
+The leading zeros of the first differing byte are looked up instead of being
+counted bit by bit, as the hive recalculates proximities for every peer.
 */
 func proximity(one, other Address) (ret int) {
+	return proximityBytes(one[:], other[:])
+}
+
+// proximityBytes calculates the proximity order of two equal length byte
+// sequences.
+func proximityBytes(one, other []byte) int {
 	for i := 0; i < len(one); i++ {
-		oxo := one[i] ^ other[i]
-		for j := 0; j < 8; j++ {
-			if (oxo>>uint8(7-j))&0x01 != 0 {
-				return i*8 + j
-			}
+		if oxo := one[i] ^ other[i]; oxo != 0 {
+			return i*8 + bits.LeadingZeros8(oxo)
 		}
 	}
 	return len(one) * 8
@@ -79,6 +128,12 @@ func proximity(one, other Address) (ret int) {
 // Returns -1 if a is closer to target, 1 if b is closer to target
 // and 0 if they are equal.
 func (target Address) ProxCmp(a, b Address) int {
+	return proxCmpBytes(target[:], a[:], b[:])
+}
+
+// proxCmpBytes compares the distances a->target and b->target of equal length
+// byte sequences.
+func proxCmpBytes(target, a, b []byte) int {
 	for i := range target {
 		da := a[i] ^ target[i]
 		db := b[i] ^ target[i]
@@ -94,26 +149,73 @@ func (target Address) ProxCmp(a, b Address) int {
 // randomAddressAt(address, prox) generates a random address
 // at proximity order prox relative to address
 // if prox is negative a random address is generated
+// if prox is 256 or more, address itself is returned as the only one that close
 func RandomAddressAt(self Address, prox int) (addr Address) {
 	addr = self
+	randomizeAt(addr[:], prox, globalRand)
+	return
+}
+
+// RandomAddressAtFrom is RandomAddressAt drawing its randomness from src, so
+// simulations seeding their own source generate reproducible addresses. The
+// source is not safe for concurrent use and must not be shared by goroutines.
+func RandomAddressAtFrom(self Address, prox int, src *rand.Rand) (addr Address) {
+	addr = self
+	randomizeAt(addr[:], prox, src)
+	return
+}
+
+// SecureRandomAddressAt is RandomAddressAt drawing its randomness from the
+// cryptographically secure system source. A prox above 256 is an error.
+func SecureRandomAddressAt(self Address, prox int) (addr Address, err error) {
+	addr = self
+	err = randomizeAt(addr[:], prox, crand.Reader)
+	return
+}
+
+// globalRand reads from the shared math/rand source, which is safe for
+// concurrent use.
+var globalRand io.Reader = globalSource{}
+
+type globalSource struct{}
+
+func (globalSource) Read(p []byte) (int, error) { return rand.Read(p) }
+
+// randomizeAt randomizes the bits of addr following the first prox ones, and
+// flips the bit at prox so addr ends up at proximity order prox from its
+// original value. If prox is negative all bits are randomized. If prox is the
+// length of addr in bits, addr is the only address that close and is left
+// unchanged, while a larger prox is an error. The random bits are read from
+// src, whose errors are returned with addr left unchanged.
+func randomizeAt(addr []byte, prox int, src io.Reader) error {
+	if max := len(addr) * 8; prox >= max {
+		if prox > max {
+			return fmt.Errorf("proximity order %d out of range, want at most %d", prox, max)
+		}
+		return nil
+	}
 	var pos int
 	if prox >= 0 {
 		pos = prox / 8
-		trans := prox % 8
-		transbytea := byte(0)
-		for j := 0; j <= trans; j++ {
-			transbytea |= 1 << uint8(7-j)
-		}
-		flipbyte := byte(1 << uint8(7-trans))
-		transbyteb := transbytea ^ byte(255)
-		randbyte := byte(rand.Intn(255))
-		addr[pos] = ((addr[pos] & transbytea) ^ flipbyte) | randbyte&transbyteb
 	}
-	for i := pos + 1; i < len(addr); i++ {
-		addr[i] = byte(rand.Intn(255))
+	entropy := make([]byte, len(addr)-pos)
+	if _, err := io.ReadFull(src, entropy); err != nil {
+		return err
 	}
-
-	return
+	if prox < 0 {
+		copy(addr, entropy)
+		return nil
+	}
+	trans := prox % 8
+	transbytea := byte(0)
+	for j := 0; j <= trans; j++ {
+		transbytea |= 1 << uint8(7-j)
+	}
+	flipbyte := byte(1 << uint8(7-trans))
+	transbyteb := transbytea ^ byte(255)
+	addr[pos] = ((addr[pos] & transbytea) ^ flipbyte) | entropy[0]&transbyteb
+	copy(addr[pos+1:], entropy[1:])
+	return nil
 }
 
 // KeyRange(a0, a1, proxLimit) returns the address inclusive address
@@ -129,13 +231,24 @@ func KeyRange(one, other Address, proxLimit int) (start, stop Address) {
 }
 
 func CommonBitsAddrF(self, other Address, f func() byte, p int) (addr Address) {
-	prox := proximity(self, other)
+	addr = self
+	commonBits(addr[:], other[:], f, p)
+	return
+}
+
+// commonBits rewrites addr, initially a copy of self, to share the first
+// min(p, proximity) bits with other, filling the remaining bits with f.
+func commonBits(addr, other []byte, f func() byte, p int) {
+	prox := proximityBytes(addr, other)
 	var pos int
 	if p <= prox {
 		prox = p
 	}
 	pos = prox / 8
-	addr = self
+	// identical addresses sharing all bits leave nothing to fill
+	if pos == len(addr) {
+		return
+	}
 	trans := byte(prox % 8)
 	var transbytea byte
 	if p > prox {
@@ -155,8 +268,6 @@ func CommonBitsAddrF(self, other Address, f func() byte, p int) (addr Address) {
 	for i := pos + 1; i < len(addr); i++ {
 		addr[i] = f()
 	}
-
-	return
 }
 
 func CommonBitsAddr(self, other Address, prox int) (addr Address) {
@@ -171,3 +282,14 @@ func CommonBitsAddrByte(self, other Address, b byte, prox int) (addr Address) {
 func RandomAddress() Address {
 	return RandomAddressAt(Address{}, -1)
 }
+
+// RandomAddressFrom generates a random address drawing its randomness from src.
+func RandomAddressFrom(src *rand.Rand) Address {
+	return RandomAddressAtFrom(Address{}, -1, src)
+}
+
+// SecureRandomAddress generates a random address from the cryptographically
+// secure system source, suitable for node identities.
+func SecureRandomAddress() (Address, error) {
+	return SecureRandomAddressAt(Address{}, -1)
+}
@@ -17,8 +17,11 @@
 package kademlia
 
 import (
+	"encoding/json"
+	"errors"
 	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/matrix/go-matrix/common"
@@ -94,3 +97,217 @@ func TestRandomAddressAt(t *testing.T) {
 		}
 	}
 }
+
+func TestRandomAddressAtFrom(t *testing.T) {
+	// Identically seeded sources generate identical addresses
+	one, other := rand.New(rand.NewSource(1)), rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		a, b := RandomAddressFrom(one), RandomAddressFrom(other)
+		if a != b {
+			t.Fatalf("address %d: seeded addresses mismatch: %v != %v", i, a, b)
+		}
+		prox := rand.Intn(256)
+		if c, d := RandomAddressAtFrom(a, prox, one), RandomAddressAtFrom(b, prox, other); c != d {
+			t.Fatalf("address %d: seeded addresses at %d mismatch: %v != %v", i, prox, c, d)
+		} else if proximity(a, c) != prox {
+			t.Fatalf("incorrect address prox(%v, %v) == %v (expected %v)", a, c, proximity(a, c), prox)
+		}
+	}
+	if RandomAddressFrom(rand.New(rand.NewSource(1))) == RandomAddressFrom(rand.New(rand.NewSource(2))) {
+		t.Fatalf("differently seeded addresses match")
+	}
+}
+
+func TestSecureRandomAddressAt(t *testing.T) {
+	a, err := SecureRandomAddress()
+	if err != nil {
+		t.Fatalf("failed to generate address: %v", err)
+	}
+	for prox := 0; prox <= 256; prox++ {
+		b, err := SecureRandomAddressAt(a, prox)
+		if err != nil {
+			t.Fatalf("failed to generate address at %d: %v", prox, err)
+		}
+		if proximity(a, b) != prox {
+			t.Fatalf("incorrect address prox(%v, %v) == %v (expected %v)", a, b, proximity(a, b), prox)
+		}
+	}
+	if _, err := SecureRandomAddressAt(a, 257); err == nil {
+		t.Fatalf("address generated beyond the address length")
+	}
+	// the deepest bin, reachable by reconfiguring the table, doesn't panic
+	if b := RandomAddressAt(a, 256); b != a {
+		t.Fatalf("have %v at full proximity, want %v", b, a)
+	}
+	if b := RandomAddressAt(a, 300); b != a {
+		t.Fatalf("have %v beyond full proximity, want %v", b, a)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("no entropy") }
+
+func TestRandomizeAtFailure(t *testing.T) {
+	a := RandomAddress()
+	b := a
+	if err := randomizeAt(b[:], 10, failingReader{}); err == nil {
+		t.Fatalf("randomizing succeeded without entropy")
+	}
+	if a != b {
+		t.Fatalf("address modified on failure: %v != %v", b, a)
+	}
+}
+
+// proximityBitwise is the reference bit by bit proximity calculation.
+func proximityBitwise(one, other Address) int {
+	for i := 0; i < len(one); i++ {
+		oxo := one[i] ^ other[i]
+		for j := 0; j < 8; j++ {
+			if (oxo>>uint8(7-j))&0x01 != 0 {
+				return i*8 + j
+			}
+		}
+	}
+	return len(one) * 8
+}
+
+func TestProximity(t *testing.T) {
+	a := RandomAddress()
+	if prox := proximity(a, a); prox != 256 {
+		t.Fatalf("self proximity mismatch: have %d, want 256", prox)
+	}
+	// Flip every single bit, then check random addresses against the reference
+	for bit := 0; bit < 256; bit++ {
+		b := a
+		b[bit/8] ^= 0x80 >> uint(bit%8)
+		if prox := proximity(a, b); prox != bit {
+			t.Fatalf("bit %d: proximity mismatch: have %d, want %d", bit, prox, bit)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		b := RandomAddressAt(a, rand.Intn(256))
+		if have, want := proximity(a, b), proximityBitwise(a, b); have != want {
+			t.Fatalf("proximity(%v, %v) mismatch: have %d, want %d", a, b, have, want)
+		}
+	}
+}
+
+func BenchmarkProximityFar(b *testing.B)   { benchmarkProximity(b, 1) }
+func BenchmarkProximityNear(b *testing.B)  { benchmarkProximity(b, 200) }
+func BenchmarkProximitySelf(b *testing.B)  { benchmarkProximity(b, 256) }
+func BenchmarkProximityTable(b *testing.B) { benchmarkProximityTable(b, 4096) }
+
+// benchmarkProximity measures the proximity of two addresses sharing the given
+// number of leading bits.
+func benchmarkProximity(b *testing.B, prox int) {
+	one := RandomAddress()
+	other := one
+	if prox < 256 {
+		other = RandomAddressAt(one, prox)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		proximity(one, other)
+	}
+}
+
+// benchmarkProximityTable measures recalculating the proximities of a routing
+// table of the given size, as the hive does when its base address changes.
+func benchmarkProximityTable(b *testing.B, size int) {
+	base := RandomAddress()
+	peers := make([]Address, size)
+	for i := range peers {
+		peers[i] = RandomAddressAt(base, rand.Intn(64))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, peer := range peers {
+			proximity(base, peer)
+		}
+	}
+}
+
+func TestAddressMarshalText(t *testing.T) {
+	const hexAddr = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	want := Address(common.HexToHash(hexAddr))
+
+	for _, input := range []string{hexAddr, "0x" + hexAddr, "0X0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF"} {
+		var addr Address
+		if err := addr.UnmarshalText([]byte(input)); err != nil {
+			t.Errorf("%s: %v", input, err)
+		} else if addr != want {
+			t.Errorf("%s: have %v, want %v", input, addr, want)
+		}
+	}
+	text, _ := want.MarshalText()
+	if string(text) != hexAddr {
+		t.Errorf("have text %s, want %s", text, hexAddr)
+	}
+
+	for _, input := range []string{
+		"",
+		"0x",
+		hexAddr[2:],
+		hexAddr + "00",
+		"zz" + hexAddr[2:],
+		"0x0x" + hexAddr[4:],
+	} {
+		addr := want
+		if err := addr.UnmarshalText([]byte(input)); err == nil {
+			t.Errorf("%q: no error", input)
+		} else if addr != want {
+			t.Errorf("%q: address changed to %v on error", input, addr)
+		}
+	}
+}
+
+func TestAddressMarshalJSON(t *testing.T) {
+	a := Address(common.HexToHash("0x0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"))
+	b := Address(common.HexToHash("0x8123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"))
+
+	// addresses as values, through pointers and as map keys
+	type config struct {
+		Self  Address
+		Boot  *Address
+		Peers map[Address]string
+	}
+	in := config{Self: a, Boot: &b, Peers: map[Address]string{a: "a", b: "b"}}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out config
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("%s: %v", data, err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("have %+v, want %+v", out, in)
+	}
+
+	for _, input := range []string{
+		`"0x` + a.String() + `"`,
+		`null`,
+	} {
+		addr := a
+		if err := json.Unmarshal([]byte(input), &addr); err != nil || addr != a {
+			t.Errorf("%s: have %v, %v", input, addr, err)
+		}
+	}
+	for _, input := range []string{
+		`""`,
+		`"`,
+		`123`,
+		`"` + a.String()[1:] + `"`,
+		`"` + a.String() + `0"`,
+		`{"` + a.String() + `":1}`,
+		`[` + strings.Repeat(`1,`, 100) + `1]`,
+	} {
+		var addr Address
+		if err := addr.UnmarshalJSON([]byte(input)); err == nil {
+			t.Errorf("%s: no error", input)
+		} else if len(err.Error()) > 200 {
+			t.Errorf("%s: error not abbreviated: %v", input, err)
+		}
+	}
+}
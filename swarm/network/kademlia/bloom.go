@@ -0,0 +1,173 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+// AddressFilter is a bloom filter answering whether an address, or any other
+// key, was added recently. It never forgets a key it was given within the
+// last rotation, but may claim to have seen a key it was not given at about
+// the configured false positive rate.
+//
+// The filter keeps two generations. Keys are added to the current one, which
+// replaces the previous one once it holds its capacity of keys or the rotation
+// interval elapsed, so the memory used stays constant however many keys pass.
+type AddressFilter struct {
+	capacity int           // keys added to a generation before it is rotated
+	interval time.Duration // age of a generation before it is rotated, 0 for no limit
+	bits     uint64        // bits per generation
+	hashes   int           // bits set per key
+
+	current, previous []uint64
+	added             int       // keys added to the current generation
+	started           time.Time // creation time of the current generation
+	lock              sync.Mutex
+}
+
+// NewAddressFilter creates a filter remembering at least capacity keys added
+// within the rotation interval (0 for no time limit) with the given false
+// positive rate.
+func NewAddressFilter(capacity int, falsePositive float64, interval time.Duration) *AddressFilter {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if falsePositive <= 0 || falsePositive >= 1 {
+		falsePositive = 0.01
+	}
+	// the optimal size and number of hashes of a bloom filter for n keys at a
+	// false positive rate p: m = -n ln p / (ln 2)^2, k = m/n ln 2
+	// both generations are tested, so each is sized for p/2
+	p := falsePositive / 2
+	m := math.Ceil(-float64(capacity) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := int(math.Round(m / float64(capacity) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	bits := (uint64(m) + 63) / 64 * 64
+	return &AddressFilter{
+		capacity: capacity,
+		interval: interval,
+		bits:     bits,
+		hashes:   k,
+		current:  make([]uint64, bits/64),
+		previous: make([]uint64, bits/64),
+		started:  time.Now(),
+	}
+}
+
+// Add adds a key to the filter
+func (self *AddressFilter) Add(key []byte) {
+	self.TestAndAdd(key)
+}
+
+// Test tells whether the key was added recently
+func (self *AddressFilter) Test(key []byte) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.expire(time.Now())
+	h1, h2 := filterHashes(key)
+	return self.test(self.current, h1, h2) || self.test(self.previous, h1, h2)
+}
+
+// TestAndAdd adds a key to the filter, telling whether it was added recently
+func (self *AddressFilter) TestAndAdd(key []byte) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	now := time.Now()
+	self.expire(now)
+	if self.added >= self.capacity {
+		self.rotate(now)
+	}
+	h1, h2 := filterHashes(key)
+	if self.test(self.current, h1, h2) {
+		return true
+	}
+	seen := self.test(self.previous, h1, h2)
+	for i := 0; i < self.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % self.bits
+		self.current[bit/64] |= 1 << (bit % 64)
+	}
+	self.added++
+	return seen
+}
+
+// Reset forgets all keys
+func (self *AddressFilter) Reset() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	for i := range self.current {
+		self.current[i], self.previous[i] = 0, 0
+	}
+	self.added, self.started = 0, time.Now()
+}
+
+// test checks the bits of a key in a generation
+func (self *AddressFilter) test(gen []uint64, h1, h2 uint64) bool {
+	for i := 0; i < self.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % self.bits
+		if gen[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// expire rotates out the generations older than the rotation interval
+// caller holds the lock
+func (self *AddressFilter) expire(now time.Time) {
+	if self.interval == 0 {
+		return
+	}
+	age := now.Sub(self.started)
+	if age >= 2*self.interval {
+		// the previous generation expired as well
+		self.rotate(now)
+	}
+	if age >= self.interval {
+		self.rotate(now)
+	}
+}
+
+// rotate starts a new generation, dropping the previous one
+// caller holds the lock
+func (self *AddressFilter) rotate(now time.Time) {
+	self.current, self.previous = self.previous, self.current
+	for i := range self.current {
+		self.current[i] = 0
+	}
+	self.added, self.started = 0, now
+}
+
+// filterHashes derives the two hashes the bit positions of a key are combined
+// from (Kirsch-Mitzenmacher double hashing)
+func filterHashes(key []byte) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write(key)
+	sum := h.Sum(nil)
+	h1, h2 := binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:])
+	// an odd step visits distinct bits for every hash of the key
+	return h1, h2 | 1
+}
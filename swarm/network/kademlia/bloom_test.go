@@ -0,0 +1,99 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestAddressFilter(t *testing.T) {
+	const n = 1000
+	filter := NewAddressFilter(n, 0.01, 0)
+	var addrs []Address
+	for i := 0; i < n; i++ {
+		addr := RandomAddress()
+		if filter.TestAndAdd(addr[:]) {
+			t.Logf("false positive adding %v", addr)
+		}
+		addrs = append(addrs, addr)
+	}
+	for _, addr := range addrs {
+		if !filter.Test(addr[:]) {
+			t.Fatalf("added address %v not found", addr)
+		}
+	}
+
+	// the false positive rate of unseen addresses is about the configured one
+	var positives int
+	for i := 0; i < 10*n; i++ {
+		addr := RandomAddress()
+		if filter.Test(addr[:]) {
+			positives++
+		}
+	}
+	if rate := float64(positives) / (10 * n); rate > 0.02 {
+		t.Errorf("false positive rate %.4f, want about 0.01", rate)
+	}
+
+	filter.Reset()
+	for _, addr := range addrs {
+		if filter.Test(addr[:]) {
+			t.Fatalf("address %v found after reset", addr)
+		}
+	}
+}
+
+func TestAddressFilterRotation(t *testing.T) {
+	const n = 100
+	filter := NewAddressFilter(n, 0.001, 0)
+	key := func(i int) []byte {
+		return binary.BigEndian.AppendUint64(nil, uint64(i))
+	}
+	// the keys of the previous generation are kept until the next rotation
+	for i := 0; i < 2*n; i++ {
+		filter.Add(key(i))
+	}
+	for i := 0; i < 2*n; i++ {
+		if !filter.Test(key(i)) {
+			t.Fatalf("key %d forgotten before its generation was rotated out", i)
+		}
+	}
+	for i := 2 * n; i < 3*n; i++ {
+		filter.Add(key(i))
+	}
+	var kept int
+	for i := 0; i < n; i++ {
+		if filter.Test(key(i)) {
+			kept++
+		}
+	}
+	if kept > 1 {
+		t.Errorf("%d keys of a rotated out generation still found", kept)
+	}
+
+	// generations expire after the rotation interval
+	filter = NewAddressFilter(n, 0.001, 10*time.Millisecond)
+	filter.Add(key(0))
+	time.Sleep(25 * time.Millisecond)
+	filter.Test(key(1))
+	time.Sleep(25 * time.Millisecond)
+	if filter.Test(key(0)) {
+		t.Errorf("key found after two rotation intervals")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"container/heap"
+	"math/big"
+	"sort"
+)
+
+// Distance returns the XOR distance between a and b, the big endian integer
+// value of a^b. Proximity is the discrete logarithmic scaling of it.
+func Distance(a, b Address) *big.Int {
+	var d Address
+	for i := range d {
+		d[i] = a[i] ^ b[i]
+	}
+	return new(big.Int).SetBytes(d[:])
+}
+
+// DistanceCmp compares the distances a->target and b->target without
+// allocating. Returns -1 if a is closer to target, 1 if b is closer to target
+// and 0 if they are equal.
+func DistanceCmp(target, a, b Address) int {
+	return proxCmpBytes(target[:], a[:], b[:])
+}
+
+// SortByDistance sorts addrs in place by increasing distance to target.
+func SortByDistance(target Address, addrs []Address) {
+	sort.Sort(&distanceSorter{target, addrs})
+}
+
+type distanceSorter struct {
+	target Address
+	addrs  []Address
+}
+
+func (s *distanceSorter) Len() int      { return len(s.addrs) }
+func (s *distanceSorter) Swap(i, j int) { s.addrs[i], s.addrs[j] = s.addrs[j], s.addrs[i] }
+func (s *distanceSorter) Less(i, j int) bool {
+	return DistanceCmp(s.target, s.addrs[i], s.addrs[j]) < 0
+}
+
+// NearestN returns the n addresses among candidates closest to target,
+// nearest first, all of them if there are fewer. Instead of sorting all
+// candidates, the n nearest seen so far are kept in a max-heap, so picking
+// the k closest of m peers takes O(m log k). The candidates are left as is.
+func NearestN(target Address, candidates []Address, n int) []Address {
+	indexes := NearestIndexes(target, len(candidates), n, func(i int) Address { return candidates[i] })
+	nearest := make([]Address, len(indexes))
+	for i, j := range indexes {
+		nearest[i] = candidates[j]
+	}
+	return nearest
+}
+
+// NearestIndexes is NearestN for a collection of m items of which addr
+// returns the addresses, it returns the indexes of the nearest ones. Items
+// at the same distance keep their order.
+func NearestIndexes(target Address, m, n int, addr func(i int) Address) []int {
+	if n <= 0 {
+		return nil
+	}
+	if n > m {
+		n = m
+	}
+	h := &nearestHeap{target: target, addr: addr, indexes: make([]int, 0, n)}
+	for i := 0; i < m; i++ {
+		if len(h.indexes) < n {
+			heap.Push(h, i)
+		} else if h.farther(h.indexes[0], i) {
+			h.indexes[0] = i
+			heap.Fix(h, 0)
+		}
+	}
+	// popping yields the farthest first
+	nearest := make([]int, len(h.indexes))
+	for i := len(nearest) - 1; i >= 0; i-- {
+		nearest[i] = heap.Pop(h).(int)
+	}
+	return nearest
+}
+
+// nearestHeap is a max-heap of item indexes keyed on the distance of their
+// addresses to the target, the farthest item on top
+type nearestHeap struct {
+	target  Address
+	addr    func(int) Address
+	indexes []int
+}
+
+// farther reports whether item i is farther from the target than item j,
+// the later one being farther among equals
+func (h *nearestHeap) farther(i, j int) bool {
+	if c := DistanceCmp(h.target, h.addr(i), h.addr(j)); c != 0 {
+		return c > 0
+	}
+	return i > j
+}
+
+func (h *nearestHeap) Len() int           { return len(h.indexes) }
+func (h *nearestHeap) Less(i, j int) bool { return h.farther(h.indexes[i], h.indexes[j]) }
+func (h *nearestHeap) Swap(i, j int)      { h.indexes[i], h.indexes[j] = h.indexes[j], h.indexes[i] }
+func (h *nearestHeap) Push(x interface{}) { h.indexes = append(h.indexes, x.(int)) }
+func (h *nearestHeap) Pop() interface{} {
+	last := h.indexes[len(h.indexes)-1]
+	h.indexes = h.indexes[:len(h.indexes)-1]
+	return last
+}
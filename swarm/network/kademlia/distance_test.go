@@ -0,0 +1,146 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	a := RandomAddress()
+	if d := Distance(a, a); d.Sign() != 0 {
+		t.Fatalf("self distance mismatch: have %v, want 0", d)
+	}
+	var zero, max Address
+	for i := range max {
+		max[i] = 0xff
+	}
+	want := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	if d := Distance(zero, max); d.Cmp(want) != 0 {
+		t.Fatalf("maximum distance mismatch: have %x, want %x", d, want)
+	}
+	for i := 0; i < 1000; i++ {
+		b, c := RandomAddressAt(a, rand.Intn(256)), RandomAddressAt(a, rand.Intn(256))
+		if Distance(a, b).Cmp(Distance(b, a)) != 0 {
+			t.Fatalf("distance not symmetric for %v, %v", a, b)
+		}
+		// The distance has 256-proximity significant bits
+		if have, want := Distance(a, b).BitLen(), 256-proximity(a, b); have != want {
+			t.Fatalf("distance bit length mismatch: have %d, want %d", have, want)
+		}
+		if have, want := DistanceCmp(a, b, c), Distance(a, b).Cmp(Distance(a, c)); have != want {
+			t.Fatalf("distance comparison mismatch: have %d, want %d", have, want)
+		}
+	}
+}
+
+func TestSortByDistance(t *testing.T) {
+	target := RandomAddress()
+	addrs := make([]Address, 256)
+	for i := range addrs {
+		addrs[i] = RandomAddressAt(target, rand.Intn(256))
+	}
+	addrs[0] = target
+
+	SortByDistance(target, addrs)
+	if addrs[0] != target {
+		t.Fatalf("target not sorted first: have %v", addrs[0])
+	}
+	for i := 1; i < len(addrs); i++ {
+		if Distance(target, addrs[i-1]).Cmp(Distance(target, addrs[i])) > 0 {
+			t.Fatalf("addresses %d and %d out of order", i-1, i)
+		}
+	}
+}
+
+func TestNearestN(t *testing.T) {
+	target := RandomAddress()
+	candidates := make([]Address, 256)
+	for i := range candidates {
+		candidates[i] = RandomAddressAt(target, rand.Intn(256))
+	}
+	candidates[17] = candidates[3] // equal distances must not upset the heap
+	original := append([]Address{}, candidates...)
+
+	sorted := append([]Address{}, candidates...)
+	SortByDistance(target, sorted)
+	for _, n := range []int{1, 2, 16, 255, 256, 300} {
+		nearest := NearestN(target, candidates, n)
+		want := n
+		if want > len(candidates) {
+			want = len(candidates)
+		}
+		if len(nearest) != want {
+			t.Fatalf("n=%d: have %d addresses, want %d", n, len(nearest), want)
+		}
+		for i := range nearest {
+			if DistanceCmp(target, nearest[i], sorted[i]) != 0 {
+				t.Fatalf("n=%d: address %d at distance %x, want %x", n, i, Distance(target, nearest[i]), Distance(target, sorted[i]))
+			}
+		}
+	}
+	for i := range candidates {
+		if candidates[i] != original[i] {
+			t.Fatalf("candidate %d modified", i)
+		}
+	}
+	if nearest := NearestN(target, candidates, 0); len(nearest) != 0 {
+		t.Fatalf("have %d addresses for n=0, want none", len(nearest))
+	}
+	if nearest := NearestN(target, nil, 4); len(nearest) != 0 {
+		t.Fatalf("have %d addresses without candidates, want none", len(nearest))
+	}
+}
+
+func TestNearestIndexesStable(t *testing.T) {
+	target := RandomAddress()
+	addr := RandomAddressAt(target, 3)
+	indexes := NearestIndexes(target, 8, 4, func(int) Address { return addr })
+	for i, j := range indexes {
+		if i != j {
+			t.Fatalf("have indexes %v among equals, want the first ones in order", indexes)
+		}
+	}
+}
+
+func BenchmarkNearestN(b *testing.B) {
+	target := RandomAddress()
+	candidates := make([]Address, 1024)
+	for i := range candidates {
+		candidates[i] = RandomAddress()
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NearestN(target, candidates, 8)
+	}
+}
+
+func BenchmarkSortByDistance(b *testing.B) {
+	target := RandomAddress()
+	candidates := make([]Address, 1024)
+	for i := range candidates {
+		candidates[i] = RandomAddress()
+	}
+	addrs := make([]Address, len(candidates))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(addrs, candidates)
+		SortByDistance(target, addrs)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"github.com/matrix/go-matrix/event"
+)
+
+// TableEventType names a kind of change of the kademlia table
+type TableEventType string
+
+const (
+	PeerAdded            TableEventType = "peerAdded"            // a node joined the table
+	PeerRemoved          TableEventType = "peerRemoved"          // a node left the table, dropped or replaced
+	DepthChanged         TableEventType = "depthChanged"         // the proximity limit of the nearest bins moved
+	NeighbourhoodChanged TableEventType = "neighbourhoodChanged" // the nodes of the nearest bins changed
+)
+
+// TableEvent is a change of the kademlia table sent to the subscribers of its
+// event feed. Peer and PO are only set for the events about a single node.
+type TableEvent struct {
+	Type  TableEventType `json:"type"`
+	Peer  *Address       `json:"peer,omitempty"`
+	PO    int            `json:"po"`    // proximity order of the peer
+	Depth int            `json:"depth"` // neighbourhood depth (proxLimit) after the change
+	Count int            `json:"count"` // number of active peers after the change
+}
+
+// SubscribeEvents registers a channel notified of the changes of the table,
+// in the order they happen. Events are sent once the table is unlocked, so
+// subscribers may query the table, but the table waits for slow subscribers.
+func (self *Kademlia) SubscribeEvents(ch chan<- TableEvent) event.Subscription {
+	return self.feed.Subscribe(ch)
+}
+
+// peerEvents returns the events of a node joining or leaving the given bin,
+// given the depth of the table before the change.
+// caller holds the lock
+func (self *Kademlia) peerEvents(typ TableEventType, addr Address, index, depth int) []TableEvent {
+	events := []TableEvent{{Type: typ, Peer: &addr, PO: index, Depth: self.proxLimit, Count: self.count}}
+	if self.proxLimit != depth {
+		events = append(events, TableEvent{Type: DepthChanged, Depth: self.proxLimit, Count: self.count})
+	}
+	// the neighbourhood spans the bins from the depth up
+	if self.proxLimit != depth || index >= depth {
+		events = append(events, TableEvent{Type: NeighbourhoodChanged, Depth: self.proxLimit, Count: self.count})
+	}
+	return events
+}
+
+// notify sends events to the subscribers of the table
+// caller must not hold the lock
+func (self *Kademlia) notify(events []TableEvent) {
+	for _, ev := range events {
+		self.feed.Send(ev)
+	}
+}
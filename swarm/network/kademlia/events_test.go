@@ -0,0 +1,79 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests that changes of the table are reported once it is unlocked, with depth
+// and neighbourhood changes following the peer changes causing them.
+func TestTableEvents(t *testing.T) {
+	params := NewDefaultKadParams()
+	params.ProxBinSize = 2
+	kad := New(Address{}, params)
+
+	events := make(chan TableEvent)
+	sub := kad.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+
+	nodes := []*testNode{
+		{RandomAddressAt(Address{}, 0)},
+		{RandomAddressAt(Address{}, 1)},
+		{RandomAddressAt(Address{}, 2)},
+		{RandomAddressAt(Address{}, 0)},
+	}
+	expect := func(change func(), want ...TableEventType) {
+		go change()
+		for i, typ := range want {
+			select {
+			case ev := <-events:
+				if ev.Type != typ {
+					t.Fatalf("event %d: have %s, want %s", i, ev.Type, typ)
+				}
+				// the table must be unlocked while notifying
+				if ev.Count != kad.Count() {
+					t.Fatalf("event %d: have count %d, table has %d", i, ev.Count, kad.Count())
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("event %d: timeout waiting for %s", i, typ)
+			}
+		}
+		select {
+		case ev := <-events:
+			t.Fatalf("unexpected event %s", ev.Type)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	on := func(n *testNode) func() { return func() { kad.On(n, nil) } }
+	off := func(n *testNode) func() { return func() { kad.Off(n, nil) } }
+
+	expect(on(nodes[0]), PeerAdded, NeighbourhoodChanged)
+	expect(on(nodes[1]), PeerAdded, NeighbourhoodChanged)
+	expect(on(nodes[2]), PeerAdded, DepthChanged, NeighbourhoodChanged)
+	if depth := kad.Histogram().Depth; depth != 1 {
+		t.Fatalf("depth mismatch: have %d, want 1", depth)
+	}
+	// a node outside the neighbourhood doesn't change it
+	expect(on(nodes[3]), PeerAdded)
+	expect(off(nodes[3]), PeerRemoved)
+	expect(off(nodes[2]), PeerRemoved, DepthChanged, NeighbourhoodChanged)
+	if depth := kad.Histogram().Depth; depth != 0 {
+		t.Fatalf("depth mismatch: have %d, want 0", depth)
+	}
+}
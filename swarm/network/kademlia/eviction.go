@@ -0,0 +1,135 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/matrix/go-matrix/metrics"
+)
+
+// names of the built-in eviction policies
+const (
+	EvictLRU            = "lru"        // the node idle for the longest time
+	EvictLeastSeen      = "least-seen" // the node connected for the longest time
+	EvictHighestLatency = "latency"    // the node with the highest round trip time
+	EvictMostFailures   = "failures"   // the node with the most failed connection attempts
+)
+
+// EvictionCandidate is an active node of a full bucket which may be dropped
+// to make room for a new node
+type EvictionCandidate struct {
+	Node    Node
+	Record  *NodeRecord   // node record of the node, nil if not known
+	Idle    time.Duration // time since the node was last active
+	Latency time.Duration // round trip time of the node, 0 if not reported
+}
+
+// EvictionPolicy chooses which node of a full bucket is replaced by a new
+// node. Only nodes idle for longer than MaxIdleInterval are candidates, so a
+// bucket of nodes all in use is never churned, unless the new node is pinned.
+// Pinned nodes are never candidates.
+type EvictionPolicy interface {
+	// Name identifies the policy in logs and metrics
+	Name() string
+	// Evict returns the index of the candidate to drop, -1 to keep them all
+	Evict(candidates []*EvictionCandidate) int
+}
+
+// LatencyNode is implemented by nodes which measure their round trip time,
+// the latency policy treats other nodes as having none
+type LatencyNode interface {
+	Latency() time.Duration
+}
+
+// evictionPolicies are the built-in policies by name
+var evictionPolicies = map[string]EvictionPolicy{
+	EvictLRU: &rankedEviction{EvictLRU, func(c *EvictionCandidate) float64 {
+		return float64(c.Idle)
+	}},
+	EvictLeastSeen: &rankedEviction{EvictLeastSeen, func(c *EvictionCandidate) float64 {
+		if c.Record == nil {
+			return 0
+		}
+		return float64(time.Since(c.Record.Seen))
+	}},
+	EvictHighestLatency: &rankedEviction{EvictHighestLatency, func(c *EvictionCandidate) float64 {
+		return float64(c.Latency)
+	}},
+	EvictMostFailures: &rankedEviction{EvictMostFailures, func(c *EvictionCandidate) float64 {
+		if c.Record == nil {
+			return 0
+		}
+		return float64(c.Record.Failures)
+	}},
+}
+
+// NewEvictionPolicy returns the built-in policy of the given name, the LRU
+// policy if name is empty
+func NewEvictionPolicy(name string) (EvictionPolicy, error) {
+	if name == "" {
+		name = EvictLRU
+	}
+	policy, ok := evictionPolicies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown eviction policy %q (have %v)", name, EvictionPolicies())
+	}
+	return policy, nil
+}
+
+// EvictionPolicies returns the names of the built-in policies
+func EvictionPolicies() []string {
+	var names []string
+	for name := range evictionPolicies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// rankedEviction evicts the candidate ranked highest, breaking ties by idle
+// time
+type rankedEviction struct {
+	name string
+	rank func(*EvictionCandidate) float64
+}
+
+func (self *rankedEviction) Name() string {
+	return self.name
+}
+
+func (self *rankedEviction) Evict(candidates []*EvictionCandidate) int {
+	pos := -1
+	var best float64
+	for i, c := range candidates {
+		r := self.rank(c)
+		if pos < 0 || r > best || r == best && c.Idle > candidates[pos].Idle {
+			pos, best = i, r
+		}
+	}
+	return pos
+}
+
+// evictionKeptAll counts the nodes not added to a full bucket because none of
+// its nodes was evicted, the nodes evicted are counted per policy
+var evictionKeptAll = metrics.NewRegisteredCounter("network.kademlia.evict.none", nil)
+
+func evictionCounter(policy EvictionPolicy) metrics.Counter {
+	return metrics.GetOrRegisterCounter("network.kademlia.evict."+policy.Name(), nil)
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"testing"
+	"time"
+)
+
+type evictNode struct {
+	testNode
+	idle    time.Duration
+	latency time.Duration
+	dropped bool
+}
+
+func (n *evictNode) LastActive() time.Time {
+	return time.Now().Add(-n.idle)
+}
+
+func (n *evictNode) Latency() time.Duration {
+	return n.latency
+}
+
+func (n *evictNode) Drop() {
+	n.dropped = true
+}
+
+type keepAll struct{}
+
+func (keepAll) Name() string                              { return "keep" }
+func (keepAll) Evict(candidates []*EvictionCandidate) int { return -1 }
+
+func TestEvictionPolicy(t *testing.T) {
+	self := RandomAddress()
+	for _, test := range []struct {
+		eviction string
+		policy   EvictionPolicy
+		evicted  int // index of the node evicted, -1 if none
+	}{
+		{eviction: "", evicted: 1},
+		{eviction: EvictLRU, evicted: 1},
+		{eviction: EvictLeastSeen, evicted: 2},
+		{eviction: EvictHighestLatency, evicted: 0},
+		{eviction: EvictMostFailures, evicted: 2},
+		{eviction: "unknown", evicted: 1},
+		{policy: keepAll{}, evicted: -1},
+	} {
+		params := NewDefaultKadParams()
+		params.BucketSize = 4
+		params.MaxIdleInterval = time.Minute
+		params.Eviction = test.eviction
+		params.EvictionPolicy = test.policy
+		kad := New(self, params)
+
+		nodes := []*evictNode{
+			{idle: time.Hour, latency: 50 * time.Millisecond},
+			{idle: 2 * time.Hour, latency: 10 * time.Millisecond},
+			{idle: 90 * time.Minute},
+			{idle: time.Second, latency: time.Second}, // active, never evicted
+		}
+		for i, n := range nodes {
+			n.addr = RandomAddressAt(self, 0)
+			if err := kad.On(n, nil); err != nil {
+				t.Fatalf("%q: node %d not added: %v", test.eviction, i, err)
+			}
+		}
+		kad.db.lock.Lock()
+		for i, n := range nodes {
+			record := kad.db.index[n.addr]
+			record.Seen = time.Now().Add(-time.Duration(i+1) * time.Minute)
+			record.Failures = i
+		}
+		kad.db.index[nodes[2].addr].Seen = time.Now().Add(-time.Hour)
+		kad.db.lock.Unlock()
+
+		err := kad.On(&evictNode{testNode: testNode{addr: RandomAddressAt(self, 0)}}, nil)
+		if test.evicted < 0 {
+			if err == nil {
+				t.Errorf("%q: expected bucket full", test.eviction)
+			}
+		} else if err != nil {
+			t.Errorf("%q: node not added: %v", test.eviction, err)
+		}
+		for i, n := range nodes {
+			if n.dropped != (i == test.evicted) {
+				t.Errorf("%q: node %d dropped: %v, want evicted node %d", test.eviction, i, n.dropped, test.evicted)
+			}
+		}
+	}
+}
+
+func TestNewEvictionPolicy(t *testing.T) {
+	for _, name := range EvictionPolicies() {
+		policy, err := NewEvictionPolicy(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if policy.Name() != name {
+			t.Errorf("have policy %q, want %q", policy.Name(), name)
+		}
+	}
+	if _, err := NewEvictionPolicy("unknown"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
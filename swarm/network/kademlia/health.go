@@ -0,0 +1,222 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package kademlia
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+NodeHealth reports how well the table of a node fits the network, judged by a
+global view of all nodes.
+
+The nearest neighbourhood of a node is the deepest proximity order such that at
+least ProxBinSize nodes of the network are at least that close. A healthy node
+* is connected to its nearest neighbours, as far as the bins can hold them
+* has a connection in every shallower bin the network has nodes in
+* has no connections to nodes that left the network
+*/
+type NodeHealth struct {
+	Addr      Address
+	Depth     int       // proximity order of the nearest neighbourhood
+	Peers     int       // number of active connections
+	MissingNN []Address // nearest neighbours not connected although their bin has room
+	EmptyBins []int     // bins shallower than depth empty although the network has nodes there
+	Stale     []Address // connections to nodes not in the network
+}
+
+// GotNN tells whether the node is connected to all its nearest neighbours
+func (self *NodeHealth) GotNN() bool {
+	return len(self.MissingNN) == 0
+}
+
+// Healthy tells whether the node has all the connections it needs
+func (self *NodeHealth) Healthy() bool {
+	return self.GotNN() && len(self.EmptyBins) == 0 && len(self.Stale) == 0
+}
+
+func (self *NodeHealth) String() string {
+	return fmt.Sprintf("%v: depth %d, %d peers, %d nearest neighbours missing, empty bins %v, %d stale peers", self.Addr.String()[:8], self.Depth, self.Peers, len(self.MissingNN), self.EmptyBins, len(self.Stale))
+}
+
+// Health is the healthiness of a whole network
+type Health struct {
+	Nodes      []*NodeHealth // per node reports, sorted by address
+	Partitions int           // number of disconnected components of the network
+}
+
+// Healthy tells whether the network is fully connected and all its nodes are healthy
+func (self *Health) Healthy() bool {
+	return self.Err() == nil
+}
+
+// Err describes the first few healthiness violations, nil if the network is healthy
+func (self *Health) Err() error {
+	var unhealthy []*NodeHealth
+	for _, node := range self.Nodes {
+		if !node.Healthy() {
+			unhealthy = append(unhealthy, node)
+		}
+	}
+	if self.Partitions <= 1 && len(unhealthy) == 0 {
+		return nil
+	}
+	msg := fmt.Sprintf("%d partitions, %d/%d nodes unhealthy", self.Partitions, len(unhealthy), len(self.Nodes))
+	for i, node := range unhealthy {
+		if i == 3 {
+			msg += ", ..."
+			break
+		}
+		msg += "; " + node.String()
+	}
+	return fmt.Errorf("kademlia unhealthy: %s", msg)
+}
+
+// CheckHealth asserts the healthiness of a network given the tables of all its nodes
+func CheckHealth(tables []*Kademlia) *Health {
+	live := make(map[Address]bool, len(tables))
+	for _, kad := range tables {
+		live[kad.Addr()] = true
+	}
+	health := &Health{}
+	for _, kad := range tables {
+		health.Nodes = append(health.Nodes, checkNode(kad, live))
+	}
+	sort.Slice(health.Nodes, func(i, j int) bool {
+		a, b := health.Nodes[i].Addr, health.Nodes[j].Addr
+		return string(a[:]) < string(b[:])
+	})
+	health.Partitions = partitions(tables, live)
+	return health
+}
+
+// Health reports the healthiness of the table given the addresses of the nodes
+// of the network. If knownPeers is nil, the node records of the kaddb are taken
+// as the network instead.
+func (self *Kademlia) Health(knownPeers []Address) *NodeHealth {
+	live := make(map[Address]bool, len(knownPeers)+1)
+	if knownPeers == nil {
+		self.db.lock.RLock()
+		for addr := range self.db.index {
+			live[addr] = true
+		}
+		self.db.lock.RUnlock()
+	}
+	for _, addr := range knownPeers {
+		live[addr] = true
+	}
+	live[self.addr] = true
+	return checkNode(self, live)
+}
+
+// Healthy tells whether the table is connected to its nearest neighbourhood
+// and has a peer in every shallower bin the network has nodes in, see Health
+func (self *Kademlia) Healthy(knownPeers []Address) bool {
+	return self.Health(knownPeers).Healthy()
+}
+
+// checkNode compares the table of a single node to the global view
+func checkNode(kad *Kademlia, live map[Address]bool) *NodeHealth {
+	self := kad.Addr()
+	params := kad.TableParams()
+
+	// count the network nodes and the connections of the table per bin
+	available := make([]int, params.MaxProx+1)
+	connected := make([]int, params.MaxProx+1)
+	peers := make(map[Address]bool)
+	for addr := range live {
+		if addr != self {
+			available[binOf(self, addr, params.MaxProx)]++
+		}
+	}
+	health := &NodeHealth{Addr: self}
+	kad.EachNode(func(node Node, po int) bool {
+		if !live[node.Addr()] {
+			health.Stale = append(health.Stale, node.Addr())
+			return true
+		}
+		if po > params.MaxProx {
+			// the table was reconfigured meanwhile
+			po = params.MaxProx
+		}
+		peers[node.Addr()] = true
+		connected[po]++
+		health.Peers++
+		return true
+	})
+	// the nearest neighbourhood is the deepest bin range holding enough nodes
+	var closer int
+	for po := params.MaxProx; po >= 0; po-- {
+		closer += available[po]
+		if closer >= params.ProxBinSize || po == 0 {
+			health.Depth = po
+			break
+		}
+	}
+	for po := 0; po < health.Depth; po++ {
+		if available[po] > 0 && connected[po] == 0 {
+			health.EmptyBins = append(health.EmptyBins, po)
+		}
+	}
+	for addr := range live {
+		if addr == self || peers[addr] {
+			continue
+		}
+		if po := binOf(self, addr, params.MaxProx); po >= health.Depth && connected[po] < params.BucketSize {
+			health.MissingNN = append(health.MissingNN, addr)
+		}
+	}
+	return health
+}
+
+// partitions counts the connected components of the graph of table connections
+func partitions(tables []*Kademlia, live map[Address]bool) int {
+	parent := make(map[Address]Address, len(live))
+	var find func(Address) Address
+	find = func(addr Address) Address {
+		if parent[addr] != addr {
+			parent[addr] = find(parent[addr])
+		}
+		return parent[addr]
+	}
+	for addr := range live {
+		parent[addr] = addr
+	}
+	for _, kad := range tables {
+		kad.EachNode(func(node Node, po int) bool {
+			if live[node.Addr()] {
+				parent[find(node.Addr())] = find(kad.Addr())
+			}
+			return true
+		})
+	}
+	var n int
+	for addr := range live {
+		if find(addr) == addr {
+			n++
+		}
+	}
+	return n
+}
+
+// binOf returns the bin of other in a table of self with the given deepest bin
+func binOf(self, other Address, maxProx int) int {
+	if po := proximity(self, other); po < maxProx {
+		return po
+	}
+	return maxProx
+}
@@ -0,0 +1,139 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package kademlia
+
+import (
+	"testing"
+)
+
+// meshTables connects all pairs of the given addresses the tables accept,
+// except for the pairs skipped
+func meshTables(addrs []Address, skip func(a, b Address) bool) []*Kademlia {
+	tables := make([]*Kademlia, len(addrs))
+	for i, addr := range addrs {
+		tables[i] = New(addr, NewDefaultKadParams())
+	}
+	for i := range tables {
+		for j := i + 1; j < len(tables); j++ {
+			a, b := addrs[i], addrs[j]
+			if skip != nil && (skip(a, b) || skip(b, a)) {
+				continue
+			}
+			if tables[i].On(&testNode{addr: b}, nil) != nil {
+				continue
+			}
+			if tables[j].On(&testNode{addr: a}, nil) != nil {
+				tables[i].Off(&testNode{addr: b}, nil)
+			}
+		}
+	}
+	return tables
+}
+
+func testAddrs(n int) []Address {
+	addrs := make([]Address, n)
+	for i := range addrs {
+		addrs[i] = gen(Address{}, quickrand).(Address)
+	}
+	return addrs
+}
+
+func TestHealthFullMesh(t *testing.T) {
+	// no more nodes than fit in a single bucket so that no connection is refused
+	health := CheckHealth(meshTables(testAddrs(bucketSize+1), nil))
+	if err := health.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if health.Partitions != 1 || len(health.Nodes) != bucketSize+1 {
+		t.Fatalf("have %d partitions of %d nodes, want 1 of %d", health.Partitions, len(health.Nodes), bucketSize+1)
+	}
+}
+
+func TestHealthMissingNeighbour(t *testing.T) {
+	addrs := testAddrs(6)
+	// find the closest pair, which are each other's nearest neighbours
+	var x, y Address
+	best := -1
+	for i := range addrs {
+		for j := i + 1; j < len(addrs); j++ {
+			if po := proximity(addrs[i], addrs[j]); po > best {
+				best, x, y = po, addrs[i], addrs[j]
+			}
+		}
+	}
+	health := CheckHealth(meshTables(addrs, func(a, b Address) bool { return a == x && b == y }))
+	if health.Healthy() {
+		t.Fatal("network missing nearest neighbour connection reported healthy")
+	}
+	for _, node := range health.Nodes {
+		if node.Addr != x && node.Addr != y {
+			continue
+		}
+		if node.GotNN() {
+			t.Errorf("%v: nearest neighbour not reported missing", node.Addr)
+		}
+	}
+}
+
+func TestHealthPartitions(t *testing.T) {
+	one, other := testAddrs(4), testAddrs(4)
+	tables := append(meshTables(one, nil), meshTables(other, nil)...)
+
+	health := CheckHealth(tables)
+	if health.Partitions != 2 {
+		t.Errorf("have %d partitions, want 2", health.Partitions)
+	}
+	if health.Healthy() {
+		t.Error("partitioned network reported healthy")
+	}
+}
+
+func TestHealthStalePeers(t *testing.T) {
+	addrs := testAddrs(4)
+	tables := meshTables(addrs, nil)
+	// the last node leaves without the others noticing
+	health := CheckHealth(tables[:3])
+	for _, node := range health.Nodes {
+		if len(node.Stale) != 1 || node.Stale[0] != addrs[3] {
+			t.Errorf("%v: have stale peers %v, want %v", node.Addr, node.Stale, addrs[3])
+		}
+	}
+}
+
+func TestTableHealth(t *testing.T) {
+	addrs := testAddrs(bucketSize + 1)
+	tables := meshTables(addrs, nil)
+	for _, kad := range tables {
+		if !kad.Healthy(addrs) {
+			t.Errorf("%v: table unhealthy: %v", kad.Addr(), kad.Health(addrs))
+		}
+	}
+	// a node of the network not connected to is a missing nearest neighbour
+	// if its bin has room, while the kaddb view only knows the peers
+	kad := tables[0]
+	other := RandomAddressAt(kad.Addr(), kad.MaxProx)
+	health := kad.Health(append(addrs, other))
+	if len(health.MissingNN) != 1 || health.MissingNN[0] != other {
+		t.Errorf("have missing nearest neighbours %v, want %v", health.MissingNN, other)
+	}
+	if !kad.Healthy(nil) {
+		t.Errorf("table unhealthy by its kaddb: %v", kad.Health(nil))
+	}
+	kad.Add([]*NodeRecord{{Addr: other}})
+	if kad.Healthy(nil) {
+		t.Error("table missing a known nearest neighbour reported healthy")
+	}
+}
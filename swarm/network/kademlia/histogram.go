@@ -0,0 +1,105 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/matrix/go-matrix/metrics"
+)
+
+// gauges of the table saturation, per proximity order where indexed
+var (
+	depthGauge     = metrics.NewRegisteredGauge("network.kademlia.depth", nil)
+	connectedGauge = metrics.NewRegisteredGauge("network.kademlia.connected", nil)
+	knownGauge     = metrics.NewRegisteredGauge("network.kademlia.known", nil)
+
+	binConnectedGauges []metrics.Gauge
+	binKnownGauges     []metrics.Gauge
+)
+
+// Bin is the population of a single proximity bin of the table
+type Bin struct {
+	PO        int `json:"po"`
+	Connected int `json:"connected"` // peers with a live connection
+	Known     int `json:"known"`     // node records in the kaddb
+}
+
+// Histogram is the population of the table per proximity order
+type Histogram struct {
+	Depth     int   `json:"depth"` // proximity order of the nearest neighbourhood bin (proxLimit)
+	Connected int   `json:"connected"`
+	Known     int   `json:"known"`
+	Bins      []Bin `json:"bins"`
+}
+
+func (self *Histogram) String() string {
+	var rows []string
+	rows = append(rows, fmt.Sprintf("depth: %d, connected: %d, known: %d", self.Depth, self.Connected, self.Known))
+	for _, bin := range self.Bins {
+		row := fmt.Sprintf("%03d %3d/%-3d %s", bin.PO, bin.Connected, bin.Known, strings.Repeat("#", bin.Connected))
+		if bin.PO == self.Depth {
+			row += " <- depth"
+		}
+		rows = append(rows, row)
+	}
+	return strings.Join(rows, "\n")
+}
+
+// Histogram returns the number of connected and known peers per proximity
+// order along with the neighbourhood depth of the table
+func (self *Kademlia) Histogram() *Histogram {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.histogram()
+}
+
+// caller holds the lock
+func (self *Kademlia) histogram() *Histogram {
+	self.db.lock.RLock()
+	defer self.db.lock.RUnlock()
+
+	h := &Histogram{
+		Depth:     self.proxLimit,
+		Connected: self.count,
+		Known:     len(self.db.index),
+		Bins:      make([]Bin, len(self.buckets)),
+	}
+	for po, bucket := range self.buckets {
+		h.Bins[po] = Bin{PO: po, Connected: len(bucket), Known: len(self.db.Nodes[po])}
+	}
+	return h
+}
+
+// updateGauges reports the histogram of the table to the metrics registry
+// caller holds the lock
+func (self *Kademlia) updateGauges() {
+	if !metrics.Enabled {
+		return
+	}
+	h := self.histogram()
+	depthGauge.Update(int64(h.Depth))
+	connectedGauge.Update(int64(h.Connected))
+	knownGauge.Update(int64(h.Known))
+	for _, bin := range h.Bins {
+		if bin.PO < len(binConnectedGauges) {
+			binConnectedGauges[bin.PO].Update(int64(bin.Connected))
+			binKnownGauges[bin.PO].Update(int64(bin.Known))
+		}
+	}
+}
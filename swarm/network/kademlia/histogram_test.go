@@ -0,0 +1,56 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	self := RandomAddress()
+	kad := New(self, NewDefaultKadParams())
+
+	connected := map[int]int{0: 2, 1: 1, 3: 1}
+	for po, n := range connected {
+		for i := 0; i < n; i++ {
+			if err := kad.On(&testNode{addr: RandomAddressAt(self, po)}, nil); err != nil {
+				t.Fatalf("failed to add node at PO %d: %v", po, err)
+			}
+		}
+	}
+	kad.Add([]*NodeRecord{
+		{Addr: RandomAddressAt(self, 5)},
+		{Addr: RandomAddressAt(self, 5)},
+	})
+
+	h := kad.Histogram()
+	if h.Connected != 4 || h.Known != 6 {
+		t.Errorf("expected 4 connected and 6 known peers, got %d and %d", h.Connected, h.Known)
+	}
+	if h.Depth != kad.proxLimit {
+		t.Errorf("expected depth %d, got %d", kad.proxLimit, h.Depth)
+	}
+	if len(h.Bins) != kad.MaxProx+1 {
+		t.Fatalf("expected %d bins, got %d", kad.MaxProx+1, len(h.Bins))
+	}
+	known := map[int]int{0: 2, 1: 1, 3: 1, 5: 2}
+	for po, bin := range h.Bins {
+		if bin.PO != po || bin.Connected != connected[po] || bin.Known != known[po] {
+			t.Errorf("PO %d: expected %d/%d connected/known, got %+v", po, connected[po], known[po], bin)
+		}
+	}
+}
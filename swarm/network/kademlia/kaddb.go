@@ -20,7 +20,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
+	"math/rand"
 	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -40,13 +43,25 @@ type NodeRecord struct {
 	Seen  time.Time        // last connected at time
 	Meta  *json.RawMessage // arbitrary metadata saved for a peer
 
-	node Node
+	Retries  int `json:",omitempty"` // connection attempts since last connected
+	Failures int `json:",omitempty"` // connection attempts which failed over the lifetime of the record
+	Backoff  int `json:",omitempty"` // consecutive failures the retry interval grows with, decayed by stable sessions
+
+	Retrievals     int           `json:",omitempty"` // chunks successfully retrieved from the node
+	ProtocolErrors int           `json:",omitempty"` // sessions with the node ended by a protocol error
+	Latency        time.Duration `json:",omitempty"` // moving average of the round trip time to the node
+
+	Pinned bool `json:",omitempty"` // protected from eviction and purging, see Kademlia.Pin
+
+	node      Node
+	connected time.Time // start of the current session
 }
 
 func (self *NodeRecord) setSeen() {
 	t := time.Now()
 	self.Seen = t
 	self.After = t
+	self.Retries = 0
 }
 
 func (self *NodeRecord) String() string {
@@ -62,7 +77,10 @@ type KadDb struct {
 	lock                 sync.RWMutex
 	purgeInterval        time.Duration
 	initialRetryInterval time.Duration
+	maxRetryInterval     time.Duration
 	connRetryExp         int
+	retryJitter          float64
+	latencyWeight        float64 // see KadParams.LatencyWeight
 }
 
 func newKadDb(addr Address, params *KadParams) *KadDb {
@@ -73,8 +91,31 @@ func newKadDb(addr Address, params *KadParams) *KadDb {
 		index:                make(map[Address]*NodeRecord),
 		purgeInterval:        params.PurgeInterval,
 		initialRetryInterval: params.InitialRetryInterval,
+		maxRetryInterval:     params.MaxRetryInterval,
 		connRetryExp:         params.ConnRetryExp,
+		retryJitter:          params.RetryJitter,
+		latencyWeight:        math.Max(0, math.Min(1, params.LatencyWeight)),
+	}
+}
+
+const (
+	maxBackoff    = 32          // cap of the backoff exponent of a record
+	stableSession = time.Minute // sessions lasting shorter count as failures
+)
+
+// backoff returns the interval to wait before the next connection attempt
+// after the given number of unsuccessful ones. The interval grows
+// exponentially up to the maximum and is randomized so that nodes which lost
+// their peers at the same time do not retry in lockstep.
+func (self *KadDb) backoff(retries int) time.Duration {
+	interval := float64(self.initialRetryInterval) * math.Pow(float64(self.connRetryExp), float64(retries))
+	if max := float64(self.maxRetryInterval); max > 0 && interval > max {
+		interval = max
 	}
+	if self.retryJitter > 0 {
+		interval *= 1 + self.retryJitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(interval)
 }
 
 func (self *KadDb) findOrCreate(index int, a Address, url string) *NodeRecord {
@@ -96,6 +137,7 @@ func (self *KadDb) findOrCreate(index int, a Address, url string) *NodeRecord {
 	}
 	// update last seen time
 	record.setSeen()
+	record.connected = record.Seen
 	// update with url in case IP/port changes
 	record.Url = url
 	return record
@@ -151,8 +193,9 @@ If the row cursor is on position i, the ith element in the row is chosen.
 If the record is scheduled not to be retried before NOW, the next element is taken.
 If the record is scheduled to be retried, it is set as checked, scheduled for
 checking and is returned. The time of the next check is in X (duration) such that
-X = ConnRetryExp * delta where delta is the time past since the last check and
-ConnRetryExp is constant obsoletion factor. (Note that when node records are added
+X = InitialRetryInterval * ConnRetryExp^backoff where backoff is the number of
+consecutive failed attempts and short sessions, halved by every stable
+session, capped at MaxRetryInterval and randomized by RetryJitter. (Note that when node records are added
 from peer messages, they are marked as checked and placed at the cursor, ie.
 given priority over older entries). Entries which were checked more than
 purgeInterval ago are deleted from the kaddb row. If no candidate is found after
@@ -169,6 +212,18 @@ offline past peer)
 The second argument returned names the first missing slot found
 */
 func (self *KadDb) findBest(maxBinSize int, binSize func(int) int) (node *NodeRecord, need bool, proxLimit int) {
+	return self.selectBest(maxBinSize, binSize, true)
+}
+
+// peekBest returns the node record findBest would return, without scheduling
+// its next check, moving the row cursors or purging expired records
+func (self *KadDb) peekBest(maxBinSize int, binSize func(int) int) (node *NodeRecord, need bool, proxLimit int) {
+	return self.selectBest(maxBinSize, binSize, false)
+}
+
+// selectBest implements findBest, scheduling the selected record and
+// updating the rows only if schedule is set
+func (self *KadDb) selectBest(maxBinSize int, binSize func(int) int, schedule bool) (node *NodeRecord, need bool, proxLimit int) {
 	// return nil, proxLimit indicates that all buckets are filled
 	defer self.lock.Unlock()
 	self.lock.Lock()
@@ -177,9 +232,11 @@ func (self *KadDb) findBest(maxBinSize int, binSize func(int) int) (node *NodeRe
 	var found bool
 	var purge []bool
 	var delta time.Duration
-	var cursor int
+	var cursor, bestCursor int
 	var count int
 	var after time.Time
+	var best *NodeRecord
+	var bestScore float64
 
 	// iterate over columns maximum bucketsize times
 	for rounds := 1; rounds <= maxBinSize; rounds++ {
@@ -217,26 +274,47 @@ func (self *KadDb) findBest(maxBinSize int, binSize func(int) int) (node *NodeRe
 				}
 
 				delta = time.Since(node.Seen)
-				if delta < self.initialRetryInterval {
-					delta = self.initialRetryInterval
-				}
-				if delta > self.purgeInterval {
+				if delta > self.purgeInterval && !node.Pinned {
 					// remove node
 					purge[cursor] = true
 					log.Debug(fmt.Sprintf("kaddb record %v (PO%03d:%d) unreachable since %v. Removed", node.Addr, po, cursor, node.Seen))
 					continue ROW
 				}
 
-				log.Debug(fmt.Sprintf("kaddb record %v (PO%03d:%d) ready to be tried. seen at %v (%v ago), scheduled at %v", node.Addr, po, cursor, node.Seen, delta, node.After))
+				score := self.suggestionScore(node, po)
+				log.Debug(fmt.Sprintf("kaddb record %v (PO%03d:%d) ready to be tried. seen at %v (%v ago), scheduled at %v, score %.2f", node.Addr, po, cursor, node.Seen, delta, node.After, score))
 
+				// the rest of the row is scanned for a pinned node or one with
+				// a better reputation, latency and proximity, among equals the
+				// first one is taken
+				if best == nil || node.Pinned && !best.Pinned || node.Pinned == best.Pinned && score > bestScore {
+					best, bestCursor, bestScore = node, cursor, score
+				}
+			} // ROW
+			if !schedule {
+				if best != nil {
+					return best, need, proxLimit
+				}
+				continue ROUND
+			}
+			if best != nil {
+				node = best
+				cursor = (bestCursor + 1) % len(dbrow)
+
+				// a new attempt being due means the previous one failed
+				if node.Retries > 0 {
+					node.Failures++
+					node.addFailure()
+				}
 				// scheduling next check
-				interval = delta * time.Duration(self.connRetryExp)
+				interval = self.backoff(node.Backoff)
+				node.Retries++
 				after = time.Now().Add(interval)
 
-				log.Debug(fmt.Sprintf("kaddb record %v (PO%03d:%d) selected as candidate connection %v. seen at %v (%v ago), selectable since %v, retry after %v (in %v)", node.Addr, po, cursor, rounds, node.Seen, delta, node.After, after, interval))
+				log.Debug(fmt.Sprintf("kaddb record %v (PO%03d:%d) selected as candidate connection %v. seen at %v (%v ago), selectable since %v, retry after %v (in %v)", node.Addr, po, bestCursor, rounds, node.Seen, time.Since(node.Seen), node.After, after, interval))
 				node.After = after
 				found = true
-			} // ROW
+			}
 			self.cursors[po] = cursor
 			self.delete(po, purge)
 			if found {
@@ -248,6 +326,36 @@ func (self *KadDb) findBest(maxBinSize int, binSize func(int) int) (node *NodeRe
 	return nil, need, proxLimit
 }
 
+// addFailure grows the backoff of the node record, up to the cap
+func (self *NodeRecord) addFailure() {
+	if self.Backoff < maxBackoff {
+		self.Backoff++
+	}
+}
+
+// endSession schedules the next connection to the node of the record after
+// its session ended. A stable session halves the backoff, a short one counts
+// as a failure, so that peers dropping right after connecting are not
+// redialled at once.
+// caller must hold the dblock
+func (self *KadDb) endSession(record *NodeRecord) {
+	if time.Since(record.connected) >= stableSession {
+		record.Backoff /= 2
+	} else {
+		record.addFailure()
+	}
+	record.Retries = 0
+	record.After = time.Now().Add(self.backoff(record.Backoff))
+}
+
+// disconnected ends the session of the record
+func (self *KadDb) disconnected(record *NodeRecord) {
+	defer self.lock.Unlock()
+	self.lock.Lock()
+	self.endSession(record)
+	record.node = nil
+}
+
 // deletes the noderecords of a kaddb row corresponding to the indexes
 // caller must hold the dblock
 // the call is unsafe, no index checks
@@ -270,29 +378,83 @@ func (self *KadDb) delete(row int, purge []bool) {
 	self.Nodes[row] = nodes
 }
 
-// save persists kaddb on disk (written to file on path in json format.
+// persisted returns a copy of the record as it is saved, leaving the live
+// record untouched.
+// the retry schedule and last seen time of offline nodes are kept so that a
+// restart does not reset their backoff or save them from being purged
+// connected nodes are saved as if disconnected now, so that flapping ones are
+// not dialled first after a restart. The session is cut by the shutdown rather
+// than the peer, so a short one does not count as a failure
+// caller must hold the dblock
+func (self *KadDb) persisted(node *NodeRecord, now time.Time) *NodeRecord {
+	record := *node
+	if node.node != nil {
+		if now.Sub(node.connected) >= stableSession {
+			record.Backoff /= 2
+		}
+		record.Retries = 0
+		record.After = now.Add(self.backoff(record.Backoff))
+		record.Seen = now
+	}
+	if record.After.IsZero() {
+		record.After = now
+		record.Retries = 0
+	}
+	if record.Seen.IsZero() {
+		record.Seen = now
+	}
+	return &record
+}
+
+// kadDbVersion is the version of the on-disk kaddb snapshot format. Files
+// written before the format was versioned carry no version and decode as 0.
+const kadDbVersion = 1
+
+// kadDbSnapshot is the on-disk representation of the kaddb. Node records are
+// kept as raw json so that a single damaged record does not invalidate the
+// whole file.
+type kadDbSnapshot struct {
+	Version int
+	Address Address
+	Saved   time.Time
+	Nodes   [][]json.RawMessage
+}
+
+// save persists kaddb on disk (written to file on path in json format).
+// The file is replaced atomically so that a crash while saving leaves the
+// previous snapshot intact.
 func (self *KadDb) save(path string, cb func(*NodeRecord, Node)) error {
 	defer self.lock.Unlock()
 	self.lock.Lock()
 
 	var n int
 
-	for _, b := range self.Nodes {
+	snapshot := &kadDbSnapshot{
+		Version: kadDbVersion,
+		Address: self.Address,
+		Saved:   time.Now(),
+		Nodes:   make([][]json.RawMessage, len(self.Nodes)),
+	}
+	now := time.Now()
+	for po, b := range self.Nodes {
 		for _, node := range b {
 			n++
-			node.After = time.Now()
-			node.Seen = time.Now()
 			if cb != nil {
 				cb(node, node.node)
 			}
+			data, err := json.Marshal(self.persisted(node, now))
+			if err != nil {
+				return err
+			}
+			snapshot.Nodes[po] = append(snapshot.Nodes[po], data)
 		}
 	}
 
-	data, err := json.MarshalIndent(self, "", " ")
+	data, err := json.MarshalIndent(snapshot, "", " ")
 	if err != nil {
 		return err
 	}
-	err = ioutil.WriteFile(path, data, os.ModePerm)
+	err = writeFileAtomic(path, data)
 	if err != nil {
 		log.Warn(fmt.Sprintf("unable to save kaddb with %v nodes to %v: %v", n, path, err))
 	} else {
@@ -302,6 +464,9 @@ func (self *KadDb) save(path string, cb func(*NodeRecord, Node)) error {
 }
 
 // Load(path) loads the node record database (kaddb) from file on path.
+// Records already known are kept. Invalid records are skipped; if the file
+// itself cannot be decoded or has an unsupported version it is moved aside
+// to path.bak and an error is returned, leaving the kaddb as it was.
 func (self *KadDb) load(path string, cb func(*NodeRecord, Node) error) (err error) {
 	defer self.lock.Unlock()
 	self.lock.Lock()
@@ -312,36 +477,96 @@ func (self *KadDb) load(path string, cb func(*NodeRecord, Node) error) (err erro
 		return
 	}
 
-	err = json.Unmarshal(data, self)
+	var snapshot kadDbSnapshot
+	if err = json.Unmarshal(data, &snapshot); err != nil {
+		err = fmt.Errorf("corrupt kaddb: %v", err)
+	} else if snapshot.Version > kadDbVersion {
+		err = fmt.Errorf("unsupported kaddb version %d (want <= %d)", snapshot.Version, kadDbVersion)
+	}
 	if err != nil {
+		if rerr := os.Rename(path, path+".bak"); rerr != nil {
+			log.Warn(fmt.Sprintf("unable to move aside kaddb %v: %v", path, rerr))
+		}
 		return
 	}
-	var n int
-	var purge []bool
-	for po, b := range self.Nodes {
-		purge = make([]bool, len(b))
-	ROW:
-		for i, node := range b {
-			if cb != nil {
-				err = cb(node, node.node)
-				if err != nil {
-					purge[i] = true
-					continue ROW
-				}
+
+	maxProx := len(self.Nodes) - 1
+	var n, skipped int
+	for _, b := range snapshot.Nodes {
+		for _, raw := range b {
+			node := new(NodeRecord)
+			if json.Unmarshal(raw, node) != nil || node.Addr == (Address{}) || node.Addr == self.Address {
+				skipped++
+				continue
+			}
+			if _, found := self.index[node.Addr]; found {
+				skipped++
+				continue
+			}
+			if cb != nil && cb(node, node.node) != nil {
+				skipped++
+				continue
 			}
-			n++
 			if node.After.IsZero() {
 				node.After = time.Now()
 			}
+			if node.Seen.IsZero() {
+				node.Seen = time.Now()
+			}
+			// rows are recomputed rather than trusted so that records end up
+			// in the right bin even if the file was written for another
+			// address or maximum proximity
+			po := proximity(self.Address, node.Addr)
+			if po > maxProx {
+				po = maxProx
+			}
+			self.Nodes[po] = append(self.Nodes[po], node)
 			self.index[node.Addr] = node
+			n++
 		}
-		self.delete(po, purge)
 	}
-	log.Info(fmt.Sprintf("loaded kaddb with %v nodes from %v", n, path))
+	if skipped > 0 {
+		log.Warn(fmt.Sprintf("skipped %v invalid or duplicate kaddb records from %v", skipped, path))
+	}
+	log.Info(fmt.Sprintf("loaded kaddb with %v nodes from %v (version %d, saved %v)", n, path, snapshot.Version, snapshot.Saved))
 
 	return
 }
 
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place once it is fully flushed.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// record returns a copy of the node record of the address, nil if not known
+func (self *KadDb) record(a Address) *NodeRecord {
+	defer self.lock.RUnlock()
+	self.lock.RLock()
+	record, ok := self.index[a]
+	if !ok {
+		return nil
+	}
+	copied := *record
+	return &copied
+}
+
 // accessor for KAD offline db count
 func (self *KadDb) count() int {
 	defer self.lock.Unlock()
@@ -23,6 +23,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/matrix/go-matrix/event"
 	"github.com/matrix/go-matrix/log"
 	"github.com/matrix/go-matrix/metrics"
 )
@@ -42,12 +43,19 @@ const (
 	maxProx      = 8
 	connRetryExp = 2
 	maxPeers     = 100
+	retryJitter  = 0.5
+
+	// proximity and latency weigh equally in suggestions by default
+	latencyPreference = 0.5
 )
 
 var (
 	purgeInterval        = 42 * time.Hour
 	initialRetryInterval = 42 * time.Millisecond
 	maxIdleInterval      = 42 * 1000 * time.Millisecond
+	maxRetryInterval     = 1 * time.Hour
+	refreshInterval      = 30 * time.Second
+	maxRefreshInterval   = 10 * time.Minute
 	// maxIdleInterval      = 42 * 10	0 * time.Millisecond
 )
 
@@ -59,7 +67,17 @@ type KadParams struct {
 	PurgeInterval        time.Duration
 	InitialRetryInterval time.Duration
 	MaxIdleInterval      time.Duration
-	ConnRetryExp         int
+	ConnRetryExp         int           // base of the exponential backoff between connection attempts
+	MaxRetryInterval     time.Duration // upper bound of the backoff, 0 for unbounded
+	RetryJitter          float64       // randomizes retry intervals by up to this fraction
+	Eviction             string        // name of the policy replacing idle nodes of full buckets, lru if empty
+	IDDifficulty         int           // leading zero bits required of the hashed overlay address of peers, 0 disables
+	RefreshInterval      time.Duration // time between lookups refreshing empty bins, 0 disables refreshing
+	MaxRefreshInterval   time.Duration // time between lookups refreshing full bins
+	LatencyWeight        float64       // weight of latency against proximity in suggestions, 0..1
+
+	// EvictionPolicy is a custom policy used instead of the named one
+	EvictionPolicy EvictionPolicy `toml:"-" json:"-"`
 }
 
 func NewDefaultKadParams() *KadParams {
@@ -71,6 +89,12 @@ func NewDefaultKadParams() *KadParams {
 		InitialRetryInterval: initialRetryInterval,
 		MaxIdleInterval:      maxIdleInterval,
 		ConnRetryExp:         connRetryExp,
+		MaxRetryInterval:     maxRetryInterval,
+		RetryJitter:          retryJitter,
+		Eviction:             EvictLRU,
+		RefreshInterval:      refreshInterval,
+		MaxRefreshInterval:   maxRefreshInterval,
+		LatencyWeight:        latencyPreference,
 	}
 }
 
@@ -84,6 +108,9 @@ type Kademlia struct {
 	buckets    [][]Node     // the actual bins
 	db         *KadDb       // kaddb, node record database
 	lock       sync.RWMutex // mutex to access buckets
+
+	eviction EvictionPolicy // chooses the node of a full bucket replaced by a new one
+	feed     event.Feed     // changes of the table, see SubscribeEvents
 }
 
 type Node interface {
@@ -103,6 +130,15 @@ func New(addr Address, params *KadParams) *Kademlia {
 		KadParams: params,
 		buckets:   buckets,
 		db:        newKadDb(addr, params),
+		eviction:  params.EvictionPolicy,
+	}
+	if kad.eviction == nil {
+		policy, err := NewEvictionPolicy(params.Eviction)
+		if err != nil {
+			log.Warn(fmt.Sprintf("%v, using %s", err, EvictLRU))
+			policy, _ = NewEvictionPolicy(EvictLRU)
+		}
+		kad.eviction = policy
 	}
 	kad.initMetricsVariables()
 	return kad
@@ -128,10 +164,14 @@ func (self *Kademlia) DBCount() int {
 // On is the entry point called when a new nodes is added
 // unsafe in that node is not checked to be already active node (to be called once)
 func (self *Kademlia) On(node Node, cb func(*NodeRecord, Node) error) (err error) {
-	log.Debug(fmt.Sprintf("%v", self))
+	log.Debug("Kademlia table", "table", self) // formatted only if logged
+	var events []TableEvent
+	defer func() { self.notify(events) }()
 	defer self.lock.Unlock()
 	self.lock.Lock()
+	defer self.updateGauges()
 
+	depth := self.proxLimit
 	index := self.proximityBin(node.Addr())
 	record := self.db.findOrCreate(index, node.Addr(), node.Url())
 
@@ -155,48 +195,75 @@ func (self *Kademlia) On(node Node, cb func(*NodeRecord, Node) error) (err error
 		self.setProxLimit(index, true)
 		record.node = node
 		self.count++
+		events = self.peerEvents(PeerAdded, node.Addr(), index, depth)
 		return nil
 	}
 
-	// always rotate peers
-	idle := self.MaxIdleInterval
-	var pos int
-	var replaced Node
+	// always rotate peers, replacing an idle one chosen by the eviction policy.
+	// Pinned peers are never replaced, while a pinned node may replace any
+	// other peer
+	pinned := self.db.pinned(node.Addr())
+	var candidates []*EvictionCandidate
+	var positions []int
 	for i, p := range bucket {
-		idleInt := time.Since(p.LastActive())
-		if idleInt > idle {
-			idle = idleInt
-			pos = i
-			replaced = p
+		idle := time.Since(p.LastActive())
+		if idle <= self.MaxIdleInterval && !pinned {
+			continue
+		}
+		c := &EvictionCandidate{Node: p, Idle: idle, Record: self.db.record(p.Addr())}
+		if c.Record != nil && c.Record.Pinned {
+			continue
 		}
+		if n, ok := p.(LatencyNode); ok {
+			c.Latency = n.Latency()
+		}
+		candidates = append(candidates, c)
+		positions = append(positions, i)
+	}
+	pos := -1
+	if len(candidates) > 0 {
+		pos = self.eviction.Evict(candidates)
 	}
-	if replaced == nil {
+	if pos < 0 || pos >= len(candidates) {
+		evictionKeptAll.Inc(1)
 		log.Debug(fmt.Sprintf("all peers wanted, PO%03d bucket full", index))
 		return fmt.Errorf("bucket full")
 	}
-	log.Debug(fmt.Sprintf("node %v replaced by %v (idle for %v  > %v)", replaced, node, idle, self.MaxIdleInterval))
+	evicted := candidates[pos]
+	evictionCounter(self.eviction).Inc(1)
+	replaced := evicted.Node
+	log.Debug(fmt.Sprintf("node %v replaced by %v (%s eviction, idle for %v, pinned %v)", replaced, node, self.eviction.Name(), evicted.Idle, pinned))
 	replaced.Drop()
+	pos = positions[pos]
 	// actually replace in the row. When off(node) is called, the peer is no longer in the row
 	bucket[pos] = node
 	// there is no change in bucket cardinalities so no prox limit adjustment is needed
 	record.node = node
 	self.count++
+	events = append(self.peerEvents(PeerRemoved, replaced.Addr(), index, depth), self.peerEvents(PeerAdded, node.Addr(), index, depth)...)
 	return nil
 
 }
 
 // Off is the called when a node is taken offline (from the protocol main loop exit)
 func (self *Kademlia) Off(node Node, cb func(*NodeRecord, Node)) (err error) {
+	var events []TableEvent
+	defer func() { self.notify(events) }()
 	self.lock.Lock()
 	defer self.lock.Unlock()
+	defer self.updateGauges()
 
+	depth := self.proxLimit
 	index := self.proximityBin(node.Addr())
 	bucketRmIndexCount[index].Inc(1)
 	bucket := self.buckets[index]
+	// nodes replaced in their bucket were reported removed already
+	removed := false
 	for i := 0; i < len(bucket); i++ {
 		if node.Addr() == bucket[i].Addr() {
 			self.buckets[index] = append(bucket[:i], bucket[(i+1):]...)
 			self.setProxLimit(index, false)
+			removed = true
 			break
 		}
 	}
@@ -206,8 +273,11 @@ func (self *Kademlia) Off(node Node, cb func(*NodeRecord, Node)) (err error) {
 	if cb != nil {
 		cb(record, record.node)
 	}
-	record.node = nil
+	self.db.disconnected(record)
 	self.count--
+	if removed {
+		events = self.peerEvents(PeerRemoved, node.Addr(), index, depth)
+	}
 	log.Debug(fmt.Sprintf("remove node %v from table, population now is %v", node, self.count))
 
 	return
@@ -304,6 +374,21 @@ func (self *Kademlia) FindClosest(target Address, max int) []Node {
 	return r.nodes
 }
 
+// EachNode calls f with the active nodes of the table and their proximity bin
+// until f returns false
+func (self *Kademlia) EachNode(f func(Node, int) bool) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	for po, bucket := range self.buckets {
+		for _, node := range bucket {
+			if !f(node, po) {
+				return
+			}
+		}
+	}
+}
+
 func (self *Kademlia) Suggest() (*NodeRecord, bool, int) {
 	defer self.lock.RUnlock()
 	self.lock.RLock()
@@ -312,7 +397,11 @@ func (self *Kademlia) Suggest() (*NodeRecord, bool, int) {
 
 //  adds node records to kaddb (persisted node record db)
 func (self *Kademlia) Add(nrs []*NodeRecord) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
 	self.db.add(nrs, self.proximityBin)
+	self.updateGauges()
 }
 
 // nodesByDistance is a list of nodes, ordered by distance to target.
@@ -384,6 +473,14 @@ func (self *Kademlia) KeyRange(other Address) (start, stop Address) {
 	return KeyRange(self.addr, other, self.proxLimit)
 }
 
+// RangeIterator returns an iterator over the key range shared with other,
+// for chunked chunk db iteration.
+func (self *Kademlia) RangeIterator(other Address) *KeyRangeIterator {
+	defer self.lock.RUnlock()
+	self.lock.RLock()
+	return RangeIterator(self.addr, other, self.proxLimit)
+}
+
 // save persists kaddb on disk (written to file on path in json format.
 func (self *Kademlia) Save(path string, cb func(*NodeRecord, Node)) error {
 	return self.db.save(path, cb)
@@ -442,13 +539,14 @@ func (self *Kademlia) String() string {
 }
 
 //We have to build up the array of counters for each index
+//The arrays are shared by all tables, so they only grow
 func (self *Kademlia) initMetricsVariables() {
-	//create the arrays
-	bucketAddIndexCount = make([]metrics.Counter, self.MaxProx+1)
-	bucketRmIndexCount = make([]metrics.Counter, self.MaxProx+1)
-	//at each index create a metrics counter
-	for i := 0; i < (self.KadParams.MaxProx + 1); i++ {
-		bucketAddIndexCount[i] = metrics.NewRegisteredCounter(fmt.Sprintf("network.kademlia.bucket.add.%d.index", i), nil)
-		bucketRmIndexCount[i] = metrics.NewRegisteredCounter(fmt.Sprintf("network.kademlia.bucket.rm.%d.index", i), nil)
+	for i := len(bucketAddIndexCount); i < (self.KadParams.MaxProx + 1); i++ {
+		bucketAddIndexCount = append(bucketAddIndexCount, metrics.GetOrRegisterCounter(fmt.Sprintf("network.kademlia.bucket.add.%d.index", i), nil))
+		bucketRmIndexCount = append(bucketRmIndexCount, metrics.GetOrRegisterCounter(fmt.Sprintf("network.kademlia.bucket.rm.%d.index", i), nil))
+		binConnectedGauges = append(binConnectedGauges, metrics.GetOrRegisterGauge(fmt.Sprintf("network.kademlia.bin.%d.connected", i), nil))
+		binKnownGauges = append(binKnownGauges, metrics.GetOrRegisterGauge(fmt.Sprintf("network.kademlia.bin.%d.known", i), nil))
+		binRefreshIntervalGauges = append(binRefreshIntervalGauges, metrics.GetOrRegisterGauge(fmt.Sprintf("network.kademlia.bin.%d.refresh.interval", i), nil))
+		binStalenessGauges = append(binStalenessGauges, metrics.GetOrRegisterGauge(fmt.Sprintf("network.kademlia.bin.%d.refresh.staleness", i), nil))
 	}
 }
@@ -17,7 +17,9 @@
 package kademlia
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"math/rand"
 	"os"
@@ -272,6 +274,332 @@ func TestSaveLoad(t *testing.T) {
 	}
 }
 
+func TestRetryBackoff(t *testing.T) {
+	params := NewDefaultKadParams()
+	params.InitialRetryInterval = time.Second
+	params.MaxRetryInterval = time.Minute
+	params.RetryJitter = 0
+	db := newKadDb(RandomAddress(), params)
+
+	for retries, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second} {
+		if have := db.backoff(retries); have != want {
+			t.Errorf("retry %d: have interval %v, want %v", retries, have, want)
+		}
+	}
+	if have := db.backoff(100); have != time.Minute {
+		t.Errorf("have interval %v, want maximum %v", have, time.Minute)
+	}
+
+	db.retryJitter = 0.5
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		have := db.backoff(2)
+		if have < 2*time.Second || have > 6*time.Second {
+			t.Fatalf("jittered interval %v out of range", have)
+		}
+		seen[have] = true
+	}
+	if len(seen) < 2 {
+		t.Error("intervals not randomized")
+	}
+}
+
+func TestSaveLoadRetries(t *testing.T) {
+	self := RandomAddress()
+	params := NewDefaultKadParams()
+	params.RetryJitter = 0
+	kad := New(self, params)
+	kad.Add([]*NodeRecord{{Addr: RandomAddress()}})
+
+	// two unsuccessful attempts to connect
+	var record *NodeRecord
+	for i := 0; i < 2; i++ {
+		kad.db.lock.Lock()
+		for _, b := range kad.db.Nodes {
+			for _, node := range b {
+				node.After = time.Now()
+			}
+		}
+		kad.db.lock.Unlock()
+		record, _, _ = kad.Suggest()
+		if record == nil {
+			t.Fatalf("attempt %d: no node suggested", i)
+		}
+	}
+	if record.Retries != 2 {
+		t.Fatalf("have %d retries, want 2", record.Retries)
+	}
+	after := record.After
+
+	path := filepath.Join(os.TempDir(), "bzz-kad-test-save-load-retries.peers")
+	defer os.Remove(path)
+	if err := kad.Save(path, nil); err != nil {
+		t.Fatalf("unexpected error saving kaddb: %v", err)
+	}
+	kad = New(self, params)
+	if err := kad.Load(path, nil); err != nil {
+		t.Fatalf("unexpected error loading kaddb: %v", err)
+	}
+	loaded := kad.db.index[record.Addr]
+	if loaded == nil {
+		t.Fatal("node record not loaded")
+	}
+	if loaded.Retries != 2 || !loaded.After.Equal(after) {
+		t.Errorf("have %d retries after %v, want 2 after %v", loaded.Retries, loaded.After, after)
+	}
+}
+
+func TestSessionBackoff(t *testing.T) {
+	self := RandomAddress()
+	params := NewDefaultKadParams()
+	params.InitialRetryInterval = time.Second
+	params.RetryJitter = 0
+	kad := New(self, params)
+	node := &testNode{RandomAddress()}
+	kad.Add([]*NodeRecord{{Addr: node.Addr()}})
+	record := kad.db.index[node.Addr()]
+
+	// two attempts to connect, the first one failing
+	for i := 0; i < 2; i++ {
+		record.After = time.Now()
+		if suggested, _, _ := kad.Suggest(); suggested != record {
+			t.Fatalf("attempt %d: have %v suggested, want %v", i, suggested, record)
+		}
+	}
+	if record.Backoff != 1 {
+		t.Fatalf("have backoff %d after failed attempt, want 1", record.Backoff)
+	}
+
+	// a session ending right after connecting counts as failure
+	kad.On(node, nil)
+	kad.Off(node, nil)
+	if record.Backoff != 2 || record.Retries != 0 {
+		t.Fatalf("have backoff %d, %d retries after short session, want 2, 0", record.Backoff, record.Retries)
+	}
+	if wait := time.Until(record.After); wait <= 3*time.Second {
+		t.Errorf("have retry in %v after short session, want 4s", wait)
+	}
+	if suggested, _, _ := kad.Suggest(); suggested != nil {
+		t.Errorf("have %v suggested during backoff", suggested)
+	}
+
+	// a stable session halves the backoff
+	kad.On(node, nil)
+	record.connected = time.Now().Add(-stableSession)
+	kad.Off(node, nil)
+	if record.Backoff != 1 {
+		t.Fatalf("have backoff %d after stable session, want 1", record.Backoff)
+	}
+
+	// the backoff is capped
+	for i := 0; i < 2*maxBackoff; i++ {
+		kad.On(node, nil)
+		kad.Off(node, nil)
+	}
+	if record.Backoff != maxBackoff {
+		t.Fatalf("have backoff %d, want maximum %d", record.Backoff, maxBackoff)
+	}
+
+	// connected flapping nodes keep their backoff over restarts, healthy ones
+	// are not penalised for the session cut by the shutdown
+	kad.On(node, nil)
+	healthy := &testNode{RandomAddress()}
+	kad.Add([]*NodeRecord{{Addr: healthy.Addr()}})
+	kad.On(healthy, nil)
+
+	live := *record
+	path := filepath.Join(os.TempDir(), "bzz-kad-test-session-backoff.peers")
+	defer os.Remove(path)
+	if err := kad.Save(path, nil); err != nil {
+		t.Fatalf("unexpected error saving kaddb: %v", err)
+	}
+	// saving leaves the live records alone
+	if record.Backoff != live.Backoff || record.Retries != live.Retries || !record.After.Equal(live.After) || !record.Seen.Equal(live.Seen) || record.node == nil {
+		t.Errorf("live record changed by saving: have %+v, want %+v", *record, live)
+	}
+	if kad.db.index[healthy.Addr()].Backoff != 0 {
+		t.Errorf("have backoff %d for healthy node after saving, want 0", kad.db.index[healthy.Addr()].Backoff)
+	}
+	kad = New(self, params)
+	if err := kad.Load(path, nil); err != nil {
+		t.Fatalf("unexpected error loading kaddb: %v", err)
+	}
+	loaded := kad.db.index[node.Addr()]
+	if loaded == nil {
+		t.Fatal("node record not loaded")
+	}
+	if loaded.Backoff != maxBackoff || !loaded.After.After(time.Now()) {
+		t.Errorf("have backoff %d, retry after %v; want %d, in the future", loaded.Backoff, loaded.After, maxBackoff)
+	}
+	if loaded := kad.db.index[healthy.Addr()]; loaded == nil || loaded.Backoff != 0 {
+		t.Errorf("have healthy node record %v loaded, want backoff 0", loaded)
+	}
+}
+
+func TestSaveLoadSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzz-kad-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bzz-peers.json")
+
+	self := RandomAddress()
+	params := NewDefaultKadParams()
+	kad := New(self, params)
+	record := &NodeRecord{Addr: RandomAddress(), Url: "enode://a"}
+	kad.Add([]*NodeRecord{record})
+	seen := time.Now().Add(-time.Hour).Truncate(time.Second)
+	record.Seen = seen
+
+	if err := kad.Save(path, nil); err != nil {
+		t.Fatalf("unexpected error saving kaddb: %v", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snapshot kadDbSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatalf("invalid snapshot: %v", err)
+	}
+	if snapshot.Version != kadDbVersion {
+		t.Errorf("have version %d, want %d", snapshot.Version, kadDbVersion)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("have %d files after save, want 1", len(files))
+	}
+
+	kad = New(self, params)
+	if err := kad.Load(path, nil); err != nil {
+		t.Fatalf("unexpected error loading kaddb: %v", err)
+	}
+	loaded := kad.db.index[record.Addr]
+	if loaded == nil {
+		t.Fatal("node record not loaded")
+	}
+	if !loaded.Seen.Equal(seen) || loaded.Url != record.Url {
+		t.Errorf("have record %v seen at %v, want %v seen at %v", loaded.Url, loaded.Seen, record.Url, seen)
+	}
+	if po := kad.proximityBin(record.Addr); len(kad.db.Nodes[po]) != 1 {
+		t.Errorf("record not in bin %d", po)
+	}
+}
+
+func TestLoadLegacy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzz-kad-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bzz-peers.json")
+
+	self := RandomAddress()
+	addr := RandomAddress()
+	params := NewDefaultKadParams()
+	legacy := &KadDb{Address: self, Nodes: make([][]*NodeRecord, params.MaxProx+1)}
+	// legacy files stored rows as they were, even if unused or misplaced
+	legacy.Nodes[0] = []*NodeRecord{{Addr: addr, Seen: time.Now()}, nil}
+	data, err := json.Marshal(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	kad := New(self, params)
+	if err := kad.Load(path, nil); err != nil {
+		t.Fatalf("unexpected error loading legacy kaddb: %v", err)
+	}
+	if kad.db.index[addr] == nil {
+		t.Fatal("node record not loaded")
+	}
+	if n := kad.DBCount(); n != 1 {
+		t.Errorf("have %d records, want 1", n)
+	}
+}
+
+func TestLoadCorrupt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzz-kad-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bzz-peers.json")
+
+	self := RandomAddress()
+	params := NewDefaultKadParams()
+	for i, content := range []string{
+		`{"Version": 1, "Nodes": [[`,
+		`not json`,
+		`{"Version": 1, "Address": 1}`,
+		`{"Version": 100}`,
+	} {
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		kad := New(self, params)
+		if err := kad.Load(path, nil); err == nil {
+			t.Errorf("test %d: expected error loading %q", i, content)
+		}
+		if n := kad.DBCount(); n != 0 {
+			t.Errorf("test %d: have %d records, want 0", i, n)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("test %d: corrupt kaddb not moved aside", i)
+		}
+		backup, err := ioutil.ReadFile(path + ".bak")
+		if err != nil || string(backup) != content {
+			t.Errorf("test %d: have backup %q (%v), want %q", i, backup, err, content)
+		}
+	}
+}
+
+func TestLoadSkipsInvalidRecords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bzz-kad-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bzz-peers.json")
+
+	self := RandomAddress()
+	addr := RandomAddress()
+	valid, _ := json.Marshal(&NodeRecord{Addr: addr})
+	own, _ := json.Marshal(&NodeRecord{Addr: self})
+	snapshot := &kadDbSnapshot{
+		Version: kadDbVersion,
+		Address: self,
+		Nodes: [][]json.RawMessage{{
+			valid,
+			valid,
+			own,
+			json.RawMessage(`null`),
+			json.RawMessage(`{"Addr": 1}`),
+			json.RawMessage(`[]`),
+		}},
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	kad := New(self, NewDefaultKadParams())
+	if err := kad.Load(path, nil); err != nil {
+		t.Fatalf("unexpected error loading kaddb: %v", err)
+	}
+	if n := kad.DBCount(); n != 1 {
+		t.Errorf("have %d records, want 1", n)
+	}
+	if kad.db.index[addr] == nil {
+		t.Error("valid node record not loaded")
+	}
+}
+
 func (self *Kademlia) proxCheck(t *testing.T) bool {
 	var sum int
 	for i, b := range self.buckets {
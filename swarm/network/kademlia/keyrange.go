@@ -0,0 +1,107 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"bytes"
+)
+
+// KeyRangeIterator walks the inclusive address range returned by KeyRange in
+// increasing order, yielding it lazily as sub-ranges. Its cursor can be read
+// and restored, so a chunked walk of the db by address can be resumed without
+// materialising the range.
+type KeyRangeIterator struct {
+	start, stop Address
+	cursor      Address // first address not yielded yet
+	done        bool
+}
+
+// RangeIterator creates an iterator over the address range containing the
+// addresses closer to one than other, as given by KeyRange.
+func RangeIterator(one, other Address, proxLimit int) *KeyRangeIterator {
+	start, stop := KeyRange(one, other, proxLimit)
+	return &KeyRangeIterator{start: start, stop: stop, cursor: start}
+}
+
+// Range returns the inclusive bounds of the whole range walked.
+func (self *KeyRangeIterator) Range() (start, stop Address) {
+	return self.start, self.stop
+}
+
+// Contains tells whether an address falls into the range walked.
+func (self *KeyRangeIterator) Contains(a Address) bool {
+	return bytes.Compare(a[:], self.start[:]) >= 0 && bytes.Compare(a[:], self.stop[:]) <= 0
+}
+
+// Done tells whether the whole range has been yielded.
+func (self *KeyRangeIterator) Done() bool {
+	return self.done
+}
+
+// Cursor returns the first address not yielded yet, to be passed to Seek when
+// resuming the walk. It is meaningless once the iterator is done.
+func (self *KeyRangeIterator) Cursor() Address {
+	return self.cursor
+}
+
+// Seek moves the cursor to the given address. Addresses before the range
+// restart the walk, addresses after it end the walk.
+func (self *KeyRangeIterator) Seek(cursor Address) {
+	switch {
+	case bytes.Compare(cursor[:], self.start[:]) < 0:
+		self.cursor, self.done = self.start, false
+	case bytes.Compare(cursor[:], self.stop[:]) > 0:
+		self.done = true
+	default:
+		self.cursor, self.done = cursor, false
+	}
+}
+
+// Next yields the inclusive bounds of the next sub-range, holding the
+// addresses from the cursor up to the end of the block of addresses sharing
+// its first depth bits, and moves the cursor past it. A depth of 256 yields
+// the addresses one by one, smaller depths yield larger sub-ranges. ok is
+// false once the range is exhausted.
+func (self *KeyRangeIterator) Next(depth int) (start, stop Address, ok bool) {
+	if self.done {
+		return Address{}, Address{}, false
+	}
+	if depth < 0 {
+		depth = 0
+	}
+	start, stop = self.cursor, self.cursor
+	if depth < len(stop)*8 {
+		pos := depth / 8
+		stop[pos] |= 0xff >> uint(depth%8)
+		for i := pos + 1; i < len(stop); i++ {
+			stop[i] = 0xff
+		}
+	}
+	if bytes.Compare(stop[:], self.stop[:]) >= 0 {
+		stop, self.done = self.stop, true
+		return start, stop, true
+	}
+	// The sub-range ends before the range does, so the increment can't overflow
+	self.cursor = stop
+	for i := len(self.cursor) - 1; i >= 0; i-- {
+		self.cursor[i]++
+		if self.cursor[i] != 0 {
+			break
+		}
+	}
+	return start, stop, true
+}
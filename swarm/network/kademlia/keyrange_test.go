@@ -0,0 +1,122 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+)
+
+// collectRange walks an iterator to the end, returning the sub-ranges yielded.
+func collectRange(it *KeyRangeIterator, depth int) [][2]Address {
+	var ranges [][2]Address
+	for {
+		start, stop, ok := it.Next(depth)
+		if !ok {
+			return ranges
+		}
+		ranges = append(ranges, [2]Address{start, stop})
+	}
+}
+
+func TestRangeIteratorCoversRange(t *testing.T) {
+	one := RandomAddress()
+	other := RandomAddressAt(one, 240)
+
+	it := RangeIterator(one, other, 256)
+	start, stop := it.Range()
+	if wantStart, wantStop := KeyRange(one, other, 256); start != wantStart || stop != wantStop {
+		t.Fatalf("range mismatch: have %v-%v, want %v-%v", start, stop, wantStart, wantStop)
+	}
+	if !it.Contains(one) || !it.Contains(other) || it.Contains(RandomAddressAt(one, 200)) {
+		t.Fatalf("range %v-%v membership mismatch", start, stop)
+	}
+	ranges := collectRange(it, 248)
+	if len(ranges) != 256 {
+		t.Fatalf("sub-range count mismatch: have %d, want %d", len(ranges), 256)
+	}
+	if ranges[0][0] != start || ranges[len(ranges)-1][1] != stop {
+		t.Fatalf("sub-ranges %v-%v don't span range %v-%v", ranges[0][0], ranges[len(ranges)-1][1], start, stop)
+	}
+	for i := 1; i < len(ranges); i++ {
+		prev := new(big.Int).SetBytes(ranges[i-1][1][:])
+		if next := new(big.Int).SetBytes(ranges[i][0][:]); next.Sub(next, prev).Int64() != 1 {
+			t.Fatalf("sub-range %d: gap between %v and %v", i, ranges[i-1][1], ranges[i][0])
+		}
+	}
+	if !it.Done() {
+		t.Fatalf("iterator not done after range exhausted")
+	}
+}
+
+func TestRangeIteratorResume(t *testing.T) {
+	one := RandomAddress()
+	other := RandomAddressAt(one, 244)
+
+	want := collectRange(RangeIterator(one, other, 256), 252)
+
+	// Walk part of the range, then resume from the saved cursor
+	it := RangeIterator(one, other, 256)
+	var have [][2]Address
+	for i := 0; i < 5; i++ {
+		start, stop, _ := it.Next(252)
+		have = append(have, [2]Address{start, stop})
+	}
+	resumed := RangeIterator(one, other, 256)
+	resumed.Seek(it.Cursor())
+	have = append(have, collectRange(resumed, 252)...)
+
+	if len(have) != len(want) {
+		t.Fatalf("sub-range count mismatch: have %d, want %d", len(have), len(want))
+	}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Fatalf("sub-range %d mismatch: have %v, want %v", i, have[i], want[i])
+		}
+	}
+}
+
+func TestRangeIteratorSeekBounds(t *testing.T) {
+	one := Address(common.HexToHash("0x0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"))
+	other := RandomAddressAt(one, 250)
+
+	it := RangeIterator(one, other, 256)
+	start, stop := it.Range()
+
+	it.Seek(Address{})
+	if first, _, ok := it.Next(256); !ok || first != start {
+		t.Fatalf("seek before range: have %v, want %v", first, start)
+	}
+	var last Address
+	for i := range last {
+		last[i] = 0xff
+	}
+	it.Seek(last)
+	if _, _, ok := it.Next(256); ok || !it.Done() {
+		t.Fatalf("seek after range yielded addresses")
+	}
+	// Single addresses are yielded at full depth
+	it.Seek(stop)
+	if first, second, ok := it.Next(256); !ok || first != stop || second != stop {
+		t.Fatalf("full depth sub-range mismatch: have %v-%v, want %v", first, second, stop)
+	}
+	if _, _, ok := it.Next(256); ok {
+		t.Fatalf("addresses yielded past the range")
+	}
+}
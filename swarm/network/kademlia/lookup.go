@@ -0,0 +1,200 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/metrics"
+)
+
+const (
+	DefaultLookupAlpha   = 3               // concurrent queries of a lookup
+	DefaultLookupTimeout = 5 * time.Second // time a queried node has to respond
+)
+
+var (
+	lookupCount      = metrics.NewRegisteredCounter("network.kademlia.lookup.count", nil)
+	lookupQueryCount = metrics.NewRegisteredCounter("network.kademlia.lookup.query.count", nil)
+	lookupQueryFail  = metrics.NewRegisteredCounter("network.kademlia.lookup.query.fail", nil)
+
+	errLookupTimeout = errors.New("lookup query timed out")
+)
+
+// FindNodeFunc asks the node of the record for the node records it knows
+// closest to target. It is called concurrently for different nodes.
+type FindNodeFunc func(node *NodeRecord, target Address) ([]*NodeRecord, error)
+
+/*
+NodeFinder runs iterative lookups of the nodes closest to a target address.
+
+A lookup starts from the closest nodes of the table and the kaddb and queries
+up to alpha of the k closest nodes known at a time. The nodes returned are
+merged into the candidates, nodes which fail to respond are dropped, and the
+lookup converges once the k closest candidates have all responded. The
+transport is left to the FindNodeFunc, so that the hive and retrieval can share
+the lookup whatever message carries the query.
+*/
+type NodeFinder struct {
+	kad   *Kademlia
+	query FindNodeFunc
+
+	Timeout time.Duration // time a queried node has to respond
+}
+
+func NewNodeFinder(kad *Kademlia, query FindNodeFunc) *NodeFinder {
+	return &NodeFinder{
+		kad:     kad,
+		query:   query,
+		Timeout: DefaultLookupTimeout,
+	}
+}
+
+// lookupEntry is a candidate of a lookup
+type lookupEntry struct {
+	record    *NodeRecord
+	queried   bool // query sent
+	responded bool // query answered
+}
+
+type lookupReply struct {
+	entry   *lookupEntry
+	records []*NodeRecord
+	err     error
+}
+
+// Lookup returns the k nodes closest to target which responded to the lookup,
+// ordered by distance. Responding nodes are added to the kaddb. Non-positive
+// alpha and k default to DefaultLookupAlpha and the bucket size.
+func (self *NodeFinder) Lookup(target Address, alpha, k int) []*NodeRecord {
+	if alpha < 1 {
+		alpha = DefaultLookupAlpha
+	}
+	if k < 1 {
+		k = self.kad.TableParams().BucketSize
+	}
+	lookupCount.Inc(1)
+
+	var candidates []*lookupEntry
+	seen := map[Address]bool{self.kad.Addr(): true}
+	merge := func(records []*NodeRecord) {
+		for _, r := range records {
+			if r == nil || seen[r.Addr] {
+				continue
+			}
+			seen[r.Addr] = true
+			e := &lookupEntry{record: &NodeRecord{Addr: r.Addr, Url: r.Url}}
+			i := sort.Search(len(candidates), func(i int) bool {
+				return DistanceCmp(target, r.Addr, candidates[i].record.Addr) < 0
+			})
+			candidates = append(candidates, nil)
+			copy(candidates[i+1:], candidates[i:])
+			candidates[i] = e
+		}
+	}
+	merge(self.seeds(target, k))
+
+	replies := make(chan *lookupReply, alpha)
+	var pending int
+	for {
+		// keep alpha queries to the closest candidates not queried yet in flight
+		for i := 0; i < len(candidates) && i < k && pending < alpha; i++ {
+			if e := candidates[i]; !e.queried {
+				e.queried = true
+				pending++
+				go self.send(e, target, replies)
+			}
+		}
+		if pending == 0 {
+			break
+		}
+		reply := <-replies
+		pending--
+		if reply.err != nil {
+			lookupQueryFail.Inc(1)
+			log.Trace(fmt.Sprintf("lookup of %v: query to %v failed: %v", target, reply.entry.record.Addr, reply.err))
+			for i, e := range candidates {
+				if e == reply.entry {
+					candidates = append(candidates[:i], candidates[i+1:]...)
+					break
+				}
+			}
+			continue
+		}
+		reply.entry.responded = true
+		merge(reply.records)
+	}
+
+	var found, add []*NodeRecord
+	for _, e := range candidates {
+		if len(found) == k {
+			break
+		}
+		if e.responded {
+			found = append(found, e.record)
+			add = append(add, &NodeRecord{Addr: e.record.Addr, Url: e.record.Url})
+		}
+	}
+	self.kad.Add(add)
+	log.Debug(fmt.Sprintf("lookup of %v found %d nodes, %d candidates", target, len(found), len(seen)-1))
+	return found
+}
+
+// seeds returns the k active nodes and the k node records of the kaddb
+// closest to target
+func (self *NodeFinder) seeds(target Address, k int) []*NodeRecord {
+	var seeds []*NodeRecord
+	for _, n := range self.kad.FindClosest(target, k) {
+		seeds = append(seeds, &NodeRecord{Addr: n.Addr(), Url: n.Url()})
+	}
+	db := self.kad.db
+	db.lock.RLock()
+	records := make([]*NodeRecord, 0, len(db.index))
+	for _, r := range db.index {
+		records = append(records, &NodeRecord{Addr: r.Addr, Url: r.Url})
+	}
+	db.lock.RUnlock()
+	sort.Slice(records, func(i, j int) bool {
+		return DistanceCmp(target, records[i].Addr, records[j].Addr) < 0
+	})
+	if len(records) > k {
+		records = records[:k]
+	}
+	return append(seeds, records...)
+}
+
+// send queries the node of the entry, giving up after the timeout
+func (self *NodeFinder) send(e *lookupEntry, target Address, replies chan<- *lookupReply) {
+	lookupQueryCount.Inc(1)
+	done := make(chan *lookupReply, 1)
+	go func() {
+		records, err := self.query(e.record, target)
+		done <- &lookupReply{e, records, err}
+	}()
+	timer := time.NewTimer(self.Timeout)
+	defer timer.Stop()
+	select {
+	case reply := <-done:
+		replies <- reply
+	case <-timer.C:
+		replies <- &lookupReply{e, nil, errLookupTimeout}
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lookupNet is a simulated network of kademlia tables answering lookup
+// queries with the closest nodes they know
+type lookupNet struct {
+	tables map[Address]*Kademlia
+	addrs  []Address
+
+	lock     sync.Mutex
+	queried  map[Address]int
+	running  int
+	parallel int // maximum concurrent queries
+	fail     map[Address]bool
+	hang     map[Address]bool
+}
+
+func newLookupNet(n int, seed int64) *lookupNet {
+	r := rand.New(rand.NewSource(seed))
+	net := &lookupNet{
+		tables:  make(map[Address]*Kademlia),
+		queried: make(map[Address]int),
+		fail:    make(map[Address]bool),
+		hang:    make(map[Address]bool),
+	}
+	for i := 0; i < n; i++ {
+		net.addrs = append(net.addrs, RandomAddressFrom(r))
+	}
+	for _, a := range net.addrs {
+		kad := New(a, NewDefaultKadParams())
+		for _, b := range net.addrs {
+			if a != b {
+				kad.On(&testNode{addr: b}, nil)
+			}
+		}
+		net.tables[a] = kad
+	}
+	return net
+}
+
+func (self *lookupNet) query(node *NodeRecord, target Address) ([]*NodeRecord, error) {
+	self.lock.Lock()
+	self.queried[node.Addr]++
+	self.running++
+	if self.running > self.parallel {
+		self.parallel = self.running
+	}
+	fail, hang := self.fail[node.Addr], self.hang[node.Addr]
+	self.lock.Unlock()
+	defer func() {
+		self.lock.Lock()
+		self.running--
+		self.lock.Unlock()
+	}()
+
+	time.Sleep(time.Millisecond)
+	if hang {
+		time.Sleep(time.Second)
+	}
+	if fail {
+		return nil, errors.New("unreachable")
+	}
+	var records []*NodeRecord
+	for _, n := range self.tables[node.Addr].FindClosest(target, 8) {
+		records = append(records, &NodeRecord{Addr: n.Addr()})
+	}
+	return records, nil
+}
+
+// closest returns the k addresses of the network closest to target except
+// the origin and unresponsive nodes
+func (self *lookupNet) closest(origin, target Address, k int) []Address {
+	var addrs []Address
+	for _, a := range self.addrs {
+		if a != origin && !self.fail[a] && !self.hang[a] {
+			addrs = append(addrs, a)
+		}
+	}
+	SortByDistance(target, addrs)
+	return addrs[:k]
+}
+
+func TestLookup(t *testing.T) {
+	net := newLookupNet(100, 1)
+	origin := net.addrs[0]
+	// the origin knows a single node only
+	kad := New(origin, NewDefaultKadParams())
+	kad.On(&testNode{addr: net.addrs[1]}, nil)
+	finder := NewNodeFinder(kad, net.query)
+
+	for i := 0; i < 10; i++ {
+		target := RandomAddress()
+		found := finder.Lookup(target, 3, 4)
+		want := net.closest(origin, target, 4)
+		if len(found) != len(want) {
+			t.Fatalf("target %v: found %d nodes, want %d", target, len(found), len(want))
+		}
+		for j, r := range found {
+			if r.Addr != want[j] {
+				t.Errorf("target %v: node %d is %v, want %v", target, j, r.Addr, want[j])
+			}
+		}
+	}
+	if net.parallel > 3 {
+		t.Errorf("have %d concurrent queries, want at most 3", net.parallel)
+	}
+	for a, n := range net.queried {
+		if a == origin {
+			t.Error("origin queried itself")
+		}
+		if n > 10 {
+			t.Errorf("node %v queried %d times in 10 lookups", a, n)
+		}
+	}
+	if kad.DBCount() < 4 {
+		t.Errorf("have %d node records, want the responding nodes added", kad.DBCount())
+	}
+}
+
+func TestLookupUnresponsive(t *testing.T) {
+	net := newLookupNet(60, 2)
+	origin := net.addrs[0]
+	// the nodes closest to the target fail or do not respond at all, so the
+	// one node the origin knows must not be among them
+	r := rand.New(rand.NewSource(3))
+	var target Address
+	var closest []Address
+	for {
+		target = RandomAddressFrom(r)
+		closest = net.closest(origin, target, 4)
+		if closest[0] != net.addrs[1] && closest[1] != net.addrs[1] && closest[2] != net.addrs[1] {
+			break
+		}
+	}
+	net.fail[closest[0]] = true
+	net.fail[closest[1]] = true
+	net.hang[closest[2]] = true
+
+	kad := New(origin, NewDefaultKadParams())
+	kad.On(&testNode{addr: net.addrs[1]}, nil)
+	finder := NewNodeFinder(kad, net.query)
+	finder.Timeout = 300 * time.Millisecond
+
+	found := finder.Lookup(target, 2, 3)
+	want := net.closest(origin, target, 3)
+	if len(found) != len(want) {
+		t.Fatalf("found %d nodes, want %d", len(found), len(want))
+	}
+	for j, r := range found {
+		if r.Addr != want[j] {
+			t.Errorf("node %d is %v, want %v", j, r.Addr, want[j])
+		}
+	}
+}
+
+func TestLookupNoPeers(t *testing.T) {
+	kad := New(RandomAddress(), NewDefaultKadParams())
+	finder := NewNodeFinder(kad, func(*NodeRecord, Address) ([]*NodeRecord, error) {
+		t.Fatal("unexpected query")
+		return nil, nil
+	})
+	if found := finder.Lookup(RandomAddress(), 0, 0); len(found) != 0 {
+		t.Errorf("found %d nodes without peers", len(found))
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	crand "crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+
+	"github.com/matrix/go-matrix/common"
+)
+
+// Supported overlay address lengths in bytes.
+const (
+	ShortAddressLength = 20                // Overlay keyed by account addresses
+	AddressLength      = common.HashLength // Default overlay, see Address
+	LongAddressLength  = 64                // Overlay with a 512 bit key space
+)
+
+// OverlayAddress is an overlay address of any supported length. Address is the
+// fixed size form of the default 32 byte overlay, converting to and from an
+// OverlayAddress with OverlayAddress and ToAddress.
+//
+// Overlay addresses of different lengths live in different address spaces, so
+// comparing them is an error. Only the address helpers below operate on other
+// lengths; the Kademlia table and the swarm network still run on Address.
+type OverlayAddress []byte
+
+// supportedLength reports whether length is a supported overlay address length.
+func supportedLength(length int) bool {
+	return length == ShortAddressLength || length == AddressLength || length == LongAddressLength
+}
+
+// NewOverlayAddress creates an overlay address from a copy of the given bytes.
+func NewOverlayAddress(b []byte) (OverlayAddress, error) {
+	if !supportedLength(len(b)) {
+		return nil, fmt.Errorf("unsupported overlay address length %d", len(b))
+	}
+	return OverlayAddress(common.CopyBytes(b)), nil
+}
+
+// RandomOverlayAddress generates a random overlay address of the given length.
+func RandomOverlayAddress(length int) (OverlayAddress, error) {
+	return randomOverlayAddress(length, globalRand)
+}
+
+// RandomOverlayAddressAt generates a random overlay address at proximity order
+// prox relative to self. If prox is negative a random address is generated, if
+// it is the address length in bits self is returned. A larger prox is an error.
+func RandomOverlayAddressAt(self OverlayAddress, prox int) (OverlayAddress, error) {
+	return randomOverlayAddressAt(self, prox, globalRand)
+}
+
+// RandomOverlayAddressFrom generates a random overlay address of the given
+// length drawing its randomness from src.
+func RandomOverlayAddressFrom(length int, src *rand.Rand) (OverlayAddress, error) {
+	return randomOverlayAddress(length, src)
+}
+
+// RandomOverlayAddressAtFrom is RandomOverlayAddressAt drawing its randomness
+// from src.
+func RandomOverlayAddressAtFrom(self OverlayAddress, prox int, src *rand.Rand) (OverlayAddress, error) {
+	return randomOverlayAddressAt(self, prox, src)
+}
+
+// SecureRandomOverlayAddress generates a random overlay address of the given
+// length from the cryptographically secure system source.
+func SecureRandomOverlayAddress(length int) (OverlayAddress, error) {
+	return randomOverlayAddress(length, crand.Reader)
+}
+
+// randomOverlayAddress generates a random overlay address of the given length
+// reading its randomness from src.
+func randomOverlayAddress(length int, src io.Reader) (OverlayAddress, error) {
+	if !supportedLength(length) {
+		return nil, fmt.Errorf("unsupported overlay address length %d", length)
+	}
+	addr := make(OverlayAddress, length)
+	if err := randomizeAt(addr, -1, src); err != nil {
+		return nil, err
+	}
+	return addr, nil
+}
+
+// randomOverlayAddressAt generates a random overlay address at proximity order
+// prox relative to self, reading its randomness from src.
+func randomOverlayAddressAt(self OverlayAddress, prox int, src io.Reader) (OverlayAddress, error) {
+	if !supportedLength(len(self)) {
+		return nil, fmt.Errorf("unsupported overlay address length %d", len(self))
+	}
+	addr := common.CopyBytes(self)
+	if err := randomizeAt(addr, prox, src); err != nil {
+		return nil, err
+	}
+	return addr, nil
+}
+
+// OverlayAddress returns the address as a 32 byte overlay address.
+func (a Address) OverlayAddress() OverlayAddress {
+	return common.CopyBytes(a[:])
+}
+
+// ToAddress converts a 32 byte overlay address to the fixed size form.
+func (a OverlayAddress) ToAddress() (Address, error) {
+	if len(a) != AddressLength {
+		return Address{}, fmt.Errorf("overlay address length %d, want %d", len(a), AddressLength)
+	}
+	var addr Address
+	copy(addr[:], a)
+	return addr, nil
+}
+
+func (a OverlayAddress) String() string {
+	return fmt.Sprintf("%x", []byte(a))
+}
+
+// Bin returns the string form of the binary representation of the address.
+func (a OverlayAddress) Bin() string {
+	var bs []string
+	for _, b := range a {
+		bs = append(bs, fmt.Sprintf("%08b", b))
+	}
+	return strings.Join(bs, "")
+}
+
+func (a OverlayAddress) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + a.String() + `"`), nil
+}
+
+func (a *OverlayAddress) UnmarshalJSON(value []byte) error {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return fmt.Errorf("invalid overlay address %s", value)
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(string(value[1:len(value)-1]), "0x"))
+	if err != nil {
+		return err
+	}
+	addr, err := NewOverlayAddress(b)
+	if err != nil {
+		return err
+	}
+	*a = addr
+	return nil
+}
+
+// ProxCmp compares the distances a->target and b->target.
+// Returns -1 if a is closer to target, 1 if b is closer to target
+// and 0 if they are equal.
+func (target OverlayAddress) ProxCmp(a, b OverlayAddress) (int, error) {
+	if err := checkMatch(target, a); err != nil {
+		return 0, err
+	}
+	if err := checkMatch(target, b); err != nil {
+		return 0, err
+	}
+	return proxCmpBytes(target, a, b), nil
+}
+
+// OverlayProximity returns the proximity order of two overlay addresses, from
+// 0 (farthest) to the address length in bits (self).
+func OverlayProximity(one, other OverlayAddress) (int, error) {
+	if err := checkMatch(one, other); err != nil {
+		return 0, err
+	}
+	return proximityBytes(one, other), nil
+}
+
+// OverlayKeyRange returns the inclusive address range containing the addresses
+// closer to one than other, at most proxLimit bits deep.
+func OverlayKeyRange(one, other OverlayAddress, proxLimit int) (start, stop OverlayAddress, err error) {
+	prox, err := OverlayProximity(one, other)
+	if err != nil {
+		return nil, nil, err
+	}
+	if prox >= proxLimit {
+		prox = proxLimit
+	}
+	start, stop = common.CopyBytes(one), common.CopyBytes(one)
+	commonBits(start, other, func() byte { return 0x00 }, prox)
+	commonBits(stop, other, func() byte { return 0xff }, prox)
+	return start, stop, nil
+}
+
+// checkMatch returns an error if two overlay addresses belong to different
+// address spaces, or to none of the supported ones.
+func checkMatch(one, other OverlayAddress) error {
+	if !supportedLength(len(one)) {
+		return fmt.Errorf("unsupported overlay address length %d", len(one))
+	}
+	if len(one) != len(other) {
+		return fmt.Errorf("overlay address length mismatch: %d != %d", len(one), len(other))
+	}
+	return nil
+}
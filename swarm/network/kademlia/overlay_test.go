@@ -0,0 +1,189 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"testing"
+)
+
+var overlayLengths = []int{ShortAddressLength, AddressLength, LongAddressLength}
+
+func TestOverlayProximity(t *testing.T) {
+	for _, length := range overlayLengths {
+		a, err := RandomOverlayAddress(length)
+		if err != nil {
+			t.Fatalf("length %d: failed to generate address: %v", length, err)
+		}
+		if prox, _ := OverlayProximity(a, a); prox != length*8 {
+			t.Fatalf("length %d: self proximity mismatch: have %d, want %d", length, prox, length*8)
+		}
+		for bit := 0; bit <= length*8; bit++ {
+			b, err := RandomOverlayAddressAt(a, bit)
+			if err != nil {
+				t.Fatalf("length %d: failed to generate address at %d: %v", length, bit, err)
+			}
+			if prox, _ := OverlayProximity(a, b); prox != bit {
+				t.Fatalf("length %d: proximity mismatch: have %d, want %d", length, prox, bit)
+			}
+			if bit == length*8 {
+				continue
+			}
+			ab, _ := a.ProxCmp(a, b)
+			ba, _ := a.ProxCmp(b, a)
+			bb, _ := a.ProxCmp(b, b)
+			if ab != -1 || ba != 1 || bb != 0 {
+				t.Fatalf("length %d: distance comparison mismatch at proximity %d", length, bit)
+			}
+		}
+		if _, err := RandomOverlayAddressAt(a, length*8+1); err == nil {
+			t.Fatalf("length %d: address generated beyond the address length", length)
+		}
+	}
+}
+
+func TestRandomOverlayAddressFrom(t *testing.T) {
+	for _, length := range overlayLengths {
+		one, other := rand.New(rand.NewSource(1)), rand.New(rand.NewSource(1))
+		a, err := RandomOverlayAddressFrom(length, one)
+		if err != nil {
+			t.Fatalf("length %d: failed to generate address: %v", length, err)
+		}
+		b, _ := RandomOverlayAddressFrom(length, other)
+		if !bytes.Equal(a, b) {
+			t.Fatalf("length %d: seeded addresses mismatch: %v != %v", length, a, b)
+		}
+		c, _ := RandomOverlayAddressAtFrom(a, 42, one)
+		d, _ := RandomOverlayAddressAtFrom(b, 42, other)
+		if !bytes.Equal(c, d) {
+			t.Fatalf("length %d: seeded addresses mismatch: %v != %v", length, c, d)
+		} else if prox, _ := OverlayProximity(a, c); prox != 42 {
+			t.Fatalf("length %d: proximity mismatch: have %d, want 42", length, prox)
+		}
+		secure, err := SecureRandomOverlayAddress(length)
+		if err != nil {
+			t.Fatalf("length %d: failed to generate secure address: %v", length, err)
+		}
+		if len(secure) != length {
+			t.Fatalf("length %d: secure address length mismatch: have %d", length, len(secure))
+		}
+	}
+	if _, err := RandomOverlayAddress(16); err == nil {
+		t.Fatalf("address of unsupported length generated")
+	}
+	if _, err := RandomOverlayAddressFrom(16, rand.New(rand.NewSource(1))); err == nil {
+		t.Fatalf("seeded address of unsupported length generated")
+	}
+	if _, err := SecureRandomOverlayAddress(16); err == nil {
+		t.Fatalf("secure address of unsupported length generated")
+	}
+}
+
+func TestOverlayKeyRange(t *testing.T) {
+	for _, length := range overlayLengths {
+		one, _ := RandomOverlayAddress(length)
+		other, _ := RandomOverlayAddressAt(one, rand.Intn(length*8))
+		prox, _ := OverlayProximity(one, other)
+
+		start, stop, err := OverlayKeyRange(one, other, length*8)
+		if err != nil {
+			t.Fatalf("length %d: failed to compute range: %v", length, err)
+		}
+		if bytes.Compare(start, one) > 0 || bytes.Compare(stop, one) < 0 {
+			t.Fatalf("length %d: range %v-%v doesn't contain %v", length, start, stop, one)
+		}
+		startProx, _ := OverlayProximity(start, one)
+		stopProx, _ := OverlayProximity(stop, one)
+		if startProx < prox || stopProx < prox {
+			t.Fatalf("length %d: range %v-%v doesn't share %d bits with %v", length, start, stop, prox, one)
+		}
+		// An address is all of its own range
+		if start, stop, err := OverlayKeyRange(one, one, length*8); err != nil || !bytes.Equal(start, one) || !bytes.Equal(stop, one) {
+			t.Fatalf("length %d: self range mismatch: have %v-%v (%v), want %v", length, start, stop, err, one)
+		}
+	}
+	// The default overlay matches the fixed size implementation
+	one, other := RandomAddress(), RandomAddress()
+	start, stop := KeyRange(one, other, 256)
+	ostart, ostop, _ := OverlayKeyRange(one.OverlayAddress(), other.OverlayAddress(), 256)
+	if !bytes.Equal(start[:], ostart) || !bytes.Equal(stop[:], ostop) {
+		t.Fatalf("key range mismatch: have %v-%v, want %v-%v", ostart, ostop, start, stop)
+	}
+}
+
+func TestOverlayAddressConversion(t *testing.T) {
+	if _, err := NewOverlayAddress(make([]byte, 16)); err == nil {
+		t.Fatalf("unsupported length accepted")
+	}
+	addr := RandomAddress()
+	back, err := addr.OverlayAddress().ToAddress()
+	if err != nil || back != addr {
+		t.Fatalf("address round trip mismatch: have %v (%v), want %v", back, err, addr)
+	}
+	long, _ := RandomOverlayAddress(LongAddressLength)
+	if _, err := long.ToAddress(); err == nil {
+		t.Fatalf("long address converted to fixed size")
+	}
+	for _, length := range overlayLengths {
+		a, _ := RandomOverlayAddress(length)
+		blob, err := json.Marshal(a)
+		if err != nil {
+			t.Fatalf("length %d: failed to encode: %v", length, err)
+		}
+		var b OverlayAddress
+		if err := json.Unmarshal(blob, &b); err != nil || !bytes.Equal(a, b) {
+			t.Fatalf("length %d: JSON round trip mismatch: have %v (%v), want %v", length, b, err, a)
+		}
+	}
+}
+
+func TestOverlayUnsupportedLength(t *testing.T) {
+	src := rand.New(rand.NewSource(1))
+	for _, self := range []OverlayAddress{nil, {}, make(OverlayAddress, 7)} {
+		if _, err := RandomOverlayAddressAt(self, -1); err == nil {
+			t.Errorf("length %d: address generated around unsupported address", len(self))
+		}
+		if _, err := RandomOverlayAddressAtFrom(self, -1, src); err == nil {
+			t.Errorf("length %d: seeded address generated around unsupported address", len(self))
+		}
+		if _, err := OverlayProximity(self, self); err == nil {
+			t.Errorf("length %d: proximity of unsupported addresses computed", len(self))
+		}
+		if _, err := self.ProxCmp(self, self); err == nil {
+			t.Errorf("length %d: distances of unsupported addresses compared", len(self))
+		}
+		if _, _, err := OverlayKeyRange(self, self, 8); err == nil {
+			t.Errorf("length %d: range of unsupported addresses computed", len(self))
+		}
+	}
+}
+
+func TestOverlayLengthMismatch(t *testing.T) {
+	short, _ := RandomOverlayAddress(ShortAddressLength)
+	long, _ := RandomOverlayAddress(AddressLength)
+	if _, err := OverlayProximity(short, long); err == nil {
+		t.Fatalf("proximity of different address spaces computed")
+	}
+	if _, err := short.ProxCmp(short, long); err == nil {
+		t.Fatalf("distances in different address spaces compared")
+	}
+	if _, _, err := OverlayKeyRange(short, long, 8); err == nil {
+		t.Fatalf("range over different address spaces computed")
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"errors"
+	"time"
+)
+
+var errPinSelf = errors.New("cannot pin own address")
+
+// Pin protects the node with the address: it is never evicted from its
+// bucket nor purged from the kaddb, it is preferred when connecting to the
+// bin, and it replaces an unpinned peer of its full bucket even if that one
+// is in use. Unknown nodes are added to the kaddb, url is the address to
+// connect them to, which may be empty if it is known otherwise. Pins are kept
+// with the records when the kaddb is saved.
+func (self *Kademlia) Pin(a Address, url string) error {
+	if a == self.addr {
+		return errPinSelf
+	}
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	self.db.pin(a, url, self.proximityBin(a))
+	return nil
+}
+
+// Unpin lifts the protection of the node with the address, it returns false
+// if it was not pinned
+func (self *Kademlia) Unpin(a Address) bool {
+	var pinned bool
+	self.db.update(a, func(record *NodeRecord) {
+		pinned, record.Pinned = record.Pinned, false
+	})
+	return pinned
+}
+
+// Pinned returns the addresses of the pinned nodes, by proximity order
+func (self *Kademlia) Pinned() []Address {
+	defer self.db.lock.RUnlock()
+	self.db.lock.RLock()
+
+	var addrs []Address
+	for _, row := range self.db.Nodes {
+		for _, record := range row {
+			if record.Pinned {
+				addrs = append(addrs, record.Addr)
+			}
+		}
+	}
+	return addrs
+}
+
+// pin marks the record of the address pinned, creating it in row po if needed
+func (self *KadDb) pin(a Address, url string, po int) {
+	defer self.lock.Unlock()
+	self.lock.Lock()
+
+	record, found := self.index[a]
+	if !found {
+		now := time.Now()
+		record = &NodeRecord{
+			Addr:  a,
+			Url:   url,
+			After: now,
+			Seen:  now,
+		}
+		self.index[a] = record
+		self.Nodes[po] = append(self.Nodes[po], record)
+	} else if len(url) > 0 {
+		record.Url = url
+	}
+	record.Pinned = true
+}
+
+// pinned tells if the node of the address is pinned
+func (self *KadDb) pinned(a Address) bool {
+	defer self.lock.RUnlock()
+	self.lock.RLock()
+	record, ok := self.index[a]
+	return ok && record.Pinned
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPinEviction(t *testing.T) {
+	self := RandomAddress()
+	params := NewDefaultKadParams()
+	params.BucketSize = 2
+	params.MaxIdleInterval = time.Minute
+	kad := New(self, params)
+
+	if err := kad.Pin(self, ""); err == nil {
+		t.Error("own address pinned")
+	}
+	nodes := []*evictNode{
+		{idle: 2 * time.Hour},
+		{idle: time.Hour},
+	}
+	for i, n := range nodes {
+		n.addr = RandomAddressAt(self, 0)
+		if err := kad.On(n, nil); err != nil {
+			t.Fatalf("node %d not added: %v", i, err)
+		}
+	}
+	if err := kad.Pin(nodes[0].addr, ""); err != nil {
+		t.Fatalf("unexpected error pinning: %v", err)
+	}
+
+	// the least recently active node is pinned so the other one is evicted
+	if err := kad.On(&evictNode{testNode: testNode{addr: RandomAddressAt(self, 0)}}, nil); err != nil {
+		t.Fatalf("node not added: %v", err)
+	}
+	if nodes[0].dropped || !nodes[1].dropped {
+		t.Fatalf("have pinned node dropped: %v, other node dropped: %v; want false, true", nodes[0].dropped, nodes[1].dropped)
+	}
+	kad.Off(nodes[1], nil)
+
+	// a pinned node replaces a peer in use, but never a pinned one
+	active := &evictNode{testNode: testNode{addr: RandomAddressAt(self, 0)}, idle: time.Second}
+	kad.lock.Lock()
+	for i, n := range kad.buckets[0] {
+		if n.Addr() != nodes[0].addr {
+			kad.buckets[0][i] = active
+		}
+	}
+	kad.lock.Unlock()
+	partner := &evictNode{testNode: testNode{addr: RandomAddressAt(self, 0)}}
+	kad.Pin(partner.addr, "enode://partner")
+	if err := kad.On(partner, nil); err != nil {
+		t.Fatalf("pinned node not added: %v", err)
+	}
+	if !active.dropped || nodes[0].dropped {
+		t.Errorf("have active node dropped: %v, pinned node dropped: %v; want true, false", active.dropped, nodes[0].dropped)
+	}
+	if err := kad.On(&evictNode{testNode: testNode{addr: RandomAddressAt(self, 0)}}, nil); err == nil {
+		t.Error("pinned node evicted")
+	}
+
+	if !kad.Unpin(partner.addr) {
+		t.Error("pinned node not unpinned")
+	}
+	if kad.Unpin(partner.addr) {
+		t.Error("node unpinned twice")
+	}
+	if pinned := kad.Pinned(); len(pinned) != 1 || pinned[0] != nodes[0].addr {
+		t.Errorf("have pinned %v, want %v", pinned, nodes[0].addr)
+	}
+}
+
+func TestPinPersistence(t *testing.T) {
+	self := RandomAddress()
+	params := NewDefaultKadParams()
+	params.PurgeInterval = time.Hour
+	kad := New(self, params)
+
+	// unknown nodes are added to the kaddb when pinned
+	addr := RandomAddressAt(self, 1)
+	kad.Pin(addr, "enode://pinned")
+	record := kad.db.record(addr)
+	if record == nil || !record.Pinned || record.Url != "enode://pinned" {
+		t.Fatalf("have record %+v, want pinned record with url", record)
+	}
+
+	path := filepath.Join(os.TempDir(), "bzz-kad-test-pin.peers")
+	defer os.Remove(path)
+	if err := kad.Save(path, nil); err != nil {
+		t.Fatalf("unexpected error saving kaddb: %v", err)
+	}
+	kad = New(self, params)
+	if err := kad.Load(path, nil); err != nil {
+		t.Fatalf("unexpected error loading kaddb: %v", err)
+	}
+	if pinned := kad.Pinned(); len(pinned) != 1 || pinned[0] != addr {
+		t.Fatalf("have pinned %v after loading, want %v", pinned, addr)
+	}
+
+	// pinned records are not purged and suggested before others
+	other := RandomAddressAt(self, 1)
+	kad.Add([]*NodeRecord{{Addr: other}})
+	kad.RecordRetrieval(other)
+	kad.db.lock.Lock()
+	kad.db.index[addr].Seen = time.Now().Add(-2 * time.Hour)
+	kad.db.lock.Unlock()
+	if record, _, _ := kad.Suggest(); record == nil || record.Addr != addr {
+		t.Fatalf("have %v suggested, want pinned %v", record, addr)
+	}
+}
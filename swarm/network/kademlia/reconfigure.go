@@ -0,0 +1,186 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/matrix/go-matrix/log"
+)
+
+// maxMaxProx is the deepest bin, holding the nodes sharing all address bits
+const maxMaxProx = len(Address{}) * 8
+
+// TableParams are the parameters shaping the table, which can be changed
+// while it runs with Reconfigure
+type TableParams struct {
+	MaxProx     int `json:"maxProx"`     // deepest bin, closer nodes are binned together
+	ProxBinSize int `json:"proxBinSize"` // minimum number of peers in the nearest neighbourhood
+	BucketSize  int `json:"bucketSize"`  // maximum number of peers per bin
+}
+
+func (self TableParams) validate() error {
+	if self.MaxProx < 0 || self.MaxProx > maxMaxProx {
+		return fmt.Errorf("invalid MaxProx %d, want 0..%d", self.MaxProx, maxMaxProx)
+	}
+	if self.ProxBinSize < 0 {
+		return fmt.Errorf("invalid ProxBinSize %d", self.ProxBinSize)
+	}
+	if self.BucketSize < 0 {
+		return fmt.Errorf("invalid BucketSize %d", self.BucketSize)
+	}
+	return nil
+}
+
+// Params returns a copy of the parameters of the table
+func (self *Kademlia) Params() KadParams {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return *self.KadParams
+}
+
+// TableParams returns the parameters shaping the table
+func (self *Kademlia) TableParams() TableParams {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return TableParams{MaxProx: self.MaxProx, ProxBinSize: self.ProxBinSize, BucketSize: self.BucketSize}
+}
+
+// Reconfigure changes the shape of the running table, zero parameters being
+// left as they are. Active peers and known node records are rebinned; peers
+// beyond the bucket size of their new bin are dropped, the least recently
+// active unpinned ones first, and their addresses returned.
+func (self *Kademlia) Reconfigure(params TableParams) ([]Address, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	var events []TableEvent
+	var dropped []Node
+	defer func() {
+		// peers may be disconnected synchronously, calling Off
+		for _, node := range dropped {
+			node.Drop()
+		}
+		self.notify(events)
+	}()
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	defer self.updateGauges()
+
+	kadParams := *self.KadParams
+	if params.MaxProx > 0 {
+		kadParams.MaxProx = params.MaxProx
+	}
+	if params.ProxBinSize > 0 {
+		kadParams.ProxBinSize = params.ProxBinSize
+	}
+	if params.BucketSize > 0 {
+		kadParams.BucketSize = params.BucketSize
+	}
+	// the params may be shared with the caller of New, so they are replaced
+	// rather than modified
+	self.KadParams = &kadParams
+	self.initMetricsVariables()
+
+	depth := self.proxLimit
+	buckets := make([][]Node, self.MaxProx+1)
+	for _, bucket := range self.buckets {
+		for _, node := range bucket {
+			po := self.proximityBin(node.Addr())
+			buckets[po] = append(buckets[po], node)
+		}
+	}
+	var neighbourhood bool
+	for po, bucket := range buckets {
+		if len(bucket) <= self.BucketSize {
+			continue
+		}
+		// pinned peers are kept first
+		pinned := make(map[Address]bool)
+		for _, node := range bucket {
+			pinned[node.Addr()] = self.db.pinned(node.Addr())
+		}
+		sort.SliceStable(bucket, func(i, j int) bool {
+			if pi, pj := pinned[bucket[i].Addr()], pinned[bucket[j].Addr()]; pi != pj {
+				return pi
+			}
+			return bucket[i].LastActive().After(bucket[j].LastActive())
+		})
+		for _, node := range bucket[self.BucketSize:] {
+			addr := node.Addr()
+			dropped = append(dropped, node)
+			events = append(events, TableEvent{Type: PeerRemoved, Peer: &addr, PO: po})
+			bucketRmIndexCount[po].Inc(1)
+		}
+		buckets[po] = bucket[:self.BucketSize]
+	}
+	self.buckets = buckets
+	self.resetProxLimit()
+	self.db.rebin(self.MaxProx+1, self.proximityBin)
+
+	for i := range events {
+		events[i].Depth, events[i].Count = self.proxLimit, self.count
+		neighbourhood = neighbourhood || events[i].PO >= self.proxLimit
+	}
+	if self.proxLimit != depth {
+		events = append(events, TableEvent{Type: DepthChanged, Depth: self.proxLimit, Count: self.count})
+	}
+	if neighbourhood || self.proxLimit != depth {
+		events = append(events, TableEvent{Type: NeighbourhoodChanged, Depth: self.proxLimit, Count: self.count})
+	}
+
+	addrs := make([]Address, len(dropped))
+	for i, node := range dropped {
+		addrs[i] = node.Addr()
+	}
+	log.Info(fmt.Sprintf("kademlia table reconfigured: MaxProx: %d, ProxBinSize: %d, BucketSize: %d, depth: %d, dropped %d peers", self.MaxProx, self.ProxBinSize, self.BucketSize, self.proxLimit, len(dropped)))
+	return addrs, nil
+}
+
+// resetProxLimit computes the proximity limit and size from scratch, as
+// setProxLimit would have adjusted them adding the nodes one by one
+// caller holds the lock
+func (self *Kademlia) resetProxLimit() {
+	var size int
+	for _, bucket := range self.buckets {
+		size += len(bucket)
+	}
+	var limit int
+	for limit < self.MaxProx && len(self.buckets[limit]) > 0 && size-len(self.buckets[limit]) >= self.ProxBinSize {
+		size -= len(self.buckets[limit])
+		limit++
+	}
+	self.proxLimit, self.proxSize = limit, size
+}
+
+// rebin redistributes the node records into the given number of rows,
+// keeping their order within the rows
+func (self *KadDb) rebin(rows int, proximityBin func(Address) int) {
+	defer self.lock.Unlock()
+	self.lock.Lock()
+
+	nodes := make([][]*NodeRecord, rows)
+	for _, row := range self.Nodes {
+		for _, record := range row {
+			po := proximityBin(record.Addr)
+			nodes[po] = append(nodes[po], record)
+		}
+	}
+	self.Nodes = nodes
+	self.cursors = make([]int, rows)
+}
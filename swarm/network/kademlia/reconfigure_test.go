@@ -0,0 +1,168 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// dropNode is a test node recording whether it was dropped
+type dropNode struct {
+	testNode
+	active  time.Time
+	dropped bool
+}
+
+func (n *dropNode) Drop()                 { n.dropped = true }
+func (n *dropNode) LastActive() time.Time { return n.active }
+
+func TestReconfigure(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	self := gen(Address{}, r).(Address)
+	params := NewDefaultKadParams()
+	params.MaxProx = 7
+	kad := New(self, params)
+
+	// peers are too recently active to be evicted by On
+	nodes, all := make(map[Address]*dropNode), make(map[Address]*dropNode)
+	for i := 0; i < 100; i++ {
+		node := &dropNode{testNode: testNode{gen(Address{}, r).(Address)}, active: time.Now().Add(-time.Duration(i) * time.Millisecond)}
+		if kad.On(node, nil) == nil {
+			nodes[node.addr], all[node.addr] = node, node
+		}
+	}
+	var records []*NodeRecord
+	for i := 0; i < 50; i++ {
+		records = append(records, &NodeRecord{Addr: RandomAddressAt(self, r.Intn(12))})
+	}
+	kad.Add(records)
+	known := kad.DBCount()
+
+	events := make(chan TableEvent, 100)
+	sub := kad.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+
+	for _, tt := range []TableParams{
+		{MaxProx: 10, BucketSize: 2},
+		{MaxProx: 3},
+		{ProxBinSize: 5, BucketSize: 8},
+	} {
+		before := kad.TableParams()
+		dropped, err := kad.Reconfigure(tt)
+		if err != nil {
+			t.Fatalf("%+v: %v", tt, err)
+		}
+		want := before
+		if tt.MaxProx > 0 {
+			want.MaxProx = tt.MaxProx
+		}
+		if tt.ProxBinSize > 0 {
+			want.ProxBinSize = tt.ProxBinSize
+		}
+		if tt.BucketSize > 0 {
+			want.BucketSize = tt.BucketSize
+		}
+		if have := kad.TableParams(); have != want {
+			t.Fatalf("%+v: have params %+v, want %+v", tt, have, want)
+		}
+		if tt.BucketSize > 0 && tt.BucketSize < before.BucketSize && len(dropped) == 0 {
+			t.Errorf("%+v: no peer dropped shrinking the bins", tt)
+		}
+		for _, addr := range dropped {
+			node := nodes[addr]
+			if !node.dropped {
+				t.Errorf("%+v: peer %v removed without being dropped", tt, addr)
+			}
+			delete(nodes, addr)
+			kad.Off(node, nil)
+		}
+		for i := range dropped {
+			if ev := <-events; ev.Type != PeerRemoved || *ev.Peer != dropped[i] {
+				t.Errorf("%+v: have event %+v, want removal of %v", tt, ev, dropped[i])
+			}
+		}
+		for len(events) > 0 {
+			if ev := <-events; ev.Type == PeerAdded || ev.Type == PeerRemoved {
+				t.Errorf("%+v: unexpected event %+v", tt, ev)
+			}
+		}
+
+		if len(kad.buckets) != want.MaxProx+1 || len(kad.db.Nodes) != want.MaxProx+1 {
+			t.Fatalf("%+v: have %d bins and %d kaddb rows, want %d", tt, len(kad.buckets), len(kad.db.Nodes), want.MaxProx+1)
+		}
+		var count int
+		for po, bucket := range kad.buckets {
+			if len(bucket) > want.BucketSize {
+				t.Errorf("%+v: bin %d holds %d peers", tt, po, len(bucket))
+			}
+			for _, node := range bucket {
+				if binOf(self, node.Addr(), want.MaxProx) != po {
+					t.Errorf("%+v: peer %v in wrong bin %d", tt, node.Addr(), po)
+				}
+				// the most recently active peers are kept
+				for _, addr := range dropped {
+					if nodes[node.Addr()].active.Before(all[addr].active) && binOf(self, addr, want.MaxProx) == po {
+						t.Errorf("%+v: peer %v kept over more active %v", tt, node.Addr(), addr)
+					}
+				}
+				count++
+			}
+		}
+		if count != len(nodes) || kad.Count() != len(nodes) {
+			t.Errorf("%+v: have %d peers in bins, count %d, want %d", tt, count, kad.Count(), len(nodes))
+		}
+		var rows int
+		for po, row := range kad.db.Nodes {
+			for _, record := range row {
+				if binOf(self, record.Addr, want.MaxProx) != po {
+					t.Errorf("%+v: record %v in wrong row %d", tt, record.Addr, po)
+				}
+				rows++
+			}
+		}
+		if rows != known {
+			t.Errorf("%+v: have %d records, want %d", tt, rows, known)
+		}
+		if !kad.proxCheck(t) {
+			t.Fatalf("%+v: inconsistent depth", tt)
+		}
+	}
+	if params.MaxProx != 7 || params.BucketSize != bucketSize {
+		t.Errorf("params passed to New modified: %+v", params)
+	}
+	if _, err := kad.Reconfigure(TableParams{MaxProx: maxMaxProx + 1}); err == nil {
+		t.Error("MaxProx beyond the address length accepted")
+	}
+}
+
+func TestResetProxLimit(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	params := NewDefaultKadParams()
+	params.MaxProx = 7
+	kad := New(gen(Address{}, r).(Address), params)
+
+	for i := 0; i < 60; i++ {
+		kad.On(&testNode{addr: gen(Address{}, r).(Address)}, nil)
+		limit, size := kad.proxLimit, kad.proxSize
+		kad.resetProxLimit()
+		if kad.proxLimit != limit || kad.proxSize != size {
+			t.Fatalf("after %d nodes: have limit %d size %d, incremental %d %d", i+1, kad.proxLimit, kad.proxSize, limit, size)
+		}
+	}
+}
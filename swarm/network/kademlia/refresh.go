@@ -0,0 +1,166 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/metrics"
+)
+
+// refreshJitter randomizes refresh intervals by up to this fraction, so that
+// the lookups of a bin do not line up with those of the others
+const refreshJitter = 0.25
+
+var (
+	refreshMeter = metrics.NewRegisteredMeter("network.kademlia.refresh", nil)
+
+	binRefreshIntervalGauges []metrics.Gauge // milliseconds
+	binStalenessGauges       []metrics.Gauge // milliseconds since the last refresh
+)
+
+// RefreshScheduler decides when the bins of a table are refreshed by lookups
+// of random addresses in them. Sparse bins are refreshed every
+// RefreshInterval, the interval growing geometrically with the fill of the bin
+// up to MaxRefreshInterval for saturated ones. Tables with a zero
+// RefreshInterval are not refreshed.
+type RefreshScheduler struct {
+	kad       *Kademlia
+	refreshed []time.Time // last refresh per bin
+	jitter    []float64   // interval factor of the current schedule per bin
+	rand      *rand.Rand
+	lock      sync.Mutex
+}
+
+// NewRefreshScheduler creates a scheduler for the bins of the table. All bins
+// are considered refreshed at creation time.
+func NewRefreshScheduler(kad *Kademlia) *RefreshScheduler {
+	return &RefreshScheduler{
+		kad:  kad,
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// BinSchedule is the refresh schedule of a single proximity bin
+type BinSchedule struct {
+	PO        int           `json:"po"`
+	Fill      float64       `json:"fill"`      // share of the bucket size connected, 0..1
+	Interval  time.Duration `json:"interval"`  // time between refreshes at this fill
+	Refreshed time.Time     `json:"refreshed"` // last refresh
+	Due       time.Time     `json:"due"`       // next refresh, jitter applied
+}
+
+// Schedule returns the refresh schedule of all bins of the table
+func (self *RefreshScheduler) Schedule(now time.Time) []BinSchedule {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.schedule(now)
+}
+
+// Next returns the bin to be refreshed next and the time left until it is
+// due, zero if overdue. Bins overdue longest are refreshed first.
+func (self *RefreshScheduler) Next(now time.Time) (po int, wait time.Duration) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	schedule := self.schedule(now)
+	po = -1
+	for _, bin := range schedule {
+		if po < 0 || bin.Due.Before(schedule[po].Due) {
+			po = bin.PO
+		}
+		if metrics.Enabled && bin.PO < len(binRefreshIntervalGauges) {
+			binRefreshIntervalGauges[bin.PO].Update(int64(bin.Interval / time.Millisecond))
+			binStalenessGauges[bin.PO].Update(int64(now.Sub(bin.Refreshed) / time.Millisecond))
+		}
+	}
+	if po < 0 {
+		return 0, 0
+	}
+	if wait = schedule[po].Due.Sub(now); wait < 0 {
+		wait = 0
+	}
+	return po, wait
+}
+
+// Refreshed records a lookup refreshing the bin, rescheduling it
+func (self *RefreshScheduler) Refreshed(po int, now time.Time) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.grow(po+1, now)
+	self.refreshed[po] = now
+	self.jitter[po] = 1 + refreshJitter*(2*self.rand.Float64()-1)
+	refreshMeter.Mark(1)
+}
+
+// Target returns a random address in the bin to look up for refreshing it
+func (self *RefreshScheduler) Target(po int) Address {
+	return RandomAddressAt(self.kad.Addr(), po)
+}
+
+// grow extends the per bin state to n bins, the new ones refreshed at now.
+// caller holds the lock
+func (self *RefreshScheduler) grow(n int, now time.Time) {
+	for len(self.refreshed) < n {
+		self.refreshed = append(self.refreshed, now)
+		self.jitter = append(self.jitter, 1+refreshJitter*(2*self.rand.Float64()-1))
+	}
+}
+
+// schedule computes the schedule of the bins of the table in its current
+// shape, the table possibly having been reconfigured since the last call.
+// caller holds the lock
+func (self *RefreshScheduler) schedule(now time.Time) []BinSchedule {
+	kad := self.kad
+	kad.lock.RLock()
+	minInterval, maxInterval := kad.RefreshInterval, kad.MaxRefreshInterval
+	fills := make([]float64, len(kad.buckets))
+	for po, bucket := range kad.buckets {
+		fills[po] = 1
+		if kad.BucketSize > 0 {
+			fills[po] = math.Min(float64(len(bucket))/float64(kad.BucketSize), 1)
+		}
+	}
+	kad.lock.RUnlock()
+
+	self.grow(len(fills), now)
+	schedule := make([]BinSchedule, len(fills))
+	for po, fill := range fills {
+		interval := binRefreshInterval(minInterval, maxInterval, fill)
+		schedule[po] = BinSchedule{
+			PO:        po,
+			Fill:      fill,
+			Interval:  interval,
+			Refreshed: self.refreshed[po],
+			Due:       self.refreshed[po].Add(time.Duration(float64(interval) * self.jitter[po])),
+		}
+	}
+	return schedule
+}
+
+// binRefreshInterval interpolates geometrically between the intervals of empty
+// and full bins, so that refreshes back off quickly once a bin has peers
+func binRefreshInterval(min, max time.Duration, fill float64) time.Duration {
+	if min <= 0 || max <= min {
+		return min
+	}
+	return time.Duration(float64(min) * math.Pow(float64(max)/float64(min), fill))
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefreshScheduler(t *testing.T) {
+	self := Address{}
+	params := NewDefaultKadParams()
+	params.MaxProx = 7
+	params.RefreshInterval = time.Second
+	params.MaxRefreshInterval = time.Minute
+	kad := New(self, params)
+	for i := 0; i < params.BucketSize; i++ {
+		if err := kad.On(&testNode{RandomAddressAt(self, 0)}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sched := NewRefreshScheduler(kad)
+	start := time.Now()
+
+	// the full bin backs off, the empty ones are refreshed often
+	for _, bin := range sched.Schedule(start) {
+		want := params.RefreshInterval
+		if bin.PO == 0 {
+			want = params.MaxRefreshInterval
+		}
+		if bin.Interval != want {
+			t.Errorf("bin %d with fill %.2f: have interval %v, want %v", bin.PO, bin.Fill, bin.Interval, want)
+		}
+		if !bin.Refreshed.Equal(start) {
+			t.Errorf("bin %d: refreshed at %v, want %v", bin.PO, bin.Refreshed, start)
+		}
+	}
+	po, wait := sched.Next(start)
+	if po == 0 || wait < time.Duration(float64(params.RefreshInterval)*(1-refreshJitter)) || wait > time.Duration(float64(params.RefreshInterval)*(1+refreshJitter)) {
+		t.Fatalf("have bin %d due in %v, want a sparse bin due in about %v", po, wait, params.RefreshInterval)
+	}
+
+	// once the sparse bins are refreshed, the stale full bin is overdue
+	now := start.Add(2 * params.MaxRefreshInterval)
+	for i := 1; i <= params.MaxProx; i++ {
+		po, wait := sched.Next(now)
+		if po == 0 || wait != 0 {
+			t.Fatalf("refresh %d: have bin %d due in %v, want an overdue sparse bin", i, po, wait)
+		}
+		sched.Refreshed(po, now)
+	}
+	if po, wait := sched.Next(now); po != 0 || wait != 0 {
+		t.Fatalf("have bin %d due in %v, want overdue bin 0", po, wait)
+	}
+	sched.Refreshed(0, now)
+	if po, wait := sched.Next(now); po == 0 || wait == 0 {
+		t.Fatalf("have bin %d due in %v after refreshing all bins", po, wait)
+	}
+}
+
+func TestBinRefreshInterval(t *testing.T) {
+	min, max := time.Second, 100*time.Second
+	tests := []struct {
+		fill float64
+		want time.Duration
+	}{
+		{0, min},
+		{0.5, 10 * time.Second},
+		{1, max},
+	}
+	for _, test := range tests {
+		if have := binRefreshInterval(min, max, test.fill); have.Round(time.Millisecond) != test.want {
+			t.Errorf("fill %.2f: have %v, want %v", test.fill, have, test.want)
+		}
+	}
+	if have := binRefreshInterval(min, 0, 1); have != min {
+		t.Errorf("without backoff: have %v, want %v", have, min)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"math"
+	"time"
+)
+
+// weights of the node record reputation score
+const (
+	retrievalReward      = 4.0                    // multiplies the logarithm of the successful retrievals
+	dialFailurePenalty   = 2.0                    // per failed connection attempt
+	protocolErrorPenalty = 5.0                    // per session ended by a protocol error
+	latencyPenaltyUnit   = 100 * time.Millisecond // one point is lost per unit of average latency
+	latencyWeight        = 0.2                    // weight of a new sample in the latency moving average
+	proximityReward      = 1.0                    // per proximity order a record is closer than its row
+)
+
+// Score returns the reputation of the node record. Records which never
+// served or failed anything score zero, reliable nodes score positive and
+// flaky ones negative. Retrievals are rewarded logarithmically so that a
+// long history of good service cannot hide a node that started failing.
+func (self *NodeRecord) Score() float64 {
+	score := retrievalReward * math.Log1p(float64(self.Retrievals))
+	score -= dialFailurePenalty * float64(self.Failures)
+	score -= protocolErrorPenalty * float64(self.ProtocolErrors)
+	score -= float64(self.Latency) / float64(latencyPenaltyUnit)
+	return score
+}
+
+// suggestionScore ranks the node record among the candidates of the kaddb
+// row po for a connection. It is the reputation score with the latency penalty
+// and a reward for proximity beyond the row (only records of the last row
+// differ in proximity) weighted against each other by the latency weight. At
+// the default weight of one half the latency penalty equals the one of Score.
+func (self *KadDb) suggestionScore(record *NodeRecord, po int) float64 {
+	latency := float64(record.Latency) / float64(latencyPenaltyUnit)
+	closer := float64(proximity(self.Address, record.Addr) - po)
+	score := record.Score() + latency
+	score -= 2 * self.latencyWeight * latency
+	score += 2 * (1 - self.latencyWeight) * proximityReward * closer
+	return score
+}
+
+// addLatency folds a round trip time sample into the latency moving average
+func (self *NodeRecord) addLatency(rtt time.Duration) {
+	if self.Latency == 0 {
+		self.Latency = rtt
+		return
+	}
+	self.Latency += time.Duration(latencyWeight * float64(rtt-self.Latency))
+}
+
+// update applies f to the node record of the address, if known
+func (self *KadDb) update(a Address, f func(*NodeRecord)) bool {
+	defer self.lock.Unlock()
+	self.lock.Lock()
+	record, ok := self.index[a]
+	if !ok {
+		return false
+	}
+	f(record)
+	return true
+}
+
+// RecordRetrieval credits the node with a chunk successfully retrieved from it
+func (self *Kademlia) RecordRetrieval(a Address) bool {
+	return self.db.update(a, func(record *NodeRecord) {
+		record.Retrievals++
+	})
+}
+
+// RecordProtocolError penalises the node for a session it broke the protocol in
+func (self *Kademlia) RecordProtocolError(a Address) bool {
+	return self.db.update(a, func(record *NodeRecord) {
+		record.ProtocolErrors++
+	})
+}
+
+// RecordLatency adds a round trip time sample to the latency average of the node.
+// Non-positive samples (no measurement yet) are ignored.
+func (self *Kademlia) RecordLatency(a Address, rtt time.Duration) bool {
+	if rtt <= 0 {
+		return false
+	}
+	return self.db.update(a, func(record *NodeRecord) {
+		record.addLatency(rtt)
+	})
+}
+
+// Score returns the reputation score of the node with the address, zero if not known
+func (self *Kademlia) Score(a Address) float64 {
+	record := self.db.record(a)
+	if record == nil {
+		return 0
+	}
+	return record.Score()
+}
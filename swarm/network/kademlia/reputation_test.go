@@ -0,0 +1,179 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNodeRecordScore(t *testing.T) {
+	fresh := &NodeRecord{}
+	if score := fresh.Score(); score != 0 {
+		t.Fatalf("fresh record: have score %v, want 0", score)
+	}
+	reliable := &NodeRecord{Retrievals: 10, Latency: 50 * time.Millisecond}
+	if score := reliable.Score(); score <= 0 {
+		t.Errorf("reliable record: have score %v, want positive", score)
+	}
+	for _, flaky := range []*NodeRecord{
+		{Failures: 1},
+		{ProtocolErrors: 1},
+		{Latency: time.Second},
+		{Retrievals: 100, ProtocolErrors: 10},
+	} {
+		if score := flaky.Score(); score >= 0 {
+			t.Errorf("flaky record %+v: have score %v, want negative", flaky, score)
+		}
+	}
+}
+
+func TestRecordLatency(t *testing.T) {
+	kad := New(RandomAddress(), NewDefaultKadParams())
+	addr := RandomAddress()
+	if kad.RecordLatency(addr, time.Second) {
+		t.Fatal("latency recorded for unknown node")
+	}
+	kad.Add([]*NodeRecord{{Addr: addr}})
+
+	kad.RecordLatency(addr, 0)
+	kad.RecordLatency(addr, 100*time.Millisecond)
+	if have := kad.db.record(addr).Latency; have != 100*time.Millisecond {
+		t.Fatalf("have latency %v after first sample, want %v", have, 100*time.Millisecond)
+	}
+	kad.RecordLatency(addr, 600*time.Millisecond)
+	if have := kad.db.record(addr).Latency; have != 200*time.Millisecond {
+		t.Fatalf("have latency %v after second sample, want %v", have, 200*time.Millisecond)
+	}
+}
+
+func TestSuggestPrefersReputation(t *testing.T) {
+	self := RandomAddress()
+	params := NewDefaultKadParams()
+	params.RetryJitter = 0
+	kad := New(self, params)
+
+	// all records share the same row so that only reputation decides
+	var addrs []Address
+	for i := 0; i < 4; i++ {
+		addr := RandomAddressAt(self, 0)
+		addrs = append(addrs, addr)
+		kad.Add([]*NodeRecord{{Addr: addr}})
+	}
+	kad.RecordProtocolError(addrs[0])
+	kad.RecordLatency(addrs[1], 2*time.Second)
+	kad.RecordRetrieval(addrs[2])
+
+	for _, want := range []Address{addrs[2], addrs[3], addrs[0], addrs[1]} {
+		record, _, _ := kad.Suggest()
+		if record == nil {
+			t.Fatalf("no node suggested, want %v", want)
+		}
+		if record.Addr != want {
+			t.Fatalf("have %v (score %.2f) suggested, want %v (score %.2f)", record.Addr, record.Score(), want, kad.Score(want))
+		}
+	}
+}
+
+func TestSaveLoadReputation(t *testing.T) {
+	self := RandomAddress()
+	kad := New(self, NewDefaultKadParams())
+	addr := RandomAddress()
+	kad.Add([]*NodeRecord{{Addr: addr}})
+	kad.RecordRetrieval(addr)
+	kad.RecordRetrieval(addr)
+	kad.RecordProtocolError(addr)
+	kad.RecordLatency(addr, 300*time.Millisecond)
+	score := kad.Score(addr)
+
+	path := filepath.Join(os.TempDir(), "bzz-kad-test-save-load-reputation.peers")
+	defer os.Remove(path)
+	if err := kad.Save(path, nil); err != nil {
+		t.Fatalf("unexpected error saving kaddb: %v", err)
+	}
+	kad = New(self, NewDefaultKadParams())
+	if err := kad.Load(path, nil); err != nil {
+		t.Fatalf("unexpected error loading kaddb: %v", err)
+	}
+	loaded := kad.db.record(addr)
+	if loaded == nil {
+		t.Fatal("node record not loaded")
+	}
+	if loaded.Retrievals != 2 || loaded.ProtocolErrors != 1 || loaded.Latency != 300*time.Millisecond {
+		t.Errorf("have %d retrievals, %d protocol errors, latency %v; want 2, 1, %v", loaded.Retrievals, loaded.ProtocolErrors, loaded.Latency, 300*time.Millisecond)
+	}
+	if have := loaded.Score(); have != score {
+		t.Errorf("have score %v after loading, want %v", have, score)
+	}
+}
+
+func TestSuggestLatencyWeight(t *testing.T) {
+	self := RandomAddress()
+	for _, test := range []struct {
+		weight float64
+		near   bool // whether the nearer and slower node is suggested first
+	}{
+		{0, true},
+		{0.5, true},
+		{1, false},
+	} {
+		params := NewDefaultKadParams()
+		params.RetryJitter = 0
+		params.LatencyWeight = test.weight
+		kad := New(self, params)
+
+		// the last row holds nodes of differing proximity
+		near := RandomAddressAt(self, params.MaxProx+2)
+		far := RandomAddressAt(self, params.MaxProx)
+		kad.Add([]*NodeRecord{{Addr: far}, {Addr: near}})
+		kad.RecordLatency(near, 150*time.Millisecond)
+		kad.RecordLatency(far, 50*time.Millisecond)
+
+		want := far
+		if test.near {
+			want = near
+		}
+		record, _, _ := kad.Suggest()
+		if record == nil || record.Addr != want {
+			t.Errorf("latency weight %v: have %v suggested, want %v", test.weight, record, want)
+		}
+	}
+}
+
+func TestSuggestPrefersLowLatency(t *testing.T) {
+	self := RandomAddress()
+	params := NewDefaultKadParams()
+	params.RetryJitter = 0
+	kad := New(self, params)
+
+	// equally proximate nodes are suggested fastest first
+	var addrs []Address
+	for i := 0; i < 3; i++ {
+		addr := RandomAddressAt(self, 1)
+		addrs = append(addrs, addr)
+		kad.Add([]*NodeRecord{{Addr: addr}})
+		kad.RecordLatency(addr, time.Duration(3-i)*100*time.Millisecond)
+	}
+	for i := len(addrs) - 1; i >= 0; i-- {
+		record, _, _ := kad.Suggest()
+		if record == nil || record.Addr != addrs[i] {
+			t.Fatalf("have %v suggested, want %v", record, addrs[i])
+		}
+	}
+}
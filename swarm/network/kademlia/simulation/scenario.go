@@ -0,0 +1,62 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package simulation
+
+import "fmt"
+
+// Step is a step of a scenario: the network changes, then rounds are run.
+type Step struct {
+	Join      int  // number of nodes joining
+	Leave     int  // number of random nodes leaving
+	Partition int  // number of random groups the network is split into, 0 keeps the groups
+	Heal      bool // lets all groups reach each other again
+	Rounds    int  // number of rounds run after the changes
+	Converge  int  // if positive, the most rounds run after those until every group is healthy
+}
+
+// Play plays a scenario, failing on the first step after which the network
+// did not converge within the step's Converge rounds. Nodes join one by one,
+// each followed by a round, after the others left.
+func (self *Simulator) Play(steps []Step) error {
+	for i, step := range steps {
+		addrs := self.Addrs()
+		self.rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+		if step.Leave > len(addrs) {
+			step.Leave = len(addrs)
+		}
+		for _, addr := range addrs[:step.Leave] {
+			self.RemoveNode(addr)
+		}
+		for n := 0; n < step.Join; n++ {
+			self.AddNode()
+			self.Run(1)
+		}
+		if step.Heal {
+			self.Heal()
+		}
+		if step.Partition > 1 {
+			self.Split(step.Partition)
+		}
+		self.Run(step.Rounds)
+		if step.Converge > 0 {
+			if rounds, err := self.RunUntilHealthy(step.Converge); err != nil {
+				return fmt.Errorf("step %d: not converged after %d rounds: %v", i, rounds, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+/*
+Package simulation drives networks of in-memory kademlia tables through joins,
+departures and churn, so kademlia changes can be checked against network level
+properties (see kademlia.CheckHealth) rather than unit tests only.
+
+Nodes pick the peers to connect to with the table's own suggestions and learn
+about other nodes the way the hive does: by exchanging the peers closest to
+each other on connection and in response to lookups for themselves and for
+random chunks.
+
+The nodes talk over a virtual transport which may lose a share of the
+exchanges and be partitioned into groups unable to reach each other, and
+scenarios of joins, departures, partitions and heals can be played with
+convergence checked after each step.
+*/
+package simulation
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
+)
+
+// Simulator is a network of kademlia tables connected in memory
+type Simulator struct {
+	params    *kademlia.KadParams
+	rand      *rand.Rand
+	nodes     map[kademlia.Address]*simNode
+	transport *transport    // reachability of the nodes
+	drops     []*simPeer    // peers dropped by tables, disconnected once the table is released
+	clock     time.Duration // simulated time, advanced by RoundTime every round
+}
+
+// RoundTime is the simulated time a round of lookups and connections takes.
+// Peers without traffic for the table's MaxIdleInterval may be replaced.
+var RoundTime = time.Second
+
+// simNode is a node of the simulated network
+type simNode struct {
+	kad   *kademlia.Kademlia
+	peers map[kademlia.Address]*simPeer
+}
+
+// simPeer is a connection as seen from the table of one of its ends
+type simPeer struct {
+	sim        *Simulator
+	self, addr kademlia.Address
+	since      time.Duration // simulated time of the connection
+}
+
+func (self *simPeer) Addr() kademlia.Address { return self.addr }
+func (self *simPeer) Url() string            { return "sim://" + self.addr.String() }
+
+// LastActive maps the simulated idle time of the connection to wall clock time
+func (self *simPeer) LastActive() time.Time {
+	return time.Now().Add(self.since - self.sim.clock)
+}
+
+// Drop is called by a table holding its lock, so the disconnection is deferred
+func (self *simPeer) Drop() {
+	self.sim.drops = append(self.sim.drops, self)
+}
+
+// NewDefaultParams returns the default kademlia parameters adjusted for
+// simulations: the kaddb schedules connection retries by wall clock time, so
+// known nodes are retried every round instead.
+func NewDefaultParams() *kademlia.KadParams {
+	params := kademlia.NewDefaultKadParams()
+	params.InitialRetryInterval = 0
+	return params
+}
+
+// New creates an empty network of tables with the given parameters. The seed
+// makes the addresses and the order of events reproducible.
+func New(params *kademlia.KadParams, seed int64) *Simulator {
+	return &Simulator{
+		params:    params,
+		rand:      rand.New(rand.NewSource(seed)),
+		nodes:     make(map[kademlia.Address]*simNode),
+		transport: newTransport(),
+	}
+}
+
+// Addrs returns the addresses of the nodes in the network, sorted
+func (self *Simulator) Addrs() []kademlia.Address {
+	addrs := make([]kademlia.Address, 0, len(self.nodes))
+	for addr := range self.nodes {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return string(addrs[i][:]) < string(addrs[j][:]) })
+	return addrs
+}
+
+// Tables returns the tables of all nodes in the network
+func (self *Simulator) Tables() []*kademlia.Kademlia {
+	tables := make([]*kademlia.Kademlia, 0, len(self.nodes))
+	for _, addr := range self.Addrs() {
+		tables = append(tables, self.nodes[addr].kad)
+	}
+	return tables
+}
+
+// Health checks the healthiness of the network
+func (self *Simulator) Health() *kademlia.Health {
+	return kademlia.CheckHealth(self.Tables())
+}
+
+// AddNode joins a node with a random address, bootstrapping from a random
+// node of the network
+func (self *Simulator) AddNode() kademlia.Address {
+	var addr kademlia.Address
+	self.rand.Read(addr[:])
+
+	addrs := self.Addrs()
+	node := &simNode{
+		kad:   kademlia.New(addr, self.params),
+		peers: make(map[kademlia.Address]*simPeer),
+	}
+	self.nodes[addr] = node
+	if len(addrs) > 0 {
+		boot := addrs[self.rand.Intn(len(addrs))]
+		self.transport.groups[addr] = self.transport.groups[boot]
+		node.kad.Add([]*kademlia.NodeRecord{{Addr: boot, Url: "sim://" + boot.String()}})
+	}
+	return addr
+}
+
+// RemoveNode takes a node off the network, disconnecting all its peers
+func (self *Simulator) RemoveNode(addr kademlia.Address) error {
+	if _, ok := self.nodes[addr]; !ok {
+		return fmt.Errorf("unknown node %v", addr)
+	}
+	for _, peer := range self.peersOf(addr) {
+		self.disconnect(addr, peer)
+	}
+	delete(self.nodes, addr)
+	delete(self.transport.groups, addr)
+	return nil
+}
+
+// Churn replaces n random nodes of the network by new ones and returns the
+// addresses of the nodes that joined
+func (self *Simulator) Churn(n int) []kademlia.Address {
+	addrs := self.Addrs()
+	self.rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	if n > len(addrs) {
+		n = len(addrs)
+	}
+	for _, addr := range addrs[:n] {
+		self.RemoveNode(addr)
+	}
+	joined := make([]kademlia.Address, n)
+	for i := range joined {
+		joined[i] = self.AddNode()
+	}
+	return joined
+}
+
+// Run lets all nodes look up their neighbourhood and connect to the peers
+// their tables suggest for the given number of rounds. It returns the number
+// of connections made.
+func (self *Simulator) Run(rounds int) int {
+	var connected int
+	for round := 0; round < rounds; round++ {
+		self.clock += RoundTime
+		for _, addr := range self.Addrs() {
+			self.lookup(addr)
+			connected += self.fill(addr)
+		}
+	}
+	return connected
+}
+
+// RunUntilHealthy runs rounds until the network is healthy, each group on its
+// own if partitioned, at most maxRounds of them. It returns the number of
+// rounds run and the healthiness violations left, nil if the network
+// converged.
+func (self *Simulator) RunUntilHealthy(maxRounds int) (int, error) {
+	var rounds int
+	for ; rounds < maxRounds; rounds++ {
+		if self.converged() == nil {
+			return rounds, nil
+		}
+		self.Run(1)
+	}
+	return rounds, self.converged()
+}
+
+// converged returns the healthiness violations of the first unhealthy group
+// of the network, nil if all groups are healthy
+func (self *Simulator) converged() error {
+	for _, health := range self.GroupHealth() {
+		if err := health.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup asks the peers of a node for the nodes closest to it and to a random
+// chunk, like the peers responses to self lookups and retrieve requests
+func (self *Simulator) lookup(addr kademlia.Address) {
+	var chunk kademlia.Address
+	self.rand.Read(chunk[:])
+
+	for _, peer := range self.peersOf(addr) {
+		if !self.delivered(addr, peer) {
+			continue
+		}
+		self.learn(addr, peer, addr)
+		self.learn(addr, peer, chunk)
+	}
+}
+
+// peersOf returns the addresses of the peers of a node, sorted
+func (self *Simulator) peersOf(addr kademlia.Address) []kademlia.Address {
+	var peers []kademlia.Address
+	for peer := range self.nodes[addr].peers {
+		peers = append(peers, peer)
+	}
+	sort.Slice(peers, func(i, j int) bool { return string(peers[i][:]) < string(peers[j][:]) })
+	return peers
+}
+
+// fill connects a node to the peers suggested by its table and returns the
+// number of connections made
+func (self *Simulator) fill(addr kademlia.Address) int {
+	var n int
+	// each suggestion is rescheduled, so a full pass over the known
+	// nodes ends the loop even if none of them accepts
+	for i := self.nodes[addr].kad.DBCount(); i >= 0; i-- {
+		record, need, _ := self.nodes[addr].kad.Suggest()
+		if record == nil || !need {
+			break
+		}
+		if self.connect(addr, record.Addr) {
+			n++
+		}
+	}
+	return n
+}
+
+// connect establishes a connection between two nodes if both tables accept it
+func (self *Simulator) connect(a, b kademlia.Address) bool {
+	na, nb := self.nodes[a], self.nodes[b]
+	if na == nil || nb == nil || a == b || na.peers[b] != nil {
+		return false
+	}
+	if !self.delivered(a, b) {
+		return false
+	}
+	pa := &simPeer{sim: self, self: a, addr: b, since: self.clock}
+	pb := &simPeer{sim: self, self: b, addr: a, since: self.clock}
+	if err := na.kad.On(pa, nil); err != nil {
+		self.flushDrops()
+		return false
+	}
+	if err := nb.kad.On(pb, nil); err != nil {
+		na.kad.Off(pa, nil)
+		self.flushDrops()
+		return false
+	}
+	na.peers[b], nb.peers[a] = pa, pb
+	self.flushDrops()
+
+	self.learn(a, b, a)
+	self.learn(b, a, b)
+	return true
+}
+
+// disconnect takes down the connection between two nodes on both ends
+func (self *Simulator) disconnect(a, b kademlia.Address) {
+	for _, pair := range [][2]kademlia.Address{{a, b}, {b, a}} {
+		node := self.nodes[pair[0]]
+		if node == nil {
+			continue
+		}
+		if peer := node.peers[pair[1]]; peer != nil {
+			delete(node.peers, pair[1])
+			node.kad.Off(peer, nil)
+		}
+	}
+}
+
+// flushDrops disconnects the peers replaced in full buckets
+func (self *Simulator) flushDrops() {
+	for len(self.drops) > 0 {
+		peer := self.drops[0]
+		self.drops = self.drops[1:]
+		self.disconnect(peer.self, peer.addr)
+	}
+}
+
+// learn adds the peer and the nodes it knows closest to target to the kaddb
+// of addr
+func (self *Simulator) learn(addr, peer, target kademlia.Address) {
+	var records []*kademlia.NodeRecord
+	for _, node := range self.nodes[peer].kad.FindClosest(target, 0) {
+		if node.Addr() != addr {
+			records = append(records, &kademlia.NodeRecord{Addr: node.Addr(), Url: node.Url()})
+		}
+	}
+	records = append(records, &kademlia.NodeRecord{Addr: peer, Url: "sim://" + peer.String()})
+	self.nodes[addr].kad.Add(records)
+}
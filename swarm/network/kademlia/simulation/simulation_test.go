@@ -0,0 +1,205 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package simulation
+
+import (
+	"testing"
+
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
+)
+
+// checkTables verifies that the tables of the network match its connections
+func checkTables(t *testing.T, sim *Simulator) {
+	for addr, node := range sim.nodes {
+		if count := node.kad.Count(); count != len(node.peers) {
+			t.Errorf("%v: table holds %d peers, connected to %d", addr, count, len(node.peers))
+		}
+		for peer := range node.peers {
+			if sim.nodes[peer] == nil || sim.nodes[peer].peers[addr] == nil {
+				t.Errorf("%v: one sided connection to %v", addr, peer)
+			}
+		}
+	}
+	for _, node := range sim.Health().Nodes {
+		if len(node.Stale) > 0 {
+			t.Errorf("%v: stale peers %v", node.Addr, node.Stale)
+		}
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	sim := New(NewDefaultParams(), 1)
+	for i := 0; i < 32; i++ {
+		sim.AddNode()
+		sim.Run(1)
+	}
+	if connected := sim.Run(60); connected == 0 {
+		t.Error("no connections made")
+	}
+	checkTables(t, sim)
+
+	health := sim.Health()
+	if len(health.Nodes) != 32 {
+		t.Fatalf("have %d nodes, want 32", len(health.Nodes))
+	}
+	if health.Partitions != 1 {
+		t.Errorf("have %d partitions, want 1", health.Partitions)
+	}
+	t.Log(health.Err())
+}
+
+func TestChurn(t *testing.T) {
+	sim := New(NewDefaultParams(), 1)
+	for i := 0; i < 32; i++ {
+		sim.AddNode()
+		sim.Run(1)
+	}
+	sim.Run(60)
+	for round := 0; round < 4; round++ {
+		joined := sim.Churn(4)
+		if len(joined) != 4 {
+			t.Fatalf("round %d: have %d nodes joined, want 4", round, len(joined))
+		}
+		sim.Run(30)
+		checkTables(t, sim)
+		if n := len(sim.Addrs()); n != 32 {
+			t.Fatalf("round %d: have %d nodes, want 32", round, n)
+		}
+		t.Logf("round %d: %v", round, sim.Health().Err())
+	}
+	if err := sim.RemoveNode(kademlia.Address{}); err == nil {
+		t.Error("removed unknown node")
+	}
+}
+
+func TestRunUntilHealthy(t *testing.T) {
+	sim := New(NewDefaultParams(), 1)
+	for i := 0; i < 16; i++ {
+		sim.AddNode()
+		sim.Run(1)
+	}
+	rounds, err := sim.RunUntilHealthy(200)
+	if err != nil {
+		t.Fatalf("not converged after %d rounds: %v", rounds, err)
+	}
+	addrs := sim.Addrs()
+	for _, kad := range sim.Tables() {
+		if !kad.Healthy(addrs) {
+			t.Errorf("%v: table unhealthy: %v", kad.Addr(), kad.Health(addrs))
+		}
+	}
+}
+
+// checkPartition verifies that no connections cross the groups of the network
+func checkPartition(t *testing.T, sim *Simulator) {
+	for addr, node := range sim.nodes {
+		for peer := range node.peers {
+			if sim.transport.groups[peer] != sim.transport.groups[addr] {
+				t.Errorf("%v: connected to %v across the partition", addr, peer)
+			}
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	sim := New(NewDefaultParams(), 1)
+	for i := 0; i < 16; i++ {
+		sim.AddNode()
+		sim.Run(1)
+	}
+	sim.Run(30)
+	groups := sim.Split(2)
+	if len(groups) != 2 || len(groups[0])+len(groups[1]) != 16 {
+		t.Fatalf("have groups %v, want 2 groups of 16 nodes", groups)
+	}
+	checkPartition(t, sim)
+	sim.Run(30)
+	checkPartition(t, sim)
+	checkTables(t, sim)
+	if health := sim.Health(); health.Partitions < 2 {
+		t.Errorf("have %d partitions, want at least 2", health.Partitions)
+	}
+	for i, health := range sim.GroupHealth() {
+		if len(health.Nodes) != len(groups[i]) {
+			t.Errorf("group %d: have %d nodes checked, want %d", i, len(health.Nodes), len(groups[i]))
+		}
+	}
+
+	sim.Heal()
+	if n := len(sim.Groups()); n != 1 {
+		t.Fatalf("have %d groups after heal, want 1", n)
+	}
+	sim.Run(30)
+	checkTables(t, sim)
+	if health := sim.Health(); health.Partitions != 1 {
+		t.Errorf("have %d partitions after heal, want 1", health.Partitions)
+	}
+
+	if err := sim.Partition([]kademlia.Address{{}}); err == nil {
+		t.Error("partitioned unknown node")
+	}
+	addr := sim.Addrs()[0]
+	if err := sim.Partition([]kademlia.Address{addr}, []kademlia.Address{addr}); err == nil {
+		t.Error("node partitioned into several groups")
+	}
+}
+
+func TestLoss(t *testing.T) {
+	sim := New(NewDefaultParams(), 1)
+	sim.SetLoss(1)
+	for i := 0; i < 8; i++ {
+		sim.AddNode()
+	}
+	if connected := sim.Run(10); connected != 0 {
+		t.Errorf("have %d connections made over a dead transport, want 0", connected)
+	}
+	sim.SetLoss(0)
+	if connected := sim.Run(10); connected == 0 {
+		t.Error("no connections made once the transport recovered")
+	}
+	checkTables(t, sim)
+}
+
+func TestPlay(t *testing.T) {
+	sim := New(NewDefaultParams(), 1)
+	err := sim.Play([]Step{
+		{Join: 16, Rounds: 30},
+		{Join: 4, Leave: 4, Rounds: 10},
+		{Partition: 2, Rounds: 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(sim.Addrs()); n != 16 {
+		t.Errorf("have %d nodes, want 16", n)
+	}
+	if n := len(sim.Groups()); n != 2 {
+		t.Errorf("have %d groups, want 2", n)
+	}
+	checkPartition(t, sim)
+
+	if err := sim.Play([]Step{{Heal: true, Rounds: 30}}); err != nil {
+		t.Fatal(err)
+	}
+	if health := sim.Health(); health.Partitions != 1 {
+		t.Errorf("have %d partitions after heal, want 1", health.Partitions)
+	}
+	checkTables(t, sim)
+
+	if err := sim.Play([]Step{{Join: 16, Converge: 1}}); err == nil {
+		t.Error("no error for a step not converged")
+	}
+}
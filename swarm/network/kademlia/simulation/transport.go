@@ -0,0 +1,131 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package simulation
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
+)
+
+// transport is the virtual network the simulated nodes talk over. It splits
+// the nodes into groups unable to reach each other and loses a share of the
+// connection attempts and lookups.
+type transport struct {
+	groups map[kademlia.Address]int // group of each node, all 0 unless partitioned
+	loss   float64                  // probability of an exchange failing
+}
+
+func newTransport() *transport {
+	return &transport{groups: make(map[kademlia.Address]int)}
+}
+
+// delivered reports whether an exchange between two nodes gets through
+func (self *Simulator) delivered(a, b kademlia.Address) bool {
+	if self.transport.groups[a] != self.transport.groups[b] {
+		return false
+	}
+	return self.transport.loss == 0 || self.rand.Float64() >= self.transport.loss
+}
+
+// SetLoss sets the probability of connection attempts and lookups failing
+func (self *Simulator) SetLoss(rate float64) {
+	self.transport.loss = rate
+}
+
+// Partition splits the network into groups of nodes unable to reach each
+// other, nodes not listed join the first group. Connections between the
+// groups are taken down. Nodes joining later are part of the group of the
+// node they bootstrap from.
+func (self *Simulator) Partition(groups ...[]kademlia.Address) error {
+	assigned := make(map[kademlia.Address]int)
+	for i, group := range groups {
+		for _, addr := range group {
+			if _, ok := self.nodes[addr]; !ok {
+				return fmt.Errorf("unknown node %v", addr)
+			}
+			if _, ok := assigned[addr]; ok {
+				return fmt.Errorf("node %v in several groups", addr)
+			}
+			assigned[addr] = i
+		}
+	}
+	for addr := range self.nodes {
+		self.transport.groups[addr] = assigned[addr]
+	}
+	for _, addr := range self.Addrs() {
+		for _, peer := range self.peersOf(addr) {
+			if assigned[addr] != assigned[peer] {
+				self.disconnect(addr, peer)
+			}
+		}
+	}
+	return nil
+}
+
+// Split partitions the network into n random groups of about the same size
+// and returns them
+func (self *Simulator) Split(n int) [][]kademlia.Address {
+	addrs := self.Addrs()
+	self.rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	groups := make([][]kademlia.Address, n)
+	for i, addr := range addrs {
+		groups[i%n] = append(groups[i%n], addr)
+	}
+	self.Partition(groups...)
+	return self.Groups()
+}
+
+// Heal lets all nodes reach each other again
+func (self *Simulator) Heal() {
+	for addr := range self.transport.groups {
+		self.transport.groups[addr] = 0
+	}
+}
+
+// Groups returns the nodes of each group of the network, sorted
+func (self *Simulator) Groups() [][]kademlia.Address {
+	members := make(map[int][]kademlia.Address)
+	for _, addr := range self.Addrs() {
+		g := self.transport.groups[addr]
+		members[g] = append(members[g], addr)
+	}
+	ids := make([]int, 0, len(members))
+	for g := range members {
+		ids = append(ids, g)
+	}
+	sort.Ints(ids)
+	groups := make([][]kademlia.Address, len(ids))
+	for i, g := range ids {
+		groups[i] = members[g]
+	}
+	return groups
+}
+
+// GroupHealth checks the healthiness of each group of the network on its own
+func (self *Simulator) GroupHealth() []*kademlia.Health {
+	var health []*kademlia.Health
+	for _, group := range self.Groups() {
+		tables := make([]*kademlia.Kademlia, len(group))
+		for i, addr := range group {
+			tables[i] = self.nodes[addr].kad
+		}
+		health = append(health, kademlia.CheckHealth(tables))
+	}
+	return health
+}
@@ -0,0 +1,125 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"time"
+)
+
+// PeerInfo describes a node of the table, connected or only known
+type PeerInfo struct {
+	Addr       Address    `json:"addr"`
+	Url        string     `json:"url"`
+	Connected  bool       `json:"connected"`
+	LastActive *time.Time `json:"lastActive,omitempty"` // last traffic of the connection, connected peers only
+	Seen       time.Time  `json:"seen"`                 // last connected or learnt about
+	After      time.Time  `json:"after"`                // next connection attempt not before
+	Retries    int        `json:"retries"`              // connection attempts since last connected
+	Failures   int        `json:"failures"`
+	Backoff    int        `json:"backoff"` // exponent of the retry interval
+	Pinned     bool       `json:"pinned"`  // protected from eviction, see Kademlia.Pin
+	Score      float64    `json:"score"`   // reputation, see NodeRecord.Score
+}
+
+// BinInfo holds the nodes of a single proximity bin of the table
+type BinInfo struct {
+	PO        int        `json:"po"`
+	Connected []PeerInfo `json:"connected"` // peers of the bin
+	Known     []PeerInfo `json:"known"`     // kaddb records of the bin without connection
+}
+
+// TableInfo is a snapshot of the table and its kaddb
+type TableInfo struct {
+	Addr      Address     `json:"addr"`
+	Params    TableParams `json:"params"`
+	Depth     int         `json:"depth"` // proximity order of the nearest neighbourhood bin (proxLimit)
+	ProxSize  int         `json:"proxSize"`
+	Connected int         `json:"connected"`
+	Known     int         `json:"known"`
+	Bins      []BinInfo   `json:"bins"`
+	Suggested *PeerInfo   `json:"suggested"` // node the table would connect to next, nil if none is due
+	NeedPO    *int        `json:"needPO"`    // shallowest bin missing peers, nil if all are full
+}
+
+// peerInfo describes the node of a kaddb record
+func peerInfo(record *NodeRecord) PeerInfo {
+	info := PeerInfo{
+		Addr:      record.Addr,
+		Url:       record.Url,
+		Connected: record.node != nil,
+		Seen:      record.Seen,
+		After:     record.After,
+		Retries:   record.Retries,
+		Failures:  record.Failures,
+		Backoff:   record.Backoff,
+		Pinned:    record.Pinned,
+		Score:     record.Score(),
+	}
+	if record.node != nil {
+		active := record.node.LastActive()
+		info.LastActive = &active
+	}
+	return info
+}
+
+// Table returns a snapshot of the routing table: the connected and known
+// nodes per bin and the node the table suggests connecting to next, without
+// scheduling it as Suggest would
+func (self *Kademlia) Table() *TableInfo {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	suggested, need, po := self.db.peekBest(self.BucketSize, func(i int) int { return len(self.buckets[i]) })
+
+	self.db.lock.RLock()
+	defer self.db.lock.RUnlock()
+
+	table := &TableInfo{
+		Addr:      self.addr,
+		Params:    TableParams{MaxProx: self.MaxProx, ProxBinSize: self.ProxBinSize, BucketSize: self.BucketSize},
+		Depth:     self.proxLimit,
+		ProxSize:  self.proxSize,
+		Connected: self.count,
+		Known:     len(self.db.index),
+		Bins:      make([]BinInfo, len(self.buckets)),
+	}
+	for po, bucket := range self.buckets {
+		bin := BinInfo{PO: po, Connected: []PeerInfo{}, Known: []PeerInfo{}}
+		for _, node := range bucket {
+			if record := self.db.index[node.Addr()]; record != nil {
+				bin.Connected = append(bin.Connected, peerInfo(record))
+			} else {
+				active := node.LastActive()
+				bin.Connected = append(bin.Connected, PeerInfo{Addr: node.Addr(), Url: node.Url(), Connected: true, LastActive: &active})
+			}
+		}
+		for _, record := range self.db.Nodes[po] {
+			if record.node == nil {
+				bin.Known = append(bin.Known, peerInfo(record))
+			}
+		}
+		table.Bins[po] = bin
+	}
+	if suggested != nil {
+		info := peerInfo(suggested)
+		table.Suggested = &info
+	}
+	if need {
+		table.NeedPO = &po
+	}
+	return table
+}
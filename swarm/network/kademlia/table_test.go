@@ -0,0 +1,84 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package kademlia
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+)
+
+func TestTable(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	self := gen(Address{}, r).(Address)
+	params := NewDefaultKadParams()
+	params.MaxProx = 7
+	params.InitialRetryInterval = 0
+	kad := New(self, params)
+
+	connected := make(map[Address]bool)
+	for i := 0; i < 40; i++ {
+		node := &testNode{gen(Address{}, r).(Address)}
+		if kad.On(node, nil) == nil {
+			connected[node.addr] = true
+		}
+	}
+	var records []*NodeRecord
+	for po := 0; po < 8; po++ {
+		records = append(records, &NodeRecord{Addr: RandomAddressAt(self, po), Url: "known"})
+	}
+	kad.Add(records)
+
+	table := kad.Table()
+	if table.Connected != len(connected) || table.Known != kad.DBCount() || len(table.Bins) != 8 {
+		t.Fatalf("have %d connected, %d known in %d bins, want %d, %d in 8", table.Connected, table.Known, len(table.Bins), len(connected), kad.DBCount())
+	}
+	var n, known int
+	for po, bin := range table.Bins {
+		for _, peer := range bin.Connected {
+			if !connected[peer.Addr] || !peer.Connected || peer.LastActive == nil || kad.proximityBin(peer.Addr) != po {
+				t.Errorf("bin %d: unexpected connected peer %+v", po, peer)
+			}
+			n++
+		}
+		for _, peer := range bin.Known {
+			if connected[peer.Addr] || peer.Connected || peer.LastActive != nil || kad.proximityBin(peer.Addr) != po {
+				t.Errorf("bin %d: unexpected known node %+v", po, peer)
+			}
+			known++
+		}
+	}
+	if n != len(connected) || n+known != table.Known {
+		t.Errorf("have %d connected and %d known nodes in bins, want %d of %d", n, known, len(connected), table.Known)
+	}
+	if _, err := json.Marshal(table); err != nil {
+		t.Fatalf("table not encodable: %v", err)
+	}
+
+	// the suggestion is peeked without being scheduled
+	if table.Suggested == nil || table.NeedPO == nil {
+		t.Fatalf("no suggestion with bins missing peers: %+v, %v", table.Suggested, table.NeedPO)
+	}
+	again := kad.Table()
+	if again.Suggested == nil || again.Suggested.Addr != table.Suggested.Addr || again.Suggested.Retries != table.Suggested.Retries {
+		t.Fatalf("suggestion changed from %+v to %+v by peeking", table.Suggested, again.Suggested)
+	}
+	record, need, po := kad.Suggest()
+	if record == nil || record.Addr != table.Suggested.Addr || !need || po != *table.NeedPO {
+		t.Fatalf("have suggestion %v (need %v at %d), table suggested %v at %d", record, need, po, table.Suggested.Addr, *table.NeedPO)
+	}
+}
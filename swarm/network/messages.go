@@ -80,10 +80,11 @@ type storeRequestMsgData struct {
 	Key   storage.Key // hash of datasize | data
 	SData []byte      // the actual chunk Data
 	// optional
-	Id             uint64     // request ID. if delivery, the ID is retrieve request ID
-	requestTimeout *time.Time // expiry for forwarding - [not serialised][not currently used]
-	storageTimeout *time.Time // expiry of content - [not serialised][not currently used]
-	from           *peer      // [not serialised] protocol registers the requester
+	Id             uint64           // request ID. if delivery, the ID is retrieve request ID
+	Receipt        *DeliveryReceipt `rlp:"optional"` // signed by the serving node if the chunk was retrieved
+	requestTimeout *time.Time       // expiry for forwarding - [not serialised][not currently used]
+	storageTimeout *time.Time       // expiry of content - [not serialised][not currently used]
+	from           *peer            // [not serialised] protocol registers the requester
 }
 
 func (self storeRequestMsgData) String() string {
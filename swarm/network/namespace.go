@@ -0,0 +1,81 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
+)
+
+// virtualAddrBits is the number of leading overlay address bits which may
+// differ between the identities of a node, enough for MaxVirtualNodes+1.
+const virtualAddrBits = 4
+
+/*
+Overlay addresses are derived from the node key, so nodes of several networks
+sharing infrastructure (or a node key reused across them) end up with
+colliding addresses and gossip each other into their kademlia tables. In
+namespaced mode the network id is mixed into the address as
+
+  keccak256(networkid || pubkey)
+
+with the id encoded as 8 big endian bytes, and the address advertised by a
+peer (or gossiped about another node) is only accepted if it is derived from
+the node id that way.
+*/
+
+// OverlayAddr derives the overlay address of a node from its uncompressed
+// public key, mixing in the network id in namespaced mode.
+func OverlayAddr(pubkey []byte, networkId uint64, namespaced bool) kademlia.Address {
+	if !namespaced {
+		return kademlia.Address(crypto.Keccak256Hash(pubkey))
+	}
+	prefix := make([]byte, 8)
+	binary.BigEndian.PutUint64(prefix, networkId)
+	return kademlia.Address(crypto.Keccak256Hash(prefix, pubkey))
+}
+
+// SetNamespace switches the identities to namespaced overlay addresses of the
+// network: peers are assigned by and validated against the address derived
+// with the network id mixed in.
+func (self Hives) SetNamespace(networkId uint64) {
+	for _, hive := range self {
+		hive.namespaced = true
+		hive.networkId = networkId
+	}
+}
+
+// overlayAddr derives the overlay address of a node from its 64 byte node id
+func (self *Hive) overlayAddr(id []byte) kademlia.Address {
+	return OverlayAddr(append([]byte{0x04}, id...), self.networkId, self.namespaced)
+}
+
+// validAddr reports whether the overlay address of a node is derived from its
+// node id in the namespace of the hive, always true if neither namespaced nor
+// requiring node id puzzles. The leading bits selecting a virtual identity of
+// the node are not checked.
+func (self *Hive) validAddr(addr *peerAddr) bool {
+	if !self.namespaced && self.kad.IDDifficulty <= 0 {
+		return true
+	}
+	want := self.overlayAddr(addr.ID)
+	mask := byte(0xff >> virtualAddrBits)
+	return addr.Addr[0]&mask == want[0]&mask && bytes.Equal(addr.Addr[1:], want[1:])
+}
@@ -0,0 +1,115 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/p2p/discover"
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
+)
+
+func TestOverlayAddr(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	pubkey := crypto.FromECDSAPub(&key.PublicKey)
+
+	plain := OverlayAddr(pubkey, 3, false)
+	if plain != kademlia.Address(crypto.Keccak256Hash(pubkey)) {
+		t.Fatalf("plain address %v not the hash of the public key", plain)
+	}
+	if other := OverlayAddr(pubkey, 4, false); other != plain {
+		t.Errorf("plain address depends on the network id: %v != %v", other, plain)
+	}
+	namespaced := OverlayAddr(pubkey, 3, true)
+	if namespaced == plain {
+		t.Error("namespaced address equals the plain one")
+	}
+	if other := OverlayAddr(pubkey, 4, true); other == namespaced {
+		t.Error("namespaced addresses of different networks are equal")
+	}
+	if again := OverlayAddr(pubkey, 3, true); again != namespaced {
+		t.Errorf("namespaced address not deterministic: %v != %v", again, namespaced)
+	}
+}
+
+func TestHiveValidAddr(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	id := discover.PubkeyID(&key.PublicKey)
+	pubkey := crypto.FromECDSAPub(&key.PublicKey)
+
+	hives, err := NewHives(common.Hash(kademlia.RandomAddress()), 1, NewDefaultHiveParams(), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerAt := func(addr kademlia.Address) *peerAddr {
+		return &peerAddr{IP: net.IPv4(127, 0, 0, 1), Port: 30399, ID: id[:], Addr: addr}
+	}
+	// Anything goes without a namespace
+	if !hives[0].validAddr(peerAt(kademlia.RandomAddress())) {
+		t.Fatal("address rejected without namespace")
+	}
+	hives.SetNamespace(3)
+	addr := OverlayAddr(pubkey, 3, true)
+	for _, hive := range hives {
+		if !hive.validAddr(peerAt(addr)) {
+			t.Error("namespaced address rejected")
+		}
+		// a virtual identity of the node only differs in the leading bits
+		virtual := VirtualAddrs(addr, MaxVirtualNodes+1)[MaxVirtualNodes]
+		if !hive.validAddr(peerAt(virtual)) {
+			t.Error("virtual identity address rejected")
+		}
+		for _, invalid := range []kademlia.Address{
+			OverlayAddr(pubkey, 3, false),
+			OverlayAddr(pubkey, 4, true),
+		} {
+			if hive.validAddr(peerAt(invalid)) {
+				t.Errorf("address %v outside the namespace accepted", invalid)
+			}
+		}
+	}
+	// Peers gossiped from other networks are not added to the table
+	from := &peer{bzz: &bzz{remoteAddr: peerAt(addr)}}
+	other, _ := crypto.GenerateKey()
+	otherId := discover.PubkeyID(&other.PublicKey)
+	hives[0].HandlePeersMsg(&peersMsgData{Peers: []*peerAddr{
+		{IP: net.IPv4(127, 0, 0, 2), Port: 30399, ID: otherId[:], Addr: OverlayAddr(crypto.FromECDSAPub(&other.PublicKey), 4, true)},
+		{IP: net.IPv4(127, 0, 0, 3), Port: 30399, ID: id[:], Addr: addr},
+	}}, from)
+	if count := hives[0].kad.DBCount(); count != 1 {
+		t.Errorf("have %d node records, want 1", count)
+	}
+}
+
+func TestHivesAssignNamespaced(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	id := discover.PubkeyID(&key.PublicKey)
+	addr := OverlayAddr(crypto.FromECDSAPub(&key.PublicKey), 3, true)
+
+	// virtual identities partitioning the space around the peer's namespaced address
+	hives, err := NewHives(common.Hash(addr), 3, NewDefaultHiveParams(), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hives.SetNamespace(3)
+	if hive := hives.assign(id); hive != hives[0] {
+		t.Errorf("peer assigned to %v, want %v", hive.Addr(), hives[0].Addr())
+	}
+}
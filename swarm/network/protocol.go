@@ -35,12 +35,14 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/matrix/go-matrix/contracts/chequebook"
 	"github.com/matrix/go-matrix/log"
 	"github.com/matrix/go-matrix/metrics"
 	"github.com/matrix/go-matrix/p2p"
+	"github.com/matrix/go-matrix/p2p/discover"
 	bzzswap "github.com/matrix/go-matrix/swarm/services/swap"
 	"github.com/matrix/go-matrix/swarm/services/swap/swap"
 	"github.com/matrix/go-matrix/swarm/storage"
@@ -64,6 +66,8 @@ const (
 	ProtocolLength     = uint64(8)
 	ProtocolMaxMsgSize = 10 * 1024 * 1024
 	NetworkId          = 3
+
+	maxPendingRetrievals = 4096 // chunks tracked per peer for delivery receipts
 )
 
 // bzz represents the swarm wire protocol
@@ -87,6 +91,11 @@ type bzz struct {
 	syncer      *syncer             // syncer instance for the peer connection
 	syncParams  *SyncParams         // syncer params
 	syncState   *syncState          // outgoing syncronisation state (contains reference to remote peers db counter)
+
+	receipts     *DeliveryReceipts // delivery receipt signer and store
+	signReceipts bool              // receipts are only signed for the node's own address
+	retrievals   map[string]bool   // keys of chunks the peer retrieves, awaiting delivery
+	retrLock     sync.Mutex
 }
 
 // interface type for handler of storage/retrieval related requests coming
@@ -110,7 +119,7 @@ on each peer connection
 The Run function of the Bzz protocol class creates a bzz instance
 which will represent the peer for the swarm hive and all peer-aware components
 */
-func Bzz(cloud StorageHandler, backend chequebook.Backend, hive *Hive, dbaccess *DbAccess, sp *bzzswap.SwapParams, sy *SyncParams, networkId uint64) (p2p.Protocol, error) {
+func Bzz(cloud StorageHandler, backend chequebook.Backend, hives Hives, dbaccess *DbAccess, sp *bzzswap.SwapParams, sy *SyncParams, networkId uint64, receipts *DeliveryReceipts) (p2p.Protocol, error) {
 
 	// a single global request db is created for all peer connections
 	// this is to persist delivery backlog and aid syncronisation
@@ -126,7 +135,8 @@ func Bzz(cloud StorageHandler, backend chequebook.Backend, hive *Hive, dbaccess
 		Version: Version,
 		Length:  ProtocolLength,
 		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
-			return run(requestDb, cloud, backend, hive, dbaccess, sp, sy, networkId, p, rw)
+			hive := hives.assign(p.ID())
+			return run(requestDb, cloud, backend, hive, dbaccess, sp, sy, networkId, receipts, hive == hives[0], p, rw)
 		},
 	}, nil
 }
@@ -143,7 +153,7 @@ the main protocol loop that
  * whenever the loop terminates, the peer will disconnect with Subprotocol error
  * whenever handlers return an error the loop terminates
 */
-func run(requestDb *storage.LDBDatabase, depo StorageHandler, backend chequebook.Backend, hive *Hive, dbaccess *DbAccess, sp *bzzswap.SwapParams, sy *SyncParams, networkId uint64, p *p2p.Peer, rw p2p.MsgReadWriter) (err error) {
+func run(requestDb *storage.LDBDatabase, depo StorageHandler, backend chequebook.Backend, hive *Hive, dbaccess *DbAccess, sp *bzzswap.SwapParams, sy *SyncParams, networkId uint64, receipts *DeliveryReceipts, signReceipts bool, p *p2p.Peer, rw p2p.MsgReadWriter) (err error) {
 
 	self := &bzz{
 		storage:     depo,
@@ -158,6 +168,9 @@ func run(requestDb *storage.LDBDatabase, depo StorageHandler, backend chequebook
 		swapEnabled: hive.swapEnabled,
 		syncEnabled: true,
 		NetworkId:   networkId,
+		receipts:     receipts,
+		signReceipts: signReceipts,
+		retrievals:   make(map[string]bool),
 	}
 
 	// handle handshake
@@ -198,12 +211,18 @@ func (self *bzz) Drop() {
 }
 
 // one cycle of the main forever loop that handles and dispatches incoming messages
-func (self *bzz) handle() error {
+func (self *bzz) handle() (err error) {
 	msg, err := self.rw.ReadMsg()
 	log.Debug(fmt.Sprintf("<- %v", msg))
 	if err != nil {
 		return err
 	}
+	// errors past reading the message are the peer breaking the protocol
+	defer func() {
+		if err != nil {
+			self.hive.recordProtocolError(&peer{bzz: self})
+		}
+	}()
 	if msg.Size > ProtocolMaxMsgSize {
 		return fmt.Errorf("message too long: %v > %v", msg.Size, ProtocolMaxMsgSize)
 	}
@@ -231,6 +250,11 @@ func (self *bzz) handle() error {
 		// last Active time is set only when receiving chunks
 		self.lastActive = time.Now()
 		log.Trace(fmt.Sprintf("incoming store request: %s", req.String()))
+		if req.Receipt != nil && self.receipts != nil {
+			if err := self.receipts.record(req.Receipt, req.Key, self.remoteAddr.Addr, self.hive); err != nil {
+				log.Debug(fmt.Sprintf("delivery receipt from %v rejected: %v", self, err))
+			}
+		}
 		// swap accounting is done within forwarding
 		self.storage.HandleStoreRequestMsg(&req, &peer{bzz: self})
 
@@ -374,6 +398,15 @@ func (self *bzz) handleStatus() (err error) {
 	}
 
 	self.remoteAddr = self.peerAddr(status.Addr)
+	if !self.hive.validAddr(self.remoteAddr) {
+		return fmt.Errorf("overlay address %v not derived from node key in network %d", self.remoteAddr.Addr, self.NetworkId)
+	}
+	if !self.hive.validID(self.remoteAddr) {
+		return fmt.Errorf("node id of %v does not solve the id puzzle of difficulty %d", self.remoteAddr.Addr, self.hive.kad.IDDifficulty)
+	}
+	// the connection authenticated the node id, unlike the one advertised
+	id := self.peer.ID()
+	self.hive.registry.Register(id, self.remoteAddr.Addr, discover.NewNode(id, self.remoteAddr.IP, 0, self.remoteAddr.Port).String())
 	log.Trace(fmt.Sprintf("self: advertised IP: %v, peer advertised: %v, local address: %v\npeer: advertised IP: %v, remote address: %v\n", self.selfAddr(), self.remoteAddr, self.peer.LocalAddr(), status.Addr.IP, self.peer.RemoteAddr()))
 
 	if self.swapEnabled {
@@ -472,10 +505,40 @@ func (self *bzz) retrieve(req *retrieveRequestMsgData) error {
 }
 
 // send storeRequestMsg
+// chunks retrieved by the peer carry a delivery receipt if enabled
 func (self *bzz) store(req *storeRequestMsgData) error {
+	if self.receipts != nil && self.signReceipts && self.delivered(req.Key) {
+		if receipt := self.receipts.sign(req.Key, self.remoteAddr.Addr); receipt != nil {
+			signed := *req
+			signed.Receipt = receipt
+			req = &signed
+		}
+	}
 	return self.send(storeRequestMsg, req)
 }
 
+// expectRetrieval marks a chunk queued for delivery as retrieved by the peer
+func (self *bzz) expectRetrieval(key storage.Key) {
+	self.retrLock.Lock()
+	defer self.retrLock.Unlock()
+
+	if len(self.retrievals) < maxPendingRetrievals {
+		self.retrievals[string(key)] = true
+	}
+}
+
+// delivered reports whether a chunk was retrieved by the peer and clears it
+func (self *bzz) delivered(key storage.Key) bool {
+	self.retrLock.Lock()
+	defer self.retrLock.Unlock()
+
+	if !self.retrievals[string(key)] {
+		return false
+	}
+	delete(self.retrievals, string(key))
+	return true
+}
+
 func (self *bzz) syncRequest() error {
 	req := &syncRequestMsgData{}
 	if self.hive.syncEnabled {
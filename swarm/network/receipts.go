@@ -0,0 +1,218 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package network
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/metrics"
+	"github.com/matrix/go-matrix/rlp"
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
+	"github.com/matrix/go-matrix/swarm/storage"
+)
+
+//metrics variables
+var (
+	receiptSignedCount   = metrics.NewRegisteredCounter("network.receipts.signed.count", nil)
+	receiptReceivedCount = metrics.NewRegisteredCounter("network.receipts.received.count", nil)
+	receiptInvalidCount  = metrics.NewRegisteredCounter("network.receipts.invalid.count", nil)
+)
+
+// DefaultReceiptsLimit is the number of received delivery receipts kept around.
+const DefaultReceiptsLimit = 10000
+
+/*
+DeliveryReceipt is attached by a node serving a chunk in response to a retrieve
+request. It is signed with the bzz key of the serving node, so the recipient
+can tell which node actually did the retrieval work.
+*/
+type DeliveryReceipt struct {
+	Chunk     common.Hash   `json:"chunk"`     // hash of the delivered chunk
+	Peer      common.Hash   `json:"peer"`      // overlay address of the node the chunk was delivered to
+	Time      uint64        `json:"time"`      // unix time of the delivery
+	Signature hexutil.Bytes `json:"signature"` // signature of the serving node over the above
+}
+
+func (self *DeliveryReceipt) sigHash() []byte {
+	blob, _ := rlp.EncodeToBytes([]interface{}{self.Chunk, self.Peer, self.Time})
+	return crypto.Keccak256(blob)
+}
+
+// Server recovers the overlay address of the node that signed the receipt,
+// derived in the namespace of networkId if namespaced
+func (self *DeliveryReceipt) Server(networkId uint64, namespaced bool) (common.Hash, error) {
+	pubkey, err := crypto.SigToPub(self.sigHash(), self.Signature)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.Hash(OverlayAddr(crypto.FromECDSAPub(pubkey), networkId, namespaced)), nil
+}
+
+// ReceiptRecord is a delivery receipt received from a peer
+type ReceiptRecord struct {
+	*DeliveryReceipt
+	Server common.Hash `json:"server"` // overlay address of the serving node
+}
+
+// ReceiptStats aggregates the receipts received from a single serving node
+type ReceiptStats struct {
+	Server     common.Hash `json:"server"`
+	Deliveries uint64      `json:"deliveries"`
+	First      uint64      `json:"first"` // unix time of the first receipt
+	Last       uint64      `json:"last"`  // unix time of the latest receipt
+}
+
+// ReceiptSummary reports the retrieval work done by and for the local node
+type ReceiptSummary struct {
+	Signed   uint64          `json:"signed"`   // receipts handed out for chunks served
+	Received uint64          `json:"received"` // valid receipts received for chunks retrieved
+	Invalid  uint64          `json:"invalid"`  // receipts rejected
+	Servers  []*ReceiptStats `json:"servers"`  // per serving node, most deliveries first
+}
+
+/*
+DeliveryReceipts signs receipts for the chunks the local node serves (if
+enabled) and accumulates the receipts received from peers. Its exported
+methods are available as bzz debug APIs.
+*/
+type DeliveryReceipts struct {
+	prvKey *ecdsa.PrivateKey // key signing receipts, nil if receipts are not handed out
+	limit  int               // maximum number of receipt records kept
+
+	lock    sync.RWMutex
+	records []*ReceiptRecord // latest receipts received, oldest first
+	stats   map[common.Hash]*ReceiptStats
+	summary ReceiptSummary
+}
+
+// NewDeliveryReceipts creates a receipt store keeping up to limit receipts.
+// Receipts for chunks served are only signed if prvKey is not nil.
+func NewDeliveryReceipts(prvKey *ecdsa.PrivateKey, limit int) *DeliveryReceipts {
+	if limit <= 0 {
+		limit = DefaultReceiptsLimit
+	}
+	return &DeliveryReceipts{
+		prvKey: prvKey,
+		limit:  limit,
+		stats:  make(map[common.Hash]*ReceiptStats),
+	}
+}
+
+// sign creates a receipt for a chunk delivered to peer, nil if disabled
+func (self *DeliveryReceipts) sign(key storage.Key, peer kademlia.Address) *DeliveryReceipt {
+	if self.prvKey == nil {
+		return nil
+	}
+	receipt := &DeliveryReceipt{
+		Chunk: common.BytesToHash(key),
+		Peer:  common.Hash(peer),
+		Time:  uint64(time.Now().Unix()),
+	}
+	sig, err := crypto.Sign(receipt.sigHash(), self.prvKey)
+	if err != nil {
+		return nil
+	}
+	receipt.Signature = sig
+	receiptSignedCount.Inc(1)
+
+	self.lock.Lock()
+	self.summary.Signed++
+	self.lock.Unlock()
+	return receipt
+}
+
+// record validates a receipt attached to the delivery of chunk key by the peer
+// at overlay address from to the local node of hive and stores it
+func (self *DeliveryReceipts) record(receipt *DeliveryReceipt, key storage.Key, from kademlia.Address, hive *Hive) error {
+	err := self.validate(receipt, key, from, hive)
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if err != nil {
+		receiptInvalidCount.Inc(1)
+		self.summary.Invalid++
+		return err
+	}
+	receiptReceivedCount.Inc(1)
+	self.summary.Received++
+
+	server := common.Hash(from)
+	self.records = append(self.records, &ReceiptRecord{DeliveryReceipt: receipt, Server: server})
+	if len(self.records) > self.limit {
+		self.records = self.records[len(self.records)-self.limit:]
+	}
+	stats, ok := self.stats[server]
+	if !ok {
+		stats = &ReceiptStats{Server: server, First: receipt.Time}
+		self.stats[server] = stats
+	}
+	stats.Deliveries++
+	stats.Last = receipt.Time
+	return nil
+}
+
+// validate checks that a receipt covers chunk key, was issued to the local node
+// of hive and is signed by the peer at overlay address from, the address of
+// the signer being derived in the namespace of the hive
+func (self *DeliveryReceipts) validate(receipt *DeliveryReceipt, key storage.Key, from kademlia.Address, hive *Hive) error {
+	if receipt.Chunk != common.BytesToHash(key) {
+		return fmt.Errorf("receipt for chunk %x attached to %v", receipt.Chunk[:4], key.Log())
+	}
+	if receipt.Peer != common.Hash(hive.addr) {
+		return fmt.Errorf("receipt issued to %x", receipt.Peer[:4])
+	}
+	server, err := receipt.Server(hive.networkId, hive.namespaced)
+	if err != nil {
+		return fmt.Errorf("invalid receipt signature: %v", err)
+	}
+	if server != common.Hash(from) {
+		return fmt.Errorf("receipt signed by %x, not the serving peer %x", server[:4], from[:4])
+	}
+	return nil
+}
+
+// Receipts returns the latest receipts received for retrieved chunks
+func (self *DeliveryReceipts) Receipts() []*ReceiptRecord {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	return append([]*ReceiptRecord(nil), self.records...)
+}
+
+// ReceiptSummary returns the receipt counters and the deliveries per serving node
+func (self *DeliveryReceipts) ReceiptSummary() *ReceiptSummary {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	summary := self.summary
+	summary.Servers = make([]*ReceiptStats, 0, len(self.stats))
+	for _, stats := range self.stats {
+		entry := *stats
+		summary.Servers = append(summary.Servers, &entry)
+	}
+	sort.Slice(summary.Servers, func(i, j int) bool {
+		return summary.Servers[i].Deliveries > summary.Servers[j].Deliveries
+	})
+	return &summary
+}
@@ -0,0 +1,134 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package network
+
+import (
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/rlp"
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
+	"github.com/matrix/go-matrix/swarm/storage"
+)
+
+func TestDeliveryReceipts(t *testing.T) {
+	serverKey, _ := crypto.GenerateKey()
+	server := kademlia.Address(crypto.Keccak256Hash(crypto.FromECDSAPub(&serverKey.PublicKey)))
+	client := kademlia.Address(common.HexToHash("0x01"))
+	key := storage.Key(crypto.Keccak256([]byte("chunk")))
+
+	signer := NewDeliveryReceipts(serverKey, 0)
+	receipts := NewDeliveryReceipts(nil, 2)
+
+	// receipts are only signed if enabled
+	if receipt := receipts.sign(key, server); receipt != nil {
+		t.Fatal("receipt signed without key")
+	}
+	receipt := signer.sign(key, client)
+	if receipt == nil {
+		t.Fatal("no receipt signed")
+	}
+	if addr, err := receipt.Server(0, false); err != nil || addr != common.Hash(server) {
+		t.Fatalf("server mismatch: have %x (%v), want %x", addr, err, server)
+	}
+	// receipts for other chunks, peers or from other servers are rejected
+	hive := &Hive{addr: client}
+	other := storage.Key(crypto.Keccak256([]byte("other")))
+	if err := receipts.record(receipt, other, server, hive); err == nil {
+		t.Error("receipt accepted for other chunk")
+	}
+	if err := receipts.record(receipt, key, server, &Hive{addr: server}); err == nil {
+		t.Error("receipt accepted for other peer")
+	}
+	if err := receipts.record(receipt, key, client, hive); err == nil {
+		t.Error("receipt accepted from other server")
+	}
+	// valid receipts are accumulated up to the limit
+	for i := 0; i < 3; i++ {
+		if err := receipts.record(signer.sign(key, client), key, server, hive); err != nil {
+			t.Fatalf("receipt %d rejected: %v", i, err)
+		}
+	}
+	if n := len(receipts.Receipts()); n != 2 {
+		t.Errorf("receipt records mismatch: have %d, want 2", n)
+	}
+	summary := receipts.ReceiptSummary()
+	if summary.Received != 3 || summary.Invalid != 3 {
+		t.Errorf("summary mismatch: have %d received, %d invalid, want 3, 3", summary.Received, summary.Invalid)
+	}
+	if len(summary.Servers) != 1 || summary.Servers[0].Server != common.Hash(server) || summary.Servers[0].Deliveries != 3 {
+		t.Errorf("server stats mismatch: %+v", summary.Servers)
+	}
+	if signed := signer.ReceiptSummary().Signed; signed != 4 {
+		t.Errorf("signed receipts mismatch: have %d, want 4", signed)
+	}
+}
+
+// Tests that receipts from a namespaced peer are validated against the overlay
+// address derived in the namespace of the local hive.
+func TestDeliveryReceiptsNamespaced(t *testing.T) {
+	serverKey, _ := crypto.GenerateKey()
+	pubkey := crypto.FromECDSAPub(&serverKey.PublicKey)
+	server := OverlayAddr(pubkey, 3, true)
+	client := kademlia.Address(common.HexToHash("0x01"))
+	key := storage.Key(crypto.Keccak256([]byte("chunk")))
+
+	receipt := NewDeliveryReceipts(serverKey, 0).sign(key, client)
+	if addr, err := receipt.Server(3, true); err != nil || addr != common.Hash(server) {
+		t.Fatalf("server mismatch: have %x (%v), want %x", addr, err, server)
+	}
+	receipts := NewDeliveryReceipts(nil, 0)
+	if err := receipts.record(receipt, key, server, &Hive{addr: client, networkId: 3, namespaced: true}); err != nil {
+		t.Errorf("namespaced receipt rejected: %v", err)
+	}
+	if err := receipts.record(receipt, key, server, &Hive{addr: client, networkId: 4, namespaced: true}); err == nil {
+		t.Error("receipt accepted from other namespace")
+	}
+	if err := receipts.record(receipt, key, OverlayAddr(pubkey, 0, false), &Hive{addr: client, networkId: 3, namespaced: true}); err == nil {
+		t.Error("receipt accepted from non-namespaced address")
+	}
+}
+
+func TestStoreRequestReceiptEncoding(t *testing.T) {
+	serverKey, _ := crypto.GenerateKey()
+	key := storage.Key(crypto.Keccak256([]byte("chunk")))
+	data := []byte{9, 0, 0, 0, 0, 0, 0, 0, 1}
+
+	// store requests without receipt encode as before
+	plain := &storeRequestMsgData{Key: key, SData: data, Id: 1}
+	blob, err := rlp.EncodeToBytes(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, _ := rlp.EncodeToBytes([]interface{}{key, data, uint64(1)})
+	if string(blob) != string(legacy) {
+		t.Errorf("encoding mismatch: have %x, want %x", blob, legacy)
+	}
+	// receipts survive the round trip
+	signed := &storeRequestMsgData{Key: key, SData: data, Id: 1}
+	signed.Receipt = NewDeliveryReceipts(serverKey, 0).sign(key, kademlia.Address{})
+	if blob, err = rlp.EncodeToBytes(signed); err != nil {
+		t.Fatal(err)
+	}
+	var decoded storeRequestMsgData
+	if err := rlp.DecodeBytes(blob, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Receipt == nil || decoded.Receipt.Chunk != signed.Receipt.Chunk || string(decoded.Receipt.Signature) != string(signed.Receipt.Signature) {
+		t.Errorf("receipt mismatch: have %+v, want %+v", decoded.Receipt, signed.Receipt)
+	}
+}
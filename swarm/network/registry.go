@@ -0,0 +1,262 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/event"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/p2p/discover"
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
+)
+
+// addrRegistryVersion is the version of the on-disk registry format
+const addrRegistryVersion = 1
+
+// AddrEventType names a kind of change of the address registry
+type AddrEventType string
+
+const (
+	AddrAdded   AddrEventType = "addrAdded"   // an overlay address was registered
+	AddrUpdated AddrEventType = "addrUpdated" // the node id or underlay of an overlay address changed
+	AddrRemoved AddrEventType = "addrRemoved" // an overlay address was removed
+)
+
+// AddrRecord maps the overlay address of a node to its node id and the enode
+// URL it was last connected at.
+type AddrRecord struct {
+	ID   discover.NodeID  `json:"id"`
+	Addr kademlia.Address `json:"addr"`
+	Url  string           `json:"url"`
+	Seen time.Time        `json:"seen"`
+}
+
+// AddrEvent is a change of the address registry sent to its subscribers
+type AddrEvent struct {
+	Type AddrEventType `json:"type"`
+	AddrRecord
+}
+
+/*
+AddrRegistry translates between the node ids of the p2p layer and the overlay
+addresses of the kademlia tables, in both directions. A node running virtual
+identities has several overlay addresses under the same node id, while an
+overlay address belongs to a single node.
+
+The registry is filled from the handshakes of connected peers, where the
+overlay address was validated against the node id, so the underlay dial
+information of any overlay address seen can be recovered, also after a
+restart as the registry is persisted next to the kaddb.
+*/
+type AddrRegistry struct {
+	path   string
+	byAddr map[kademlia.Address]*AddrRecord
+	byID   map[discover.NodeID][]kademlia.Address
+	feed   event.Feed
+	lock   sync.RWMutex
+}
+
+type addrRegistrySnapshot struct {
+	Version int
+	Saved   time.Time
+	Records []*AddrRecord
+}
+
+// NewAddrRegistry creates an empty registry persisted to path, if not empty
+func NewAddrRegistry(path string) *AddrRegistry {
+	return &AddrRegistry{
+		path:   path,
+		byAddr: make(map[kademlia.Address]*AddrRecord),
+		byID:   make(map[discover.NodeID][]kademlia.Address),
+	}
+}
+
+// Register maps the overlay address to the node id and the enode URL the node
+// is connected at, replacing any previous mapping of the address
+func (self *AddrRegistry) Register(id discover.NodeID, addr kademlia.Address, url string) {
+	self.lock.Lock()
+	ev := self.register(&AddrRecord{ID: id, Addr: addr, Url: url, Seen: time.Now()})
+	self.lock.Unlock()
+
+	if ev != nil {
+		self.feed.Send(*ev)
+	}
+}
+
+// register adds a record, returning the event of the change, nil if none
+// caller holds the lock
+func (self *AddrRegistry) register(record *AddrRecord) *AddrEvent {
+	typ := AddrAdded
+	if old := self.byAddr[record.Addr]; old != nil {
+		if old.ID == record.ID && old.Url == record.Url {
+			old.Seen = record.Seen
+			return nil
+		}
+		typ = AddrUpdated
+		self.unlinkID(old.ID, old.Addr)
+	}
+	self.byAddr[record.Addr] = record
+	self.byID[record.ID] = append(self.byID[record.ID], record.Addr)
+	return &AddrEvent{Type: typ, AddrRecord: *record}
+}
+
+// Remove forgets the overlay address, reporting whether it was known
+func (self *AddrRegistry) Remove(addr kademlia.Address) bool {
+	self.lock.Lock()
+	record := self.byAddr[addr]
+	if record != nil {
+		delete(self.byAddr, addr)
+		self.unlinkID(record.ID, addr)
+	}
+	self.lock.Unlock()
+
+	if record == nil {
+		return false
+	}
+	self.feed.Send(AddrEvent{Type: AddrRemoved, AddrRecord: *record})
+	return true
+}
+
+// unlinkID removes an overlay address from the ones of a node id
+// caller holds the lock
+func (self *AddrRegistry) unlinkID(id discover.NodeID, addr kademlia.Address) {
+	addrs := self.byID[id]
+	for i, a := range addrs {
+		if a == addr {
+			addrs = append(addrs[:i:i], addrs[i+1:]...)
+			break
+		}
+	}
+	if len(addrs) == 0 {
+		delete(self.byID, id)
+	} else {
+		self.byID[id] = addrs
+	}
+}
+
+// Lookup returns the record of an overlay address
+func (self *AddrRegistry) Lookup(addr kademlia.Address) (AddrRecord, bool) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	record := self.byAddr[addr]
+	if record == nil {
+		return AddrRecord{}, false
+	}
+	return *record, true
+}
+
+// ID returns the node id of an overlay address
+func (self *AddrRegistry) ID(addr kademlia.Address) (discover.NodeID, bool) {
+	record, ok := self.Lookup(addr)
+	return record.ID, ok
+}
+
+// Url returns the enode URL an overlay address was last connected at, empty if unknown
+func (self *AddrRegistry) Url(addr kademlia.Address) string {
+	record, _ := self.Lookup(addr)
+	return record.Url
+}
+
+// Addrs returns the overlay addresses of a node id
+func (self *AddrRegistry) Addrs(id discover.NodeID) []kademlia.Address {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	return append([]kademlia.Address(nil), self.byID[id]...)
+}
+
+// Records returns all records sorted by overlay address
+func (self *AddrRegistry) Records() []AddrRecord {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	records := make([]AddrRecord, 0, len(self.byAddr))
+	for _, record := range self.byAddr {
+		records = append(records, *record)
+	}
+	sort.Slice(records, func(i, j int) bool { return string(records[i].Addr[:]) < string(records[j].Addr[:]) })
+	return records
+}
+
+// SubscribeEvents registers a channel notified of the changes of the registry
+func (self *AddrRegistry) SubscribeEvents(ch chan<- AddrEvent) event.Subscription {
+	return self.feed.Subscribe(ch)
+}
+
+// Save writes the registry to its path, replacing the file atomically
+func (self *AddrRegistry) Save() error {
+	if self.path == "" {
+		return nil
+	}
+	self.lock.RLock()
+	snapshot := &addrRegistrySnapshot{Version: addrRegistryVersion, Saved: time.Now()}
+	for _, record := range self.byAddr {
+		snapshot.Records = append(snapshot.Records, record)
+	}
+	data, err := json.MarshalIndent(snapshot, "", " ")
+	self.lock.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := self.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0600); err == nil {
+		err = os.Rename(tmp, self.path)
+	}
+	if err != nil {
+		log.Warn(fmt.Sprintf("unable to save address registry with %v records to %v: %v", len(snapshot.Records), self.path, err))
+	}
+	return err
+}
+
+// Load adds the records saved at its path to the registry. Records of
+// addresses registered since are kept, no events are sent.
+func (self *AddrRegistry) Load() error {
+	if self.path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(self.path)
+	if err != nil {
+		return err
+	}
+	var snapshot addrRegistrySnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("corrupt address registry: %v", err)
+	}
+	if snapshot.Version > addrRegistryVersion {
+		return fmt.Errorf("unsupported address registry version %d (want <= %d)", snapshot.Version, addrRegistryVersion)
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, record := range snapshot.Records {
+		if record == nil || self.byAddr[record.Addr] != nil {
+			continue
+		}
+		self.register(record)
+	}
+	log.Debug(fmt.Sprintf("loaded address registry with %v records from %v", len(snapshot.Records), self.path))
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package network
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/matrix/go-matrix/p2p/discover"
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
+)
+
+func TestAddrRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "addr-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bzz-addrs.json")
+
+	registry := NewAddrRegistry(path)
+	events := make(chan AddrEvent, 10)
+	sub := registry.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+	expect := func(typ AddrEventType, addr kademlia.Address) {
+		select {
+		case ev := <-events:
+			if ev.Type != typ || ev.Addr != addr {
+				t.Fatalf("have event %v for %v, want %v for %v", ev.Type, ev.Addr, typ, addr)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %v event for %v", typ, addr)
+		}
+	}
+
+	var id, other discover.NodeID
+	id[0], other[0] = 1, 2
+	// a node with a virtual identity registers two overlay addresses
+	addrs := VirtualAddrs(kademlia.RandomAddress(), 2)
+	registry.Register(id, addrs[0], "enode://a@127.0.0.1:30399")
+	expect(AddrAdded, addrs[0])
+	registry.Register(id, addrs[1], "enode://a@127.0.0.1:30399")
+	expect(AddrAdded, addrs[1])
+	registry.Register(id, addrs[1], "enode://a@127.0.0.1:30399")
+
+	if have := registry.Addrs(id); !reflect.DeepEqual(have, addrs) {
+		t.Errorf("have addresses %v, want %v", have, addrs)
+	}
+	if have, ok := registry.ID(addrs[1]); !ok || have != id {
+		t.Errorf("have node id %v (%v), want %v", have, ok, id)
+	}
+	if url := registry.Url(addrs[0]); url != "enode://a@127.0.0.1:30399" {
+		t.Errorf("have url %q", url)
+	}
+
+	// the address is taken over by another node
+	registry.Register(other, addrs[1], "enode://b@127.0.0.1:30400")
+	expect(AddrUpdated, addrs[1])
+	if have := registry.Addrs(id); !reflect.DeepEqual(have, addrs[:1]) {
+		t.Errorf("have addresses %v after takeover, want %v", have, addrs[:1])
+	}
+
+	if err := registry.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if !registry.Remove(addrs[0]) {
+		t.Fatalf("registered address %v not removed", addrs[0])
+	}
+	expect(AddrRemoved, addrs[0])
+	if len(registry.Addrs(id)) != 0 {
+		t.Errorf("addresses of node id left after removal: %v", registry.Addrs(id))
+	}
+
+	// the saved registry is restored
+	restored := NewAddrRegistry(path)
+	if err := restored.Load(); err != nil {
+		t.Fatal(err)
+	}
+	records := restored.Records()
+	if len(records) != 2 {
+		t.Fatalf("have %d records restored, want 2", len(records))
+	}
+	if have, _ := restored.ID(addrs[0]); have != id {
+		t.Errorf("restored node id %v, want %v", have, id)
+	}
+	if have, _ := restored.ID(addrs[1]); have != other {
+		t.Errorf("restored node id %v, want %v", have, other)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package network
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/bits"
+
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
+)
+
+// MaxIDDifficulty is the highest node id puzzle difficulty accepted, mining a
+// key takes 2^difficulty key generations on average.
+const MaxIDDifficulty = 24
+
+/*
+Overlay addresses are cheap to generate, so an attacker can fill the tables of
+a neighbourhood with nodes it controls by grinding keys close to the target.
+Following the static puzzle of S/Kademlia, a network may require node ids
+whose derived overlay address hashes to at least IDDifficulty leading zero
+bits:
+
+  keccak256(overlayaddr)
+
+A peer is only accepted if its advertised address is derived from its node id
+and that address solves the puzzle, so each identity costs 2^IDDifficulty
+key generations on average while verifying it takes a single hash.
+*/
+
+// IDPuzzleBits returns the number of leading zero bits of the hash of the
+// overlay address
+func IDPuzzleBits(addr kademlia.Address) int {
+	h := crypto.Keccak256(addr[:])
+	n := 0
+	for _, b := range h {
+		n += bits.LeadingZeros8(b)
+		if b != 0 {
+			break
+		}
+	}
+	return n
+}
+
+// SolvesIDPuzzle reports whether the overlay address solves the node id
+// puzzle of the given difficulty, always true for a difficulty of 0
+func SolvesIDPuzzle(addr kademlia.Address, difficulty int) bool {
+	return difficulty <= 0 || IDPuzzleBits(addr) >= difficulty
+}
+
+// GenerateSecureKey mines a node key whose overlay address in the network
+// solves the node id puzzle of the given difficulty
+func GenerateSecureKey(difficulty int, networkId uint64, namespaced bool) (*ecdsa.PrivateKey, error) {
+	if difficulty < 0 || difficulty > MaxIDDifficulty {
+		return nil, fmt.Errorf("invalid node id difficulty %d (max %d)", difficulty, MaxIDDifficulty)
+	}
+	for {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		addr := OverlayAddr(crypto.FromECDSAPub(&key.PublicKey), networkId, namespaced)
+		if SolvesIDPuzzle(addr, difficulty) {
+			return key, nil
+		}
+	}
+}
+
+// validID reports whether the node id of a peer solves the id puzzle
+// required by the hive
+func (self *Hive) validID(addr *peerAddr) bool {
+	return SolvesIDPuzzle(self.overlayAddr(addr.ID), self.kad.IDDifficulty)
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package network
+
+import (
+	"crypto/ecdsa"
+	"net"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/p2p/discover"
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
+)
+
+func TestIDPuzzleBits(t *testing.T) {
+	var addr kademlia.Address
+	for i := 0; i < 1000; i++ {
+		addr = kademlia.RandomAddress()
+		h := crypto.Keccak256(addr[:])
+		want := 0
+		for want < 256 && h[want/8]&(0x80>>uint(want%8)) == 0 {
+			want++
+		}
+		if have := IDPuzzleBits(addr); have != want {
+			t.Fatalf("address %v: have %d leading zero bits, want %d", addr, have, want)
+		}
+		if !SolvesIDPuzzle(addr, want) || SolvesIDPuzzle(addr, want+1) {
+			t.Fatalf("address %v with %d leading zero bits misjudged", addr, want)
+		}
+	}
+	if !SolvesIDPuzzle(addr, 0) {
+		t.Error("puzzle of difficulty 0 not solved")
+	}
+}
+
+func TestGenerateSecureKey(t *testing.T) {
+	for _, namespaced := range []bool{false, true} {
+		key, err := GenerateSecureKey(8, 3, namespaced)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := OverlayAddr(crypto.FromECDSAPub(&key.PublicKey), 3, namespaced)
+		if bits := IDPuzzleBits(addr); bits < 8 {
+			t.Errorf("namespaced=%v: generated address has %d leading zero bits, want 8", namespaced, bits)
+		}
+	}
+	if _, err := GenerateSecureKey(MaxIDDifficulty+1, 3, false); err == nil {
+		t.Error("expected error for excessive difficulty")
+	}
+}
+
+func TestHiveValidID(t *testing.T) {
+	const difficulty = 6
+	params := NewDefaultHiveParams()
+	params.IDDifficulty = difficulty
+	hive := NewHive(common.Hash(kademlia.RandomAddress()), params, false, false)
+
+	peerOf := func(key *ecdsa.PrivateKey, addr kademlia.Address) *peerAddr {
+		id := discover.PubkeyID(&key.PublicKey)
+		return &peerAddr{IP: net.IPv4(127, 0, 0, 1), Port: 30399, ID: id[:], Addr: addr}
+	}
+	secure, err := GenerateSecureKey(difficulty, NetworkId, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := OverlayAddr(crypto.FromECDSAPub(&secure.PublicKey), NetworkId, false)
+	if p := peerOf(secure, addr); !hive.validAddr(p) || !hive.validID(p) {
+		t.Fatal("peer solving the id puzzle rejected")
+	}
+	// an address not derived from the key is rejected even without namespace
+	if hive.validAddr(peerOf(secure, kademlia.RandomAddress())) {
+		t.Error("address not derived from the node id accepted")
+	}
+	// find a key failing the puzzle
+	var weak *ecdsa.PrivateKey
+	for weak == nil {
+		key, _ := crypto.GenerateKey()
+		if !SolvesIDPuzzle(OverlayAddr(crypto.FromECDSAPub(&key.PublicKey), NetworkId, false), difficulty) {
+			weak = key
+		}
+	}
+	weakAddr := OverlayAddr(crypto.FromECDSAPub(&weak.PublicKey), NetworkId, false)
+	if hive.validID(peerOf(weak, weakAddr)) {
+		t.Error("peer failing the id puzzle accepted")
+	}
+
+	// Gossiped peers failing the puzzle are not added to the table
+	from := &peer{bzz: &bzz{remoteAddr: peerOf(secure, addr)}}
+	weakPeer := peerOf(weak, weakAddr)
+	weakPeer.IP = net.IPv4(127, 0, 0, 2)
+	securePeer := peerOf(secure, addr)
+	securePeer.IP = net.IPv4(127, 0, 0, 3)
+	hive.HandlePeersMsg(&peersMsgData{Peers: []*peerAddr{weakPeer, securePeer}}, from)
+	if count := hive.kad.DBCount(); count != 1 {
+		t.Errorf("have %d node records, want 1", count)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package network
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/p2p/discover"
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
+	"github.com/matrix/go-matrix/swarm/storage"
+)
+
+// MaxVirtualNodes is the maximum number of overlay identities run by a
+// single process in addition to its own
+const MaxVirtualNodes = 15
+
+/*
+Hives are the overlay identities (virtual nodes) run by a single process,
+the first one being the node's own. A beefy host can thus cover more of the
+overlay address space than a single address allows.
+
+The identities share the chunk store and the bzz transport, but each keeps
+its own kademlia table and kaddb. Since there is a single connection to each
+remote node, a connection is served by the identity closest to the remote
+peer and chunks are routed via the peers of all the identities.
+*/
+type Hives []*Hive
+
+// NewHives creates the hive for the node's own address and count virtual
+// nodes partitioning the address space with it
+func NewHives(addr common.Hash, count int, params *HiveParams, swapEnabled, syncEnabled bool) (Hives, error) {
+	if count < 0 || count > MaxVirtualNodes {
+		return nil, fmt.Errorf("invalid number of virtual nodes %d (max %d)", count, MaxVirtualNodes)
+	}
+	var hives Hives
+	for i, a := range VirtualAddrs(kademlia.Address(addr), count+1) {
+		hive := NewHive(common.Hash(a), params, swapEnabled, syncEnabled)
+		if i > 0 && hive.path != "" {
+			ext := filepath.Ext(hive.path)
+			hive.path = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(hive.path, ext), i, ext)
+		}
+		if i > 0 {
+			// a remote node has the same node id for all identities
+			hive.registry = hives[0].registry
+			hive.dialer = hives[0].dialer
+		}
+		hives = append(hives, hive)
+	}
+	return hives, nil
+}
+
+// VirtualAddrs partitions the address space into the smallest power of two
+// number of slices holding count addresses and returns one address in each of
+// the first count slices. The first address is base itself, the others only
+// differ from it in the leading bits selecting the slice.
+func VirtualAddrs(base kademlia.Address, count int) []kademlia.Address {
+	var bits uint
+	for 1<<bits < count {
+		bits++
+	}
+	prefix := int(base[0] >> (8 - bits))
+	addrs := make([]kademlia.Address, count)
+	for i := range addrs {
+		addr := base
+		addr[0] = byte((prefix^i)<<(8-bits)) | base[0]&(0xff>>bits)
+		addrs[i] = addr
+	}
+	return addrs
+}
+
+// Addrs returns the overlay addresses of the identities
+func (self Hives) Addrs() []kademlia.Address {
+	addrs := make([]kademlia.Address, len(self))
+	for i, hive := range self {
+		addrs[i] = hive.Addr()
+	}
+	return addrs
+}
+
+// Registry returns the address registry shared by the identities
+func (self Hives) Registry() *AddrRegistry {
+	return self[0].registry
+}
+
+// Start starts the peer management of all the identities
+func (self Hives) Start(id discover.NodeID, listenAddr func() string, connectPeer func(string) error) error {
+	for _, hive := range self {
+		if err := hive.Start(id, listenAddr, connectPeer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop stops all the identities and saves their kaddbs
+func (self Hives) Stop() (err error) {
+	for _, hive := range self {
+		if e := hive.Stop(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// DropAll disconnects all the peers
+func (self Hives) DropAll() {
+	for _, hive := range self {
+		hive.DropAll()
+	}
+}
+
+// assign returns the identity serving a newly connected peer: the one closest
+// to the overlay address derived from the peer's node key. Remote nodes with
+// virtual identities of their own may present another address, the
+// connection is then served by a less appropriate table.
+func (self Hives) assign(id discover.NodeID) *Hive {
+	target := self[0].overlayAddr(id[:])
+	best := self[0]
+	for _, hive := range self[1:] {
+		if target.ProxCmp(hive.addr, best.addr) < 0 {
+			best = hive
+		}
+	}
+	return best
+}
+
+// getPeers returns the peers of all the identities closest to the target
+func (self Hives) getPeers(target storage.Key, max int) []*peer {
+	if len(self) == 1 {
+		return self[0].getPeers(target, max)
+	}
+	var peers []*peer
+	for _, hive := range self {
+		peers = append(peers, hive.getPeers(target, max)...)
+	}
+	var addr kademlia.Address
+	copy(addr[:], target[:])
+	if max > 0 {
+		nearest := make([]*peer, 0, max)
+		for _, i := range kademlia.NearestIndexes(addr, len(peers), max, func(i int) kademlia.Address { return peers[i].Addr() }) {
+			nearest = append(nearest, peers[i])
+		}
+		return nearest
+	}
+	sort.SliceStable(peers, func(i, j int) bool {
+		return addr.ProxCmp(peers[i].Addr(), peers[j].Addr()) < 0
+	})
+	return peers
+}
@@ -0,0 +1,100 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package network
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/p2p/discover"
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
+)
+
+func TestVirtualAddrs(t *testing.T) {
+	base := kademlia.RandomAddress()
+	for _, test := range []struct {
+		count int
+		mask  byte // bits not selecting the slice
+	}{{1, 0xff}, {2, 0x7f}, {3, 0x3f}, {4, 0x3f}, {7, 0x1f}, {16, 0x0f}} {
+		count := test.count
+		addrs := VirtualAddrs(base, count)
+		if len(addrs) != count || addrs[0] != base {
+			t.Fatalf("count %d: have %d addresses starting with %v", count, len(addrs), addrs[0])
+		}
+		slices := make(map[byte]bool)
+		for _, addr := range addrs {
+			if addr[0]&test.mask != base[0]&test.mask || !bytes.Equal(addr[1:], base[1:]) {
+				t.Errorf("count %d: address %v differs from base beyond the slice bits", count, addr)
+			}
+			slices[addr[0]] = true
+		}
+		if len(slices) != count {
+			t.Errorf("count %d: have %d distinct addresses", count, len(slices))
+		}
+	}
+}
+
+func TestNewHives(t *testing.T) {
+	dir, err := ioutil.TempDir("", "virtual-hives")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	params := NewDefaultHiveParams()
+	params.Init(dir)
+	if _, err := NewHives(common.Hash{}, MaxVirtualNodes+1, params, false, false); err == nil {
+		t.Fatal("expected error for too many virtual nodes")
+	}
+	hives, err := NewHives(common.Hash(kademlia.RandomAddress()), 3, params, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hives) != 4 {
+		t.Fatalf("have %d hives, want 4", len(hives))
+	}
+	paths := make(map[string]bool)
+	for _, hive := range hives {
+		paths[hive.path] = true
+	}
+	if len(paths) != 4 || !paths[params.KadDbPath] || !paths[filepath.Join(filepath.Dir(params.KadDbPath), "bzz-peers-3.json")] {
+		t.Errorf("unexpected kaddb paths %v", paths)
+	}
+}
+
+func TestHivesAssign(t *testing.T) {
+	hives, err := NewHives(common.Hash(kademlia.RandomAddress()), 3, NewDefaultHiveParams(), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		var id discover.NodeID
+		rand.Read(id[:])
+		target := kademlia.Address(crypto.Keccak256Hash(append([]byte{0x04}, id[:]...)))
+
+		hive := hives.assign(id)
+		// the identities partition the address space by the two leading bits
+		if hive.addr[0]>>6 != target[0]>>6 {
+			t.Errorf("peer %v assigned to identity %v", target, hive.addr)
+		}
+	}
+}
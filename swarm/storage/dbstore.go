@@ -32,6 +32,7 @@ import (
 	"io/ioutil"
 	"sync"
 
+	"github.com/matrix/go-matrix/common"
 	"github.com/matrix/go-matrix/log"
 	"github.com/matrix/go-matrix/metrics"
 	"github.com/matrix/go-matrix/rlp"
@@ -43,6 +44,8 @@ import (
 var (
 	gcCounter            = metrics.NewRegisteredCounter("storage.db.dbstore.gc.count", nil)
 	dbStoreDeleteCounter = metrics.NewRegisteredCounter("storage.db.dbstore.rm.count", nil)
+
+	dbStoreQuarantineCounter = metrics.NewRegisteredCounter("storage.db.dbstore.quarantine.count", nil)
 )
 
 const (
@@ -53,7 +56,8 @@ const (
 	gcArrayFreeRatio = 0.1
 
 	// key prefixes for leveldb storage
-	kpIndex = 0
+	kpIndex      = 0
+	kpQuarantine = 6 // corrupt chunk data kept aside by hash, see quarantine
 )
 
 var (
@@ -146,6 +150,13 @@ func getIndexKey(hash Key) []byte {
 	return key
 }
 
+func getQuarantineKey(hash Key) []byte {
+	key := make([]byte, len(hash)+1)
+	key[0] = kpQuarantine
+	copy(key[1:], hash[:])
+	return key
+}
+
 func getDataKey(idx uint64) []byte {
 	key := make([]byte, 9)
 	key[0] = 1
@@ -371,10 +382,7 @@ func (s *DbStore) Cleanup() {
 			s.delete(index.Idx, getIndexKey(key[1:]))
 			errorsFound++
 		} else {
-			hasher := s.hashfunc()
-			hasher.Write(data)
-			hash := hasher.Sum(nil)
-			if !bytes.Equal(hash, key[1:]) {
+			if hash := s.hash(data); !bytes.Equal(hash, key[1:]) {
 				log.Warn(fmt.Sprintf("Found invalid chunk. Hash mismatch. hash=%x, key=%x", hash, key[:]))
 				s.delete(index.Idx, getIndexKey(key[1:]))
 				errorsFound++
@@ -396,6 +404,94 @@ func (s *DbStore) delete(idx uint64, idxKey []byte) {
 	s.db.Write(batch)
 }
 
+// hash returns the hash of chunk data
+func (s *DbStore) hash(data []byte) []byte {
+	hasher := s.hashfunc()
+	hasher.Write(data)
+	return hasher.Sum(nil)
+}
+
+// quarantine removes a corrupt chunk from the store, keeping its data aside
+// for inspection
+// caller holds the lock
+func (s *DbStore) quarantine(idx uint64, key Key, data []byte) {
+	batch := new(leveldb.Batch)
+	batch.Delete(getIndexKey(key))
+	batch.Delete(getDataKey(idx))
+	batch.Put(getQuarantineKey(key), data)
+	dbStoreQuarantineCounter.Inc(1)
+	s.entryCnt--
+	batch.Put(keyEntryCnt, U64ToBytes(s.entryCnt))
+	s.db.Write(batch)
+}
+
+// Quarantined returns the data of a chunk found corrupt, nil if there is none
+func (s *DbStore) Quarantined(key Key) []byte {
+	data, err := s.db.Get(getQuarantineKey(key))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// Verify re-hashes the data of a stored chunk, quarantining it if corrupt.
+// It returns notFound if the chunk isn't stored.
+func (s *DbStore) Verify(key Key) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	idata, err := s.db.Get(getIndexKey(key))
+	if err != nil {
+		return false, notFound
+	}
+	var index dpaDBIndex
+	decodeIndex(idata, &index)
+
+	data, err := s.db.Get(getDataKey(index.Idx))
+	if err != nil {
+		// the index of a chunk without data is useless, same as in Get
+		s.delete(index.Idx, getIndexKey(key))
+		return false, nil
+	}
+	if !bytes.Equal(s.hash(data), key) {
+		s.quarantine(index.Idx, key, data)
+		return false, nil
+	}
+	return true, nil
+}
+
+// Sample returns up to n stored chunk keys picked at random positions of the
+// index, fewer if the store holds fewer chunks
+func (s *DbStore) Sample(n int, src io.Reader) []Key {
+	it := s.db.NewIterator()
+	defer it.Release()
+
+	var (
+		keys []Key
+		seen = make(map[string]bool)
+		pos  = make([]byte, 32)
+	)
+	for i := 0; i < n; i++ {
+		if _, err := io.ReadFull(src, pos); err != nil {
+			break
+		}
+		it.Seek(getIndexKey(pos))
+		if !it.Valid() || it.Key()[0] != kpIndex {
+			// wrap around to the first chunk
+			it.Seek([]byte{kpIndex})
+			if !it.Valid() || it.Key()[0] != kpIndex {
+				break
+			}
+		}
+		key := Key(common.CopyBytes(it.Key()[1:]))
+		if !seen[string(key)] {
+			seen[string(key)] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
 func (s *DbStore) Counter() uint64 {
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -484,12 +580,11 @@ func (s *DbStore) Get(key Key) (chunk *Chunk, err error) {
 			return
 		}
 
-		hasher := s.hashfunc()
-		hasher.Write(data)
-		hash := hasher.Sum(nil)
-		if !bytes.Equal(hash, key) {
-			s.delete(index.Idx, getIndexKey(key))
-			log.Warn("Invalid Chunk in Database. Please repair with command: 'swarm cleandb'")
+		if !bytes.Equal(s.hash(data), key) {
+			// never serve corrupt data, the chunk is fetched again instead
+			s.quarantine(index.Idx, key, data)
+			log.Warn(fmt.Sprintf("Invalid chunk %v in database quarantined", key.Log()))
+			return nil, notFound
 		}
 
 		chunk = &Chunk{
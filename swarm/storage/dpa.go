@@ -86,6 +86,28 @@ func NewDPA(store ChunkStore, params *ChunkerParams) *DPA {
 	}
 }
 
+// NewDryRunDPA returns a DPA which computes the swarm hashes of the content
+// stored without keeping any of the chunks, so the address of content is known
+// before it is uploaded. Retrieval from it always fails.
+func NewDryRunDPA(chunker Chunker) *DPA {
+	return &DPA{
+		Chunker:    chunker,
+		ChunkStore: dryRunChunkStore{},
+	}
+}
+
+// Hash returns the swarm root hash of the data read without storing the chunks
+func (self *DPA) Hash(data io.Reader, size int64) (Key, error) {
+	return self.Chunker.Split(data, size, nil, nil, nil)
+}
+
+// dryRunChunkStore discards the chunks put
+type dryRunChunkStore struct{}
+
+func (dryRunChunkStore) Put(*Chunk)              {}
+func (dryRunChunkStore) Get(Key) (*Chunk, error) { return nil, notFound }
+func (dryRunChunkStore) Close()                  {}
+
 // Public API. Main entry point for document retrieval directly. Used by the
 // FS-aware API and httpaccess
 // Chunk retrieval blocks on netStore requests with a timeout so reader will
@@ -100,6 +122,31 @@ func (self *DPA) Store(data io.Reader, size int64, swg *sync.WaitGroup, wwg *syn
 	return self.Chunker.Split(data, size, self.storeC, swg, wwg)
 }
 
+// StoreWithProgress stores the data like Store, counting its chunks in
+// progress as they are stored and synced
+func (self *DPA) StoreWithProgress(data io.Reader, size int64, swg *sync.WaitGroup, progress *Progress) (key Key, err error) {
+	if progress == nil {
+		return self.Store(data, size, swg, nil)
+	}
+	// the chunks are tagged on their way to the store workers, the channel
+	// is closed once all the hash workers of the split are done sending
+	chunkC := make(chan *Chunk)
+	go func() {
+		for chunk := range chunkC {
+			chunk.Progress = progress
+			progress.addChunk()
+			self.storeC <- chunk
+		}
+	}()
+	wwg := &sync.WaitGroup{}
+	key, err = self.Chunker.Split(data, size, chunkC, swg, wwg)
+	go func() {
+		wwg.Wait()
+		close(chunkC)
+	}()
+	return key, err
+}
+
 func (self *DPA) Start() {
 	self.lock.Lock()
 	defer self.lock.Unlock()
@@ -168,6 +215,7 @@ func (self *DPA) storeWorker() {
 
 	for chunk := range self.storeC {
 		self.Put(chunk)
+		chunk.Progress.addStored()
 		if chunk.wg != nil {
 			log.Trace(fmt.Sprintf("dpa: store processor %v", chunk.Key.Log()))
 			chunk.wg.Done()
@@ -227,8 +275,11 @@ func (self *dpaChunkStore) Put(entry *Chunk) {
 		log.Trace(fmt.Sprintf("DPA.Put: %v request entry found", entry.Key.Log()))
 		chunk.SData = entry.SData
 		chunk.Size = entry.Size
+		chunk.Progress = entry.Progress
 	} else {
 		log.Trace(fmt.Sprintf("DPA.Put: %v chunk already known", entry.Key.Log()))
+		// known chunks have been synced when first stored
+		entry.Progress.AddSynced()
 		return
 	}
 	// from this point on the storage logic is the same with network storage requests
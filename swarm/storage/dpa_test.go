@@ -141,3 +141,32 @@ func TestDPA_capacity(t *testing.T) {
 		t.Errorf("Comparison error after clearing memStore.")
 	}
 }
+
+func TestDPAHash(t *testing.T) {
+	dbStore := initDbStore(t)
+	localStore := &LocalStore{
+		NewMemStore(dbStore, defaultCacheCapacity),
+		dbStore,
+	}
+	dpa := NewDPA(localStore, NewChunkerParams())
+	dpa.Start()
+	defer dpa.Stop()
+
+	_, slice := testDataReaderAndSlice(0x10000)
+	hash, err := NewDryRunDPA(dpa.Chunker).Hash(bytes.NewReader(slice), int64(len(slice)))
+	if err != nil {
+		t.Fatalf("Hash error: %v", err)
+	}
+	if count := dbStore.Counter(); count != 0 {
+		t.Fatalf("hashing stored %d chunks", count)
+	}
+	wg := &sync.WaitGroup{}
+	key, err := dpa.Store(bytes.NewReader(slice), int64(len(slice)), wg, nil)
+	if err != nil {
+		t.Fatalf("Store error: %v", err)
+	}
+	wg.Wait()
+	if !bytes.Equal(hash, key) {
+		t.Fatalf("hash %v, stored as %v", hash, key)
+	}
+}
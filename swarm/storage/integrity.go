@@ -0,0 +1,177 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/metrics"
+)
+
+var (
+	integrityCheckedCounter   = metrics.NewRegisteredCounter("storage.integrity.checked.count", nil)
+	integrityCorruptCounter   = metrics.NewRegisteredCounter("storage.integrity.corrupt.count", nil)
+	integrityRecoveredCounter = metrics.NewRegisteredCounter("storage.integrity.recovered.count", nil)
+	integrityLostCounter      = metrics.NewRegisteredCounter("storage.integrity.lost.count", nil)
+	integrityCorruptGauge     = metrics.NewRegisteredGaugeFloat64("storage.integrity.corrupt.rate", nil) // share of the last sample found corrupt
+)
+
+const defaultIntegritySamples = 128
+
+var (
+	// interval between two samples of the integrity checker
+	integrityCheckInterval = time.Hour
+	// time allowed to retrieve a corrupt chunk again from the network
+	integrityRetrieveTimeout = 4 * searchTimeout
+)
+
+/*
+IntegrityChecker guards against silent corruption of the chunks stored on
+disk. Every hour it re-hashes a random sample of the stored chunks, quarantines
+the corrupt ones so that they are no longer served, and attempts to retrieve
+them again from the network. The corruption rate of the last sample and the
+outcome of the repairs are reported via metrics.
+*/
+type IntegrityChecker struct {
+	dbStore  *DbStore
+	netStore *NetStore
+	samples  int // number of chunks checked per interval
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// IntegrityReport is the outcome of checking a sample of stored chunks
+type IntegrityReport struct {
+	Checked   int
+	Corrupt   int
+	Recovered int // corrupt chunks retrieved again from the network
+}
+
+// NewIntegrityChecker creates a checker of the chunks stored by the local
+// store of a net store, retrieving the corrupt ones through the net store
+func NewIntegrityChecker(netStore *NetStore, samples int) *IntegrityChecker {
+	return &IntegrityChecker{
+		dbStore:  netStore.localStore.DbStore.(*DbStore),
+		netStore: netStore,
+		samples:  samples,
+	}
+}
+
+// Start launches the periodic checks
+func (self *IntegrityChecker) Start() {
+	self.quit = make(chan struct{})
+	self.wg.Add(1)
+	go self.loop()
+}
+
+// Stop terminates the periodic checks, waiting for the one running
+func (self *IntegrityChecker) Stop() {
+	close(self.quit)
+	self.wg.Wait()
+}
+
+func (self *IntegrityChecker) loop() {
+	defer self.wg.Done()
+
+	ticker := time.NewTicker(integrityCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			report := self.Check()
+			if report.Corrupt > 0 {
+				log.Warn(fmt.Sprintf("Chunk integrity check: %d of %d chunks corrupt, %d recovered from the network", report.Corrupt, report.Checked, report.Recovered))
+			} else {
+				log.Debug(fmt.Sprintf("Chunk integrity check: %d chunks intact", report.Checked))
+			}
+		case <-self.quit:
+			return
+		}
+	}
+}
+
+// Check verifies a random sample of the stored chunks, repairing the corrupt
+// ones from the network
+func (self *IntegrityChecker) Check() *IntegrityReport {
+	report := new(IntegrityReport)
+loop:
+	for _, key := range self.dbStore.Sample(self.samples, rand.Reader) {
+		ok, err := self.dbStore.Verify(key)
+		if err != nil {
+			// removed since sampled
+			continue
+		}
+		report.Checked++
+		if ok {
+			continue
+		}
+		report.Corrupt++
+		log.Warn(fmt.Sprintf("Chunk %v corrupt on disk, quarantined", key.Log()))
+		if self.recover(key) {
+			report.Recovered++
+			integrityRecoveredCounter.Inc(1)
+		} else {
+			integrityLostCounter.Inc(1)
+			log.Warn(fmt.Sprintf("Chunk %v could not be retrieved again", key.Log()))
+		}
+		select {
+		case <-self.quit:
+			break loop
+		default:
+		}
+	}
+	integrityCheckedCounter.Inc(int64(report.Checked))
+	integrityCorruptCounter.Inc(int64(report.Corrupt))
+	if report.Checked > 0 {
+		integrityCorruptGauge.Update(float64(report.Corrupt) / float64(report.Checked))
+	}
+	return report
+}
+
+// recover retrieves a quarantined chunk again, from the memory cache if it
+// still holds an intact copy or from the network, and stores it back
+func (self *IntegrityChecker) recover(key Key) bool {
+	chunk, err := self.netStore.Get(key)
+	if err != nil {
+		return false
+	}
+	if chunk.SData == nil {
+		select {
+		case <-chunk.Req.C:
+		case <-time.After(integrityRetrieveTimeout):
+			return false
+		case <-self.quit:
+			return false
+		}
+		// the delivered chunk replaces the request in the local store
+		if chunk, err = self.netStore.localStore.Get(key); err != nil {
+			return false
+		}
+	}
+	if len(chunk.SData) < 8 || !bytes.Equal(self.dbStore.hash(chunk.SData), key) {
+		return false
+	}
+	// deliveries are stored by the net store already, cached copies are not
+	self.dbStore.Put(&Chunk{Key: key, SData: chunk.SData, Size: chunk.Size})
+	return true
+}
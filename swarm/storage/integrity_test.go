@@ -0,0 +1,79 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package storage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+)
+
+// corruptChunk stores a chunk whose data doesn't match its key
+func corruptChunk(dbStore *DbStore) (*Chunk, []byte) {
+	chunk := testChunk()
+	bad := common.CopyBytes(chunk.SData)
+	bad[len(bad)-1] ^= 0x01
+	dbStore.Put(&Chunk{Key: chunk.Key, SData: bad, Size: chunk.Size})
+	return chunk, bad
+}
+
+func TestDbStoreQuarantine(t *testing.T) {
+	dbStore := initDbStore(t)
+	defer dbStore.Close()
+
+	chunk, bad := corruptChunk(dbStore)
+	if _, err := dbStore.Get(chunk.Key); err != notFound {
+		t.Fatalf("corrupt chunk served: %v", err)
+	}
+	if !bytes.Equal(dbStore.Quarantined(chunk.Key), bad) {
+		t.Fatal("corrupt chunk not quarantined")
+	}
+	if _, err := dbStore.Verify(chunk.Key); err != notFound {
+		t.Fatalf("quarantined chunk still stored: %v", err)
+	}
+}
+
+func TestIntegrityCheckRecovers(t *testing.T) {
+	netStore, cloud := newTestNetStore(t)
+	dbStore := netStore.localStore.DbStore.(*DbStore)
+	defer dbStore.Close()
+
+	chunk, _ := corruptChunk(dbStore)
+	// a peer delivers the chunk once requested
+	go func() {
+		for retrieves, _ := cloud.counts(); retrieves == 0; retrieves, _ = cloud.counts() {
+			time.Sleep(time.Millisecond)
+		}
+		req, _ := netStore.Get(chunk.Key)
+		netStore.Put(&Chunk{Key: chunk.Key, SData: chunk.SData, Size: chunk.Size, Req: req.Req})
+	}()
+	checker := NewIntegrityChecker(netStore, 8)
+	report := checker.Check()
+	if report.Checked != 1 || report.Corrupt != 1 || report.Recovered != 1 {
+		t.Fatalf("report mismatch: %+v", report)
+	}
+	stored, err := dbStore.Get(chunk.Key)
+	if err != nil || !bytes.Equal(stored.SData, chunk.SData) {
+		t.Fatalf("chunk not restored: %v", err)
+	}
+	// intact chunks are left alone
+	if report := checker.Check(); report.Checked != 1 || report.Corrupt != 0 {
+		t.Fatalf("report mismatch after repair: %+v", report)
+	}
+}
@@ -19,9 +19,18 @@ package storage
 import (
 	"fmt"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/metrics"
+)
+
+var (
+	netStoreFetchCounter     = metrics.NewRegisteredCounter("storage.netstore.fetch.count", nil)
+	netStoreRefetchCounter   = metrics.NewRegisteredCounter("storage.netstore.fetch.retry.count", nil)
+	netStoreCoalesceCounter  = metrics.NewRegisteredCounter("storage.netstore.fetch.coalesced.count", nil)
+	netStoreDuplicateCounter = metrics.NewRegisteredCounter("storage.netstore.delivery.duplicate.count", nil)
 )
 
 /*
@@ -34,11 +43,25 @@ It is called by the bzz protocol instances via Depo (the store/retrieve request
 a protocol instance is running on each peer, so this is heavily parallelised.
 NetStore falls back to a backend (CloudStorage interface)
 implemented by bzz/network/forwarder. forwarder or IPFS or IPΞS
+
+Concurrent requests for the same chunk, local or remote, are coalesced into
+a single network fetch, the delivery of which is fanned out to all of them.
+Only the first delivery of a requested chunk is stored and passed on.
 */
 type NetStore struct {
 	hashfunc   SwarmHasher
 	localStore *LocalStore
 	cloud      CloudStore
+
+	lock     sync.Mutex
+	fetching map[string]*fetch // chunks requested from the network, awaiting delivery
+	expired  time.Time         // last time timed out fetches were removed
+}
+
+// fetch is a network retrieval in progress
+type fetch struct {
+	chunk   *Chunk
+	started time.Time
 }
 
 // backend engine for cloud store
@@ -51,18 +74,20 @@ type CloudStore interface {
 }
 
 type StoreParams struct {
-	ChunkDbPath   string
-	DbCapacity    uint64
-	CacheCapacity uint
-	Radius        int
+	ChunkDbPath      string
+	DbCapacity       uint64
+	CacheCapacity    uint
+	Radius           int
+	IntegritySamples int // chunks re-hashed per hour by the integrity checker, 0 disables it
 }
 
 //create params with default values
 func NewDefaultStoreParams() (self *StoreParams) {
 	return &StoreParams{
-		DbCapacity:    defaultDbCapacity,
-		CacheCapacity: defaultCacheCapacity,
-		Radius:        defaultRadius,
+		DbCapacity:       defaultDbCapacity,
+		CacheCapacity:    defaultCacheCapacity,
+		Radius:           defaultRadius,
+		IntegritySamples: defaultIntegritySamples,
 	}
 }
 
@@ -80,6 +105,7 @@ func NewNetStore(hash SwarmHasher, lstore *LocalStore, cloud CloudStore, params
 		hashfunc:   hash,
 		localStore: lstore,
 		cloud:      cloud,
+		fetching:   make(map[string]*fetch),
 	}
 }
 
@@ -93,6 +119,11 @@ var (
 // the chunk is forced to propagate (Cloud.Store) even if locally found!
 // caller needs to make sure if that is wanted
 func (self *NetStore) Put(entry *Chunk) {
+	if entry.Req != nil && !self.delivered(entry) {
+		netStoreDuplicateCounter.Inc(1)
+		log.Trace(fmt.Sprintf("NetStore.Put: %v already delivered, duplicate ignored", entry.Key.Log()))
+		return
+	}
 	self.localStore.Put(entry)
 
 	// handle deliveries
@@ -111,25 +142,85 @@ func (self *NetStore) Put(entry *Chunk) {
 	}
 }
 
+// delivered marks the request for the entry as served, it returns false if
+// the chunk has already been delivered
+func (self *NetStore) delivered(entry *Chunk) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	delete(self.fetching, string(entry.Key))
+	if entry.Req.delivered {
+		return false
+	}
+	entry.Req.delivered = true
+	return true
+}
+
 // retrieve logic common for local and network chunk retrieval requests
 func (self *NetStore) Get(key Key) (*Chunk, error) {
-	var err error
 	chunk, err := self.localStore.Get(key)
-	if err == nil {
-		if chunk.Req == nil {
+	if err == nil && chunk.Req == nil {
+		log.Trace(fmt.Sprintf("NetStore.Get: %v found locally", key))
+		return chunk, err
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.expire()
+
+	f, ok := self.fetching[string(key)]
+	switch {
+	case ok && time.Since(f.started) < searchTimeout:
+		// no need to launch again
+		netStoreCoalesceCounter.Inc(1)
+		log.Trace(fmt.Sprintf("NetStore.Get: %v hit on an existing request", key))
+		return f.chunk, nil
+
+	case ok:
+		netStoreRefetchCounter.Inc(1)
+		log.Trace(fmt.Sprintf("NetStore.Get: %v request timed out. retry", key))
+
+	default:
+		// the chunk may have been delivered since the lookup above
+		chunk, err = self.localStore.Get(key)
+		switch {
+		case err == nil && (chunk.Req == nil || chunk.Req.delivered):
 			log.Trace(fmt.Sprintf("NetStore.Get: %v found locally", key))
-		} else {
-			log.Trace(fmt.Sprintf("NetStore.Get: %v hit on an existing request", key))
-			// no need to launch again
+			return chunk, nil
+
+		case err == nil:
+			netStoreRefetchCounter.Inc(1)
+			log.Trace(fmt.Sprintf("NetStore.Get: %v request timed out. retry", key))
+
+		default:
+			// no data and no request status
+			netStoreFetchCounter.Inc(1)
+			log.Trace(fmt.Sprintf("NetStore.Get: %v not found locally. open new request", key))
+			chunk = NewChunk(key, newRequestStatus(key))
+			self.localStore.memStore.Put(chunk)
+		}
+		f = &fetch{chunk: chunk}
+		self.fetching[string(key)] = f
+	}
+	// the same request status is kept so that all requesters are served
+	f.started = time.Now()
+	go self.cloud.Retrieve(f.chunk)
+	return f.chunk, nil
+}
+
+// expire forgets about timed out fetches, a later request for the chunk
+// launches a new one
+// caller must hold the lock
+func (self *NetStore) expire() {
+	if time.Since(self.expired) < searchTimeout {
+		return
+	}
+	self.expired = time.Now()
+	for key, f := range self.fetching {
+		if time.Since(f.started) >= searchTimeout {
+			delete(self.fetching, key)
 		}
-		return chunk, err
 	}
-	// no data and no request status
-	log.Trace(fmt.Sprintf("NetStore.Get: %v not found locally. open new request", key))
-	chunk = NewChunk(key, newRequestStatus(key))
-	self.localStore.memStore.Put(chunk)
-	go self.cloud.Retrieve(chunk)
-	return chunk, nil
 }
 
 // Close netstore
@@ -0,0 +1,133 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package storage
+
+import (
+	"encoding/binary"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCloudStore counts the calls of the netstore
+type testCloudStore struct {
+	lock       sync.Mutex
+	retrieves  int
+	deliveries int
+}
+
+func (self *testCloudStore) Store(*Chunk) {}
+
+func (self *testCloudStore) Deliver(*Chunk) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.deliveries++
+}
+
+func (self *testCloudStore) Retrieve(*Chunk) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.retrieves++
+}
+
+func (self *testCloudStore) counts() (int, int) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.retrieves, self.deliveries
+}
+
+func newTestNetStore(t *testing.T) (*NetStore, *testCloudStore) {
+	dbStore := initDbStore(t)
+	localStore := &LocalStore{
+		NewMemStore(dbStore, defaultCacheCapacity),
+		dbStore,
+	}
+	cloud := &testCloudStore{}
+	return NewNetStore(MakeHashFunc(SHA3Hash), localStore, cloud, NewDefaultStoreParams()), cloud
+}
+
+func testChunk() *Chunk {
+	data := make([]byte, 8+32)
+	binary.LittleEndian.PutUint64(data, 32)
+	copy(data[8:], "netstore coalescing test chunk")
+	hasher := MakeHashFunc(SHA3Hash)()
+	hasher.Write(data)
+	return &Chunk{Key: hasher.Sum(nil), SData: data, Size: 32}
+}
+
+func TestNetStoreCoalesce(t *testing.T) {
+	netStore, cloud := newTestNetStore(t)
+	data := testChunk()
+
+	var wg sync.WaitGroup
+	chunks := make([]*Chunk, 16)
+	for i := range chunks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			chunks[i], _ = netStore.Get(data.Key)
+		}(i)
+	}
+	wg.Wait()
+	time.Sleep(10 * time.Millisecond) // retrievals are launched in the background
+	if retrieves, _ := cloud.counts(); retrieves != 1 {
+		t.Fatalf("have %d network fetches, want 1", retrieves)
+	}
+
+	// two peers deliver the chunk
+	for i := 0; i < 2; i++ {
+		netStore.Put(&Chunk{Key: data.Key, SData: data.SData, Size: data.Size, Req: chunks[i].Req})
+	}
+	for i, chunk := range chunks {
+		select {
+		case <-chunk.Req.C:
+		case <-time.After(time.Second):
+			t.Fatalf("request %d not served", i)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, deliveries := cloud.counts(); deliveries != 1 {
+		t.Fatalf("have %d deliveries passed on, want 1", deliveries)
+	}
+
+	chunk, err := netStore.Get(data.Key)
+	if err != nil || chunk.SData == nil {
+		t.Fatalf("delivered chunk not found: %v", err)
+	}
+	if retrieves, _ := cloud.counts(); retrieves != 1 {
+		t.Fatalf("have %d network fetches after delivery, want 1", retrieves)
+	}
+}
+
+func TestNetStoreRefetch(t *testing.T) {
+	defer func(timeout time.Duration) { searchTimeout = timeout }(searchTimeout)
+	searchTimeout = 10 * time.Millisecond
+
+	netStore, cloud := newTestNetStore(t)
+	key := testChunk().Key
+
+	first, _ := netStore.Get(key)
+	time.Sleep(2 * searchTimeout)
+	second, _ := netStore.Get(key)
+	time.Sleep(10 * time.Millisecond)
+
+	if retrieves, _ := cloud.counts(); retrieves != 2 {
+		t.Fatalf("have %d network fetches, want 2", retrieves)
+	}
+	if first.Req != second.Req {
+		t.Fatal("retry did not keep the request of the earlier requesters")
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"hash"
 	"io"
 	"sync"
+	"sync/atomic"
 
 	"github.com/matrix/go-matrix/bmt"
 	"github.com/matrix/go-matrix/common"
@@ -133,6 +134,8 @@ type RequestStatus struct {
 	Source     Peer
 	C          chan bool
 	Requesters map[uint64][]interface{}
+
+	delivered bool // set by NetStore once the chunk is delivered
 }
 
 func newRequestStatus(key Key) *RequestStatus {
@@ -157,6 +160,46 @@ type Chunk struct {
 	Req      *RequestStatus  // request Status needed by netStore
 	wg       *sync.WaitGroup // wg to synchronize
 	dbStored chan bool       // never remove a chunk from memStore before it is written to dbStore
+
+	Progress *Progress // progress of the upload the chunk belongs to, nil if not tracked
+}
+
+/*
+Progress counts the chunks of an upload as they are produced by the chunker,
+stored locally and handed on to peers for syncing. All methods may be called
+on a nil Progress.
+*/
+type Progress struct {
+	chunks int64
+	stored int64
+	synced int64
+}
+
+func (self *Progress) addChunk() {
+	if self != nil {
+		atomic.AddInt64(&self.chunks, 1)
+	}
+}
+
+func (self *Progress) addStored() {
+	if self != nil {
+		atomic.AddInt64(&self.stored, 1)
+	}
+}
+
+// AddSynced counts a chunk handed on to the network
+func (self *Progress) AddSynced() {
+	if self != nil {
+		atomic.AddInt64(&self.synced, 1)
+	}
+}
+
+// Counts returns the number of chunks produced, stored and synced so far
+func (self *Progress) Counts() (chunks, stored, synced int64) {
+	if self == nil {
+		return 0, 0, 0
+	}
+	return atomic.LoadInt64(&self.chunks), atomic.LoadInt64(&self.stored), atomic.LoadInt64(&self.synced)
 }
 
 func NewChunk(key Key, rs *RequestStatus) *Chunk {
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"math/big"
 	"net"
+	"path/filepath"
 	"strings"
 	"time"
 	"unicode"
@@ -44,6 +45,7 @@ import (
 	httpapi "github.com/matrix/go-matrix/swarm/api/http"
 	"github.com/matrix/go-matrix/swarm/fuse"
 	"github.com/matrix/go-matrix/swarm/network"
+	"github.com/matrix/go-matrix/swarm/network/kademlia"
 	"github.com/matrix/go-matrix/swarm/storage"
 )
 
@@ -74,6 +76,16 @@ type Swarm struct {
 	swapEnabled bool
 	lstore      *storage.LocalStore // local store, needs to store for releasing resources after node stopped
 	sfs         *fuse.SwarmFS       // need this to cleanup all the active mounts on node exit
+
+	receipts *network.DeliveryReceipts // delivery receipts handed out and received
+
+	hives network.Hives // the node's own hive followed by those of its virtual nodes
+
+	uploads *api.UploadJobs // uploads running in the background
+
+	quotas *api.Quotas // API keys of the http gateway and their quotas, nil if not required
+
+	integrity *storage.IntegrityChecker // spot checks of the chunks stored on disk, nil if disabled
 }
 
 type SwarmAPI struct {
@@ -99,6 +111,9 @@ func NewSwarm(ctx *node.ServiceContext, backend chequebook.Backend, config *api.
 	if bytes.Equal(common.FromHex(config.BzzKey), storage.ZeroKey) {
 		return nil, fmt.Errorf("empty bzz key")
 	}
+	if difficulty := config.HiveParams.IDDifficulty; !network.SolvesIDPuzzle(kademlia.Address(common.HexToHash(config.BzzKey)), difficulty) {
+		return nil, fmt.Errorf("bzz key does not solve the node id puzzle of difficulty %d, generate one with 'swarm genkey'", difficulty)
+	}
 
 	self = &Swarm{
 		config:      config,
@@ -121,23 +136,44 @@ func NewSwarm(ctx *node.ServiceContext, backend chequebook.Backend, config *api.
 	self.dbAccess = network.NewDbAccess(self.lstore)
 	log.Debug(fmt.Sprintf("Set up local db access (iterator/counter)"))
 
-	// set up the kademlia hive
-	self.hive = network.NewHive(
+	// set up the kademlia hive and those of the virtual nodes
+	self.hives, err = network.NewHives(
 		common.HexToHash(self.config.BzzKey), // key to hive (kademlia base address)
+		config.VirtualNodes,                  // number of additional overlay identities
 		config.HiveParams,                    // configuration parameters
 		config.SwapEnabled,                   // SWAP enabled
 		config.SyncEnabled,                   // syncronisation enabled
 	)
-	log.Debug(fmt.Sprintf("Set up swarm network with Kademlia hive"))
+	if err != nil {
+		return
+	}
+	if config.NamespacedAddr {
+		self.hives.SetNamespace(config.NetworkId)
+	}
+	self.hive = self.hives[0]
+	log.Debug(fmt.Sprintf("Set up swarm network with %d Kademlia hives", len(self.hives)))
 
 	// setup cloud storage backend
-	self.cloud = network.NewForwarder(self.hive)
+	self.cloud = network.NewForwarder(self.hives...)
 	log.Debug(fmt.Sprintf("-> set swarm forwarder as cloud storage backend"))
 
 	// setup cloud storage internal access layer
-	self.storage = storage.NewNetStore(hash, self.lstore, self.cloud, config.StoreParams)
+	netStore := storage.NewNetStore(hash, self.lstore, self.cloud, config.StoreParams)
+	self.storage = netStore
 	log.Debug(fmt.Sprintf("-> swarm net store shared access layer to Swarm Chunk Store"))
 
+	if config.IntegritySamples > 0 {
+		self.integrity = storage.NewIntegrityChecker(netStore, config.IntegritySamples)
+		log.Debug(fmt.Sprintf("-> chunk integrity checks of %d chunks per hour", config.IntegritySamples))
+	}
+
+	// set up delivery receipts, only signed for chunks served if enabled
+	var receiptKey *ecdsa.PrivateKey
+	if config.DeliveryReceipts {
+		receiptKey = self.privateKey
+	}
+	self.receipts = network.NewDeliveryReceipts(receiptKey, network.DefaultReceiptsLimit)
+
 	// set up Depo (storage handler = cloud storage access layer for incoming remote requests)
 	self.depo = network.NewDepo(hash, self.lstore, self.storage)
 	log.Debug(fmt.Sprintf("-> REmote Access to CHunks"))
@@ -166,6 +202,16 @@ func NewSwarm(ctx *node.ServiceContext, backend chequebook.Backend, config *api.
 	// Manifests for Smart Hosting
 	log.Debug(fmt.Sprintf("-> Web3 virtual server API"))
 
+	self.uploads = api.NewUploadJobs(self.api)
+
+	if config.GatewayQuotas {
+		self.quotas, err = api.NewQuotas(filepath.Join(config.Path, "bzz-quotas.json"))
+		if err != nil {
+			return nil, err
+		}
+		log.Debug("-> Gateway API key quotas")
+	}
+
 	self.sfs = fuse.NewSwarmFS(self.api)
 	log.Debug("-> Initializing Fuse file system")
 
@@ -295,22 +341,30 @@ func (self *Swarm) Start(srv *p2p.Server) error {
 	}
 
 	log.Warn(fmt.Sprintf("Starting Swarm service"))
-	self.hive.Start(
+	self.hives.Start(
 		discover.PubkeyID(&srv.PrivateKey.PublicKey),
 		func() string { return srv.ListenAddr },
 		connectPeer,
 	)
 	log.Info(fmt.Sprintf("Swarm network started on bzz address: %v", self.hive.Addr()))
+	for _, addr := range self.hives.Addrs()[1:] {
+		log.Info(fmt.Sprintf("Swarm virtual node started on bzz address: %v", addr))
+	}
 
 	self.dpa.Start()
 	log.Debug(fmt.Sprintf("Swarm DPA started"))
 
+	if self.integrity != nil {
+		self.integrity.Start()
+	}
+
 	// start swarm http proxy server
 	if self.config.Port != "" {
 		addr := net.JoinHostPort(self.config.ListenAddr, self.config.Port)
 		go httpapi.StartHttpServer(self.api, &httpapi.ServerConfig{
 			Addr:       addr,
 			CorsString: self.corsString,
+			Quotas:     self.quotas,
 		})
 		log.Info(fmt.Sprintf("Swarm http proxy started on %v", addr))
 
@@ -344,8 +398,11 @@ func (self *Swarm) updateGauges() {
 // implements the node.Service interface
 // stops all component services.
 func (self *Swarm) Stop() error {
+	if self.integrity != nil {
+		self.integrity.Stop()
+	}
 	self.dpa.Stop()
-	err := self.hive.Stop()
+	err := self.hives.Stop()
 	if ch := self.config.Swap.Chequebook(); ch != nil {
 		ch.Stop()
 		ch.Save()
@@ -355,13 +412,18 @@ func (self *Swarm) Stop() error {
 		self.lstore.DbStore.Close()
 	}
 	self.sfs.Stop()
+	if self.quotas != nil {
+		if qerr := self.quotas.Save(); qerr != nil {
+			log.Warn(fmt.Sprintf("unable to save gateway quotas: %v", qerr))
+		}
+	}
 	stopCounter.Inc(1)
 	return err
 }
 
 // implements the node.Service interface
 func (self *Swarm) Protocols() []p2p.Protocol {
-	proto, err := network.Bzz(self.depo, self.backend, self.hive, self.dbAccess, self.config.Swap, self.config.SyncParams, self.config.NetworkId)
+	proto, err := network.Bzz(self.depo, self.backend, self.hives, self.dbAccess, self.config.Swap, self.config.SyncParams, self.config.NetworkId, self.receipts)
 	if err != nil {
 		return nil
 	}
@@ -371,7 +433,7 @@ func (self *Swarm) Protocols() []p2p.Protocol {
 // implements node.Service
 // Apis returns the RPC Api descriptors the Swarm implementation offers
 func (self *Swarm) APIs() []rpc.API {
-	return []rpc.API{
+	apis := []rpc.API{
 		// public APIs
 		{
 			Namespace: "bzz",
@@ -386,6 +448,12 @@ func (self *Swarm) APIs() []rpc.API {
 			Service:   api.NewControl(self.api, self.hive),
 			Public:    false,
 		},
+		{
+			Namespace: "bzz",
+			Version:   "0.1",
+			Service:   self.receipts,
+			Public:    false,
+		},
 		{
 			Namespace: "chequebook",
 			Version:   chequebook.Version,
@@ -412,8 +480,23 @@ func (self *Swarm) APIs() []rpc.API {
 			Service:   api.NewFileSystem(self.api),
 			Public:    false,
 		},
+		{
+			Namespace: "bzz",
+			Version:   "0.1",
+			Service:   self.uploads,
+			Public:    false,
+		},
 		// {Namespace, Version, api.NewAdmin(self), false},
 	}
+	if self.quotas != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "bzz",
+			Version:   "0.1",
+			Service:   api.NewQuotaControl(self.quotas),
+			Public:    false,
+		})
+	}
+	return apis
 }
 
 func (self *Swarm) Api() *api.Api {
@@ -427,7 +510,7 @@ func (self *Swarm) SetChequebook(ctx context.Context) error {
 		return err
 	}
 	log.Info(fmt.Sprintf("new chequebook set (%v): saving config file, resetting all connections in the hive", self.config.Swap.Contract.Hex()))
-	self.hive.DropAll()
+	self.hives.DropAll()
 	return nil
 }
 
@@ -28,6 +28,12 @@ import (
 )
 
 func NewTestSwarmServer(t *testing.T) *TestSwarmServer {
+	return NewTestSwarmQuotaServer(t, nil)
+}
+
+// NewTestSwarmQuotaServer returns a test server which enforces the given
+// quotas, or none if quotas is nil
+func NewTestSwarmQuotaServer(t *testing.T, quotas *api.Quotas) *TestSwarmServer {
 	dir, err := ioutil.TempDir("", "swarm-storage-test")
 	if err != nil {
 		t.Fatal(err)
@@ -50,7 +56,7 @@ func NewTestSwarmServer(t *testing.T) *TestSwarmServer {
 	}
 	dpa.Start()
 	a := api.NewApi(dpa, nil)
-	srv := httptest.NewServer(httpapi.NewServer(a))
+	srv := httptest.NewServer(httpapi.NewQuotaServer(a, quotas))
 	return &TestSwarmServer{
 		Server: srv,
 		Dpa:    dpa,
@@ -267,7 +267,7 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 	}
 	// Move the trie itself into the batch, flushing if enough data is accumulated
 	nodes, storage := len(db.nodes), db.nodesSize+db.preimagesSize
-	if err := db.commit(node, batch); err != nil {
+	if err := db.commit(node, batch, true); err != nil {
 		log.Error("Failed to commit trie from trie database", "err", err)
 		db.lock.RUnlock()
 		return err
@@ -302,15 +302,58 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 	return nil
 }
 
-// commit is the private locked version of Commit.
-func (db *Database) commit(hash common.Hash, batch mandb.Batch) error {
+// CommitBatch writes all the nodes of a trie, along with the accumulated
+// pre-images, into the given batch without ever flushing it, so that the state
+// of a block can be persisted atomically with the block itself in a single
+// database write. The nodes stay in the memory cache until the returned
+// function is called, which must only happen once the batch was written.
+func (db *Database) CommitBatch(node common.Hash, batch mandb.Batch) (func(), error) {
+	db.lock.RLock()
+
+	start := time.Now()
+	preimages := make([]common.Hash, 0, len(db.preimages))
+	for hash, preimage := range db.preimages {
+		if err := batch.Put(db.secureKey(hash[:]), preimage); err != nil {
+			log.Error("Failed to batch preimage from trie database", "err", err)
+			db.lock.RUnlock()
+			return nil, err
+		}
+		preimages = append(preimages, hash)
+	}
+	size := batch.ValueSize()
+	if err := db.commit(node, batch, false); err != nil {
+		log.Error("Failed to batch trie from trie database", "err", err)
+		db.lock.RUnlock()
+		return nil, err
+	}
+	log.Debug("Batched trie from memory database", "size", common.StorageSize(batch.ValueSize()-size), "preimages", len(preimages), "time", time.Since(start))
+	db.lock.RUnlock()
+
+	uncache := func() {
+		db.lock.Lock()
+		defer db.lock.Unlock()
+
+		for _, hash := range preimages {
+			if preimage, ok := db.preimages[hash]; ok {
+				delete(db.preimages, hash)
+				db.preimagesSize -= common.StorageSize(common.HashLength + len(preimage))
+			}
+		}
+		db.uncache(node)
+	}
+	return uncache, nil
+}
+
+// commit is the private locked version of Commit. Unless flush is set, the
+// batch is never written out, regardless of its size.
+func (db *Database) commit(hash common.Hash, batch mandb.Batch, flush bool) error {
 	// If the node does not exist, it's a previously committed node
 	node, ok := db.nodes[hash]
 	if !ok {
 		return nil
 	}
 	for child := range node.children {
-		if err := db.commit(child, batch); err != nil {
+		if err := db.commit(child, batch, flush); err != nil {
 			return err
 		}
 	}
@@ -318,7 +361,7 @@ func (db *Database) commit(hash common.Hash, batch mandb.Batch) error {
 		return err
 	}
 	// If we've reached an optimal match size, commit and start over
-	if batch.ValueSize() >= mandb.IdealBatchSize {
+	if flush && batch.ValueSize() >= mandb.IdealBatchSize {
 		if err := batch.Write(); err != nil {
 			return err
 		}
@@ -26,12 +26,17 @@ import (
 	"github.com/matrix/go-matrix/rlp"
 )
 
+// parallelHashThreshold is the number of trie updates after which the children
+// of the root node are hashed concurrently.
+const parallelHashThreshold = 100
+
 type hasher struct {
 	tmp        *bytes.Buffer
 	sha        hash.Hash
 	cachegen   uint16
 	cachelimit uint16
 	onleaf     LeafCallback
+	parallel   bool // Whether to hash the children of the next full node concurrently
 }
 
 // hashers live in a global db.
@@ -43,7 +48,7 @@ var hasherPool = sync.Pool{
 
 func newHasher(cachegen, cachelimit uint16, onleaf LeafCallback) *hasher {
 	h := hasherPool.Get().(*hasher)
-	h.cachegen, h.cachelimit, h.onleaf = cachegen, cachelimit, onleaf
+	h.cachegen, h.cachelimit, h.onleaf, h.parallel = cachegen, cachelimit, onleaf, false
 	return h
 }
 
@@ -125,6 +130,12 @@ func (h *hasher) hashChildren(original node, db *Database) (node, node, error) {
 		// Hash the full node's children, caching the newly hashed subtrees
 		collapsed, cached := n.copy(), n.copy()
 
+		if h.parallel {
+			if err := h.hashChildrenParallel(n, collapsed, cached, db); err != nil {
+				return original, original, err
+			}
+			return collapsed, cached, nil
+		}
 		for i := 0; i < 16; i++ {
 			if n.Children[i] != nil {
 				collapsed.Children[i], cached.Children[i], err = h.hash(n.Children[i], db, false)
@@ -147,6 +158,52 @@ func (h *hasher) hashChildren(original node, db *Database) (node, node, error) {
 	}
 }
 
+// hashChildrenParallel hashes the children of a full node concurrently, one
+// goroutine per subtrie, filling in the collapsed and cached copies of the node.
+// Leaf callbacks are serialized, since callers don't expect concurrent calls.
+func (h *hasher) hashChildrenParallel(n, collapsed, cached *fullNode, db *Database) error {
+	onleaf := h.onleaf
+	if onleaf != nil {
+		var lock sync.Mutex
+		onleaf = func(leaf []byte, parent common.Hash) error {
+			lock.Lock()
+			defer lock.Unlock()
+			return h.onleaf(leaf, parent)
+		}
+	}
+	var (
+		pend sync.WaitGroup
+		errs [16]error
+	)
+	for i := 0; i < 16; i++ {
+		if n.Children[i] == nil {
+			collapsed.Children[i] = valueNode(nil) // Ensure that nil children are encoded as empty strings.
+			continue
+		}
+		pend.Add(1)
+		go func(i int) {
+			defer pend.Done()
+
+			hasher := newHasher(h.cachegen, h.cachelimit, onleaf)
+			defer returnHasherToPool(hasher)
+
+			collapsed.Children[i], cached.Children[i], errs[i] = hasher.hash(n.Children[i], db, false)
+		}(i)
+	}
+	pend.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	cached.Children[16] = n.Children[16]
+	if collapsed.Children[16] == nil {
+		collapsed.Children[16] = valueNode(nil)
+	}
+	return nil
+}
+
 // store hashes the node n and if we have a storage layer specified, it writes
 // the key/value pair to it and tracks any node->child references as well as any
 // node->external trie references.
@@ -0,0 +1,151 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package proof
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/params"
+	"github.com/matrix/go-matrix/rlp"
+	"github.com/matrix/go-matrix/trie"
+)
+
+var (
+	// emptyRoot is the root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCodeHash is the code hash of accounts without code.
+	emptyCodeHash = crypto.Keccak256(nil)
+)
+
+// Account is the consensus representation of an account in the state trie.
+type Account struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash // merkle root of the storage trie
+	CodeHash []byte
+}
+
+// VerifyAccountProof checks the merkle proof of an account against a state
+// root and returns the account, or nil if the proof shows it doesn't exist.
+func VerifyAccountProof(stateRoot common.Hash, address common.Address, proof trie.DatabaseReader) (*Account, error) {
+	enc, err := VerifyProof(stateRoot, crypto.Keccak256(address.Bytes()), proof)
+	if err != nil || enc == nil {
+		return nil, err
+	}
+	account := new(Account)
+	if err := rlp.DecodeBytes(enc, account); err != nil {
+		return nil, fmt.Errorf("invalid account %x: %v", address, err)
+	}
+	return account, nil
+}
+
+// VerifyStorageProof checks the merkle proof of a storage slot against the
+// storage root of an account and returns the slot value. Slots missing from
+// the trie hold the zero value.
+func VerifyStorageProof(storageRoot common.Hash, slot common.Hash, proof trie.DatabaseReader) (common.Hash, error) {
+	// An empty trie has no nodes to prove with
+	if storageRoot == emptyRoot {
+		return common.Hash{}, nil
+	}
+	enc, err := VerifyProof(storageRoot, crypto.Keccak256(slot.Bytes()), proof)
+	if err != nil || enc == nil {
+		return common.Hash{}, err
+	}
+	_, content, _, err := rlp.Split(enc)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid storage slot %x: %v", slot, err)
+	}
+	return common.BytesToHash(content), nil
+}
+
+// AccountResult is the reply of the man_getProof RPC method: the merkle proof
+// of an account along with the proofs of the requested storage slots.
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []string        `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is the merkle proof of a single storage slot.
+type StorageResult struct {
+	Key   string       `json:"key"`
+	Value *hexutil.Big `json:"value"`
+	Proof []string     `json:"proof"`
+}
+
+// Verify checks the account and all storage slots of the reply against a state
+// root, usually taken from a trusted block header. The reported fields must
+// match the proven ones exactly.
+func (r *AccountResult) Verify(stateRoot common.Hash) error {
+	proof, err := NodeSetFromHex(r.AccountProof)
+	if err != nil {
+		return fmt.Errorf("invalid account proof: %v", err)
+	}
+	account, err := VerifyAccountProof(stateRoot, r.Address, proof)
+	if err != nil {
+		return err
+	}
+	// Non-existent accounts are reported as empty ones, with the nonce offset
+	if account == nil {
+		account = &Account{Nonce: params.NonceAddOne, Balance: new(big.Int), Root: emptyRoot, CodeHash: emptyCodeHash}
+	}
+	if uint64(r.Nonce) != account.Nonce {
+		return fmt.Errorf("nonce mismatch: have %d, proven %d", r.Nonce, account.Nonce)
+	}
+	if r.Balance == nil || r.Balance.ToInt().Cmp(account.Balance) != 0 {
+		return fmt.Errorf("balance mismatch: have %v, proven %v", r.Balance, account.Balance)
+	}
+	if r.StorageHash != account.Root {
+		return fmt.Errorf("storage hash mismatch: have %x, proven %x", r.StorageHash, account.Root)
+	}
+	if !bytes.Equal(r.CodeHash[:], account.CodeHash) {
+		return fmt.Errorf("code hash mismatch: have %x, proven %x", r.CodeHash, account.CodeHash)
+	}
+	for _, slot := range r.StorageProof {
+		if err := slot.Verify(r.StorageHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify checks the storage slot of the reply against the storage root of the
+// account.
+func (r *StorageResult) Verify(storageRoot common.Hash) error {
+	proof, err := NodeSetFromHex(r.Proof)
+	if err != nil {
+		return fmt.Errorf("invalid storage proof of slot %s: %v", r.Key, err)
+	}
+	value, err := VerifyStorageProof(storageRoot, common.HexToHash(r.Key), proof)
+	if err != nil {
+		return err
+	}
+	if r.Value == nil || r.Value.ToInt().Cmp(value.Big()) != 0 {
+		return fmt.Errorf("value mismatch of slot %s: have %v, proven %x", r.Key, r.Value, value)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package proof
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/crypto"
+)
+
+var errNotFound = errors.New("proof node not found")
+
+// NodeSet is an in-memory set of trie nodes making up one or more merkle
+// proofs, keyed by node hash. It can be filled by a prover and handed to any of
+// the verification functions. The nodes are kept in insertion order, which for
+// a single proof is the path from the root down to the key.
+type NodeSet struct {
+	nodes map[string][]byte
+	order []string
+	lock  sync.RWMutex
+}
+
+// NewNodeSet creates an empty proof node set.
+func NewNodeSet() *NodeSet {
+	return &NodeSet{nodes: make(map[string][]byte)}
+}
+
+// NodeSetFromHex creates a node set from the hex encoded nodes of a proof, as
+// returned by the man_getProof RPC method.
+func NodeSetFromHex(nodes []string) (*NodeSet, error) {
+	set := NewNodeSet()
+	for _, node := range nodes {
+		blob, err := hexutil.Decode(node)
+		if err != nil {
+			return nil, err
+		}
+		set.Put(crypto.Keccak256(blob), blob)
+	}
+	return set, nil
+}
+
+// Put inserts a proof node. Nodes already in the set are ignored.
+func (s *NodeSet) Put(key []byte, value []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.nodes[string(key)]; ok {
+		return nil
+	}
+	s.nodes[string(key)] = common.CopyBytes(value)
+	s.order = append(s.order, string(key))
+	return nil
+}
+
+// Get retrieves the proof node with the given hash.
+func (s *NodeSet) Get(key []byte) ([]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if node, ok := s.nodes[string(key)]; ok {
+		return common.CopyBytes(node), nil
+	}
+	return nil, errNotFound
+}
+
+// Has reports whether the set contains the proof node with the given hash.
+func (s *NodeSet) Has(key []byte) (bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	_, ok := s.nodes[string(key)]
+	return ok, nil
+}
+
+// Len returns the number of nodes in the set.
+func (s *NodeSet) Len() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return len(s.nodes)
+}
+
+// Nodes returns the proof nodes in insertion order.
+func (s *NodeSet) Nodes() [][]byte {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	nodes := make([][]byte, len(s.order))
+	for i, key := range s.order {
+		nodes[i] = common.CopyBytes(s.nodes[key])
+	}
+	return nodes
+}
+
+// Hex returns the hex encoded proof nodes in insertion order, in the format of
+// the man_getProof RPC method.
+func (s *NodeSet) Hex() []string {
+	nodes := s.Nodes()
+	hex := make([]string, len(nodes))
+	for i, node := range nodes {
+		hex[i] = hexutil.Encode(node)
+	}
+	return hex
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package proof provides merkle proof building and verification for the
+// MATRIX tries, usable without a running node.
+//
+// Proofs are sets of trie nodes keyed by their hash. They can be built from any
+// trie implementing Prover, or decoded from the reply of the man_getProof RPC
+// method, and verified against a known trie root such as the state root of a
+// block header:
+//
+//	var result proof.AccountResult
+//	client.Call(&result, "man_getProof", address, slots, "latest")
+//	if err := result.Verify(header.Root); err != nil {
+//		// the node served a bad proof
+//	}
+package proof
+
+import (
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/mandb"
+	"github.com/matrix/go-matrix/trie"
+)
+
+// Prover is a trie able to build merkle proofs of its keys, such as trie.Trie
+// and trie.SecureTrie.
+type Prover interface {
+	Prove(key []byte, fromLevel uint, proofDb mandb.Putter) error
+}
+
+// Prove builds the merkle proof of a key in the trie. The key doesn't need to
+// be present, in which case the proof shows its absence.
+func Prove(p Prover, key []byte) (*NodeSet, error) {
+	set := NewNodeSet()
+	if err := p.Prove(key, 0, set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// ProveRange builds the proof of the two edges of a key range, as needed by
+// VerifyRangeProof.
+func ProveRange(p Prover, firstKey []byte, lastKey []byte) (*NodeSet, error) {
+	set := NewNodeSet()
+	if err := p.Prove(firstKey, 0, set); err != nil {
+		return nil, err
+	}
+	if err := p.Prove(lastKey, 0, set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// VerifyProof checks the merkle proof of a key against the trie root and
+// returns the value of the key, or nil if the proof shows the key is absent. An
+// error is returned if the proof is invalid or incomplete.
+func VerifyProof(root common.Hash, key []byte, proof trie.DatabaseReader) ([]byte, error) {
+	value, _, err := trie.VerifyProof(root, key, proof)
+	return value, err
+}
+
+// VerifyRangeProof checks that keys and values are exactly the leaves of the
+// trie between firstKey and lastKey, both inclusive, given the edge proofs
+// built by ProveRange. A nil proof requires the range to be the complete trie.
+// The returned flag reports whether the trie has more leaves after the range.
+func VerifyRangeProof(root common.Hash, firstKey []byte, lastKey []byte, keys [][]byte, values [][]byte, proof trie.DatabaseReader) (bool, error) {
+	return trie.VerifyRangeProof(root, firstKey, lastKey, keys, values, proof)
+}
@@ -0,0 +1,212 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package proof
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/core/state"
+	"github.com/matrix/go-matrix/crypto"
+	"github.com/matrix/go-matrix/mandb"
+	"github.com/matrix/go-matrix/trie"
+)
+
+// makeTrie creates a trie with n sequential keys and returns them sorted.
+func makeTrie(n int) (*trie.Trie, [][]byte, [][]byte) {
+	tr, _ := trie.New(common.Hash{}, trie.NewDatabase(mandb.NewMemDatabase()))
+
+	var keys, values [][]byte
+	for i := 0; i < n; i++ {
+		key := crypto.Keccak256(big.NewInt(int64(i)).Bytes())
+		value := big.NewInt(int64(i + 1)).Bytes()
+		tr.Update(key, value)
+		keys, values = append(keys, key), append(values, value)
+	}
+	sort.Sort(&kvSorter{keys, values})
+	return tr, keys, values
+}
+
+type kvSorter struct{ keys, values [][]byte }
+
+func (s *kvSorter) Len() int           { return len(s.keys) }
+func (s *kvSorter) Less(i, j int) bool { return bytes.Compare(s.keys[i], s.keys[j]) < 0 }
+func (s *kvSorter) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.values[i], s.values[j] = s.values[j], s.values[i]
+}
+
+func TestProve(t *testing.T) {
+	tr, keys, values := makeTrie(256)
+	root := tr.Hash()
+
+	for i, key := range keys {
+		proof, err := Prove(tr, key)
+		if err != nil {
+			t.Fatalf("key %x: failed to prove: %v", key, err)
+		}
+		// Round trip the proof through its RPC encoding
+		proof, err = NodeSetFromHex(proof.Hex())
+		if err != nil {
+			t.Fatalf("key %x: failed to decode proof: %v", key, err)
+		}
+		value, err := VerifyProof(root, key, proof)
+		if err != nil {
+			t.Fatalf("key %x: failed to verify proof: %v", key, err)
+		}
+		if !bytes.Equal(value, values[i]) {
+			t.Fatalf("key %x: value mismatch: have %x, want %x", key, value, values[i])
+		}
+	}
+	// Absent keys are proven to be missing
+	missing := crypto.Keccak256([]byte("missing"))
+	proof, err := Prove(tr, missing)
+	if err != nil {
+		t.Fatalf("failed to prove missing key: %v", err)
+	}
+	if value, err := VerifyProof(root, missing, proof); err != nil || value != nil {
+		t.Fatalf("missing key: have %x, %v, want nil value", value, err)
+	}
+	// Proofs don't verify against other roots
+	proof, _ = Prove(tr, keys[0])
+	if _, err := VerifyProof(common.Hash{1}, keys[0], proof); err == nil {
+		t.Fatalf("proof verified against a wrong root")
+	}
+}
+
+func TestProveRange(t *testing.T) {
+	tr, keys, values := makeTrie(256)
+	root := tr.Hash()
+
+	proof, err := ProveRange(tr, keys[10], keys[99])
+	if err != nil {
+		t.Fatalf("failed to prove range: %v", err)
+	}
+	more, err := VerifyRangeProof(root, keys[10], keys[99], keys[10:100], values[10:100], proof)
+	if err != nil {
+		t.Fatalf("failed to verify range: %v", err)
+	}
+	if !more {
+		t.Fatalf("more entries not reported")
+	}
+	// Dropping a leaf from the middle of the range must be detected
+	gapped := append(append([][]byte{}, keys[10:50]...), keys[51:100]...)
+	gappedValues := append(append([][]byte{}, values[10:50]...), values[51:100]...)
+	if _, err := VerifyRangeProof(root, keys[10], keys[99], gapped, gappedValues, proof); err == nil {
+		t.Fatalf("range with a missing leaf verified")
+	}
+	// The whole trie verifies without edge proofs
+	if _, err := VerifyRangeProof(root, nil, nil, keys, values, nil); err != nil {
+		t.Fatalf("failed to verify whole trie: %v", err)
+	}
+}
+
+// getProof assembles a man_getProof reply from a committed state.
+func getProof(t *testing.T, statedb *state.StateDB, address common.Address, slots []common.Hash) *AccountResult {
+	accountProof, err := statedb.GetProof(address)
+	if err != nil {
+		t.Fatalf("failed to prove account: %v", err)
+	}
+	result := &AccountResult{
+		Address:      address,
+		AccountProof: toHex(accountProof),
+		Balance:      (*hexutil.Big)(statedb.GetBalance(address)),
+		CodeHash:     crypto.Keccak256Hash(nil),
+		Nonce:        hexutil.Uint64(statedb.GetNonce(address)),
+		StorageHash:  emptyRoot,
+	}
+	if tr := statedb.StorageTrie(address); tr != nil {
+		result.CodeHash = statedb.GetCodeHash(address)
+		result.StorageHash = tr.Hash()
+	}
+	for _, slot := range slots {
+		proof, err := statedb.GetStorageProof(address, slot)
+		if err != nil {
+			t.Fatalf("failed to prove slot %x: %v", slot, err)
+		}
+		result.StorageProof = append(result.StorageProof, StorageResult{
+			Key:   slot.Hex(),
+			Value: (*hexutil.Big)(statedb.GetState(address, slot).Big()),
+			Proof: toHex(proof),
+		})
+	}
+	return result
+}
+
+func toHex(nodes [][]byte) []string {
+	hex := make([]string, len(nodes))
+	for i, node := range nodes {
+		hex[i] = hexutil.Encode(node)
+	}
+	return hex
+}
+
+func TestAccountResult(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(mandb.NewMemDatabase()))
+
+	var (
+		contract = common.HexToAddress("0x01")
+		plain    = common.HexToAddress("0x02")
+		missing  = common.HexToAddress("0x03")
+		slots    = []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03")}
+	)
+	statedb.SetBalance(contract, big.NewInt(1000))
+	statedb.SetNonce(contract, 7)
+	statedb.SetCode(contract, []byte{0x60, 0x00})
+	statedb.SetState(contract, slots[0], common.HexToHash("0xdead"))
+	statedb.SetState(contract, slots[1], common.HexToHash("0xbeef"))
+	statedb.SetBalance(plain, big.NewInt(42))
+
+	root, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	for _, address := range []common.Address{contract, plain, missing} {
+		var keys []common.Hash
+		if address == contract {
+			keys = slots
+		}
+		result := getProof(t, statedb, address, keys)
+		if err := result.Verify(root); err != nil {
+			t.Fatalf("account %x: failed to verify: %v", address, err)
+		}
+	}
+	// Tampered fields must be detected
+	result := getProof(t, statedb, contract, slots)
+	result.Balance = (*hexutil.Big)(big.NewInt(1001))
+	if err := result.Verify(root); err == nil {
+		t.Fatalf("tampered balance verified")
+	}
+	result = getProof(t, statedb, contract, slots)
+	result.StorageProof[1].Value = (*hexutil.Big)(big.NewInt(1))
+	if err := result.Verify(root); err == nil {
+		t.Fatalf("tampered storage value verified")
+	}
+	result = getProof(t, statedb, contract, slots)
+	result.StorageProof[2].Value = (*hexutil.Big)(big.NewInt(1))
+	if err := result.Verify(root); err == nil {
+		t.Fatalf("tampered missing storage slot verified")
+	}
+	result = getProof(t, statedb, plain, nil)
+	if err := result.Verify(common.Hash{1}); err == nil {
+		t.Fatalf("proof verified against a wrong root")
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	crand "crypto/rand"
 	mrand "math/rand"
+	"sort"
 	"testing"
 	"time"
 
@@ -144,6 +145,188 @@ func TestMissingKeyProof(t *testing.T) {
 }
 
 // mutateByte changes one byte in b.
+// sortedEntries returns the leaves of a random trie ordered by key.
+func sortedEntries(vals map[string]*kv) []*kv {
+	entries := make([]*kv, 0, len(vals))
+	for _, kv := range vals {
+		entries = append(entries, kv)
+	}
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].k, entries[j].k) < 0 })
+	return entries
+}
+
+// rangeProof proves the edges of entries[start:end] and splits the range.
+func rangeProof(t *testing.T, trie *Trie, entries []*kv, start, end int, first, last []byte) (*mandb.MemDatabase, [][]byte, [][]byte) {
+	proof := mandb.NewMemDatabase()
+	if err := trie.Prove(first, 0, proof); err != nil {
+		t.Fatalf("failed to prove the first edge %x: %v", first, err)
+	}
+	if err := trie.Prove(last, 0, proof); err != nil {
+		t.Fatalf("failed to prove the last edge %x: %v", last, err)
+	}
+	var keys, values [][]byte
+	for _, kv := range entries[start:end] {
+		keys = append(keys, kv.k)
+		values = append(values, kv.v)
+	}
+	return proof, keys, values
+}
+
+func TestRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+	root := trie.Hash()
+
+	for i := 0; i < 500; i++ {
+		start := mrand.Intn(len(entries))
+		end := start + 1 + mrand.Intn(len(entries)-start)
+
+		proof, keys, values := rangeProof(t, trie, entries, start, end, entries[start].k, entries[end-1].k)
+		more, err := VerifyRangeProof(root, entries[start].k, entries[end-1].k, keys, values, proof)
+		if err != nil {
+			t.Fatalf("range %d-%d: failed to verify proof: %v", start, end, err)
+		}
+		if more != (end < len(entries)) {
+			t.Fatalf("range %d-%d: more entries mismatch: have %v", start, end, more)
+		}
+	}
+}
+
+func TestRangeProofWithNonExistentEdges(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+	root := trie.Hash()
+
+	for i := 0; i < 500; i++ {
+		start := 1 + mrand.Intn(len(entries)-2)
+		end := start + 1 + mrand.Intn(len(entries)-start-1)
+
+		// Pick edges in the gaps around the range
+		first := decrease(common.CopyBytes(entries[start].k))
+		if bytes.Compare(first, entries[start-1].k) <= 0 {
+			continue
+		}
+		last := increase(common.CopyBytes(entries[end-1].k))
+		if bytes.Compare(last, entries[end].k) >= 0 {
+			continue
+		}
+		proof, keys, values := rangeProof(t, trie, entries, start, end, first, last)
+		more, err := VerifyRangeProof(root, first, last, keys, values, proof)
+		if err != nil {
+			t.Fatalf("range %d-%d: failed to verify proof: %v", start, end, err)
+		}
+		if !more {
+			t.Fatalf("range %d-%d: more entries not reported", start, end)
+		}
+	}
+}
+
+func TestRangeProofWholeTrie(t *testing.T) {
+	trie, vals := randomTrie(1024)
+	entries := sortedEntries(vals)
+	root := trie.Hash()
+
+	var keys, values [][]byte
+	for _, kv := range entries {
+		keys = append(keys, kv.k)
+		values = append(values, kv.v)
+	}
+	// Without any proof, the range must be the complete trie
+	if _, err := VerifyRangeProof(root, nil, nil, keys, values, nil); err != nil {
+		t.Fatalf("failed to verify whole trie: %v", err)
+	}
+	if _, err := VerifyRangeProof(root, nil, nil, keys[1:], values[1:], nil); err == nil {
+		t.Fatalf("partial trie verified without proof")
+	}
+	// With edge proofs of the outermost leaves
+	proof, _, _ := rangeProof(t, trie, entries, 0, len(entries), keys[0], keys[len(keys)-1])
+	more, err := VerifyRangeProof(root, keys[0], keys[len(keys)-1], keys, values, proof)
+	if err != nil {
+		t.Fatalf("failed to verify whole trie with edge proofs: %v", err)
+	}
+	if more {
+		t.Fatalf("more entries reported after the last leaf")
+	}
+}
+
+func TestEmptyRangeProof(t *testing.T) {
+	trie, vals := randomTrie(1024)
+	entries := sortedEntries(vals)
+	root := trie.Hash()
+
+	// Past the last leaf, an empty range is valid
+	first := increase(common.CopyBytes(entries[len(entries)-1].k))
+	proof := mandb.NewMemDatabase()
+	trie.Prove(first, 0, proof)
+	if _, err := VerifyRangeProof(root, first, nil, nil, nil, proof); err != nil {
+		t.Fatalf("failed to verify empty range: %v", err)
+	}
+	// Before it, leaves are hidden
+	first = decrease(common.CopyBytes(entries[len(entries)-1].k))
+	proof = mandb.NewMemDatabase()
+	trie.Prove(first, 0, proof)
+	if _, err := VerifyRangeProof(root, first, nil, nil, nil, proof); err == nil {
+		t.Fatalf("empty range verified with leaves remaining")
+	}
+}
+
+func TestBadRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+	root := trie.Hash()
+
+	for i := 0; i < 500; i++ {
+		start := mrand.Intn(len(entries))
+		end := start + 1 + mrand.Intn(len(entries)-start)
+		if end-start < 3 {
+			continue
+		}
+		proof, keys, values := rangeProof(t, trie, entries, start, end, entries[start].k, entries[end-1].k)
+
+		index := 1 + mrand.Intn(len(keys)-2)
+		switch mrand.Intn(4) {
+		case 0:
+			// Modified value
+			values[index] = randBytes(20)
+		case 1:
+			// Missing leaf
+			keys = append(keys[:index:index], keys[index+1:]...)
+			values = append(values[:index:index], values[index+1:]...)
+		case 2:
+			// Unordered leaves
+			keys[index], keys[index+1] = keys[index+1], keys[index]
+		case 3:
+			// Deleted leaf
+			values[index] = nil
+		}
+		if _, err := VerifyRangeProof(root, entries[start].k, entries[end-1].k, keys, values, proof); err == nil {
+			t.Fatalf("range %d-%d: bad range verified", start, end)
+		}
+	}
+}
+
+// increase returns the key incremented by one, wrapping on overflow.
+func increase(key []byte) []byte {
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]++
+		if key[i] != 0x0 {
+			break
+		}
+	}
+	return key
+}
+
+// decrease returns the key decremented by one, wrapping on underflow.
+func decrease(key []byte) []byte {
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]--
+		if key[i] != 0xff {
+			break
+		}
+	}
+	return key
+}
+
 func mutateByte(b []byte) {
 	for r := mrand.Intn(len(b)); ; {
 		new := byte(mrand.Intn(255))
@@ -0,0 +1,267 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package trie
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/crypto/sha3"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/mandb"
+	"github.com/matrix/go-matrix/rlp"
+)
+
+var (
+	errStackTrieOrder  = errors.New("stack trie keys not in ascending order")
+	errStackTriePrefix = errors.New("stack trie key is a prefix of another key")
+	errStackTrieHashed = errors.New("stack trie already hashed")
+)
+
+// kinds of stack trie nodes
+const (
+	emptyStackNode = iota
+	branchStackNode
+	extStackNode
+	leafStackNode
+	hashedStackNode
+)
+
+// stackNode is a node of a stack trie. Hashed nodes keep the reference their
+// parent embeds only, the node itself when smaller than a hash or the RLP
+// encoded hash otherwise.
+type stackNode struct {
+	kind     uint8
+	key      []byte         // nibbles of the key below the node, leaf and extension nodes only
+	val      []byte         // value of a leaf, reference of a hashed node
+	children [16]*stackNode // children of a branch, the child of an extension is the first one
+}
+
+// StackTrie builds a trie from keys inserted in ascending order, like the
+// accounts of the genesis or the transactions of a block. Only the path to the
+// last key is kept in memory: every subtrie left of it is final and collapsed
+// into its hash as soon as a greater key is inserted, so memory use doesn't
+// grow with the number of keys. The nodes are written to the database, if
+// any, as they are hashed, without going through a trie database.
+//
+// Keys must not be a prefix of each other, which holds for keys of the same
+// length and for RLP encoded ones.
+type StackTrie struct {
+	root *stackNode
+	last []byte       // last key inserted
+	hash *common.Hash // root hash, once computed the trie can't be updated
+	db   mandb.Putter // receives the nodes, nil to only compute the hash
+	sha  hash.Hash
+	err  error // first database error
+}
+
+// NewStackTrie creates an empty stack trie writing its nodes to db, which
+// may be nil.
+func NewStackTrie(db mandb.Putter) *StackTrie {
+	return &StackTrie{
+		root: new(stackNode),
+		db:   db,
+		sha:  sha3.NewKeccak256(),
+	}
+}
+
+// Update inserts key with value into the trie, logging any error.
+func (t *StackTrie) Update(key, value []byte) {
+	if err := t.TryUpdate(key, value); err != nil {
+		log.Error(fmt.Sprintf("Unhandled trie error: %v", err))
+	}
+}
+
+// TryUpdate inserts key with value into the trie. Keys must be greater than
+// all keys inserted before, empty values are skipped.
+func (t *StackTrie) TryUpdate(key, value []byte) error {
+	if t.hash != nil {
+		return errStackTrieHashed
+	}
+	if t.last != nil && bytes.Compare(key, t.last) <= 0 {
+		return errStackTrieOrder
+	}
+	t.last = common.CopyBytes(key)
+	if len(value) == 0 {
+		return nil
+	}
+	hex := keybytesToHex(key)
+	return t.insert(t.root, hex[:len(hex)-1], common.CopyBytes(value))
+}
+
+// Hash returns the root hash of the trie. The trie can't be updated afterwards.
+func (t *StackTrie) Hash() common.Hash {
+	if t.hash != nil {
+		return *t.hash
+	}
+	root := emptyRoot
+	if t.root.kind != emptyStackNode {
+		t.hashNode(t.root)
+		if ref := t.root.val; len(ref) < 32 {
+			// the root is hashed even if small, and stored by its hash
+			root = common.BytesToHash(t.keccak(ref))
+			t.write(root, ref)
+		} else {
+			root = common.BytesToHash(ref[1:])
+		}
+	}
+	t.root = nil
+	t.hash = &root
+	return root
+}
+
+// Commit hashes the trie, returning the root hash and the first error writing
+// the nodes to the database.
+func (t *StackTrie) Commit() (common.Hash, error) {
+	root := t.Hash()
+	return root, t.err
+}
+
+// insert adds a value at the key nibbles below the node n
+func (t *StackTrie) insert(n *stackNode, key, value []byte) error {
+	switch n.kind {
+	case emptyStackNode:
+		n.kind, n.key, n.val = leafStackNode, key, value
+
+	case branchStackNode:
+		if len(key) == 0 {
+			return errStackTriePrefix
+		}
+		idx := key[0]
+		// the closest child left of the key is complete now
+		for i := int(idx) - 1; i >= 0; i-- {
+			if child := n.children[i]; child != nil {
+				t.hashNode(child)
+				break
+			}
+		}
+		if n.children[idx] == nil {
+			n.children[idx] = &stackNode{kind: leafStackNode, key: key[1:], val: value}
+			return nil
+		}
+		return t.insert(n.children[idx], key[1:], value)
+
+	case extStackNode:
+		d := prefixLen(n.key, key)
+		if d == len(n.key) {
+			return t.insert(n.children[0], key[d:], value)
+		}
+		if d == len(key) {
+			return errStackTriePrefix
+		}
+		// the key branches off within the extension, the part past the
+		// branching nibble is complete
+		orig := n.children[0]
+		if d < len(n.key)-1 {
+			orig = &stackNode{kind: extStackNode, key: n.key[d+1:], children: [16]*stackNode{orig}}
+		}
+		t.hashNode(orig)
+		t.split(n, d, orig, key, value)
+
+	case leafStackNode:
+		d := prefixLen(n.key, key)
+		if d == len(n.key) || d == len(key) {
+			return errStackTriePrefix
+		}
+		orig := &stackNode{kind: leafStackNode, key: n.key[d+1:], val: n.val}
+		t.hashNode(orig)
+		t.split(n, d, orig, key, value)
+
+	default:
+		return errStackTrieOrder
+	}
+	return nil
+}
+
+// split turns the leaf or extension node n into a branch at d nibbles into
+// its key, below an extension of the common nibbles if any. The branch gets
+// orig at the node's nibble and a new leaf at the key's.
+func (t *StackTrie) split(n *stackNode, d int, orig *stackNode, key, value []byte) {
+	branch := &stackNode{kind: branchStackNode}
+	branch.children[n.key[d]] = orig
+	branch.children[key[d]] = &stackNode{kind: leafStackNode, key: key[d+1:], val: value}
+	if d == 0 {
+		*n = *branch
+		return
+	}
+	n.kind, n.key, n.val = extStackNode, n.key[:d], nil
+	n.children = [16]*stackNode{branch}
+}
+
+// hashNode collapses the node into the reference its parent embeds, writing
+// it to the database if it is referenced by hash
+func (t *StackTrie) hashNode(n *stackNode) {
+	var enc []byte
+	switch n.kind {
+	case hashedStackNode:
+		return
+
+	case leafStackNode:
+		key := append(append([]byte{}, n.key...), 16)
+		enc, _ = rlp.EncodeToBytes([]interface{}{hexToCompact(key), n.val})
+
+	case extStackNode:
+		enc, _ = rlp.EncodeToBytes([]interface{}{hexToCompact(n.key), t.ref(n.children[0])})
+
+	case branchStackNode:
+		var children [17]interface{}
+		for i, child := range n.children {
+			if child == nil {
+				children[i] = []byte{}
+			} else {
+				children[i] = t.ref(child)
+			}
+		}
+		children[16] = []byte{}
+		enc, _ = rlp.EncodeToBytes(children[:])
+
+	default:
+		panic(fmt.Sprintf("invalid stack trie node kind %d", n.kind))
+	}
+	n.kind, n.key, n.children = hashedStackNode, nil, [16]*stackNode{}
+	if len(enc) < 32 {
+		// nodes smaller than a hash are embedded in their parent
+		n.val = enc
+		return
+	}
+	hash := t.keccak(enc)
+	t.write(common.BytesToHash(hash), enc)
+	n.val, _ = rlp.EncodeToBytes(hash)
+}
+
+// ref hashes a child node, returning the reference its parent embeds
+func (t *StackTrie) ref(n *stackNode) rlp.RawValue {
+	t.hashNode(n)
+	return rlp.RawValue(n.val)
+}
+
+func (t *StackTrie) keccak(data []byte) []byte {
+	t.sha.Reset()
+	t.sha.Write(data)
+	return t.sha.Sum(nil)
+}
+
+// write stores a node in the database, remembering the first error
+func (t *StackTrie) write(hash common.Hash, enc []byte) {
+	if t.db == nil || t.err != nil {
+		return
+	}
+	t.err = t.db.Put(hash[:], enc)
+}
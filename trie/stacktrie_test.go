@@ -0,0 +1,127 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package trie
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/matrix/go-matrix/mandb"
+)
+
+// randomSortedEntries returns n random key-value pairs with keys of the given
+// length in ascending order. Short values make for embedded nodes.
+func randomSortedEntries(rng *rand.Rand, n, keyLen int) (keys, values [][]byte) {
+	seen := make(map[string]bool)
+	for len(keys) < n {
+		key := make([]byte, keyLen)
+		rng.Read(key)
+		if seen[string(key)] {
+			continue
+		}
+		seen[string(key)] = true
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+	for range keys {
+		value := make([]byte, 1+rng.Intn(40))
+		rng.Read(value)
+		values = append(values, value)
+	}
+	return keys, values
+}
+
+func TestStackTrieHash(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, keyLen := range []int{1, 2, 4, 32} {
+		for _, n := range []int{0, 1, 2, 3, 16, 100, 1000} {
+			if keyLen == 1 && n > 256 {
+				continue
+			}
+			keys, values := randomSortedEntries(rng, n, keyLen)
+			trie, stack := newEmpty(), NewStackTrie(nil)
+			for i := range keys {
+				trie.Update(keys[i], values[i])
+				if err := stack.TryUpdate(keys[i], values[i]); err != nil {
+					t.Fatalf("%d keys of %d bytes: insert %x: %v", n, keyLen, keys[i], err)
+				}
+			}
+			if have, want := stack.Hash(), trie.Hash(); have != want {
+				t.Errorf("%d keys of %d bytes: have root %x, want %x", n, keyLen, have, want)
+			}
+		}
+	}
+}
+
+func TestStackTrieCommit(t *testing.T) {
+	keys, values := randomSortedEntries(rand.New(rand.NewSource(2)), 500, 32)
+	diskdb := mandb.NewMemDatabase()
+	stack := NewStackTrie(diskdb)
+	for i := range keys {
+		stack.Update(keys[i], values[i])
+	}
+	root, err := stack.Commit()
+	if err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	// the written nodes make up the full trie
+	trie, err := New(root, NewDatabase(diskdb))
+	if err != nil {
+		t.Fatalf("can't open committed trie: %v", err)
+	}
+	for i := range keys {
+		value, err := trie.TryGet(keys[i])
+		if err != nil {
+			t.Fatalf("key %x: %v", keys[i], err)
+		}
+		if !bytes.Equal(value, values[i]) {
+			t.Fatalf("key %x: have value %x, want %x", keys[i], value, values[i])
+		}
+	}
+	// a root smaller than a hash is written as well
+	stack = NewStackTrie(diskdb)
+	stack.Update([]byte{1}, []byte{2})
+	root, _ = stack.Commit()
+	if ok, _ := diskdb.Has(root[:]); !ok {
+		t.Errorf("small root node %x not written", root)
+	}
+}
+
+func TestStackTrieErrors(t *testing.T) {
+	stack := NewStackTrie(nil)
+	if err := stack.TryUpdate([]byte{1, 2}, []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stack.TryUpdate([]byte{1, 1}, []byte{1}); err != errStackTrieOrder {
+		t.Errorf("descending key: have error %v, want %v", err, errStackTrieOrder)
+	}
+	if err := stack.TryUpdate([]byte{1, 2}, []byte{1}); err != errStackTrieOrder {
+		t.Errorf("repeated key: have error %v, want %v", err, errStackTrieOrder)
+	}
+	if err := stack.TryUpdate([]byte{1, 2, 3}, []byte{1}); err != errStackTriePrefix {
+		t.Errorf("extended key: have error %v, want %v", err, errStackTriePrefix)
+	}
+	stack.Hash()
+	if err := stack.TryUpdate([]byte{2}, []byte{1}); err != errStackTrieHashed {
+		t.Errorf("update after hashing: have error %v, want %v", err, errStackTrieHashed)
+	}
+	if root := NewStackTrie(nil).Hash(); root != emptyRoot {
+		t.Errorf("empty trie: have root %x, want %x", root, emptyRoot)
+	}
+}
@@ -74,6 +74,10 @@ type Trie struct {
 	// new nodes are tagged with the current generation and unloaded
 	// when their generation is older than than cachegen-cachelimit.
 	cachegen, cachelimit uint16
+
+	// Number of updates since the last hashing, used to decide whether the
+	// subtries are worth hashing concurrently.
+	unhashed int
 }
 
 // SetCacheLimit sets the number of 'cache generations' to keep.
@@ -198,6 +202,7 @@ func (t *Trie) Update(key, value []byte) {
 //
 // If a node was not found in the database, a MissingNodeError is returned.
 func (t *Trie) TryUpdate(key, value []byte) error {
+	t.unhashed++
 	k := keybytesToHex(key)
 	if len(value) != 0 {
 		_, n, err := t.insert(t.root, nil, k, valueNode(value))
@@ -294,6 +299,7 @@ func (t *Trie) Delete(key []byte) {
 // TryDelete removes any existing value for key from the trie.
 // If a node was not found in the database, a MissingNodeError is returned.
 func (t *Trie) TryDelete(key []byte) error {
+	t.unhashed++
 	k := keybytesToHex(key)
 	_, n, err := t.delete(t.root, nil, k)
 	if err != nil {
@@ -474,5 +480,11 @@ func (t *Trie) hashRoot(db *Database, onleaf LeafCallback) (node, node, error) {
 	}
 	h := newHasher(t.cachegen, t.cachelimit, onleaf)
 	defer returnHasherToPool(h)
+
+	// Large batches of changes touch most subtries of the root, hash them
+	// concurrently in that case
+	h.parallel = t.unhashed >= parallelHashThreshold
+	t.unhashed = 0
+
 	return h.hash(t.root, db, true)
 }
@@ -509,6 +509,80 @@ func TestRandom(t *testing.T) {
 	}
 }
 
+// Tests that hashing the subtries of the root concurrently yields the same root
+// and stores the same nodes as hashing them serially.
+func TestParallelCommit(t *testing.T) {
+	addresses, accounts := makeAccounts(2 * parallelHashThreshold)
+
+	commit := func(parallel bool) (common.Hash, *Database) {
+		triedb := NewDatabase(mandb.NewMemDatabase())
+		trie, _ := New(common.Hash{}, triedb)
+		for i := 0; i < len(addresses); i++ {
+			trie.Update(crypto.Keccak256(addresses[i][:]), accounts[i])
+		}
+		if !parallel {
+			trie.unhashed = 0
+		}
+		var leaves int
+		root, err := trie.Commit(func(leaf []byte, parent common.Hash) error {
+			leaves++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("failed to commit trie: %v", err)
+		}
+		if leaves != len(accounts) {
+			t.Fatalf("leaf callback count mismatch: have %d, want %d", leaves, len(accounts))
+		}
+		return root, triedb
+	}
+	serialRoot, serialDB := commit(false)
+	parallelRoot, parallelDB := commit(true)
+
+	if serialRoot != parallelRoot {
+		t.Fatalf("root mismatch: serial %x, parallel %x", serialRoot, parallelRoot)
+	}
+	if have, want := len(parallelDB.Nodes()), len(serialDB.Nodes()); have != want {
+		t.Fatalf("stored node count mismatch: have %d, want %d", have, want)
+	}
+}
+
+// Tests that a trie committed into an external batch is only persisted once the
+// batch is written, and only dropped from memory afterwards.
+func TestCommitBatch(t *testing.T) {
+	diskdb := mandb.NewMemDatabase()
+	triedb := NewDatabase(diskdb)
+
+	trie, _ := New(common.Hash{}, triedb)
+	updateString(trie, "doe", "reindeer")
+	updateString(trie, "dog", "puppy")
+	updateString(trie, "dogglesworth", "cat")
+	root, _ := trie.Commit(nil)
+
+	batch := diskdb.NewBatch()
+	uncache, err := triedb.CommitBatch(root, batch)
+	if err != nil {
+		t.Fatalf("failed to batch trie: %v", err)
+	}
+	if ok, _ := diskdb.Has(root[:]); ok {
+		t.Fatalf("root persisted before the batch was written")
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("failed to write batch: %v", err)
+	}
+	uncache()
+
+	if ok, _ := diskdb.Has(root[:]); !ok {
+		t.Fatalf("root not persisted after the batch was written")
+	}
+	if size := triedb.Size(); size != 0 {
+		t.Fatalf("trie still cached after uncaching: %v", size)
+	}
+	if _, err := New(root, NewDatabase(diskdb)); err != nil {
+		t.Fatalf("failed to reopen persisted trie: %v", err)
+	}
+}
+
 func BenchmarkGet(b *testing.B)      { benchGet(b, false) }
 func BenchmarkGetDB(b *testing.B)    { benchGet(b, true) }
 func BenchmarkUpdateBE(b *testing.B) { benchUpdate(b, binary.BigEndian) }
@@ -560,17 +634,100 @@ func benchUpdate(b *testing.B, e binary.ByteOrder) *Trie {
 // the first one will be NOOP. As such, we'll use b.N as the number of account to
 // insert into the trie before measuring the hashing.
 func BenchmarkHash(b *testing.B) {
+	// Create a realistic account trie to hash
+	addresses, accounts := makeAccounts(b.N)
+	trie := newEmpty()
+	for i := 0; i < len(addresses); i++ {
+		trie.Update(crypto.Keccak256(addresses[i][:]), accounts[i])
+	}
+	b.ResetTimer()
+	b.ReportAllocs()
+	trie.Hash()
+}
+
+// Benchmarks the commit of freshly updated account tries of growing sizes, up
+// to a mainnet-sized state, with the subtries hashed serially or concurrently.
+func BenchmarkCommit(b *testing.B) {
+	for _, size := range []int{10000, 100000, 1000000} {
+		b.Run(fmt.Sprintf("%d", size), func(b *testing.B) {
+			addresses, accounts := makeAccounts(size)
+			b.Run("serial", func(b *testing.B) { benchCommit(b, addresses, accounts, false) })
+			b.Run("parallel", func(b *testing.B) { benchCommit(b, addresses, accounts, true) })
+		})
+	}
+}
+
+func benchCommit(b *testing.B, addresses [][20]byte, accounts [][]byte, parallel bool) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		trie := newEmpty()
+		for j := 0; j < len(addresses); j++ {
+			trie.Update(crypto.Keccak256(addresses[j][:]), accounts[j])
+		}
+		if !parallel {
+			trie.unhashed = 0
+		}
+		b.StartTimer()
+		trie.Commit(nil)
+	}
+}
+
+// Benchmarks persisting a committed account trie to disk, either in batches
+// flushed as they fill up or all at once in a single batch.
+func BenchmarkPersist(b *testing.B) {
+	addresses, accounts := makeAccounts(100000)
+	for _, single := range []bool{false, true} {
+		name := "flushed"
+		if single {
+			name = "single"
+		}
+		b.Run(name, func(b *testing.B) {
+			dir, triedb := tempDB()
+			defer os.RemoveAll(dir)
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				trie, _ := New(common.Hash{}, triedb)
+				for j := 0; j < len(addresses); j++ {
+					addresses[j][0] = byte(i) // Make sure every iteration writes new nodes
+					trie.Update(crypto.Keccak256(addresses[j][:]), accounts[j])
+				}
+				root, _ := trie.Commit(nil)
+				b.StartTimer()
+
+				if single {
+					batch := triedb.diskdb.NewBatch()
+					uncache, err := triedb.CommitBatch(root, batch)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if err := batch.Write(); err != nil {
+						b.Fatal(err)
+					}
+					uncache()
+				} else if err := triedb.Commit(root, false); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// makeAccounts generates a deterministic set of random addresses and RLP encoded
+// accounts to fill realistic account tries with.
+func makeAccounts(size int) (addresses [][20]byte, accounts [][]byte) {
 	// Make the random benchmark deterministic
 	random := rand.New(rand.NewSource(0))
 
-	// Create a realistic account trie to hash
-	addresses := make([][20]byte, b.N)
+	addresses = make([][20]byte, size)
 	for i := 0; i < len(addresses); i++ {
 		for j := 0; j < len(addresses[i]); j++ {
 			addresses[i][j] = byte(random.Intn(256))
 		}
 	}
-	accounts := make([][]byte, len(addresses))
+	accounts = make([][]byte, len(addresses))
 	for i := 0; i < len(accounts); i++ {
 		var (
 			nonce   = uint64(random.Int63())
@@ -580,14 +737,7 @@ func BenchmarkHash(b *testing.B) {
 		)
 		accounts[i], _ = rlp.EncodeToBytes([]interface{}{nonce, balance, root, code})
 	}
-	// Insert the accounts into the trie and hash it
-	trie := newEmpty()
-	for i := 0; i < len(addresses); i++ {
-		trie.Update(crypto.Keccak256(addresses[i][:]), accounts[i])
-	}
-	b.ResetTimer()
-	b.ReportAllocs()
-	trie.Hash()
+	return addresses, accounts
 }
 
 func tempDB() (string, *Database) {
@@ -0,0 +1,111 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package userop
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+)
+
+// PublicUserOpAPI provides the ERC-4337 style user operation endpoints.
+type PublicUserOpAPI struct {
+	s *Service
+}
+
+// NewPublicUserOpAPI creates a new user operation API.
+func NewPublicUserOpAPI(s *Service) *PublicUserOpAPI {
+	return &PublicUserOpAPI{s}
+}
+
+// SendUserOperation validates a user operation against the entrypoint and
+// adds it to the pool, returning its hash.
+func (api *PublicUserOpAPI) SendUserOperation(ctx context.Context, args RPCUserOperation, entryPoint common.Address) (common.Hash, error) {
+	if entryPoint != api.s.config.EntryPoint {
+		return common.Hash{}, fmt.Errorf("unsupported entrypoint %x", entryPoint)
+	}
+	return api.s.Add(ctx, args.toUserOperation())
+}
+
+// SupportedEntryPoints returns the entrypoints user operations are accepted for.
+func (api *PublicUserOpAPI) SupportedEntryPoints() []common.Address {
+	return []common.Address{api.s.config.EntryPoint}
+}
+
+// GetUserOperationByHash returns the pooled user operation with the given
+// hash, or nil if it is not pooled.
+func (api *PublicUserOpAPI) GetUserOperationByHash(hash common.Hash) *RPCUserOperation {
+	if op := api.s.pool.Get(hash); op != nil {
+		return newRPCUserOperation(op)
+	}
+	return nil
+}
+
+// PublicBundlerAPI provides read only access to the bundler hooks.
+type PublicBundlerAPI struct {
+	s *Service
+}
+
+// NewPublicBundlerAPI creates a new bundler API.
+func NewPublicBundlerAPI(s *Service) *PublicBundlerAPI {
+	return &PublicBundlerAPI{s}
+}
+
+// PendingUserOperations returns all pooled user operations, ordered by
+// sender and nonce.
+func (api *PublicBundlerAPI) PendingUserOperations() []*RPCUserOperation {
+	ops := api.s.pool.Content()
+
+	result := make([]*RPCUserOperation, len(ops))
+	for i, op := range ops {
+		result[i] = newRPCUserOperation(op)
+	}
+	return result
+}
+
+// PrivateBundlerAPI provides the bundler hooks spending node account funds.
+type PrivateBundlerAPI struct {
+	s *Service
+}
+
+// NewPrivateBundlerAPI creates a new private bundler API.
+func NewPrivateBundlerAPI(s *Service) *PrivateBundlerAPI {
+	return &PrivateBundlerAPI{s}
+}
+
+// BuildBundle returns the entrypoint calldata executing the next bundle and
+// paying its fees to beneficiary, for external bundlers to submit. Building a
+// bundle simulates the pooled operations again and drops the invalidated ones
+// from the pool, hence it is not exposed publicly.
+func (api *PrivateBundlerAPI) BuildBundle(ctx context.Context, beneficiary common.Address) (hexutil.Bytes, error) {
+	api.s.bundleLock.Lock()
+	defer api.s.bundleLock.Unlock()
+
+	ops, err := api.s.bundle(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return packHandleOps(ops, beneficiary), nil
+}
+
+// SendBundle signs the next bundle with the unlocked from account and submits
+// it to the transaction pool, returning the transaction hash.
+func (api *PrivateBundlerAPI) SendBundle(ctx context.Context, from, beneficiary common.Address) (common.Hash, error) {
+	return api.s.sendBundle(ctx, from, beneficiary)
+}
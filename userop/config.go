@@ -0,0 +1,57 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package userop
+
+import (
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+)
+
+// DefaultConfig contains default settings for the user operation pool.
+var DefaultConfig = Config{
+	MaxOps:        4096,
+	Lifetime:      time.Hour,
+	ValidationGas: 3000000,
+	BundleSize:    16,
+	BundleGas:     8000000,
+}
+
+// Config contains the configuration parameters of the user operation pool and
+// of the bundler built on top of it.
+type Config struct {
+	// EntryPoint is the entrypoint contract user operations are validated
+	// against and bundles are submitted to.
+	EntryPoint common.Address
+
+	// MaxOps is the maximum number of user operations kept in the pool.
+	MaxOps int `toml:",omitempty"`
+
+	// Lifetime is the maximum time a user operation stays in the pool
+	// without being bundled.
+	Lifetime time.Duration `toml:",omitempty"`
+
+	// ValidationGas is the gas allowance of the entrypoint simulation run
+	// for every incoming user operation.
+	ValidationGas uint64 `toml:",omitempty"`
+
+	// BundleSize is the maximum number of user operations per bundle.
+	BundleSize int `toml:",omitempty"`
+
+	// BundleGas is the gas limit of the bundle transactions sent by the node.
+	BundleGas uint64 `toml:",omitempty"`
+}
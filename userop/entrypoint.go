@@ -0,0 +1,220 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package userop
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/math"
+	"github.com/matrix/go-matrix/crypto"
+)
+
+// The user operation tuple as laid out by the ERC-4337 entrypoint interface.
+const opTuple = "(address,uint256,bytes,bytes,uint256,uint256,uint256,uint256,uint256,bytes,bytes)"
+
+var (
+	// Entrypoint methods called by the pool and the bundler
+	simulateValidationID = selector("simulateValidation" + opTuple)
+	handleOpsID          = selector("handleOps(" + opTuple + "[],address)")
+
+	// Custom errors reverted with by the entrypoint simulation
+	validationResultID = selector("ValidationResult((uint256,uint256,bool,uint48,uint48,bytes),(uint256,uint256),(uint256,uint256),(uint256,uint256))")
+	failedOpID         = selector("FailedOp(uint256,string)")
+	errorID            = selector("Error(string)")
+)
+
+// selector returns the 4 byte identifier of a method or error signature.
+func selector(signature string) []byte {
+	return crypto.Keccak256([]byte(signature))[:4]
+}
+
+// abiUint encodes a non-negative integer as a 32 byte ABI word.
+func abiUint(n *big.Int) []byte {
+	if n == nil {
+		return make([]byte, 32)
+	}
+	return math.PaddedBigBytes(n, 32)
+}
+
+// abiUint64 encodes an integer as a 32 byte ABI word.
+func abiUint64(n uint64) []byte {
+	return abiUint(new(big.Int).SetUint64(n))
+}
+
+// abiAddress encodes an address as a 32 byte ABI word.
+func abiAddress(addr common.Address) []byte {
+	return common.LeftPadBytes(addr[:], 32)
+}
+
+// abiBytes encodes the tail of a dynamic byte array: its length followed by
+// the data, right padded to a multiple of 32 bytes.
+func abiBytes(data []byte) []byte {
+	padded := (len(data) + 31) / 32 * 32
+	return append(abiUint64(uint64(len(data))), common.RightPadBytes(data, padded)...)
+}
+
+// packOp ABI encodes a user operation as a tuple, with head offsets relative
+// to the start of the tuple.
+func packOp(op *UserOperation) []byte {
+	const fields = 11
+
+	var (
+		head []byte
+		tail []byte
+	)
+	dynamic := func(data []byte) {
+		head = append(head, abiUint64(uint64(fields*32+len(tail)))...)
+		tail = append(tail, abiBytes(data)...)
+	}
+	head = append(head, abiAddress(op.Sender)...)
+	head = append(head, abiUint(op.Nonce)...)
+	dynamic(op.InitCode)
+	dynamic(op.CallData)
+	head = append(head, abiUint64(op.CallGasLimit)...)
+	head = append(head, abiUint64(op.VerificationGasLimit)...)
+	head = append(head, abiUint64(op.PreVerificationGas)...)
+	head = append(head, abiUint(op.MaxFeePerGas)...)
+	head = append(head, abiUint(op.MaxPriorityFeePerGas)...)
+	dynamic(op.PaymasterAndData)
+	dynamic(op.Signature)
+
+	return append(head, tail...)
+}
+
+// packSimulateValidation creates the calldata of an entrypoint simulation of
+// a single user operation.
+func packSimulateValidation(op *UserOperation) []byte {
+	data := append(common.CopyBytes(simulateValidationID), abiUint64(32)...)
+	return append(data, packOp(op)...)
+}
+
+// packHandleOps creates the calldata of an entrypoint transaction executing
+// a bundle of user operations, paying the fees to beneficiary.
+func packHandleOps(ops []*UserOperation, beneficiary common.Address) []byte {
+	data := common.CopyBytes(handleOpsID)
+	data = append(data, abiUint64(64)...)
+	data = append(data, abiAddress(beneficiary)...)
+
+	// The array of dynamic tuples: length, element offsets and elements, with
+	// the offsets relative to the first offset word
+	data = append(data, abiUint64(uint64(len(ops)))...)
+
+	var elems []byte
+	for _, op := range ops {
+		data = append(data, abiUint64(uint64(len(ops)*32+len(elems)))...)
+		elems = append(elems, packOp(op)...)
+	}
+	return append(data, elems...)
+}
+
+var (
+	// errValidationReverted is returned if the entrypoint rejects an operation
+	// without a decodable reason.
+	errValidationReverted = errors.New("entrypoint validation reverted")
+
+	// errValidationNotReverted is returned if the simulation didn't revert, which
+	// the ERC-4337 entrypoint always does. The configured entrypoint either has
+	// no code or isn't one.
+	errValidationNotReverted = errors.New("entrypoint simulation returned without validation result")
+
+	// errValidationResult is returned if the ValidationResult of the simulation
+	// can't be decoded.
+	errValidationResult = errors.New("malformed entrypoint validation result")
+
+	// errSignatureFailed is returned if the account or paymaster reports the
+	// signature of the operation as invalid.
+	errSignatureFailed = errors.New("user operation signature invalid")
+)
+
+// simulationResult interprets the outcome of an entrypoint simulation at the
+// given unix time. The ERC-4337 entrypoint always reverts, with ValidationResult
+// on success, whose signature status and validity window must accept the
+// operation.
+func simulationResult(ret []byte, failed bool, now uint64) error {
+	if !failed {
+		return errValidationNotReverted
+	}
+	if len(ret) < 4 {
+		return errValidationReverted
+	}
+	id, args := ret[:4], ret[4:]
+	switch {
+	case bytes.Equal(id, validationResultID):
+		return checkValidationResult(args, now)
+	case bytes.Equal(id, failedOpID):
+		// FailedOp(uint256 opIndex, string reason)
+		if reason, ok := unpackString(args, 32); ok {
+			return fmt.Errorf("entrypoint rejected operation: %s", reason)
+		}
+	case bytes.Equal(id, errorID):
+		if reason, ok := unpackString(args, 0); ok {
+			return fmt.Errorf("entrypoint validation reverted: %s", reason)
+		}
+	}
+	return errValidationReverted
+}
+
+// checkValidationResult decodes the return info of a ValidationResult, the
+// dynamic tuple (preOpGas, prefund, sigFailed, validAfter, validUntil,
+// paymasterContext) whose offset is the first argument, and checks the
+// operation is validly signed and valid at the given unix time.
+func checkValidationResult(args []byte, now uint64) error {
+	if len(args) < 32 {
+		return errValidationResult
+	}
+	offset := new(big.Int).SetBytes(args[:32])
+	if !offset.IsUint64() || offset.Uint64() > uint64(len(args)) || uint64(len(args))-offset.Uint64() < 5*32 {
+		return errValidationResult
+	}
+	info := args[offset.Uint64():]
+	word := func(i int) *big.Int { return new(big.Int).SetBytes(info[i*32 : (i+1)*32]) }
+
+	sigFailed, validAfter, validUntil := word(2), word(3), word(4)
+	if sigFailed.Sign() != 0 {
+		return errSignatureFailed
+	}
+	if validAfter.Cmp(new(big.Int).SetUint64(now)) > 0 {
+		return fmt.Errorf("user operation not valid until %v", validAfter)
+	}
+	// A zero validUntil means the operation never expires
+	if validUntil.Sign() != 0 && validUntil.Cmp(new(big.Int).SetUint64(now)) < 0 {
+		return fmt.Errorf("user operation expired at %v", validUntil)
+	}
+	return nil
+}
+
+// unpackString decodes an ABI encoded string whose offset is stored in the
+// word at the given position of the arguments.
+func unpackString(args []byte, pos int) (string, bool) {
+	if len(args) < pos+32 {
+		return "", false
+	}
+	offset := new(big.Int).SetBytes(args[pos : pos+32])
+	if !offset.IsUint64() || offset.Uint64() > uint64(len(args)-32) {
+		return "", false
+	}
+	start := int(offset.Uint64())
+	size := new(big.Int).SetBytes(args[start : start+32])
+	if !size.IsUint64() || size.Uint64() > uint64(len(args)-start-32) {
+		return "", false
+	}
+	return string(args[start+32 : start+32+int(size.Uint64())]), true
+}
@@ -0,0 +1,270 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package userop
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/event"
+)
+
+// priceBump is the minimum fee increase, in percent, needed to replace a
+// pooled user operation with one of the same sender and nonce.
+const priceBump = 10
+
+var (
+	// ErrKnownOp is returned if a user operation is already pooled.
+	ErrKnownOp = errors.New("known user operation")
+
+	// ErrReplaceUnderpriced is returned if a user operation tries to replace
+	// a pooled one without paying enough extra fees.
+	ErrReplaceUnderpriced = errors.New("replacement user operation underpriced")
+
+	// ErrPoolFull is returned if the pool is at capacity and the new user
+	// operation does not pay more than the cheapest pooled one.
+	ErrPoolFull = errors.New("user operation pool full")
+)
+
+// NewOpsEvent is posted when a batch of user operations enter the pool.
+type NewOpsEvent struct{ Ops []*UserOperation }
+
+// pooledOp is a user operation tracked by the pool along with its metadata.
+type pooledOp struct {
+	op    *UserOperation
+	hash  common.Hash
+	added time.Time
+}
+
+// Pool holds validated user operations waiting to be bundled. Operations are
+// keyed by hash and unique per sender and nonce, a same nonce operation only
+// replacing a pooled one if it bumps both of its fees.
+type Pool struct {
+	config  *Config
+	chainID *big.Int
+
+	all     map[common.Hash]*pooledOp                    // All pooled operations by hash
+	senders map[common.Address]map[common.Hash]*pooledOp // Pooled operations by sender and nonce
+	lock    sync.RWMutex
+
+	feed  event.Feed
+	scope event.SubscriptionScope
+}
+
+// NewPool creates a user operation pool for the configured entrypoint.
+func NewPool(config *Config, chainID *big.Int) *Pool {
+	return &Pool{
+		config:  config,
+		chainID: chainID,
+		all:     make(map[common.Hash]*pooledOp),
+		senders: make(map[common.Address]map[common.Hash]*pooledOp),
+	}
+}
+
+// Hash returns the hash identifying a user operation in this pool.
+func (pool *Pool) Hash(op *UserOperation) common.Hash {
+	return op.Hash(pool.config.EntryPoint, pool.chainID)
+}
+
+// Add inserts an already simulated user operation into the pool, announcing
+// it to subscribers if accepted.
+func (pool *Pool) Add(op *UserOperation) (common.Hash, error) {
+	if err := op.validate(); err != nil {
+		return common.Hash{}, err
+	}
+	hash := pool.Hash(op)
+
+	pool.lock.Lock()
+	if _, ok := pool.all[hash]; ok {
+		pool.lock.Unlock()
+		return hash, ErrKnownOp
+	}
+	nonce := common.BigToHash(op.Nonce)
+	if old := pool.senders[op.Sender][nonce]; old != nil {
+		if !bumped(old.op.MaxFeePerGas, op.MaxFeePerGas) || !bumped(old.op.MaxPriorityFeePerGas, op.MaxPriorityFeePerGas) {
+			pool.lock.Unlock()
+			return hash, ErrReplaceUnderpriced
+		}
+		pool.remove(old.hash)
+	} else if len(pool.all) >= pool.config.MaxOps {
+		cheapest := pool.cheapest()
+		if cheapest == nil || cheapest.op.MaxPriorityFeePerGas.Cmp(op.MaxPriorityFeePerGas) >= 0 {
+			pool.lock.Unlock()
+			return hash, ErrPoolFull
+		}
+		pool.remove(cheapest.hash)
+	}
+	entry := &pooledOp{op: op, hash: hash, added: time.Now()}
+	pool.all[hash] = entry
+	if pool.senders[op.Sender] == nil {
+		pool.senders[op.Sender] = make(map[common.Hash]*pooledOp)
+	}
+	pool.senders[op.Sender][nonce] = entry
+	pool.lock.Unlock()
+
+	pool.feed.Send(NewOpsEvent{Ops: []*UserOperation{op}})
+	return hash, nil
+}
+
+// Has reports whether the pool holds a user operation with the given hash.
+func (pool *Pool) Has(hash common.Hash) bool {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	return pool.all[hash] != nil
+}
+
+// Get returns the pooled user operation with the given hash, if any.
+func (pool *Pool) Get(hash common.Hash) *UserOperation {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	if entry := pool.all[hash]; entry != nil {
+		return entry.op
+	}
+	return nil
+}
+
+// Len returns the number of pooled user operations.
+func (pool *Pool) Len() int {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	return len(pool.all)
+}
+
+// Content returns all pooled user operations, ordered by sender and nonce.
+func (pool *Pool) Content() []*UserOperation {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	ops := make([]*UserOperation, 0, len(pool.all))
+	for _, entry := range pool.all {
+		ops = append(ops, entry.op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Sender != ops[j].Sender {
+			return ops[i].Sender.Big().Cmp(ops[j].Sender.Big()) < 0
+		}
+		return ops[i].Nonce.Cmp(ops[j].Nonce) < 0
+	})
+	return ops
+}
+
+// Pending returns up to max user operations for the next bundle: the lowest
+// nonce operation of each sender, best paying first. A sender never gets more
+// than one operation per bundle, as its later ones could only be validated
+// against the state left behind by the first.
+func (pool *Pool) Pending(max int) []*UserOperation {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	ops := make([]*UserOperation, 0, len(pool.senders))
+	for _, entries := range pool.senders {
+		var first *pooledOp
+		for _, entry := range entries {
+			if first == nil || entry.op.Nonce.Cmp(first.op.Nonce) < 0 {
+				first = entry
+			}
+		}
+		if first != nil {
+			ops = append(ops, first.op)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].MaxPriorityFeePerGas.Cmp(ops[j].MaxPriorityFeePerGas) > 0
+	})
+	if max > 0 && len(ops) > max {
+		ops = ops[:max]
+	}
+	return ops
+}
+
+// Remove drops the user operations with the given hashes from the pool.
+func (pool *Pool) Remove(hashes ...common.Hash) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	for _, hash := range hashes {
+		pool.remove(hash)
+	}
+}
+
+// Expire drops all user operations pooled for longer than the configured
+// lifetime, returning the number of operations dropped.
+func (pool *Pool) Expire(now time.Time) int {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	var dropped int
+	for hash, entry := range pool.all {
+		if now.Sub(entry.added) > pool.config.Lifetime {
+			pool.remove(hash)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// SubscribeNewOpsEvent registers a subscription of NewOpsEvent and starts
+// sending events to the given channel.
+func (pool *Pool) SubscribeNewOpsEvent(ch chan<- NewOpsEvent) event.Subscription {
+	return pool.scope.Track(pool.feed.Subscribe(ch))
+}
+
+// Stop terminates all subscriptions of the pool.
+func (pool *Pool) Stop() {
+	pool.scope.Close()
+}
+
+// remove drops a single user operation. The caller must hold the lock.
+func (pool *Pool) remove(hash common.Hash) {
+	entry := pool.all[hash]
+	if entry == nil {
+		return
+	}
+	delete(pool.all, hash)
+
+	sender := entry.op.Sender
+	delete(pool.senders[sender], common.BigToHash(entry.op.Nonce))
+	if len(pool.senders[sender]) == 0 {
+		delete(pool.senders, sender)
+	}
+}
+
+// cheapest returns the pooled user operation with the lowest priority fee.
+// The caller must hold the lock.
+func (pool *Pool) cheapest() *pooledOp {
+	var cheapest *pooledOp
+	for _, entry := range pool.all {
+		if cheapest == nil || entry.op.MaxPriorityFeePerGas.Cmp(cheapest.op.MaxPriorityFeePerGas) < 0 {
+			cheapest = entry
+		}
+	}
+	return cheapest
+}
+
+// bumped reports whether fee is at least priceBump percent higher than old.
+func bumped(old, fee *big.Int) bool {
+	threshold := new(big.Int).Mul(old, big.NewInt(100+priceBump))
+	threshold.Div(threshold, big.NewInt(100))
+	return fee.Cmp(threshold) >= 0
+}
@@ -0,0 +1,177 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package userop
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+)
+
+func testOp(sender byte, nonce int64, tip int64) *UserOperation {
+	return &UserOperation{
+		Sender:               common.Address{sender},
+		Nonce:                big.NewInt(nonce),
+		CallGasLimit:         50000,
+		VerificationGasLimit: 100000,
+		PreVerificationGas:   21000,
+		MaxFeePerGas:         big.NewInt(tip * 2),
+		MaxPriorityFeePerGas: big.NewInt(tip),
+	}
+}
+
+func testPool(maxOps int) *Pool {
+	config := DefaultConfig
+	config.EntryPoint = common.Address{0xee}
+	config.MaxOps = maxOps
+	return NewPool(&config, big.NewInt(1))
+}
+
+func TestPoolReplacement(t *testing.T) {
+	pool := testPool(16)
+
+	hash, err := pool.Add(testOp(1, 0, 100))
+	if err != nil {
+		t.Fatalf("failed to add operation: %v", err)
+	}
+	if _, err := pool.Add(testOp(1, 0, 100)); err != ErrKnownOp {
+		t.Fatalf("duplicate error mismatch: have %v, want %v", err, ErrKnownOp)
+	}
+	if _, err := pool.Add(testOp(1, 0, 105)); err != ErrReplaceUnderpriced {
+		t.Fatalf("underpriced replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	replaced, err := pool.Add(testOp(1, 0, 110))
+	if err != nil {
+		t.Fatalf("failed to replace operation: %v", err)
+	}
+	if pool.Has(hash) || !pool.Has(replaced) || pool.Len() != 1 {
+		t.Fatalf("replacement not applied: old %v, new %v, len %d", pool.Has(hash), pool.Has(replaced), pool.Len())
+	}
+}
+
+func TestPoolEviction(t *testing.T) {
+	pool := testPool(2)
+
+	cheap, _ := pool.Add(testOp(1, 0, 10))
+	pool.Add(testOp(2, 0, 20))
+
+	if _, err := pool.Add(testOp(3, 0, 5)); err != ErrPoolFull {
+		t.Fatalf("full pool error mismatch: have %v, want %v", err, ErrPoolFull)
+	}
+	if _, err := pool.Add(testOp(3, 0, 30)); err != nil {
+		t.Fatalf("failed to add better paying operation: %v", err)
+	}
+	if pool.Has(cheap) || pool.Len() != 2 {
+		t.Fatalf("cheapest operation not evicted: pooled %v, len %d", pool.Has(cheap), pool.Len())
+	}
+}
+
+func TestPoolPending(t *testing.T) {
+	pool := testPool(16)
+
+	pool.Add(testOp(1, 1, 50))
+	pool.Add(testOp(1, 0, 10))
+	pool.Add(testOp(2, 0, 30))
+	pool.Add(testOp(3, 0, 20))
+
+	pending := pool.Pending(0)
+	if len(pending) != 3 {
+		t.Fatalf("pending count mismatch: have %d, want 3", len(pending))
+	}
+	want := []common.Address{{2}, {3}, {1}}
+	for i, op := range pending {
+		if op.Sender != want[i] {
+			t.Errorf("op %d: sender mismatch: have %x, want %x", i, op.Sender, want[i])
+		}
+	}
+	if pending[2].Nonce.Sign() != 0 {
+		t.Errorf("sender picked with nonce %v, want lowest", pending[2].Nonce)
+	}
+	if pending := pool.Pending(2); len(pending) != 2 {
+		t.Errorf("capped pending count mismatch: have %d, want 2", len(pending))
+	}
+}
+
+func TestPoolExpiry(t *testing.T) {
+	pool := testPool(16)
+
+	pool.Add(testOp(1, 0, 10))
+	if dropped := pool.Expire(time.Now()); dropped != 0 {
+		t.Fatalf("fresh operation expired")
+	}
+	if dropped := pool.Expire(time.Now().Add(pool.config.Lifetime + time.Second)); dropped != 1 {
+		t.Fatalf("expired count mismatch: have %d, want 1", dropped)
+	}
+	if pool.Len() != 0 || len(pool.senders) != 0 {
+		t.Fatalf("expired operation still tracked: %d ops, %d senders", pool.Len(), len(pool.senders))
+	}
+}
+
+// packValidationResult creates the revert data of a successful entrypoint
+// simulation with the given signature status and validity window.
+func packValidationResult(sigFailed bool, validAfter, validUntil uint64) []byte {
+	ret := append(common.CopyBytes(validationResultID), abiUint64(7*32)...)
+	ret = append(ret, make([]byte, 6*32)...) // sender, factory and aggregator stakes
+
+	var sig uint64
+	if sigFailed {
+		sig = 1
+	}
+	ret = append(ret, abiUint64(100000)...) // preOpGas
+	ret = append(ret, abiUint64(1000)...)   // prefund
+	ret = append(ret, abiUint64(sig)...)
+	ret = append(ret, abiUint64(validAfter)...)
+	ret = append(ret, abiUint64(validUntil)...)
+	ret = append(ret, abiUint64(6*32)...) // paymasterContext offset
+	return append(ret, abiBytes(nil)...)
+}
+
+func TestSimulationResult(t *testing.T) {
+	// FailedOp(0, "AA21 didn't pay prefund")
+	reason := "AA21 didn't pay prefund"
+	failed := append(common.CopyBytes(failedOpID), abiUint64(0)...)
+	failed = append(failed, abiUint64(64)...)
+	failed = append(failed, abiBytes([]byte(reason))...)
+
+	const now = 1000
+	tests := []struct {
+		ret    []byte
+		failed bool
+		err    string
+	}{
+		{nil, false, errValidationNotReverted.Error()},
+		{packValidationResult(false, 0, 0), false, errValidationNotReverted.Error()},
+		{packValidationResult(false, 0, 0), true, ""},
+		{packValidationResult(false, now, now), true, ""},
+		{packValidationResult(true, 0, 0), true, errSignatureFailed.Error()},
+		{packValidationResult(false, now+1, 0), true, "user operation not valid until 1001"},
+		{packValidationResult(false, 0, now-1), true, "user operation expired at 999"},
+		{packValidationResult(false, 0, 0)[:4+7*32+4*32], true, errValidationResult.Error()},
+		{append(common.CopyBytes(validationResultID), make([]byte, 64)...), true, errValidationResult.Error()},
+		{failed, true, "entrypoint rejected operation: " + reason},
+		{failed[:40], true, errValidationReverted.Error()},
+		{nil, true, errValidationReverted.Error()},
+	}
+	for i, tt := range tests {
+		err := simulationResult(tt.ret, tt.failed, now)
+		if (err == nil && tt.err != "") || (err != nil && err.Error() != tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %q", i, err, tt.err)
+		}
+	}
+}
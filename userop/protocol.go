@@ -0,0 +1,220 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package userop
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/p2p"
+	"gopkg.in/fatih/set.v0"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	ProtocolName       = "aa"        // Protocol name used for user operation gossip
+	ProtocolVersion    = 1           // Protocol version used for user operation gossip
+	ProtocolLength     = 1           // Number of implemented message codes
+	ProtocolMaxMsgSize = 1024 * 1024 // Maximum cap on the size of a protocol message
+
+	// UserOpsMsg carries a batch of user operations.
+	UserOpsMsg = 0x00
+)
+
+const (
+	maxKnownOps   = 32768 // Maximum user operation hashes to keep in the known list (prevent DOS)
+	maxQueuedOps  = 128   // Maximum number of user operation batches to queue up before dropping broadcasts
+	maxPendingOps = 16    // Maximum number of received batches to queue up for validation before dropping them
+
+	opsRate   = 8.0  // Remote user operations a peer may have simulated per second
+	opsBurst  = 64   // Remote user operations a peer may send at once before being throttled
+	faultRate = 0.05 // Invalid user operations forgiven per second to a peer
+	maxFaults = 16   // Invalid user operations tolerated from a peer before dropping it
+)
+
+var errTooManyInvalidOps = errors.New("too many invalid user operations")
+
+// peer is a remote node speaking the user operation gossip protocol.
+type peer struct {
+	id string
+
+	*p2p.Peer
+	rw p2p.MsgReadWriter
+
+	knownOps   *set.Set              // Set of user operation hashes known to be known by this peer
+	queuedOps  chan []*UserOperation // Queue of user operations to broadcast to the peer
+	pendingOps chan []*UserOperation // Queue of user operations received from the peer awaiting validation
+	budget     *bucket               // Remote user operations the peer may still have simulated
+	faults     *bucket               // Invalid user operations the peer may still send
+	term       chan struct{}         // Termination channel to stop the broadcaster and validator
+}
+
+func newPeer(p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
+	return &peer{
+		Peer:       p,
+		rw:         rw,
+		id:         fmt.Sprintf("%x", p.ID().Bytes()[:8]),
+		knownOps:   set.New(),
+		queuedOps:  make(chan []*UserOperation, maxQueuedOps),
+		pendingOps: make(chan []*UserOperation, maxPendingOps),
+		budget:     newBucket(opsRate, opsBurst),
+		faults:     newBucket(faultRate, maxFaults),
+		term:       make(chan struct{}),
+	}
+}
+
+// broadcast is a write loop sending queued user operations to the remote
+// peer, so that the pool never blocks on a slow connection.
+func (p *peer) broadcast() {
+	for {
+		select {
+		case ops := <-p.queuedOps:
+			if err := p2p.Send(p.rw, UserOpsMsg, ops); err != nil {
+				return
+			}
+			p.Log().Trace("Broadcast user operations", "count", len(ops))
+
+		case <-p.term:
+			return
+		}
+	}
+}
+
+// close signals the broadcast goroutine to terminate.
+func (p *peer) close() {
+	close(p.term)
+}
+
+// markOp marks a user operation as known for the peer, ensuring that it will
+// never be propagated back to this particular peer.
+func (p *peer) markOp(hash common.Hash) {
+	for p.knownOps.Size() >= maxKnownOps {
+		p.knownOps.Pop()
+	}
+	p.knownOps.Add(hash)
+}
+
+// penalise records an invalid user operation received from the peer, returning
+// an error once the peer sent more of them than tolerated.
+func (p *peer) penalise() error {
+	if !p.faults.take(time.Now()) {
+		return errTooManyInvalidOps
+	}
+	return nil
+}
+
+// asyncSendOps queues a batch of user operations for propagation to the
+// remote peer. If the peer's broadcast queue is full, the batch is dropped.
+func (p *peer) asyncSendOps(ops []*UserOperation, hashes []common.Hash) {
+	select {
+	case p.queuedOps <- ops:
+		for _, hash := range hashes {
+			p.markOp(hash)
+		}
+	default:
+		p.Log().Debug("Dropping user operation propagation", "count", len(ops))
+	}
+}
+
+// peerSet is the set of peers currently speaking the gossip protocol.
+type peerSet struct {
+	peers map[string]*peer
+	lock  sync.RWMutex
+}
+
+func newPeerSet() *peerSet {
+	return &peerSet{peers: make(map[string]*peer)}
+}
+
+func (ps *peerSet) register(p *peer) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	ps.peers[p.id] = p
+	go p.broadcast()
+}
+
+func (ps *peerSet) unregister(id string) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if p, ok := ps.peers[id]; ok {
+		delete(ps.peers, id)
+		p.close()
+	}
+}
+
+// peersWithoutOp retrieves a list of peers that do not have a given user
+// operation in their set of known hashes.
+func (ps *peerSet) peersWithoutOp(hash common.Hash) []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if !p.knownOps.Has(hash) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+func (ps *peerSet) len() int {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	return len(ps.peers)
+}
+
+// bucket is a token bucket refilled at a constant rate up to its capacity,
+// limiting how often a peer may do something.
+type bucket struct {
+	rate     float64 // Tokens added per second
+	capacity float64 // Maximum number of tokens held
+
+	tokens float64
+	last   time.Time // Last refill of the bucket
+	lock   sync.Mutex
+}
+
+func newBucket(rate, capacity float64) *bucket {
+	return &bucket{rate: rate, capacity: capacity, tokens: capacity}
+}
+
+// take refills the bucket and takes a token from it, reporting whether there
+// was one left.
+func (b *bucket) take(now time.Time) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
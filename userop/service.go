@@ -0,0 +1,542 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package userop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru"
+
+	"github.com/matrix/go-matrix/accounts"
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/core"
+	"github.com/matrix/go-matrix/core/state"
+	"github.com/matrix/go-matrix/core/types"
+	"github.com/matrix/go-matrix/core/vm"
+	"github.com/matrix/go-matrix/event"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/metrics"
+	"github.com/matrix/go-matrix/p2p"
+	"github.com/matrix/go-matrix/p2p/discover"
+	"github.com/matrix/go-matrix/params"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+const (
+	simulationTimeout = 5 * time.Second // Maximum runtime of an entrypoint simulation
+	expiryInterval    = time.Minute     // Interval between two pool expiry runs
+
+	maxRejectedOps = 4096 // Maximum number of invalid user operation hashes remembered
+	maxSimulations = 4    // Maximum number of remote user operations simulated concurrently
+)
+
+var (
+	acceptedMeter  = metrics.NewRegisteredMeter("userop/accepted", nil)
+	rejectedMeter  = metrics.NewRegisteredMeter("userop/rejected", nil)
+	bundledMeter   = metrics.NewRegisteredMeter("userop/bundled", nil)
+	throttledMeter = metrics.NewRegisteredMeter("userop/throttled", nil)
+)
+
+var errEmptyBundle = errors.New("no valid user operations to bundle")
+
+// Backend is the subset of the Matrix API backend the user operation service
+// needs to simulate operations and submit bundles.
+type Backend interface {
+	ChainConfig() *params.ChainConfig
+	CurrentBlock() *types.Block
+	StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error)
+	GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error)
+	SuggestPrice(ctx context.Context) (*big.Int, error)
+	GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error)
+	SendTx(ctx context.Context, signedTx *types.Transaction) error
+}
+
+// Service is an experimental account abstraction node service. It runs a
+// mempool lane for user operations next to the transaction pool, admitting
+// only operations the configured entrypoint contract validates in simulation,
+// gossips them over a dedicated protocol and exposes bundler hooks packing
+// pooled operations into entrypoint transactions.
+type Service struct {
+	config  *Config
+	backend Backend
+	manager *accounts.Manager
+
+	pool     *Pool
+	peers    *peerSet
+	rejected *lru.Cache    // Hashes of remote user operations which failed validation
+	simSlots chan struct{} // Limits the remote user operations simulated concurrently
+
+	opsCh  chan NewOpsEvent
+	opsSub event.Subscription
+
+	bundleLock sync.Mutex // serialises bundle submissions so nonces are never reused
+	quit       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// New creates a user operation service validating against and bundling for
+// the configured entrypoint.
+func New(config *Config, backend Backend, manager *accounts.Manager) (*Service, error) {
+	if config.EntryPoint == (common.Address{}) {
+		return nil, errors.New("user operation entrypoint not configured")
+	}
+	rejected, _ := lru.New(maxRejectedOps)
+	return &Service{
+		config:   config,
+		backend:  backend,
+		manager:  manager,
+		pool:     NewPool(config, backend.ChainConfig().ChainId),
+		peers:    newPeerSet(),
+		rejected: rejected,
+		simSlots: make(chan struct{}, maxSimulations),
+		opsCh:    make(chan NewOpsEvent, 256),
+		quit:     make(chan struct{}),
+	}, nil
+}
+
+// Pool returns the user operation pool of the service.
+func (s *Service) Pool() *Pool { return s.pool }
+
+// Protocols implements node.Service, returning the user operation gossip
+// protocol.
+func (s *Service) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{{
+		Name:    ProtocolName,
+		Version: ProtocolVersion,
+		Length:  ProtocolLength,
+		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+			s.wg.Add(1)
+			defer s.wg.Done()
+			return s.handle(newPeer(p, rw))
+		},
+		NodeInfo: func() interface{} {
+			return map[string]interface{}{
+				"entryPoint": s.config.EntryPoint,
+				"pooled":     s.pool.Len(),
+				"peers":      s.peers.len(),
+			}
+		},
+		PeerInfo: func(id discover.NodeID) interface{} {
+			return nil
+		},
+	}}
+}
+
+// APIs implements node.Service, returning the user operation and bundler
+// RPC endpoints.
+func (s *Service) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "man",
+			Version:   "1.0",
+			Service:   NewPublicUserOpAPI(s),
+			Public:    true,
+		}, {
+			Namespace: "eth",
+			Version:   "1.0",
+			Service:   NewPublicUserOpAPI(s),
+			Public:    true,
+		}, {
+			Namespace: "bundler",
+			Version:   "1.0",
+			Service:   NewPublicBundlerAPI(s),
+			Public:    true,
+		}, {
+			Namespace: "bundler",
+			Version:   "1.0",
+			Service:   NewPrivateBundlerAPI(s),
+		},
+	}
+}
+
+// Start implements node.Service, starting the gossip broadcaster and the
+// pool expiry loop.
+func (s *Service) Start(server *p2p.Server) error {
+	s.opsSub = s.pool.SubscribeNewOpsEvent(s.opsCh)
+
+	s.wg.Add(2)
+	go s.broadcastLoop()
+	go s.expiryLoop()
+
+	log.Info("User operation pool started", "entrypoint", s.config.EntryPoint)
+	return nil
+}
+
+// Stop implements node.Service, terminating all background loops.
+func (s *Service) Stop() error {
+	s.opsSub.Unsubscribe()
+	close(s.quit)
+	s.pool.Stop()
+	s.wg.Wait()
+
+	log.Info("User operation pool stopped")
+	return nil
+}
+
+// Add validates a user operation against the entrypoint and adds it to the
+// pool if accepted.
+func (s *Service) Add(ctx context.Context, op *UserOperation) (common.Hash, error) {
+	if err := s.check(ctx, op); err != nil {
+		rejectedMeter.Mark(1)
+		return common.Hash{}, err
+	}
+	hash, err := s.pool.Add(op)
+	if err != nil {
+		rejectedMeter.Mark(1)
+		return hash, err
+	}
+	acceptedMeter.Mark(1)
+	log.Debug("Pooled user operation", "hash", hash, "sender", op.Sender, "nonce", op.Nonce)
+	return hash, nil
+}
+
+// check runs the stateless sanity checks and the entrypoint simulation of a
+// user operation.
+func (s *Service) check(ctx context.Context, op *UserOperation) error {
+	if err := op.validate(); err != nil {
+		return err
+	}
+	if gas := opGas(op); gas > s.config.BundleGas {
+		return fmt.Errorf("user operation gas %d exceeds bundle gas limit %d", gas, s.config.BundleGas)
+	}
+	return s.simulate(ctx, op)
+}
+
+// simulate runs the entrypoint validation of a user operation on top of the
+// latest state, without persisting any changes.
+func (s *Service) simulate(ctx context.Context, op *UserOperation) error {
+	statedb, header, err := s.backend.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if statedb == nil || err != nil {
+		return err
+	}
+	entryPoint := s.config.EntryPoint
+	msg := types.NewMessage(common.Address{}, &entryPoint, 0, new(big.Int), s.config.ValidationGas, new(big.Int), packSimulateValidation(op), false)
+
+	ctx, cancel := context.WithTimeout(ctx, simulationTimeout)
+	defer cancel()
+
+	evm, vmError, err := s.backend.GetEVM(ctx, msg, statedb, header, vm.Config{})
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		evm.Cancel()
+	}()
+	gp := new(core.GasPool).AddGas(math.MaxUint64)
+	ret, _, failed, err := core.ApplyMessage(evm, msg, gp)
+	if err := vmError(); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	return simulationResult(ret, failed, uint64(time.Now().Unix()))
+}
+
+// bundle collects the pooled user operations making up the next bundle. Each
+// is simulated again against the latest state, dropping the ones which became
+// invalid, e.g. because another bundler already included them.
+func (s *Service) bundle(ctx context.Context) ([]*UserOperation, error) {
+	var (
+		ops []*UserOperation
+		gas uint64
+	)
+	for _, op := range s.pool.Pending(s.config.BundleSize) {
+		if err := s.simulate(ctx, op); err != nil {
+			hash := s.pool.Hash(op)
+			log.Debug("Dropping invalidated user operation", "hash", hash, "err", err)
+			s.pool.Remove(hash)
+			continue
+		}
+		if gas+opGas(op) > s.config.BundleGas {
+			continue
+		}
+		gas += opGas(op)
+		ops = append(ops, op)
+	}
+	if len(ops) == 0 {
+		return nil, errEmptyBundle
+	}
+	return ops, nil
+}
+
+// sendBundle signs and submits an entrypoint transaction executing the next
+// bundle from the given account, removing the bundled operations from the
+// pool.
+func (s *Service) sendBundle(ctx context.Context, from, beneficiary common.Address) (common.Hash, error) {
+	s.bundleLock.Lock()
+	defer s.bundleLock.Unlock()
+
+	account := accounts.Account{Address: from}
+	wallet, err := s.manager.Find(account)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	ops, err := s.bundle(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	nonce, err := s.backend.GetPoolNonce(ctx, from)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	price, err := s.backend.SuggestPrice(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	tx := types.NewTransaction(nonce, s.config.EntryPoint, new(big.Int), s.config.BundleGas, price, packHandleOps(ops, beneficiary))
+
+	var chainID *big.Int
+	if config := s.backend.ChainConfig(); config.IsEIP155(s.backend.CurrentBlock().Number()) {
+		chainID = config.ChainId
+	}
+	signed, err := wallet.SignTx(account, tx, chainID)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := s.backend.SendTx(ctx, signed); err != nil {
+		return common.Hash{}, err
+	}
+	for _, op := range ops {
+		s.pool.Remove(s.pool.Hash(op))
+	}
+	bundledMeter.Mark(int64(len(ops)))
+	log.Info("Submitted user operation bundle", "tx", signed.Hash(), "ops", len(ops), "beneficiary", beneficiary)
+	return signed.Hash(), nil
+}
+
+// handle is the callback invoked to manage the life cycle of a gossip peer.
+// When this function terminates, the peer is disconnected.
+func (s *Service) handle(p *peer) error {
+	p.Log().Debug("User operation peer connected", "name", p.Name())
+
+	s.peers.register(p)
+	defer s.peers.unregister(p.id)
+
+	s.wg.Add(1)
+	go s.validateLoop(p)
+
+	// Send the whole pool to the new peer, it will forget about the known
+	// ones on its own
+	if ops := s.pool.Content(); len(ops) > 0 {
+		p.asyncSendOps(ops, s.hashes(ops))
+	}
+	for {
+		if err := s.handleMsg(p); err != nil {
+			p.Log().Debug("User operation peer disconnected", "err", err)
+			return err
+		}
+	}
+}
+
+// handleMsg is invoked whenever an inbound message is received from a remote
+// peer. The remote connection is torn down upon returning any error.
+func (s *Service) handleMsg(p *peer) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return fmt.Errorf("message too large: %v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	defer msg.Discard()
+
+	switch msg.Code {
+	case UserOpsMsg:
+		var ops []*UserOperation
+		if err := msg.Decode(&ops); err != nil {
+			return fmt.Errorf("msg %v: %v", msg, err)
+		}
+		// Filter out the operations known to be pooled or invalid, and the ones
+		// over the peer's simulation budget, queueing the rest for validation
+		var pending []*UserOperation
+		for i, op := range ops {
+			if op == nil {
+				return fmt.Errorf("user operation %d is nil", i)
+			}
+			hash := s.pool.Hash(op)
+			p.markOp(hash)
+			if s.pool.Has(hash) {
+				continue
+			}
+			if s.rejected.Contains(hash) {
+				rejectedMeter.Mark(1)
+				if err := p.penalise(); err != nil {
+					return err
+				}
+				continue
+			}
+			if !p.budget.take(time.Now()) {
+				throttledMeter.Mark(1)
+				continue
+			}
+			pending = append(pending, op)
+		}
+		if len(pending) > 0 {
+			select {
+			case p.pendingOps <- pending:
+			default:
+				throttledMeter.Mark(int64(len(pending)))
+				p.Log().Debug("Dropping remote user operations", "count", len(pending))
+			}
+		}
+	default:
+		return fmt.Errorf("invalid message code %v", msg.Code)
+	}
+	return nil
+}
+
+// validateLoop simulates the user operations received from a peer, off the
+// peer's read loop, and pools the valid ones. The peer is dropped once it sent
+// more invalid operations than tolerated.
+func (s *Service) validateLoop(p *peer) {
+	defer s.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-p.term:
+		case <-s.quit:
+		}
+		cancel()
+	}()
+	for {
+		select {
+		case ops := <-p.pendingOps:
+			for _, op := range ops {
+				if err := s.addRemote(ctx, p, op); err != nil {
+					p.Log().Debug("Dropping user operation peer", "err", err)
+					p.Disconnect(p2p.DiscUselessPeer)
+					return
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// addRemote validates a user operation received from a peer and pools it if
+// accepted. Operations failing validation are remembered so they are never
+// simulated again, and count against the peer.
+func (s *Service) addRemote(ctx context.Context, p *peer, op *UserOperation) error {
+	hash := s.pool.Hash(op)
+	if s.pool.Has(hash) {
+		return nil
+	}
+	select {
+	case s.simSlots <- struct{}{}:
+	case <-ctx.Done():
+		return nil
+	}
+	err := s.check(ctx, op)
+	<-s.simSlots
+
+	if err != nil {
+		// Aborted simulations say nothing about the operation
+		if ctx.Err() != nil {
+			return nil
+		}
+		rejectedMeter.Mark(1)
+		s.rejected.Add(hash, struct{}{})
+		p.Log().Trace("Rejected remote user operation", "hash", hash, "err", err)
+		return p.penalise()
+	}
+	if _, err := s.pool.Add(op); err != nil {
+		rejectedMeter.Mark(1)
+		p.Log().Trace("Rejected remote user operation", "hash", hash, "err", err)
+		return nil
+	}
+	acceptedMeter.Mark(1)
+	log.Debug("Pooled user operation", "hash", hash, "sender", op.Sender, "nonce", op.Nonce)
+	return nil
+}
+
+// broadcastLoop propagates newly pooled user operations to the peers not yet
+// knowing about them.
+func (s *Service) broadcastLoop() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case ev := <-s.opsCh:
+			batches := make(map[*peer][]*UserOperation)
+			for _, op := range ev.Ops {
+				for _, p := range s.peers.peersWithoutOp(s.pool.Hash(op)) {
+					batches[p] = append(batches[p], op)
+				}
+			}
+			for p, ops := range batches {
+				p.asyncSendOps(ops, s.hashes(ops))
+			}
+
+		// Err() channel will be closed when unsubscribing.
+		case <-s.opsSub.Err():
+			return
+		}
+	}
+}
+
+// expiryLoop periodically drops the user operations which stayed pooled for
+// longer than the configured lifetime.
+func (s *Service) expiryLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(expiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if dropped := s.pool.Expire(now); dropped > 0 {
+				log.Debug("Expired user operations", "count", dropped, "pooled", s.pool.Len())
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// hashes returns the pool hashes of a batch of user operations.
+func (s *Service) hashes(ops []*UserOperation) []common.Hash {
+	hashes := make([]common.Hash, len(ops))
+	for i, op := range ops {
+		hashes[i] = s.pool.Hash(op)
+	}
+	return hashes
+}
+
+// opGas returns the total gas a user operation may consume in a bundle,
+// capped at math.MaxUint64.
+func opGas(op *UserOperation) uint64 {
+	gas := op.CallGasLimit
+	for _, limit := range []uint64{op.VerificationGasLimit, op.PreVerificationGas} {
+		if gas+limit < gas {
+			return math.MaxUint64
+		}
+		gas += limit
+	}
+	return gas
+}
@@ -0,0 +1,135 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package userop
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/golang-lru"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/p2p"
+	"github.com/matrix/go-matrix/p2p/discover"
+)
+
+func testService() *Service {
+	config := DefaultConfig
+	config.EntryPoint = common.Address{0xee}
+
+	rejected, _ := lru.New(maxRejectedOps)
+	return &Service{
+		config:   &config,
+		pool:     NewPool(&config, big.NewInt(1)),
+		peers:    newPeerSet(),
+		rejected: rejected,
+		simSlots: make(chan struct{}, maxSimulations),
+		quit:     make(chan struct{}),
+	}
+}
+
+// testGossip delivers a batch of user operations from the peer to the service,
+// returning the error of the message handler.
+func testGossip(t *testing.T, s *Service, p *peer, remote p2p.MsgReadWriter, ops []*UserOperation) error {
+	errc := make(chan error, 1)
+	go func() { errc <- p2p.Send(remote, UserOpsMsg, ops) }()
+
+	err := s.handleMsg(p)
+	if err := <-errc; err != nil {
+		t.Fatalf("failed to send operations: %v", err)
+	}
+	return err
+}
+
+// Tests that a peer can only have a bounded number of operations simulated,
+// the ones above its budget being dropped without simulation.
+func TestRemoteOpsThrottling(t *testing.T) {
+	s := testService()
+
+	local, remote := p2p.MsgPipe()
+	defer local.Close()
+	p := newPeer(p2p.NewPeer(discover.NodeID{1}, "test", nil), local)
+
+	ops := make([]*UserOperation, opsBurst+8)
+	for i := range ops {
+		ops[i] = testOp(byte(i), 0, 10)
+	}
+	if err := testGossip(t, s, p, remote, ops); err != nil {
+		t.Fatalf("failed to handle operations: %v", err)
+	}
+	select {
+	case pending := <-p.pendingOps:
+		if len(pending) != opsBurst {
+			t.Fatalf("queued operation count mismatch: have %d, want %d", len(pending), opsBurst)
+		}
+	default:
+		t.Fatalf("no operations queued for validation")
+	}
+	if err := testGossip(t, s, p, remote, []*UserOperation{testOp(0xff, 0, 10)}); err != nil {
+		t.Fatalf("failed to handle operations: %v", err)
+	}
+	if len(p.pendingOps) != 0 {
+		t.Fatalf("operation over budget queued for validation")
+	}
+}
+
+// Tests that operations known to be invalid are never simulated again and that
+// a peer resending them is eventually dropped.
+func TestRemoteOpsRejected(t *testing.T) {
+	s := testService()
+
+	local, remote := p2p.MsgPipe()
+	defer local.Close()
+	p := newPeer(p2p.NewPeer(discover.NodeID{1}, "test", nil), local)
+
+	op := testOp(1, 0, 10)
+	s.rejected.Add(s.pool.Hash(op), struct{}{})
+
+	for i := 0; i < maxFaults; i++ {
+		if err := testGossip(t, s, p, remote, []*UserOperation{op}); err != nil {
+			t.Fatalf("rejection %d: peer dropped early: %v", i, err)
+		}
+	}
+	if len(p.pendingOps) != 0 {
+		t.Fatalf("rejected operation queued for validation")
+	}
+	if err := testGossip(t, s, p, remote, []*UserOperation{op}); err != errTooManyInvalidOps {
+		t.Fatalf("error mismatch: have %v, want %v", err, errTooManyInvalidOps)
+	}
+}
+
+func TestBucket(t *testing.T) {
+	var (
+		b   = newBucket(2, 3)
+		now = time.Now()
+	)
+	for i := 0; i < 3; i++ {
+		if !b.take(now) {
+			t.Fatalf("token %d not available", i)
+		}
+	}
+	if b.take(now) {
+		t.Fatalf("token available from empty bucket")
+	}
+	if !b.take(now.Add(500*time.Millisecond)) || b.take(now.Add(500*time.Millisecond)) {
+		t.Fatalf("refill mismatch after half a second")
+	}
+	if !b.take(now.Add(time.Hour)) || !b.take(now.Add(time.Hour)) || !b.take(now.Add(time.Hour)) || b.take(now.Add(time.Hour)) {
+		t.Fatalf("bucket refilled above its capacity")
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package userop implements an experimental mempool lane for account
+// abstraction user operations, validated by simulating a configurable
+// entrypoint contract, gossiped over a dedicated subprotocol and packed into
+// entrypoint transactions by a bundler API.
+package userop
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/common/hexutil"
+	"github.com/matrix/go-matrix/crypto"
+)
+
+// UserOperation is an account abstraction operation, executed on behalf of a
+// smart contract account by the entrypoint contract.
+type UserOperation struct {
+	Sender               common.Address
+	Nonce                *big.Int
+	InitCode             []byte
+	CallData             []byte
+	CallGasLimit         uint64
+	VerificationGasLimit uint64
+	PreVerificationGas   uint64
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+	PaymasterAndData     []byte
+	Signature            []byte
+}
+
+// Hash returns the identifier of the operation as computed by the entrypoint
+// contract: the hash of the operation without its signature, bound to the
+// entrypoint and the chain.
+func (op *UserOperation) Hash(entryPoint common.Address, chainID *big.Int) common.Hash {
+	inner := crypto.Keccak256(
+		abiAddress(op.Sender),
+		abiUint(op.Nonce),
+		crypto.Keccak256(op.InitCode),
+		crypto.Keccak256(op.CallData),
+		abiUint64(op.CallGasLimit),
+		abiUint64(op.VerificationGasLimit),
+		abiUint64(op.PreVerificationGas),
+		abiUint(op.MaxFeePerGas),
+		abiUint(op.MaxPriorityFeePerGas),
+		crypto.Keccak256(op.PaymasterAndData),
+	)
+	return crypto.Keccak256Hash(inner, abiAddress(entryPoint), abiUint(chainID))
+}
+
+// validate performs the sanity checks not requiring any chain state.
+func (op *UserOperation) validate() error {
+	switch {
+	case op.Sender == (common.Address{}):
+		return errors.New("missing sender")
+	case op.Nonce == nil || op.Nonce.Sign() < 0:
+		return errors.New("invalid nonce")
+	case op.MaxFeePerGas == nil || op.MaxFeePerGas.Sign() < 0:
+		return errors.New("invalid max fee per gas")
+	case op.MaxPriorityFeePerGas == nil || op.MaxPriorityFeePerGas.Sign() < 0:
+		return errors.New("invalid max priority fee per gas")
+	case op.MaxPriorityFeePerGas.Cmp(op.MaxFeePerGas) > 0:
+		return errors.New("max priority fee per gas higher than max fee per gas")
+	case op.CallGasLimit == 0 && len(op.CallData) > 0:
+		return errors.New("call gas limit required for call data")
+	}
+	return nil
+}
+
+// RPCUserOperation is the JSON representation of a user operation, using the
+// field names of the ERC-4337 bundler RPC.
+type RPCUserOperation struct {
+	Sender               common.Address `json:"sender"`
+	Nonce                *hexutil.Big   `json:"nonce"`
+	InitCode             hexutil.Bytes  `json:"initCode"`
+	CallData             hexutil.Bytes  `json:"callData"`
+	CallGasLimit         hexutil.Uint64 `json:"callGasLimit"`
+	VerificationGasLimit hexutil.Uint64 `json:"verificationGasLimit"`
+	PreVerificationGas   hexutil.Uint64 `json:"preVerificationGas"`
+	MaxFeePerGas         *hexutil.Big   `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big   `json:"maxPriorityFeePerGas"`
+	PaymasterAndData     hexutil.Bytes  `json:"paymasterAndData"`
+	Signature            hexutil.Bytes  `json:"signature"`
+}
+
+// newRPCUserOperation converts a user operation into its JSON representation.
+func newRPCUserOperation(op *UserOperation) *RPCUserOperation {
+	return &RPCUserOperation{
+		Sender:               op.Sender,
+		Nonce:                (*hexutil.Big)(op.Nonce),
+		InitCode:             op.InitCode,
+		CallData:             op.CallData,
+		CallGasLimit:         hexutil.Uint64(op.CallGasLimit),
+		VerificationGasLimit: hexutil.Uint64(op.VerificationGasLimit),
+		PreVerificationGas:   hexutil.Uint64(op.PreVerificationGas),
+		MaxFeePerGas:         (*hexutil.Big)(op.MaxFeePerGas),
+		MaxPriorityFeePerGas: (*hexutil.Big)(op.MaxPriorityFeePerGas),
+		PaymasterAndData:     op.PaymasterAndData,
+		Signature:            op.Signature,
+	}
+}
+
+// toUserOperation converts the JSON representation back into an operation.
+func (args *RPCUserOperation) toUserOperation() *UserOperation {
+	return &UserOperation{
+		Sender:               args.Sender,
+		Nonce:                args.Nonce.ToInt(),
+		InitCode:             args.InitCode,
+		CallData:             args.CallData,
+		CallGasLimit:         uint64(args.CallGasLimit),
+		VerificationGasLimit: uint64(args.VerificationGasLimit),
+		PreVerificationGas:   uint64(args.PreVerificationGas),
+		MaxFeePerGas:         args.MaxFeePerGas.ToInt(),
+		MaxPriorityFeePerGas: args.MaxPriorityFeePerGas.ToInt(),
+		PaymasterAndData:     args.PaymasterAndData,
+		Signature:            args.Signature,
+	}
+}
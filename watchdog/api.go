@@ -0,0 +1,42 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package watchdog
+
+import "time"
+
+// HeartStatus is the heartbeat state of a monitored loop.
+type HeartStatus struct {
+	Name     string     `json:"name"`
+	LastBeat *time.Time `json:"lastBeat"` // Nil while the loop is idle
+	Stalled  bool       `json:"stalled"`
+}
+
+// PrivateWatchdogAPI exposes the state of the watchdog over the debug
+// namespace.
+type PrivateWatchdogAPI struct {
+	w *Watchdog
+}
+
+// NewPrivateWatchdogAPI creates a new API for the given watchdog.
+func NewPrivateWatchdogAPI(w *Watchdog) *PrivateWatchdogAPI {
+	return &PrivateWatchdogAPI{w}
+}
+
+// Heartbeats returns the heartbeat state of all monitored loops.
+func (api *PrivateWatchdogAPI) Heartbeats() []HeartStatus {
+	return api.w.Status()
+}
@@ -0,0 +1,45 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package watchdog
+
+import "time"
+
+// Actions the watchdog can take once a monitored loop stopped beating, on top
+// of logging the stall.
+const (
+	ActionDump    = "dump"    // Dump the goroutine stacks of the node
+	ActionRestart = "restart" // Dump the stacks and restart the stalled subsystem
+	ActionExit    = "exit"    // Dump the stacks and terminate the node
+)
+
+// DefaultConfig contains default settings for the watchdog.
+var DefaultConfig = Config{
+	Timeout: 3 * time.Minute,
+	Action:  ActionDump,
+}
+
+// Config contains the configuration parameters of the watchdog.
+type Config struct {
+	// Timeout is the time a monitored loop may go without beating before it
+	// is considered stalled.
+	Timeout time.Duration `toml:",omitempty"`
+
+	// Action is the remedy applied to stalled loops, one of dump, restart or
+	// exit. Restarting is only possible for subsystems supporting it, others
+	// fall back to dumping the stacks.
+	Action string `toml:",omitempty"`
+}
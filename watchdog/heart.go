@@ -0,0 +1,114 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package watchdog
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BeatInterval is the rate at which monitored loops are expected to beat while
+// they are active. The watchdog timeout should be a generous multiple of it.
+const BeatInterval = 5 * time.Second
+
+var (
+	hearts   = make(map[string]*Heart)
+	heartsMu sync.RWMutex
+)
+
+// Heart is the liveness signal of a single long running loop. The loop calls
+// Beat regularly while it is active and Idle once it deliberately parks or
+// terminates, so that only wedged loops are reported by the watchdog.
+type Heart struct {
+	name string
+	last int64 // Unix nanoseconds of the last beat, zero while idle
+
+	restart func() // Optional hook restarting the owning subsystem
+	lock    sync.Mutex
+}
+
+// Register returns the heart of the loop with the given name, creating it on
+// first use. Newly created hearts are idle until their first beat.
+func Register(name string) *Heart {
+	heartsMu.Lock()
+	defer heartsMu.Unlock()
+
+	if h, ok := hearts[name]; ok {
+		return h
+	}
+	h := &Heart{name: name}
+	hearts[name] = h
+	return h
+}
+
+// Hearts returns all registered hearts, sorted by name.
+func Hearts() []*Heart {
+	heartsMu.RLock()
+	defer heartsMu.RUnlock()
+
+	list := make([]*Heart, 0, len(hearts))
+	for _, h := range hearts {
+		list = append(list, h)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
+// Name returns the name of the loop the heart belongs to.
+func (h *Heart) Name() string {
+	return h.name
+}
+
+// Beat signals that the loop is alive.
+func (h *Heart) Beat() {
+	atomic.StoreInt64(&h.last, time.Now().UnixNano())
+}
+
+// Idle signals that the loop is deliberately not running, suspending the
+// monitoring until the next beat.
+func (h *Heart) Idle() {
+	atomic.StoreInt64(&h.last, 0)
+}
+
+// LastBeat returns the time of the last beat, or the zero time if the loop
+// is idle.
+func (h *Heart) LastBeat() time.Time {
+	last := atomic.LoadInt64(&h.last)
+	if last == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last)
+}
+
+// OnStall sets the hook restarting the owning subsystem if the watchdog finds
+// the loop stalled and is configured to restart it.
+func (h *Heart) OnStall(restart func()) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.restart = restart
+}
+
+// restartHook returns the currently configured restart hook, if any.
+func (h *Heart) restartHook() func() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.restart
+}
@@ -0,0 +1,206 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package watchdog monitors the heartbeats of the critical node loops and
+// collects diagnostics when one of them wedges.
+package watchdog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/matrix/go-matrix/common"
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/metrics"
+	"github.com/matrix/go-matrix/p2p"
+	"github.com/matrix/go-matrix/rpc"
+)
+
+var stallMeter = metrics.NewRegisteredMeter("watchdog/stalls", nil)
+
+// Watchdog is a node service periodically checking the registered hearts. If
+// a loop stops beating for longer than the configured timeout, the goroutine
+// stacks of the node are dumped and the configured remedy is applied.
+type Watchdog struct {
+	config  *Config
+	dumpDir string // Directory to write stack dumps into, stderr if empty
+
+	stalled map[string]time.Time // Loops reported stalled, mapped to their last beat
+	lock    sync.Mutex
+
+	exit func(loop string) // Terminates the node, replaceable for testing
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a watchdog writing its stack dumps into dumpDir.
+func New(config *Config, dumpDir string) (*Watchdog, error) {
+	switch config.Action {
+	case ActionDump, ActionRestart, ActionExit:
+	default:
+		return nil, fmt.Errorf("unknown watchdog action %q", config.Action)
+	}
+	if config.Timeout < 2*BeatInterval {
+		return nil, fmt.Errorf("watchdog timeout %v below twice the beat interval %v", config.Timeout, BeatInterval)
+	}
+	return &Watchdog{
+		config:  config,
+		dumpDir: dumpDir,
+		stalled: make(map[string]time.Time),
+		exit: func(loop string) {
+			log.Crit("Watchdog terminating node", "loop", loop)
+		},
+		quit: make(chan struct{}),
+	}, nil
+}
+
+// Protocols implements node.Service, returning the P2P network protocols used
+// by the watchdog (none).
+func (w *Watchdog) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the RPC API endpoints provided by
+// the watchdog.
+func (w *Watchdog) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "debug",
+			Version:   "1.0",
+			Service:   NewPrivateWatchdogAPI(w),
+		},
+	}
+}
+
+// Start implements node.Service, starting the monitoring loop.
+func (w *Watchdog) Start(server *p2p.Server) error {
+	w.wg.Add(1)
+	go w.loop()
+
+	log.Info("Started watchdog", "timeout", w.config.Timeout, "action", w.config.Action)
+	return nil
+}
+
+// Stop implements node.Service, terminating the monitoring loop.
+func (w *Watchdog) Stop() error {
+	close(w.quit)
+	w.wg.Wait()
+
+	log.Info("Watchdog stopped")
+	return nil
+}
+
+// loop checks the hearts a few times per timeout period.
+func (w *Watchdog) loop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.config.Timeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			w.check(now)
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// check inspects all registered hearts, handling the ones that newly stalled
+// since the last run and clearing the ones that recovered.
+func (w *Watchdog) check(now time.Time) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, h := range Hearts() {
+		last := h.LastBeat()
+		if last.IsZero() || now.Sub(last) < w.config.Timeout {
+			if _, ok := w.stalled[h.name]; ok {
+				log.Warn("Watchdog loop recovered", "loop", h.name)
+				delete(w.stalled, h.name)
+			}
+			continue
+		}
+		// Loop silent for too long, only react once per stall
+		if prev, ok := w.stalled[h.name]; ok && prev.Equal(last) {
+			continue
+		}
+		w.stalled[h.name] = last
+		stallMeter.Mark(1)
+
+		log.Error("Watchdog detected stalled loop", "loop", h.name, "silent", common.PrettyDuration(now.Sub(last)))
+		w.dump(h.name, now)
+
+		switch w.config.Action {
+		case ActionRestart:
+			if restart := h.restartHook(); restart != nil {
+				log.Warn("Watchdog restarting stalled subsystem", "loop", h.name)
+				go restart()
+			} else {
+				log.Warn("Stalled subsystem cannot be restarted", "loop", h.name)
+			}
+		case ActionExit:
+			w.exit(h.name)
+		}
+	}
+}
+
+// dump writes the goroutine stacks of the node into a new file in the dump
+// directory, or to stderr if no directory is configured.
+func (w *Watchdog) dump(loop string, now time.Time) {
+	var out io.Writer = os.Stderr
+	if w.dumpDir != "" {
+		if err := os.MkdirAll(w.dumpDir, 0700); err != nil {
+			log.Error("Failed to create stack dump directory", "err", err)
+			return
+		}
+		path := filepath.Join(w.dumpDir, fmt.Sprintf("stacks-%s-%d.txt", loop, now.Unix()))
+		file, err := os.Create(path)
+		if err != nil {
+			log.Error("Failed to create stack dump", "err", err)
+			return
+		}
+		defer file.Close()
+
+		out = file
+		log.Error("Dumping goroutine stacks", "loop", loop, "file", path)
+	}
+	if err := pprof.Lookup("goroutine").WriteTo(out, 2); err != nil {
+		log.Error("Failed to dump goroutine stacks", "err", err)
+	}
+}
+
+// Status returns the current heartbeat state of all monitored loops.
+func (w *Watchdog) Status() []HeartStatus {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	hearts := Hearts()
+	status := make([]HeartStatus, 0, len(hearts))
+	for _, h := range hearts {
+		s := HeartStatus{Name: h.name}
+		if last := h.LastBeat(); !last.IsZero() {
+			s.LastBeat = &last
+		}
+		_, s.Stalled = w.stalled[h.name]
+		status = append(status, s)
+	}
+	return status
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package watchdog
+
+import (
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Tests that stalled loops are reported once with a stack dump and restarted,
+// while idle and healthy loops are left alone.
+func TestWatchdogStall(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watchdog-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := New(&Config{Timeout: time.Minute, Action: ActionRestart}, dir)
+	if err != nil {
+		t.Fatalf("failed to create watchdog: %v", err)
+	}
+	restarts := make(chan struct{}, 2)
+
+	stalled := Register("test-stalled")
+	stalled.OnStall(func() { restarts <- struct{}{} })
+	stalled.Beat()
+	defer stalled.Idle()
+
+	healthy := Register("test-healthy")
+	healthy.Beat()
+	defer healthy.Idle()
+
+	Register("test-idle")
+
+	// Nothing stalled yet, no reaction expected
+	w.check(time.Now())
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("stack dumps mismatch: have %d, want 0", len(files))
+	}
+	// Advance past the timeout while keeping the healthy loop beating
+	now := time.Now().Add(2 * time.Minute)
+	beatAt(healthy, now)
+
+	w.check(now)
+	w.check(now.Add(time.Second))
+
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("stack dumps mismatch: have %d, want 1", len(files))
+	}
+	select {
+	case <-restarts:
+	case <-time.After(time.Second):
+		t.Fatalf("stalled loop not restarted")
+	}
+	select {
+	case <-restarts:
+		t.Fatalf("stalled loop restarted twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+	for _, s := range w.Status() {
+		want := s.Name == "test-stalled"
+		if s.Stalled != want {
+			t.Errorf("loop %s: stalled mismatch: have %v, want %v", s.Name, s.Stalled, want)
+		}
+		if (s.LastBeat == nil) != (s.Name == "test-idle") {
+			t.Errorf("loop %s: idle mismatch", s.Name)
+		}
+	}
+	// Parking the stalled loop should clear its state
+	stalled.Idle()
+	w.check(now)
+	for _, s := range w.Status() {
+		if s.Stalled {
+			t.Errorf("loop %s still stalled after going idle", s.Name)
+		}
+	}
+}
+
+// Tests that the exit action terminates the node on stalls.
+func TestWatchdogExit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watchdog-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := New(&Config{Timeout: time.Minute, Action: ActionExit}, dir)
+	if err != nil {
+		t.Fatalf("failed to create watchdog: %v", err)
+	}
+
+	var exited string
+	w.exit = func(loop string) { exited = loop }
+
+	heart := Register("test-exit")
+	defer heart.Idle()
+
+	heart.Beat()
+	w.check(time.Now().Add(2 * time.Minute))
+
+	if exited != "test-exit" {
+		t.Fatalf("exit loop mismatch: have %q, want %q", exited, "test-exit")
+	}
+}
+
+// Tests that invalid configurations are rejected.
+func TestWatchdogConfig(t *testing.T) {
+	if _, err := New(&Config{Timeout: time.Minute, Action: "reboot"}, ""); err == nil {
+		t.Errorf("unknown action accepted")
+	}
+	if _, err := New(&Config{Timeout: BeatInterval, Action: ActionDump}, ""); err == nil {
+		t.Errorf("timeout below the beat interval accepted")
+	}
+}
+
+// beatAt sets the last beat of a heart to an arbitrary time.
+func beatAt(h *Heart, t time.Time) {
+	atomic.StoreInt64(&h.last, t.UnixNano())
+}
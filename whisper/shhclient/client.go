@@ -191,3 +191,10 @@ func (sc *Client) FilterMessages(ctx context.Context, id string) ([]*whisper.Mes
 	var messages []*whisper.Message
 	return messages, sc.c.CallContext(ctx, &messages, "shh_getFilterMessages", id)
 }
+
+// MissedMessages retrieves the messages persisted by the node that match the
+// criteria and were received at or after since, a unix timestamp.
+func (sc *Client) MissedMessages(ctx context.Context, criteria whisper.Criteria, since uint32) ([]*whisper.Message, error) {
+	var messages []*whisper.Message
+	return messages, sc.c.CallContext(ctx, &messages, "shh_getMissedMessages", criteria, since)
+}
@@ -40,6 +40,8 @@ var (
 	ErrInvalidSigningPubKey = errors.New("invalid signing public key")
 	ErrTooLowPoW            = errors.New("message rejected, PoW too low")
 	ErrNoTopics             = errors.New("missing topic(s)")
+	ErrTooManyFilters       = errors.New("too many filters installed for the key")
+	ErrNoMessageStore       = errors.New("message persistence is disabled")
 )
 
 // PublicWhisperAPI provides the whisper RPC service that can be
@@ -526,6 +528,53 @@ func (api *PublicWhisperAPI) DeleteMessageFilter(id string) (bool, error) {
 // NewMessageFilter creates a new filter that can be used to poll for
 // (new) messages that satisfy the given criteria.
 func (api *PublicWhisperAPI) NewMessageFilter(req Criteria) (string, error) {
+	f, err := api.criteriaFilter(req)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := api.w.Subscribe(f)
+	if err != nil {
+		return "", err
+	}
+
+	api.mu.Lock()
+	api.lastUsed[id] = time.Now()
+	api.mu.Unlock()
+
+	return id, nil
+}
+
+// GetMissedMessages returns the persisted messages that satisfy the given
+// criteria and were received at or after since, a unix timestamp. Messages
+// sent while the client or the node was restarting are recovered this way.
+func (api *PublicWhisperAPI) GetMissedMessages(req Criteria, since uint32) ([]*Message, error) {
+	f, err := api.criteriaFilter(req)
+	if err != nil {
+		return nil, err
+	}
+	envelopes, err := api.w.MissedMessages(req.Topics, since)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*Message, 0)
+	for _, env := range envelopes {
+		env.PoW() // stored envelopes come without their PoW computed
+		if !f.MatchEnvelope(env) {
+			continue
+		}
+		msg := env.Open(f)
+		if msg == nil || (f.Src != nil && !IsPubKeyEqual(msg.Src, f.Src)) {
+			continue
+		}
+		messages = append(messages, ToWhisperMessage(msg))
+	}
+	return messages, nil
+}
+
+// criteriaFilter creates the filter of messages satisfying the criteria.
+func (api *PublicWhisperAPI) criteriaFilter(req Criteria) (*Filter, error) {
 	var (
 		src     *ecdsa.PublicKey
 		keySym  []byte
@@ -540,28 +589,28 @@ func (api *PublicWhisperAPI) NewMessageFilter(req Criteria) (string, error) {
 
 	// user must specify either a symmetric or an asymmetric key
 	if (symKeyGiven && asymKeyGiven) || (!symKeyGiven && !asymKeyGiven) {
-		return "", ErrSymAsym
+		return nil, ErrSymAsym
 	}
 
 	if len(req.Sig) > 0 {
 		src = crypto.ToECDSAPub(req.Sig)
 		if !ValidatePublicKey(src) {
-			return "", ErrInvalidSigningPubKey
+			return nil, ErrInvalidSigningPubKey
 		}
 	}
 
 	if symKeyGiven {
 		if keySym, err = api.w.GetSymKey(req.SymKeyID); err != nil {
-			return "", err
+			return nil, err
 		}
 		if !validateDataIntegrity(keySym, aesKeyLength) {
-			return "", ErrInvalidSymmetricKey
+			return nil, ErrInvalidSymmetricKey
 		}
 	}
 
 	if asymKeyGiven {
 		if keyAsym, err = api.w.GetPrivateKey(req.PrivateKeyID); err != nil {
-			return "", err
+			return nil, err
 		}
 	}
 
@@ -573,7 +622,7 @@ func (api *PublicWhisperAPI) NewMessageFilter(req Criteria) (string, error) {
 		}
 	}
 
-	f := &Filter{
+	return &Filter{
 		Src:      src,
 		KeySym:   keySym,
 		KeyAsym:  keyAsym,
@@ -581,16 +630,5 @@ func (api *PublicWhisperAPI) NewMessageFilter(req Criteria) (string, error) {
 		AllowP2P: req.AllowP2P,
 		Topics:   topics,
 		Messages: make(map[common.Hash]*ReceivedMessage),
-	}
-
-	id, err := api.w.Subscribe(f)
-	if err != nil {
-		return "", err
-	}
-
-	api.mu.Lock()
-	api.lastUsed[id] = time.Now()
-	api.mu.Unlock()
-
-	return id, nil
+	}, nil
 }
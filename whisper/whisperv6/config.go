@@ -20,10 +20,17 @@ package whisperv6
 type Config struct {
 	MaxMessageSize     uint32  `toml:",omitempty"`
 	MinimumAcceptedPOW float64 `toml:",omitempty"`
+	MaxIdentityFilters int     `toml:",omitempty"` // filters allowed per key, 0 for no limit
+	PersistMessages    bool    `toml:",omitempty"` // keeps the messages of the subscribed topics across restarts
+	MessageRetention   uint32  `toml:",omitempty"` // seconds the persisted messages are kept
+	MaxTopicMessages   int     `toml:",omitempty"` // persisted messages kept per topic, 0 for no limit
 }
 
 // DefaultConfig represents (shocker!) the default configuration.
 var DefaultConfig = Config{
 	MaxMessageSize:     DefaultMaxMessageSize,
 	MinimumAcceptedPOW: DefaultMinimumPoW,
+	MaxIdentityFilters: DefaultMaxIdentityFilters,
+	MessageRetention:   DefaultMessageRetention,
+	MaxTopicMessages:   DefaultMaxTopicMessages,
 }
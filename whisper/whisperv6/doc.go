@@ -77,6 +77,12 @@ const (
 
 	DefaultTTL           = 50 // seconds
 	DefaultSyncAllowance = 10 // seconds
+
+	DefaultMaxIdentityFilters = 64
+	DefaultMessageRetention   = 24 * 60 * 60 // seconds
+	DefaultMaxTopicMessages   = 4096
+
+	storePruneCycle = time.Minute
 )
 
 type unknownVersionError uint64
@@ -48,6 +48,8 @@ type Filters struct {
 	topicMatcher     map[TopicType]map[*Filter]struct{} // map a topic to the filters that are interested in being notified when a message matches that topic
 	allTopicsMatcher map[*Filter]struct{}               // list all the filters that will be notified of a new message, no matter what its topic is
 
+	maxPerIdentity int // filters allowed per key, 0 for no limit
+
 	whisper *Whisper
 	mutex   sync.RWMutex
 }
@@ -83,6 +85,9 @@ func (fs *Filters) Install(watcher *Filter) (string, error) {
 	if fs.watchers[id] != nil {
 		return "", fmt.Errorf("failed to generate unique ID")
 	}
+	if fs.maxPerIdentity > 0 && fs.countByIdentity(watcher.identity()) >= fs.maxPerIdentity {
+		return "", ErrTooManyFilters
+	}
 
 	if watcher.expectsSymmetricEncryption() {
 		watcher.SymKeyHash = crypto.Keccak256Hash(watcher.KeySym)
@@ -107,6 +112,18 @@ func (fs *Filters) Uninstall(id string) bool {
 	return false
 }
 
+// countByIdentity returns the number of filters installed for a key,
+// caller must hold the lock
+func (fs *Filters) countByIdentity(identity string) int {
+	var n int
+	for _, watcher := range fs.watchers {
+		if watcher.identity() == identity {
+			n++
+		}
+	}
+	return n
+}
+
 // addTopicMatcher adds a filter to the topic matchers.
 // If the filter's Topics array is empty, it will be tried on every topic.
 // Otherwise, it will be tried on the topics specified.
@@ -152,6 +169,17 @@ func (fs *Filters) Get(id string) *Filter {
 	return fs.watchers[id]
 }
 
+// All returns the filters of the collection
+func (fs *Filters) All() []*Filter {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+	all := make([]*Filter, 0, len(fs.watchers))
+	for _, watcher := range fs.watchers {
+		all = append(all, watcher)
+	}
+	return all
+}
+
 // NotifyWatchers notifies any filter that has declared interest
 // for the envelope's topic.
 func (fs *Filters) NotifyWatchers(env *Envelope, p2pMessage bool) {
@@ -191,6 +219,15 @@ func (fs *Filters) NotifyWatchers(env *Envelope, p2pMessage bool) {
 	}
 }
 
+// identity returns the key the filter decrypts messages with, which
+// identifies the party the filter was installed for
+func (f *Filter) identity() string {
+	if f.expectsAsymmetricEncryption() {
+		return string(crypto.FromECDSAPub(&f.KeyAsym.PublicKey))
+	}
+	return string(crypto.Keccak256(f.KeySym))
+}
+
 func (f *Filter) expectsAsymmetricEncryption() bool {
 	return f.KeyAsym != nil
 }
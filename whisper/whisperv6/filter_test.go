@@ -132,6 +132,44 @@ func TestInstallFilters(t *testing.T) {
 	}
 }
 
+func TestInstallFiltersPerIdentity(t *testing.T) {
+	InitSingleTest()
+
+	w := New(&Config{MaxIdentityFilters: 2})
+	filters := NewFilters(w)
+	filters.maxPerIdentity = 2
+
+	f, err := generateFilter(t, true)
+	if err != nil {
+		t.Fatalf("seed %d: failed to generate filter: %s", seed, err)
+	}
+	var ids []string
+	for i := 0; i < 2; i++ {
+		id, err := filters.Install(&Filter{KeySym: f.KeySym, Topics: f.Topics})
+		if err != nil {
+			t.Fatalf("seed %d: failed to install filter %d: %s", seed, i, err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := filters.Install(&Filter{KeySym: f.KeySym, Topics: f.Topics}); err != ErrTooManyFilters {
+		t.Fatalf("seed %d: have error %v over the limit, want %v", seed, err, ErrTooManyFilters)
+	}
+
+	// other keys have their own quota
+	g, err := generateFilter(t, false)
+	if err != nil {
+		t.Fatalf("seed %d: failed to generate filter: %s", seed, err)
+	}
+	if _, err := filters.Install(g); err != nil {
+		t.Fatalf("seed %d: failed to install filter of another key: %s", seed, err)
+	}
+
+	filters.Uninstall(ids[0])
+	if _, err := filters.Install(&Filter{KeySym: f.KeySym, Topics: f.Topics}); err != nil {
+		t.Fatalf("seed %d: failed to install filter after uninstall: %s", seed, err)
+	}
+}
+
 func TestInstallSymKeyGeneratesHash(t *testing.T) {
 	InitSingleTest()
 
@@ -0,0 +1,281 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package whisperv6
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/matrix/go-matrix/log"
+	"github.com/matrix/go-matrix/rlp"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+var (
+	storeTopicPrefix    = []byte("t") // topic -> time the last filter on it was removed, 0 while subscribed
+	storeEnvelopePrefix = []byte("e") // topic + arrival + hash -> envelope
+)
+
+// messageStore keeps the envelopes received on the subscribed topics on disk,
+// so that the messages sent while a client or the node itself was restarting
+// can still be fetched. A topic stays queued for the retention period after
+// its last filter is gone, long enough for the clients to come back and
+// subscribe again.
+type messageStore struct {
+	path      string // database location, in memory if empty
+	retention uint32 // seconds the envelopes and the released topics are kept
+	limit     int    // envelopes kept per topic, 0 for no limit
+
+	mu     sync.Mutex
+	db     *leveldb.DB // nil while closed
+	topics map[TopicType]*storeTopic
+}
+
+// storeTopic is the subscription state of a queued topic
+type storeTopic struct {
+	filters  int    // filters installed on the topic
+	released uint32 // time the last filter was removed
+}
+
+// openMessageStore opens the message store at path, in memory if the path is
+// empty.
+func openMessageStore(path string, retention uint32, limit int, now uint32) (*messageStore, error) {
+	store := &messageStore{path: path, retention: retention, limit: limit}
+	if _, err := store.open(now); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// open opens the database if closed and loads the queued topics, reporting
+// whether it was closed. The topics subscribed to when the store was closed
+// are released at now, the filters still installed must subscribe again.
+func (store *messageStore) open(now uint32) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.db != nil {
+		return false, nil
+	}
+	var (
+		db  *leveldb.DB
+		err error
+	)
+	if store.path == "" {
+		db, err = leveldb.Open(storage.NewMemStorage(), nil)
+	} else {
+		db, err = leveldb.OpenFile(store.path, nil)
+	}
+	if err != nil {
+		return false, err
+	}
+	store.db = db
+	store.topics = make(map[TopicType]*storeTopic)
+
+	it := db.NewIterator(util.BytesPrefix(storeTopicPrefix), nil)
+	defer it.Release()
+	for it.Next() {
+		topic := BytesToTopic(it.Key()[len(storeTopicPrefix):])
+		released := binary.BigEndian.Uint32(it.Value())
+		if released == 0 {
+			released = now
+			store.putTopic(topic, released)
+		}
+		store.topics[topic] = &storeTopic{released: released}
+	}
+	return true, it.Error()
+}
+
+func topicKey(topic TopicType) []byte {
+	return append(append([]byte{}, storeTopicPrefix...), topic[:]...)
+}
+
+// envelopeKey returns the key of the envelopes of a topic which arrived at
+// the given time, followed by the hash of the envelope if any
+func envelopeKey(topic TopicType, arrival uint32, hash []byte) []byte {
+	key := make([]byte, len(storeEnvelopePrefix)+TopicLength+4, len(storeEnvelopePrefix)+TopicLength+4+len(hash))
+	copy(key, storeEnvelopePrefix)
+	copy(key[len(storeEnvelopePrefix):], topic[:])
+	binary.BigEndian.PutUint32(key[len(storeEnvelopePrefix)+TopicLength:], arrival)
+	return append(key, hash...)
+}
+
+// envelopeRange returns the key range of the envelopes of a topic which
+// arrived at or after from
+func envelopeRange(topic TopicType, from uint32) *util.Range {
+	prefix := append(append([]byte{}, storeEnvelopePrefix...), topic[:]...)
+	return &util.Range{Start: envelopeKey(topic, from, nil), Limit: util.BytesPrefix(prefix).Limit}
+}
+
+// putTopic records the release time of a topic, caller must hold the lock
+func (store *messageStore) putTopic(topic TopicType, released uint32) {
+	if store.db == nil {
+		return
+	}
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, released)
+	if err := store.db.Put(topicKey(topic), value, nil); err != nil {
+		log.Warn("Failed to store whisper topic", "topic", topic.String(), "err", err)
+	}
+}
+
+// subscribe starts or keeps queueing the envelopes of the topics of a filter
+func (store *messageStore) subscribe(topics [][]byte) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, t := range topics {
+		topic := BytesToTopic(t)
+		st := store.topics[topic]
+		if st == nil {
+			st = new(storeTopic)
+			store.topics[topic] = st
+		}
+		if st.filters == 0 {
+			st.released = 0
+			store.putTopic(topic, 0)
+		}
+		st.filters++
+	}
+}
+
+// unsubscribe releases the topics of a removed filter, the ones left without
+// filters are queued until the retention period is over
+func (store *messageStore) unsubscribe(topics [][]byte, now uint32) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, t := range topics {
+		topic := BytesToTopic(t)
+		st := store.topics[topic]
+		if st == nil || st.filters == 0 {
+			continue
+		}
+		if st.filters--; st.filters == 0 {
+			st.released = now
+			store.putTopic(topic, now)
+		}
+	}
+}
+
+// archive stores an envelope received on a queued topic
+func (store *messageStore) archive(env *Envelope, now uint32) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, queued := store.topics[env.Topic]; !queued || store.db == nil {
+		return
+	}
+	data, err := rlp.EncodeToBytes(env)
+	if err != nil {
+		log.Warn("Failed to encode whisper envelope", "hash", env.Hash().Hex(), "err", err)
+		return
+	}
+	if err := store.db.Put(envelopeKey(env.Topic, now, env.Hash().Bytes()), data, nil); err != nil {
+		log.Warn("Failed to store whisper envelope", "hash", env.Hash().Hex(), "err", err)
+	}
+}
+
+// envelopes returns the stored envelopes of the topics, of all queued topics
+// if none is given, which arrived at or after from, oldest first per topic
+func (store *messageStore) envelopes(topics []TopicType, from uint32) ([]*Envelope, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.db == nil {
+		return nil, leveldb.ErrClosed
+	}
+	if len(topics) == 0 {
+		for topic := range store.topics {
+			topics = append(topics, topic)
+		}
+	}
+	var envs []*Envelope
+	for _, topic := range topics {
+		it := store.db.NewIterator(envelopeRange(topic, from), nil)
+		for it.Next() {
+			env := new(Envelope)
+			if err := rlp.DecodeBytes(it.Value(), env); err != nil {
+				log.Warn("Dropping undecodable whisper envelope", "key", it.Key(), "err", err)
+				continue
+			}
+			envs = append(envs, env)
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return nil, err
+		}
+	}
+	return envs, nil
+}
+
+// prune deletes the envelopes older than the retention period and the oldest
+// ones of the topics over the limit, then forgets the topics released for
+// longer than the retention period along with their envelopes
+func (store *messageStore) prune(now uint32) error {
+	var cutoff uint32
+	if now > store.retention {
+		cutoff = now - store.retention
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.db == nil {
+		return leveldb.ErrClosed
+	}
+	batch := new(leveldb.Batch)
+	for topic, st := range store.topics {
+		expired := st.filters == 0 && st.released < cutoff
+		if expired {
+			batch.Delete(topicKey(topic))
+			delete(store.topics, topic)
+		}
+		// walk the topic newest first, the ones past the limit or the
+		// cutoff are deleted
+		it := store.db.NewIterator(envelopeRange(topic, 0), nil)
+		kept := 0
+		for ok := it.Last(); ok; ok = it.Prev() {
+			key := it.Key()
+			arrival := binary.BigEndian.Uint32(key[len(storeEnvelopePrefix)+TopicLength:])
+			if expired || arrival < cutoff || (store.limit > 0 && kept >= store.limit) {
+				batch.Delete(append([]byte{}, key...))
+				continue
+			}
+			kept++
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return err
+		}
+	}
+	return store.db.Write(batch, nil)
+}
+
+// close closes the underlying database, an in memory store loses its content
+func (store *messageStore) close() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.db == nil {
+		return nil
+	}
+	err := store.db.Close()
+	store.db = nil
+	return err
+}
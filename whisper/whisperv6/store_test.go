@@ -0,0 +1,231 @@
+// Copyright 2018 The MATRIX Authors as well as Copyright 2014-2017 The go-ethereum Authors
+// This file is consisted of the MATRIX library and part of the go-ethereum library.
+//
+// The MATRIX-ethereum library is free software: you can redistribute it and/or modify it under the terms of the MIT License.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+// to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, 
+//and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject tothe following conditions:
+//
+//The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+//THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, 
+//WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISINGFROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+//OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package whisperv6
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func storeTestEnvelope(topic TopicType, nonce uint64) *Envelope {
+	return &Envelope{Expiry: 1000, TTL: 50, Topic: topic, Data: []byte{0x01, 0x02}, Nonce: nonce}
+}
+
+func TestMessageStore(t *testing.T) {
+	store, err := openMessageStore("", 100, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.close()
+
+	queued, other := TopicType{0x01}, TopicType{0x02}
+	store.subscribe([][]byte{queued[:]})
+	store.archive(storeTestEnvelope(queued, 1), 10)
+	store.archive(storeTestEnvelope(queued, 2), 20)
+	store.archive(storeTestEnvelope(other, 3), 20)
+
+	envs, err := store.envelopes(nil, 0)
+	if err != nil {
+		t.Fatalf("failed to read envelopes: %v", err)
+	}
+	if len(envs) != 2 || envs[0].Nonce != 1 || envs[1].Nonce != 2 {
+		t.Fatalf("have envelopes %v, want the 2 of the queued topic in order", envs)
+	}
+	if envs, _ = store.envelopes([]TopicType{queued}, 15); len(envs) != 1 || envs[0].Nonce != 2 {
+		t.Fatalf("have envelopes %v since 15, want the second one", envs)
+	}
+	if envs, _ = store.envelopes([]TopicType{other}, 0); len(envs) != 0 {
+		t.Fatalf("have %d envelopes of a topic never subscribed, want 0", len(envs))
+	}
+
+	// the first envelope ages out, the topic stays subscribed
+	if err := store.prune(115); err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	if envs, _ = store.envelopes(nil, 0); len(envs) != 1 || envs[0].Nonce != 2 {
+		t.Fatalf("have envelopes %v after pruning, want the second one", envs)
+	}
+
+	// a released topic is queued for the retention period
+	store.unsubscribe([][]byte{queued[:]}, 115)
+	store.archive(storeTestEnvelope(queued, 4), 116)
+	store.prune(200)
+	if envs, _ = store.envelopes(nil, 0); len(envs) != 1 || envs[0].Nonce != 4 {
+		t.Fatalf("have envelopes %v of the released topic, want the last one", envs)
+	}
+	store.prune(216)
+	if envs, _ = store.envelopes([]TopicType{queued}, 0); len(envs) != 0 {
+		t.Fatalf("have %d envelopes of an expired topic, want 0", len(envs))
+	}
+	if _, ok := store.topics[queued]; ok {
+		t.Fatal("expired topic still queued")
+	}
+}
+
+func TestMessageStoreLimit(t *testing.T) {
+	store, err := openMessageStore("", 100, 2, 0)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.close()
+
+	topic := TopicType{0x01}
+	store.subscribe([][]byte{topic[:]})
+	for i := uint64(1); i <= 3; i++ {
+		store.archive(storeTestEnvelope(topic, i), uint32(i))
+	}
+	store.prune(3)
+	envs, _ := store.envelopes(nil, 0)
+	if len(envs) != 2 || envs[0].Nonce != 2 || envs[1].Nonce != 3 {
+		t.Fatalf("have envelopes %v, want the 2 newest", envs)
+	}
+}
+
+func TestMessageStoreReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "whisper-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "messages")
+
+	store, err := openMessageStore(path, 100, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	topic := TopicType{0x01}
+	store.subscribe([][]byte{topic[:]})
+	store.archive(storeTestEnvelope(topic, 1), 10)
+	store.close()
+
+	// the filters are gone with the restart, the topic is released at reopening
+	store, err = openMessageStore(path, 100, 0, 50)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer store.close()
+	if st := store.topics[topic]; st == nil || st.filters != 0 || st.released != 50 {
+		t.Fatalf("have topic state %+v, want released at 50", st)
+	}
+	envs, _ := store.envelopes(nil, 0)
+	if len(envs) != 1 || envs[0].Nonce != 1 {
+		t.Fatalf("have envelopes %v after reopening, want the stored one", envs)
+	}
+}
+
+func TestMissedMessages(t *testing.T) {
+	InitSingleTest()
+
+	w := New(&DefaultConfig)
+	if err := w.OpenMessageStore("", DefaultMessageRetention, DefaultMaxTopicMessages); err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	w.Start(nil)
+	defer w.Stop()
+	api := NewPublicWhisperAPI(w)
+
+	keyID, err := w.GenerateSymKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	topic := TopicType{0xde, 0xad, 0xbe, 0xef}
+	crit := Criteria{SymKeyID: keyID, Topics: []TopicType{topic}}
+	if _, err := api.GetMissedMessages(Criteria{}, 0); err != ErrSymAsym {
+		t.Fatalf("have error %v for criteria without key, want %v", err, ErrSymAsym)
+	}
+	id, err := api.NewMessageFilter(crit)
+	if err != nil {
+		t.Fatalf("failed to install filter: %v", err)
+	}
+
+	params, err := generateMessageParams()
+	if err != nil {
+		t.Fatalf("failed generateMessageParams with seed %d: %s.", seed, err)
+	}
+	params.KeySym, _ = w.GetSymKey(keyID)
+	params.Topic = topic
+	params.PoW = DefaultMinimumPoW
+	params.WorkTime = 10
+	params.TTL = 50
+	msg, err := NewSentMessage(params)
+	if err != nil {
+		t.Fatalf("failed to create new message with seed %d: %s.", seed, err)
+	}
+	env, err := msg.Wrap(params)
+	if err != nil {
+		t.Fatalf("failed Wrap with seed %d: %s.", seed, err)
+	}
+	since := uint32(time.Now().Unix())
+	if err := w.Send(env); err != nil {
+		t.Fatalf("failed to send envelope: %v", err)
+	}
+
+	// the client goes away without polling its filter
+	if _, err := api.DeleteMessageFilter(id); err != nil {
+		t.Fatalf("failed to delete filter: %v", err)
+	}
+	messages, err := api.GetMissedMessages(crit, since)
+	if err != nil {
+		t.Fatalf("failed to get missed messages: %v", err)
+	}
+	if len(messages) != 1 || !bytes.Equal(messages[0].Payload, params.Payload) {
+		t.Fatalf("have %d missed messages, want the sent one", len(messages))
+	}
+	if messages, _ = api.GetMissedMessages(crit, since+1); len(messages) != 0 {
+		t.Fatalf("have %d messages received after the sent one, want 0", len(messages))
+	}
+
+	if _, err := NewPublicWhisperAPI(New(&DefaultConfig)).GetMissedMessages(crit, 0); err == nil {
+		t.Fatal("no error without a message store")
+	}
+}
+
+func TestMessageStoreRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "whisper-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w := New(&DefaultConfig)
+	if err := w.OpenMessageStore(filepath.Join(dir, "messages"), DefaultMessageRetention, DefaultMaxTopicMessages); err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	w.Start(nil)
+	f, err := generateFilter(t, true)
+	if err != nil {
+		t.Fatalf("failed to generate filter: %v", err)
+	}
+	if _, err := w.Subscribe(f); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	// a supervised restart keeps the filters, so their topics stay subscribed
+	w.Stop()
+	if err := w.Start(nil); err != nil {
+		t.Fatalf("failed to restart: %v", err)
+	}
+	defer w.Stop()
+	topic := BytesToTopic(f.Topics[0])
+	if st := w.store.topics[topic]; st == nil || st.filters != 1 || st.released != 0 {
+		t.Fatalf("have topic state %+v after restart, want subscribed once", st)
+	}
+}
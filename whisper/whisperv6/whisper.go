@@ -77,6 +77,8 @@ type Whisper struct {
 	messageQueue chan *Envelope // Message queue for normal whisper messages
 	p2pMsgQueue  chan *Envelope // Message queue for peer-to-peer messages (not to be forwarded any further)
 	quit         chan struct{}  // Channel used for graceful exit
+	faults       chan error     // Crashes of the background loops, reported to the node
+	wg           sync.WaitGroup // Background loops, waited for on Stop
 
 	settings syncmap.Map // holds configuration settings that can be dynamically changed
 
@@ -88,6 +90,8 @@ type Whisper struct {
 	stats   Statistics // Statistics of whisper node
 
 	mailServer MailServer // MailServer interface
+
+	store *messageStore // Messages of the subscribed topics kept across restarts, nil if disabled
 }
 
 // New creates a Whisper client ready to communicate through the Matrix P2P network.
@@ -105,10 +109,12 @@ func New(cfg *Config) *Whisper {
 		messageQueue:  make(chan *Envelope, messageQueueLimit),
 		p2pMsgQueue:   make(chan *Envelope, messageQueueLimit),
 		quit:          make(chan struct{}),
+		faults:        make(chan error, 1),
 		syncAllowance: DefaultSyncAllowance,
 	}
 
 	whisper.filters = NewFilters(whisper)
+	whisper.filters.maxPerIdentity = cfg.MaxIdentityFilters
 
 	whisper.settings.Store(minPowIdx, cfg.MinimumAcceptedPOW)
 	whisper.settings.Store(maxMsgSizeIdx, cfg.MaxMessageSize)
@@ -211,6 +217,19 @@ func (whisper *Whisper) RegisterServer(server MailServer) {
 	whisper.mailServer = server
 }
 
+// OpenMessageStore enables the persistence of the messages received on the
+// subscribed topics in the database at path, kept in memory if the path is
+// empty. Messages are kept for retention seconds, at most limit of them per
+// topic. It must be called before the whisper service is started.
+func (whisper *Whisper) OpenMessageStore(path string, retention uint32, limit int) error {
+	store, err := openMessageStore(path, retention, limit, uint32(time.Now().Unix()))
+	if err != nil {
+		return fmt.Errorf("open message store: %v", err)
+	}
+	whisper.store = store
+	return nil
+}
+
 // Protocols returns the whisper sub-protocols ran by this particular client.
 func (whisper *Whisper) Protocols() []p2p.Protocol {
 	return []p2p.Protocol{whisper.protocol}
@@ -551,6 +570,9 @@ func (whisper *Whisper) Subscribe(f *Filter) (string, error) {
 	s, err := whisper.filters.Install(f)
 	if err == nil {
 		whisper.updateBloomFilter(f)
+		if whisper.store != nil {
+			whisper.store.subscribe(f.Topics)
+		}
 	}
 	return s, err
 }
@@ -579,10 +601,14 @@ func (whisper *Whisper) GetFilter(id string) *Filter {
 
 // Unsubscribe removes an installed message handler.
 func (whisper *Whisper) Unsubscribe(id string) error {
+	f := whisper.filters.Get(id)
 	ok := whisper.filters.Uninstall(id)
 	if !ok {
 		return fmt.Errorf("Unsubscribe: Invalid ID")
 	}
+	if whisper.store != nil {
+		whisper.store.unsubscribe(f.Topics, uint32(time.Now().Unix()))
+	}
 	return nil
 }
 
@@ -600,11 +626,26 @@ func (whisper *Whisper) Send(envelope *Envelope) error {
 // of the Whisper protocol.
 func (whisper *Whisper) Start(*p2p.Server) error {
 	log.Info("started whisper v." + ProtocolVersionStr)
-	go whisper.update()
+	whisper.quit = make(chan struct{})
+
+	// the store is closed by Stop, reopened when the node restarts the service
+	if whisper.store != nil {
+		reopened, err := whisper.store.open(uint32(time.Now().Unix()))
+		if err != nil {
+			return fmt.Errorf("open message store: %v", err)
+		}
+		if reopened {
+			for _, f := range whisper.filters.All() {
+				whisper.store.subscribe(f.Topics)
+			}
+		}
+	}
 
 	numCPU := runtime.NumCPU()
+	whisper.wg.Add(numCPU + 1)
+	go whisper.update(whisper.quit)
 	for i := 0; i < numCPU; i++ {
-		go whisper.processQueue()
+		go whisper.processQueue(whisper.quit)
 	}
 
 	return nil
@@ -614,10 +655,34 @@ func (whisper *Whisper) Start(*p2p.Server) error {
 // of the Whisper protocol.
 func (whisper *Whisper) Stop() error {
 	close(whisper.quit)
+	whisper.wg.Wait()
+	if whisper.store != nil {
+		if err := whisper.store.close(); err != nil {
+			log.Warn("Failed to close whisper message store", "err", err)
+		}
+	}
 	log.Info("whisper stopped")
 	return nil
 }
 
+// Faults implements node.FaultReporter, delivering the crashes of the background
+// loops so a supervising node can restart the service.
+func (whisper *Whisper) Faults() <-chan error {
+	return whisper.faults
+}
+
+// recoverLoop is deferred by the background loops, turning a panic into a fault
+// reported to the node instead of crashing the process.
+func (whisper *Whisper) recoverLoop(name string) {
+	if r := recover(); r != nil {
+		log.Error("Whisper loop crashed", "loop", name, "err", r)
+		select {
+		case whisper.faults <- fmt.Errorf("%s loop crashed: %v", name, r):
+		default:
+		}
+	}
+}
+
 // HandlePeer is called by the underlying P2P layer when the whisper sub-protocol
 // connection is negotiated.
 func (whisper *Whisper) HandlePeer(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
@@ -815,6 +880,9 @@ func (whisper *Whisper) add(envelope *Envelope, isP2P bool) (bool, error) {
 		if whisper.mailServer != nil {
 			whisper.mailServer.Archive(envelope)
 		}
+		if whisper.store != nil {
+			whisper.store.archive(envelope, now)
+		}
 	}
 	return true, nil
 }
@@ -847,11 +915,14 @@ func (whisper *Whisper) checkOverflow() {
 }
 
 // processQueue delivers the messages to the watchers during the lifetime of the whisper node.
-func (whisper *Whisper) processQueue() {
+func (whisper *Whisper) processQueue(quit chan struct{}) {
+	defer whisper.wg.Done()
+	defer whisper.recoverLoop("queue")
+
 	var e *Envelope
 	for {
 		select {
-		case <-whisper.quit:
+		case <-quit:
 			return
 
 		case e = <-whisper.messageQueue:
@@ -865,9 +936,16 @@ func (whisper *Whisper) processQueue() {
 
 // update loops until the lifetime of the whisper node, updating its internal
 // state by expiring stale messages from the pool.
-func (whisper *Whisper) update() {
+func (whisper *Whisper) update(quit chan struct{}) {
+	defer whisper.wg.Done()
+	defer whisper.recoverLoop("update")
+
 	// Start a ticker to check for expirations
 	expire := time.NewTicker(expirationCycle)
+	defer expire.Stop()
+
+	prune := time.NewTicker(storePruneCycle)
+	defer prune.Stop()
 
 	// Repeat updates until termination is requested
 	for {
@@ -875,7 +953,14 @@ func (whisper *Whisper) update() {
 		case <-expire.C:
 			whisper.expire()
 
-		case <-whisper.quit:
+		case <-prune.C:
+			if whisper.store != nil {
+				if err := whisper.store.prune(uint32(time.Now().Unix())); err != nil {
+					log.Warn("Failed to prune whisper message store", "err", err)
+				}
+			}
+
+		case <-quit:
 			return
 		}
 	}
@@ -908,6 +993,15 @@ func (whisper *Whisper) expire() {
 	}
 }
 
+// MissedMessages returns the envelopes persisted for the topics, for all
+// queued topics if none is given, which arrived at or after the given time.
+func (whisper *Whisper) MissedMessages(topics []TopicType, since uint32) ([]*Envelope, error) {
+	if whisper.store == nil {
+		return nil, ErrNoMessageStore
+	}
+	return whisper.store.envelopes(topics, since)
+}
+
 // Stats returns the whisper node statistics.
 func (whisper *Whisper) Stats() Statistics {
 	whisper.statsMu.Lock()